@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The install-cni binary runs in a DaemonSet and installs the Istio CNI plugin on each node. The
+// plugin is reinstalled if the network configuration is rewritten, and removed on exit.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/cni/pkg/install"
+	"istio.io/istio/pkg/cmd"
+	"istio.io/istio/pkg/log"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+var (
+	config = install.Config{
+		PluginBinary:       "/opt/cni/bin/istio-cni",
+		KubeconfigFilename: "ZZZ-istio-cni-kubeconfig",
+	}
+	excludeNamespaces string
+	checkInterval     time.Duration
+	loggingOptions    = log.DefaultOptions()
+
+	rootCmd = &cobra.Command{
+		Use:   "install-cni",
+		Short: "Installs the Istio CNI plugin on the node.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := log.Configure(loggingOptions); err != nil {
+				return err
+			}
+			kubeconfig, err := serviceAccountKubeconfig()
+			if err != nil {
+				return err
+			}
+			config.Kubeconfig = kubeconfig
+			config.ExcludeNamespaces = splitNamespaces(excludeNamespaces)
+
+			confFile, err := install.Install(&config)
+			if err != nil {
+				return err
+			}
+
+			stop := make(chan struct{})
+			go cmd.WaitSignal(stop)
+			ticker := time.NewTicker(checkInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return install.Uninstall(&config, confFile)
+				case <-ticker.C:
+					if install.IsInstalled(confFile) {
+						continue
+					}
+					log.Infof("The %s plugin was removed from %s, reinstalling", install.PluginType, confFile)
+					if confFile, err = install.Install(&config); err != nil {
+						log.Errorf("Failed to reinstall the plugin: %v", err)
+					}
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	f := rootCmd.PersistentFlags()
+	f.StringVar(&config.MountedCNINetDir, "mounted-cni-net-dir", "/host/etc/cni/net.d",
+		"CNI configuration directory of the node, as mounted in the container")
+	f.StringVar(&config.CNINetDir, "cni-net-dir", "/etc/cni/net.d", "CNI configuration directory of the node")
+	f.StringVar(&config.CNIConfName, "cni-conf-name", "",
+		"Name of the network configuration file to update, by default the first one in lexicographic order")
+	f.StringVar(&config.MountedCNIBinDir, "mounted-cni-bin-dir", "/host/opt/cni/bin",
+		"CNI binary directory of the node, as mounted in the container")
+	f.StringVar(&config.CNIBinDir, "cni-bin-dir", "/opt/cni/bin", "CNI binary directory of the node")
+	f.StringVar(&config.PluginBinary, "plugin-binary", config.PluginBinary, "Path of the plugin binary to install")
+	f.StringVar(&config.LogLevel, "plugin-log-level", "info", "Log level of the plugin")
+	f.StringVar(&excludeNamespaces, "exclude-namespaces", "istio-system",
+		"Comma separated list of namespaces whose pods are not redirected")
+	f.DurationVar(&checkInterval, "check-interval", 10*time.Second,
+		"Interval between the checks that the plugin is still in the network configuration")
+
+	loggingOptions.AttachCobraFlags(rootCmd)
+	cmd.AddFlags(rootCmd)
+}
+
+// serviceAccountKubeconfig returns the kubeconfig of the plugin, using the credentials of the
+// service account of the installer.
+func serviceAccountKubeconfig() ([]byte, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	token, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	return install.Kubeconfig("https://"+net.JoinHostPort(host, port), ca, string(token)), nil
+}
+
+func splitNamespaces(s string) []string {
+	var namespaces []string
+	for _, ns := range strings.Split(s, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(-1)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The istio-cni binary is the CNI plugin setting up the traffic redirection of the injected pods.
+// It is run by the container runtime, and installed on the nodes by install-cni.
+package main
+
+import (
+	"istio.io/istio/cni/pkg/plugin"
+)
+
+func main() {
+	plugin.Main(plugin.New())
+}
@@ -0,0 +1,298 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package install installs the Istio CNI plugin on a node: it copies the plugin binary, writes the
+// kubeconfig used by the plugin, and chains the plugin after the network plugin of the node.
+package install
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"istio.io/istio/pkg/log"
+)
+
+// PluginType is the type of the plugin in the CNI network configuration.
+const PluginType = "istio-cni"
+
+// Config describes where the plugin is installed. The directories of the node are mounted in the
+// container running the installer, so both the mounted and the host paths are needed.
+type Config struct {
+	// MountedCNINetDir and CNINetDir are the CNI configuration directory in the container and on the node.
+	MountedCNINetDir string
+	CNINetDir        string
+	// CNIConfName is the name of the configuration file of the network, by default the first one
+	// in lexicographic order as selected by the kubelet.
+	CNIConfName string
+	// MountedCNIBinDir and CNIBinDir are the CNI binary directory in the container and on the node.
+	MountedCNIBinDir string
+	CNIBinDir        string
+	// PluginBinary is the path of the plugin binary in the container.
+	PluginBinary string
+	// KubeconfigFilename is the name of the kubeconfig of the plugin, in the CNI configuration directory.
+	KubeconfigFilename string
+	// Kubeconfig is the content of the kubeconfig.
+	Kubeconfig []byte
+	LogLevel   string
+	// ExcludeNamespaces are the namespaces whose pods are not redirected.
+	ExcludeNamespaces []string
+}
+
+// Install installs the plugin, and returns the path of the updated network configuration in the
+// container.
+func Install(c *Config) (string, error) {
+	if err := copyFile(c.PluginBinary, filepath.Join(c.MountedCNIBinDir, filepath.Base(c.PluginBinary)), 0755); err != nil {
+		return "", fmt.Errorf("failed to copy the plugin binary: %v", err)
+	}
+	kubeconfig := filepath.Join(c.MountedCNINetDir, c.KubeconfigFilename)
+	if err := writeFileAtomically(kubeconfig, c.Kubeconfig, 0600); err != nil {
+		return "", fmt.Errorf("failed to write the kubeconfig: %v", err)
+	}
+
+	confFile, err := SelectConfFile(c.MountedCNINetDir, c.CNIConfName)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(confFile)
+	if err != nil {
+		return "", err
+	}
+	conflist, err := InsertPlugin(data, PluginConfig(c))
+	if err != nil {
+		return "", fmt.Errorf("failed to update %s: %v", confFile, err)
+	}
+
+	// A single network configuration is converted to a list, which must use the .conflist extension.
+	target := confFile
+	if filepath.Ext(confFile) == ".conf" {
+		target = confFile + "list"
+	}
+	if err := writeFileAtomically(target, conflist, 0644); err != nil {
+		return "", err
+	}
+	if target != confFile {
+		if err := os.Remove(confFile); err != nil {
+			return "", err
+		}
+	}
+	log.Infof("Installed the %s plugin in %s", PluginType, target)
+	return target, nil
+}
+
+// Uninstall removes the plugin from the network configuration, and deletes the files of the plugin.
+func Uninstall(c *Config, confFile string) error {
+	data, err := ioutil.ReadFile(confFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		conflist, err := RemovePlugin(data)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %v", confFile, err)
+		}
+		if err := writeFileAtomically(confFile, conflist, 0644); err != nil {
+			return err
+		}
+	}
+	for _, file := range []string{
+		filepath.Join(c.MountedCNINetDir, c.KubeconfigFilename),
+		filepath.Join(c.MountedCNIBinDir, filepath.Base(c.PluginBinary)),
+	} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	log.Infof("Uninstalled the %s plugin from %s", PluginType, confFile)
+	return nil
+}
+
+// IsInstalled returns whether the plugin is in the network configuration, which may be rewritten
+// by the network plugin of the node.
+func IsInstalled(confFile string) bool {
+	data, err := ioutil.ReadFile(confFile)
+	if err != nil {
+		return false
+	}
+	conflist := map[string]interface{}{}
+	if err := json.Unmarshal(data, &conflist); err != nil {
+		return false
+	}
+	plugins, _ := conflist["plugins"].([]interface{})
+	return findPlugin(plugins) >= 0
+}
+
+// SelectConfFile returns the network configuration file to update, which is the first one in
+// lexicographic order unless a name is given.
+func SelectConfFile(dir, name string) (string, error) {
+	if name != "" {
+		return filepath.Join(dir, name), nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, f := range files {
+		switch filepath.Ext(f.Name()) {
+		case ".conf", ".conflist":
+			if !f.IsDir() {
+				names = append(names, f.Name())
+			}
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no CNI network configuration in %s", dir)
+	}
+	sort.Strings(names)
+	return filepath.Join(dir, names[0]), nil
+}
+
+// PluginConfig returns the configuration of the plugin in the list of the network.
+func PluginConfig(c *Config) map[string]interface{} {
+	excludeNamespaces := c.ExcludeNamespaces
+	if excludeNamespaces == nil {
+		excludeNamespaces = []string{}
+	}
+	return map[string]interface{}{
+		"type":      PluginType,
+		"log_level": c.LogLevel,
+		"kubernetes": map[string]interface{}{
+			"kubeconfig":         filepath.Join(c.CNINetDir, c.KubeconfigFilename),
+			"cni_bin_dir":        c.CNIBinDir,
+			"exclude_namespaces": excludeNamespaces,
+		},
+	}
+}
+
+// InsertPlugin chains the plugin at the end of a network configuration, which may be a single
+// configuration or a list, and returns the resulting list. A plugin installed previously is replaced.
+func InsertPlugin(data []byte, plugin map[string]interface{}) ([]byte, error) {
+	conf := map[string]interface{}{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, err
+	}
+
+	var plugins []interface{}
+	if list, ok := conf["plugins"]; ok {
+		if plugins, ok = list.([]interface{}); !ok {
+			return nil, fmt.Errorf("invalid plugins %v", list)
+		}
+	} else {
+		// A single configuration holds the name and the version of the network, which move to the list.
+		single := map[string]interface{}{}
+		list := map[string]interface{}{}
+		for k, v := range conf {
+			switch k {
+			case "cniVersion", "name":
+				list[k] = v
+			default:
+				single[k] = v
+			}
+		}
+		plugins = []interface{}{single}
+		conf = list
+	}
+	if i := findPlugin(plugins); i >= 0 {
+		plugins = append(plugins[:i], plugins[i+1:]...)
+	}
+	conf["plugins"] = append(plugins, plugin)
+	return json.MarshalIndent(conf, "", "  ")
+}
+
+// RemovePlugin removes the plugin from a network configuration list.
+func RemovePlugin(data []byte) ([]byte, error) {
+	conf := map[string]interface{}{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, err
+	}
+	plugins, ok := conf["plugins"].([]interface{})
+	if !ok {
+		return data, nil
+	}
+	if i := findPlugin(plugins); i >= 0 {
+		conf["plugins"] = append(plugins[:i], plugins[i+1:]...)
+	}
+	return json.MarshalIndent(conf, "", "  ")
+}
+
+func findPlugin(plugins []interface{}) int {
+	for i, p := range plugins {
+		if m, ok := p.(map[string]interface{}); ok && m["type"] == PluginType {
+			return i
+		}
+	}
+	return -1
+}
+
+// Kubeconfig returns the kubeconfig authenticating the plugin with the token of the service
+// account of the installer.
+func Kubeconfig(server string, caData []byte, token string) []byte {
+	return []byte(fmt.Sprintf(`# Kubeconfig file for the %s CNI plugin.
+apiVersion: v1
+kind: Config
+clusters:
+- name: local
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+users:
+- name: %s
+  user:
+    token: %s
+contexts:
+- name: %s-context
+  context:
+    cluster: local
+    user: %s
+current-context: %s-context
+`, PluginType, server, base64.StdEncoding.EncodeToString(caData), PluginType, strings.TrimSpace(token), PluginType, PluginType, PluginType))
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// The binary may be in use by the container runtime, so it is replaced rather than overwritten.
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func writeFileAtomically(path string, data []byte, mode os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	calicoConf = `{
+  "cniVersion": "0.3.1",
+  "name": "k8s-pod-network",
+  "type": "calico",
+  "ipam": {"type": "calico-ipam"}
+}`
+	calicoConflist = `{
+  "cniVersion": "0.3.1",
+  "name": "k8s-pod-network",
+  "plugins": [
+    {"type": "calico", "ipam": {"type": "calico-ipam"}},
+    {"type": "portmap", "capabilities": {"portMappings": true}}
+  ]
+}`
+)
+
+func testConfig(dir string) *Config {
+	return &Config{
+		MountedCNINetDir:   filepath.Join(dir, "net.d"),
+		CNINetDir:          "/etc/cni/net.d",
+		MountedCNIBinDir:   filepath.Join(dir, "bin"),
+		CNIBinDir:          "/opt/cni/bin",
+		PluginBinary:       filepath.Join(dir, "istio-cni"),
+		KubeconfigFilename: "ZZZ-istio-cni-kubeconfig",
+		Kubeconfig:         Kubeconfig("https://10.0.0.1:443", []byte("ca"), "token\n"),
+		LogLevel:           "info",
+		ExcludeNamespaces:  []string{"istio-system"},
+	}
+}
+
+func pluginTypes(t *testing.T, data []byte) []string {
+	t.Helper()
+	var conflist struct {
+		CNIVersion string                   `json:"cniVersion"`
+		Name       string                   `json:"name"`
+		Plugins    []map[string]interface{} `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &conflist); err != nil {
+		t.Fatal(err)
+	}
+	if conflist.CNIVersion != "0.3.1" || conflist.Name != "k8s-pod-network" {
+		t.Errorf("the network version and name are lost:\n%s", data)
+	}
+	var types []string
+	for _, p := range conflist.Plugins {
+		types = append(types, p["type"].(string))
+	}
+	return types
+}
+
+func TestInsertAndRemovePlugin(t *testing.T) {
+	plugin := PluginConfig(testConfig(""))
+	cases := []struct {
+		name string
+		conf string
+		want []string
+	}{
+		{"single configuration", calicoConf, []string{"calico", PluginType}},
+		{"configuration list", calicoConflist, []string{"calico", "portmap", PluginType}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := InsertPlugin([]byte(c.conf), plugin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if types := pluginTypes(t, got); !reflect.DeepEqual(types, c.want) {
+				t.Errorf("got plugins %v, want %v", types, c.want)
+			}
+
+			// Installing again replaces the plugin.
+			if got, err = InsertPlugin(got, plugin); err != nil {
+				t.Fatal(err)
+			}
+			if types := pluginTypes(t, got); !reflect.DeepEqual(types, c.want) {
+				t.Errorf("got plugins %v after a second install, want %v", types, c.want)
+			}
+
+			if got, err = RemovePlugin(got); err != nil {
+				t.Fatal(err)
+			}
+			if types := pluginTypes(t, got); !reflect.DeepEqual(types, c.want[:len(c.want)-1]) {
+				t.Errorf("got plugins %v after removal, want %v", types, c.want[:len(c.want)-1])
+			}
+		})
+	}
+}
+
+func TestInstallAndUninstall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "install-cni")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	c := testConfig(dir)
+	for _, d := range []string{c.MountedCNINetDir, c.MountedCNIBinDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		c.PluginBinary: "binary",
+		filepath.Join(c.MountedCNINetDir, "10-calico.conf"):    calicoConf,
+		filepath.Join(c.MountedCNINetDir, "20-bridge.conf"):    `{"cniVersion":"0.3.1","name":"bridge","type":"bridge"}`,
+		filepath.Join(c.MountedCNINetDir, "calico-kubeconfig"): "kubeconfig",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	confFile, err := Install(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(c.MountedCNINetDir, "10-calico.conflist"); confFile != want {
+		t.Errorf("installed in %s, want %s", confFile, want)
+	}
+	if _, err := os.Stat(filepath.Join(c.MountedCNINetDir, "10-calico.conf")); !os.IsNotExist(err) {
+		t.Errorf("the single configuration is not replaced by the list: %v", err)
+	}
+	if !IsInstalled(confFile) {
+		t.Error("the plugin is not installed")
+	}
+	for _, name := range []string{filepath.Join(c.MountedCNIBinDir, "istio-cni"), filepath.Join(c.MountedCNINetDir, c.KubeconfigFilename)} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s is not installed: %v", name, err)
+		}
+	}
+
+	if err := Uninstall(c, confFile); err != nil {
+		t.Fatal(err)
+	}
+	if IsInstalled(confFile) {
+		t.Error("the plugin is still installed")
+	}
+	for _, name := range []string{filepath.Join(c.MountedCNIBinDir, "istio-cni"), filepath.Join(c.MountedCNINetDir, c.KubeconfigFilename)} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s is not removed: %v", name, err)
+		}
+	}
+}
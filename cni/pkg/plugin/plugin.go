@@ -0,0 +1,316 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin implements the Istio CNI plugin, which sets up the redirection of the traffic of
+// the injected pods to the sidecar in their network namespace. It replaces the istio-init
+// container, which requires the NET_ADMIN capability.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/containernetworking/cni/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+)
+
+// Annotations set by the users to customize the redirection. When the CNI plugin is enabled, the
+// injector stamps all of them on the pods with the values of the installation.
+const (
+	annotationStatus              = "sidecar.istio.io/status"
+	annotationInterceptionMode    = "sidecar.istio.io/interceptionMode"
+	annotationStatusPort          = "status.sidecar.istio.io/port"
+	annotationIncludeIPRanges     = "traffic.sidecar.istio.io/includeOutboundIPRanges"
+	annotationExcludeIPRanges     = "traffic.sidecar.istio.io/excludeOutboundIPRanges"
+	annotationIncludeInboundPorts = "traffic.sidecar.istio.io/includeInboundPorts"
+	annotationExcludeInboundPorts = "traffic.sidecar.istio.io/excludeInboundPorts"
+	annotationKubevirtInterfaces  = "traffic.sidecar.istio.io/kubevirtInterfaces"
+	annotationDNSCapturePort      = "traffic.sidecar.istio.io/dnsCapturePort"
+)
+
+// Defaults of the injection template, used when the pods are not annotated.
+const (
+	proxyContainerName      = "istio-proxy"
+	interceptionModeNone    = "NONE"
+	defaultProxyPort        = 15001
+	defaultProxyUID         = "1337"
+	defaultStatusPort       = "15020"
+	defaultIncludeIPRanges  = capture.Wildcard
+	defaultTProxyMark       = 1337
+	defaultTProxyRouteTable = 133
+)
+
+// proxyInboundPorts are the ports of the sidecar itself, the Envoy admin and Prometheus ports, which
+// are never redirected to the sidecar. The status port is excluded as well, from its annotation.
+var proxyInboundPorts = []string{"15000", "15090"}
+
+// Error codes of the CNI specification, above 100 the codes are reserved to the plugins.
+const (
+	errorCodeInvalidConfig = 6
+	errorCodeInternal      = 100
+)
+
+// Config is the network configuration of the plugin, chained after the main plugin of the node.
+type Config struct {
+	types.NetConf
+	LogLevel   string `json:"log_level"`
+	Kubernetes struct {
+		Kubeconfig string `json:"kubeconfig"`
+		CNIBinDir  string `json:"cni_bin_dir"`
+		// ExcludeNamespaces are the namespaces whose pods are never redirected.
+		ExcludeNamespaces []string `json:"exclude_namespaces"`
+	} `json:"kubernetes"`
+}
+
+// K8sArgs are the extra arguments passed by the kubelet in CNI_ARGS,
+// e.g. "IgnoreUnknown=1;K8S_POD_NAMESPACE=default;K8S_POD_NAME=foo".
+type K8sArgs struct {
+	types.CommonArgs
+	K8S_POD_NAME               types.UnmarshallableString // nolint: golint
+	K8S_POD_NAMESPACE          types.UnmarshallableString // nolint: golint
+	K8S_POD_INFRA_CONTAINER_ID types.UnmarshallableString // nolint: golint
+}
+
+// Plugin sets up the traffic redirection of the pods.
+type Plugin struct {
+	// NewKubeClient returns the client used to get the pods from the kubeconfig of the configuration.
+	NewKubeClient func(kubeconfig string) (kubernetes.Interface, error)
+	// NetnsRunner returns the runner executing the commands in a network namespace.
+	NetnsRunner func(netns string) capture.Runner
+	// Stdout receives the result of the commands.
+	Stdout io.Writer
+}
+
+// New returns the plugin running the commands in the network namespaces of the pods.
+func New() *Plugin {
+	return &Plugin{
+		NewKubeClient: func(kubeconfig string) (kubernetes.Interface, error) {
+			return kube.CreateClientset(kubeconfig, "")
+		},
+		NetnsRunner: capture.NamespaceRunner,
+		Stdout:      os.Stdout,
+	}
+}
+
+// Main runs the plugin for the command of the environment, following the CNI specification.
+func Main(p *Plugin) {
+	skel.PluginMain(p.Add, p.Check, p.Del, version.PluginSupports("0.3.0", "0.3.1", "0.4.0"), "Istio CNI plugin")
+}
+
+// ParseConfig parses the network configuration of the plugin, including the result of the
+// previous plugin of the chain.
+func ParseConfig(data []byte) (*Config, error) {
+	conf := &Config{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, newError(errorCodeInvalidConfig, "failed to parse the network configuration: %v", err)
+	}
+	if err := version.ParsePrevResult(&conf.NetConf); err != nil {
+		return nil, newError(errorCodeInvalidConfig, "%v", err)
+	}
+	return conf, nil
+}
+
+func newError(code uint, format string, a ...interface{}) *types.Error {
+	return &types.Error{Code: code, Msg: fmt.Sprintf(format, a...)}
+}
+
+var logLevels = map[string]log.Level{
+	"debug": log.DebugLevel,
+	"info":  log.InfoLevel,
+	"warn":  log.WarnLevel,
+	"error": log.ErrorLevel,
+}
+
+// configureLogging writes the logs to the standard error, as the standard output is reserved to
+// the result, with the level of the network configuration.
+func configureLogging(conf *Config) {
+	o := log.DefaultOptions()
+	o.OutputPaths = []string{"stderr"}
+	if level, ok := logLevels[conf.LogLevel]; ok {
+		o.SetOutputLevel(log.DefaultScopeName, level)
+	}
+	_ = log.Configure(o)
+}
+
+// Add sets up the redirection of the pod if it is injected, and outputs the result of the
+// previous plugin.
+func (p *Plugin) Add(args *skel.CmdArgs) error {
+	conf, err := ParseConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+	configureLogging(conf)
+	if conf.PrevResult == nil {
+		return newError(errorCodeInvalidConfig, "%s must be chained after the network plugin of the node", conf.Type)
+	}
+	result, err := current.NewResultFromResult(conf.PrevResult)
+	if err != nil {
+		return newError(errorCodeInvalidConfig, "failed to convert the previous result: %v", err)
+	}
+	if err := p.setUpRedirection(conf, args, result); err != nil {
+		return err
+	}
+	versioned, err := result.GetAsVersion(conf.CNIVersion)
+	if err != nil {
+		return err
+	}
+	return versioned.PrintTo(p.Stdout)
+}
+
+func (p *Plugin) setUpRedirection(conf *Config, args *skel.CmdArgs, result *current.Result) error {
+	k8sArgs := &K8sArgs{}
+	if err := types.LoadArgs(args.Args, k8sArgs); err != nil {
+		return newError(errorCodeInvalidConfig, "invalid CNI_ARGS: %v", err)
+	}
+	podName, podNamespace := string(k8sArgs.K8S_POD_NAME), string(k8sArgs.K8S_POD_NAMESPACE)
+	if podName == "" || podNamespace == "" {
+		log.Infof("Container %s is not a Kubernetes pod, skipping", args.ContainerID)
+		return nil
+	}
+	for _, ns := range conf.Kubernetes.ExcludeNamespaces {
+		if ns == podNamespace {
+			log.Infof("Pod %s/%s is in an excluded namespace, skipping", podNamespace, podName)
+			return nil
+		}
+	}
+
+	client, err := p.NewKubeClient(conf.Kubernetes.Kubeconfig)
+	if err != nil {
+		return newError(errorCodeInternal, "failed to create the Kubernetes client: %v", err)
+	}
+	pod, err := client.CoreV1().Pods(podNamespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return newError(errorCodeInternal, "failed to get pod %s/%s: %v", podNamespace, podName, err)
+	}
+
+	captureConfig, skipReason, err := captureConfigForPod(pod)
+	if err != nil {
+		return newError(errorCodeInvalidConfig, "invalid redirection of pod %s/%s: %v", podNamespace, podName, err)
+	}
+	if captureConfig == nil {
+		log.Infof("Pod %s/%s %s, skipping", podNamespace, podName, skipReason)
+		return nil
+	}
+	captureConfig.EnableInboundIPv6 = isIPv6Result(result)
+	if err := captureConfig.Validate(); err != nil {
+		return newError(errorCodeInvalidConfig, "invalid redirection of pod %s/%s: %v", podNamespace, podName, err)
+	}
+	log.Infof("Setting up the redirection of pod %s/%s in %s", podNamespace, podName, args.Netns)
+	if err := capture.ApplyWith(capture.Build(captureConfig), p.NetnsRunner(args.Netns)); err != nil {
+		return newError(errorCodeInternal, "failed to set up the redirection of pod %s/%s: %v",
+			podNamespace, podName, err)
+	}
+	return nil
+}
+
+// Del does nothing, as the rules are removed along with the network namespace of the pod.
+func (p *Plugin) Del(args *skel.CmdArgs) error {
+	_, err := ParseConfig(args.StdinData)
+	return err
+}
+
+// Check does nothing, as the rules are not observable outside of the network namespace of the pod.
+func (p *Plugin) Check(args *skel.CmdArgs) error {
+	_, err := ParseConfig(args.StdinData)
+	return err
+}
+
+// captureConfigForPod returns the redirection of an injected pod from the annotations stamped by the
+// injector, or the reason why the pod is not redirected. Pods injected without the annotations use
+// the defaults of the injection template.
+func captureConfigForPod(pod *corev1.Pod) (*capture.Config, string, error) {
+	if pod.Spec.HostNetwork {
+		return nil, "uses the host network", nil
+	}
+	if _, ok := pod.Annotations[annotationStatus]; !ok {
+		return nil, "is not injected", nil
+	}
+	hasProxy := false
+	for _, c := range pod.Spec.Containers {
+		if c.Name == proxyContainerName {
+			hasProxy = true
+			break
+		}
+	}
+	if !hasProxy {
+		return nil, "has no " + proxyContainerName + " container", nil
+	}
+	mode := annotation(pod, annotationInterceptionMode, capture.InterceptionModeRedirect)
+	if mode == interceptionModeNone {
+		return nil, "has the " + interceptionModeNone + " interception mode", nil
+	}
+
+	dnsCapturePort, err := strconv.Atoi(annotation(pod, annotationDNSCapturePort, "0"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid DNS capture port: %v", err)
+	}
+
+	excludeInboundPorts := append([]string{annotation(pod, annotationStatusPort, defaultStatusPort)}, proxyInboundPorts...)
+	excludeInboundPorts = append(excludeInboundPorts, capture.SplitList(pod.Annotations[annotationExcludeInboundPorts])...)
+	return &capture.Config{
+		ProxyPort:               defaultProxyPort,
+		ProxyUIDs:               []string{defaultProxyUID},
+		ProxyGIDs:               []string{defaultProxyUID},
+		InboundInterceptionMode: mode,
+		InboundTProxyMark:       defaultTProxyMark,
+		InboundTProxyRouteTable: defaultTProxyRouteTable,
+		InboundPortsInclude:     capture.SplitList(annotation(pod, annotationIncludeInboundPorts, containerPorts(pod))),
+		InboundPortsExclude:     excludeInboundPorts,
+		OutboundIPRangesInclude: capture.SplitList(annotation(pod, annotationIncludeIPRanges, defaultIncludeIPRanges)),
+		OutboundIPRangesExclude: capture.SplitList(pod.Annotations[annotationExcludeIPRanges]),
+		KubevirtInterfaces:      capture.SplitList(pod.Annotations[annotationKubevirtInterfaces]),
+		DNSCapturePort:          dnsCapturePort,
+	}, "", nil
+}
+
+func annotation(pod *corev1.Pod, name, defaultValue string) string {
+	if value, ok := pod.Annotations[name]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+// containerPorts returns the ports of the application containers of the pod, which are redirected
+// by default.
+func containerPorts(pod *corev1.Pod) string {
+	var ports []string
+	for _, c := range pod.Spec.Containers {
+		if c.Name == proxyContainerName {
+			continue
+		}
+		for _, p := range c.Ports {
+			ports = append(ports, strconv.Itoa(int(p.ContainerPort)))
+		}
+	}
+	return strings.Join(ports, ",")
+}
+
+// isIPv6Result returns whether the first address assigned to the pod by the previous plugin is an
+// IPv6 address.
+func isIPv6Result(result *current.Result) bool {
+	return len(result.IPs) > 0 && result.IPs[0].Address.IP.To4() == nil
+}
@@ -0,0 +1,312 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+)
+
+const (
+	testNetns      = "/proc/1234/ns/net"
+	testPrevResult = `{"cniVersion":"0.3.1","ips":[{"version":"4","address":"10.1.0.5/24"}]}`
+	testConf       = `{
+  "cniVersion": "0.3.1",
+  "name": "k8s-pod-network",
+  "type": "istio-cni",
+  "log_level": "debug",
+  "kubernetes": {
+    "kubeconfig": "/etc/cni/net.d/ZZZ-istio-cni-kubeconfig",
+    "cni_bin_dir": "/opt/cni/bin",
+    "exclude_namespaces": ["istio-system"]
+  },
+  "prevResult": ` + testPrevResult + `
+}`
+)
+
+// command is a command run by the plugin in a network namespace.
+type command struct {
+	netns string
+	cmd   string
+	stdin string
+}
+
+type fakeNetns struct {
+	commands []command
+}
+
+func (f *fakeNetns) runner(netns string) capture.Runner {
+	return func(cmd []string, stdin string) (string, error) {
+		f.commands = append(f.commands, command{netns: netns, cmd: strings.Join(cmd, " "), stdin: stdin})
+		return "", nil
+	}
+}
+
+func injectedPod(namespace string, annotations map[string]string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hello",
+			Namespace:   namespace,
+			Annotations: map[string]string{annotationStatus: `{"version":""}`},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "hello", Ports: []corev1.ContainerPort{{ContainerPort: 80}, {ContainerPort: 8080}}},
+				{Name: proxyContainerName, Ports: []corev1.ContainerPort{{ContainerPort: 15090}}},
+			},
+		},
+	}
+	for k, v := range annotations {
+		pod.Annotations[k] = v
+	}
+	return pod
+}
+
+func newTestPlugin(pods ...*corev1.Pod) (*Plugin, *fakeNetns, *bytes.Buffer) {
+	client := fake.NewSimpleClientset()
+	for _, pod := range pods {
+		_, _ = client.CoreV1().Pods(pod.Namespace).Create(pod)
+	}
+	netns := &fakeNetns{}
+	out := &bytes.Buffer{}
+	return &Plugin{
+		NewKubeClient: func(string) (kubernetes.Interface, error) { return client, nil },
+		NetnsRunner:   netns.runner,
+		Stdout:        out,
+	}, netns, out
+}
+
+func addArgs(namespace string) *skel.CmdArgs {
+	return &skel.CmdArgs{
+		ContainerID: "container",
+		Netns:       testNetns,
+		IfName:      "eth0",
+		Args:        "IgnoreUnknown=1;K8S_POD_NAMESPACE=" + namespace + ";K8S_POD_NAME=hello;K8S_POD_INFRA_CONTAINER_ID=container",
+		StdinData:   []byte(testConf),
+	}
+}
+
+func TestAdd(t *testing.T) {
+	cases := []struct {
+		name string
+		pod  *corev1.Pod
+		// inbound and outbound are the rules expected in the IPv4 rules, none if empty.
+		inbound    []string
+		outbound   []string
+		notInbound []string
+	}{
+		{
+			name: "injected",
+			pod:  injectedPod("default", nil),
+			inbound: []string{
+				"-A ISTIO_INBOUND -p tcp --dport 80 -j ISTIO_IN_REDIRECT",
+				"-A ISTIO_INBOUND -p tcp --dport 8080 -j ISTIO_IN_REDIRECT",
+			},
+			notInbound: []string{"-A ISTIO_INBOUND -p tcp --dport 15090 -j ISTIO_IN_REDIRECT"},
+			outbound:   []string{"-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN", "-A ISTIO_OUTPUT -j ISTIO_REDIRECT"},
+		},
+		{
+			name: "annotations",
+			pod: injectedPod("default", map[string]string{
+				annotationIncludeInboundPorts: "*",
+				annotationExcludeInboundPorts: "9000",
+				annotationStatusPort:          "15021",
+				annotationIncludeIPRanges:     "10.96.0.0/12",
+			}),
+			inbound: []string{
+				"-A ISTIO_INBOUND -p tcp --dport 15021 -j RETURN",
+				"-A ISTIO_INBOUND -p tcp --dport 15000 -j RETURN",
+				"-A ISTIO_INBOUND -p tcp --dport 15090 -j RETURN",
+				"-A ISTIO_INBOUND -p tcp --dport 9000 -j RETURN",
+				"-A ISTIO_INBOUND -p tcp -j ISTIO_IN_REDIRECT",
+			},
+			outbound: []string{"-A ISTIO_OUTPUT -d 10.96.0.0/12 -j ISTIO_REDIRECT"},
+		},
+		{
+			// The annotations stamped by the injector with non-default values of the installation.
+			name: "injector annotations",
+			pod: injectedPod("default", map[string]string{
+				annotationInterceptionMode:    "TPROXY",
+				annotationStatusPort:          "15021",
+				annotationIncludeIPRanges:     "10.0.0.0/8",
+				annotationExcludeIPRanges:     "10.1.0.0/16",
+				annotationIncludeInboundPorts: "80",
+				annotationExcludeInboundPorts: "",
+				annotationDNSCapturePort:      "15053",
+			}),
+			inbound: []string{"-A ISTIO_INBOUND -p tcp --dport 80 -j ISTIO_TPROXY"},
+			notInbound: []string{
+				"-A ISTIO_INBOUND -p tcp --dport 8080 -j ISTIO_TPROXY",
+			},
+			outbound: []string{
+				"-A ISTIO_OUTPUT -d 10.1.0.0/16 -j RETURN",
+				"-A ISTIO_OUTPUT -d 10.0.0.0/8 -j ISTIO_REDIRECT",
+				"-A ISTIO_DNS -p udp -j REDIRECT --to-port 15053",
+			},
+		},
+		{
+			name: "not injected",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "hello"}}},
+			},
+		},
+		{
+			name: "no proxy container",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "hello",
+					Namespace:   "default",
+					Annotations: map[string]string{annotationStatus: "{}"},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "hello"}}},
+			},
+		},
+		{
+			name: "interception mode NONE",
+			pod:  injectedPod("default", map[string]string{annotationInterceptionMode: "NONE"}),
+		},
+		{
+			name: "excluded namespace",
+			pod:  injectedPod("istio-system", nil),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			plugin, netns, out := newTestPlugin(c.pod)
+			if err := plugin.Add(addArgs(c.pod.Namespace)); err != nil {
+				t.Fatalf("ADD failed: %v", err)
+			}
+			var result struct {
+				CNIVersion string `json:"cniVersion"`
+				IPs        []struct {
+					Address string `json:"address"`
+				} `json:"ips"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+				t.Fatalf("invalid result %s: %v", out.String(), err)
+			}
+			if result.CNIVersion != "0.3.1" || len(result.IPs) != 1 || result.IPs[0].Address != "10.1.0.5/24" {
+				t.Errorf("got result %s, want the previous result %s", out.String(), testPrevResult)
+			}
+
+			if len(c.inbound) == 0 && len(c.outbound) == 0 {
+				if len(netns.commands) != 0 {
+					t.Fatalf("got commands %v, want none", netns.commands)
+				}
+				return
+			}
+			var ipv4 string
+			for _, cmd := range netns.commands {
+				if cmd.netns != testNetns {
+					t.Errorf("command %q run in %s, want %s", cmd.cmd, cmd.netns, testNetns)
+				}
+				if cmd.cmd == "iptables-restore --noflush" {
+					ipv4 = cmd.stdin
+				}
+			}
+			for _, rule := range append(c.inbound, c.outbound...) {
+				if !strings.Contains(ipv4, rule+"\n") {
+					t.Errorf("rule %q not found in:\n%s", rule, ipv4)
+				}
+			}
+			for _, rule := range c.notInbound {
+				if strings.Contains(ipv4, rule+"\n") {
+					t.Errorf("unexpected rule %q in:\n%s", rule, ipv4)
+				}
+			}
+		})
+	}
+}
+
+func TestAddErrors(t *testing.T) {
+	plugin, _, _ := newTestPlugin(injectedPod("default", map[string]string{annotationIncludeInboundPorts: "http"}))
+
+	args := addArgs("default")
+	if err := plugin.Add(args); err == nil || !strings.Contains(err.Error(), `invalid inbound port "http"`) {
+		t.Errorf("got error %v for an invalid annotation", err)
+	}
+
+	plugin, _, _ = newTestPlugin(injectedPod("default", map[string]string{annotationDNSCapturePort: "dns"}))
+	if err := plugin.Add(args); err == nil || !strings.Contains(err.Error(), "invalid DNS capture port") {
+		t.Errorf("got error %v for an invalid DNS capture port", err)
+	}
+
+	args = addArgs("other")
+	if err := plugin.Add(args); err == nil || !strings.Contains(err.Error(), "failed to get pod other/hello") {
+		t.Errorf("got error %v for a missing pod", err)
+	}
+
+	args = addArgs("default")
+	args.StdinData = []byte(`{"cniVersion":"0.3.1","name":"k8s-pod-network","type":"istio-cni"}`)
+	if err := plugin.Add(args); err == nil || err.(*types.Error).Code != errorCodeInvalidConfig {
+		t.Errorf("got error %v without a previous result", err)
+	}
+
+	args = addArgs("default")
+	args.Args = "K8S_POD_NAMESPACE=default;K8S_POD_NAME=hello;UNKNOWN=1"
+	if err := plugin.Add(args); err == nil || err.(*types.Error).Code != errorCodeInvalidConfig {
+		t.Errorf("got error %v for unknown arguments", err)
+	}
+}
+
+func TestAddIPv6(t *testing.T) {
+	plugin, netns, _ := newTestPlugin(injectedPod("default", nil))
+	args := addArgs("default")
+	args.StdinData = []byte(strings.Replace(testConf, `"version":"4","address":"10.1.0.5/24"`, `"version":"6","address":"fd00::5/64"`, 1))
+	if err := plugin.Add(args); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, cmd := range netns.commands {
+		if cmd.cmd == "ip6tables-restore --noflush" {
+			found = true
+			if !strings.Contains(cmd.stdin, "-A ISTIO_OUTPUT -j ISTIO_REDIRECT") {
+				t.Errorf("IPv6 traffic is not redirected:\n%s", cmd.stdin)
+			}
+		}
+	}
+	if !found {
+		t.Error("no IPv6 rules applied")
+	}
+}
+
+func TestDelAndCheck(t *testing.T) {
+	plugin, netns, _ := newTestPlugin()
+	if err := plugin.Del(&skel.CmdArgs{StdinData: []byte(testConf)}); err != nil {
+		t.Errorf("DEL failed: %v", err)
+	}
+	if err := plugin.Check(&skel.CmdArgs{StdinData: []byte(testConf)}); err != nil {
+		t.Errorf("CHECK failed: %v", err)
+	}
+	if len(netns.commands) != 0 {
+		t.Errorf("DEL and CHECK ran commands %v", netns.commands)
+	}
+	if err := plugin.Del(&skel.CmdArgs{StdinData: []byte("{")}); err == nil {
+		t.Error("expected an error for an invalid configuration")
+	}
+}
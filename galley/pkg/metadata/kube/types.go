@@ -541,6 +541,17 @@ func init() {
 		Converter: converter.Get("identity"),
 	})
 
+	b.Add(schema.ResourceSpec{
+		Kind:      "LocalRateLimit",
+		ListKind:  "LocalRateLimitList",
+		Singular:  "localratelimit",
+		Plural:    "localratelimits",
+		Version:   "v1alpha3",
+		Group:     "networking.istio.io",
+		Target:    metadata.Types.Get("istio/networking/v1alpha3/localratelimits"),
+		Converter: converter.Get("identity"),
+	})
+
 	b.Add(schema.ResourceSpec{
 		Kind:      "ServiceEntry",
 		ListKind:  "ServiceEntryList",
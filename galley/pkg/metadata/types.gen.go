@@ -26,6 +26,9 @@ import (
 	// Register protos in "istio.io/api/rbac/v1alpha1"
 	_ "istio.io/api/rbac/v1alpha1"
 
+	// Register protos in "istio.io/istio/pilot/pkg/model/ratelimit"
+	_ "istio.io/istio/pilot/pkg/model/ratelimit"
+
 	// Register protos in "k8s.io/api/core/v1"
 	_ "k8s.io/api/core/v1"
 
@@ -166,6 +169,9 @@ var (
 	// istio/networking/v1alpha3/gateways metadata
 	IstioNetworkingV1alpha3Gateways resource.Info
 
+	// istio/networking/v1alpha3/localratelimits metadata
+	IstioNetworkingV1alpha3Localratelimits resource.Info
+
 	// istio/networking/v1alpha3/serviceentries metadata
 	IstioNetworkingV1alpha3Serviceentries resource.Info
 
@@ -350,6 +356,9 @@ func init() {
 	IstioNetworkingV1alpha3Gateways = b.Register(
 		"istio/networking/v1alpha3/gateways",
 		"type.googleapis.com/istio.networking.v1alpha3.Gateway")
+	IstioNetworkingV1alpha3Localratelimits = b.Register(
+		"istio/networking/v1alpha3/localratelimits",
+		"type.googleapis.com/istio.networking.ratelimit.LocalRateLimit")
 	IstioNetworkingV1alpha3Serviceentries = b.Register(
 		"istio/networking/v1alpha3/serviceentries",
 		"type.googleapis.com/istio.networking.v1alpha3.ServiceEntry")
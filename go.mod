@@ -41,6 +41,7 @@ require (
 	github.com/circonus-labs/circonus-gometrics v2.3.1+incompatible
 	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
 	github.com/containerd/continuity v0.0.0-20190426062206-aaeac12a7ffc // indirect
+	github.com/containernetworking/cni v0.7.1
 	github.com/coreos/go-oidc v0.0.0-20180117170138-065b426bd416
 	github.com/cpuguy83/go-md2man v1.0.8 // indirect
 	github.com/d4l3k/messagediff v1.2.1 // indirect
@@ -169,7 +170,7 @@ require (
 	github.com/uber/jaeger-lib v2.0.0+incompatible // indirect
 	github.com/yashtewari/glob-intersection v0.0.0-20180206001645-7af743e8ec84 // indirect
 	github.com/yl2chen/cidranger v0.0.0-20180214081945-928b519e5268
-	github.com/yuin/gopher-lua v0.0.0-20180316054350-84ea3a3c79b3 // indirect
+	github.com/yuin/gopher-lua v0.0.0-20180316054350-84ea3a3c79b3
 	go.opencensus.io v0.20.1
 	go.uber.org/atomic v1.3.1
 	go.uber.org/multierr v1.1.0
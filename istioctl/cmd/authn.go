@@ -23,12 +23,17 @@ import (
 )
 
 func tlsCheck() *cobra.Command {
+	var workloads bool
 	cmd := &cobra.Command{
 		Use:   "tls-check <pod-name[.namespace]> [<service>]",
 		Short: "Check whether TLS setting are matching between authentication policy and destination rules",
 		Long: `
 Check what authentication policies and destination rules pilot uses to config a proxy instance,
 and check if TLS settings are compatible between them.
+
+With --workloads, report the effective mTLS mode between every pair of workloads in the namespace
+instead, taking port level authentication policies and sidecar versions into account. Clients
+sending plaintext to STRICT servers are reported as conflicts.
 `,
 		Example: `
 # Check settings for pod "foo-656bd7df7c-5zp4s" in namespace default:
@@ -37,13 +42,31 @@ istioctl authn tls-check foo-656bd7df7c-5zp4s.default
 # Check settings for pod "foo-656bd7df7c-5zp4s" in namespace default, filtered on destintation
 service "bar" :
 istioctl authn tls-check foo-656bd7df7c-5zp4s.default bar
+
+# Report the mTLS mode between all workloads in namespace default:
+istioctl authn tls-check --workloads -n default
 `,
-		Args: cobra.MinimumNArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if workloads {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			kubeClient, err := clientExecFactory(kubeconfig, configContext)
 			if err != nil {
 				return err
 			}
+			if workloads {
+				// Each Pilot replica only reports the client sidecars connected to it, so ask all of them.
+				debug, err := kubeClient.AllPilotsDiscoveryDo(istioNamespace, "GET",
+					fmt.Sprintf("/debug/mtlsz?namespace=%s", handleNamespace()), nil)
+				if err != nil {
+					return err
+				}
+				mrw := pilot.MTLSReportWriter{Writer: cmd.OutOrStdout()}
+				return mrw.Print(debug)
+			}
 			podName, ns := inferPodInfo(args[0], handleNamespace())
 			debug, err := kubeClient.PilotDiscoveryDo(istioNamespace, "GET",
 				fmt.Sprintf("/debug/authenticationz?proxyID=%s.%s", podName, ns), nil)
@@ -57,6 +80,8 @@ istioctl authn tls-check foo-656bd7df7c-5zp4s.default bar
 			return tcw.PrintAll(debug)
 		},
 	}
+	cmd.PersistentFlags().BoolVar(&workloads, "workloads", false,
+		"Report the mTLS mode between all workloads of the namespace")
 	return cmd
 }
 
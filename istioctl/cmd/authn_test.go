@@ -56,6 +56,53 @@ details.default.svc.cluster.local:8080     OK         HTTP/mTLS     mTLS       d
 	}
 }
 
+func TestAuthnTlsCheckWorkloads(t *testing.T) {
+	clientExecFactory = mockExecClientMTLS
+
+	cases := []testCase{
+		{ // case 0
+			configs:        []model.Config{},
+			args:           strings.Split("authn tls-check --workloads foo-123456-7890", " "),
+			expectedRegexp: regexp.MustCompile("Error: unknown command \"foo-123456-7890\""),
+			wantException:  true,
+		},
+		{ // case 1
+			configs: []model.Config{},
+			args:    strings.Split("authn tls-check --workloads", " "),
+			expectedOutput: `CLIENT                     VERSION     details.default.svc.cluster.local:8080
+productpage-v1.default     1.1.0       CONFLICT
+
+CLIENT                     SERVER                 HOST:PORT                                  STATUS       SERVER     CLIENT     AUTHN POLICY     DESTINATION RULE     REASON
+productpage-v1.default     details-v1.default     details.default.svc.cluster.local:8080     CONFLICT     mTLS       HTTP       default/         -                    client sends plaintext to a STRICT server
+`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyOutput(t, c)
+		})
+	}
+}
+
+func TestAuthnTlsCheckWorkloadsReplicas(t *testing.T) {
+	clientExecFactory = mockExecClientMTLSReplicas
+
+	c := testCase{
+		configs: []model.Config{},
+		args:    strings.Split("authn tls-check --workloads", " "),
+		expectedOutput: `CLIENT                     VERSION     details.default.svc.cluster.local:8080     reviews.default.svc.cluster.local:9080
+productpage-v1.default     1.1.0       CONFLICT                                   OK
+
+CLIENT                     SERVER                 HOST:PORT                                  STATUS       SERVER     CLIENT     AUTHN POLICY     DESTINATION RULE     REASON
+productpage-v1.default     details-v1.default     details.default.svc.cluster.local:8080     CONFLICT     mTLS       HTTP       default/         -                    client sends plaintext to a STRICT server
+
+Aggregated from 2 Pilot replicas (istio-pilot-123456-7890, istio-pilot-123456-7891)
+`,
+	}
+	verifyOutput(t, c)
+}
+
 func TestAuthnTlsCheckNoPilot(t *testing.T) {
 	clientExecFactory = mockExecClientAuthNoPilot
 
@@ -93,6 +140,48 @@ func mockExecClientAuth(_, _ string) (kubernetes.ExecClient, error) {
 	}, nil
 }
 
+func mockExecClientMTLS(_, _ string) (kubernetes.ExecClient, error) {
+	return &mockExecConfig{
+		results: map[string][]byte{
+			"istio-pilot-123456-7890": []byte(`
+[
+{
+  "client": "productpage-v1.default",
+  "client_version": "1.1.0",
+  "server": "details-v1.default",
+  "host": "details.default.svc.cluster.local",
+  "port": 8080,
+  "authentication_policy_name": "default/",
+  "destination_rule_name": "-",
+  "server_protocol": "mTLS",
+  "client_protocol": "HTTP",
+  "TLS_conflict_status": "CONFLICT",
+  "reason": "client sends plaintext to a STRICT server"
+}]`),
+		},
+	}, nil
+}
+
+func mockExecClientMTLSReplicas(_, _ string) (kubernetes.ExecClient, error) {
+	mtls, _ := mockExecClientMTLS("", "")
+	results := mtls.(*mockExecConfig).results
+	results["istio-pilot-123456-7891"] = []byte(`
+[
+{
+  "client": "productpage-v1.default",
+  "client_version": "1.1.0",
+  "server": "reviews-v1.default",
+  "host": "reviews.default.svc.cluster.local",
+  "port": 9080,
+  "authentication_policy_name": "default/",
+  "destination_rule_name": "-",
+  "server_protocol": "mTLS",
+  "client_protocol": "mTLS",
+  "TLS_conflict_status": "OK"
+}]`)
+	return &mockExecConfig{results: results}, nil
+}
+
 func mockExecClientAuthNoPilot(_, _ string) (kubernetes.ExecClient, error) {
 	return &mockExecConfig{}, nil
 }
@@ -0,0 +1,217 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pilot/pkg/kube/inject"
+)
+
+const (
+	defaultInjectWebhookConfigName = "istio-sidecar-injector"
+	injectWebhookName              = "sidecar-injector.istio.io"
+	sidecarStatusAnnotation        = "sidecar.istio.io/status"
+)
+
+var (
+	// Create a kubernetes.Interface (or a fake clientset)
+	interfaceFactory = createInterface
+
+	// Ask the sidecar injector for the injection decision (or a fake one)
+	injectExplainer = explainWithWebhook
+)
+
+func checkInjectCmd() *cobra.Command {
+	var (
+		podFilename       string
+		webhookConfigName string
+	)
+
+	checkCmd := &cobra.Command{
+		Use:   "check-inject [<pod-name>]",
+		Short: "Explains whether a pod is injected with the Istio sidecar",
+		Long: `
+check-inject asks the sidecar injector whether it injects a pod, using the namespace selector of
+the webhook configuration and the namespace of the pod read from the cluster, and prints whether
+the pod is injected along with the rule which decided it.
+
+The pod is either a running pod, or read from a file. Without a pod, the decision for the pods
+of the namespace without any injection annotation or label is printed.
+`,
+		Example: `# Explain why the details-v1-5b6f6c7c8d-9xk2p pod is not injected
+istioctl experimental check-inject details-v1-5b6f6c7c8d-9xk2p -n bookinfo
+
+# Check a pod before creating it
+istioctl experimental check-inject -f pod.yaml -n bookinfo
+
+# Check the default injection of the pods of a namespace
+istioctl experimental check-inject -n bookinfo`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			if podFilename != "" && len(args) > 0 {
+				return fmt.Errorf("a pod name cannot be given with --filename")
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+
+			var pod *corev1.Pod
+			switch {
+			case podFilename != "":
+				if pod, err = readPodFile(podFilename); err != nil {
+					return err
+				}
+				if pod.Namespace, err = handleNamespaces(pod.Namespace); err != nil {
+					return err
+				}
+			case len(args) > 0:
+				ns, _ := handleNamespaces(namespace)
+				if pod, err = client.CoreV1().Pods(ns).Get(args[0], metav1.GetOptions{}); err != nil {
+					return err
+				}
+			default:
+				ns, _ := handleNamespaces(namespace)
+				pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns}}
+			}
+
+			decision, err := checkInjection(client, pod, webhookConfigName)
+			if err != nil {
+				return err
+			}
+			printInjectionDecision(c.OutOrStdout(), pod, decision)
+			return nil
+		},
+	}
+
+	checkCmd.PersistentFlags().StringVarP(&podFilename, "filename", "f", "", "Pod filename")
+	checkCmd.PersistentFlags().StringVar(&webhookConfigName, "webhookConfigName", defaultInjectWebhookConfigName,
+		"Name of the mutatingwebhookconfiguration of the sidecar injector")
+
+	return checkCmd
+}
+
+func readPodFile(filename string) (*corev1.Pod, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pod := &corev1.Pod{}
+	if err := yaml.Unmarshal(data, pod); err != nil {
+		return nil, fmt.Errorf("cannot parse pod %s: %v", filename, err)
+	}
+	if pod.Kind != "" && pod.Kind != "Pod" {
+		return nil, fmt.Errorf("%s holds a %s, only pods can be checked", filename, pod.Kind)
+	}
+	return pod, nil
+}
+
+// checkInjection returns the injection decision for a pod, from the sidecar injector of the webhook configuration.
+func checkInjection(client kubernetes.Interface, pod *corev1.Pod, webhookConfigName string) (inject.InjectionDecision, error) {
+	ns, err := client.CoreV1().Namespaces().Get(pod.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return inject.InjectionDecision{}, err
+	}
+
+	// The namespace selector is evaluated by the API server, so it is read from the webhook configuration.
+	webhookConfig, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(
+		webhookConfigName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return inject.InjectionDecision{
+			Rule:   inject.RuleNamespaceSelector,
+			Reason: fmt.Sprintf("the mutatingwebhookconfiguration %s does not exist", webhookConfigName),
+		}, nil
+	case err != nil:
+		return inject.InjectionDecision{}, err
+	}
+	var webhook *v1beta1.Webhook
+	for i := range webhookConfig.Webhooks {
+		if webhookConfig.Webhooks[i].Name == injectWebhookName || len(webhookConfig.Webhooks) == 1 {
+			webhook = &webhookConfig.Webhooks[i]
+			break
+		}
+	}
+	if webhook == nil || webhook.ClientConfig.Service == nil {
+		return inject.InjectionDecision{}, fmt.Errorf("the mutatingwebhookconfiguration %s has no %s webhook served by a service",
+			webhookConfigName, injectWebhookName)
+	}
+
+	return injectExplainer(client, webhook.ClientConfig.Service, &inject.ExplainRequest{
+		Pod:               pod,
+		Namespace:         ns,
+		NamespaceSelector: webhook.NamespaceSelector,
+	})
+}
+
+// explainWithWebhook posts the request to the explain endpoint of the sidecar injector, through the
+// service proxy of the API server.
+func explainWithWebhook(client kubernetes.Interface, service *v1beta1.ServiceReference,
+	req *inject.ExplainRequest) (inject.InjectionDecision, error) {
+	decision := inject.InjectionDecision{}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return decision, err
+	}
+	resp, err := client.CoreV1().RESTClient().Post().
+		Namespace(service.Namespace).
+		Resource("services").
+		Name(fmt.Sprintf("https:%s:443", service.Name)).
+		SubResource("proxy").
+		Suffix("explain").
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		DoRaw()
+	if err != nil {
+		return decision, fmt.Errorf("failed to query the sidecar injector %s.%s: %v %s",
+			service.Name, service.Namespace, err, string(resp))
+	}
+	if err := json.Unmarshal(resp, &decision); err != nil {
+		return decision, fmt.Errorf("invalid response of the sidecar injector: %v", err)
+	}
+	return decision, nil
+}
+
+func printInjectionDecision(w io.Writer, pod *corev1.Pod, decision inject.InjectionDecision) {
+	subject := fmt.Sprintf("Pods of namespace %s", pod.Namespace)
+	if pod.Name != "" {
+		subject = fmt.Sprintf("Pod %s.%s", pod.Name, pod.Namespace)
+	}
+	if decision.Inject {
+		fmt.Fprintf(w, "%s: sidecar injected\n", subject)
+	} else {
+		fmt.Fprintf(w, "%s: sidecar not injected\n", subject)
+	}
+	fmt.Fprintf(w, "Rule: %s\n", decision.Rule)
+	fmt.Fprintf(w, "Reason: %s\n", decision.Reason)
+	if decision.NamespaceOverride {
+		fmt.Fprintf(w, "The injection values are overridden for namespace %s\n", pod.Namespace)
+	}
+	if _, ok := pod.Annotations[sidecarStatusAnnotation]; ok {
+		fmt.Fprintf(w, "The pod already has the %s annotation of an injected pod\n", sidecarStatusAnnotation)
+	}
+}
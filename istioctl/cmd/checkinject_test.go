@@ -0,0 +1,174 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+
+	"istio.io/istio/pilot/pkg/kube/inject"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// mockInjectExplainer stands for the explain endpoint of a sidecar injector with the overrides of
+// namespace bookinfo.
+func mockInjectExplainer(_ kubernetes.Interface, service *v1beta1.ServiceReference,
+	req *inject.ExplainRequest) (inject.InjectionDecision, error) {
+	if service.Name != "istio-sidecar-injector" || service.Namespace != "istio-system" {
+		return inject.InjectionDecision{}, fmt.Errorf("unexpected sidecar injector %s.%s", service.Name, service.Namespace)
+	}
+	config := &inject.Config{
+		Policy: inject.InjectionPolicyEnabled,
+		NeverInjectSelector: []metav1.LabelSelector{{
+			MatchLabels: map[string]string{"app": "job"},
+		}},
+	}
+	decision := inject.ExplainInjection(config, req.NamespaceSelector, req.Namespace, req.Pod)
+	decision.NamespaceOverride = req.Pod.Namespace == "bookinfo"
+	return decision, nil
+}
+
+func mockInterfaceCheckInject(string) (kubernetes.Interface, error) {
+	objects := []runtime.Object{
+		&v1beta1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultInjectWebhookConfigName},
+			Webhooks: []v1beta1.Webhook{{
+				Name: injectWebhookName,
+				ClientConfig: v1beta1.WebhookClientConfig{
+					Service: &v1beta1.ServiceReference{Name: "istio-sidecar-injector", Namespace: "istio-system"},
+				},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"istio-injection": "enabled"},
+				},
+			}},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "bookinfo",
+			Labels: map[string]string{"istio-injection": "enabled"},
+		}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "details-v1",
+			Namespace:   "bookinfo",
+			Annotations: map[string]string{sidecarStatusAnnotation: "{}"},
+		}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      "migrate",
+			Namespace: "bookinfo",
+			Labels:    map[string]string{"app": "job"},
+		}},
+	}
+	return fake.NewSimpleClientset(objects...), nil
+}
+
+func TestCheckInject(t *testing.T) {
+	interfaceFactory = mockInterfaceCheckInject
+	injectExplainer = mockInjectExplainer
+	defer func() {
+		interfaceFactory = createInterface
+		injectExplainer = explainWithWebhook
+	}()
+
+	cases := []testCase{
+		{ // case 0
+			args: strings.Split("experimental check-inject -n default", " "),
+			expectedOutput: `Pods of namespace default: sidecar not injected
+Rule: NamespaceSelector
+Reason: the labels of namespace default do not match the namespace selector "istio-injection=enabled" of the webhook
+`,
+		},
+		{ // case 1
+			args: strings.Split("experimental check-inject details-v1 -n bookinfo", " "),
+			expectedOutput: `Pod details-v1.bookinfo: sidecar injected
+Rule: Policy
+Reason: the default injection policy is enabled
+The injection values are overridden for namespace bookinfo
+The pod already has the sidecar.istio.io/status annotation of an injected pod
+`,
+		},
+		{ // case 2
+			args: strings.Split("experimental check-inject migrate -n bookinfo", " "),
+			expectedOutput: `Pod migrate.bookinfo: sidecar not injected
+Rule: NeverInjectSelector
+Reason: the pod labels match the neverInjectSelector "app=job"
+The injection values are overridden for namespace bookinfo
+`,
+		},
+		{ // case 3
+			args:          strings.Split("experimental check-inject unknown -n bookinfo", " "),
+			wantException: true,
+		},
+		{ // case 4
+			args: strings.Split("experimental check-inject -n default --webhookConfigName=unknown", " "),
+			expectedOutput: `Pods of namespace default: sidecar not injected
+Rule: NamespaceSelector
+Reason: the mutatingwebhookconfiguration unknown does not exist
+`,
+		},
+	}
+
+	for i, c := range cases {
+		c.configs = []model.Config{}
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyOutput(t, c)
+		})
+	}
+}
+
+func TestExplainWithWebhook(t *testing.T) {
+	var gotPath string
+	var gotReq inject.ExplainRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(inject.InjectionDecision{Inject: true, Rule: inject.RulePolicy, Reason: "enabled"})
+	}))
+	defer server.Close()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "details-v1", Namespace: "bookinfo"}}
+	decision, err := explainWithWebhook(client, &v1beta1.ServiceReference{Name: "istio-sidecar-injector", Namespace: "istio-system"},
+		&inject.ExplainRequest{Pod: pod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/api/v1/namespaces/istio-system/services/https:istio-sidecar-injector:443/proxy/explain"; gotPath != want {
+		t.Errorf("got path %s, want %s", gotPath, want)
+	}
+	if gotReq.Pod == nil || gotReq.Pod.Name != "details-v1" {
+		t.Errorf("unexpected request %+v", gotReq)
+	}
+	if !decision.Inject || decision.Rule != inject.RulePolicy {
+		t.Errorf("unexpected decision %+v", decision)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/security/pkg/audit"
+)
+
+func issuedCerts() *cobra.Command {
+	var auditLog string
+
+	issuedCertsCmd := &cobra.Command{
+		Use:   "issued-certs <service account>",
+		Short: "Lists the certificates Citadel issued to a service account",
+		Long: `
+issued-certs lists the certificates Citadel issued to a service account of the namespace, from the
+audit records of Citadel.
+
+With '--audit-sink=kubernetes-event', Citadel records the certificates as events on the service
+accounts, which the API server only keeps for a limited time. With '--audit-sink=file', copy the audit
+log of Citadel and pass it with --audit-log.
+`,
+		Example: `# List the certificates issued to the sleep service account of the default namespace
+istioctl experimental issued-certs sleep -n default
+
+# List them from a copy of the audit log of Citadel
+kubectl -n istio-system cp <citadel pod>:/var/log/citadel/audit.log audit.log
+istioctl experimental issued-certs sleep -n default --audit-log audit.log`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			ns := handleNamespace()
+			var records []*audit.Record
+			if auditLog != "" {
+				all, err := audit.ReadFile(auditLog)
+				if err != nil {
+					return err
+				}
+				for _, record := range all {
+					if record.IssuedTo(ns, args[0]) {
+						records = append(records, record)
+					}
+				}
+			} else {
+				client, err := interfaceFactory(kubeconfig)
+				if err != nil {
+					return err
+				}
+				if records, err = audit.ListEvents(client.CoreV1(), ns, args[0]); err != nil {
+					return err
+				}
+			}
+			if len(records) == 0 {
+				c.Printf("No certificates issued to %s.%s\n", args[0], ns)
+				return nil
+			}
+			sort.Slice(records, func(i, j int) bool {
+				return records[i].IssuedAt.Before(records[j].IssuedAt)
+			})
+
+			w := tabwriter.NewWriter(c.OutOrStdout(), 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "SERIAL\tIDENTITIES\tREQUESTER\tSOURCE IP\tTTL\tISSUED AT")
+			for _, record := range records {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\n", record.Serial, strings.Join(record.SubjectIDs, ","),
+					record.Requester, record.SourceIP, time.Duration(record.TTLSeconds)*time.Second,
+					record.IssuedAt.Format(time.RFC3339))
+			}
+			return w.Flush()
+		},
+	}
+
+	issuedCertsCmd.PersistentFlags().StringVar(&auditLog, "audit-log", "",
+		"The audit log of Citadel to read the records from, instead of the events of the service account")
+
+	return issuedCertsCmd
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/audit"
+)
+
+func TestIssuedCerts(t *testing.T) {
+	issuedAt := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	records := []*audit.Record{
+		{
+			Serial:     "3fa29c01",
+			SubjectIDs: []string{"spiffe://cluster.local/ns/default/sa/sleep"},
+			Requester:  "spiffe://cluster.local/ns/default/sa/sleep",
+			SourceIP:   "10.1.2.3",
+			TTLSeconds: 3600,
+			IssuedAt:   issuedAt.Add(time.Hour),
+		},
+		{
+			Serial:     "1b",
+			SubjectIDs: []string{"spiffe://cluster.local/ns/default/sa/sleep"},
+			Requester:  audit.SecretControllerRequester,
+			TTLSeconds: 7776000,
+			IssuedAt:   issuedAt,
+		},
+		{
+			Serial:     "2c",
+			SubjectIDs: []string{"spiffe://cluster.local/ns/default/sa/httpbin"},
+			Requester:  audit.SecretControllerRequester,
+			TTLSeconds: 7776000,
+			IssuedAt:   issuedAt,
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	interfaceFactory = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer func() { interfaceFactory = createInterface }()
+	sink := audit.NewEventSink(client.CoreV1(), "istio-system")
+	file, err := ioutil.TempFile("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name()) // nolint: errcheck
+	for _, record := range records {
+		if err := sink.Write(record); err != nil {
+			t.Fatal(err)
+		}
+		line, _ := json.Marshal(record)
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = file.Close()
+
+	sleepCerts := "SERIAL    IDENTITIES                                  REQUESTER                                   SOURCE IP  TTL        ISSUED AT\n" +
+		"1b        spiffe://cluster.local/ns/default/sa/sleep  secret-controller                                      2160h0m0s  2019-05-01T10:00:00Z\n" +
+		"3fa29c01  spiffe://cluster.local/ns/default/sa/sleep  spiffe://cluster.local/ns/default/sa/sleep  10.1.2.3   1h0m0s     2019-05-01T11:00:00Z\n"
+	cases := []testCase{
+		{
+			args:           strings.Split("experimental issued-certs sleep -n default", " "),
+			expectedOutput: sleepCerts,
+		},
+		{
+			args:           strings.Split("experimental issued-certs sleep -n default --audit-log "+file.Name(), " "),
+			expectedOutput: sleepCerts,
+		},
+		{
+			args:           strings.Split("experimental issued-certs sleep -n foo", " "),
+			expectedOutput: "No certificates issued to sleep.foo\n",
+		},
+		{
+			args:           strings.Split("experimental issued-certs -n default", " "),
+			expectedOutput: "Error: accepts 1 arg(s), received 0\n",
+			wantException:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			verifyOutput(t, c)
+		})
+	}
+}
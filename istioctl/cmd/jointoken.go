@@ -0,0 +1,68 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/security/pkg/jointoken"
+)
+
+func joinToken() *cobra.Command {
+	var (
+		identity string
+		ttl      time.Duration
+	)
+
+	joinTokenCmd := &cobra.Command{
+		Use:   "join-token",
+		Short: "Creates a one-time join token for a node agent outside Kubernetes",
+		Long: `
+join-token creates a one-time token bound to a SPIFFE identity in the ` + jointoken.SecretName + ` secret of
+the Istio namespace, and prints it.
+
+A node agent started with "--env jointoken" and the token in the "--join-token" file presents it to
+Citadel for its initial certificate, and renews the certificate with mTLS afterwards. Citadel accepts
+the token once, before it expires, when started with "--join-token-attestation".
+`,
+		Example: `# Create a join token for a VM running the database service account of the vm namespace
+istioctl experimental join-token --identity spiffe://cluster.local/ns/vm/sa/database --ttl 1h > join-token`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if identity == "" {
+				return fmt.Errorf("--identity must be set")
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			token, err := jointoken.NewController(istioNamespace, client.CoreV1()).Create(identity, ttl)
+			if err != nil {
+				return err
+			}
+			c.Println(token)
+			return nil
+		},
+	}
+
+	joinTokenCmd.PersistentFlags().StringVar(&identity, "identity", "",
+		"The identity the token is bound to, e.g. spiffe://cluster.local/ns/<namespace>/sa/<service account>")
+	joinTokenCmd.PersistentFlags().DurationVar(&ttl, "ttl", time.Hour, "The duration the token is valid for")
+
+	return joinTokenCmd
+}
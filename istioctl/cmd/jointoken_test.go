@@ -0,0 +1,63 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/jointoken"
+)
+
+func TestJoinToken(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	interfaceFactory = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer func() { interfaceFactory = createInterface }()
+
+	cases := []testCase{
+		{
+			args:           strings.Split("experimental join-token", " "),
+			expectedOutput: "Error: --identity must be set\n",
+			wantException:  true,
+		},
+		{
+			args:           strings.Split("experimental join-token --identity database", " "),
+			expectedOutput: "Error: identity \"database\" is not a SPIFFE ID\n",
+			wantException:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			verifyOutput(t, c)
+		})
+	}
+
+	var out bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split(
+		"experimental join-token --identity spiffe://cluster.local/ns/vm/sa/database --ttl 10m", " "))
+	rootCmd.SetOutput(&out)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Failed to create the join token: %v", err)
+	}
+	token := strings.TrimSpace(out.String())
+	id, err := jointoken.NewController("istio-system", client.CoreV1()).Consume(token)
+	if err != nil || id != "spiffe://cluster.local/ns/vm/sa/database" {
+		t.Errorf("Consume(%q) = %q, %v", token, id, err)
+	}
+}
@@ -56,7 +56,11 @@ func getMeshConfigFromConfigMap(kubeconfig string) (*meshconfig.MeshConfig, erro
 	if err != nil {
 		return nil, err
 	}
+	return readMeshConfig(client)
+}
 
+// readMeshConfig reads the mesh config from the istio ConfigMap of the cluster.
+func readMeshConfig(client kubernetes.Interface) (*meshconfig.MeshConfig, error) {
 	config, err := client.CoreV1().ConfigMaps(istioNamespace).Get(meshConfigMapName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("could not read valid configmap %q from namespace  %q: %v - "+
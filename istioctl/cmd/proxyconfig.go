@@ -17,6 +17,8 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"net/url"
+	"strconv"
 
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
@@ -24,6 +26,8 @@ import (
 	"istio.io/istio/istioctl/pkg/writer/envoy/clusters"
 	"istio.io/istio/istioctl/pkg/writer/envoy/configdump"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/proxy/envoy/history"
+	"istio.io/istio/pkg/envoy/compare"
 )
 
 const (
@@ -40,6 +44,9 @@ var (
 	routeName string
 
 	clusterName, status string
+
+	diffEpoch, diffFromEpoch, diffToEpoch int
+	diffStatusPort                        int
 )
 
 func handleNamespace() string {
@@ -68,6 +75,61 @@ func setupConfigdumpEnvoyConfigWriter(podName, podNamespace string, out io.Write
 	return cw, nil
 }
 
+// diffPodConfigDumps prints the diffs between the config dumps of the Envoys of two pods.
+func diffPodConfigDumps(fromPod, toPod string, out io.Writer) error {
+	kubeClient, err := clientExecFactory(kubeconfig, configContext)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %v", err)
+	}
+	fromName, fromNs := inferPodInfo(fromPod, handleNamespace())
+	from, err := kubeClient.EnvoyDo(fromName, fromNs, "GET", "config_dump", nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute command on envoy: %v", err)
+	}
+	toName, toNs := inferPodInfo(toPod, handleNamespace())
+	to, err := kubeClient.EnvoyDo(toName, toNs, "GET", "config_dump", nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute command on envoy: %v", err)
+	}
+	c, err := compare.NewEnvoyComparator(out, fromName+"."+fromNs, from, toName+"."+toNs, to)
+	if err != nil {
+		return err
+	}
+	if err := c.BootstrapDiff(); err != nil {
+		return err
+	}
+	return c.Diff()
+}
+
+// diffEpochConfigs prints the diffs between the configs of two epochs of the Envoy of a pod,
+// recorded by the pilot agent.
+func diffEpochConfigs(podName, podNamespace string, out io.Writer) error {
+	kubeClient, err := clientExecFactory(kubeconfig, configContext)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %v", err)
+	}
+	query := url.Values{}
+	if diffFromEpoch >= 0 || diffToEpoch >= 0 {
+		if diffFromEpoch < 0 || diffToEpoch < 0 {
+			return fmt.Errorf("both --from and --to must be set")
+		}
+		query.Set("from", strconv.Itoa(diffFromEpoch))
+		query.Set("to", strconv.Itoa(diffToEpoch))
+	} else if diffEpoch >= 0 {
+		query.Set("epoch", strconv.Itoa(diffEpoch))
+	}
+	path := history.ConfigDiffPath
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	diff, err := kubeClient.PilotAgentDo(podName, podNamespace, diffStatusPort, "GET", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute command on pilot agent: %v", err)
+	}
+	_, err = out.Write(diff)
+	return err
+}
+
 // TODO(fisherxu): migrate this to config dump when implemented in Envoy
 // Issue to track -> https://github.com/envoyproxy/envoy/issues/3362
 func setupClustersEnvoyConfigWriter(podName, podNamespace string, out io.Writer) (*clusters.ConfigWriter, error) {
@@ -94,7 +156,7 @@ func proxyConfig() *cobra.Command {
 		Short: "Retrieve information about proxy configuration from Envoy [kube only]",
 		Long:  `A group of commands used to retrieve information about proxy configuration from the Envoy config dump`,
 		Example: `  # Retrieve information about proxy configuration from an Envoy instance.
-  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap> <pod-name[.namespace]>`,
+  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap|diff> <pod-name[.namespace]>`,
 		Aliases: []string{"pc"},
 	}
 
@@ -291,7 +353,40 @@ func proxyConfig() *cobra.Command {
 		},
 	}
 
-	configCmd.AddCommand(clusterConfigCmd, listenerConfigCmd, routeConfigCmd, bootstrapConfigCmd, endpointConfigCmd)
+	diffConfigCmd := &cobra.Command{
+		Use:   "diff <pod-name[.namespace]> [<pod-name[.namespace]>]",
+		Short: "Diffs the Envoy configuration of two pods, or of a pod across hot restarts",
+		Long: `Diff the bootstrap, cluster, listener and route configuration of the Envoy instances in two pods.
+With a single pod, diff the configuration of two restart epochs of its Envoy instance, recorded by the pilot
+agent when the proxy is hot restarted. The pilot agent keeps the configuration of the last epochs only, and
+only if started with a positive --configHistorySize, which is 0 by default.`,
+		Example: `  # Diff the configuration of the Envoys of two pods.
+  istioctl proxy-config diff <pod-name[.namespace]> <pod-name[.namespace]>
+
+  # Diff the configuration of the latest epoch of the Envoy of a pod with the previous one.
+  istioctl proxy-config diff <pod-name[.namespace]>
+
+  # Diff the configuration of the epoch 3 of the Envoy of a pod with the epoch 1.
+  istioctl proxy-config diff <pod-name[.namespace]> --from 1 --to 3
+`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) == 2 {
+				return diffPodConfigDumps(args[0], args[1], c.OutOrStdout())
+			}
+			podName, ns := inferPodInfo(args[0], handleNamespace())
+			return diffEpochConfigs(podName, ns, c.OutOrStdout())
+		},
+	}
+
+	diffConfigCmd.PersistentFlags().IntVar(&diffEpoch, "epoch", -1,
+		"Epoch diffed with the previous one, the latest epoch by default")
+	diffConfigCmd.PersistentFlags().IntVar(&diffFromEpoch, "from", -1, "Epoch to diff from, along with --to")
+	diffConfigCmd.PersistentFlags().IntVar(&diffToEpoch, "to", -1, "Epoch to diff to, along with --from")
+	diffConfigCmd.PersistentFlags().IntVar(&diffStatusPort, "status-port", 15020, "Status port of the pilot agent")
+
+	configCmd.AddCommand(clusterConfigCmd, listenerConfigCmd, routeConfigCmd, bootstrapConfigCmd, endpointConfigCmd,
+		diffConfigCmd)
 
 	return configCmd
 }
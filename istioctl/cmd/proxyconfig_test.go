@@ -47,7 +47,14 @@ type mockExecConfig struct {
 
 func TestProxyConfig(t *testing.T) {
 	cannedConfig := map[string][]byte{
-		"details-v1-5b7f94f9bc-wp5tb": util.ReadFile("../pkg/writer/compare/testdata/envoyconfigdump.json", t),
+		"details-v1-5b7f94f9bc-wp5tb": util.ReadFile("../../pkg/envoy/compare/testdata/envoyconfigdump.json", t),
+	}
+	diffConfig := map[string][]byte{
+		"details-v1-5b7f94f9bc-wp5tb": util.ReadFile("../../pkg/envoy/compare/testdata/envoyconfigdump.json", t),
+		"details-v2-7f5b9c6b7d-x2lkq": util.ReadFile("../../pkg/envoy/compare/testdata/diffenvoyconfigdump.json", t),
+	}
+	epochDiffConfig := map[string][]byte{
+		"details-v1-5b7f94f9bc-wp5tb": []byte("Rendered Bootstrap Match\n"),
 	}
 	endpointConfig := map[string][]byte{
 		"details-v1-5b7f94f9bc-wp5tb": util.ReadFile("../pkg/writer/envoy/clusters/testdata/clusters.json", t),
@@ -125,6 +132,28 @@ inbound|9080||productpage.default.svc.cluster.local     1
 172.17.0.14:15014     UNHEALTHY     outbound|15014||istio-policy.istio-system.svc.cluster.local
 `,
 		},
+		{ // case 12 diff invalid
+			args:           strings.Split("proxy-config diff invalid", " "),
+			expectedString: "unable to retrieve Pod: pods \"invalid\" not found",
+			wantException:  true, // "istioctl proxy-config diff invalid" should fail
+		},
+		{ // case 13 diff of two pods
+			execClientConfig: diffConfig,
+			args:             strings.Split("proxy-config diff details-v1-5b7f94f9bc-wp5tb details-v2-7f5b9c6b7d-x2lkq", " "),
+			expectedString: "--- details-v1-5b7f94f9bc-wp5tb.default Clusters\n" +
+				"+++ details-v2-7f5b9c6b7d-x2lkq.default Clusters\n",
+		},
+		{ // case 14 diff of the epochs of a pod
+			execClientConfig: epochDiffConfig,
+			args:             strings.Split("proxy-config diff details-v1-5b7f94f9bc-wp5tb --epoch 2", " "),
+			expectedOutput:   "Rendered Bootstrap Match\n",
+		},
+		{ // case 15 diff with a single epoch bound
+			execClientConfig: epochDiffConfig,
+			args:             strings.Split("proxy-config diff details-v1-5b7f94f9bc-wp5tb --from 1", " "),
+			expectedString:   "both --from and --to must be set",
+			wantException:    true,
+		},
 	}
 
 	for i, c := range cases {
@@ -199,6 +228,15 @@ func (client mockExecConfig) EnvoyDo(podName, podNamespace, method, path string,
 	return results, nil
 }
 
+// nolint: unparam
+func (client mockExecConfig) PilotAgentDo(podName, podNamespace string, port int, method, path string, body []byte) ([]byte, error) {
+	results, ok := client.results[podName]
+	if !ok {
+		return nil, fmt.Errorf("unable to retrieve Pod: pods %q not found", podName)
+	}
+	return results, nil
+}
+
 // nolint: unparam
 func (client mockExecConfig) PilotDiscoveryDo(pilotNamespace, method, path string, body []byte) ([]byte, error) {
 	for _, results := range client.results {
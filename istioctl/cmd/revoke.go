@@ -0,0 +1,92 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/security/pkg/revocation"
+)
+
+func revoke() *cobra.Command {
+	var (
+		serial   string
+		identity string
+		reason   string
+	)
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revokes a workload certificate or identity",
+		Long: `
+revoke adds a certificate, by serial number, or an identity to the revocations of Citadel in the
+` + revocation.ConfigMapName + ` configmap of the Istio namespace.
+
+Citadel no longer signs certificates for a revoked identity, and rejects the requests authenticated
+by a revoked certificate. It publishes the CRL of the revoked serial numbers in the configmap, which
+Pilot pushes to the sidecars when PILOT_CERT_REVOCATION_LIST_FILE is the mounted ` + revocation.CRLKey + `.
+The revocations take effect within the revocation check interval of Citadel.
+
+A revoked identity is not part of the CRL, since Citadel keeps no record of the serial numbers it issued:
+the certificates already issued to the identity stay valid for the sidecars until they expire. Revoke
+their serial numbers as well, e.g. from the audit records of Citadel, to reject them immediately.
+`,
+		Example: `# Revoke the certificate printed by "openssl x509 -serial"
+istioctl experimental revoke --serial 3f:a2:9c:01 --reason "key compromise"
+
+# Revoke an identity
+istioctl experimental revoke --identity spiffe://cluster.local/ns/default/sa/sleep`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if (serial == "") == (identity == "") {
+				return fmt.Errorf("exactly one of --serial or --identity must be set")
+			}
+			var entry revocation.Entry
+			var err error
+			if serial != "" {
+				entry, err = revocation.NewSerialEntry(serial, reason)
+			} else {
+				entry, err = revocation.NewIdentityEntry(identity, reason)
+			}
+			if err != nil {
+				return err
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			if err := revocation.NewController(istioNamespace, client.CoreV1()).Revoke(entry); err != nil {
+				return err
+			}
+			if entry.Serial != "" {
+				c.Printf("Revoked the certificate %s\n", entry.Serial)
+			} else {
+				c.Printf("Revoked the identity %s\n", entry.Identity)
+			}
+			return nil
+		},
+	}
+
+	revokeCmd.PersistentFlags().StringVar(&serial, "serial", "",
+		"The hexadecimal serial number of the revoked certificate, optionally separated by colons")
+	revokeCmd.PersistentFlags().StringVar(&identity, "identity", "",
+		"The revoked identity, e.g. spiffe://cluster.local/ns/<namespace>/sa/<service account>. The certificates "+
+			"already issued to it are not part of the CRL, and stay valid for the sidecars until they expire")
+	revokeCmd.PersistentFlags().StringVar(&reason, "reason", "", "The reason of the revocation")
+
+	return revokeCmd
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/revocation"
+)
+
+func TestRevoke(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	interfaceFactory = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer func() { interfaceFactory = createInterface }()
+
+	cases := []testCase{
+		{
+			args:           strings.Split("experimental revoke --serial 3F:A2:9C:01 --reason compromised", " "),
+			expectedOutput: "Revoked the certificate 3fa29c01\n",
+		},
+		{
+			args:           strings.Split("experimental revoke --identity spiffe://cluster.local/ns/default/sa/sleep", " "),
+			expectedOutput: "Revoked the identity spiffe://cluster.local/ns/default/sa/sleep\n",
+		},
+		{
+			args:           strings.Split("experimental revoke", " "),
+			expectedOutput: "Error: exactly one of --serial or --identity must be set\n",
+			wantException:  true,
+		},
+		{
+			args:           strings.Split("experimental revoke --serial xyz", " "),
+			expectedOutput: "Error: invalid serial number \"xyz\", must be hexadecimal\n",
+			wantException:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			verifyOutput(t, c)
+		})
+	}
+
+	denylist, _, err := revocation.NewController("istio-system", client.CoreV1()).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(denylist.Entries) != 2 || denylist.Entries[0].Serial != "3fa29c01" ||
+		denylist.Entries[0].Reason != "compromised" ||
+		denylist.Entries[1].Identity != "spiffe://cluster.local/ns/default/sa/sleep" {
+		t.Errorf("Unexpected revocations %+v", denylist.Entries)
+	}
+}
@@ -108,6 +108,11 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(convertIngress())
 	experimentalCmd.AddCommand(dashboard())
 	experimentalCmd.AddCommand(metricsCmd)
+	experimentalCmd.AddCommand(checkInjectCmd())
+	experimentalCmd.AddCommand(vmBundle())
+	experimentalCmd.AddCommand(revoke())
+	experimentalCmd.AddCommand(issuedCerts())
+	experimentalCmd.AddCommand(joinToken())
 
 	rootCmd.AddCommand(collateral.CobraCommand(rootCmd, &doc.GenManHeader{
 		Title:   "Istio Control",
@@ -189,6 +189,10 @@ func (client mockExecVersionConfig) EnvoyDo(podName, podNamespace, method, path
 	return nil, nil
 }
 
+func (client mockExecVersionConfig) PilotAgentDo(podName, podNamespace string, port int, method, path string, body []byte) ([]byte, error) {
+	return nil, nil
+}
+
 func (client mockExecVersionConfig) PilotDiscoveryDo(pilotNamespace, method, path string, body []byte) ([]byte, error) {
 	return nil, nil
 }
@@ -0,0 +1,312 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/security/pkg/k8s/controller"
+)
+
+const (
+	// The install directories of the certs and of the sidecar config on the VM.
+	vmCertsDir = "etc/certs"
+	vmEnvoyDir = "var/lib/istio/envoy"
+
+	// vmProxyUser is the user running the sidecar on the VM, owning its files.
+	vmProxyUser = "istio-proxy"
+
+	// citadelSecretPrefix is the prefix of the names of the secrets of the service accounts,
+	// written by Citadel.
+	citadelSecretPrefix = "istio."
+
+	citadelService = "istio-citadel"
+)
+
+// vmBundleParameters are the parameters of the bundle of a VM.
+type vmBundleParameters struct {
+	serviceAccount string
+	serviceCIDR    string
+	controlPlaneIP string
+}
+
+// vmBundleFile is a file of the bundle of a VM, at its install path relative to the root of
+// the VM.
+type vmBundleFile struct {
+	path string
+	mode int64
+	data []byte
+}
+
+func vmBundle() *cobra.Command {
+	var (
+		params      vmBundleParameters
+		output      string
+		register    bool
+		labels      []string
+		annotations []string
+	)
+
+	bundleCmd := &cobra.Command{
+		Use:   "vm-bundle <svcname> <ip> [name1:]port1 [name2:]port2 ...",
+		Short: "Generates the bundle onboarding a VM into the mesh",
+		Long: `
+vm-bundle generates a tarball with everything a VM needs to join the mesh, and registers the VM
+as an endpoint of the service like the register command.
+
+The bundle contains the root cert and the initial key and cert of the service account from
+Citadel, the cluster.env and sidecar.env configuration of the pilot agent, with the address of
+Pilot and the control plane auth policy of the mesh config, and the hosts entries of the control
+plane services. Its files are at their install path, the bundle is installed by extracting it at
+the root of the VM, and appending the hosts entries to /etc/hosts.
+
+The control plane services are reached on their internal load balancer IPs, read from the
+<service>-ilb or <service> services of the Istio namespace, or on --control-plane-ip.
+`,
+		Example: `# Generate the bundle of a VM with the IP 10.128.0.5 of the service vmhttp
+istioctl experimental vm-bundle vmhttp 10.128.0.5 http:8080 --service-cidr 10.55.240.0/20 -n bookinfo
+
+# Install the bundle on the VM
+sudo tar -xzf vmhttp-bundle.tar.gz -C / && cat /var/lib/istio/envoy/hosts | sudo tee -a /etc/hosts`,
+		Args: cobra.MinimumNArgs(3),
+		RunE: func(c *cobra.Command, args []string) error {
+			svcName, ip := args[0], args[1]
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("invalid IP %q", ip)
+			}
+			if params.serviceCIDR == "" {
+				return fmt.Errorf("the service IP range of the cluster must be set with --service-cidr")
+			}
+			ports := make([]kube.NamedPort, 0, len(args)-2)
+			for _, s := range args[2:] {
+				p, err := kube.Str2NamedPort(s)
+				if err != nil {
+					return err
+				}
+				ports = append(ports, p)
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			ns, _ := handleNamespaces(namespace)
+
+			files, err := buildVMBundle(client, ns, svcName, ip, ports, params)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				output = svcName + "-bundle.tar.gz"
+			}
+			f, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				return err
+			}
+			if err := writeVMBundle(f, files); err != nil {
+				_ = f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			c.Printf("Wrote the bundle of %s.%s to %s\n", svcName, ns, output)
+
+			if !register {
+				return nil
+			}
+			annotations = append(annotations, fmt.Sprintf("%s=%s", kube.KubeServiceAccountsOnVMAnnotation, params.serviceAccount))
+			if err := kube.RegisterEndpoint(client, ns, svcName, ip, ports, labels, annotations); err != nil {
+				return err
+			}
+			c.Printf("Registered %s as an endpoint of %s.%s\n", ip, svcName, ns)
+			return nil
+		},
+	}
+
+	bundleCmd.PersistentFlags().StringVarP(&output, "output", "o", "",
+		"File of the bundle, <svcname>-bundle.tar.gz by default")
+	bundleCmd.PersistentFlags().StringVarP(&params.serviceAccount, "serviceaccount", "s", "default",
+		"Service account of the VM")
+	bundleCmd.PersistentFlags().StringVar(&params.serviceCIDR, "service-cidr", "",
+		"Service IP range of the cluster, whose outbound traffic is captured by the sidecar of the VM")
+	bundleCmd.PersistentFlags().StringVar(&params.controlPlaneIP, "control-plane-ip", "",
+		"IP of the control plane services from the VM, e.g. the IP of a gateway. "+
+			"The internal load balancer IPs of the services are used by default")
+	bundleCmd.PersistentFlags().BoolVar(&register, "register", true,
+		"Register the VM as an endpoint of the service")
+	bundleCmd.PersistentFlags().StringSliceVarP(&labels, "labels", "l",
+		nil, "List of labels to apply if creating a service/endpoint; e.g. -l env=prod,vers=2")
+	bundleCmd.PersistentFlags().StringSliceVarP(&annotations, "annotations", "a",
+		nil, "List of string annotations to apply if creating a service/endpoint; e.g. -a foo=bar,test,x=y")
+
+	return bundleCmd
+}
+
+// buildVMBundle returns the files of the bundle of a VM.
+func buildVMBundle(client kubernetes.Interface, ns, svcName, ip string, ports []kube.NamedPort,
+	params vmBundleParameters) ([]vmBundleFile, error) {
+	secretName := citadelSecretPrefix + params.serviceAccount
+	secret, err := client.CoreV1().Secrets(ns).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not read the certs of service account %s.%s from secret %s: %v",
+			params.serviceAccount, ns, secretName, err)
+	}
+	var files []vmBundleFile
+	for _, cert := range []struct {
+		key  string
+		mode int64
+	}{
+		{controller.RootCertID, 0644},
+		{controller.CertChainID, 0644},
+		{controller.PrivateKeyID, 0600},
+	} {
+		data, ok := secret.Data[cert.key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s.%s has no %s", secretName, ns, cert.key)
+		}
+		files = append(files, vmBundleFile{path: path.Join(vmCertsDir, cert.key), mode: cert.mode, data: data})
+	}
+
+	meshConfig, err := readMeshConfig(client)
+	if err != nil {
+		return nil, err
+	}
+	proxyConfig := meshConfig.GetDefaultConfig()
+	pilotHost, _, err := net.SplitHostPort(proxyConfig.GetDiscoveryAddress())
+	if err != nil {
+		return nil, fmt.Errorf("invalid discovery address %q: %v", proxyConfig.GetDiscoveryAddress(), err)
+	}
+
+	inboundPorts := make([]string, 0, len(ports))
+	for _, p := range ports {
+		inboundPorts = append(inboundPorts, strconv.Itoa(int(p.Port)))
+	}
+	clusterEnv := &bytes.Buffer{}
+	fmt.Fprintf(clusterEnv, "ISTIO_SERVICE_CIDR=%s\n", params.serviceCIDR)
+	fmt.Fprintf(clusterEnv, "ISTIO_SYSTEM_NAMESPACE=%s\n", istioNamespace)
+	fmt.Fprintf(clusterEnv, "ISTIO_CP_AUTH=%s\n", proxyConfig.GetControlPlaneAuthPolicy())
+	fmt.Fprintf(clusterEnv, "ISTIO_INBOUND_PORTS=%s\n", strings.Join(inboundPorts, ","))
+	files = append(files, vmBundleFile{path: path.Join(vmEnvoyDir, "cluster.env"), mode: 0644, data: clusterEnv.Bytes()})
+
+	sidecarEnv := &bytes.Buffer{}
+	fmt.Fprintf(sidecarEnv, "ISTIO_NAMESPACE=%s\n", ns)
+	fmt.Fprintf(sidecarEnv, "ISTIO_SERVICE=%s\n", svcName)
+	fmt.Fprintf(sidecarEnv, "ISTIO_SVC_IP=%s\n", ip)
+	fmt.Fprintf(sidecarEnv, "PILOT_ADDRESS=%s\n", proxyConfig.GetDiscoveryAddress())
+	files = append(files, vmBundleFile{path: path.Join(vmEnvoyDir, "sidecar.env"), mode: 0644, data: sidecarEnv.Bytes()})
+
+	// Pilot is required, the other control plane services are optional.
+	hosts := &bytes.Buffer{}
+	pilotIP, err := controlPlaneIP(client, pilotHost, params.controlPlaneIP)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(hosts, "%s %s\n", pilotIP, pilotHost)
+	seen := map[string]bool{pilotHost: true}
+	optionalHosts := []string{citadelService + "." + istioNamespace}
+	for _, address := range []string{meshConfig.GetMixerCheckServer(), meshConfig.GetMixerReportServer()} {
+		if address == "" {
+			continue
+		}
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mixer address %q: %v", address, err)
+		}
+		optionalHosts = append(optionalHosts, host)
+	}
+	for _, host := range optionalHosts {
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		hostIP, err := controlPlaneIP(client, host, params.controlPlaneIP)
+		if err != nil {
+			log.Warnf("No hosts entry for %s: %v", host, err)
+			continue
+		}
+		fmt.Fprintf(hosts, "%s %s\n", hostIP, host)
+	}
+	files = append(files, vmBundleFile{path: path.Join(vmEnvoyDir, "hosts"), mode: 0644, data: hosts.Bytes()})
+
+	return files, nil
+}
+
+// controlPlaneIP returns the IP of a control plane host from the VM, the load balancer IP of
+// its internal load balancer service or of its service if not set.
+func controlPlaneIP(client kubernetes.Interface, host, ip string) (string, error) {
+	if ip != "" {
+		return ip, nil
+	}
+	// The host is <service>.<namespace>, possibly followed by the domain.
+	parts := strings.Split(host, ".")
+	ns := istioNamespace
+	if len(parts) > 1 {
+		ns = parts[1]
+	}
+	for _, name := range []string{parts[0] + "-ilb", parts[0]} {
+		svc, err := client.CoreV1().Services(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				return ingress.IP, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no load balancer IP for service %s.%s, set --control-plane-ip", parts[0], ns)
+}
+
+// writeVMBundle writes the files of the bundle of a VM as a gzipped tarball.
+func writeVMBundle(w io.Writer, files []vmBundleFile) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    f.path,
+			Mode:    f.mode,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+			Uname:   vmProxyUser,
+			Gname:   vmProxyUser,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
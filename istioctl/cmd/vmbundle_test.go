@@ -0,0 +1,183 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func vmBundleClient() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio.vmhttp", Namespace: "bookinfo"},
+			Data: map[string][]byte{
+				"root-cert.pem":  []byte("root"),
+				"cert-chain.pem": []byte("chain"),
+				"key.pem":        []byte("key"),
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultMeshConfigMapName, Namespace: "istio-system"},
+			Data: map[string]string{configMapKey: `
+mixerCheckServer: istio-policy.istio-system.svc.cluster.local:15004
+mixerReportServer: istio-telemetry.istio-system.svc.cluster.local:15004
+defaultConfig:
+  discoveryAddress: istio-pilot.istio-system:15011
+  controlPlaneAuthPolicy: MUTUAL_TLS
+`},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio-pilot-ilb", Namespace: "istio-system"},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.128.0.100"}},
+			}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio-policy", Namespace: "istio-system"},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.128.0.101"}},
+			}},
+		},
+	)
+}
+
+func readVMBundle(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Uname != vmProxyUser {
+			t.Errorf("%s is owned by %s, want %s", h.Name, h.Uname, vmProxyUser)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[fmt.Sprintf("%s %o", h.Name, h.Mode)] = string(data)
+	}
+}
+
+func TestVMBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vmbundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	output := filepath.Join(dir, "bundle.tar.gz")
+
+	client := vmBundleClient()
+	interfaceFactory = func(string) (kubernetes.Interface, error) { return client, nil }
+	defer func() { interfaceFactory = createInterface }()
+
+	verifyOutput(t, testCase{
+		args: strings.Split("experimental vm-bundle vmhttp 10.128.0.5 http:8080 grpc:9090 -n bookinfo "+
+			"-s vmhttp --service-cidr 10.55.240.0/20 -o "+output, " "),
+		expectedOutput: fmt.Sprintf("Wrote the bundle of vmhttp.bookinfo to %s\n"+
+			"Registered 10.128.0.5 as an endpoint of vmhttp.bookinfo\n", output),
+	})
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint: errcheck
+	want := map[string]string{
+		"etc/certs/root-cert.pem 644":  "root",
+		"etc/certs/cert-chain.pem 644": "chain",
+		"etc/certs/key.pem 600":        "key",
+		"var/lib/istio/envoy/cluster.env 644": `ISTIO_SERVICE_CIDR=10.55.240.0/20
+ISTIO_SYSTEM_NAMESPACE=istio-system
+ISTIO_CP_AUTH=MUTUAL_TLS
+ISTIO_INBOUND_PORTS=8080,9090
+`,
+		"var/lib/istio/envoy/sidecar.env 644": `ISTIO_NAMESPACE=bookinfo
+ISTIO_SERVICE=vmhttp
+ISTIO_SVC_IP=10.128.0.5
+PILOT_ADDRESS=istio-pilot.istio-system:15011
+`,
+		// Citadel and the telemetry service have no load balancer IP.
+		"var/lib/istio/envoy/hosts 644": `10.128.0.100 istio-pilot.istio-system
+10.128.0.101 istio-policy.istio-system.svc.cluster.local
+`,
+	}
+	if got := readVMBundle(t, f); !reflect.DeepEqual(got, want) {
+		t.Errorf("got bundle %v, want %v", got, want)
+	}
+
+	endpoints, err := client.CoreV1().Endpoints("bookinfo").Get("vmhttp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("the VM is not registered: %v", err)
+	}
+	if ip := endpoints.Subsets[0].Addresses[0].IP; ip != "10.128.0.5" {
+		t.Errorf("got endpoint IP %s, want 10.128.0.5", ip)
+	}
+}
+
+func TestVMBundleErrors(t *testing.T) {
+	interfaceFactory = func(string) (kubernetes.Interface, error) { return vmBundleClient(), nil }
+	defer func() { interfaceFactory = createInterface }()
+
+	cases := []struct {
+		args string
+		want string
+	}{
+		{
+			args: "experimental vm-bundle vmhttp 10.128.0.5 http:8080 -n bookinfo -s vmhttp",
+			want: "--service-cidr",
+		},
+		{
+			args: "experimental vm-bundle vmhttp 10.128.0 http:8080 -n bookinfo -s vmhttp --service-cidr 10.55.240.0/20",
+			want: "invalid IP",
+		},
+		{
+			args: "experimental vm-bundle vmhttp 10.128.0.5 http:8080 -n bookinfo --service-cidr 10.55.240.0/20",
+			want: "could not read the certs of service account default.bookinfo",
+		},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		rootCmd := GetRootCmd(strings.Split(c.args, " "))
+		rootCmd.SetOutput(&out)
+		if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want %q", c.args, err, c.want)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 
 	multierror "github.com/hashicorp/go-multierror"
@@ -56,6 +57,7 @@ type Client struct {
 // ExecClient is an interface for remote execution
 type ExecClient interface {
 	EnvoyDo(podName, podNamespace, method, path string, body []byte) ([]byte, error)
+	PilotAgentDo(podName, podNamespace string, port int, method, path string, body []byte) ([]byte, error)
 	AllPilotsDiscoveryDo(pilotNamespace, method, path string, body []byte) (map[string][]byte, error)
 	GetIstioVersions(namespace string) (*version.MeshInfo, error)
 	PilotDiscoveryDo(pilotNamespace, method, path string, body []byte) ([]byte, error)
@@ -180,6 +182,17 @@ func (client *Client) EnvoyDo(podName, podNamespace, method, path string, body [
 	return client.ExtractExecResult(podName, podNamespace, container, cmd)
 }
 
+// PilotAgentDo makes an http request to a port of the pilot agent in the specified pod, such as
+// its status port
+func (client *Client) PilotAgentDo(podName, podNamespace string, port int, method, path string, body []byte) ([]byte, error) {
+	container, err := client.GetPilotAgentContainer(podName, podNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve proxy container name: %v", err)
+	}
+	cmd := []string{pilotAgentPath, "request", "--port", strconv.Itoa(port), method, path, string(body)}
+	return client.ExtractExecResult(podName, podNamespace, container, cmd)
+}
+
 // ExtractExecResult wraps PodExec and return the execution result and error if has any.
 func (client *Client) ExtractExecResult(podName, podNamespace, container string, cmd []string) ([]byte, error) {
 	stdout, stderr, err := client.PodExec(podName, podNamespace, container, cmd)
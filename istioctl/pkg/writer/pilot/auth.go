@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
 	v2 "istio.io/istio/pilot/pkg/proxy/envoy/v2"
@@ -84,3 +85,135 @@ func tlsCheckPrintln(w io.Writer, entry v2.AuthenticationDebug) {
 		entry.ServerProtocol, entry.ClientProtocol,
 		entry.AuthenticationPolicyName, entry.DestinationRuleName)
 }
+
+// MTLSReportWriter enables printing of the namespace wide mTLS report aggregated from all Pilot responses
+type MTLSReportWriter struct {
+	Writer io.Writer
+}
+
+var tlsConflictSeverity = map[string]int{
+	"OK":           0,
+	"MAY CONFLICT": 1,
+	"CONFLICT":     2,
+}
+
+// Print takes the mtlsz responses of all Pilot replicas, keyed by replica, and outputs the status of each
+// client workload and destination as a matrix, followed by the details of the conflicting entries.
+// Each replica reports the client workloads connected to it against all the server workloads of the
+// service registry, so the merged responses cover every client.
+func (m *MTLSReportWriter) Print(mtlsDebug map[string][]byte) error {
+	dat, err := mergeMTLSDebug(mtlsDebug)
+	if err != nil {
+		return err
+	}
+	if len(dat) < 1 {
+		return fmt.Errorf("nothing to output")
+	}
+	sort.SliceStable(dat, func(i, j int) bool {
+		if dat[i].Host == dat[j].Host {
+			return dat[i].Port < dat[j].Port
+		}
+		return dat[i].Host < dat[j].Host
+	})
+
+	clients := []string{}
+	versions := map[string]string{}
+	destinations := []string{}
+	statuses := map[string]map[string]string{}
+	conflicts := []v2.WorkloadMTLSDebug{}
+	for _, entry := range dat {
+		destination := fmt.Sprintf("%s:%d", entry.Host, entry.Port)
+		if _, ok := statuses[entry.Client]; !ok {
+			clients = append(clients, entry.Client)
+			versions[entry.Client] = entry.ClientVersion
+			statuses[entry.Client] = map[string]string{}
+		}
+		if !containsString(destinations, destination) {
+			destinations = append(destinations, destination)
+		}
+		// A destination may be served by several workloads, report the worst status.
+		if current, ok := statuses[entry.Client][destination]; !ok ||
+			tlsConflictSeverity[entry.TLSConflictStatus] > tlsConflictSeverity[current] {
+			statuses[entry.Client][destination] = entry.TLSConflictStatus
+		}
+		if entry.TLSConflictStatus != "OK" {
+			conflicts = append(conflicts, entry)
+		}
+	}
+	sort.Strings(clients)
+
+	w := new(tabwriter.Writer).Init(m.Writer, 0, 8, 5, ' ', 0)
+	fmt.Fprintf(w, "CLIENT\tVERSION\t%s\n", strings.Join(destinations, "\t"))
+	for _, client := range clients {
+		row := make([]string, 0, len(destinations))
+		for _, destination := range destinations {
+			status, ok := statuses[client][destination]
+			if !ok {
+				status = "-"
+			}
+			row = append(row, status)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", client, versions[client], strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		fmt.Fprintln(m.Writer)
+		w = new(tabwriter.Writer).Init(m.Writer, 0, 8, 5, ' ', 0)
+		fmt.Fprintln(w, "CLIENT\tSERVER\tHOST:PORT\tSTATUS\tSERVER\tCLIENT\tAUTHN POLICY\tDESTINATION RULE\tREASON")
+		for _, entry := range conflicts {
+			fmt.Fprintf(w, "%s\t%s\t%s:%d\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Client, entry.Server,
+				entry.Host, entry.Port, entry.TLSConflictStatus, entry.ServerProtocol, entry.ClientProtocol,
+				entry.AuthenticationPolicyName, entry.DestinationRuleName, entry.Reason)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(mtlsDebug) > 1 {
+		fmt.Fprintf(m.Writer, "\nAggregated from %d Pilot replicas (%s)\n",
+			len(mtlsDebug), strings.Join(sortedKeys(mtlsDebug), ", "))
+	}
+	return nil
+}
+
+// mergeMTLSDebug merges the mtlsz responses of several Pilot replicas, dropping the entries reported
+// by more than one replica, as happens while a sidecar reconnects.
+func mergeMTLSDebug(mtlsDebug map[string][]byte) ([]v2.WorkloadMTLSDebug, error) {
+	merged := []v2.WorkloadMTLSDebug{}
+	seen := map[string]bool{}
+	for _, pilot := range sortedKeys(mtlsDebug) {
+		var dat []v2.WorkloadMTLSDebug
+		if err := json.Unmarshal(mtlsDebug[pilot], &dat); err != nil {
+			return nil, fmt.Errorf("unable to parse mTLS report of %s: %v", pilot, err)
+		}
+		for _, entry := range dat {
+			key := fmt.Sprintf("%s/%s/%s:%d", entry.Client, entry.Server, entry.Host, entry.Port)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, entry)
+		}
+	}
+	return merged, nil
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
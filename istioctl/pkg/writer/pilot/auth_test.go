@@ -131,3 +131,99 @@ func authInput() []v2.AuthenticationDebug {
 		},
 	}
 }
+
+func TestMTLSReportWriter_Print(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   map[string][]v2.WorkloadMTLSDebug
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "prints mTLS matrix and conflicts",
+			input: map[string][]v2.WorkloadMTLSDebug{"pilot-1": mtlsInput()},
+			want:  "testdata/mtlsReport.txt",
+		},
+		{
+			name:  "prints mTLS matrix without conflicts",
+			input: map[string][]v2.WorkloadMTLSDebug{"pilot-1": mtlsInput()[:1]},
+			want:  "testdata/mtlsReportNoConflict.txt",
+		},
+		{
+			name: "merges the reports of several Pilot replicas",
+			input: map[string][]v2.WorkloadMTLSDebug{
+				"pilot-1": mtlsInput()[:2],
+				"pilot-2": mtlsInput()[1:],
+			},
+			want: "testdata/mtlsReportReplicas.txt",
+		},
+		{
+			name:    "error if given non-mTLS info",
+			input:   map[string][]v2.WorkloadMTLSDebug{"pilot-1": nil},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &bytes.Buffer{}
+			mrw := MTLSReportWriter{Writer: got}
+			input := map[string][]byte{}
+			for pilot, debug := range tt.input {
+				input[pilot], _ = json.Marshal(debug)
+			}
+			err := mrw.Print(input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			want, _ := ioutil.ReadFile(tt.want)
+			if err := util.Compare(got.Bytes(), want); err != nil {
+				t.Errorf(err.Error())
+			}
+		})
+	}
+}
+
+func mtlsInput() []v2.WorkloadMTLSDebug {
+	return []v2.WorkloadMTLSDebug{
+		{
+			Client:                   "a.namespace1",
+			ClientVersion:            "1.1.0",
+			Server:                   "10.0.1.2",
+			Host:                     "host2",
+			Port:                     80,
+			AuthenticationPolicyName: "default/namespace1",
+			DestinationRuleName:      "destination-rule2/namespace1",
+			ServerProtocol:           "HTTP/mTLS",
+			ClientProtocol:           "mTLS",
+			TLSConflictStatus:        "OK",
+		},
+		{
+			Client:                   "a.namespace1",
+			ClientVersion:            "1.1.0",
+			Server:                   "10.0.1.2",
+			Host:                     "host2",
+			Port:                     9000,
+			AuthenticationPolicyName: "auth-policy2/namespace1",
+			DestinationRuleName:      "-",
+			ServerProtocol:           "mTLS",
+			ClientProtocol:           "HTTP",
+			TLSConflictStatus:        "CONFLICT",
+			Reason:                   "client sends plaintext to a STRICT server",
+		},
+		{
+			Client:                   "b.namespace1",
+			ClientVersion:            "1.0.6",
+			Server:                   "10.0.1.1",
+			Host:                     "host1",
+			Port:                     80,
+			AuthenticationPolicyName: "default/namespace1",
+			DestinationRuleName:      "destination-rule1/namespace1",
+			ServerProtocol:           "HTTP/mTLS",
+			ClientProtocol:           "mTLS",
+			TLSConflictStatus:        "CONFLICT",
+			Reason:                   "client sidecar 1.0.6 does not support PERMISSIVE servers",
+		},
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	ads "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/pkg/dns"
+	"istio.io/istio/pkg/log"
+)
+
+const dnsProxyRetryDelay = 5 * time.Second
+
+var (
+	dnsProxyAddress string
+	dnsResolvConf   string
+
+	errNameTableStreamClosed = errors.New("name table stream closed")
+)
+
+// dnsProxy runs the DNS server answering from the name table of Pilot, and keeps the table up
+// to date.
+type dnsProxy struct {
+	server           *dns.Server
+	discoveryAddress string
+	// node is the node of the proxy, as sent by Envoy, so that the name table has the services
+	// visible to the proxy.
+	node *core.Node
+	// tlsConfig is used to connect to Pilot, nil for plain text.
+	tlsConfig  *tls.Config
+	retryDelay time.Duration
+}
+
+func newDNSProxy(discoveryAddress string, node *core.Node, tlsConfig *tls.Config) (*dnsProxy, error) {
+	upstreams, err := dns.UpstreamResolvers(dnsResolvConf, dnsProxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	server, err := dns.NewServer(dnsProxyAddress, upstreams)
+	if err != nil {
+		return nil, err
+	}
+	return &dnsProxy{
+		server:           server,
+		discoveryAddress: discoveryAddress,
+		node:             node,
+		tlsConfig:        tlsConfig,
+		retryDelay:       dnsProxyRetryDelay,
+	}, nil
+}
+
+// proxyNode returns the node sent by the DNS proxy to Pilot: the node of Envoy, with its metadata.
+func proxyNode(id, cluster string, meta map[string]string) *core.Node {
+	fields := make(map[string]*types.Value, len(meta))
+	for k, v := range meta {
+		fields[k] = &types.Value{Kind: &types.Value_StringValue{StringValue: v}}
+	}
+	return &core.Node{
+		Id:       id,
+		Cluster:  cluster,
+		Metadata: &types.Struct{Fields: fields},
+	}
+}
+
+// Run serves the DNS queries and watches the name table until the context is canceled. The
+// queries are forwarded to the upstream resolvers until the first name table is received.
+func (p *dnsProxy) Run(ctx context.Context) {
+	go p.server.Run(ctx)
+	for {
+		err := p.watchNameTable(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		log.Warnf("Failed to watch the name table, retrying in %v: %v", p.retryDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.retryDelay):
+		}
+	}
+}
+
+func (p *dnsProxy) watchNameTable(ctx context.Context) error {
+	opt := grpc.WithInsecure()
+	if p.tlsConfig != nil {
+		opt = grpc.WithTransportCredentials(credentials.NewTLS(p.tlsConfig))
+	}
+	conn, err := grpc.DialContext(ctx, p.discoveryAddress, opt)
+	if err != nil {
+		return err
+	}
+	defer conn.Close() // nolint: errcheck
+
+	stream, err := ads.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&xdsapi.DiscoveryRequest{Node: p.node, TypeUrl: dns.NameTableType}); err != nil {
+		return err
+	}
+	version := ""
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("%v: %v", errNameTableStreamClosed, err)
+		}
+		ack := &xdsapi.DiscoveryRequest{
+			Node:          p.node,
+			TypeUrl:       dns.NameTableType,
+			ResponseNonce: resp.Nonce,
+		}
+		nt, err := nameTable(resp)
+		if err != nil {
+			log.Warnf("Rejected the name table version %s: %v", resp.VersionInfo, err)
+			ack.ErrorDetail = &rpc.Status{Code: int32(codes.InvalidArgument), Message: err.Error()}
+		} else {
+			p.server.UpdateNameTable(nt)
+			version = resp.VersionInfo
+		}
+		ack.VersionInfo = version
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+// nameTable returns the name table of a response.
+func nameTable(resp *xdsapi.DiscoveryResponse) (*dns.NameTable, error) {
+	if len(resp.Resources) != 1 {
+		return nil, fmt.Errorf("got %d name tables, want 1", len(resp.Resources))
+	}
+	nt := &dns.NameTable{}
+	if err := types.UnmarshalAny(&resp.Resources[0], nt); err != nil {
+		return nil, err
+	}
+	return nt, nil
+}
+
+// pilotTLSConfig returns the TLS settings of the connections to Pilot, like the ones of Envoy. The
+// certificates are read for each connection since they are rotated, and the certificate of Pilot
+// must have one of the SANs, or any SAN if there is none.
+func pilotTLSConfig(certChain, key, rootCert string, pilotSAN []string) *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certChain, key)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		},
+		// Pilot is identified by its SAN rather than by the host name, see VerifyPeerCertificate.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPilotCertificate(rawCerts, rootCert, pilotSAN)
+		},
+	}
+}
+
+// verifyPilotCertificate verifies the certificate chain of Pilot with the root certificate, and
+// checks its SAN.
+func verifyPilotCertificate(rawCerts [][]byte, rootCert string, pilotSAN []string) error {
+	rootPEM, err := ioutil.ReadFile(rootCert)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(rootPEM) {
+		return fmt.Errorf("no root certificate in %s", rootCert)
+	}
+	if len(rawCerts) == 0 {
+		return errors.New("no certificate from Pilot")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		return err
+	}
+
+	if len(pilotSAN) == 0 {
+		return nil
+	}
+	for _, uri := range certs[0].URIs {
+		for _, san := range pilotSAN {
+			if uri.String() == san {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("the certificate of Pilot has none of the SANs %v", pilotSAN)
+}
@@ -0,0 +1,298 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	ads "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pkg/dns"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// fakeADS pushes the name tables of the tables channel to the watching stream.
+type fakeADS struct {
+	tables      chan *dns.NameTable
+	closeStream chan struct{}
+	// acks receives the ACKs of the pushed name tables.
+	acks chan *xdsapi.DiscoveryRequest
+}
+
+func newFakeADS() *fakeADS {
+	return &fakeADS{
+		tables:      make(chan *dns.NameTable),
+		closeStream: make(chan struct{}),
+		acks:        make(chan *xdsapi.DiscoveryRequest, 10),
+	}
+}
+
+func (f *fakeADS) StreamAggregatedResources(stream ads.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if req.TypeUrl != dns.NameTableType || req.Node.GetId() != testNode.Id {
+		return fmt.Errorf("unexpected request %v", req)
+	}
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			f.acks <- ack
+		}
+	}()
+	for {
+		select {
+		case nt := <-f.tables:
+			resource, err := types.MarshalAny(nt)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(&xdsapi.DiscoveryResponse{
+				TypeUrl:   dns.NameTableType,
+				Nonce:     time.Now().String(),
+				Resources: []types.Any{*resource},
+			}); err != nil {
+				return err
+			}
+		case <-f.closeStream:
+			return nil
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (f *fakeADS) DeltaAggregatedResources(ads.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	return status.Error(codes.Unimplemented, "not implemented")
+}
+
+func waitResolved(t *testing.T, r *net.Resolver, host, want string) {
+	t.Helper()
+	var got []string
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		got, err = r.LookupHost(context.Background(), host)
+		if err == nil && len(got) == 1 && got[0] == want {
+			return
+		}
+	}
+	t.Fatalf("%s resolved to %v %v, want %s", host, got, err, want)
+}
+
+var testNode = proxyNode("sidecar~10.0.0.10~foo.default~default.svc.cluster.local", "foo.default",
+	map[string]string{"ISTIO_PROXY_VERSION": "1.1.3"})
+
+// startFakeADS serves the fake ADS server, over TLS if creds is not nil.
+func startFakeADS(t *testing.T, creds credentials.TransportCredentials) (*fakeADS, string, func()) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opts []grpc.ServerOption
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	fake := newFakeADS()
+	grpcServer := grpc.NewServer(opts...)
+	ads.RegisterAggregatedDiscoveryServiceServer(grpcServer, fake)
+	go func() {
+		_ = grpcServer.Serve(l)
+	}()
+	return fake, l.Addr().String(), grpcServer.Stop
+}
+
+// startDNSProxy runs a DNS proxy watching the name table of the discovery address.
+func startDNSProxy(ctx context.Context, t *testing.T, discoveryAddress string, tlsConfig *tls.Config) *dnsProxy {
+	t.Helper()
+	resolvConf, err := ioutil.TempFile("", "resolv.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(resolvConf.Name()) // nolint: errcheck
+	if err := resolvConf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dnsProxyAddress, dnsResolvConf = "127.0.0.1:0", resolvConf.Name()
+	defer func() {
+		dnsProxyAddress, dnsResolvConf = "", ""
+	}()
+
+	p, err := newDNSProxy(discoveryAddress, testNode, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.retryDelay = 10 * time.Millisecond
+	go p.Run(ctx)
+	return p
+}
+
+func dnsResolver(p *dnsProxy) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", p.server.Address())
+		},
+	}
+}
+
+func TestDNSProxy(t *testing.T) {
+	fake, addr, stop := startFakeADS(t, nil)
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := startDNSProxy(ctx, t, addr, nil)
+	r := dnsResolver(p)
+
+	fake.tables <- &dns.NameTable{Table: map[string]*dns.NameInfo{
+		"foo.default.svc.cluster.local": {Ips: []string{"10.0.0.1"}},
+	}}
+	waitResolved(t, r, "foo.default.svc.cluster.local.", "10.0.0.1")
+	select {
+	case ack := <-fake.acks:
+		if ack.ResponseNonce == "" || ack.ErrorDetail != nil {
+			t.Errorf("unexpected ACK %v", ack)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the name table was not acknowledged")
+	}
+
+	fake.tables <- &dns.NameTable{Table: map[string]*dns.NameInfo{
+		"foo.default.svc.cluster.local": {Ips: []string{"10.0.0.2"}},
+	}}
+	waitResolved(t, r, "foo.default.svc.cluster.local.", "10.0.0.2")
+
+	// The proxy watches the name table again when the stream is closed.
+	fake.closeStream <- struct{}{}
+	fake.tables <- &dns.NameTable{Table: map[string]*dns.NameInfo{
+		"foo.default.svc.cluster.local": {Ips: []string{"10.0.0.3"}},
+	}}
+	waitResolved(t, r, "foo.default.svc.cluster.local.", "10.0.0.3")
+}
+
+// writeCert writes a certificate and its key signed by the CA, or self-signed if ca is nil.
+func writeCert(t *testing.T, dir, name string, opts util.CertOptions, ca *tls.Certificate) (string, string) {
+	t.Helper()
+	opts.TTL = time.Hour
+	opts.RSAKeySize = 2048
+	if ca == nil {
+		opts.IsSelfSigned = true
+	} else {
+		signer, err := x509.ParseCertificate(ca.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.SignerCert, opts.SignerPriv = signer, ca.PrivateKey
+	}
+	cert, key, err := util.GenCertKeyFromOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+"-cert.pem"), filepath.Join(dir, name+"-key.pem")
+	if err := ioutil.WriteFile(certFile, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestDNSProxyTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dns-proxy-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	pilotSAN := "spiffe://cluster.local/ns/istio-system/sa/istio-pilot-service-account"
+	rootCert, rootKey := writeCert(t, dir, "root", util.CertOptions{Org: "root", IsCA: true}, nil)
+	root, err := tls.LoadX509KeyPair(rootCert, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pilotCert, pilotKey := writeCert(t, dir, "pilot", util.CertOptions{Host: pilotSAN, IsServer: true}, &root)
+	clientCert, clientKey := writeCert(t, dir, "client",
+		util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/foo", IsClient: true}, &root)
+	otherRootCert, _ := writeCert(t, dir, "other-root", util.CertOptions{Org: "other", IsCA: true}, nil)
+
+	pilot, err := tls.LoadX509KeyPair(pilotCert, pilotKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootLeaf, err := x509.ParseCertificate(root.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(rootLeaf)
+	fake, addr, stop := startFakeADS(t, credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pilot},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}))
+	defer stop()
+
+	// The SAN and the root certificate of Pilot are verified.
+	for _, c := range []struct {
+		name     string
+		rootCert string
+		san      []string
+	}{
+		{"other SAN", rootCert, []string{"spiffe://cluster.local/ns/istio-system/sa/other"}},
+		{"other root", otherRootCert, []string{pilotSAN}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			p := &dnsProxy{
+				discoveryAddress: addr,
+				node:             testNode,
+				tlsConfig:        pilotTLSConfig(clientCert, clientKey, c.rootCert, c.san),
+			}
+			if err := p.watchNameTable(ctx); err == nil || ctx.Err() != nil {
+				t.Fatalf("got %v, want a TLS error", err)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := startDNSProxy(ctx, t, addr, pilotTLSConfig(clientCert, clientKey, rootCert, []string{pilotSAN}))
+	fake.tables <- &dns.NameTable{Table: map[string]*dns.NameInfo{
+		"foo.default.svc.cluster.local": {Ips: []string{"10.0.0.1"}},
+	}}
+	waitResolved(t, dnsResolver(p), "foo.default.svc.cluster.local.", "10.0.0.1")
+}
@@ -17,6 +17,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
@@ -29,6 +30,7 @@ import (
 	"time"
 
 	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
 
@@ -37,7 +39,9 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/proxy"
 	"istio.io/istio/pilot/pkg/proxy/envoy"
+	"istio.io/istio/pilot/pkg/proxy/envoy/history"
 	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/bootstrap"
 	"istio.io/istio/pkg/cmd"
 	"istio.io/istio/pkg/collateral"
 	"istio.io/istio/pkg/env"
@@ -53,6 +57,11 @@ var (
 	registry         serviceregistry.ServiceRegistry
 	statusPort       uint16
 	applicationPorts []string
+	// Regexps of the metric families served by the status server
+	statsInclusionRegexps []string
+	statsExclusionRegexps []string
+	// Number of epochs of the proxy in the config history served by the status server
+	configHistorySize int
 
 	// proxy config flags (named identically)
 	configPath                 string
@@ -78,6 +87,8 @@ var (
 	proxyComponentLogLevel     string
 	concurrency                int
 	templateFile               string
+	meshConfigFile             string
+	proxyConfigFile            string
 	disableInternalTelemetry   bool
 	tlsServerCertChain         string
 	tlsServerKey               string
@@ -90,11 +101,13 @@ var (
 
 	wg sync.WaitGroup
 
-	instanceIPVar        = env.RegisterStringVar("INSTANCE_IP", "", "")
-	podNameVar           = env.RegisterStringVar("POD_NAME", "", "")
-	podNamespaceVar      = env.RegisterStringVar("POD_NAMESPACE", "", "")
-	istioNamespaceVar    = env.RegisterStringVar("ISTIO_NAMESPACE", "", "")
-	kubeAppProberNameVar = env.RegisterStringVar(status.KubeAppProberEnvName, "", "")
+	instanceIPVar            = env.RegisterStringVar("INSTANCE_IP", "", "")
+	podNameVar               = env.RegisterStringVar("POD_NAME", "", "")
+	podNamespaceVar          = env.RegisterStringVar("POD_NAMESPACE", "", "")
+	istioNamespaceVar        = env.RegisterStringVar("ISTIO_NAMESPACE", "", "")
+	kubeAppProberNameVar     = env.RegisterStringVar(status.KubeAppProberEnvName, "", "")
+	prometheusAnnotationsVar = env.RegisterStringVar(status.PrometheusAnnotationsEnvName, "",
+		"The prometheus annotations of the application, whose metrics are merged with the ones of Envoy")
 
 	rootCmd = &cobra.Command{
 		Use:          "pilot-agent",
@@ -304,7 +317,7 @@ var (
 			// TODO: change Mixer and Pilot to use standard template and deprecate this custom bootstrap parser
 			if controlPlaneBootstrap {
 				if templateFile != "" && proxyConfig.CustomConfigFile == "" {
-					opts := make(map[string]string)
+					opts := make(map[string]interface{})
 					opts["PodName"] = podNameVar.Get()
 					opts["PodNamespace"] = podNamespaceVar.Get()
 					// Setting default to ipv4 local host, wildcard and dns policy
@@ -332,6 +345,11 @@ var (
 					if disableInternalTelemetry {
 						opts["DisableReportCalls"] = "true"
 					}
+					envoyVersion, err := bootstrap.GetEnvoyVersion(proxyConfig.BinaryPath)
+					if err != nil {
+						log.Warnf("Unknown Envoy version, the bootstrap is rendered for the latest one: %v", err)
+					}
+					opts["envoy_version"] = envoyVersion
 					tmpl, err := template.ParseFiles(templateFile)
 					if err != nil {
 						return err
@@ -359,6 +377,14 @@ var (
 				cancel()
 				wg.Wait()
 			}()
+			// The config history is recorded by the proxy and served by the status server.
+			var configHistory *history.ConfigHistory
+			var configRecorder envoy.ConfigRecorder
+			if configHistorySize > 0 {
+				configHistory = history.NewConfigHistory(configHistorySize)
+				configRecorder = configHistory
+			}
+
 			// If a status port was provided, start handling status probes.
 			if statusPort > 0 {
 				parsedPorts, err := parseApplicationPorts()
@@ -370,24 +396,59 @@ var (
 					localHostAddr = "[::1]"
 				}
 				prober := kubeAppProberNameVar.Get()
-				statusServer, err := status.NewServer(status.Config{
-					LocalHostAddr:      localHostAddr,
-					AdminPort:          proxyAdminPort,
-					StatusPort:         statusPort,
-					ApplicationPorts:   parsedPorts,
-					KubeAppHTTPProbers: prober,
-				})
+				statusConfig := status.Config{
+					LocalHostAddr:         localHostAddr,
+					AdminPort:             proxyAdminPort,
+					StatusPort:            statusPort,
+					ApplicationPorts:      parsedPorts,
+					KubeAppHTTPProbers:    prober,
+					PrometheusAnnotations: prometheusAnnotationsVar.Get(),
+					StatsInclusionRegexps: statsInclusionRegexps,
+					StatsExclusionRegexps: statsExclusionRegexps,
+					PrometheusGatherer:    prometheus.DefaultGatherer,
+				}
+				if configHistory != nil {
+					statusConfig.DebugHandler = configHistory
+				}
+				statusServer, err := status.NewServer(statusConfig)
 				if err != nil {
 					return err
 				}
 				go waitForCompletion(ctx, statusServer.Run)
 			}
 
+			// If a DNS proxy address was provided, answer the queries for the hosts of the mesh.
+			if dnsProxyAddress != "" {
+				// The name table is watched with the node of Envoy and the same TLS settings.
+				var tlsConfig *tls.Config
+				if proxyConfig.ControlPlaneAuthPolicy == meshconfig.AuthenticationPolicy_MUTUAL_TLS {
+					tlsConfig = pilotTLSConfig(tlsClientCertChain, tlsClientKey, tlsClientRootCert, pilotSAN)
+				}
+				node := proxyNode(role.ServiceNode(), proxyConfig.ServiceCluster,
+					bootstrap.NodeMetadata(os.Environ(), role.IPAddresses))
+				dnsProxy, err := newDNSProxy(proxyConfig.DiscoveryAddress, node, tlsConfig)
+				if err != nil {
+					return err
+				}
+				go waitForCompletion(ctx, dnsProxy.Run)
+			}
+
 			log.Infof("PilotSAN %#v", pilotSAN)
 
-			envoyProxy := envoy.NewProxy(proxyConfig, role.ServiceNode(), proxyLogLevel, proxyComponentLogLevel, pilotSAN, role.IPAddresses)
+			envoyProxy := envoy.NewProxy(proxyConfig, role.ServiceNode(), proxyLogLevel, proxyComponentLogLevel, pilotSAN,
+				role.IPAddresses, configRecorder)
 			agent := proxy.NewAgent(envoyProxy, proxy.DefaultRetry, pilot.TerminationDrainDuration())
-			watcher := envoy.NewWatcher(tlsCertsToWatch, agent.ConfigCh())
+			var watcher envoy.Watcher
+			if meshConfigFile != "" || proxyConfigFile != "" {
+				watcher = envoy.NewReloadingWatcher(tlsCertsToWatch, &envoy.ConfigReloader{
+					Base:            proxyConfig,
+					MeshConfigFile:  meshConfigFile,
+					ProxyConfigFile: proxyConfigFile,
+					Proxy:           envoyProxy,
+				}, agent.ConfigCh())
+			} else {
+				watcher = envoy.NewWatcher(tlsCertsToWatch, agent.ConfigCh())
+			}
 
 			go waitForCompletion(ctx, agent.Run)
 			go waitForCompletion(ctx, watcher.Run)
@@ -507,6 +568,14 @@ func init() {
 
 	proxyCmd.PersistentFlags().Uint16Var(&statusPort, "statusPort", 0,
 		"HTTP Port on which to serve pilot agent status. If zero, agent status will not be provided.")
+	proxyCmd.PersistentFlags().StringSliceVar(&statsInclusionRegexps, "statsInclusionRegexps", []string{},
+		"Comma separated regexps of the metric families merged on "+status.PrometheusPath+
+			" of the status port, all are included if empty")
+	proxyCmd.PersistentFlags().StringSliceVar(&statsExclusionRegexps, "statsExclusionRegexps", []string{},
+		"Comma separated regexps of the metric families excluded from "+status.PrometheusPath+" of the status port")
+	proxyCmd.PersistentFlags().IntVar(&configHistorySize, "configHistorySize", 0,
+		"Number of epochs of the proxy whose rendered bootstrap and config dumps are served to localhost by the "+
+			"status server under /debug/config_history and /debug/config_diff. The history is disabled if 0")
 	proxyCmd.PersistentFlags().StringSliceVar(&applicationPorts, "applicationPorts", []string{},
 		"Ports exposed by the application. Used to determine that Envoy is configured and ready to receive traffic.")
 
@@ -562,6 +631,17 @@ func init() {
 		"number of worker threads to run")
 	proxyCmd.PersistentFlags().StringVar(&templateFile, "templateFile", "",
 		"Go template bootstrap config")
+	proxyCmd.PersistentFlags().StringVar(&meshConfigFile, "meshConfigFile", "",
+		"File name of the mesh config, whose default proxy config overrides the flags. "+
+			"The proxy is hot restarted when a change of the file changes its bootstrap")
+	proxyCmd.PersistentFlags().StringVar(&proxyConfigFile, "proxyConfigFile", "",
+		"File name of a proxy config overriding the flags and the mesh config. "+
+			"The proxy is hot restarted when a change of the file changes its bootstrap")
+	proxyCmd.PersistentFlags().StringVar(&dnsProxyAddress, "dnsProxyAddress", "",
+		"UDP and TCP address of a DNS proxy answering the queries for the hosts of the mesh from the registry of Pilot, "+
+			"e.g. 127.0.0.1:15053. The DNS proxy is disabled if empty")
+	proxyCmd.PersistentFlags().StringVar(&dnsResolvConf, "dnsResolvConf", "/etc/resolv.conf",
+		"File with the upstream resolvers of the DNS proxy")
 	proxyCmd.PersistentFlags().BoolVar(&disableInternalTelemetry, "disableInternalTelemetry", false,
 		"Disable internal telemetry")
 	proxyCmd.PersistentFlags().BoolVar(&controlPlaneBootstrap, "controlPlaneBootstrap", true,
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -27,13 +28,15 @@ import (
 // must not be added in this command. Otherwise, it'd break istioctl proxy-config,
 // which interprets the output literally as json document.
 var (
+	requestPort int
+
 	requestCmd = &cobra.Command{
 		Use:   "request <method> <path> [<body>]",
-		Short: "Makes an HTTP request to the Envoy admin API",
+		Short: "Makes an HTTP request to the Envoy admin API, or to the status server with --port",
 		Args:  cobra.MinimumNArgs(2),
 		RunE: func(c *cobra.Command, args []string) error {
 			command := &request.Command{
-				Address: "localhost:15000",
+				Address: fmt.Sprintf("localhost:%d", requestPort),
 				Client: &http.Client{
 					Timeout: 60 * time.Second,
 				},
@@ -48,5 +51,6 @@ var (
 )
 
 func init() {
+	requestCmd.PersistentFlags().IntVar(&requestPort, "port", 15000, "Port of the request, the Envoy admin port by default")
 	rootCmd.AddCommand(requestCmd)
 }
@@ -0,0 +1,210 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// PrometheusPath is the path of the merged metrics of the Envoy proxy, the application and
+	// pilot agent.
+	PrometheusPath = "/stats/prometheus"
+
+	// PrometheusAnnotationsEnvName is the name of the environment variable with the original
+	// prometheus.io annotations of the pod, encoded as a JSON PrometheusScrapeConfiguration.
+	// It is set by the injector when the annotations are rewritten to scrape PrometheusPath.
+	PrometheusAnnotationsEnvName = "ISTIO_PROMETHEUS_ANNOTATIONS"
+
+	defaultPrometheusAppPath = "/metrics"
+)
+
+var (
+	// metricsScrapeTimeout is the timeout of the scrapes of Envoy and the application.
+	metricsScrapeTimeout = 5 * time.Second
+)
+
+// PrometheusScrapeConfiguration is the scrape configuration of the application, from the
+// prometheus.io/scrape, prometheus.io/port and prometheus.io/path annotations.
+type PrometheusScrapeConfiguration struct {
+	Scrape string `json:"scrape"`
+	Port   string `json:"port"`
+	Path   string `json:"path"`
+}
+
+// metricsFilter selects the metric families served by PrometheusPath.
+type metricsFilter struct {
+	inclusions []*regexp.Regexp
+	exclusions []*regexp.Regexp
+}
+
+// newMetricsFilter compiles the regexes matching the full names of the metric families.
+func newMetricsFilter(inclusions, exclusions []string) (*metricsFilter, error) {
+	f := &metricsFilter{}
+	for _, r := range inclusions {
+		re, err := regexp.Compile("^(?:" + r + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid stats inclusion regexp %q: %v", r, err)
+		}
+		f.inclusions = append(f.inclusions, re)
+	}
+	for _, r := range exclusions {
+		re, err := regexp.Compile("^(?:" + r + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid stats exclusion regexp %q: %v", r, err)
+		}
+		f.exclusions = append(f.exclusions, re)
+	}
+	return f, nil
+}
+
+// match returns true if the metric family is included and not excluded. All the families are
+// included when there is no inclusion regexp.
+func (f *metricsFilter) match(name string) bool {
+	included := len(f.inclusions) == 0
+	for _, re := range f.inclusions {
+		if re.MatchString(name) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range f.exclusions {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// appMetricsURL returns the URL of the metrics of the application, or an empty string if it
+// is not scraped. Invalid annotations only disable the merging of the application metrics, so
+// that the sidecar still starts.
+func appMetricsURL(annotations string, statusPort uint16) string {
+	if annotations == "" {
+		return ""
+	}
+	var config PrometheusScrapeConfiguration
+	if err := json.Unmarshal([]byte(annotations), &config); err != nil {
+		log.Warnf("Not merging the application metrics, failed to decode the prometheus annotations %q: %v",
+			annotations, err)
+		return ""
+	}
+	if scrape, err := strconv.ParseBool(config.Scrape); err != nil || !scrape {
+		return ""
+	}
+	port, err := strconv.Atoi(config.Port)
+	if err != nil || port <= 0 || port > 65535 {
+		log.Warnf("Not merging the application metrics, invalid prometheus port %q", config.Port)
+		return ""
+	}
+	// Avoid scraping the merged metrics recursively.
+	if port == int(statusPort) {
+		return ""
+	}
+	path := config.Path
+	if path == "" {
+		path = defaultPrometheusAppPath
+	}
+	return fmt.Sprintf("http://localhost:%d%s", port, path)
+}
+
+// handleStats serves the metrics of Envoy, the application and pilot agent, in this order.
+// When a family is exposed by several of them, only the first one is served.
+func (s *Server) handleStats(w http.ResponseWriter, _ *http.Request) {
+	var families []*dto.MetricFamily
+	seen := map[string]bool{}
+	add := func(source string, mfs []*dto.MetricFamily) {
+		for _, mf := range mfs {
+			name := mf.GetName()
+			if !s.metricsFilter.match(name) {
+				continue
+			}
+			if seen[name] {
+				log.Debugf("Skipping the duplicate metric family %s of %s", name, source)
+				continue
+			}
+			seen[name] = true
+			families = append(families, mf)
+		}
+	}
+
+	envoyURL := fmt.Sprintf("http://%s:%d%s", s.ready.LocalHostAddr, s.ready.AdminPort, PrometheusPath)
+	if mfs, err := scrapeMetrics(envoyURL); err != nil {
+		log.Warnf("Failed to scrape the Envoy metrics: %v", err)
+	} else {
+		add("envoy", mfs)
+	}
+	if s.appMetricsURL != "" {
+		if mfs, err := scrapeMetrics(s.appMetricsURL); err != nil {
+			log.Warnf("Failed to scrape the application metrics: %v", err)
+		} else {
+			add("application", mfs)
+		}
+	}
+	if s.gatherer != nil {
+		if mfs, err := s.gatherer.Gather(); err != nil {
+			log.Warnf("Failed to gather the agent metrics: %v", err)
+		} else {
+			add("agent", mfs)
+		}
+	}
+
+	w.Header().Set("Content-Type", string(expfmt.FmtText))
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			log.Warnf("Failed to write the metric family %s: %v", mf.GetName(), err)
+			return
+		}
+	}
+}
+
+// scrapeMetrics returns the metric families of a Prometheus text endpoint, sorted by name.
+func scrapeMetrics(url string) ([]*dto.MetricFamily, error) {
+	client := &http.Client{Timeout: metricsScrapeTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	var parser expfmt.TextParser
+	byName, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	mfs := make([]*dto.MetricFamily, 0, len(byName))
+	for _, mf := range byName {
+		mfs = append(mfs, mf)
+	}
+	sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+	return mfs, nil
+}
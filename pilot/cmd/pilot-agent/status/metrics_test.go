@@ -0,0 +1,209 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestAppMetricsURL(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations string
+		want        string
+	}{
+		{name: "not set"},
+		{name: "not scraped", annotations: `{"scrape":"false","port":"9090"}`},
+		{name: "default path", annotations: `{"scrape":"true","port":"9090"}`, want: "http://localhost:9090/metrics"},
+		{name: "path", annotations: `{"scrape":"true","port":"9090","path":"/stats"}`, want: "http://localhost:9090/stats"},
+		{name: "status port", annotations: `{"scrape":"true","port":"15020","path":"/stats/prometheus"}`},
+		{name: "invalid json", annotations: `{"scrape":`},
+		{name: "missing port", annotations: `{"scrape":"true"}`},
+		{name: "invalid port", annotations: `{"scrape":"true","port":"http"}`},
+	}
+	for _, c := range cases {
+		got := appMetricsURL(c.annotations, 15020)
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMetricsFilter(t *testing.T) {
+	cases := []struct {
+		name       string
+		inclusions []string
+		exclusions []string
+		match      []string
+		noMatch    []string
+	}{
+		{
+			name:  "everything",
+			match: []string{"envoy_cluster_upstream_rq", "go_goroutines"},
+		},
+		{
+			name:       "inclusions",
+			inclusions: []string{"envoy_cluster_.*", "go_goroutines"},
+			match:      []string{"envoy_cluster_upstream_rq", "go_goroutines"},
+			noMatch:    []string{"envoy_server_live", "go_goroutines_total"},
+		},
+		{
+			name:       "exclusions",
+			inclusions: []string{"envoy_.*"},
+			exclusions: []string{".*_bucket", "envoy_server_.*"},
+			match:      []string{"envoy_cluster_upstream_rq"},
+			noMatch:    []string{"envoy_cluster_upstream_rq_time_bucket", "envoy_server_live", "go_goroutines"},
+		},
+	}
+	for _, c := range cases {
+		f, err := newMetricsFilter(c.inclusions, c.exclusions)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, name := range c.match {
+			if !f.match(name) {
+				t.Errorf("%s: %s is filtered", c.name, name)
+			}
+		}
+		for _, name := range c.noMatch {
+			if f.match(name) {
+				t.Errorf("%s: %s is not filtered", c.name, name)
+			}
+		}
+	}
+
+	if _, err := newMetricsFilter([]string{"("}, nil); err == nil {
+		t.Error("expected an error for an invalid regexp")
+	}
+}
+
+func metricsServer(t *testing.T, path, metrics string) (*httptest.Server, int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(metrics))
+	}))
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	return server, p
+}
+
+func TestHandleStats(t *testing.T) {
+	envoy, envoyPort := metricsServer(t, PrometheusPath, `# TYPE envoy_cluster_upstream_rq counter
+envoy_cluster_upstream_rq{cluster_name="outbound|80||foo"} 3
+# TYPE envoy_server_live gauge
+envoy_server_live 1
+`)
+	defer envoy.Close()
+	app, appPort := metricsServer(t, "/stats", `# TYPE app_requests_total counter
+app_requests_total 7
+# TYPE envoy_server_live gauge
+envoy_server_live 0
+`)
+	defer app.Close()
+
+	registry := prometheus.NewRegistry()
+	agentMetric := prometheus.NewGauge(prometheus.GaugeOpts{Name: "agent_up", Help: "Agent."})
+	registry.MustRegister(agentMetric)
+	agentMetric.Set(1)
+
+	s, err := NewServer(Config{
+		LocalHostAddr:         "127.0.0.1",
+		AdminPort:             uint16(envoyPort),
+		StatusPort:            15020,
+		PrometheusAnnotations: fmt.Sprintf(`{"scrape":"true","port":"%d","path":"/stats"}`, appPort),
+		StatsExclusionRegexps: []string{"envoy_cluster_.*"},
+		PrometheusGatherer:    registry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.handleStats(w, httptest.NewRequest("GET", PrometheusPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(w.Body)
+	if err != nil {
+		t.Fatalf("invalid merged metrics: %v", err)
+	}
+	var names []string
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{"agent_up", "app_requests_total", "envoy_server_live"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got metric families %v, want %v", names, want)
+	}
+	// The metrics of Envoy take precedence.
+	if v := families["envoy_server_live"].GetMetric()[0].GetGauge().GetValue(); v != 1 {
+		t.Errorf("got envoy_server_live %v, want the value of Envoy", v)
+	}
+}
+
+func TestHandleStatsWithoutPrometheusPort(t *testing.T) {
+	envoy, envoyPort := metricsServer(t, PrometheusPath, `# TYPE envoy_server_live gauge
+envoy_server_live 1
+`)
+	defer envoy.Close()
+
+	// The application metrics are not merged, but the server still starts.
+	s, err := NewServer(Config{
+		LocalHostAddr:         "127.0.0.1",
+		AdminPort:             uint16(envoyPort),
+		StatusPort:            15020,
+		PrometheusAnnotations: `{"scrape":"true","port":"","path":"/stats"}`,
+		PrometheusGatherer:    prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.appMetricsURL != "" {
+		t.Errorf("got application metrics URL %q, want none", s.appMetricsURL)
+	}
+
+	w := httptest.NewRecorder()
+	s.handleStats(w, httptest.NewRequest("GET", PrometheusPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(w.Body)
+	if err != nil {
+		t.Fatalf("invalid merged metrics: %v", err)
+	}
+	if _, ok := families["envoy_server_live"]; !ok {
+		t.Errorf("got metric families %v, want the ones of Envoy", families)
+	}
+}
@@ -29,6 +29,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
 	"istio.io/istio/pkg/log"
 
@@ -39,9 +43,11 @@ import (
 const (
 	// readyPath is for the pilot agent readiness itself.
 	readyPath = "/healthz/ready"
+	// debugPathPrefix is the prefix of the paths of the debug handler.
+	debugPathPrefix = "/debug/"
 	// KubeAppProberEnvName is the name of the command line flag for pilot agent to pass app prober config.
 	// The json encoded string to pass app HTTP probe information from injector(istioctl or webhook).
-	// For example, --ISTIO_KUBE_APP_PROBERS='{"/app-health/httpbin/livez":{"path": "/hello", "port": 8080}.
+	// For example, --ISTIO_KUBE_APP_PROBERS='{"/app-health/httpbin/livez":{"httpGet":{"path": "/hello", "port": 8080}}}'
 	// indicates that httpbin container liveness prober port is 8080 and probing path is /hello.
 	// This environment variable should never be set manually.
 	KubeAppProberEnvName = "ISTIO_KUBE_APP_PROBERS"
@@ -49,13 +55,52 @@ const (
 
 var (
 	appProberPattern = regexp.MustCompile(`^/app-health/[^/]+/(livez|readyz)$`)
+
+	// appProbeTimeout is the timeout of the probes sent to the application.
+	// TODO: figure out the appropriate timeout?
+	appProbeTimeout = 10 * time.Second
 )
 
 // KubeAppProbers holds the information about a Kubernetes pod prober.
 // It's a map from the prober URL path to the Kubernetes Prober config.
 // For example, "/app-health/hello-world/livez" entry contains livenss prober config for
 // container "hello-world".
-type KubeAppProbers map[string]*corev1.HTTPGetAction
+type KubeAppProbers map[string]*Prober
+
+// Prober is the probe taken over by pilot agent on behalf of the kubelet. Exactly one of the
+// actions is set.
+type Prober struct {
+	HTTPGet   *corev1.HTTPGetAction   `json:"httpGet,omitempty"`
+	TCPSocket *corev1.TCPSocketAction `json:"tcpSocket,omitempty"`
+	GRPC      *GRPCAction             `json:"grpc,omitempty"`
+}
+
+// UnmarshalJSON decodes a prober, also accepting the previous format where the value is a bare
+// HTTPGetAction such as {"path": "/hello", "port": 8080}, as written by older sidecar injectors.
+func (p *Prober) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	_, httpGet := fields["httpGet"]
+	_, tcpSocket := fields["tcpSocket"]
+	_, grpcAction := fields["grpc"]
+	if len(fields) > 0 && !httpGet && !tcpSocket && !grpcAction {
+		p.HTTPGet = &corev1.HTTPGetAction{}
+		return json.Unmarshal(data, p.HTTPGet)
+	}
+	// The alias drops the methods of Prober, so the default decoding is used.
+	type prober Prober
+	return json.Unmarshal(data, (*prober)(p))
+}
+
+// GRPCAction describes a health check of the application using the standard gRPC health
+// checking protocol, as done by the grpc_health_probe command.
+type GRPCAction struct {
+	Port int `json:"port"`
+	// Service is the name of the service to check, the overall server health is checked if empty.
+	Service string `json:"service,omitempty"`
+}
 
 // Config for the status server.
 type Config struct {
@@ -63,8 +108,20 @@ type Config struct {
 	StatusPort       uint16
 	AdminPort        uint16
 	ApplicationPorts []uint16
-	// KubeAppHTTPProbers is a json with Kubernetes application HTTP prober config encoded.
+	// KubeAppHTTPProbers is a json with Kubernetes application prober config encoded.
 	KubeAppHTTPProbers string
+	// PrometheusAnnotations is a json with the prometheus annotations of the application encoded,
+	// its metrics are merged with the ones of Envoy if it is scraped.
+	PrometheusAnnotations string
+	// StatsInclusionRegexps and StatsExclusionRegexps select the metric families served by
+	// PrometheusPath, by full name.
+	StatsInclusionRegexps []string
+	StatsExclusionRegexps []string
+	// PrometheusGatherer has the metrics of pilot agent itself, if not nil.
+	PrometheusGatherer prometheus.Gatherer
+	// DebugHandler serves the paths under /debug/ to localhost, such as the config history of
+	// the proxy, if not nil.
+	DebugHandler http.Handler
 }
 
 // Server provides an endpoint for handling status probes.
@@ -74,6 +131,10 @@ type Server struct {
 	appKubeProbers      KubeAppProbers
 	statusPort          uint16
 	lastProbeSuccessful bool
+	appMetricsURL       string
+	metricsFilter       *metricsFilter
+	gatherer            prometheus.Gatherer
+	debugHandler        http.Handler
 }
 
 // NewServer creates a new status server.
@@ -85,6 +146,13 @@ func NewServer(config Config) (*Server, error) {
 			AdminPort:        config.AdminPort,
 			ApplicationPorts: config.ApplicationPorts,
 		},
+		gatherer:     config.PrometheusGatherer,
+		debugHandler: config.DebugHandler,
+	}
+	s.appMetricsURL = appMetricsURL(config.PrometheusAnnotations, config.StatusPort)
+	var err error
+	if s.metricsFilter, err = newMetricsFilter(config.StatsInclusionRegexps, config.StatsExclusionRegexps); err != nil {
+		return nil, err
 	}
 	if config.KubeAppHTTPProbers == "" {
 		return s, nil
@@ -97,13 +165,55 @@ func NewServer(config Config) (*Server, error) {
 		if !appProberPattern.Match([]byte(path)) {
 			return nil, fmt.Errorf(`invalid key, must be in form of regex pattern ^/app-health/[^\/]+/(livez|readyz)$`)
 		}
-		if prober.Port.Type != intstr.Int {
-			return nil, fmt.Errorf("invalid prober config for %v, the port must be int type", path)
+		if err := validateProber(prober); err != nil {
+			return nil, fmt.Errorf("invalid prober config for %v, %v", path, err)
 		}
 	}
 	return s, nil
 }
 
+func validateProber(prober *Prober) error {
+	if prober == nil {
+		return fmt.Errorf("no probe action is set")
+	}
+	actions := 0
+	if prober.HTTPGet != nil {
+		actions++
+		if prober.HTTPGet.Port.Type != intstr.Int {
+			return fmt.Errorf("the port must be int type")
+		}
+	}
+	if prober.TCPSocket != nil {
+		actions++
+		if prober.TCPSocket.Port.Type != intstr.Int {
+			return fmt.Errorf("the port must be int type")
+		}
+	}
+	if prober.GRPC != nil {
+		actions++
+		if prober.GRPC.Port <= 0 {
+			return fmt.Errorf("the port must be positive")
+		}
+	}
+	if actions != 1 {
+		return fmt.Errorf("exactly one probe action must be set, got %d", actions)
+	}
+	return nil
+}
+
+// localhostOnly restricts a handler to the requests from the pod itself, e.g. with pilot-agent
+// request, since the status port is reachable from the whole cluster.
+func localhostOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "only served to localhost", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 // FormatProberURL returns a pair of HTTP URLs that pilot agent will serve to take over Kubernetes
 // app probers.
 func FormatProberURL(container string) (string, string) {
@@ -119,6 +229,10 @@ func (s *Server) Run(ctx context.Context) {
 
 	// Add the handler for ready probes.
 	mux.HandleFunc(readyPath, s.handleReadyProbe)
+	mux.HandleFunc(PrometheusPath, s.handleStats)
+	if s.debugHandler != nil {
+		mux.Handle(debugPathPrefix, localhostOnly(s.debugHandler))
+	}
 	mux.HandleFunc("/", s.handleAppProbe)
 
 	mux.HandleFunc("/app-health", s.handleAppProbe)
@@ -190,10 +304,20 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	switch {
+	case prober.HTTPGet != nil:
+		handleAppProbeHTTPGet(w, req, path, prober.HTTPGet)
+	case prober.TCPSocket != nil:
+		handleAppProbeTCPSocket(w, path, prober.TCPSocket)
+	case prober.GRPC != nil:
+		handleAppProbeGRPC(w, path, prober.GRPC)
+	}
+}
+
+func handleAppProbeHTTPGet(w http.ResponseWriter, req *http.Request, path string, prober *corev1.HTTPGetAction) {
 	// Construct a request sent to the application.
 	httpClient := &http.Client{
-		// TODO: figure out the appropriate timeout?
-		Timeout: 10 * time.Second,
+		Timeout: appProbeTimeout,
 		// We skip the verification since kubelet skips the verification for HTTPS prober as well
 		// https://kubernetes.io/docs/tasks/configure-pod-container/configure-liveness-readiness-probes/#configure-probes
 		Transport: &http.Transport{
@@ -232,3 +356,44 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	// We only write the status code to the response.
 	w.WriteHeader(response.StatusCode)
 }
+
+// handleAppProbeTCPSocket succeeds if a TCP connection can be opened to the application port,
+// as the kubelet does for TCP socket probes.
+func handleAppProbeTCPSocket(w http.ResponseWriter, path string, prober *corev1.TCPSocketAction) {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%v", prober.Port.IntValue()), appProbeTimeout)
+	if err != nil {
+		log.Errorf("TCP probe of app failed: %v, original URL path = %v", err, path)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = conn.Close()
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAppProbeGRPC succeeds if the application reports itself as serving through the gRPC
+// health checking protocol.
+func handleAppProbeGRPC(w http.ResponseWriter, path string, prober *GRPCAction) {
+	ctx, cancel := context.WithTimeout(context.Background(), appProbeTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, fmt.Sprintf("localhost:%v", prober.Port), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		log.Errorf("Failed to connect to app for gRPC probe: %v, original URL path = %v", err, path)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: prober.Service})
+	if err != nil {
+		log.Errorf("gRPC probe of app failed: %v, original URL path = %v", err, path)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		log.Infof("gRPC probe of app returned status %v, original URL path = %v", resp.Status, path)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -19,10 +19,15 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
 	"istio.io/istio/pkg/test/env"
 )
 
@@ -53,18 +58,53 @@ func TestNewServer(t *testing.T) {
 		},
 		// Port is not Int typed.
 		{
-			httpProbe: `{"/app-health/hello-world/readyz": {"path": "/hello/sunnyvale", "port": "container-port-dontknow"}}`,
+			httpProbe: `{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": "container-port-dontknow"}}}`,
+			err:       "must be int type",
+		},
+		// TCP port is not Int typed.
+		{
+			httpProbe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": "container-port-dontknow"}}}`,
 			err:       "must be int type",
 		},
+		// gRPC port is not set.
+		{
+			httpProbe: `{"/app-health/hello-world/readyz": {"grpc": {"service": "foo"}}}`,
+			err:       "must be positive",
+		},
+		// No probe action.
+		{
+			httpProbe: `{"/app-health/hello-world/readyz": {}}`,
+			err:       "exactly one probe action",
+		},
+		// More than one probe action.
+		{
+			httpProbe: `{"/app-health/hello-world/readyz": {"httpGet": {"port": 8080}, "tcpSocket": {"port": 8080}}}`,
+			err:       "exactly one probe action",
+		},
 		// A valid input.
 		{
-			httpProbe: `{"/app-health/hello-world/readyz": {"path": "/hello/sunnyvale", "port": 8080},` +
-				`"/app-health/business/livez": {"path": "/buisiness/live", "port": 9090}}`,
+			httpProbe: `{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": 8080}},` +
+				`"/app-health/business/livez": {"httpGet": {"path": "/buisiness/live", "port": 9090}}}`,
 		},
 		// A valid input with empty probing path, which happens when HTTPGetAction.Path is not specified.
+		{
+			httpProbe: `{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": 8080}},
+"/app-health/business/livez": {"httpGet": {"port": 9090}}}`,
+		},
+		// A valid input with TCP and gRPC probers.
+		{
+			httpProbe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": 8080}},
+"/app-health/business/livez": {"grpc": {"port": 9090, "service": "business"}}}`,
+		},
+		// A valid input in the legacy format, where the value is an HTTPGetAction.
 		{
 			httpProbe: `{"/app-health/hello-world/readyz": {"path": "/hello/sunnyvale", "port": 8080},
-"/app-health/business/livez": {"port": 9090}}`,
+"/app-health/business/livez": {"port": 9090, "scheme": "HTTPS"}}`,
+		},
+		// Port is not Int typed in the legacy format.
+		{
+			httpProbe: `{"/app-health/hello-world/readyz": {"path": "/hello/sunnyvale", "port": "container-port-dontknow"}}`,
+			err:       "must be int type",
 		},
 		// A valid input without any prober info.
 		{
@@ -92,6 +132,23 @@ func TestNewServer(t *testing.T) {
 	}
 }
 
+func TestNewServerLegacyProber(t *testing.T) {
+	server, err := NewServer(Config{
+		KubeAppHTTPProbers: `{"/app-health/hello-world/readyz": {"path": "/hello/sunnyvale", "port": 8080, "scheme": "HTTPS"}}`,
+	})
+	if err != nil {
+		t.Fatalf("failed to create status server %v", err)
+	}
+	prober := server.appKubeProbers["/app-health/hello-world/readyz"]
+	if prober == nil || prober.HTTPGet == nil {
+		t.Fatalf("legacy prober not converted to an httpGet action: %+v", prober)
+	}
+	if prober.HTTPGet.Path != "/hello/sunnyvale" || prober.HTTPGet.Port.IntValue() != 8080 ||
+		prober.HTTPGet.Scheme != "HTTPS" {
+		t.Errorf("unexpected httpGet action %+v", prober.HTTPGet)
+	}
+}
+
 func TestAppProbe(t *testing.T) {
 	// Starts the application first.
 	listener, err := net.Listen("tcp", ":0")
@@ -104,8 +161,8 @@ func TestAppProbe(t *testing.T) {
 	// Starts the pilot agent status server.
 	server, err := NewServer(Config{
 		StatusPort: 0,
-		KubeAppHTTPProbers: fmt.Sprintf(`{"/app-health/hello-world/readyz": {"path": "/hello/sunnyvale", "port": %v},
-"/app-health/hello-world/livez": {"port": %v}}`, appPort, appPort),
+		KubeAppHTTPProbers: fmt.Sprintf(`{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": %v}},
+"/app-health/hello-world/livez": {"httpGet": {"port": %v}}}`, appPort, appPort),
 	})
 	if err != nil {
 		t.Errorf("failed to create status server %v", err)
@@ -168,8 +225,8 @@ func TestHttpsAppProbe(t *testing.T) {
 	// Starts the pilot agent status server.
 	server, err := NewServer(Config{
 		StatusPort: 0,
-		KubeAppHTTPProbers: fmt.Sprintf(`{"/app-health/hello-world/readyz": {"path": "/hello/sunnyvale", "port": %v, "scheme": "HTTPS"},
-"/app-health/hello-world/livez": {"port": %v, "scheme": "HTTPS"}}`, appPort, appPort),
+		KubeAppHTTPProbers: fmt.Sprintf(`{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": %v, "scheme": "HTTPS"}},
+"/app-health/hello-world/livez": {"httpGet": {"port": %v, "scheme": "HTTPS"}}}`, appPort, appPort),
 	})
 	if err != nil {
 		t.Errorf("failed to create status server %v", err)
@@ -217,3 +274,157 @@ func TestHttpsAppProbe(t *testing.T) {
 		}
 	}
 }
+
+func TestTCPAppProbe(t *testing.T) {
+	// Starts the application first.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Errorf("failed to allocate unused port %v", err)
+	}
+	go http.Serve(listener, &handler{})
+	appPort := listener.Addr().(*net.TCPAddr).Port
+
+	// Allocates a port with nothing listening on it.
+	closed, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Errorf("failed to allocate unused port %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	// Starts the pilot agent status server.
+	server, err := NewServer(Config{
+		StatusPort: 0,
+		KubeAppHTTPProbers: fmt.Sprintf(`{"/app-health/hello-world/readyz": {"tcpSocket": {"port": %v}},
+"/app-health/hello-world/livez": {"tcpSocket": {"port": %v}}}`, appPort, closedPort),
+	})
+	if err != nil {
+		t.Errorf("failed to create status server %v", err)
+		return
+	}
+	go server.Run(context.Background())
+
+	// We wait a bit here to ensure server's statusPort is updated.
+	time.Sleep(time.Second * 3)
+
+	server.mutex.RLock()
+	statusPort := server.statusPort
+	server.mutex.RUnlock()
+	t.Logf("status server starts at port %v, app starts at port %v", statusPort, appPort)
+	testCases := []struct {
+		probePath  string
+		statusCode int
+	}{
+		{
+			probePath:  fmt.Sprintf(":%v/app-health/hello-world/readyz", statusPort),
+			statusCode: http.StatusOK,
+		},
+		{
+			probePath:  fmt.Sprintf(":%v/app-health/hello-world/livez", statusPort),
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range testCases {
+		client := http.Client{}
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost%s", tc.probePath), nil)
+		if err != nil {
+			t.Errorf("[%v] failed to create request", tc.probePath)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal("request failed")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != tc.statusCode {
+			t.Errorf("[%v] unexpected status code, want = %v, got = %v", tc.probePath, tc.statusCode, resp.StatusCode)
+		}
+	}
+}
+
+func TestGRPCAppProbe(t *testing.T) {
+	// Starts the application first.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Errorf("failed to allocate unused port %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("not-serving", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	appPort := listener.Addr().(*net.TCPAddr).Port
+
+	// Starts the pilot agent status server.
+	server, err := NewServer(Config{
+		StatusPort: 0,
+		KubeAppHTTPProbers: fmt.Sprintf(`{"/app-health/hello-world/readyz": {"grpc": {"port": %v}},
+"/app-health/hello-world/livez": {"grpc": {"port": %v, "service": "not-serving"}}}`, appPort, appPort),
+	})
+	if err != nil {
+		t.Errorf("failed to create status server %v", err)
+		return
+	}
+	go server.Run(context.Background())
+
+	// We wait a bit here to ensure server's statusPort is updated.
+	time.Sleep(time.Second * 3)
+
+	server.mutex.RLock()
+	statusPort := server.statusPort
+	server.mutex.RUnlock()
+	t.Logf("status server starts at port %v, app starts at port %v", statusPort, appPort)
+	testCases := []struct {
+		probePath  string
+		statusCode int
+	}{
+		{
+			probePath:  fmt.Sprintf(":%v/app-health/hello-world/readyz", statusPort),
+			statusCode: http.StatusOK,
+		},
+		{
+			probePath:  fmt.Sprintf(":%v/app-health/hello-world/livez", statusPort),
+			statusCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		client := http.Client{}
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost%s", tc.probePath), nil)
+		if err != nil {
+			t.Errorf("[%v] failed to create request", tc.probePath)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal("request failed")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != tc.statusCode {
+			t.Errorf("[%v] unexpected status code, want = %v, got = %v", tc.probePath, tc.statusCode, resp.StatusCode)
+		}
+	}
+}
+
+func TestLocalhostOnly(t *testing.T) {
+	h := localhostOnly(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	cases := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{remoteAddr: "127.0.0.1:12345", want: http.StatusOK},
+		{remoteAddr: "[::1]:12345", want: http.StatusOK},
+		{remoteAddr: "10.1.2.3:12345", want: http.StatusForbidden},
+		{remoteAddr: "invalid", want: http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/debug/config_history", nil)
+		r.RemoteAddr = c.remoteAddr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s: got status %d, want %d", c.remoteAddr, w.Code, c.want)
+		}
+	}
+}
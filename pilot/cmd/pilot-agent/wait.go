@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/pkg/log"
+)
+
+var (
+	waitTimeout        time.Duration
+	waitRequestTimeout time.Duration
+	waitPeriod         time.Duration
+	waitURL            string
+
+	// waitCmd blocks until the proxy is ready. It is run as the postStart hook of the proxy
+	// container, so that Kubernetes only starts the application containers once the proxy has
+	// received its configuration.
+	waitCmd = &cobra.Command{
+		Use:   "wait",
+		Short: "Waits until the Envoy proxy is ready",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(c *cobra.Command, args []string) error {
+			client := &http.Client{
+				Timeout: waitRequestTimeout,
+			}
+			log.Infof("Waiting for the Envoy proxy to be ready (timeout: %v)", waitTimeout)
+			return waitReady(client, waitURL, waitTimeout, waitPeriod)
+		},
+	}
+)
+
+// waitReady polls the readiness endpoint of the status server until it succeeds or the timeout elapses.
+func waitReady(client *http.Client, url string, timeout, period time.Duration) error {
+	var err error
+	deadline := time.Now().Add(timeout)
+	for {
+		if err = checkReady(client, url); err == nil {
+			log.Info("Envoy proxy is ready")
+			return nil
+		}
+		if time.Now().Add(period).After(deadline) {
+			return fmt.Errorf("timeout waiting for the Envoy proxy to become ready, last error: %v", err)
+		}
+		log.Debugf("Envoy proxy is not ready yet: %v", err)
+		time.Sleep(period)
+	}
+}
+
+func checkReady(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func init() {
+	waitCmd.PersistentFlags().DurationVar(&waitTimeout, "timeout", 60*time.Second,
+		"Maximum time to wait for the proxy to be ready")
+	waitCmd.PersistentFlags().DurationVar(&waitRequestTimeout, "requestTimeout", 500*time.Millisecond,
+		"Timeout of each readiness request")
+	waitCmd.PersistentFlags().DurationVar(&waitPeriod, "period", 500*time.Millisecond,
+		"Time between the readiness requests")
+	waitCmd.PersistentFlags().StringVar(&waitURL, "url", "http://localhost:15020/healthz/ready",
+		"Readiness URL of the status server of the proxy")
+	rootCmd.AddCommand(waitCmd)
+}
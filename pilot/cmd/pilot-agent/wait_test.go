@@ -0,0 +1,52 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitReady(t *testing.T) {
+	var probes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// The proxy becomes ready on the third probe.
+		if atomic.AddInt32(&probes, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Timeout: time.Second}
+	if err := waitReady(client, server.URL, 10*time.Second, time.Millisecond); err != nil {
+		t.Fatalf("waitReady() failed: %v", err)
+	}
+	if got := atomic.LoadInt32(&probes); got != 3 {
+		t.Errorf("got %d probes, want 3", got)
+	}
+
+	notReady := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer notReady.Close()
+	err := waitReady(client, notReady.URL, 10*time.Millisecond, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timeout waiting for the Envoy proxy") {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+}
@@ -43,6 +43,10 @@ var (
 
 	loggingOptions = log.DefaultOptions()
 
+	// trustDomainAliases are the other trust domains whose identities are trusted as the same
+	// identities in the trust domain.
+	trustDomainAliases []string
+
 	rootCmd = &cobra.Command{
 		Use:          "pilot-discovery",
 		Short:        "Istio Pilot.",
@@ -61,6 +65,7 @@ var (
 			}
 
 			spiffe.SetTrustDomain(spiffe.DetermineTrustDomain(serverArgs.Config.ControllerOptions.TrustDomain, hasKubeRegistry()))
+			spiffe.SetTrustDomainAliases(trustDomainAliases)
 
 			// Create the stop channel for all of the servers.
 			stop := make(chan struct{})
@@ -129,6 +134,10 @@ func init() {
 		"DNS domain suffix")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Config.ControllerOptions.TrustDomain, "trust-domain", "",
 		"The domain serves to identify the system with spiffe")
+	discoveryCmd.PersistentFlags().StringSliceVar(&trustDomainAliases,
+		"trust-domain-aliases", nil, "The other trust domains, e.g. the previous trust domain or the trust domains "+
+			"of merged meshes, whose identities are trusted in authorization policies and destination rule "+
+			"subject alt names as the same identities in the trust domain")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Service.Consul.ServerURL, "consulserverURL", "",
 		"URL for the Consul server")
 	discoveryCmd.PersistentFlags().DurationVar(&serverArgs.Service.Consul.Interval, "consulserverInterval", 2*time.Second,
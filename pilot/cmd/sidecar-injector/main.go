@@ -56,6 +56,7 @@ var (
 		port                int
 		healthCheckInterval time.Duration
 		healthCheckFile     string
+		namespaceValuesDir  string
 		probeOptions        probe.Options
 		kubeconfigFile      string
 		webhookConfigName   string
@@ -86,6 +87,7 @@ var (
 				Port:                flags.port,
 				HealthCheckInterval: flags.healthCheckInterval,
 				HealthCheckFile:     flags.healthCheckFile,
+				NamespaceValuesDir:  flags.namespaceValuesDir,
 			}
 			wh, err := inject.NewWebhook(parameters)
 			if err != nil {
@@ -219,6 +221,8 @@ func init() {
 		"Configure how frequently the health check file specified by --healthCheckFile should be updated")
 	rootCmd.PersistentFlags().StringVar(&flags.healthCheckFile, "healthCheckFile", "",
 		"File that should be periodically updated if health checking is enabled")
+	rootCmd.PersistentFlags().StringVar(&flags.namespaceValuesDir, "namespaceValuesDir", "",
+		"Directory holding the overrides of the injection values of some namespaces, in a file named after each namespace")
 	rootCmd.PersistentFlags().StringVar(&flags.kubeconfigFile, "kubeconfig", "",
 		"Specifies path to kubeconfig file. This must be specified when not running inside a Kubernetes pod.")
 	rootCmd.PersistentFlags().StringVar(&flags.webhookConfigName, "webhookConfigName", "istio-sidecar-injector",
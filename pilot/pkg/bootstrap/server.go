@@ -15,9 +15,11 @@
 package bootstrap
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -110,6 +112,7 @@ var (
 		plugin.Authn,
 		plugin.Authz,
 		plugin.Health,
+		plugin.LocalRateLimit,
 		plugin.Mixer,
 	}
 )
@@ -243,6 +246,8 @@ func NewServer(args PilotArgs) (*Server, error) {
 	if err := s.initMeshNetworks(&args); err != nil {
 		return nil, fmt.Errorf("mesh networks: %v", err)
 	}
+	s.initCertificateRevocationList()
+	s.initTrustBundles()
 	if err := s.initConfigController(&args); err != nil {
 		return nil, fmt.Errorf("config controller: %v", err)
 	}
@@ -463,6 +468,110 @@ func (s *Server) initMeshNetworks(args *PilotArgs) error { //nolint: unparam
 	return nil
 }
 
+// initCertificateRevocationList loads the CRL of the mesh CA, if configured, and pushes the sidecars
+// when it is updated or expires. An expired CRL is not pushed, since the sidecars would reject all the
+// certificates of the mesh CA: revocations are then not enforced until Citadel publishes a new CRL.
+func (s *Server) initCertificateRevocationList() {
+	file := pilot.CertificateRevocationListFile
+	if file == "" {
+		return
+	}
+	var expiry *time.Timer
+	load := func(crl []byte) {
+		if expiry != nil {
+			expiry.Stop()
+		}
+		nextUpdate, err := model.SetCertificateRevocationList(crl)
+		if err != nil {
+			log.Errorf("certificate revocation list %q is not pushed, revoked certificates are accepted: %v", file, err)
+			return
+		}
+		if len(crl) == 0 {
+			return
+		}
+		expiry = time.AfterFunc(time.Until(nextUpdate), func() {
+			log.Errorf("certificate revocation list %q expired on %v and is no longer pushed, revoked certificates "+
+				"are accepted", file, nextUpdate)
+			if s.EnvoyXdsServer != nil {
+				s.EnvoyXdsServer.ConfigUpdate(true)
+			}
+		})
+	}
+
+	crl, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Warnf("failed to read the certificate revocation list from %q: %v", file, err)
+	}
+	load(crl)
+
+	s.addFileWatcher(file, func() {
+		newCRL, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Warnf("failed to read the certificate revocation list from %q: %v", file, err)
+			return
+		}
+		if !bytes.Equal(newCRL, crl) {
+			log.Infof("certificate revocation list %q updated", file)
+			crl = newCRL
+			load(crl)
+			if s.EnvoyXdsServer != nil {
+				s.EnvoyXdsServer.ConfigUpdate(true)
+			}
+		}
+	})
+}
+
+// initTrustBundles loads the root certificates of the mesh and its peers by trust domain, if configured,
+// and pushes the sidecars when they are updated.
+func (s *Server) initTrustBundles() {
+	file := pilot.TrustBundlesFile
+	if file == "" {
+		return
+	}
+	value, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Warnf("failed to read the trust bundles from %q: %v", file, err)
+	}
+	if err := setTrustBundles(value); err != nil {
+		log.Warnf("failed to parse the trust bundles from %q: %v", file, err)
+	}
+
+	s.addFileWatcher(file, func() {
+		newValue, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Warnf("failed to read the trust bundles from %q: %v", file, err)
+			return
+		}
+		if !bytes.Equal(newValue, value) {
+			if err := setTrustBundles(newValue); err != nil {
+				log.Warnf("failed to parse the trust bundles from %q: %v", file, err)
+				return
+			}
+			log.Infof("trust bundles %q updated", file)
+			value = newValue
+			if s.EnvoyXdsServer != nil {
+				s.EnvoyXdsServer.ConfigUpdate(true)
+			}
+		}
+	})
+}
+
+// setTrustBundles parses the JSON object of the PEM-encoded root certificates by trust domain.
+func setTrustBundles(value []byte) error {
+	bundles := make(map[string][]byte)
+	if len(bytes.TrimSpace(value)) != 0 {
+		var values map[string]string
+		if err := json.Unmarshal(value, &values); err != nil {
+			return err
+		}
+		for trustDomain, rootCerts := range values {
+			bundles[trustDomain] = []byte(rootCerts)
+		}
+	}
+	model.SetTrustBundles(bundles)
+	return nil
+}
+
 func (s *Server) getKubeCfgFile(args *PilotArgs) string {
 	return args.Config.KubeConfig
 }
@@ -105,6 +105,16 @@ var knownTypes = map[string]schemaType{
 		},
 		collection: &SidecarList{},
 	},
+	model.LocalRateLimit.Type: {
+		schema: model.LocalRateLimit,
+		object: &LocalRateLimit{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "LocalRateLimit",
+				APIVersion: apiVersion(&model.LocalRateLimit),
+			},
+		},
+		collection: &LocalRateLimitList{},
+	},
 	model.HTTPAPISpec.Type: {
 		schema: model.HTTPAPISpec,
 		object: &HTTPAPISpec{
@@ -938,6 +948,109 @@ func (in *SidecarList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// LocalRateLimit is the generic Kubernetes API object wrapper
+type LocalRateLimit struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               map[string]interface{} `json:"spec"`
+}
+
+// GetSpec from a wrapper
+func (in *LocalRateLimit) GetSpec() map[string]interface{} {
+	return in.Spec
+}
+
+// SetSpec for a wrapper
+func (in *LocalRateLimit) SetSpec(spec map[string]interface{}) {
+	in.Spec = spec
+}
+
+// GetObjectMeta from a wrapper
+func (in *LocalRateLimit) GetObjectMeta() meta_v1.ObjectMeta {
+	return in.ObjectMeta
+}
+
+// SetObjectMeta for a wrapper
+func (in *LocalRateLimit) SetObjectMeta(metadata meta_v1.ObjectMeta) {
+	in.ObjectMeta = metadata
+}
+
+// LocalRateLimitList is the generic Kubernetes API list wrapper
+type LocalRateLimitList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	meta_v1.ListMeta `json:"metadata"`
+	Items            []LocalRateLimit `json:"items"`
+}
+
+// GetItems from a wrapper
+func (in *LocalRateLimitList) GetItems() []IstioObject {
+	out := make([]IstioObject, len(in.Items))
+	for i := range in.Items {
+		out[i] = &in.Items[i]
+	}
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalRateLimit) DeepCopyInto(out *LocalRateLimit) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalRateLimit.
+func (in *LocalRateLimit) DeepCopy() *LocalRateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LocalRateLimit) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalRateLimitList) DeepCopyInto(out *LocalRateLimitList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LocalRateLimit, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalRateLimitList.
+func (in *LocalRateLimitList) DeepCopy() *LocalRateLimitList {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalRateLimitList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LocalRateLimitList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+
+	return nil
+}
+
 // HTTPAPISpec is the generic Kubernetes API object wrapper
 type HTTPAPISpec struct {
 	meta_v1.TypeMeta   `json:",inline"`
@@ -19,6 +19,8 @@ package inject
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -35,12 +37,18 @@ const (
 	// We reuse it for taking over application's readiness probing as well.
 	// TODO: replace the hardcoded statusPort elsewhere by this variable as much as possible.
 	StatusPortCmdFlagName = "statusPort"
+
+	// grpcHealthProbeCommand is the command commonly used by exec probes to health check gRPC servers.
+	grpcHealthProbeCommand = "grpc_health_probe"
 )
 
 var (
 	// regex pattern for to extract the pilot agent probing port.
 	// Supported format, --statusPort, -statusPort, --statusPort=15020.
 	statusPortPattern = regexp.MustCompile(fmt.Sprintf(`^-{1,2}%s(=(?P<port>\d+))?$`, StatusPortCmdFlagName))
+
+	// regex pattern to parse the flags of grpc_health_probe, e.g. -addr=:8080, --service foo.
+	grpcHealthProbeFlagPattern = regexp.MustCompile(`^-{1,2}([a-z-]+)(=(.*))?$`)
 )
 
 // ShouldRewriteAppHTTPProbers returns if we should rewrite apps' probers config.
@@ -103,41 +111,121 @@ func extractStatusPort(sidecar *corev1.Container) int {
 	return -1
 }
 
-// convertAppProber returns a overwritten `HTTPGetAction` for pilot agent to take over.
-func convertAppProber(probe *corev1.Probe, newURL string, statusPort int) *corev1.HTTPGetAction {
-	if probe == nil || probe.HTTPGet == nil {
+// convertAppProber returns a overwritten `Probe` for pilot agent to take over.
+func convertAppProber(probe *corev1.Probe, newURL string, statusPort int) *corev1.Probe {
+	if probe == nil {
 		return nil
 	}
-	c := probe.HTTPGet.DeepCopy()
-	// Change the application container prober config.
-	c.Port = intstr.FromInt(statusPort)
-	c.Path = newURL
-	// For HTTPS prober, we change to HTTP,
-	// and pilot agent uses https to request application prober endpoint.
-	// Kubelet -> HTTP -> Pilot Agent -> HTTPS -> Application
-	if c.Scheme == corev1.URISchemeHTTPS {
-		c.Scheme = corev1.URISchemeHTTP
-	}
-	return c
+	if probe.HTTPGet != nil {
+		p := probe.DeepCopy()
+		// Change the application container prober config.
+		p.HTTPGet.Port = intstr.FromInt(statusPort)
+		p.HTTPGet.Path = newURL
+		// For HTTPS prober, we change to HTTP,
+		// and pilot agent uses https to request application prober endpoint.
+		// Kubelet -> HTTP -> Pilot Agent -> HTTPS -> Application
+		if p.HTTPGet.Scheme == corev1.URISchemeHTTPS {
+			p.HTTPGet.Scheme = corev1.URISchemeHTTP
+		}
+		return p
+	}
+	if probe.TCPSocket != nil || grpcHealthProbeAction(probe) != nil {
+		// Kubelet -> HTTP -> Pilot Agent -> TCP connection or gRPC health check -> Application
+		p := probe.DeepCopy()
+		p.Handler = corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: newURL,
+				Port: intstr.FromInt(statusPort),
+			},
+		}
+		return p
+	}
+	return nil
+}
+
+// grpcHealthProbeAction returns the gRPC health check performed by an exec probe running the
+// grpc_health_probe command against a local port, or nil if the probe is anything else.
+// Probes using any other flag, e.g. TLS settings, are not taken over.
+func grpcHealthProbeAction(probe *corev1.Probe) *status.GRPCAction {
+	if probe == nil || probe.Exec == nil || len(probe.Exec.Command) < 2 {
+		return nil
+	}
+	if path.Base(probe.Exec.Command[0]) != grpcHealthProbeCommand {
+		return nil
+	}
+	action := &status.GRPCAction{}
+	args := probe.Exec.Command[1:]
+	for i := 0; i < len(args); i++ {
+		match := grpcHealthProbeFlagPattern.FindStringSubmatch(args[i])
+		if match == nil {
+			return nil
+		}
+		name, value := match[1], match[3]
+		if match[2] == "" {
+			// The value is in the next arg, e.g. -addr :8080.
+			if i+1 >= len(args) {
+				return nil
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "addr":
+			host, portStr, err := net.SplitHostPort(value)
+			if err != nil || (host != "" && host != "localhost" && host != "127.0.0.1") {
+				return nil
+			}
+			port, err := strconv.Atoi(portStr)
+			if err != nil {
+				return nil
+			}
+			action.Port = port
+		case "service":
+			action.Service = value
+		default:
+			return nil
+		}
+	}
+	if action.Port == 0 {
+		return nil
+	}
+	return action
 }
 
 // DumpAppProbers returns a json encoded string as `status.KubeAppProbers`.
 // Also update the probers so that all usages of named port will be resolved to integer.
 func DumpAppProbers(podspec *corev1.PodSpec) string {
 	out := status.KubeAppProbers{}
-	updateNamedPort := func(p *corev1.Probe, portMap map[string]int32) *corev1.HTTPGetAction {
-		if p == nil || p.HTTPGet == nil {
+	resolveNamedPort := func(port *intstr.IntOrString, portMap map[string]int32) bool {
+		if port.Type == intstr.String {
+			p, exists := portMap[port.StrVal]
+			if !exists {
+				return false
+			}
+			*port = intstr.FromInt(int(p))
+		}
+		return true
+	}
+	updateNamedPort := func(p *corev1.Probe, portMap map[string]int32) *status.Prober {
+		if p == nil {
 			return nil
 		}
-		h := p.HTTPGet
-		if h.Port.Type == intstr.String {
-			port, exists := portMap[h.Port.StrVal]
-			if !exists {
+		switch {
+		case p.HTTPGet != nil:
+			if !resolveNamedPort(&p.HTTPGet.Port, portMap) {
+				return nil
+			}
+			return &status.Prober{HTTPGet: p.HTTPGet}
+		case p.TCPSocket != nil:
+			if !resolveNamedPort(&p.TCPSocket.Port, portMap) {
 				return nil
 			}
-			h.Port = intstr.FromInt(int(port))
+			return &status.Prober{TCPSocket: p.TCPSocket}
 		}
-		return h
+		if g := grpcHealthProbeAction(p); g != nil {
+			return &status.Prober{GRPC: g}
+		}
+		return nil
 	}
 	for _, c := range podspec.Containers {
 		if c.Name == ProxyContainerName {
@@ -191,11 +279,11 @@ func rewriteAppHTTPProbe(annotations map[string]string, podSpec *corev1.PodSpec,
 			continue
 		}
 		readyz, livez := status.FormatProberURL(c.Name)
-		if hg := convertAppProber(c.ReadinessProbe, readyz, statusPort); hg != nil {
-			*c.ReadinessProbe.HTTPGet = *hg
+		if p := convertAppProber(c.ReadinessProbe, readyz, statusPort); p != nil {
+			*c.ReadinessProbe = *p
 		}
-		if hg := convertAppProber(c.LivenessProbe, livez, statusPort); hg != nil {
-			*c.LivenessProbe.HTTPGet = *hg
+		if p := convertAppProber(c.LivenessProbe, livez, statusPort); p != nil {
+			*c.LivenessProbe = *p
 		}
 	}
 }
@@ -226,19 +314,28 @@ func createProbeRewritePatch(annotations map[string]string, podSpec *corev1.PodS
 		}
 		readyz, livez := status.FormatProberURL(c.Name)
 		if after := convertAppProber(c.ReadinessProbe, readyz, statusPort); after != nil {
-			patch = append(patch, rfc6902PatchOperation{
-				Op:    "replace",
-				Path:  fmt.Sprintf("/spec/containers/%v/readinessProbe/httpGet", i),
-				Value: *after,
-			})
+			patch = append(patch, probeRewritePatch(c.ReadinessProbe, after, fmt.Sprintf("/spec/containers/%v/readinessProbe", i)))
 		}
 		if after := convertAppProber(c.LivenessProbe, livez, statusPort); after != nil {
-			patch = append(patch, rfc6902PatchOperation{
-				Op:    "replace",
-				Path:  fmt.Sprintf("/spec/containers/%v/livenessProbe/httpGet", i),
-				Value: *after,
-			})
+			patch = append(patch, probeRewritePatch(c.LivenessProbe, after, fmt.Sprintf("/spec/containers/%v/livenessProbe", i)))
 		}
 	}
 	return patch
 }
+
+// probeRewritePatch returns the patch replacing the probe at the given path. Only the action is
+// replaced for HTTP probes, while the whole probe is replaced for the others to drop their action.
+func probeRewritePatch(before, after *corev1.Probe, probePath string) rfc6902PatchOperation {
+	if before.HTTPGet != nil {
+		return rfc6902PatchOperation{
+			Op:    "replace",
+			Path:  probePath + "/httpGet",
+			Value: *after.HTTPGet,
+		}
+	}
+	return rfc6902PatchOperation{
+		Op:    "replace",
+		Path:  probePath,
+		Value: *after,
+	}
+}
@@ -14,9 +14,12 @@
 package inject
 
 import (
+	"reflect"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pilot/cmd/pilot-agent/status"
 )
 
 func TestFindSidecar(t *testing.T) {
@@ -107,3 +110,26 @@ func TestShouldRewriteAppHTTPProbers(t *testing.T) {
 		}
 	}
 }
+
+func TestGRPCHealthProbeAction(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		command  []string
+		expected *status.GRPCAction
+	}{
+		{"addr-with-equal", []string{"grpc_health_probe", "-addr=:5000"}, &status.GRPCAction{Port: 5000}},
+		{"addr-in-next-arg", []string{"/bin/grpc_health_probe", "--addr", "localhost:5000"}, &status.GRPCAction{Port: 5000}},
+		{"service", []string{"grpc_health_probe", "-addr=127.0.0.1:5000", "-service=foo"}, &status.GRPCAction{Port: 5000, Service: "foo"}},
+		{"remote-addr", []string{"grpc_health_probe", "-addr=10.0.0.1:5000"}, nil},
+		{"tls", []string{"grpc_health_probe", "-addr=:5000", "-tls"}, nil},
+		{"missing-addr", []string{"grpc_health_probe", "-service=foo"}, nil},
+		{"missing-addr-value", []string{"grpc_health_probe", "-addr"}, nil},
+		{"other-command", []string{"cat", "-addr=:5000"}, nil},
+	} {
+		probe := &corev1.Probe{Handler: corev1.Handler{Exec: &corev1.ExecAction{Command: tc.command}}}
+		got := grpcHealthProbeAction(probe)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("[%v] failed, want %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
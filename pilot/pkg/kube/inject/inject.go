@@ -54,6 +54,7 @@ const (
 	annotationPolicy                = "sidecar.istio.io/inject"
 	annotationStatus                = "sidecar.istio.io/status"
 	annotationRewriteAppHTTPProbers = "sidecar.istio.io/rewriteAppHTTPProbers"
+	annotationProxyConfig           = "sidecar.istio.io/proxyConfig"
 )
 
 // per-sidecar policy and status
@@ -79,9 +80,25 @@ var (
 		annotations.Register("traffic.sidecar.istio.io/includeInboundPorts", "").Name:          ValidateIncludeInboundPorts,
 		annotations.Register("traffic.sidecar.istio.io/excludeInboundPorts", "").Name:          ValidateExcludeInboundPorts,
 		annotations.Register("traffic.sidecar.istio.io/kubevirtInterfaces", "").Name:           alwaysValidFunc,
+		annotations.Register("traffic.sidecar.istio.io/dnsCapturePort",
+			"Port of the DNS proxy of istio-proxy to which the DNS queries are redirected, disabled if 0").Name: validateDNSCapturePort,
+		annotations.Register(annotationMergeMetrics,
+			"Merge the metrics of the application scraped by Prometheus with the ones of istio-proxy").Name: alwaysValidFunc,
+		annotations.Register("sidecar.istio.io/statsInclusionRegexps",
+			"Regexps of the metric families merged by istio-proxy").Name: validateRegexpList,
+		annotations.Register("sidecar.istio.io/statsExclusionRegexps",
+			"Regexps of the metric families excluded from the merged metrics of istio-proxy").Name: validateRegexpList,
+		annotations.Register(annotationProxyConfig,
+			"Proxy config in YAML overriding the mesh config, reloaded by istio-proxy when it changes").Name: validateProxyConfig,
 	}
 )
 
+// validateProxyConfig checks that the value is a proxy config in YAML. It is merged with the
+// mesh config by istio-proxy, so it is validated as a whole there.
+func validateProxyConfig(value string) error {
+	return model.ApplyYAML(value, &meshconfig.ProxyConfig{})
+}
+
 func validateAnnotations(annotations map[string]string) (err error) {
 	for name, value := range annotations {
 		if v, ok := annotationRegistry[name]; ok {
@@ -146,6 +163,9 @@ type SidecarInjectionSpec struct {
 	Volumes             []corev1.Volume               `yaml:"volumes"`
 	DNSConfig           *corev1.PodDNSConfig          `yaml:"dnsConfig"`
 	ImagePullSecrets    []corev1.LocalObjectReference `yaml:"imagePullSecrets"`
+	// PodRedirectAnnot are the annotations describing the traffic redirection of the pod, added
+	// when the redirection is set up by the CNI plugin instead of the istio-init container.
+	PodRedirectAnnot map[string]string `yaml:"podRedirectAnnot"`
 }
 
 // SidecarTemplateData is the data object to which the templated
@@ -196,6 +216,13 @@ type Params struct {
 	// Comma separated list of inbound ports. If set, inbound traffic will not be redirected for those ports.
 	// Exclusions are only applied if configured to redirect all inbound traffic. By default, no ports are excluded.
 	ExcludeInboundPorts string `json:"excludeInboundPorts"`
+	// Comma separated lists of regexps selecting the metric families served by the sidecar for the pods
+	// whose metrics are merged. By default, all the metric families are served.
+	StatsInclusionRegexps string `json:"statsInclusionRegexps"`
+	StatsExclusionRegexps string `json:"statsExclusionRegexps"`
+	// Port of the DNS proxy of the sidecar to which the DNS queries of the application are redirected.
+	// The DNS queries are not redirected if 0, the default.
+	DNSCapturePort int `json:"dnsCapturePort"`
 	// Comma separated list of virtual interfaces whose inbound traffic (from VM) will be treated as outbound
 	// By default, no interfaces are configured.
 	KubevirtInterfaces           string                 `json:"kubevirtInterfaces"`
@@ -208,11 +235,18 @@ type Params struct {
 	ReadinessFailureThreshold    uint32                 `json:"readinessFailureThreshold"`
 	RewriteAppHTTPProbe          bool                   `json:"rewriteAppHTTPProbe"`
 	EnableCoreDump               bool                   `json:"enableCoreDump"`
-	DebugMode                    bool                   `json:"debugMode"`
-	Privileged                   bool                   `json:"privileged"`
-	SDSEnabled                   bool                   `json:"sdsEnabled"`
-	EnableSdsTokenMount          bool                   `json:"enableSdsTokenMount"`
-	PodDNSSearchNamespaces       []string               `json:"podDNSSearchNamespaces"`
+	// EnableCNI leaves out the istio-init container, as the traffic redirection is set up by the
+	// Istio CNI plugin.
+	EnableCNI              bool     `json:"enableCNI"`
+	DebugMode              bool     `json:"debugMode"`
+	Privileged             bool     `json:"privileged"`
+	SDSEnabled             bool     `json:"sdsEnabled"`
+	EnableSdsTokenMount    bool     `json:"enableSdsTokenMount"`
+	PodDNSSearchNamespaces []string `json:"podDNSSearchNamespaces"`
+
+	// HoldApplicationUntilProxyStarts starts the proxy container first, and delays the start of
+	// the application containers until the proxy is ready.
+	HoldApplicationUntilProxyStarts bool `json:"holdApplicationUntilProxyStarts"`
 }
 
 // Validate validates the parameters and returns an error if there is configuration issue.
@@ -226,30 +260,45 @@ func (p *Params) Validate() error {
 	if err := ValidateIncludeInboundPorts(p.IncludeInboundPorts); err != nil {
 		return err
 	}
+	if p.StatsInclusionRegexps != "" {
+		if err := validateRegexpList(p.StatsInclusionRegexps); err != nil {
+			return err
+		}
+	}
+	if p.StatsExclusionRegexps != "" {
+		if err := validateRegexpList(p.StatsExclusionRegexps); err != nil {
+			return err
+		}
+	}
 	return ValidateExcludeInboundPorts(p.ExcludeInboundPorts)
 }
 
 // intoHelmValues returns a map of the traversed path in helm values YAML to the param value.
 func (p *Params) intoHelmValues() map[string]string {
 	vals := map[string]string{
-		"global.proxy_init.image":                    p.InitImage,
-		"global.proxy.image":                         p.ProxyImage,
-		"global.proxy.enableCoreDump":                strconv.FormatBool(p.EnableCoreDump),
-		"global.proxy.privileged":                    strconv.FormatBool(p.Privileged),
-		"global.imagePullPolicy":                     p.ImagePullPolicy,
-		"global.proxy.statusPort":                    strconv.Itoa(p.StatusPort),
-		"global.proxy.tracer":                        p.Tracer,
-		"global.proxy.readinessInitialDelaySeconds":  strconv.Itoa(int(p.ReadinessInitialDelaySeconds)),
-		"global.proxy.readinessPeriodSeconds":        strconv.Itoa(int(p.ReadinessPeriodSeconds)),
-		"global.proxy.readinessFailureThreshold":     strconv.Itoa(int(p.ReadinessFailureThreshold)),
-		"global.sds.enabled":                         strconv.FormatBool(p.SDSEnabled),
-		"global.sds.useTrustworthyJwt":               strconv.FormatBool(p.EnableSdsTokenMount),
-		"global.proxy.includeIPRanges":               p.IncludeIPRanges,
-		"global.proxy.excludeIPRanges":               p.ExcludeIPRanges,
-		"global.proxy.includeInboundPorts":           p.IncludeInboundPorts,
-		"global.proxy.excludeInboundPorts":           p.ExcludeInboundPorts,
-		"sidecarInjectorWebhook.rewriteAppHTTPProbe": strconv.FormatBool(p.RewriteAppHTTPProbe),
-		"global.podDNSSearchNamespaces":              getHelmValue(p.PodDNSSearchNamespaces),
+		"global.proxy_init.image":                      p.InitImage,
+		"global.proxy.image":                           p.ProxyImage,
+		"global.proxy.enableCoreDump":                  strconv.FormatBool(p.EnableCoreDump),
+		"global.proxy.holdApplicationUntilProxyStarts": strconv.FormatBool(p.HoldApplicationUntilProxyStarts),
+		"istio_cni.enabled":                            strconv.FormatBool(p.EnableCNI),
+		"global.proxy.privileged":                      strconv.FormatBool(p.Privileged),
+		"global.imagePullPolicy":                       p.ImagePullPolicy,
+		"global.proxy.statusPort":                      strconv.Itoa(p.StatusPort),
+		"global.proxy.tracer":                          p.Tracer,
+		"global.proxy.readinessInitialDelaySeconds":    strconv.Itoa(int(p.ReadinessInitialDelaySeconds)),
+		"global.proxy.readinessPeriodSeconds":          strconv.Itoa(int(p.ReadinessPeriodSeconds)),
+		"global.proxy.readinessFailureThreshold":       strconv.Itoa(int(p.ReadinessFailureThreshold)),
+		"global.sds.enabled":                           strconv.FormatBool(p.SDSEnabled),
+		"global.sds.useTrustworthyJwt":                 strconv.FormatBool(p.EnableSdsTokenMount),
+		"global.proxy.includeIPRanges":                 p.IncludeIPRanges,
+		"global.proxy.excludeIPRanges":                 p.ExcludeIPRanges,
+		"global.proxy.includeInboundPorts":             p.IncludeInboundPorts,
+		"global.proxy.excludeInboundPorts":             p.ExcludeInboundPorts,
+		"global.proxy.dnsCapturePort":                  strconv.Itoa(p.DNSCapturePort),
+		"global.proxy.statsInclusionRegexps":           p.StatsInclusionRegexps,
+		"global.proxy.statsExclusionRegexps":           p.StatsExclusionRegexps,
+		"sidecarInjectorWebhook.rewriteAppHTTPProbe":   strconv.FormatBool(p.RewriteAppHTTPProbe),
+		"global.podDNSSearchNamespaces":                getHelmValue(p.PodDNSSearchNamespaces),
 	}
 	return vals
 }
@@ -387,13 +436,69 @@ func validateStatusPort(port string) error {
 	return nil
 }
 
+// validateDNSCapturePort validates the port of the DNS proxy, 0 disabling the redirection.
+func validateDNSCapturePort(port string) error {
+	if _, e := parsePort(port); e != nil {
+		return fmt.Errorf("dnsCapturePort invalid: %v", e)
+	}
+	return nil
+}
+
 // validateUInt32 validates that the given annotation value is a positive integer.
 func validateUInt32(value string) error {
 	_, err := strconv.ParseUint(value, 10, 32)
 	return err
 }
 
+// Rules deciding whether a pod is injected, as reported by InjectionDecision.
+const (
+	// RuleNamespaceSelector is the namespace selector of the webhook configuration, evaluated by
+	// the API server before calling the webhook.
+	RuleNamespaceSelector    = "NamespaceSelector"
+	RuleHostNetwork          = "HostNetwork"
+	RuleIgnoredNamespace     = "IgnoredNamespace"
+	RuleAnnotation           = "Annotation"
+	RuleNeverInjectSelector  = "NeverInjectSelector"
+	RuleAlwaysInjectSelector = "AlwaysInjectSelector"
+	RulePolicy               = "Policy"
+)
+
+// InjectionDecision is the outcome of the injection policy for a pod, along with the rule which
+// decided it.
+type InjectionDecision struct {
+	Inject bool   `json:"inject"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+	// NamespaceOverride is set if the values of the injection template are overridden for the
+	// namespace of the pod.
+	NamespaceOverride bool `json:"namespaceOverride,omitempty"`
+}
+
 func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, metadata *metav1.ObjectMeta) bool { // nolint: lll
+	return injectionDecision(ignored, config, podSpec, metadata).Inject
+}
+
+// ExplainInjection returns the injection decision for a pod. The namespace selector of the webhook
+// configuration is only evaluated if both the selector and the namespace of the pod are given.
+func ExplainInjection(config *Config, namespaceSelector *metav1.LabelSelector, namespace *corev1.Namespace,
+	pod *corev1.Pod) InjectionDecision {
+	if namespaceSelector != nil && namespace != nil {
+		selector, err := metav1.LabelSelectorAsSelector(namespaceSelector)
+		if err != nil {
+			return InjectionDecision{Rule: RuleNamespaceSelector, Reason: fmt.Sprintf("invalid namespace selector: %v", err)}
+		}
+		if !selector.Matches(labels.Set(namespace.Labels)) {
+			return InjectionDecision{
+				Rule: RuleNamespaceSelector,
+				Reason: fmt.Sprintf("the labels of namespace %s do not match the namespace selector %q of the webhook",
+					namespace.Name, selector),
+			}
+		}
+	}
+	return injectionDecision(ignoredNamespaces, config, &pod.Spec, &pod.ObjectMeta)
+}
+
+func injectionDecision(ignored []string, config *Config, podSpec *corev1.PodSpec, metadata *metav1.ObjectMeta) InjectionDecision { // nolint: lll
 	// Skip injection when host networking is enabled. The problem is
 	// that the iptable changes are assumed to be within the pod when,
 	// in fact, they are changing the routing at the host level. This
@@ -401,13 +506,13 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 	// affect the network provider within the cluster causing
 	// additional pod failures.
 	if podSpec.HostNetwork {
-		return false
+		return InjectionDecision{Rule: RuleHostNetwork, Reason: "the pod uses the host network"}
 	}
 
 	// skip special kubernetes system namespaces
 	for _, namespace := range ignored {
 		if metadata.Namespace == namespace {
-			return false
+			return InjectionDecision{Rule: RuleIgnoredNamespace, Reason: fmt.Sprintf("namespace %s is never injected", namespace)}
 		}
 	}
 
@@ -418,12 +523,18 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 
 	var useDefault bool
 	var inject bool
-	switch strings.ToLower(annotations[annotationPolicy]) {
+	decision := InjectionDecision{}
+	switch value := annotations[annotationPolicy]; strings.ToLower(value) {
 	// http://yaml.org/type/bool.html
 	case "y", "yes", "true", "on":
 		inject = true
+		decision.Rule = RuleAnnotation
+		decision.Reason = fmt.Sprintf("the pod has the %s=%s annotation", annotationPolicy, value)
 	case "":
 		useDefault = true
+	default:
+		decision.Rule = RuleAnnotation
+		decision.Reason = fmt.Sprintf("the pod has the %s=%s annotation", annotationPolicy, value)
 	}
 
 	// If an annotation is not explicitly given, check the LabelSelectors, starting with NeverInject
@@ -437,6 +548,8 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 					metadata.Namespace, potentialPodName(metadata))
 				inject = false
 				useDefault = false
+				decision.Rule = RuleNeverInjectSelector
+				decision.Reason = fmt.Sprintf("the pod labels match the neverInjectSelector %q", selector)
 				break
 			}
 		}
@@ -453,6 +566,8 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 					metadata.Namespace, potentialPodName(metadata))
 				inject = true
 				useDefault = false
+				decision.Rule = RuleAlwaysInjectSelector
+				decision.Reason = fmt.Sprintf("the pod labels match the alwaysInjectSelector %q", selector)
 				break
 			}
 		}
@@ -464,6 +579,8 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 		log.Errorf("Illegal value for autoInject:%s, must be one of [%s,%s]. Auto injection disabled!",
 			config.Policy, InjectionPolicyDisabled, InjectionPolicyEnabled)
 		required = false
+		decision.Rule = RulePolicy
+		decision.Reason = fmt.Sprintf("the injection policy %q is invalid", config.Policy)
 	case InjectionPolicyDisabled:
 		if useDefault {
 			required = false
@@ -477,6 +594,11 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 			required = inject
 		}
 	}
+	if useDefault && decision.Rule == "" {
+		decision.Rule = RulePolicy
+		decision.Reason = fmt.Sprintf("the default injection policy is %s", config.Policy)
+	}
+	decision.Inject = required
 
 	if log.DebugEnabled() {
 		// Build a log message for the annotations.
@@ -499,7 +621,7 @@ func injectRequired(ignored []string, config *Config, podSpec *corev1.PodSpec, m
 			annotationStr)
 	}
 
-	return required
+	return decision
 }
 
 func formatDuration(in *types.Duration) string {
@@ -772,7 +894,13 @@ func intoObject(sidecarTemplate string, valuesConfig string, meshconfig *meshcon
 
 	podSpec.InitContainers = append(podSpec.InitContainers, spec.InitContainers...)
 
-	podSpec.Containers = append(podSpec.Containers, spec.Containers...)
+	for _, c := range spec.Containers {
+		if holdsApplication(&c) {
+			podSpec.Containers = append([]corev1.Container{c}, podSpec.Containers...)
+		} else {
+			podSpec.Containers = append(podSpec.Containers, c)
+		}
+	}
 	podSpec.Volumes = append(podSpec.Volumes, spec.Volumes...)
 
 	podSpec.DNSConfig = spec.DNSConfig
@@ -780,6 +908,7 @@ func intoObject(sidecarTemplate string, valuesConfig string, meshconfig *meshcon
 	// Modify application containers' HTTP probe after appending injected containers.
 	// Because we need to extract istio-proxy's statusPort.
 	rewriteAppHTTPProbe(metadata.Annotations, podSpec, spec)
+	prometheusAnnotations := rewritePrometheusAnnotations(metadata.Annotations, FindSidecar(podSpec.Containers))
 
 	// due to bug https://github.com/kubernetes/kubernetes/issues/57923,
 	// k8s sa jwt token volume mount file is only accessible to root user, not istio-proxy(the user that istio proxy runs as).
@@ -795,6 +924,12 @@ func intoObject(sidecarTemplate string, valuesConfig string, meshconfig *meshcon
 		metadata.Annotations = make(map[string]string)
 	}
 	metadata.Annotations[annotationStatus] = status
+	for k, v := range prometheusAnnotations {
+		metadata.Annotations[k] = v
+	}
+	for k, v := range spec.PodRedirectAnnot {
+		metadata.Annotations[k] = v
+	}
 
 	return out, nil
 }
@@ -879,6 +1014,14 @@ func toYaml(value interface{}) string {
 	return string(y)
 }
 
+// holdsApplication returns whether the proxy container delays the start of the application with a
+// postStart hook waiting for the proxy to be ready. Kubernetes starts the containers of a pod in
+// order, and only starts a container once the postStart hook of the previous one completes, so
+// such a proxy must be the first container of the pod.
+func holdsApplication(c *corev1.Container) bool {
+	return c.Name == ProxyContainerName && c.Lifecycle != nil && c.Lifecycle.PostStart != nil
+}
+
 func annotation(meta metav1.ObjectMeta, name string, defaultValue interface{}) string {
 	value, ok := meta.Annotations[name]
 	if !ok {
@@ -73,12 +73,17 @@ func TestIntoResourceFile(t *testing.T) {
 		includeInboundPorts          string
 		excludeInboundPorts          string
 		kubevirtInterfaces           string
+		dnsCapturePort               int
+		statsInclusionRegexps        string
+		statsExclusionRegexps        string
 		statusPort                   int
 		readinessInitialDelaySeconds uint32
 		readinessPeriodSeconds       uint32
 		readinessFailureThreshold    uint32
 		enableAuth                   bool
 		enableCoreDump               bool
+		enableCNI                    bool
+		holdApplication              bool
 		debugMode                    bool
 		privileged                   bool
 		tproxy                       bool
@@ -223,6 +228,90 @@ func TestIntoResourceFile(t *testing.T) {
 			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
 			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
 		},
+		{
+			in:                           "hello.yaml",
+			want:                         "hello-cni.yaml.injected",
+			enableCNI:                    true,
+			includeIPRanges:              DefaultIncludeIPRanges,
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			statusPort:                   DefaultStatusPort,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+		},
+		{
+			// The regexps of the installation are overridden by the annotations of the pod.
+			in:                           "hello-prometheus.yaml",
+			want:                         "hello-prometheus-regexps.yaml.injected",
+			includeIPRanges:              DefaultIncludeIPRanges,
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			statsInclusionRegexps:        "istio_.*",
+			statsExclusionRegexps:        "envoy_server_.*",
+			statusPort:                   DefaultStatusPort,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+		},
+		{
+			in:                           "hello.yaml",
+			want:                         "hello-stats-regexps.yaml.injected",
+			includeIPRanges:              DefaultIncludeIPRanges,
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			statsInclusionRegexps:        "istio_.*,envoy_cluster_.*",
+			statsExclusionRegexps:        "envoy_cluster_.*_bucket",
+			statusPort:                   DefaultStatusPort,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+		},
+		{
+			in:                           "hello.yaml",
+			want:                         "hello-dns.yaml.injected",
+			includeIPRanges:              DefaultIncludeIPRanges,
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			dnsCapturePort:               15053,
+			statusPort:                   DefaultStatusPort,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+		},
+		{
+			// The redirection values of the installation are stamped on the pod for the CNI plugin.
+			in:                           "hello.yaml",
+			want:                         "hello-cni-redirect.yaml.injected",
+			enableCNI:                    true,
+			includeIPRanges:              "10.0.0.0/8",
+			excludeIPRanges:              "10.1.0.0/16",
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			excludeInboundPorts:          "8000",
+			dnsCapturePort:               15053,
+			statusPort:                   15021,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+			tproxy:                       true,
+		},
+		{
+			in:                           "hello.yaml",
+			want:                         "hello-hold.yaml.injected",
+			holdApplication:              true,
+			includeIPRanges:              DefaultIncludeIPRanges,
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			statusPort:                   DefaultStatusPort,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+		},
+		{
+			in:                           "hello-prometheus.yaml",
+			want:                         "hello-prometheus.yaml.injected",
+			includeIPRanges:              DefaultIncludeIPRanges,
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			statusPort:                   DefaultStatusPort,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+		},
 		{
 			in:                           "auth.yaml",
 			want:                         "auth.yaml.injected",
@@ -456,6 +545,17 @@ func TestIntoResourceFile(t *testing.T) {
 			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
 			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
 		},
+		{
+			// Verifies that the proxy config annotation is mounted and passed to the agent.
+			in:                           "proxy_config_annotation.yaml",
+			want:                         "proxy_config_annotation.yaml.injected",
+			includeIPRanges:              DefaultIncludeIPRanges,
+			includeInboundPorts:          DefaultIncludeInboundPorts,
+			statusPort:                   DefaultStatusPort,
+			readinessInitialDelaySeconds: DefaultReadinessInitialDelaySeconds,
+			readinessPeriodSeconds:       DefaultReadinessPeriodSeconds,
+			readinessFailureThreshold:    DefaultReadinessFailureThreshold,
+		},
 		{
 			// Verifies that the kubevirtInterfaces list are applied properly from parameters..
 			in:                           "kubevirtInterfaces.yaml",
@@ -514,29 +614,34 @@ func TestIntoResourceFile(t *testing.T) {
 			}
 
 			params := &Params{
-				InitImage:                    InitImageName(unitTestHub, unitTestTag, c.debugMode),
-				ProxyImage:                   ProxyImageName(unitTestHub, unitTestTag, c.debugMode),
-				ImagePullPolicy:              "IfNotPresent",
-				SDSEnabled:                   false,
-				EnableSdsTokenMount:          false,
-				Verbosity:                    DefaultVerbosity,
-				SidecarProxyUID:              DefaultSidecarProxyUID,
-				Version:                      "12345678",
-				EnableCoreDump:               c.enableCoreDump,
-				Privileged:                   c.privileged,
-				Mesh:                         &mesh,
-				DebugMode:                    c.debugMode,
-				IncludeIPRanges:              c.includeIPRanges,
-				ExcludeIPRanges:              c.excludeIPRanges,
-				IncludeInboundPorts:          c.includeInboundPorts,
-				ExcludeInboundPorts:          c.excludeInboundPorts,
-				KubevirtInterfaces:           c.kubevirtInterfaces,
-				StatusPort:                   c.statusPort,
-				ReadinessInitialDelaySeconds: c.readinessInitialDelaySeconds,
-				ReadinessPeriodSeconds:       c.readinessPeriodSeconds,
-				ReadinessFailureThreshold:    c.readinessFailureThreshold,
-				RewriteAppHTTPProbe:          false,
-				PodDNSSearchNamespaces:       c.podDNSSearchNamespaces,
+				InitImage:                       InitImageName(unitTestHub, unitTestTag, c.debugMode),
+				ProxyImage:                      ProxyImageName(unitTestHub, unitTestTag, c.debugMode),
+				ImagePullPolicy:                 "IfNotPresent",
+				SDSEnabled:                      false,
+				EnableSdsTokenMount:             false,
+				Verbosity:                       DefaultVerbosity,
+				SidecarProxyUID:                 DefaultSidecarProxyUID,
+				Version:                         "12345678",
+				EnableCoreDump:                  c.enableCoreDump,
+				EnableCNI:                       c.enableCNI,
+				HoldApplicationUntilProxyStarts: c.holdApplication,
+				Privileged:                      c.privileged,
+				Mesh:                            &mesh,
+				DebugMode:                       c.debugMode,
+				IncludeIPRanges:                 c.includeIPRanges,
+				ExcludeIPRanges:                 c.excludeIPRanges,
+				IncludeInboundPorts:             c.includeInboundPorts,
+				ExcludeInboundPorts:             c.excludeInboundPorts,
+				KubevirtInterfaces:              c.kubevirtInterfaces,
+				DNSCapturePort:                  c.dnsCapturePort,
+				StatsInclusionRegexps:           c.statsInclusionRegexps,
+				StatsExclusionRegexps:           c.statsExclusionRegexps,
+				StatusPort:                      c.statusPort,
+				ReadinessInitialDelaySeconds:    c.readinessInitialDelaySeconds,
+				ReadinessPeriodSeconds:          c.readinessPeriodSeconds,
+				ReadinessFailureThreshold:       c.readinessFailureThreshold,
+				RewriteAppHTTPProbe:             false,
+				PodDNSSearchNamespaces:          c.podDNSSearchNamespaces,
 			}
 			if c.imagePullPolicy != "" {
 				params.ImagePullPolicy = c.imagePullPolicy
@@ -613,6 +718,11 @@ func TestRewriteAppProbe(t *testing.T) {
 			rewriteAppHTTPProbe: true,
 			want:                "https-probes.yaml.injected",
 		},
+		{
+			in:                  "tcp-grpc-probes.yaml",
+			rewriteAppHTTPProbe: true,
+			want:                "tcp-grpc-probes.yaml.injected",
+		},
 		{
 			in:                  "hello-probes-with-flag-set-in-annotation.yaml",
 			rewriteAppHTTPProbe: false,
@@ -739,6 +849,10 @@ func TestInvalidAnnotations(t *testing.T) {
 			annotation: "excludeinboundports",
 			in:         "traffic-annotations-bad-excludeinboundports.yaml",
 		},
+		{
+			annotation: "proxyconfig",
+			in:         "proxy_config_annotation-bad.yaml",
+		},
 	}
 
 	for _, c := range cases {
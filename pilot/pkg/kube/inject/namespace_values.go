@@ -0,0 +1,91 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// LoadNamespaceValues reads the per-namespace overrides of the values of the injection template
+// from a directory, typically a mounted ConfigMap with one key per namespace, and returns the
+// resulting values of each namespace. The namespaces whose overrides can't be read or merged keep
+// their previous values, if any, and the errors are returned along with the values.
+func LoadNamespaceValues(dir, valuesConfig string, previous map[string]string) (map[string]string, error) {
+	namespaceValues := map[string]string{}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		for namespace, values := range previous {
+			namespaceValues[namespace] = values
+		}
+		return namespaceValues, err
+	}
+	var errs error
+	for _, f := range files {
+		// Skip the hidden files and directories created by Kubernetes for the ConfigMap volumes.
+		if strings.HasPrefix(f.Name(), ".") || f.IsDir() {
+			continue
+		}
+		overrides, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err == nil {
+			var merged string
+			if merged, err = MergeValues(valuesConfig, string(overrides)); err == nil {
+				namespaceValues[f.Name()] = merged
+				continue
+			}
+		}
+		errs = multierror.Append(errs, fmt.Errorf("invalid values of namespace %s: %v", f.Name(), err))
+		if values, ok := previous[f.Name()]; ok {
+			namespaceValues[f.Name()] = values
+		}
+	}
+	return namespaceValues, errs
+}
+
+// MergeValues returns the values of the injection template with the overrides applied. Maps are
+// merged recursively, while the other values of the overrides replace the original ones.
+func MergeValues(valuesConfig, overrides string) (string, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(valuesConfig), &values); err != nil {
+		return "", err
+	}
+	overrideValues := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(overrides), &overrideValues); err != nil {
+		return "", err
+	}
+	merged, err := yaml.Marshal(mergeMaps(values, overrideValues))
+	if err != nil {
+		return "", err
+	}
+	return string(merged), nil
+}
+
+func mergeMaps(base, overrides map[string]interface{}) map[string]interface{} {
+	for k, v := range overrides {
+		if overrideMap, ok := v.(map[string]interface{}); ok {
+			if baseMap, ok := base[k].(map[string]interface{}); ok {
+				base[k] = mergeMaps(baseMap, overrideMap)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+const testValues = `
+global:
+  hub: docker.io/istio
+  tag: 1.2.0
+  proxy:
+    image: proxyv2
+    resources:
+      requests:
+        cpu: 100m
+`
+
+func TestMergeValues(t *testing.T) {
+	merged, err := MergeValues(testValues, `
+global:
+  proxy:
+    image: proxy-debug
+    resources:
+      limits:
+        cpu: 500m
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want map[string]interface{}
+	if err := yaml.Unmarshal([]byte(merged), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(`
+global:
+  hub: docker.io/istio
+  tag: 1.2.0
+  proxy:
+    image: proxy-debug
+    resources:
+      requests:
+        cpu: 100m
+      limits:
+        cpu: 500m
+`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got values:\n%s", merged)
+	}
+
+	if _, err := MergeValues(testValues, "global: ["); err == nil {
+		t.Error("expected an error for invalid overrides")
+	}
+}
+
+func TestLoadNamespaceValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "namespace_values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	// Mimic the layout of a ConfigMap volume.
+	if err := os.Mkdir(filepath.Join(dir, "..data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"debug":   "global:\n  proxy:\n    image: proxy-debug\n",
+		"invalid": "global: [",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, err := LoadNamespaceValues(dir, testValues, map[string]string{"invalid": "previous", "removed": "previous"})
+	if err == nil {
+		t.Error("expected an error for the invalid values")
+	}
+	if len(values) != 2 || values["invalid"] != "previous" {
+		t.Fatalf("got values of namespaces %v, want debug and the previous values of invalid", values)
+	}
+	var debug struct {
+		Global struct {
+			Tag   string `json:"tag"`
+			Proxy struct {
+				Image string `json:"image"`
+			} `json:"proxy"`
+		} `json:"global"`
+	}
+	if err := yaml.Unmarshal([]byte(values["debug"]), &debug); err != nil {
+		t.Fatal(err)
+	}
+	if debug.Global.Proxy.Image != "proxy-debug" || debug.Global.Tag != "1.2.0" {
+		t.Errorf("unexpected values of namespace debug:\n%s", values["debug"])
+	}
+
+	// The previous values are kept if the directory can't be read.
+	values, err = LoadNamespaceValues(filepath.Join(dir, "missing"), testValues, map[string]string{"debug": "previous"})
+	if err == nil || values["debug"] != "previous" {
+		t.Errorf("got values %v and error %v for a missing directory", values, err)
+	}
+}
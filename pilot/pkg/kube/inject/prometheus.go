@@ -0,0 +1,102 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pilot/cmd/pilot-agent/status"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	prometheusScrapeAnnotation = "prometheus.io/scrape"
+	prometheusPortAnnotation   = "prometheus.io/port"
+	prometheusPathAnnotation   = "prometheus.io/path"
+	prometheusSchemeAnnotation = "prometheus.io/scheme"
+
+	// annotationMergeMetrics disables the merging of the metrics of a scraped application with
+	// the ones of its sidecar when set to false.
+	annotationMergeMetrics = "prometheus.istio.io/mergeMetrics"
+)
+
+// shouldMergeMetrics returns true if the pod is scraped by Prometheus on a given port, and the
+// merging of its metrics is not disabled. Without a port, Prometheus scrapes all the ports of
+// the pod, which can't be merged.
+func shouldMergeMetrics(annotations map[string]string) bool {
+	if scrape, err := strconv.ParseBool(annotations[prometheusScrapeAnnotation]); err != nil || !scrape {
+		return false
+	}
+	if merge, err := strconv.ParseBool(annotations[annotationMergeMetrics]); err == nil && !merge {
+		return false
+	}
+	if port, err := strconv.Atoi(annotations[prometheusPortAnnotation]); err != nil || port <= 0 || port > 65535 {
+		return false
+	}
+	return true
+}
+
+// rewritePrometheusAnnotations points Prometheus to the merged metrics of the application and
+// its sidecar, served by pilot agent over plain HTTP on the status port, which the Prometheus of
+// the installation only scrapes with the http scheme. The original annotations are passed to pilot
+// agent in an environment variable of the sidecar. It returns the annotations to set on the pod,
+// which are empty if the metrics are not merged.
+func rewritePrometheusAnnotations(annotations map[string]string, sidecar *corev1.Container) map[string]string {
+	if sidecar == nil || !shouldMergeMetrics(annotations) {
+		return nil
+	}
+	statusPort := extractStatusPort(sidecar)
+	// Pilot agent statusPort is not defined, the metrics can't be merged.
+	if statusPort == -1 {
+		return nil
+	}
+	port := strconv.Itoa(statusPort)
+	// The annotations were already rewritten, e.g. by a previous kube-inject.
+	if annotations[prometheusPortAnnotation] == port && annotations[prometheusPathAnnotation] == status.PrometheusPath &&
+		annotations[prometheusSchemeAnnotation] == "http" {
+		return nil
+	}
+	b, err := json.Marshal(status.PrometheusScrapeConfiguration{
+		Scrape: annotations[prometheusScrapeAnnotation],
+		Port:   annotations[prometheusPortAnnotation],
+		Path:   annotations[prometheusPathAnnotation],
+	})
+	if err != nil {
+		log.Errorf("failed to serialize the prometheus annotations: %v", err)
+		return nil
+	}
+	sidecar.Env = append(sidecar.Env, corev1.EnvVar{Name: status.PrometheusAnnotationsEnvName, Value: string(b)})
+	return map[string]string{
+		prometheusPortAnnotation:   port,
+		prometheusPathAnnotation:   status.PrometheusPath,
+		prometheusSchemeAnnotation: "http",
+	}
+}
+
+// validateRegexpList validates a comma separated list of regexps.
+func validateRegexpList(value string) error {
+	for _, r := range strings.Split(value, ",") {
+		if _, err := regexp.Compile(r); err != nil {
+			return fmt.Errorf("invalid regexp %q: %v", r, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pilot/cmd/pilot-agent/status"
+)
+
+func TestRewritePrometheusAnnotations(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		args        []string
+		want        map[string]string
+		wantEnv     string
+	}{
+		{
+			name:        "not scraped",
+			annotations: map[string]string{},
+			args:        []string{"--statusPort", "15020"},
+		},
+		{
+			name:        "scraped",
+			annotations: map[string]string{"prometheus.io/scrape": "true", "prometheus.io/port": "9090"},
+			args:        []string{"--statusPort", "15020"},
+			want: map[string]string{
+				"prometheus.io/port": "15020", "prometheus.io/path": status.PrometheusPath, "prometheus.io/scheme": "http",
+			},
+			wantEnv: `{"scrape":"true","port":"9090","path":""}`,
+		},
+		{
+			name: "merge disabled",
+			annotations: map[string]string{
+				"prometheus.io/scrape": "true", "prometheus.io/port": "9090", annotationMergeMetrics: "false",
+			},
+			args: []string{"--statusPort", "15020"},
+		},
+		{
+			name:        "no prometheus port",
+			annotations: map[string]string{"prometheus.io/scrape": "true"},
+			args:        []string{"--statusPort", "15020"},
+		},
+		{
+			name:        "invalid prometheus port",
+			annotations: map[string]string{"prometheus.io/scrape": "true", "prometheus.io/port": "http"},
+			args:        []string{"--statusPort", "15020"},
+		},
+		{
+			name:        "no status port",
+			annotations: map[string]string{"prometheus.io/scrape": "true", "prometheus.io/port": "9090"},
+		},
+		{
+			name: "already rewritten",
+			annotations: map[string]string{
+				"prometheus.io/scrape": "true", "prometheus.io/port": "15020", "prometheus.io/path": status.PrometheusPath,
+				"prometheus.io/scheme": "http",
+			},
+			args: []string{"--statusPort=15020"},
+		},
+	}
+	for _, c := range cases {
+		sidecar := &corev1.Container{Name: ProxyContainerName, Args: c.args}
+		got := rewritePrometheusAnnotations(c.annotations, sidecar)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got annotations %v, want %v", c.name, got, c.want)
+		}
+		env := ""
+		for _, e := range sidecar.Env {
+			if e.Name == status.PrometheusAnnotationsEnvName {
+				env = e.Value
+			}
+		}
+		if env != c.wantEnv {
+			t.Errorf("%s: got env %q, want %q", c.name, env, c.wantEnv)
+		}
+	}
+}
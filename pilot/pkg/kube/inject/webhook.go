@@ -62,6 +62,8 @@ type Webhook struct {
 	sidecarTemplateVersion string
 	meshConfig             *meshconfig.MeshConfig
 	valuesConfig           string
+	// namespaceValues are the values of the namespaces overriding the values of the injection template.
+	namespaceValues map[string]string
 
 	healthCheckInterval time.Duration
 	healthCheckFile     string
@@ -74,6 +76,9 @@ type Webhook struct {
 	certFile   string
 	keyFile    string
 	cert       *tls.Certificate
+
+	// namespaceValuesDir holds the overrides of the values of each namespace.
+	namespaceValuesDir string
 }
 
 func loadConfig(injectFile, meshFile, valuesFile string) (*Config, *meshconfig.MeshConfig, string, error) {
@@ -134,6 +139,11 @@ type WebhookParameters struct {
 	// HealthCheckFile specifies the path to the health check file
 	// that is periodically updated.
 	HealthCheckFile string
+
+	// NamespaceValuesDir is the optional directory holding the overrides
+	// of the values of the injection template for some namespaces, in a
+	// file named after each namespace, e.g. to use a different proxy image.
+	NamespaceValuesDir string
 }
 
 // NewWebhook creates a new instance of a mutating webhook for automatic sidecar injection.
@@ -147,13 +157,25 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 		return nil, err
 	}
 
+	var namespaceValues map[string]string
+	if p.NamespaceValuesDir != "" {
+		// An invalid override only affects its namespace, which is injected with the default values.
+		if namespaceValues, err = LoadNamespaceValues(p.NamespaceValuesDir, valuesConfig, nil); err != nil {
+			log.Errorf("failed to load the values of the namespaces: %v", err)
+		}
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 	// watch the parent directory of the target files so we can catch
 	// symlink updates of k8s ConfigMaps volumes.
-	for _, file := range []string{p.ConfigFile, p.MeshFile, p.CertFile, p.KeyFile} {
+	watched := []string{p.ConfigFile, p.MeshFile, p.CertFile, p.KeyFile}
+	if p.NamespaceValuesDir != "" {
+		watched = append(watched, filepath.Join(p.NamespaceValuesDir, "namespace"))
+	}
+	for _, file := range watched {
 		watchDir, _ := filepath.Split(file)
 		if err := watcher.Watch(watchDir); err != nil {
 			return nil, fmt.Errorf("could not watch %v: %v", file, err)
@@ -170,6 +192,8 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 		configFile:             p.ConfigFile,
 		valuesFile:             p.ValuesFile,
 		valuesConfig:           valuesConfig,
+		namespaceValues:        namespaceValues,
+		namespaceValuesDir:     p.NamespaceValuesDir,
 		meshFile:               p.MeshFile,
 		watcher:                watcher,
 		healthCheckInterval:    p.HealthCheckInterval,
@@ -182,6 +206,7 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 	wh.server.TLSConfig = &tls.Config{GetCertificate: wh.getCert}
 	h := http.NewServeMux()
 	h.HandleFunc("/inject", wh.serveInject)
+	h.HandleFunc("/explain", wh.serveExplain)
 	wh.server.Handler = h
 
 	return wh, nil
@@ -215,6 +240,18 @@ func (wh *Webhook) Run(stop <-chan struct{}) {
 				break
 			}
 
+			var namespaceValues map[string]string
+			if wh.namespaceValuesDir != "" {
+				wh.mu.RLock()
+				previous := wh.namespaceValues
+				wh.mu.RUnlock()
+				// Keep the previous values of the namespaces whose overrides are invalid, without
+				// blocking the reload of the other files.
+				if namespaceValues, err = LoadNamespaceValues(wh.namespaceValuesDir, valuesConfig, previous); err != nil {
+					log.Errorf("update error: %v", err)
+				}
+			}
+
 			version := sidecarTemplateVersionHash(sidecarConfig.Template)
 			pair, err := tls.LoadX509KeyPair(wh.certFile, wh.keyFile)
 			if err != nil {
@@ -224,6 +261,7 @@ func (wh *Webhook) Run(stop <-chan struct{}) {
 			wh.mu.Lock()
 			wh.sidecarConfig = sidecarConfig
 			wh.valuesConfig = valuesConfig
+			wh.namespaceValues = namespaceValues
 			wh.sidecarTemplateVersion = version
 			wh.meshConfig = meshConfig
 			wh.cert = &pair
@@ -339,6 +377,8 @@ func addContainer(target, added []corev1.Container, basePath string) (patch []rf
 		if first {
 			first = false
 			value = []corev1.Container{add}
+		} else if holdsApplication(&add) {
+			path += "/0"
 		} else {
 			path += "/-"
 		}
@@ -446,6 +486,13 @@ func updateAnnotation(target map[string]string, added map[string]string) (patch
 func createPatch(pod *corev1.Pod, prevStatus *SidecarInjectionStatus, annotations map[string]string, sic *SidecarInjectionSpec) ([]byte, error) {
 	var patch []rfc6902PatchOperation
 
+	// The probes are rewritten first, as the indices of the containers of the pod change once the
+	// previously injected containers are removed and the sidecar is inserted, possibly first.
+	rewrite := ShouldRewriteAppHTTPProbers(pod.Annotations, sic)
+	if rewrite {
+		patch = append(patch, createProbeRewritePatch(pod.Annotations, &pod.Spec, sic)...)
+	}
+
 	// Remove any containers previously injected by kube-inject using
 	// container and volume name as unique key for removal.
 	patch = append(patch, removeContainers(pod.Spec.InitContainers, prevStatus.InitContainers, "/spec/initContainers")...)
@@ -453,7 +500,6 @@ func createPatch(pod *corev1.Pod, prevStatus *SidecarInjectionStatus, annotation
 	patch = append(patch, removeVolumes(pod.Spec.Volumes, prevStatus.Volumes, "/spec/volumes")...)
 	patch = append(patch, removeImagePullSecrets(pod.Spec.ImagePullSecrets, prevStatus.ImagePullSecrets, "/spec/imagePullSecrets")...)
 
-	rewrite := ShouldRewriteAppHTTPProbers(pod.Annotations, sic)
 	addAppProberCmd := func() {
 		if !rewrite {
 			return
@@ -469,6 +515,12 @@ func createPatch(pod *corev1.Pod, prevStatus *SidecarInjectionStatus, annotation
 		}
 	}
 	addAppProberCmd()
+	for k, v := range rewritePrometheusAnnotations(pod.Annotations, FindSidecar(sic.Containers)) {
+		annotations[k] = v
+	}
+	for k, v := range sic.PodRedirectAnnot {
+		annotations[k] = v
+	}
 
 	patch = append(patch, addContainer(pod.Spec.InitContainers, sic.InitContainers, "/spec/initContainers")...)
 	patch = append(patch, addContainer(pod.Spec.Containers, sic.Containers, "/spec/containers")...)
@@ -485,10 +537,6 @@ func createPatch(pod *corev1.Pod, prevStatus *SidecarInjectionStatus, annotation
 
 	patch = append(patch, updateAnnotation(pod.Annotations, annotations)...)
 
-	if rewrite {
-		patch = append(patch, createProbeRewritePatch(pod.Annotations, &pod.Spec, sic)...)
-	}
-
 	return json.Marshal(patch)
 }
 
@@ -572,7 +620,12 @@ func (wh *Webhook) inject(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionRespons
 		}
 	}
 
-	spec, status, err := InjectionData(wh.sidecarConfig.Template, wh.valuesConfig, wh.sidecarTemplateVersion, &pod.ObjectMeta, &pod.Spec, &pod.ObjectMeta, wh.meshConfig.DefaultConfig, wh.meshConfig) // nolint: lll
+	valuesConfig, namespaceOverride := wh.valuesForNamespace(pod.ObjectMeta.Namespace)
+	if namespaceOverride {
+		log.Infof("Using the values of namespace %s", pod.ObjectMeta.Namespace)
+	}
+
+	spec, status, err := InjectionData(wh.sidecarConfig.Template, valuesConfig, wh.sidecarTemplateVersion, &pod.ObjectMeta, &pod.Spec, &pod.ObjectMeta, wh.meshConfig.DefaultConfig, wh.meshConfig) // nolint: lll
 	if err != nil {
 		log.Infof("Injection data: err=%v spec=%v\n", err, status)
 		return toAdmissionResponse(err)
@@ -600,6 +653,54 @@ func (wh *Webhook) inject(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionRespons
 	return &reviewResponse
 }
 
+// valuesForNamespace returns the values of the injection template for a namespace, and whether
+// they are overridden for the namespace.
+func (wh *Webhook) valuesForNamespace(namespace string) (string, bool) {
+	if values, ok := wh.namespaceValues[namespace]; ok {
+		return values, true
+	}
+	return wh.valuesConfig, false
+}
+
+// ExplainRequest is the body of the requests to the explain endpoint of the webhook.
+type ExplainRequest struct {
+	Pod *corev1.Pod `json:"pod"`
+	// Namespace and NamespaceSelector are optional, and must be both set to check the namespace
+	// selector of the webhook configuration, which is not known by the webhook.
+	Namespace         *corev1.Namespace     `json:"namespace,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// serveExplain returns the injection decision for a pod, without injecting it.
+func (wh *Webhook) serveExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	req := ExplainRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Pod == nil {
+		http.Error(w, "no pod in the request", http.StatusBadRequest)
+		return
+	}
+	if req.Pod.Namespace == "" && req.Namespace != nil {
+		req.Pod.Namespace = req.Namespace.Name
+	}
+
+	wh.mu.RLock()
+	decision := ExplainInjection(wh.sidecarConfig, req.NamespaceSelector, req.Namespace, req.Pod)
+	_, decision.NamespaceOverride = wh.valuesForNamespace(req.Pod.Namespace)
+	wh.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(decision); err != nil {
+		log.Errorf("Could not write response: %v", err)
+	}
+}
+
 func (wh *Webhook) serveInject(w http.ResponseWriter, r *http.Request) {
 	var body []byte
 	if r.Body != nil {
@@ -38,6 +38,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/helm/pkg/chartutil"
 	"k8s.io/helm/pkg/engine"
 	"k8s.io/helm/pkg/proto/hapi/chart"
@@ -613,6 +614,11 @@ func TestWebhookInject(t *testing.T) {
 			wantFile:     "TestWebhookInject_https_probe_rewrite.patch",
 			templateFile: "TestWebhookInject_https_probe_rewrite_template.yaml",
 		},
+		{
+			inputFile:    "TestWebhookInject_tcp_grpc_probe_rewrite.yaml",
+			wantFile:     "TestWebhookInject_tcp_grpc_probe_rewrite.patch",
+			templateFile: "TestWebhookInject_tcp_grpc_probe_rewrite_template.yaml",
+		},
 		{
 			inputFile:    "TestWebhookInject_http_probe_rewrite_enabled_via_annotation.yaml",
 			wantFile:     "TestWebhookInject_http_probe_rewrite_enabled_via_annotation.patch",
@@ -1506,6 +1512,41 @@ func TestReloadCert(t *testing.T) {
 	}, "10s", "100ms").Should(gomega.BeTrue())
 }
 
+func TestReloadWithInvalidNamespaceValues(t *testing.T) {
+	wh, cleanup := createWebhook(t, minimalSidecarTemplate)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "namespace_values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo"), []byte("global: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wh.namespaceValuesDir = dir
+	wh.namespaceValues = map[string]string{"foo": "previous"}
+
+	stop := make(chan struct{})
+	defer func() { close(stop) }()
+	go wh.Run(stop)
+	// The cert is reloaded in spite of the invalid overrides, which keep their previous values.
+	if err := ioutil.WriteFile(wh.certFile, rotatedCert, 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", wh.certFile, err)
+	}
+	if err := ioutil.WriteFile(wh.keyFile, rotatedKey, 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", wh.keyFile, err)
+	}
+	g := gomega.NewGomegaWithT(t)
+	g.Eventually(func() bool {
+		return checkCert(t, wh, rotatedCert, rotatedKey)
+	}, "10s", "100ms").Should(gomega.BeTrue())
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	if wh.namespaceValues["foo"] != "previous" {
+		t.Errorf("got values %q of namespace foo, want the previous ones", wh.namespaceValues["foo"])
+	}
+}
+
 func checkCert(t *testing.T, wh *Webhook, cert, key []byte) bool {
 	t.Helper()
 	actual, err := wh.getCert(nil)
@@ -1549,3 +1590,251 @@ func BenchmarkInjectServe(b *testing.B) {
 		wh.serveInject(httptest.NewRecorder(), req)
 	}
 }
+
+func TestExplainInjection(t *testing.T) {
+	config := &Config{
+		Policy:               InjectionPolicyDisabled,
+		AlwaysInjectSelector: []metav1.LabelSelector{*parseToLabelSelector(t, "inject=always")},
+		NeverInjectSelector:  []metav1.LabelSelector{*parseToLabelSelector(t, "inject=never")},
+	}
+	namespaceSelector := parseToLabelSelector(t, "istio-injection=enabled")
+	enabled := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "test-namespace",
+		Labels: map[string]string{"istio-injection": "enabled"},
+	}}
+	cases := []struct {
+		name              string
+		namespaceSelector *metav1.LabelSelector
+		namespace         *corev1.Namespace
+		pod               *corev1.Pod
+		want              InjectionDecision
+	}{
+		{
+			name:              "namespace not selected",
+			namespaceSelector: namespaceSelector,
+			namespace:         &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-namespace"}},
+			pod:               &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace"}},
+			want:              InjectionDecision{Rule: RuleNamespaceSelector},
+		},
+		{
+			name:              "namespace selected",
+			namespaceSelector: namespaceSelector,
+			namespace:         enabled,
+			pod:               &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace"}},
+			want:              InjectionDecision{Rule: RulePolicy},
+		},
+		{
+			name: "ignored namespace",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceSystem}},
+			want: InjectionDecision{Rule: RuleIgnoredNamespace},
+		},
+		{
+			name: "host network",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace"},
+				Spec:       corev1.PodSpec{HostNetwork: true},
+			},
+			want: InjectionDecision{Rule: RuleHostNetwork},
+		},
+		{
+			name: "annotation",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "test-namespace",
+				Annotations: map[string]string{annotationPolicy: "true"},
+				Labels:      map[string]string{"inject": "never"},
+			}},
+			want: InjectionDecision{Inject: true, Rule: RuleAnnotation},
+		},
+		{
+			name: "annotation disabled",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "test-namespace",
+				Annotations: map[string]string{annotationPolicy: "false"},
+			}},
+			want: InjectionDecision{Rule: RuleAnnotation},
+		},
+		{
+			name: "always inject selector",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Labels:    map[string]string{"inject": "always"},
+			}},
+			want: InjectionDecision{Inject: true, Rule: RuleAlwaysInjectSelector},
+		},
+		{
+			name: "never inject selector",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-namespace",
+				Labels:    map[string]string{"inject": "never"},
+			}},
+			want: InjectionDecision{Rule: RuleNeverInjectSelector},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ExplainInjection(config, c.namespaceSelector, c.namespace, c.pod)
+			if got.Inject != c.want.Inject || got.Rule != c.want.Rule {
+				t.Errorf("got %+v, want inject %v by rule %s", got, c.want.Inject, c.want.Rule)
+			}
+			if got.Reason == "" {
+				t.Error("no reason given for the decision")
+			}
+		})
+	}
+}
+
+func TestServeExplain(t *testing.T) {
+	wh, cleanup := createTestWebhook(t, minimalSidecarTemplate)
+	defer cleanup()
+	wh.namespaceValues = map[string]string{"overridden": wh.valuesConfig}
+
+	cases := []struct {
+		name string
+		body string
+		code int
+		want InjectionDecision
+	}{
+		{
+			name: "default policy",
+			body: `{"pod":{"metadata":{"namespace":"default"}}}`,
+			code: http.StatusOK,
+			want: InjectionDecision{Inject: true, Rule: RulePolicy, Reason: "the default injection policy is enabled"},
+		},
+		{
+			name: "namespace override",
+			body: `{"pod":{"metadata":{"namespace":"overridden"}}}`,
+			code: http.StatusOK,
+			want: InjectionDecision{
+				Inject:            true,
+				Rule:              RulePolicy,
+				Reason:            "the default injection policy is enabled",
+				NamespaceOverride: true,
+			},
+		},
+		{
+			name: "namespace selector",
+			body: `{"pod":{"metadata":{}},
+				"namespace":{"metadata":{"name":"default"}},
+				"namespaceSelector":{"matchLabels":{"istio-injection":"enabled"}}}`,
+			code: http.StatusOK,
+			want: InjectionDecision{
+				Rule:   RuleNamespaceSelector,
+				Reason: `the labels of namespace default do not match the namespace selector "istio-injection=enabled" of the webhook`,
+			},
+		},
+		{
+			name: "no pod",
+			body: `{}`,
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://sidecar-injector/explain", strings.NewReader(c.body))
+			rec := httptest.NewRecorder()
+			wh.serveExplain(rec, req)
+			if rec.Code != c.code {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, c.code, rec.Body.String())
+			}
+			if c.code != http.StatusOK {
+				return
+			}
+			var got InjectionDecision
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAddContainerHoldsApplication(t *testing.T) {
+	app := []corev1.Container{{Name: "hello"}}
+	proxy := corev1.Container{Name: ProxyContainerName}
+	holdingProxy := corev1.Container{
+		Name: ProxyContainerName,
+		Lifecycle: &corev1.Lifecycle{
+			PostStart: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"pilot-agent", "wait"}}},
+		},
+	}
+	cases := []struct {
+		name   string
+		target []corev1.Container
+		added  corev1.Container
+		want   string
+	}{
+		{"appended", app, proxy, "/spec/containers/-"},
+		{"first", app, holdingProxy, "/spec/containers/0"},
+		{"no container", nil, holdingProxy, "/spec/containers"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patch := addContainer(c.target, []corev1.Container{c.added}, "/spec/containers")
+			if len(patch) != 1 || patch[0].Path != c.want {
+				t.Errorf("got patch %+v, want a single operation on %s", patch, c.want)
+			}
+		})
+	}
+}
+
+func TestCreatePatchHoldsApplicationWithProbeRewrite(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "hello",
+					ReadinessProbe: &corev1.Probe{Handler: corev1.Handler{
+						HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromInt(8080)},
+					}},
+				},
+				{
+					Name: "second",
+					LivenessProbe: &corev1.Probe{Handler: corev1.Handler{
+						HTTPGet: &corev1.HTTPGetAction{Path: "/live", Port: intstr.FromInt(9090)},
+					}},
+				},
+			},
+		},
+	}
+	sic := &SidecarInjectionSpec{
+		RewriteAppHTTPProbe: true,
+		Containers: []corev1.Container{{
+			Name: ProxyContainerName,
+			Args: []string{"proxy", "sidecar", "--statusPort", "15020"},
+			Lifecycle: &corev1.Lifecycle{
+				PostStart: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"pilot-agent", "wait"}}},
+			},
+		}},
+	}
+	patch, err := createPatch(pod, &SidecarInjectionStatus{}, map[string]string{}, sic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podJSON, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patched corev1.Pod
+	if err := json.Unmarshal(applyJSONPatch(podJSON, patch, t), &patched); err != nil {
+		t.Fatal(err)
+	}
+
+	containers := patched.Spec.Containers
+	if len(containers) != 3 || containers[0].Name != ProxyContainerName {
+		t.Fatalf("got containers %v, want the sidecar first", containers)
+	}
+	if containers[0].ReadinessProbe != nil || containers[0].LivenessProbe != nil {
+		t.Errorf("probes added to the sidecar: %+v", containers[0])
+	}
+	if got := containers[1].ReadinessProbe.HTTPGet; got.Path != "/app-health/hello/readyz" || got.Port.IntValue() != 15020 {
+		t.Errorf("readiness probe of hello not rewritten: %+v", got)
+	}
+	if got := containers[2].LivenessProbe.HTTPGet; got.Path != "/app-health/second/livez" || got.Port.IntValue() != 15020 {
+		t.Errorf("liveness probe of second not rewritten: %+v", got)
+	}
+}
@@ -15,17 +15,24 @@
 package model
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/envoyproxy/go-control-plane/envoy/config/grpc_credential/v2alpha"
 	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus"
 
 	authn "istio.io/api/authentication/v1alpha1"
+
+	"istio.io/istio/pkg/spiffe"
 )
 
 const (
@@ -56,11 +63,194 @@ const (
 
 	// IngressGatewaySdsCaSuffix is the suffix of the sds resource name for root CA.
 	IngressGatewaySdsCaSuffix = "-cacert"
+
+	// JwtClaimMatchPrefix is the prefix of a VirtualService header match name that refers to a
+	// claim of the request JWT (validated by the authentication policy) instead of a header.
+	// E.g. "@request.auth.claims.tenant" matches on the "tenant" claim.
+	JwtClaimMatchPrefix = "@request.auth.claims."
+
+	// JwtClaimHeaderPrefix is the prefix of the request headers that carry the validated JWT claims
+	// from the authentication filters to the router.
+	JwtClaimHeaderPrefix = "x-istio-jwt-claim-"
 )
 
 // JwtKeyResolver resolves JWT public key and JwksURI.
 var JwtKeyResolver = newJwksResolver(JwtPubKeyExpireDuration, JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval)
 
+// revocationList is the PEM-encoded CRL of the mesh CA, with the time it expires.
+type revocationList struct {
+	crl        []byte
+	nextUpdate time.Time
+}
+
+// certificateRevocationList holds the revocationList of the mesh CA.
+var certificateRevocationList atomic.Value
+
+// certificateRevocationListExpired is set when the CRL of the mesh CA is expired, and no longer pushed.
+var certificateRevocationListExpired = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "pilot_crl_expired",
+	Help: "Whether the certificate revocation list of the mesh CA is expired, and no longer pushed to the sidecars.",
+})
+
+// certificateRevocationListLocalOnly is set when the trust bundles of peer meshes are loaded, so that the CRL of
+// the mesh CA is only pushed to the validation contexts of the identities of the local trust domain.
+var certificateRevocationListLocalOnly = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "pilot_crl_local_only",
+	Help: "Whether the certificate revocation list of the mesh CA is only pushed to the validation contexts " +
+		"of the local identities, since the trust bundles of peer meshes are loaded.",
+})
+
+func init() {
+	prometheus.MustRegister(certificateRevocationListExpired)
+	prometheus.MustRegister(certificateRevocationListLocalOnly)
+}
+
+// SetCertificateRevocationList sets the PEM-encoded CRL of the mesh CA, which is inlined in the validation
+// contexts of Istio mutual TLS, and returns the time it expires. No CRL is inlined if crl is empty, or if
+// it is invalid or expired, since the sidecars would then reject all the certificates of the mesh CA.
+func SetCertificateRevocationList(crl []byte) (time.Time, error) {
+	if len(crl) == 0 {
+		certificateRevocationList.Store(revocationList{})
+		certificateRevocationListExpired.Set(0)
+		updateCertificateRevocationListScope()
+		return time.Time{}, nil
+	}
+	parsed, err := x509.ParseCRL(crl)
+	if err != nil {
+		certificateRevocationList.Store(revocationList{})
+		updateCertificateRevocationListScope()
+		return time.Time{}, fmt.Errorf("invalid certificate revocation list: %v", err)
+	}
+	nextUpdate := parsed.TBSCertList.NextUpdate
+	if parsed.HasExpired(time.Now()) {
+		certificateRevocationList.Store(revocationList{})
+		certificateRevocationListExpired.Set(1)
+		updateCertificateRevocationListScope()
+		return nextUpdate, fmt.Errorf("the certificate revocation list expired on %v", nextUpdate)
+	}
+	certificateRevocationList.Store(revocationList{crl: crl, nextUpdate: nextUpdate})
+	certificateRevocationListExpired.Set(0)
+	updateCertificateRevocationListScope()
+	return nextUpdate, nil
+}
+
+// updateCertificateRevocationListScope records whether the CRL of the mesh CA is restricted to the validation
+// contexts of the local identities by the trust bundles of peer meshes.
+func updateCertificateRevocationListScope() {
+	list, _ := certificateRevocationList.Load().(revocationList)
+	if len(list.crl) == 0 || !hasPeerTrustBundles() {
+		certificateRevocationListLocalOnly.Set(0)
+		return
+	}
+	log.Warnf("The trust bundles of peer meshes are loaded: the certificate revocation list of the mesh CA " +
+		"is only pushed to the validation contexts of the identities of the local trust domain")
+	certificateRevocationListLocalOnly.Set(1)
+}
+
+// ConstructCertificateRevocationList returns the data source of the CRL of the mesh CA for the validation
+// context of the SANs, or nil if there is none or if it is expired. When the trust bundles of peer meshes
+// are loaded, the CRL is only returned if the SANs are all SPIFFE IDs of the local trust domain or one of
+// its aliases: the sidecars would otherwise reject the certificates of the peers, since the CRLs of their
+// CAs are unknown.
+func ConstructCertificateRevocationList(sans []string) *core.DataSource {
+	list, _ := certificateRevocationList.Load().(revocationList)
+	if len(list.crl) == 0 {
+		return nil
+	}
+	if hasPeerTrustBundles() && !isLocalIdentities(sans) {
+		return nil
+	}
+	if time.Now().After(list.nextUpdate) {
+		certificateRevocationListExpired.Set(1)
+		return nil
+	}
+	return &core.DataSource{
+		Specifier: &core.DataSource_InlineBytes{
+			InlineBytes: list.crl,
+		},
+	}
+}
+
+// trustBundles holds the PEM-encoded root certificates of the mesh and its peers by trust domain.
+var trustBundles atomic.Value
+
+// SetTrustBundles sets the PEM-encoded root certificates of the mesh and its peers by trust domain, which
+// are used to validate the certificates of Istio mutual TLS by the trust domain of the expected SANs.
+func SetTrustBundles(bundles map[string][]byte) {
+	trustBundles.Store(bundles)
+	updateCertificateRevocationListScope()
+}
+
+// ConstructTrustBundle returns the data source of the root certificates of the peer trust domain of the SANs,
+// so that the peer certificates are only accepted for the identities of the trust domain of their CA. It
+// returns nil if the SANs are not all SPIFFE IDs of the same trust domain, or if the trust domain is the one
+// of the mesh or one of its aliases, or has no bundle.
+func ConstructTrustBundle(sans []string) *core.DataSource {
+	bundles, _ := trustBundles.Load().(map[string][]byte)
+	if len(bundles) == 0 || len(sans) == 0 {
+		return nil
+	}
+	var trustDomain string
+	for i, san := range sans {
+		if !strings.HasPrefix(san, spiffe.URIPrefix) {
+			return nil
+		}
+		sanTrustDomain := strings.SplitN(strings.TrimPrefix(san, spiffe.URIPrefix), "/", 2)[0]
+		if i > 0 && sanTrustDomain != trustDomain {
+			return nil
+		}
+		trustDomain = sanTrustDomain
+	}
+	if isLocalTrustDomain(trustDomain) || len(bundles[trustDomain]) == 0 {
+		return nil
+	}
+	return &core.DataSource{
+		Specifier: &core.DataSource_InlineBytes{
+			InlineBytes: bundles[trustDomain],
+		},
+	}
+}
+
+// hasPeerTrustBundles returns whether the bundle of a peer trust domain is loaded.
+func hasPeerTrustBundles() bool {
+	bundles, _ := trustBundles.Load().(map[string][]byte)
+	for trustDomain, rootCerts := range bundles {
+		if len(rootCerts) != 0 && !isLocalTrustDomain(trustDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalIdentities returns whether the SANs are all SPIFFE IDs of the local trust domain or one of its aliases.
+func isLocalIdentities(sans []string) bool {
+	if len(sans) == 0 {
+		return false
+	}
+	for _, san := range sans {
+		if !strings.HasPrefix(san, spiffe.URIPrefix) {
+			return false
+		}
+		if !isLocalTrustDomain(strings.SplitN(strings.TrimPrefix(san, spiffe.URIPrefix), "/", 2)[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLocalTrustDomain returns whether the trust domain is the one of the mesh or one of its aliases.
+func isLocalTrustDomain(trustDomain string) bool {
+	if trustDomain == spiffe.GetTrustDomain() {
+		return true
+	}
+	for _, alias := range spiffe.GetTrustDomainAliases() {
+		if trustDomain == alias {
+			return true
+		}
+	}
+	return false
+}
+
 // GetConsolidateAuthenticationPolicy returns the authentication policy for workload specified by
 // hostname (or label selector if specified) and port, if defined.
 // It also tries to resolve JWKS URI if necessary.
@@ -178,6 +368,7 @@ func ConstructValidationContext(rootCAFilePath string, subjectAltNames []string)
 					Filename: rootCAFilePath,
 				},
 			},
+			Crl: ConstructCertificateRevocationList(subjectAltNames),
 		},
 	}
 
@@ -283,3 +474,30 @@ func findOrMarshalFileBasedMetadataConfig(tokenFileName, headerKey string, fbMet
 	fileBasedMetadataConfigAnyMap.Store(key, *any)
 	return any
 }
+
+// JwtClaimFromMatchName returns the JWT claim referred by the header match name, if the name
+// has the JwtClaimMatchPrefix. Otherwise, returns ("", false).
+func JwtClaimFromMatchName(name string) (string, bool) {
+	if !strings.HasPrefix(name, JwtClaimMatchPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, JwtClaimMatchPrefix), true
+}
+
+// JwtClaimHeaderName returns the name of the request header carrying the given JWT claim.
+// Claim names are lower-cased and any byte other than [a-z0-9_-] is replaced by '-', so that
+// the result is always a valid header name. The claims of a token mapped to the same header are
+// not forwarded, so a match on them never succeeds.
+func JwtClaimHeaderName(claim string) string {
+	out := []byte(claim)
+	for i, c := range out {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			out[i] = c - 'A' + 'a'
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '_', c == '-':
+		default:
+			out[i] = '-'
+		}
+	}
+	return JwtClaimHeaderPrefix + string(out)
+}
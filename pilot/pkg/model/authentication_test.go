@@ -15,13 +15,23 @@
 package model
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/envoyproxy/go-control-plane/envoy/config/grpc_credential/v2alpha"
 	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func TestParseJwksURI(t *testing.T) {
@@ -281,3 +291,207 @@ func constructsdsconfighelper(tokenFileName, headerKey string, metaConfig *v2alp
 		},
 	}
 }
+
+func TestJwtClaimHeaderName(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{
+			in:       "tenant",
+			expected: "x-istio-jwt-claim-tenant",
+		},
+		{
+			in:       "Group_ID",
+			expected: "x-istio-jwt-claim-group_id",
+		},
+		{
+			in:       "https://example.com/tier",
+			expected: "x-istio-jwt-claim-https---example-com-tier",
+		},
+	}
+	for _, c := range cases {
+		if got := JwtClaimHeaderName(c.in); got != c.expected {
+			t.Errorf("JwtClaimHeaderName(%s): expected %s, got %s", c.in, c.expected, got)
+		}
+	}
+}
+
+// testCRL returns a PEM-encoded CRL of a self-signed CA, valid until nextUpdate.
+func testCRL(t *testing.T, nextUpdate time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"cluster.local"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := []pkix.RevokedCertificate{{SerialNumber: big.NewInt(42), RevocationTime: time.Now()}}
+	crl, err := cert.CreateCRL(rand.Reader, key, revoked, nextUpdate.Add(-time.Hour), nextUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})
+}
+
+func TestConstructValidationContextWithCertificateRevocationList(t *testing.T) {
+	defer SetCertificateRevocationList(nil)
+
+	expected := &auth.CommonTlsContext_ValidationContext{
+		ValidationContext: &auth.CertificateValidationContext{
+			TrustedCa: &core.DataSource{
+				Specifier: &core.DataSource_Filename{Filename: "/etc/certs/root-cert.pem"},
+			},
+		},
+	}
+	if got := ConstructValidationContext("/etc/certs/root-cert.pem", nil); !reflect.DeepEqual(got, expected) {
+		t.Errorf("ConstructValidationContext: expected %v, got %v", expected, got)
+	}
+
+	crl := testCRL(t, time.Now().Add(time.Hour))
+	if _, err := SetCertificateRevocationList(crl); err != nil {
+		t.Fatalf("SetCertificateRevocationList: unexpected error %v", err)
+	}
+	withCRL := &auth.CommonTlsContext_ValidationContext{
+		ValidationContext: &auth.CertificateValidationContext{
+			TrustedCa: expected.ValidationContext.TrustedCa,
+			Crl: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{InlineBytes: crl},
+			},
+		},
+	}
+	if got := ConstructValidationContext("/etc/certs/root-cert.pem", nil); !reflect.DeepEqual(got, withCRL) {
+		t.Errorf("ConstructValidationContext with CRL: expected %v, got %v", withCRL, got)
+	}
+
+	// The sidecars would reject all the certificates with an invalid or expired CRL.
+	for name, crl := range map[string][]byte{
+		"invalid": []byte("crl"),
+		"expired": testCRL(t, time.Now().Add(-time.Minute)),
+	} {
+		if _, err := SetCertificateRevocationList(crl); err == nil {
+			t.Errorf("SetCertificateRevocationList %s: expected an error", name)
+		}
+		if got := ConstructValidationContext("/etc/certs/root-cert.pem", nil); !reflect.DeepEqual(got, expected) {
+			t.Errorf("ConstructValidationContext with %s CRL: expected %v, got %v", name, expected, got)
+		}
+	}
+}
+
+func TestConstructTrustBundle(t *testing.T) {
+	defer SetTrustBundles(nil)
+
+	sans := []string{"spiffe://east.example.com/ns/default/sa/reviews"}
+	if got := ConstructTrustBundle(sans); got != nil {
+		t.Errorf("ConstructTrustBundle without trust bundles: expected nil, got %v", got)
+	}
+
+	SetTrustBundles(map[string][]byte{
+		"cluster.local":    []byte("local-root"),
+		"east.example.com": []byte("east-root\n"),
+		"west.example.com": []byte("west-root"),
+	})
+	cases := []struct {
+		sans     []string
+		expected *core.DataSource
+	}{
+		{
+			sans: sans,
+			expected: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{InlineBytes: []byte("east-root\n")},
+			},
+		},
+		{
+			sans: []string{"spiffe://east.example.com/ns/default/sa/ratings", sans[0]},
+			expected: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{InlineBytes: []byte("east-root\n")},
+			},
+		},
+		{
+			// The CA of one trust domain must not be trusted for the identities of another one.
+			sans: []string{"spiffe://west.example.com/ns/default/sa/ratings", sans[0]},
+		},
+		{
+			// The trust domain of the SANs has no bundle.
+			sans: []string{"spiffe://north.example.com/ns/default/sa/ratings"},
+		},
+		{
+			// The identities of the mesh are validated with its own roots.
+			sans: []string{"spiffe://cluster.local/ns/default/sa/ratings"},
+		},
+		{
+			sans: []string{"reviews.default.svc.cluster.local"},
+		},
+		{
+			sans: nil,
+		},
+	}
+	for _, c := range cases {
+		if got := ConstructTrustBundle(c.sans); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("ConstructTrustBundle(%v): expected %v, got %v", c.sans, c.expected, got)
+		}
+	}
+}
+
+func TestConstructCertificateRevocationListWithPeerTrustBundles(t *testing.T) {
+	defer SetTrustBundles(nil)
+	defer SetCertificateRevocationList(nil)
+
+	if _, err := SetCertificateRevocationList(testCRL(t, time.Now().Add(time.Hour))); err != nil {
+		t.Fatalf("SetCertificateRevocationList: %v", err)
+	}
+	local := []string{"spiffe://cluster.local/ns/default/sa/reviews"}
+	SetTrustBundles(map[string][]byte{"cluster.local": []byte("local-root")})
+	for _, sans := range [][]string{nil, local, {"spiffe://east.example.com/ns/default/sa/reviews"}} {
+		if ConstructCertificateRevocationList(sans) == nil {
+			t.Errorf("ConstructCertificateRevocationList(%v) with the bundle of the mesh only: expected the CRL, got nil", sans)
+		}
+	}
+	if got := gaugeValue(t, certificateRevocationListLocalOnly); got != 0 {
+		t.Errorf("pilot_crl_local_only with the bundle of the mesh only: expected 0, got %v", got)
+	}
+
+	// The sidecars would reject the certificates of the peers, whose CAs have no CRL, so the CRL is only
+	// kept for the identities of the mesh.
+	SetTrustBundles(map[string][]byte{"cluster.local": []byte("local-root"), "east.example.com": []byte("east-root")})
+	if ConstructCertificateRevocationList(local) == nil {
+		t.Errorf("ConstructCertificateRevocationList(%v) with a peer trust bundle: expected the CRL, got nil", local)
+	}
+	for _, sans := range [][]string{
+		nil,
+		{"spiffe://east.example.com/ns/default/sa/reviews"},
+		{local[0], "spiffe://east.example.com/ns/default/sa/reviews"},
+		{"reviews.default.svc.cluster.local"},
+	} {
+		if got := ConstructCertificateRevocationList(sans); got != nil {
+			t.Errorf("ConstructCertificateRevocationList(%v) with a peer trust bundle: expected nil, got %v", sans, got)
+		}
+	}
+	if got := gaugeValue(t, certificateRevocationListLocalOnly); got != 1 {
+		t.Errorf("pilot_crl_local_only with a peer trust bundle: expected 1, got %v", got)
+	}
+}
+
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := gauge.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
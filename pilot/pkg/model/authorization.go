@@ -17,7 +17,10 @@ package model
 import (
 	"fmt"
 
+	"github.com/gogo/protobuf/proto"
+
 	rbacproto "istio.io/api/rbac/v1alpha1"
+	"istio.io/istio/pkg/annotations"
 	istiolog "istio.io/istio/pkg/log"
 )
 
@@ -25,10 +28,34 @@ const (
 	// DefaultRbacConfigName is the name of the mesh global RbacConfig name. Only RbacConfig with this
 	// name will be considered.
 	DefaultRbacConfigName = "default"
+
+	// AuthorizationDryRunAnnotation is the annotation that puts a ServiceRoleBinding or an
+	// AuthorizationPolicy in dry-run mode when set to "true". The bindings of a policy in dry-run
+	// mode are handled as if their mode was PERMISSIVE: they are evaluated as shadow rules whose
+	// decisions are reported by the proxy but never enforced.
+	AuthorizationDryRunAnnotation = "istio.io/dry-run"
+
+	// AuthorizationActionAnnotation is the annotation that sets the action of an AuthorizationPolicy,
+	// either AuthorizationActionAllow (the default) or AuthorizationActionDeny. The bindings of a
+	// DENY policy deny the matched requests, and are evaluated before the bindings of ALLOW policies.
+	AuthorizationActionAnnotation = "istio.io/authorization-action"
+
+	// AuthorizationActionAllow is the action of AuthorizationPolicies allowing the matched requests.
+	AuthorizationActionAllow = "ALLOW"
+
+	// AuthorizationActionDeny is the action of AuthorizationPolicies denying the matched requests.
+	AuthorizationActionDeny = "DENY"
 )
 
 var (
 	rbacLog = istiolog.RegisterScope("rbac", "rbac debugging", 0)
+
+	_ = annotations.Register(AuthorizationDryRunAnnotation,
+		"Evaluates the ServiceRoleBinding or AuthorizationPolicy in shadow mode when set to true, "+
+			"so that its decisions are logged but not enforced.")
+	_ = annotations.Register(AuthorizationActionAnnotation,
+		"The action of the AuthorizationPolicy, ALLOW or DENY. The bindings of DENY policies deny the "+
+			"matched requests, and are evaluated before the bindings of ALLOW policies. Defaults to ALLOW.")
 )
 
 // RolesAndBindings stores the the ServiceRole and ServiceRoleBinding in the same namespace.
@@ -44,6 +71,9 @@ type RolesAndBindings struct {
 type AuthorizationPolicyConfig struct {
 	Name   string
 	Policy *rbacproto.AuthorizationPolicy
+
+	// True if the bindings of the policy deny the matched requests, instead of allowing them.
+	Deny bool
 }
 
 // AuthorizationConfigV2 stores a list of AuthorizationPolicyConfig and ServiceRole in a given namespace.
@@ -124,14 +154,27 @@ func (policy *AuthorizationPolicies) addServiceRoleBinding(binding *Config) {
 	if rolesAndBindings.RoleNameToBindings[name] == nil {
 		rolesAndBindings.RoleNameToBindings[name] = []*rbacproto.ServiceRoleBinding{}
 	}
-	rolesAndBindings.RoleNameToBindings[name] = append(
-		rolesAndBindings.RoleNameToBindings[name], binding.Spec.(*rbacproto.ServiceRoleBinding))
+	spec := binding.Spec.(*rbacproto.ServiceRoleBinding)
+	if isDryRun(binding) {
+		spec = dryRunBinding(spec)
+	}
+	rolesAndBindings.RoleNameToBindings[name] = append(rolesAndBindings.RoleNameToBindings[name], spec)
 }
 
 func (policy *AuthorizationPolicies) addAuthorizationPolicy(authzPolicy *Config) {
 	if authzPolicy == nil || authzPolicy.Spec.(*rbacproto.AuthorizationPolicy) == nil {
 		return
 	}
+	var deny bool
+	switch action := authzPolicy.Annotations[AuthorizationActionAnnotation]; action {
+	case "", AuthorizationActionAllow:
+	case AuthorizationActionDeny:
+		deny = true
+	default:
+		rbacLog.Errorf("ignored AuthorizationPolicy %s in %s with invalid action %q",
+			authzPolicy.Name, authzPolicy.Namespace, action)
+		return
+	}
 
 	// Initialize AuthzPolicies for authz v2.
 	if policy.NamespaceToAuthorizationConfigV2 == nil {
@@ -143,13 +186,39 @@ func (policy *AuthorizationPolicies) addAuthorizationPolicy(authzPolicy *Config)
 			NameToServiceRoles: map[string]*rbacproto.ServiceRole{},
 		}
 	}
+	spec := authzPolicy.Spec.(*rbacproto.AuthorizationPolicy)
+	if isDryRun(authzPolicy) {
+		spec = proto.Clone(spec).(*rbacproto.AuthorizationPolicy)
+		for _, binding := range spec.Allow {
+			if binding != nil {
+				binding.Mode = rbacproto.EnforcementMode_PERMISSIVE
+			}
+		}
+	}
 	authzV2 := policy.NamespaceToAuthorizationConfigV2[authzPolicy.Namespace]
 	authzV2.AuthzPolicies = append(authzV2.AuthzPolicies, &AuthorizationPolicyConfig{
 		Name:   authzPolicy.Name,
-		Policy: authzPolicy.Spec.(*rbacproto.AuthorizationPolicy),
+		Policy: spec,
+		Deny:   deny,
 	})
 }
 
+// isDryRun returns true if the config has the dry-run annotation set to true.
+func isDryRun(cfg *Config) bool {
+	return cfg.Annotations[AuthorizationDryRunAnnotation] == "true"
+}
+
+// dryRunBinding returns a copy of the binding in PERMISSIVE mode. The binding is copied so that
+// the config cached in the store is left unchanged.
+func dryRunBinding(binding *rbacproto.ServiceRoleBinding) *rbacproto.ServiceRoleBinding {
+	if binding == nil {
+		return nil
+	}
+	out := proto.Clone(binding).(*rbacproto.ServiceRoleBinding)
+	out.Mode = rbacproto.EnforcementMode_PERMISSIVE
+	return out
+}
+
 // AddConfig adds a config of type ServiceRole, ServiceRoleBinding or AuthorizationPolicy to
 // AuthorizationPolicies.
 func (policy *AuthorizationPolicies) AddConfig(cfgs ...*Config) {
@@ -51,6 +51,37 @@ func TestAddConfig(t *testing.T) {
 		},
 	}
 
+	dryRunBindingCfg := model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type: model.ServiceRoleBinding.Type, Name: "test-binding-1", Namespace: model.NamespaceAll,
+			Annotations: map[string]string{model.AuthorizationDryRunAnnotation: "true"}},
+		Spec: bindingCfg.Spec,
+	}
+	dryRunAuthzCfg := model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type: model.AuthorizationPolicy.Type, Name: "test-authz-1", Namespace: model.NamespaceAll,
+			Annotations: map[string]string{model.AuthorizationDryRunAnnotation: "true"}},
+		Spec: &rbacproto.AuthorizationPolicy{
+			Allow: []*rbacproto.ServiceRoleBinding{{
+				Subjects: []*rbacproto.Subject{{User: "test-user-1"}},
+				RoleRef:  &rbacproto.RoleRef{Kind: "ServiceRole", Name: "test-role-1"},
+			}},
+		},
+	}
+
+	denyAuthzCfg := model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type: model.AuthorizationPolicy.Type, Name: "test-authz-1", Namespace: model.NamespaceAll,
+			Annotations: map[string]string{model.AuthorizationActionAnnotation: model.AuthorizationActionDeny}},
+		Spec: authzCfg.Spec,
+	}
+	invalidActionAuthzCfg := model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type: model.AuthorizationPolicy.Type, Name: "test-authz-1", Namespace: model.NamespaceAll,
+			Annotations: map[string]string{model.AuthorizationActionAnnotation: "deny"}},
+		Spec: authzCfg.Spec,
+	}
+
 	invalidateBindingCfg := model.Config{
 		ConfigMeta: model.ConfigMeta{
 			Type: model.ServiceRoleBinding.Type, Name: "test-binding-1", Namespace: model.NamespaceAll},
@@ -95,6 +126,61 @@ func TestAddConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:          "test add config for ServiceRoleBinding in dry-run mode",
+			config:        []model.Config{dryRunBindingCfg},
+			authzPolicies: &model.AuthorizationPolicies{},
+			expectedRolesAndBindings: &model.RolesAndBindings{
+				Roles: []model.Config{},
+				RoleNameToBindings: map[string][]*rbacproto.ServiceRoleBinding{
+					"test-role-1": {&rbacproto.ServiceRoleBinding{
+						Subjects: []*rbacproto.Subject{{User: "test-user-1"}},
+						RoleRef:  &rbacproto.RoleRef{Kind: "ServiceRole", Name: "test-role-1"},
+						Mode:     rbacproto.EnforcementMode_PERMISSIVE,
+					}},
+				},
+			},
+		},
+		{
+			name:          "test add config for AuthorizationPolicy in dry-run mode",
+			config:        []model.Config{dryRunAuthzCfg},
+			authzPolicies: &model.AuthorizationPolicies{},
+			expectedAuthorizationConfigV2: &model.AuthorizationConfigV2{
+				AuthzPolicies: []*model.AuthorizationPolicyConfig{
+					{
+						Name: "test-authz-1", Policy: &rbacproto.AuthorizationPolicy{
+							Allow: []*rbacproto.ServiceRoleBinding{{
+								Subjects: []*rbacproto.Subject{{User: "test-user-1"}},
+								RoleRef:  &rbacproto.RoleRef{Kind: "ServiceRole", Name: "test-role-1"},
+								Mode:     rbacproto.EnforcementMode_PERMISSIVE,
+							}},
+						}},
+				},
+				NameToServiceRoles: map[string]*rbacproto.ServiceRole{},
+			},
+		},
+		{
+			name:          "test add config for AuthorizationPolicy with DENY action",
+			config:        []model.Config{denyAuthzCfg},
+			authzPolicies: &model.AuthorizationPolicies{},
+			expectedAuthorizationConfigV2: &model.AuthorizationConfigV2{
+				AuthzPolicies: []*model.AuthorizationPolicyConfig{
+					{
+						Name: "test-authz-1", Policy: &rbacproto.AuthorizationPolicy{
+							WorkloadSelector: &rbacproto.WorkloadSelector{
+								Labels: map[string]string{"app": "test"},
+							},
+						},
+						Deny: true},
+				},
+				NameToServiceRoles: map[string]*rbacproto.ServiceRole{},
+			},
+		},
+		{
+			name:          "test add config for AuthorizationPolicy with invalid action",
+			config:        []model.Config{invalidActionAuthzCfg},
+			authzPolicies: &model.AuthorizationPolicies{},
+		},
 		{
 			name:          "test add config for AuthorizationPolicy",
 			config:        []model.Config{authzCfg, roleCfg},
@@ -145,6 +231,21 @@ func TestAddConfig(t *testing.T) {
 			}
 		})
 	}
+
+	// AuthorizationPolicies with an invalid action are ignored.
+	authzPolicies := &model.AuthorizationPolicies{}
+	authzPolicies.AddConfig(&invalidActionAuthzCfg)
+	if len(authzPolicies.NamespaceToAuthorizationConfigV2) != 0 {
+		t.Errorf("AuthorizationPolicy with invalid action added: %v", authzPolicies.NamespaceToAuthorizationConfigV2)
+	}
+
+	// The dry-run mode must not modify the configs in the store.
+	if mode := bindingCfg.Spec.(*rbacproto.ServiceRoleBinding).Mode; mode != rbacproto.EnforcementMode_ENFORCED {
+		t.Errorf("ServiceRoleBinding mode changed to %v", mode)
+	}
+	if mode := dryRunAuthzCfg.Spec.(*rbacproto.AuthorizationPolicy).Allow[0].Mode; mode != rbacproto.EnforcementMode_ENFORCED {
+		t.Errorf("AuthorizationPolicy binding mode changed to %v", mode)
+	}
 }
 
 func TestRolesForNamespace(t *testing.T) {
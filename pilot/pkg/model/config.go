@@ -406,6 +406,17 @@ var (
 		Collection:  metadata.IstioNetworkingV1alpha3Sidecars.Collection.String(),
 	}
 
+	// LocalRateLimit describes the rate limits enforced locally by proxies
+	LocalRateLimit = ProtoSchema{
+		Type:        "local-rate-limit",
+		Plural:      "local-rate-limits",
+		Group:       "networking",
+		Version:     "v1alpha3",
+		MessageName: "istio.networking.ratelimit.LocalRateLimit",
+		Validate:    ValidateLocalRateLimit,
+		Collection:  metadata.IstioNetworkingV1alpha3Localratelimits.Collection.String(),
+	}
+
 	// HTTPAPISpec describes an HTTP API specification.
 	HTTPAPISpec = ProtoSchema{
 		Type:        "http-api-spec",
@@ -543,6 +554,7 @@ var (
 		DestinationRule,
 		EnvoyFilter,
 		Sidecar,
+		LocalRateLimit,
 		HTTPAPISpec,
 		HTTPAPISpecBinding,
 		QuotaSpec,
@@ -0,0 +1,1814 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: pilot/pkg/model/ratelimit/ratelimit.proto
+
+package ratelimit
+
+/*
+	`LocalRateLimit` configures token bucket based rate limiting enforced by
+	each proxy on its own, without a round trip to Mixer. Every proxy has its
+	own buckets, so the effective limit of a service is the configured limit
+	multiplied by the number of its replicas. This makes local rate limiting
+	suitable for coarse protection of workloads against overload; use Mixer
+	quotas for limits that must be enforced globally.
+
+	For sidecars, the limits apply to the inbound HTTP traffic of the
+	workload. For gateways, they apply to all HTTP traffic received by the
+	gateway.
+
+	The following example limits each `reviews` workload to 100 requests per
+	second, with bursts of up to 200 requests, and limits requests carrying
+	the `x-user-tier: free` header to 10 requests per second. Requests routed
+	by the `reviews-canary` VirtualService are limited to 20 requests per
+	second.
+
+	```yaml
+	apiVersion: networking.istio.io/v1alpha3
+	kind: LocalRateLimit
+	metadata:
+	  name: reviews
+	spec:
+	  workloadLabels:
+	    app: reviews
+	  tokenBucket:
+	    maxTokens: 200
+	    tokensPerFill: 100
+	    fillInterval: 1s
+	  descriptors:
+	  - header: x-user-tier
+	    value: free
+	    tokenBucket:
+	      maxTokens: 10
+	      tokensPerFill: 10
+	      fillInterval: 1s
+	  routes:
+	  - virtualService: reviews-canary
+	    tokenBucket:
+	      maxTokens: 20
+	      tokensPerFill: 20
+	      fillInterval: 1s
+	```
+*/
+
+import proto "github.com/gogo/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import types "github.com/gogo/protobuf/types"
+
+import strings "strings"
+import reflect "reflect"
+import github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
+
+import io "io"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
+
+// LocalRateLimit describes the local rate limits of a set of workloads.
+type LocalRateLimit struct {
+	// One or more labels that indicate a specific set of pods/VMs whose
+	// proxies should be configured to enforce the rate limits. The scope of
+	// label search is restricted to the configuration namespace in which the
+	// resource is present. If omitted, the rate limits apply to all workloads
+	// in the namespace.
+	WorkloadLabels map[string]string `protobuf:"bytes,1,rep,name=workload_labels,json=workloadLabels,proto3" json:"workload_labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// REQUIRED. The token bucket limiting all the requests handled by the
+	// workload, unless overridden by one of the `routes`.
+	TokenBucket *TokenBucket `protobuf:"bytes,2,opt,name=token_bucket,json=tokenBucket,proto3" json:"token_bucket,omitempty"`
+	// Additional token buckets for the requests matching the descriptors.
+	// A request matching a descriptor consumes tokens from both the
+	// descriptor bucket and the default bucket.
+	Descriptors []*Descriptor `protobuf:"bytes,3,rep,name=descriptors,proto3" json:"descriptors,omitempty"`
+	// Rate limits overriding the default ones for specific routes.
+	Routes []*RouteRateLimit `protobuf:"bytes,4,rep,name=routes,proto3" json:"routes,omitempty"`
+}
+
+func (m *LocalRateLimit) Reset()      { *m = LocalRateLimit{} }
+func (*LocalRateLimit) ProtoMessage() {}
+func (*LocalRateLimit) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ratelimit_96a3f992b1611cdf, []int{0}
+}
+func (m *LocalRateLimit) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LocalRateLimit) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LocalRateLimit.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *LocalRateLimit) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LocalRateLimit.Merge(dst, src)
+}
+func (m *LocalRateLimit) XXX_Size() int {
+	return m.Size()
+}
+func (m *LocalRateLimit) XXX_DiscardUnknown() {
+	xxx_messageInfo_LocalRateLimit.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LocalRateLimit proto.InternalMessageInfo
+
+func (m *LocalRateLimit) GetWorkloadLabels() map[string]string {
+	if m != nil {
+		return m.WorkloadLabels
+	}
+	return nil
+}
+
+func (m *LocalRateLimit) GetTokenBucket() *TokenBucket {
+	if m != nil {
+		return m.TokenBucket
+	}
+	return nil
+}
+
+func (m *LocalRateLimit) GetDescriptors() []*Descriptor {
+	if m != nil {
+		return m.Descriptors
+	}
+	return nil
+}
+
+func (m *LocalRateLimit) GetRoutes() []*RouteRateLimit {
+	if m != nil {
+		return m.Routes
+	}
+	return nil
+}
+
+// TokenBucket configures a token bucket. Each request consumes a token, and
+// requests arriving while the bucket is empty are rejected with a 429
+// status code.
+type TokenBucket struct {
+	// REQUIRED. The maximum number of tokens in the bucket, which is also the
+	// initial number of tokens. It bounds the size of request bursts.
+	MaxTokens uint32 `protobuf:"varint,1,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	// The number of tokens added to the bucket on each fill. Defaults to 1.
+	TokensPerFill uint32 `protobuf:"varint,2,opt,name=tokens_per_fill,json=tokensPerFill,proto3" json:"tokens_per_fill,omitempty"`
+	// REQUIRED. The interval between fills. Must be at least 50ms.
+	FillInterval *types.Duration `protobuf:"bytes,3,opt,name=fill_interval,json=fillInterval,proto3" json:"fill_interval,omitempty"`
+}
+
+func (m *TokenBucket) Reset()      { *m = TokenBucket{} }
+func (*TokenBucket) ProtoMessage() {}
+func (*TokenBucket) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ratelimit_96a3f992b1611cdf, []int{1}
+}
+func (m *TokenBucket) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TokenBucket) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TokenBucket.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *TokenBucket) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenBucket.Merge(dst, src)
+}
+func (m *TokenBucket) XXX_Size() int {
+	return m.Size()
+}
+func (m *TokenBucket) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenBucket.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenBucket proto.InternalMessageInfo
+
+func (m *TokenBucket) GetMaxTokens() uint32 {
+	if m != nil {
+		return m.MaxTokens
+	}
+	return 0
+}
+
+func (m *TokenBucket) GetTokensPerFill() uint32 {
+	if m != nil {
+		return m.TokensPerFill
+	}
+	return 0
+}
+
+func (m *TokenBucket) GetFillInterval() *types.Duration {
+	if m != nil {
+		return m.FillInterval
+	}
+	return nil
+}
+
+// Descriptor selects the requests that consume tokens from a dedicated
+// bucket, based on the value of a request header.
+type Descriptor struct {
+	// REQUIRED. The name of the request header.
+	Header string `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	// REQUIRED. The value of the header, matched exactly.
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	// REQUIRED. The token bucket of the matching requests.
+	TokenBucket *TokenBucket `protobuf:"bytes,3,opt,name=token_bucket,json=tokenBucket,proto3" json:"token_bucket,omitempty"`
+}
+
+func (m *Descriptor) Reset()      { *m = Descriptor{} }
+func (*Descriptor) ProtoMessage() {}
+func (*Descriptor) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ratelimit_96a3f992b1611cdf, []int{2}
+}
+func (m *Descriptor) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Descriptor) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Descriptor.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *Descriptor) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Descriptor.Merge(dst, src)
+}
+func (m *Descriptor) XXX_Size() int {
+	return m.Size()
+}
+func (m *Descriptor) XXX_DiscardUnknown() {
+	xxx_messageInfo_Descriptor.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Descriptor proto.InternalMessageInfo
+
+func (m *Descriptor) GetHeader() string {
+	if m != nil {
+		return m.Header
+	}
+	return ""
+}
+
+func (m *Descriptor) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *Descriptor) GetTokenBucket() *TokenBucket {
+	if m != nil {
+		return m.TokenBucket
+	}
+	return nil
+}
+
+// RouteRateLimit overrides the rate limits of a set of routes. Exactly one of
+// `virtual_service` or `port` must be set.
+type RouteRateLimit struct {
+	// The name of a VirtualService, in the namespace of the LocalRateLimit,
+	// whose routes are rate limited. Applies to gateways.
+	VirtualService string `protobuf:"bytes,1,opt,name=virtual_service,json=virtualService,proto3" json:"virtual_service,omitempty"`
+	// The inbound service port whose route is rate limited. Applies to
+	// sidecars.
+	Port uint32 `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	// REQUIRED. The token bucket limiting the requests of the routes.
+	TokenBucket *TokenBucket `protobuf:"bytes,3,opt,name=token_bucket,json=tokenBucket,proto3" json:"token_bucket,omitempty"`
+	// Additional token buckets for the requests of the routes matching the
+	// descriptors. Replaces the default descriptors.
+	Descriptors []*Descriptor `protobuf:"bytes,4,rep,name=descriptors,proto3" json:"descriptors,omitempty"`
+}
+
+func (m *RouteRateLimit) Reset()      { *m = RouteRateLimit{} }
+func (*RouteRateLimit) ProtoMessage() {}
+func (*RouteRateLimit) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ratelimit_96a3f992b1611cdf, []int{3}
+}
+func (m *RouteRateLimit) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RouteRateLimit) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RouteRateLimit.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *RouteRateLimit) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RouteRateLimit.Merge(dst, src)
+}
+func (m *RouteRateLimit) XXX_Size() int {
+	return m.Size()
+}
+func (m *RouteRateLimit) XXX_DiscardUnknown() {
+	xxx_messageInfo_RouteRateLimit.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RouteRateLimit proto.InternalMessageInfo
+
+func (m *RouteRateLimit) GetVirtualService() string {
+	if m != nil {
+		return m.VirtualService
+	}
+	return ""
+}
+
+func (m *RouteRateLimit) GetPort() uint32 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
+}
+
+func (m *RouteRateLimit) GetTokenBucket() *TokenBucket {
+	if m != nil {
+		return m.TokenBucket
+	}
+	return nil
+}
+
+func (m *RouteRateLimit) GetDescriptors() []*Descriptor {
+	if m != nil {
+		return m.Descriptors
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*LocalRateLimit)(nil), "istio.networking.ratelimit.LocalRateLimit")
+	proto.RegisterMapType((map[string]string)(nil), "istio.networking.ratelimit.LocalRateLimit.WorkloadLabelsEntry")
+	proto.RegisterType((*TokenBucket)(nil), "istio.networking.ratelimit.TokenBucket")
+	proto.RegisterType((*Descriptor)(nil), "istio.networking.ratelimit.Descriptor")
+	proto.RegisterType((*RouteRateLimit)(nil), "istio.networking.ratelimit.RouteRateLimit")
+}
+func (this *LocalRateLimit) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LocalRateLimit)
+	if !ok {
+		that2, ok := that.(LocalRateLimit)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.WorkloadLabels) != len(that1.WorkloadLabels) {
+		return false
+	}
+	for i := range this.WorkloadLabels {
+		if this.WorkloadLabels[i] != that1.WorkloadLabels[i] {
+			return false
+		}
+	}
+	if !this.TokenBucket.Equal(that1.TokenBucket) {
+		return false
+	}
+	if len(this.Descriptors) != len(that1.Descriptors) {
+		return false
+	}
+	for i := range this.Descriptors {
+		if !this.Descriptors[i].Equal(that1.Descriptors[i]) {
+			return false
+		}
+	}
+	if len(this.Routes) != len(that1.Routes) {
+		return false
+	}
+	for i := range this.Routes {
+		if !this.Routes[i].Equal(that1.Routes[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *TokenBucket) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*TokenBucket)
+	if !ok {
+		that2, ok := that.(TokenBucket)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.MaxTokens != that1.MaxTokens {
+		return false
+	}
+	if this.TokensPerFill != that1.TokensPerFill {
+		return false
+	}
+	if !this.FillInterval.Equal(that1.FillInterval) {
+		return false
+	}
+	return true
+}
+func (this *Descriptor) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Descriptor)
+	if !ok {
+		that2, ok := that.(Descriptor)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Header != that1.Header {
+		return false
+	}
+	if this.Value != that1.Value {
+		return false
+	}
+	if !this.TokenBucket.Equal(that1.TokenBucket) {
+		return false
+	}
+	return true
+}
+func (this *RouteRateLimit) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*RouteRateLimit)
+	if !ok {
+		that2, ok := that.(RouteRateLimit)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.VirtualService != that1.VirtualService {
+		return false
+	}
+	if this.Port != that1.Port {
+		return false
+	}
+	if !this.TokenBucket.Equal(that1.TokenBucket) {
+		return false
+	}
+	if len(this.Descriptors) != len(that1.Descriptors) {
+		return false
+	}
+	for i := range this.Descriptors {
+		if !this.Descriptors[i].Equal(that1.Descriptors[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *LocalRateLimit) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&ratelimit.LocalRateLimit{")
+	keysForWorkloadLabels := make([]string, 0, len(this.WorkloadLabels))
+	for k, _ := range this.WorkloadLabels {
+		keysForWorkloadLabels = append(keysForWorkloadLabels, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForWorkloadLabels)
+	mapStringForWorkloadLabels := "map[string]string{"
+	for _, k := range keysForWorkloadLabels {
+		mapStringForWorkloadLabels += fmt.Sprintf("%#v: %#v,", k, this.WorkloadLabels[k])
+	}
+	mapStringForWorkloadLabels += "}"
+	if this.WorkloadLabels != nil {
+		s = append(s, "WorkloadLabels: "+mapStringForWorkloadLabels+",\n")
+	}
+	if this.TokenBucket != nil {
+		s = append(s, "TokenBucket: "+fmt.Sprintf("%#v", this.TokenBucket)+",\n")
+	}
+	if this.Descriptors != nil {
+		s = append(s, "Descriptors: "+fmt.Sprintf("%#v", this.Descriptors)+",\n")
+	}
+	if this.Routes != nil {
+		s = append(s, "Routes: "+fmt.Sprintf("%#v", this.Routes)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *TokenBucket) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&ratelimit.TokenBucket{")
+	s = append(s, "MaxTokens: "+fmt.Sprintf("%#v", this.MaxTokens)+",\n")
+	s = append(s, "TokensPerFill: "+fmt.Sprintf("%#v", this.TokensPerFill)+",\n")
+	if this.FillInterval != nil {
+		s = append(s, "FillInterval: "+fmt.Sprintf("%#v", this.FillInterval)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Descriptor) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&ratelimit.Descriptor{")
+	s = append(s, "Header: "+fmt.Sprintf("%#v", this.Header)+",\n")
+	s = append(s, "Value: "+fmt.Sprintf("%#v", this.Value)+",\n")
+	if this.TokenBucket != nil {
+		s = append(s, "TokenBucket: "+fmt.Sprintf("%#v", this.TokenBucket)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *RouteRateLimit) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&ratelimit.RouteRateLimit{")
+	s = append(s, "VirtualService: "+fmt.Sprintf("%#v", this.VirtualService)+",\n")
+	s = append(s, "Port: "+fmt.Sprintf("%#v", this.Port)+",\n")
+	if this.TokenBucket != nil {
+		s = append(s, "TokenBucket: "+fmt.Sprintf("%#v", this.TokenBucket)+",\n")
+	}
+	if this.Descriptors != nil {
+		s = append(s, "Descriptors: "+fmt.Sprintf("%#v", this.Descriptors)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringRatelimit(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+func (m *LocalRateLimit) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LocalRateLimit) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.WorkloadLabels) > 0 {
+		for k, _ := range m.WorkloadLabels {
+			dAtA[i] = 0xa
+			i++
+			v := m.WorkloadLabels[k]
+			mapSize := 1 + len(k) + sovRatelimit(uint64(len(k))) + 1 + len(v) + sovRatelimit(uint64(len(v)))
+			i = encodeVarintRatelimit(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintRatelimit(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintRatelimit(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if m.TokenBucket != nil {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(m.TokenBucket.Size()))
+		n1, err := m.TokenBucket.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n1
+	}
+	if len(m.Descriptors) > 0 {
+		for _, msg := range m.Descriptors {
+			dAtA[i] = 0x1a
+			i++
+			i = encodeVarintRatelimit(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.Routes) > 0 {
+		for _, msg := range m.Routes {
+			dAtA[i] = 0x22
+			i++
+			i = encodeVarintRatelimit(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *TokenBucket) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TokenBucket) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.MaxTokens != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(m.MaxTokens))
+	}
+	if m.TokensPerFill != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(m.TokensPerFill))
+	}
+	if m.FillInterval != nil {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(m.FillInterval.Size()))
+		n2, err := m.FillInterval.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n2
+	}
+	return i, nil
+}
+
+func (m *Descriptor) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Descriptor) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Header) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(len(m.Header)))
+		i += copy(dAtA[i:], m.Header)
+	}
+	if len(m.Value) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(len(m.Value)))
+		i += copy(dAtA[i:], m.Value)
+	}
+	if m.TokenBucket != nil {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(m.TokenBucket.Size()))
+		n3, err := m.TokenBucket.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n3
+	}
+	return i, nil
+}
+
+func (m *RouteRateLimit) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RouteRateLimit) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.VirtualService) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(len(m.VirtualService)))
+		i += copy(dAtA[i:], m.VirtualService)
+	}
+	if m.Port != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(m.Port))
+	}
+	if m.TokenBucket != nil {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintRatelimit(dAtA, i, uint64(m.TokenBucket.Size()))
+		n4, err := m.TokenBucket.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n4
+	}
+	if len(m.Descriptors) > 0 {
+		for _, msg := range m.Descriptors {
+			dAtA[i] = 0x22
+			i++
+			i = encodeVarintRatelimit(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func encodeVarintRatelimit(dAtA []byte, offset int, v uint64) int {
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return offset + 1
+}
+func (m *LocalRateLimit) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.WorkloadLabels) > 0 {
+		for k, v := range m.WorkloadLabels {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovRatelimit(uint64(len(k))) + 1 + len(v) + sovRatelimit(uint64(len(v)))
+			n += mapEntrySize + 1 + sovRatelimit(uint64(mapEntrySize))
+		}
+	}
+	if m.TokenBucket != nil {
+		l = m.TokenBucket.Size()
+		n += 1 + l + sovRatelimit(uint64(l))
+	}
+	if len(m.Descriptors) > 0 {
+		for _, e := range m.Descriptors {
+			l = e.Size()
+			n += 1 + l + sovRatelimit(uint64(l))
+		}
+	}
+	if len(m.Routes) > 0 {
+		for _, e := range m.Routes {
+			l = e.Size()
+			n += 1 + l + sovRatelimit(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TokenBucket) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MaxTokens != 0 {
+		n += 1 + sovRatelimit(uint64(m.MaxTokens))
+	}
+	if m.TokensPerFill != 0 {
+		n += 1 + sovRatelimit(uint64(m.TokensPerFill))
+	}
+	if m.FillInterval != nil {
+		l = m.FillInterval.Size()
+		n += 1 + l + sovRatelimit(uint64(l))
+	}
+	return n
+}
+
+func (m *Descriptor) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Header)
+	if l > 0 {
+		n += 1 + l + sovRatelimit(uint64(l))
+	}
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovRatelimit(uint64(l))
+	}
+	if m.TokenBucket != nil {
+		l = m.TokenBucket.Size()
+		n += 1 + l + sovRatelimit(uint64(l))
+	}
+	return n
+}
+
+func (m *RouteRateLimit) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.VirtualService)
+	if l > 0 {
+		n += 1 + l + sovRatelimit(uint64(l))
+	}
+	if m.Port != 0 {
+		n += 1 + sovRatelimit(uint64(m.Port))
+	}
+	if m.TokenBucket != nil {
+		l = m.TokenBucket.Size()
+		n += 1 + l + sovRatelimit(uint64(l))
+	}
+	if len(m.Descriptors) > 0 {
+		for _, e := range m.Descriptors {
+			l = e.Size()
+			n += 1 + l + sovRatelimit(uint64(l))
+		}
+	}
+	return n
+}
+
+func sovRatelimit(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+func sozRatelimit(x uint64) (n int) {
+	return sovRatelimit(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *LocalRateLimit) String() string {
+	if this == nil {
+		return "nil"
+	}
+	keysForWorkloadLabels := make([]string, 0, len(this.WorkloadLabels))
+	for k, _ := range this.WorkloadLabels {
+		keysForWorkloadLabels = append(keysForWorkloadLabels, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForWorkloadLabels)
+	mapStringForWorkloadLabels := "map[string]string{"
+	for _, k := range keysForWorkloadLabels {
+		mapStringForWorkloadLabels += fmt.Sprintf("%v: %v,", k, this.WorkloadLabels[k])
+	}
+	mapStringForWorkloadLabels += "}"
+	s := strings.Join([]string{`&LocalRateLimit{`,
+		`WorkloadLabels:` + mapStringForWorkloadLabels + `,`,
+		`TokenBucket:` + strings.Replace(fmt.Sprintf("%v", this.TokenBucket), "TokenBucket", "TokenBucket", 1) + `,`,
+		`Descriptors:` + strings.Replace(fmt.Sprintf("%v", this.Descriptors), "Descriptor", "Descriptor", 1) + `,`,
+		`Routes:` + strings.Replace(fmt.Sprintf("%v", this.Routes), "RouteRateLimit", "RouteRateLimit", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *TokenBucket) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&TokenBucket{`,
+		`MaxTokens:` + fmt.Sprintf("%v", this.MaxTokens) + `,`,
+		`TokensPerFill:` + fmt.Sprintf("%v", this.TokensPerFill) + `,`,
+		`FillInterval:` + strings.Replace(fmt.Sprintf("%v", this.FillInterval), "Duration", "types.Duration", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Descriptor) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Descriptor{`,
+		`Header:` + fmt.Sprintf("%v", this.Header) + `,`,
+		`Value:` + fmt.Sprintf("%v", this.Value) + `,`,
+		`TokenBucket:` + strings.Replace(fmt.Sprintf("%v", this.TokenBucket), "TokenBucket", "TokenBucket", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *RouteRateLimit) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&RouteRateLimit{`,
+		`VirtualService:` + fmt.Sprintf("%v", this.VirtualService) + `,`,
+		`Port:` + fmt.Sprintf("%v", this.Port) + `,`,
+		`TokenBucket:` + strings.Replace(fmt.Sprintf("%v", this.TokenBucket), "TokenBucket", "TokenBucket", 1) + `,`,
+		`Descriptors:` + strings.Replace(fmt.Sprintf("%v", this.Descriptors), "Descriptor", "Descriptor", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringRatelimit(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *LocalRateLimit) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRatelimit
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LocalRateLimit: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LocalRateLimit: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WorkloadLabels", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.WorkloadLabels == nil {
+				m.WorkloadLabels = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRatelimit
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRatelimit
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthRatelimit
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRatelimit
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthRatelimit
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipRatelimit(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthRatelimit
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.WorkloadLabels[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TokenBucket", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TokenBucket == nil {
+				m.TokenBucket = &TokenBucket{}
+			}
+			if err := m.TokenBucket.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Descriptors", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Descriptors = append(m.Descriptors, &Descriptor{})
+			if err := m.Descriptors[len(m.Descriptors)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Routes", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Routes = append(m.Routes, &RouteRateLimit{})
+			if err := m.Routes[len(m.Routes)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRatelimit(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TokenBucket) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRatelimit
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TokenBucket: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TokenBucket: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxTokens", wireType)
+			}
+			m.MaxTokens = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxTokens |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TokensPerFill", wireType)
+			}
+			m.TokensPerFill = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TokensPerFill |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FillInterval", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.FillInterval == nil {
+				m.FillInterval = &types.Duration{}
+			}
+			if err := m.FillInterval.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRatelimit(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Descriptor) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRatelimit
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Descriptor: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Descriptor: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Header", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Header = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TokenBucket", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TokenBucket == nil {
+				m.TokenBucket = &TokenBucket{}
+			}
+			if err := m.TokenBucket.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRatelimit(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RouteRateLimit) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRatelimit
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RouteRateLimit: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RouteRateLimit: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VirtualService", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.VirtualService = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Port", wireType)
+			}
+			m.Port = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Port |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TokenBucket", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TokenBucket == nil {
+				m.TokenBucket = &TokenBucket{}
+			}
+			if err := m.TokenBucket.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Descriptors", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Descriptors = append(m.Descriptors, &Descriptor{})
+			if err := m.Descriptors[len(m.Descriptors)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRatelimit(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRatelimit
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipRatelimit(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowRatelimit
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+			return iNdEx, nil
+		case 1:
+			iNdEx += 8
+			return iNdEx, nil
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowRatelimit
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			iNdEx += length
+			if length < 0 {
+				return 0, ErrInvalidLengthRatelimit
+			}
+			return iNdEx, nil
+		case 3:
+			for {
+				var innerWire uint64
+				var start int = iNdEx
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return 0, ErrIntOverflowRatelimit
+					}
+					if iNdEx >= l {
+						return 0, io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					innerWire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				innerWireType := int(innerWire & 0x7)
+				if innerWireType == 4 {
+					break
+				}
+				next, err := skipRatelimit(dAtA[start:])
+				if err != nil {
+					return 0, err
+				}
+				iNdEx = start + next
+			}
+			return iNdEx, nil
+		case 4:
+			return iNdEx, nil
+		case 5:
+			iNdEx += 4
+			return iNdEx, nil
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+	}
+	panic("unreachable")
+}
+
+var (
+	ErrInvalidLengthRatelimit = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowRatelimit   = fmt.Errorf("proto: integer overflow")
+)
+
+func init() {
+	proto.RegisterFile("pilot/pkg/model/ratelimit/ratelimit.proto", fileDescriptor_ratelimit_96a3f992b1611cdf)
+}
+
+var fileDescriptor_ratelimit_96a3f992b1611cdf = []byte{
+	// 520 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x52, 0xb1, 0x6e, 0x13, 0x41,
+	0x10, 0xbd, 0xcd, 0x19, 0x4b, 0x1e, 0xc7, 0x36, 0x5a, 0x10, 0x3a, 0x2c, 0xb1, 0xb2, 0x5c, 0x24,
+	0x86, 0xe2, 0x2c, 0x85, 0x06, 0x51, 0x04, 0x61, 0x05, 0x04, 0xc8, 0x05, 0x5a, 0x90, 0x90, 0x68,
+	0x4e, 0x6b, 0x7b, 0x63, 0x56, 0x5e, 0xdf, 0x9e, 0xf6, 0xf6, 0x9c, 0xa4, 0xa3, 0xa2, 0xa6, 0x40,
+	0x7c, 0x03, 0x9f, 0x42, 0xe9, 0x32, 0x25, 0x3e, 0x37, 0x94, 0xf9, 0x04, 0x74, 0x7b, 0x87, 0x1d,
+	0x43, 0x70, 0x11, 0xd1, 0xcd, 0xcc, 0xbe, 0x79, 0x3b, 0x6f, 0xe6, 0xc1, 0xfd, 0x48, 0x48, 0x65,
+	0xba, 0xd1, 0x64, 0xdc, 0x9d, 0xaa, 0x11, 0x97, 0x5d, 0xcd, 0x0c, 0x97, 0x62, 0x2a, 0xcc, 0x3a,
+	0xf2, 0x23, 0xad, 0x8c, 0xc2, 0x4d, 0x11, 0x1b, 0xa1, 0xfc, 0x90, 0x9b, 0x13, 0xa5, 0x27, 0x22,
+	0x1c, 0xfb, 0x2b, 0x44, 0x93, 0x8c, 0x95, 0x1a, 0x4b, 0xde, 0xb5, 0xc8, 0x41, 0x72, 0xdc, 0x1d,
+	0x25, 0x9a, 0x19, 0xa1, 0xc2, 0xbc, 0xb7, 0xfd, 0xd5, 0x85, 0x7a, 0x5f, 0x0d, 0x99, 0xa4, 0xcc,
+	0xf0, 0x7e, 0xd6, 0x82, 0xc7, 0xd0, 0xc8, 0x78, 0xa4, 0x62, 0xa3, 0x40, 0xb2, 0x01, 0x97, 0xb1,
+	0x87, 0x5a, 0x6e, 0xa7, 0x7a, 0x70, 0xe8, 0xff, 0xfb, 0x23, 0x7f, 0x93, 0xc4, 0x7f, 0x57, 0x30,
+	0xf4, 0x2d, 0xc1, 0xb3, 0xd0, 0xe8, 0x33, 0x5a, 0x3f, 0xd9, 0x28, 0xe2, 0x57, 0xb0, 0x6b, 0xd4,
+	0x84, 0x87, 0xc1, 0x20, 0x19, 0x4e, 0xb8, 0xf1, 0x76, 0x5a, 0xa8, 0x53, 0x3d, 0xd8, 0xdf, 0xf6,
+	0xcb, 0xdb, 0x0c, 0xdf, 0xb3, 0x70, 0x5a, 0x35, 0xeb, 0x04, 0xbf, 0x80, 0xea, 0x88, 0xc7, 0x43,
+	0x2d, 0x22, 0xa3, 0x74, 0xec, 0xb9, 0x76, 0xe0, 0xbd, 0x6d, 0x54, 0x47, 0x2b, 0x38, 0xbd, 0xdc,
+	0x8a, 0x7b, 0x50, 0xd6, 0x2a, 0x31, 0x3c, 0xf6, 0x4a, 0x96, 0xe4, 0xc1, 0x36, 0x12, 0x9a, 0x21,
+	0x57, 0xaa, 0x69, 0xd1, 0xd9, 0x7c, 0x0a, 0xb7, 0xae, 0x58, 0x00, 0xbe, 0x09, 0xee, 0x84, 0x9f,
+	0x79, 0xa8, 0x85, 0x3a, 0x15, 0x9a, 0x85, 0xf8, 0x36, 0xdc, 0x98, 0x31, 0x99, 0x70, 0xab, 0xbd,
+	0x42, 0xf3, 0xe4, 0xf1, 0xce, 0x23, 0xd4, 0xfe, 0x82, 0xa0, 0x7a, 0x49, 0x2d, 0xbe, 0x07, 0x30,
+	0x65, 0xa7, 0x81, 0xd5, 0x1c, 0x5b, 0x8a, 0x1a, 0xad, 0x4c, 0xd9, 0xa9, 0xc5, 0xc4, 0x78, 0x0f,
+	0x1a, 0xf9, 0x53, 0x10, 0x71, 0x1d, 0x1c, 0x0b, 0x29, 0x2d, 0x65, 0x8d, 0xd6, 0xf2, 0xf2, 0x6b,
+	0xae, 0x9f, 0x0b, 0x29, 0xf1, 0x21, 0xd4, 0xb2, 0xc7, 0x40, 0x84, 0x86, 0xeb, 0x19, 0x93, 0x9e,
+	0x6b, 0x97, 0x7e, 0xd7, 0xcf, 0x7d, 0xe2, 0xff, 0xf6, 0x89, 0x7f, 0x54, 0xf8, 0x84, 0xee, 0x66,
+	0xf8, 0x97, 0x05, 0xbc, 0xfd, 0x09, 0x01, 0xac, 0x37, 0x87, 0xef, 0x40, 0xf9, 0x03, 0x67, 0x23,
+	0xae, 0x0b, 0x51, 0x45, 0x76, 0xb5, 0xae, 0xbf, 0x0e, 0xee, 0x5e, 0xff, 0xe0, 0xed, 0x25, 0x82,
+	0xfa, 0xe6, 0xf6, 0xf1, 0x3e, 0x34, 0x66, 0x42, 0x9b, 0x84, 0xc9, 0x20, 0xe6, 0x7a, 0x26, 0x86,
+	0xbc, 0x98, 0xaa, 0x5e, 0x94, 0xdf, 0xe4, 0x55, 0x8c, 0xa1, 0x14, 0x29, 0x6d, 0x8a, 0x0d, 0xd9,
+	0xf8, 0x7f, 0xce, 0xf6, 0xa7, 0x19, 0x4b, 0xd7, 0x36, 0x63, 0xef, 0xc9, 0x7c, 0x41, 0x9c, 0xf3,
+	0x05, 0x71, 0x2e, 0x16, 0x04, 0x7d, 0x4c, 0x09, 0xfa, 0x96, 0x12, 0xf4, 0x3d, 0x25, 0x68, 0x9e,
+	0x12, 0xf4, 0x23, 0x25, 0xe8, 0x67, 0x4a, 0x9c, 0x8b, 0x94, 0xa0, 0xcf, 0x4b, 0xe2, 0xcc, 0x97,
+	0xc4, 0x39, 0x5f, 0x12, 0xe7, 0x7d, 0x65, 0x45, 0x3c, 0x28, 0xdb, 0x83, 0x3e, 0xfc, 0x35, 0x00,
+	0xae, 0xb9, 0x9c, 0xb3, 0x4f, 0x04, 0x00, 0x00,
+}
@@ -35,6 +35,7 @@ import (
 	mccpb "istio.io/api/mixer/v1/config/client"
 	networking "istio.io/api/networking/v1alpha3"
 	rbac "istio.io/api/rbac/v1alpha1"
+	"istio.io/istio/pilot/pkg/model/ratelimit"
 )
 
 const (
@@ -612,6 +613,82 @@ func ValidateEnvoyFilter(_, _ string, msg proto.Message) (errs error) {
 	return
 }
 
+// ValidateLocalRateLimit checks local rate limit specifications
+func ValidateLocalRateLimit(_, _ string, msg proto.Message) (errs error) {
+	rule, ok := msg.(*ratelimit.LocalRateLimit)
+	if !ok {
+		return fmt.Errorf("cannot cast to local rate limit")
+	}
+
+	errs = appendErrors(errs, Labels(rule.WorkloadLabels).Validate())
+	if rule.TokenBucket == nil {
+		errs = appendErrors(errs, fmt.Errorf("local rate limit: missing token bucket"))
+	} else {
+		errs = appendErrors(errs, validateTokenBucket(rule.TokenBucket))
+	}
+	errs = appendErrors(errs, validateRateLimitDescriptors(rule.Descriptors))
+
+	for _, route := range rule.Routes {
+		if route == nil {
+			errs = appendErrors(errs, fmt.Errorf("local rate limit: route may not be null"))
+			continue
+		}
+		if (route.VirtualService == "") == (route.Port == 0) {
+			errs = appendErrors(errs, fmt.Errorf("local rate limit: exactly one of virtualService or port must be set in a route"))
+		}
+		if route.Port != 0 {
+			errs = appendErrors(errs, ValidatePort(int(route.Port)))
+		}
+		if route.TokenBucket == nil {
+			errs = appendErrors(errs, fmt.Errorf("local rate limit: missing token bucket in route"))
+		} else {
+			errs = appendErrors(errs, validateTokenBucket(route.TokenBucket))
+		}
+		errs = appendErrors(errs, validateRateLimitDescriptors(route.Descriptors))
+	}
+
+	return
+}
+
+func validateRateLimitDescriptors(descriptors []*ratelimit.Descriptor) (errs error) {
+	seen := make(map[string]bool)
+	for _, descriptor := range descriptors {
+		if descriptor == nil {
+			errs = appendErrors(errs, fmt.Errorf("local rate limit: descriptor may not be null"))
+			continue
+		}
+		errs = appendErrors(errs, ValidateHTTPHeaderName(descriptor.Header))
+		if descriptor.Value == "" {
+			errs = appendErrors(errs, fmt.Errorf("local rate limit: missing value for descriptor header %q", descriptor.Header))
+		}
+		key := strings.ToLower(descriptor.Header) + "=" + descriptor.Value
+		if seen[key] {
+			errs = appendErrors(errs, fmt.Errorf("local rate limit: duplicate descriptor %s", key))
+		}
+		seen[key] = true
+		if descriptor.TokenBucket == nil {
+			errs = appendErrors(errs, fmt.Errorf("local rate limit: missing token bucket for descriptor %s", key))
+		} else {
+			errs = appendErrors(errs, validateTokenBucket(descriptor.TokenBucket))
+		}
+	}
+	return
+}
+
+func validateTokenBucket(bucket *ratelimit.TokenBucket) (errs error) {
+	if bucket.MaxTokens == 0 {
+		errs = appendErrors(errs, fmt.Errorf("token bucket: maxTokens must be greater than 0"))
+	}
+	if bucket.FillInterval == nil {
+		errs = appendErrors(errs, fmt.Errorf("token bucket: missing fillInterval"))
+	} else if err := ValidateDurationGogo(bucket.FillInterval); err != nil {
+		errs = appendErrors(errs, fmt.Errorf("token bucket: invalid fillInterval: %v", err))
+	} else if interval, _ := types.DurationFromProto(bucket.FillInterval); interval < 50*time.Millisecond {
+		errs = appendErrors(errs, fmt.Errorf("token bucket: fillInterval must be at least 50ms"))
+	}
+	return
+}
+
 // validates that hostname in ns/<hostname> is a valid hostname according to
 // API specs
 func validateSidecarOrGatewayHostnamePart(host string, isGateway bool) (errs error) {
@@ -1795,6 +1872,7 @@ func ValidateVirtualService(_, _ string, msg proto.Message) (errs error) {
 	}
 	for _, httpRoute := range virtualService.Http {
 		errs = appendErrors(errs, validateHTTPRoute(httpRoute))
+		errs = appendErrors(errs, validateJwtClaimMatches(httpRoute, appliesToMesh))
 	}
 	for _, tlsRoute := range virtualService.Tls {
 		errs = appendErrors(errs, validateTLSRoute(tlsRoute, virtualService))
@@ -1953,6 +2031,9 @@ func validateHTTPRoute(http *networking.HTTPRoute) (errs error) {
 	for _, match := range http.Match {
 		for name := range match.Headers {
 			errs = appendErrors(errs, ValidateHTTPHeaderName(name))
+			if claim, ok := JwtClaimFromMatchName(name); ok && claim == "" {
+				errs = appendErrors(errs, fmt.Errorf("JWT claim name cannot be empty in header match %q", name))
+			}
 		}
 
 		if match.Port != 0 {
@@ -1973,6 +2054,39 @@ func validateHTTPRoute(http *networking.HTTPRoute) (errs error) {
 	return
 }
 
+// validateJwtClaimMatches checks that JWT claim matches are only used in routes applied to gateways,
+// as the claims are not available to the router in sidecars.
+func validateJwtClaimMatches(http *networking.HTTPRoute, appliesToMesh bool) (errs error) {
+	for _, match := range http.Match {
+		hasClaim := false
+		for name := range match.Headers {
+			if _, ok := JwtClaimFromMatchName(name); ok {
+				hasClaim = true
+				break
+			}
+		}
+		if !hasClaim {
+			continue
+		}
+
+		matchesMesh := appliesToMesh
+		if len(match.Gateways) > 0 {
+			matchesMesh = false
+			for _, gateway := range match.Gateways {
+				if gateway == IstioMeshGateway {
+					matchesMesh = true
+					break
+				}
+			}
+		}
+		if matchesMesh {
+			errs = appendErrors(errs, fmt.Errorf("JWT claim matches (%s*) are not supported for the mesh gateway",
+				JwtClaimMatchPrefix))
+		}
+	}
+	return
+}
+
 func validateGatewayNames(gateways []string) (errs error) {
 	for _, gateway := range gateways {
 		parts := strings.SplitN(gateway, "/", 2)
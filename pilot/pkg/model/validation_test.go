@@ -30,6 +30,7 @@ import (
 	mccpb "istio.io/api/mixer/v1/config/client"
 	networking "istio.io/api/networking/v1alpha3"
 	rbac "istio.io/api/rbac/v1alpha1"
+	"istio.io/istio/pilot/pkg/model/ratelimit"
 	"istio.io/istio/pilot/pkg/model/test"
 )
 
@@ -2540,6 +2541,62 @@ func TestValidateVirtualService(t *testing.T) {
 				}},
 			}},
 		}, valid: false},
+		{name: "JWT claim match in gateway", in: &networking.VirtualService{
+			Hosts:    []string{"foo.bar"},
+			Gateways: []string{"ns1/gateway"},
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Headers: map[string]*networking.StringMatch{
+						"@request.auth.claims.tenant": {MatchType: &networking.StringMatch_Exact{Exact: "premium"}},
+					},
+				}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "foo.baz"},
+				}},
+			}},
+		}, valid: true},
+		{name: "JWT claim match in mesh", in: &networking.VirtualService{
+			Hosts: []string{"foo.bar"},
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Headers: map[string]*networking.StringMatch{
+						"@request.auth.claims.tenant": {MatchType: &networking.StringMatch_Exact{Exact: "premium"}},
+					},
+				}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "foo.baz"},
+				}},
+			}},
+		}, valid: false},
+		{name: "JWT claim match restricted to gateway", in: &networking.VirtualService{
+			Hosts:    []string{"foo.bar"},
+			Gateways: []string{"ns1/gateway", "mesh"},
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Headers: map[string]*networking.StringMatch{
+						"@request.auth.claims.tenant": {MatchType: &networking.StringMatch_Exact{Exact: "premium"}},
+					},
+					Gateways: []string{"ns1/gateway"},
+				}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "foo.baz"},
+				}},
+			}},
+		}, valid: true},
+		{name: "JWT claim match without claim", in: &networking.VirtualService{
+			Hosts:    []string{"foo.bar"},
+			Gateways: []string{"ns1/gateway"},
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Headers: map[string]*networking.StringMatch{
+						"@request.auth.claims.": {MatchType: &networking.StringMatch_Exact{Exact: "premium"}},
+					},
+				}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "foo.baz"},
+				}},
+			}},
+		}, valid: false},
 		{name: "FQDN for gateway", in: &networking.VirtualService{
 			Hosts:    []string{"foo.bar"},
 			Gateways: []string{"gateway.example.com"},
@@ -4609,6 +4666,86 @@ func TestValidateSidecar(t *testing.T) {
 	}
 }
 
+func TestValidateLocalRateLimit(t *testing.T) {
+	bucket := &ratelimit.TokenBucket{MaxTokens: 10, TokensPerFill: 10, FillInterval: types.DurationProto(time.Second)}
+	tests := []struct {
+		name  string
+		in    proto.Message
+		valid bool
+	}{
+		{"invalid message", &networking.Sidecar{}, false},
+		{"valid", &ratelimit.LocalRateLimit{
+			WorkloadLabels: map[string]string{"app": "reviews"},
+			TokenBucket:    bucket,
+			Descriptors:    []*ratelimit.Descriptor{{Header: "x-user-tier", Value: "free", TokenBucket: bucket}},
+			Routes: []*ratelimit.RouteRateLimit{
+				{Port: 9080, TokenBucket: bucket},
+				{VirtualService: "reviews", TokenBucket: bucket},
+			},
+		}, true},
+		{"missing token bucket", &ratelimit.LocalRateLimit{}, false},
+		{"invalid labels", &ratelimit.LocalRateLimit{
+			WorkloadLabels: map[string]string{"@": "reviews"},
+			TokenBucket:    bucket,
+		}, false},
+		{"zero max tokens", &ratelimit.LocalRateLimit{
+			TokenBucket: &ratelimit.TokenBucket{FillInterval: types.DurationProto(time.Second)},
+		}, false},
+		{"missing fill interval", &ratelimit.LocalRateLimit{
+			TokenBucket: &ratelimit.TokenBucket{MaxTokens: 10},
+		}, false},
+		{"fill interval too short", &ratelimit.LocalRateLimit{
+			TokenBucket: &ratelimit.TokenBucket{MaxTokens: 10, FillInterval: types.DurationProto(10 * time.Millisecond)},
+		}, false},
+		{"descriptor without value", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Descriptors: []*ratelimit.Descriptor{{Header: "x-user-tier", TokenBucket: bucket}},
+		}, false},
+		{"descriptor without header", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Descriptors: []*ratelimit.Descriptor{{Value: "free", TokenBucket: bucket}},
+		}, false},
+		{"descriptor without token bucket", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Descriptors: []*ratelimit.Descriptor{{Header: "x-user-tier", Value: "free"}},
+		}, false},
+		{"duplicate descriptors", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Descriptors: []*ratelimit.Descriptor{
+				{Header: "x-user-tier", Value: "free", TokenBucket: bucket},
+				{Header: "X-User-Tier", Value: "free", TokenBucket: bucket},
+			},
+		}, false},
+		{"route with port and virtual service", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Routes:      []*ratelimit.RouteRateLimit{{Port: 9080, VirtualService: "reviews", TokenBucket: bucket}},
+		}, false},
+		{"route without selector", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Routes:      []*ratelimit.RouteRateLimit{{TokenBucket: bucket}},
+		}, false},
+		{"route with invalid port", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Routes:      []*ratelimit.RouteRateLimit{{Port: 70000, TokenBucket: bucket}},
+		}, false},
+		{"route without token bucket", &ratelimit.LocalRateLimit{
+			TokenBucket: bucket,
+			Routes:      []*ratelimit.RouteRateLimit{{Port: 9080}},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLocalRateLimit(someName, someNamespace, tt.in)
+			if err == nil && !tt.valid {
+				t.Fatalf("ValidateLocalRateLimit(%v) = true, wanted false", tt.in)
+			} else if err != nil && tt.valid {
+				t.Fatalf("ValidateLocalRateLimit(%v) = %v, wanted true", tt.in, err)
+			}
+		})
+	}
+}
+
 func TestValidateLocalityLbSetting(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -34,6 +34,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/spiffe"
 )
 
 const (
@@ -863,10 +864,25 @@ func applyUpstreamTLSSettings(env *model.Environment, cluster *apiv2.Cluster, tl
 			},
 		}
 	}
-	if trustedCa != nil || len(tls.SubjectAltNames) > 0 {
+	// The SANs in the trust domain or one of its aliases are also accepted in the others.
+	subjectAltNames := spiffe.ExpandWithTrustDomainAliases(tls.SubjectAltNames)
+	// The certificates of Istio mutual TLS are validated with the roots of the peer trust domain of the SANs,
+	// if its bundle is exchanged with the mesh.
+	var trustBundle *core.DataSource
+	if tls.Mode == networking.TLSSettings_ISTIO_MUTUAL {
+		trustBundle = model.ConstructTrustBundle(subjectAltNames)
+		if trustBundle != nil {
+			trustedCa = trustBundle
+		}
+	}
+	if trustedCa != nil || len(subjectAltNames) > 0 {
 		certValidationContext = &auth.CertificateValidationContext{
 			TrustedCa:            trustedCa,
-			VerifySubjectAltName: tls.SubjectAltNames,
+			VerifySubjectAltName: subjectAltNames,
+		}
+		// Only the certificates of the mesh CA are revoked by its CRL.
+		if tls.Mode == networking.TLSSettings_ISTIO_MUTUAL && trustBundle == nil {
+			certValidationContext.Crl = model.ConstructCertificateRevocationList(subjectAltNames)
 		}
 	}
 
@@ -923,12 +939,22 @@ func applyUpstreamTLSSettings(env *model.Environment, cluster *apiv2.Cluster, tl
 			cluster.TlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs = append(cluster.TlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs,
 				model.ConstructSdsSecretConfig(model.SDSDefaultResourceName, env.Mesh.SdsUdsPath, env.Mesh.EnableSdsTokenMount, env.Mesh.SdsUseK8SSaJwt, metadata))
 
-			cluster.TlsContext.CommonTlsContext.ValidationContextType = &auth.CommonTlsContext_CombinedValidationContext{
-				CombinedValidationContext: &auth.CommonTlsContext_CombinedCertificateValidationContext{
-					DefaultValidationContext: &auth.CertificateValidationContext{VerifySubjectAltName: tls.SubjectAltNames},
-					ValidationContextSdsSecretConfig: model.ConstructSdsSecretConfig(model.SDSRootResourceName, env.Mesh.SdsUdsPath,
-						env.Mesh.EnableSdsTokenMount, env.Mesh.SdsUseK8SSaJwt, metadata),
-				},
+			if trustBundle != nil {
+				// The trust bundle replaces the root certificates fetched through SDS.
+				cluster.TlsContext.CommonTlsContext.ValidationContextType = &auth.CommonTlsContext_ValidationContext{
+					ValidationContext: certValidationContext,
+				}
+			} else {
+				cluster.TlsContext.CommonTlsContext.ValidationContextType = &auth.CommonTlsContext_CombinedValidationContext{
+					CombinedValidationContext: &auth.CommonTlsContext_CombinedCertificateValidationContext{
+						DefaultValidationContext: &auth.CertificateValidationContext{
+							VerifySubjectAltName: subjectAltNames,
+							Crl:                  model.ConstructCertificateRevocationList(subjectAltNames),
+						},
+						ValidationContextSdsSecretConfig: model.ConstructSdsSecretConfig(model.SDSRootResourceName, env.Mesh.SdsUdsPath,
+							env.Mesh.EnableSdsTokenMount, env.Mesh.SdsUseK8SSaJwt, metadata),
+					},
+				}
 			}
 		}
 
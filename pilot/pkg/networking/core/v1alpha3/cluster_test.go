@@ -15,7 +15,14 @@
 package v1alpha3
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
@@ -32,6 +39,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/fakes"
 	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pkg/spiffe"
 )
 
 type ConfigType int
@@ -648,6 +656,122 @@ func TestConditionallyConvertToIstioMtls(t *testing.T) {
 	}
 }
 
+func TestApplyUpstreamTLSSettingsWithTrustDomainAliases(t *testing.T) {
+	g := NewGomegaWithT(t)
+	oldTrustDomain := spiffe.GetTrustDomain()
+	defer spiffe.SetTrustDomain(oldTrustDomain)
+	defer spiffe.SetTrustDomainAliases(nil)
+	spiffe.SetTrustDomain("new.td")
+	spiffe.SetTrustDomainAliases([]string{"old.td"})
+
+	env := &model.Environment{Mesh: &testMesh}
+	cluster := &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	tls := buildIstioMutualTLS([]string{"spiffe://old.td/ns/foo/sa/bar", "custom.foo.com"}, "", &model.Proxy{})
+	applyUpstreamTLSSettings(env, cluster, tls, nil)
+
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetValidationContext().VerifySubjectAltName).To(Equal(
+		[]string{"spiffe://old.td/ns/foo/sa/bar", "spiffe://new.td/ns/foo/sa/bar", "custom.foo.com"}))
+}
+
+func TestApplyUpstreamTLSSettingsWithTrustBundles(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer model.SetTrustBundles(nil)
+	model.SetTrustBundles(map[string][]byte{"east.example.com": []byte("east-root\n")})
+	expectedCa := &core.DataSource{
+		Specifier: &core.DataSource_InlineBytes{InlineBytes: []byte("east-root\n")},
+	}
+
+	sans := []string{"spiffe://east.example.com/ns/foo/sa/bar"}
+	env := &model.Environment{Mesh: &testMesh}
+	cluster := &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS(sans, "", &model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetValidationContext().TrustedCa).To(Equal(expectedCa))
+
+	// With SDS, the trust bundle replaces the root certificates fetched through SDS.
+	sdsMesh := testMesh
+	sdsMesh.SdsUdsPath = "/var/run/sds/uds_path"
+	env = &model.Environment{Mesh: &sdsMesh}
+	cluster = &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS(sans, "", &model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetCombinedValidationContext()).To(BeNil())
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetValidationContext().TrustedCa).To(Equal(expectedCa))
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetValidationContext().VerifySubjectAltName).To(Equal(sans))
+
+	// The peers of another trust domain are validated with the roots of the mesh.
+	cluster = &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS([]string{"spiffe://west.example.com/ns/foo/sa/bar"}, "",
+		&model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetCombinedValidationContext()).NotTo(BeNil())
+}
+
+func TestApplyUpstreamTLSSettingsWithTrustBundlesAndRevocationList(t *testing.T) {
+	g := NewGomegaWithT(t)
+	defer model.SetTrustBundles(nil)
+	defer model.SetCertificateRevocationList(nil)
+	if _, err := model.SetCertificateRevocationList(testCRL(t)); err != nil {
+		t.Fatalf("Failed to set the CRL: %v", err)
+	}
+
+	env := &model.Environment{Mesh: &testMesh}
+	localSans := []string{"spiffe://cluster.local/ns/foo/sa/bar"}
+	cluster := &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS(localSans, "", &model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetValidationContext().Crl).NotTo(BeNil())
+
+	// The sidecars would reject the certificates of the peer CAs with the CRL of the mesh CA, which is
+	// only kept for the identities of the mesh.
+	model.SetTrustBundles(map[string][]byte{"east.example.com": []byte("east-root\n")})
+	peerSans := []string{"spiffe://east.example.com/ns/foo/sa/bar"}
+	cluster = &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS(peerSans, "", &model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetValidationContext().Crl).To(BeNil())
+	cluster = &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS(localSans, "", &model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetValidationContext().Crl).NotTo(BeNil())
+
+	sdsMesh := testMesh
+	sdsMesh.SdsUdsPath = "/var/run/sds/uds_path"
+	env = &model.Environment{Mesh: &sdsMesh}
+	cluster = &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS(localSans, "", &model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetCombinedValidationContext().DefaultValidationContext.Crl).NotTo(BeNil())
+	cluster = &apiv2.Cluster{Name: "outbound|8080||foo.example.org"}
+	applyUpstreamTLSSettings(env, cluster, buildIstioMutualTLS([]string{"spiffe://west.example.com/ns/foo/sa/bar"}, "",
+		&model.Proxy{}), nil)
+	g.Expect(cluster.TlsContext.CommonTlsContext.GetCombinedValidationContext().DefaultValidationContext.Crl).To(BeNil())
+}
+
+// testCRL returns a PEM-encoded CRL of a self-signed CA, valid for an hour.
+func testCRL(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"cluster.local"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := cert.CreateCRL(rand.Reader, key, nil, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})
+}
+
 func TestLocalityLB(t *testing.T) {
 	g := NewGomegaWithT(t)
 	// Distribute locality loadbalancing setting
@@ -33,6 +33,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/route/retry"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/features/pilot"
 	"istio.io/istio/pkg/log"
 )
 
@@ -316,6 +317,13 @@ func translateRoute(push *model.PushContext, node *model.Proxy, in *networking.H
 		return nil
 	}
 
+	// JWT claims are only available to the router in gateways that validate the request JWT.
+	// Elsewhere the claim headers could be set by the client, so skip the route altogether.
+	if hasJwtClaimMatch(match) && (node.Type != model.Router || !pilot.EnableJwtClaimRouting()) {
+		log.Debugf("skipping route with JWT claim match in virtual service %s for proxy %s", virtualService.Name, node.ID)
+		return nil
+	}
+
 	out := &route.Route{
 		Match:    translateRouteMatch(match),
 		Metadata: util.BuildConfigInfoMetadata(virtualService.ConfigMeta),
@@ -515,6 +523,9 @@ func translateRouteMatch(in *networking.HTTPMatchRequest) route.RouteMatch {
 	}
 
 	for name, stringMatch := range in.Headers {
+		if claim, ok := model.JwtClaimFromMatchName(name); ok {
+			name = model.JwtClaimHeaderName(claim)
+		}
 		matcher := translateHeaderMatch(name, stringMatch)
 		out.Headers = append(out.Headers, &matcher)
 	}
@@ -553,6 +564,19 @@ func translateRouteMatch(in *networking.HTTPMatchRequest) route.RouteMatch {
 	return out
 }
 
+// hasJwtClaimMatch returns true if the match condition refers to any claim of the request JWT.
+func hasJwtClaimMatch(in *networking.HTTPMatchRequest) bool {
+	if in == nil {
+		return false
+	}
+	for name := range in.Headers {
+		if _, ok := model.JwtClaimFromMatchName(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // translateHeaderMatch translates to HeaderMatcher
 func translateHeaderMatch(name string, in *networking.StringMatch) route.HeaderMatcher {
 	out := route.HeaderMatcher{
@@ -15,6 +15,7 @@
 package route_test
 
 import (
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -285,6 +286,40 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		}
 		g.Expect(routes[0].GetRoute().GetHashPolicy()).To(gomega.ConsistOf(hashPolicy))
 	})
+
+	t.Run("for virtual service with JWT claim match", func(t *testing.T) {
+		g := gomega.NewGomegaWithT(t)
+
+		os.Setenv("PILOT_ENABLE_JWT_CLAIM_ROUTING", "true")
+		defer os.Unsetenv("PILOT_ENABLE_JWT_CLAIM_ROUTING")
+
+		gateway := &model.Proxy{
+			Type:        model.Router,
+			IPAddresses: []string{"1.1.1.1"},
+			ID:          "someID",
+			DNSDomain:   "foo.com",
+			Metadata:    map[string]string{"ISTIO_PROXY_VERSION": "1.1"},
+		}
+		routes, err := route.BuildHTTPRoutesForVirtualService(gateway, nil, virtualServiceWithJwtClaimMatch, serviceRegistry, 8080, model.LabelsCollection{}, gatewayNames)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(2))
+		g.Expect(routes[0].Match.Headers).To(gomega.ConsistOf(&envoyroute.HeaderMatcher{
+			Name:                 "x-istio-jwt-claim-tenant",
+			HeaderMatchSpecifier: &envoyroute.HeaderMatcher_ExactMatch{ExactMatch: "premium"},
+		}))
+
+		// Claims are not available in sidecars, so the route must not be generated.
+		routes, err = route.BuildHTTPRoutesForVirtualService(node, nil, virtualServiceWithJwtClaimMatch, serviceRegistry, 8080, model.LabelsCollection{}, gatewayNames)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		g.Expect(routes[0].Match.Headers).To(gomega.BeEmpty())
+
+		// Nor in gateways unless the feature is enabled.
+		os.Unsetenv("PILOT_ENABLE_JWT_CLAIM_ROUTING")
+		routes, err = route.BuildHTTPRoutesForVirtualService(gateway, nil, virtualServiceWithJwtClaimMatch, serviceRegistry, 8080, model.LabelsCollection{}, gatewayNames)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+	})
 }
 
 func loadBalancerPolicy(name string) *networking.LoadBalancerSettings_ConsistentHash {
@@ -374,6 +409,50 @@ var virtualServicePlain = model.Config{
 	},
 }
 
+var virtualServiceWithJwtClaimMatch = model.Config{
+	ConfigMeta: model.ConfigMeta{
+		Type:    model.VirtualService.Type,
+		Version: model.VirtualService.Version,
+		Name:    "acme",
+	},
+	Spec: &networking.VirtualService{
+		Hosts:    []string{},
+		Gateways: []string{"some-gateway"},
+		Http: []*networking.HTTPRoute{
+			{
+				Match: []*networking.HTTPMatchRequest{
+					{
+						Headers: map[string]*networking.StringMatch{
+							"@request.auth.claims.tenant": {
+								MatchType: &networking.StringMatch_Exact{Exact: "premium"},
+							},
+						},
+					},
+				},
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host:   "*.example.org",
+							Subset: "premium",
+						},
+						Weight: 100,
+					},
+				},
+			},
+			{
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{
+							Host: "*.example.org",
+						},
+						Weight: 100,
+					},
+				},
+			},
+		},
+	},
+}
+
 var portLevelDestinationRule = &networking.DestinationRule{
 	Host:    "*.example.org",
 	Subsets: []*networking.Subset{},
@@ -23,6 +23,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/security/authn/factory"
+	"istio.io/istio/pkg/features/pilot"
 )
 
 // Plugin implements Istio mTLS auth
@@ -79,6 +80,12 @@ func buildFilter(in *plugin.InputParams, mutable *plugin.MutableObjects) error {
 			if filter := applier.AuthNFilter(in.Node.Type, util.IsXDSMarshalingToAnyEnabled(in.Node)); filter != nil {
 				mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP, filter)
 			}
+			// Expose the validated JWT claims to the router in gateways. The filter is added even without
+			// a JWT policy, so that claim headers sent by the client are always removed before routing.
+			if in.Node.Type == model.Router && pilot.EnableJwtClaimRouting() {
+				mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP,
+					buildJwtClaimsFilter(util.IsXDSMarshalingToAnyEnabled(in.Node)))
+			}
 		}
 	}
 
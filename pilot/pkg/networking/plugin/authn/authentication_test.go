@@ -0,0 +1,163 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
+	lua "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/lua/v2"
+	xdsutil "github.com/envoyproxy/go-control-plane/pkg/util"
+	"github.com/gogo/protobuf/types"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	jwtfilter "istio.io/api/envoy/config/filter/http/jwt_auth/v2alpha1"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/test"
+	"istio.io/istio/pilot/pkg/networking/plugin"
+)
+
+func buildGatewayParams(t *testing.T, policy *authn.Policy) (*plugin.InputParams, *plugin.MutableObjects) {
+	t.Helper()
+	store := model.MakeIstioStore(memory.Make(model.IstioConfigTypes))
+	if policy != nil {
+		config := model.Config{
+			ConfigMeta: model.ConfigMeta{
+				Type:      model.AuthenticationPolicy.Type,
+				Name:      "default",
+				Namespace: "istio-system",
+			},
+			Spec: policy,
+		}
+		if _, err := store.Create(config); err != nil {
+			t.Fatalf("failed to create authentication policy: %v", err)
+		}
+	}
+	mesh := model.DefaultMeshConfig()
+	in := &plugin.InputParams{
+		ListenerProtocol: plugin.ListenerProtocolHTTP,
+		Env: &model.Environment{
+			IstioConfigStore: store,
+			Mesh:             &mesh,
+		},
+		Node: &model.Proxy{
+			Type:     model.Router,
+			Metadata: map[string]string{"ISTIO_PROXY_VERSION": "1.1"},
+		},
+		ServiceInstance: &model.ServiceInstance{
+			Service: &model.Service{
+				Hostname:   "istio-ingressgateway.istio-system.svc.cluster.local",
+				Attributes: model.ServiceAttributes{Namespace: "istio-system"},
+			},
+			Endpoint: model.NetworkEndpoint{
+				ServicePort: &model.Port{Name: "http2", Port: 80, Protocol: model.ProtocolHTTP2},
+			},
+		},
+	}
+	mutable := &plugin.MutableObjects{
+		Listener:     &xdsapi.Listener{FilterChains: []listener.FilterChain{{}}},
+		FilterChains: []plugin.FilterChain{{}},
+	}
+	return in, mutable
+}
+
+func TestOnOutboundListenerJwtClaimRouting(t *testing.T) {
+	ms, err := test.StartNewServer()
+	if err != nil {
+		t.Fatalf("failed to start a mock server: %v", err)
+	}
+	defer func() {
+		_ = ms.Stop()
+	}()
+
+	os.Setenv("PILOT_ENABLE_JWT_CLAIM_ROUTING", "true")
+	defer os.Unsetenv("PILOT_ENABLE_JWT_CLAIM_ROUTING")
+
+	jwtPolicy := &authn.Policy{
+		Origins: []*authn.OriginAuthenticationMethod{
+			{
+				Jwt: &authn.Jwt{
+					Issuer: ms.URL,
+				},
+			},
+		},
+		PrincipalBinding: authn.PrincipalBinding_USE_ORIGIN,
+	}
+
+	cases := []struct {
+		name     string
+		policy   *authn.Policy
+		expected []string
+	}{
+		{
+			name:     "jwt policy",
+			policy:   jwtPolicy,
+			expected: []string{"jwt-auth", "istio_authn", xdsutil.Lua},
+		},
+		{
+			name:     "no policy",
+			expected: []string{xdsutil.Lua},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			in, mutable := buildGatewayParams(t, c.policy)
+			if err := NewPlugin().OnOutboundListener(in, mutable); err != nil {
+				t.Fatalf("OnOutboundListener(): %v", err)
+			}
+			var got []string
+			for _, filter := range mutable.FilterChains[0].HTTP {
+				got = append(got, filter.Name)
+			}
+			if !reflect.DeepEqual(got, c.expected) {
+				t.Fatalf("got filters %v, expected %v", got, c.expected)
+			}
+
+			if c.policy != nil {
+				// The JWKS served by the local server must be inlined in the JWT filter config.
+				jwtConfig := &jwtfilter.JwtAuthentication{}
+				if err := types.UnmarshalAny(mutable.FilterChains[0].HTTP[0].GetTypedConfig(), jwtConfig); err != nil {
+					t.Fatalf("failed to read jwt filter config: %v", err)
+				}
+				if got := jwtConfig.Rules[0].GetLocalJwks().GetInlineString(); got != test.JwtPubKey1 {
+					t.Errorf("got jwt public key %q, expected %q", got, test.JwtPubKey1)
+				}
+			}
+
+			claimsFilter := mutable.FilterChains[0].HTTP[len(got)-1]
+			luaConfig := &lua.Lua{}
+			if err := types.UnmarshalAny(claimsFilter.GetTypedConfig(), luaConfig); err != nil {
+				t.Fatalf("failed to read claims filter config: %v", err)
+			}
+			if luaConfig.InlineCode != jwtClaimsScript {
+				t.Errorf("unexpected claims filter script %q", luaConfig.InlineCode)
+			}
+		})
+	}
+}
+
+func TestOnOutboundListenerJwtClaimRoutingDisabled(t *testing.T) {
+	in, mutable := buildGatewayParams(t, nil)
+	if err := NewPlugin().OnOutboundListener(in, mutable); err != nil {
+		t.Fatalf("OnOutboundListener(): %v", err)
+	}
+	if len(mutable.FilterChains[0].HTTP) != 0 {
+		t.Errorf("expected no filters, got %v", mutable.FilterChains[0].HTTP)
+	}
+}
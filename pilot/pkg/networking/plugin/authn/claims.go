@@ -0,0 +1,96 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"fmt"
+
+	lua "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/lua/v2"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	xdsutil "github.com/envoyproxy/go-control-plane/pkg/util"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/security/authn/v1alpha1"
+)
+
+// jwtClaimsMetadataKey is the key of the validated JWT claims in the dynamic metadata
+// emitted by the Istio authn filter.
+const jwtClaimsMetadataKey = "request.auth.claims"
+
+// jwtClaimsScript copies the JWT claims validated by the Istio authn filter into request headers,
+// so that the router can match on them. Claim headers sent by the client are always removed first.
+// The claims whose names map to the same header, e.g. "Tenant" and "tenant", are all dropped, so
+// that one claim cannot be matched as another. The header names must be kept in sync with
+// model.JwtClaimHeaderName.
+var jwtClaimsScript = fmt.Sprintf(`
+function envoy_on_request(request_handle)
+  local prefix = %[1]q
+  local headers = request_handle:headers()
+  local spoofed = {}
+  for key, _ in pairs(headers) do
+    if string.sub(key, 1, string.len(prefix)) == prefix then
+      table.insert(spoofed, key)
+    end
+  end
+  for _, key in ipairs(spoofed) do
+    headers:remove(key)
+  end
+
+  local authn = request_handle:streamInfo():dynamicMetadata():get(%[2]q)
+  if authn == nil or authn[%[3]q] == nil then
+    return
+  end
+  local claims = {}
+  local collided = {}
+  for name, value in pairs(authn[%[3]q]) do
+    local header = prefix .. (string.gsub(string.lower(name), "[^a-z0-9_-]", "-"))
+    if claims[header] ~= nil then
+      collided[header] = true
+    end
+    claims[header] = value
+  end
+  for header, value in pairs(claims) do
+    local values = {}
+    if type(value) == "table" then
+      for _, v in ipairs(value) do
+        if type(v) ~= "table" then
+          table.insert(values, tostring(v))
+        end
+      end
+    else
+      table.insert(values, tostring(value))
+    end
+    if #values > 0 and not collided[header] then
+      headers:replace(header, table.concat(values, ","))
+    end
+  end
+end
+`, model.JwtClaimHeaderPrefix, v1alpha1.AuthnFilterName, jwtClaimsMetadataKey)
+
+// buildJwtClaimsFilter returns the HTTP filter exposing the validated JWT claims to the router.
+// It must be placed after the Istio authn filter.
+func buildJwtClaimsFilter(isXDSMarshalingToAnyEnabled bool) *http_conn.HttpFilter {
+	filterConfigProto := &lua.Lua{InlineCode: jwtClaimsScript}
+	out := &http_conn.HttpFilter{
+		Name: xdsutil.Lua,
+	}
+	if isXDSMarshalingToAnyEnabled {
+		out.ConfigType = &http_conn.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(filterConfigProto)}
+	} else {
+		out.ConfigType = &http_conn.HttpFilter_Config{Config: util.MessageToStruct(filterConfigProto)}
+	}
+	return out
+}
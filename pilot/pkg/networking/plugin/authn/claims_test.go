@@ -0,0 +1,91 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"reflect"
+	"testing"
+
+	glua "github.com/yuin/gopher-lua"
+)
+
+// jwtClaimsHarness runs envoy_on_request with a request handle whose headers are a plain table,
+// and the claims in claims, then stores the resulting claim headers in the result table.
+const jwtClaimsHarness = `
+local headers = {["x-istio-jwt-claim-spoofed"] = "admin", [":path"] = "/"}
+function headers:remove(key) self[key] = nil end
+function headers:replace(key, value) self[key] = value end
+local metadata = {}
+function metadata:get(name) return {["request.auth.claims"] = claims} end
+local streamInfo = {}
+function streamInfo:dynamicMetadata() return metadata end
+local handle = {}
+function handle:headers() return headers end
+function handle:streamInfo() return streamInfo end
+envoy_on_request(handle)
+result = {}
+for key, value in pairs(headers) do
+  if type(value) == "string" then
+    result[key] = value
+  end
+end
+`
+
+func TestJwtClaimsScript(t *testing.T) {
+	cases := []struct {
+		name     string
+		claims   string
+		expected map[string]string
+	}{
+		{
+			name:   "claims",
+			claims: `{iss = "example.com", groups = {"dev", "ops"}, ["Tenant.ID"] = "a"}`,
+			expected: map[string]string{
+				":path":                       "/",
+				"x-istio-jwt-claim-iss":       "example.com",
+				"x-istio-jwt-claim-groups":    "dev,ops",
+				"x-istio-jwt-claim-tenant-id": "a",
+			},
+		},
+		{
+			// The claims mapped to the same header are all dropped, so that none can be matched as another.
+			name:   "colliding claims",
+			claims: `{iss = "example.com", tenant = "a", Tenant = "b", ["tenant.id"] = "c", ["tenant-id"] = "d"}`,
+			expected: map[string]string{
+				":path":                 "/",
+				"x-istio-jwt-claim-iss": "example.com",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			state := glua.NewState()
+			defer state.Close()
+			if err := state.DoString(jwtClaimsScript); err != nil {
+				t.Fatalf("Failed to load the script: %v", err)
+			}
+			if err := state.DoString("claims = " + c.claims + "\n" + jwtClaimsHarness); err != nil {
+				t.Fatalf("Failed to run the script: %v", err)
+			}
+			got := map[string]string{}
+			state.GetGlobal("result").(*glua.LTable).ForEach(func(key, value glua.LValue) {
+				got[key.String()] = value.String()
+			})
+			if !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("Got headers %v, expected %v", got, c.expected)
+			}
+		})
+	}
+}
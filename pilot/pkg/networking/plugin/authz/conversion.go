@@ -116,6 +116,15 @@ func principalForKeyValues(key string, values []string, forTCPFilter bool) *envo
 	return pg.OrPrincipals()
 }
 
+func principalForSourcePrincipal(value string, forTCPFilter bool) *envoy_rbac.Principal {
+	if forTCPFilter {
+		m := matcher.StringMatcherWithPrefix(value, spiffe.URIPrefix)
+		return rbacfilter.PrincipalAuthenticated(m)
+	}
+	metadata := matcher.MetadataStringMatcher(authn_v1alpha1.AuthnFilterName, attrSrcPrincipal, matcher.StringMatcher(value))
+	return rbacfilter.PrincipalMetadata(metadata)
+}
+
 func principalForKeyValue(key, value string, forTCPFilter bool) *envoy_rbac.Principal {
 	switch {
 	case attrSrcIP == key:
@@ -148,12 +157,16 @@ func principalForKeyValue(key, value string, forTCPFilter bool) *envoy_rbac.Prin
 			value = "*"
 		}
 
-		if forTCPFilter {
-			m := matcher.StringMatcherWithPrefix(value, spiffe.URIPrefix)
-			return rbacfilter.PrincipalAuthenticated(m)
+		// The principal in the trust domain or one of its aliases also matches the same principal
+		// in the others.
+		if values := spiffe.ExpandWithTrustDomainAliases([]string{value}); len(values) > 1 {
+			pg := rbacfilter.PrincipalGenerator{}
+			for _, v := range values {
+				pg.Append(principalForSourcePrincipal(v, forTCPFilter))
+			}
+			return pg.OrPrincipals()
 		}
-		metadata := matcher.MetadataStringMatcher(authn_v1alpha1.AuthnFilterName, key, matcher.StringMatcher(value))
-		return rbacfilter.PrincipalMetadata(metadata)
+		return principalForSourcePrincipal(value, forTCPFilter)
 	case found(key, []string{attrRequestPrincipal, attrRequestAudiences, attrRequestPresenter, attrSrcUser}):
 		m := matcher.MetadataStringMatcher(authn_v1alpha1.AuthnFilterName, key, matcher.StringMatcher(value))
 		return rbacfilter.PrincipalMetadata(m)
@@ -121,11 +121,13 @@ func NewModel(role *istio_rbac.ServiceRole, bindings []*istio_rbac.ServiceRoleBi
 // in the model for the given service. This function only generates the policy if the constraints
 // and properties specified in the model is matched with the given service. It also validates if the
 // model is valid for TCP filter.
-func (m *Model) Generate(service *serviceMetadata, forTCPFilter bool) *envoy_rbac.Policy {
+// For a DENY policy, the HTTP-only fields are ignored instead when generating the TCP filter, so that
+// the generated policy denies more requests rather than less.
+func (m *Model) Generate(service *serviceMetadata, forTCPFilter bool, forDenyPolicy bool) *envoy_rbac.Policy {
 	policy := &envoy_rbac.Policy{}
 	for _, permission := range m.Permissions {
 		if permission.Match(service) {
-			p, err := permission.generate(forTCPFilter)
+			p, err := permission.generate(forTCPFilter, forDenyPolicy)
 			if err != nil {
 				rbacLog.Debugf("ignored HTTP permission for TCP service: %v", err)
 				continue
@@ -140,7 +142,7 @@ func (m *Model) Generate(service *serviceMetadata, forTCPFilter bool) *envoy_rba
 	}
 
 	for _, principal := range m.Principals {
-		p, err := principal.generate(forTCPFilter)
+		p, err := principal.generate(forTCPFilter, forDenyPolicy)
 		if err != nil {
 			rbacLog.Debugf("ignored HTTP principal for TCP service: %v", err)
 			continue
@@ -264,7 +266,62 @@ func (principal *Principal) ValidateForTCP(forTCP bool) error {
 	return nil
 }
 
-func (permission *Permission) generate(forTCPFilter bool) (*envoy_rbac.Permission, error) {
+// withoutHTTPOnlyFields returns a copy of the permission without the fields that are only
+// supported by the HTTP filter.
+func (permission *Permission) withoutHTTPOnlyFields() *Permission {
+	out := *permission
+	out.Hosts, out.NotHosts = nil, nil
+	out.Paths, out.NotPaths = nil, nil
+	out.Methods, out.NotMethods = nil, nil
+	out.Constraints = nil
+	for _, constraint := range permission.Constraints {
+		kv := KeyValues{}
+		for k, v := range constraint {
+			if !strings.HasPrefix(k, attrRequestHeader) {
+				kv[k] = v
+			}
+		}
+		out.Constraints = append(out.Constraints, kv)
+	}
+	return &out
+}
+
+// withoutHTTPOnlyFields returns a copy of the principal without the fields that are only
+// supported by the HTTP filter.
+func (principal *Principal) withoutHTTPOnlyFields() *Principal {
+	out := *principal
+	out.Group = ""
+	out.Groups, out.NotGroups = nil, nil
+	out.Properties = nil
+	for _, p := range principal.Properties {
+		kv := KeyValues{}
+		for k, v := range p {
+			if found(k, []string{attrSrcIP, attrSrcNamespace, attrSrcPrincipal}) {
+				kv[k] = v
+			}
+		}
+		out.Properties = append(out.Properties, kv)
+	}
+	return &out
+}
+
+// isEmpty returns true if the principal doesn't specify any field.
+func (principal *Principal) isEmpty() bool {
+	for _, p := range principal.Properties {
+		if len(p) != 0 {
+			return false
+		}
+	}
+	return principal.User == "" && len(principal.Names) == 0 && len(principal.NotNames) == 0 &&
+		principal.Group == "" && len(principal.Groups) == 0 && len(principal.NotGroups) == 0 &&
+		len(principal.Namespaces) == 0 && len(principal.NotNamespaces) == 0 &&
+		len(principal.IPs) == 0 && len(principal.NotIPs) == 0
+}
+
+func (permission *Permission) generate(forTCPFilter, forDenyPolicy bool) (*envoy_rbac.Permission, error) {
+	if forTCPFilter && forDenyPolicy {
+		permission = permission.withoutHTTPOnlyFields()
+	}
 	if err := permission.ValidateForTCP(forTCPFilter); err != nil {
 		return nil, err
 	}
@@ -335,7 +392,14 @@ func (permission *Permission) generate(forTCPFilter bool) (*envoy_rbac.Permissio
 	return pg.AndPermissions(), nil
 }
 
-func (principal *Principal) generate(forTCPFilter bool) (*envoy_rbac.Principal, error) {
+func (principal *Principal) generate(forTCPFilter, forDenyPolicy bool) (*envoy_rbac.Principal, error) {
+	if forTCPFilter && forDenyPolicy && principal.ValidateForTCP(forTCPFilter) != nil {
+		principal = principal.withoutHTTPOnlyFields()
+		if principal.isEmpty() {
+			// The principal only had HTTP-only fields, deny everyone for TCP.
+			return rbacfilter.PrincipalAny(true), nil
+		}
+	}
 	if err := principal.ValidateForTCP(forTCPFilter); err != nil {
 		return nil, err
 	}
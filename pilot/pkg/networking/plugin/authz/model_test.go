@@ -14,7 +14,14 @@
 
 package authz
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	envoy_rbac "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v2alpha"
+
+	"istio.io/istio/pilot/pkg/networking/plugin/authz/rbacfilter"
+)
 
 func TestPermission_Match(t *testing.T) {
 	cases := []struct {
@@ -230,3 +237,91 @@ func TestPrincipal_Validate(t *testing.T) {
 		}
 	}
 }
+
+func TestModel_GenerateForTCPDenyPolicy(t *testing.T) {
+	service := &serviceMetadata{name: "product.default"}
+	names := Principal{Names: []string{"cluster.local/ns/default/sa/ops"}}
+	portPermission := Permission{Ports: []int32{8080}}
+	generate := func(m *Model, forTCPFilter, forDenyPolicy bool) *envoy_rbac.Policy {
+		return m.Generate(service, forTCPFilter, forDenyPolicy)
+	}
+	anyPrincipal := []*envoy_rbac.Principal{rbacfilter.PrincipalAny(true)}
+
+	cases := []struct {
+		name  string
+		model *Model
+		// want is the model whose TCP ALLOW policy is expected to be generated, nil if no policy is
+		// expected.
+		want *Model
+		// wantPrincipals overrides the principals of the expected policy.
+		wantPrincipals []*envoy_rbac.Principal
+	}{
+		{
+			name: "HTTP-only permission fields are ignored",
+			model: &Model{
+				Permissions: []Permission{{Paths: []string{"/admin"}, NotMethods: []string{"GET"}, Ports: []int32{8080}}},
+				Principals:  []Principal{names},
+			},
+			want: &Model{Permissions: []Permission{portPermission}, Principals: []Principal{names}},
+		},
+		{
+			name: "HTTP-only permission matches everything",
+			model: &Model{
+				Permissions: []Permission{{Hosts: []string{"example.com"}}},
+				Principals:  []Principal{names},
+			},
+			want: &Model{Permissions: []Permission{{}}, Principals: []Principal{names}},
+		},
+		{
+			name: "HTTP-only constraints are ignored",
+			model: &Model{
+				Permissions: []Permission{{Constraints: []KeyValues{{"request.headers[x-id]": []string{"1"}, attrDestPort: []string{"8080"}}}}},
+				Principals:  []Principal{names},
+			},
+			want: &Model{Permissions: []Permission{{Constraints: []KeyValues{{attrDestPort: []string{"8080"}}}}}, Principals: []Principal{names}},
+		},
+		{
+			name: "HTTP-only principal fields are ignored",
+			model: &Model{
+				Permissions: []Permission{portPermission},
+				Principals: []Principal{{
+					Names:      names.Names,
+					NotGroups:  []string{"ops"},
+					Properties: []KeyValues{{attrRequestPrincipal: []string{"issuer/ops"}, attrSrcIP: []string{"10.0.0.0/8"}}},
+				}},
+			},
+			want: &Model{
+				Permissions: []Permission{portPermission},
+				Principals:  []Principal{{Names: names.Names, Properties: []KeyValues{{attrSrcIP: []string{"10.0.0.0/8"}}}}},
+			},
+		},
+		{
+			name: "HTTP-only principal matches everyone",
+			model: &Model{
+				Permissions: []Permission{portPermission},
+				Principals:  []Principal{{Groups: []string{"admin"}, Properties: []KeyValues{{attrRequestPrincipal: []string{"issuer/ops"}}}}},
+			},
+			want:           &Model{Permissions: []Permission{portPermission}, Principals: []Principal{names}},
+			wantPrincipals: anyPrincipal,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := generate(tc.want, true, false)
+			if tc.wantPrincipals != nil {
+				want.Principals = tc.wantPrincipals
+			}
+			if got := generate(tc.model, true, true); !reflect.DeepEqual(want, got) {
+				t.Errorf("want:\n%v\nbut got:\n%v", want, got)
+			}
+			// The HTTP filter and ALLOW policies are not affected.
+			if got, want := generate(tc.model, false, true), generate(tc.model, false, false); !reflect.DeepEqual(want, got) {
+				t.Errorf("HTTP policy want:\n%v\nbut got:\n%v", want, got)
+			}
+			if got := generate(tc.model, true, false); got != nil {
+				t.Errorf("TCP ALLOW policy want nil but got:\n%v", got)
+			}
+		})
+	}
+}
@@ -47,8 +47,11 @@ const (
 	rbacHTTPFilterName = "envoy.filters.http.rbac"
 
 	// rbacTCPFilterName is the name of the RBAC network filter in envoy.
-	rbacTCPFilterName       = "envoy.filters.network.rbac"
-	rbacTCPFilterStatPrefix = "tcp."
+	rbacTCPFilterName = "envoy.filters.network.rbac"
+	// The stat prefixes of the RBAC network filters, distinct so that the counters of the filter
+	// enforcing the DENY policies are not merged with those of the filter enforcing the others.
+	rbacTCPFilterStatPrefix     = "tcp."
+	rbacTCPDenyFilterStatPrefix = "tcp.deny."
 
 	// attributes that could be used in both ServiceRoleBinding and ServiceRole.
 	attrRequestHeader = "request.headers" // header name is surrounded by brackets, e.g. "request.headers[User-Agent]".
@@ -174,33 +177,33 @@ func buildFilter(in *plugin.InputParams, mutable *plugin.MutableObjects) error {
 	switch in.ListenerProtocol {
 	case plugin.ListenerProtocolTCP:
 		rbacLog.Debugf("building filter for TCP listener protocol")
-		tcpFilter := buildTCPFilter(service, option, util.IsXDSMarshalingToAnyEnabled(in.Node))
+		tcpFilters := buildTCPFilters(service, option, util.IsXDSMarshalingToAnyEnabled(in.Node))
 		if in.Node.Type == model.Router {
 			// For gateways, due to TLS termination, a listener marked as TCP could very well
 			// be using a HTTP connection manager. So check the filterChain.listenerProtocol
 			// to decide the type of filter to attach
-			httpFilter := buildHTTPFilter(service, option, util.IsXDSMarshalingToAnyEnabled(in.Node))
+			httpFilters := buildHTTPFilters(service, option, util.IsXDSMarshalingToAnyEnabled(in.Node))
 			rbacLog.Infof("built RBAC http filter for router %s", service)
 			for cnum := range mutable.FilterChains {
 				if mutable.FilterChains[cnum].ListenerProtocol == plugin.ListenerProtocolHTTP {
-					mutable.FilterChains[cnum].HTTP = append(mutable.FilterChains[cnum].HTTP, httpFilter)
+					mutable.FilterChains[cnum].HTTP = append(mutable.FilterChains[cnum].HTTP, httpFilters...)
 				} else {
-					mutable.FilterChains[cnum].TCP = append(mutable.FilterChains[cnum].TCP, *tcpFilter)
+					mutable.FilterChains[cnum].TCP = append(mutable.FilterChains[cnum].TCP, tcpFilters...)
 				}
 			}
 		} else {
 			rbacLog.Infof("built RBAC tcp filter for sidecar %s", service)
 			for cnum := range mutable.FilterChains {
-				mutable.FilterChains[cnum].TCP = append(mutable.FilterChains[cnum].TCP, *tcpFilter)
+				mutable.FilterChains[cnum].TCP = append(mutable.FilterChains[cnum].TCP, tcpFilters...)
 			}
 		}
 	case plugin.ListenerProtocolHTTP:
 		rbacLog.Debugf("building filter for HTTP listener protocol")
-		filter := buildHTTPFilter(service, option, util.IsXDSMarshalingToAnyEnabled(in.Node))
-		if filter != nil {
+		filters := buildHTTPFilters(service, option, util.IsXDSMarshalingToAnyEnabled(in.Node))
+		if len(filters) != 0 {
 			rbacLog.Infof("built RBAC http filter for %s", service)
 			for cnum := range mutable.FilterChains {
-				mutable.FilterChains[cnum].HTTP = append(mutable.FilterChains[cnum].HTTP, filter)
+				mutable.FilterChains[cnum].HTTP = append(mutable.FilterChains[cnum].HTTP, filters...)
 			}
 		}
 	}
@@ -268,6 +271,28 @@ func isRbacEnabled(svc string, ns string, authzPolicies *model.AuthorizationPoli
 	}
 }
 
+// buildTCPFilters builds the RBAC network filters for the service. The filter denying requests, if
+// any, comes first.
+func buildTCPFilters(service *serviceMetadata, option rbacOption, isXDSMarshalingToAnyEnabled bool) []listener.Filter {
+	var filters []listener.Filter
+	if denyFilter := buildDenyTCPFilter(service, option, isXDSMarshalingToAnyEnabled); denyFilter != nil {
+		filters = append(filters, *denyFilter)
+	}
+	return append(filters, *buildTCPFilter(service, option, isXDSMarshalingToAnyEnabled))
+}
+
+// buildHTTPFilters builds the RBAC http filters for the service. The filter denying requests, if
+// any, comes first. Unlike the network filter, the http filter config has no stat prefix, so both
+// filters count under the rbac stats of the connection manager: the DENY and ALLOW policies are
+// told apart by their policy IDs, e.g. in the shadow_effective_policy_id dynamic metadata.
+func buildHTTPFilters(service *serviceMetadata, option rbacOption, isXDSMarshalingToAnyEnabled bool) []*http_conn.HttpFilter {
+	var filters []*http_conn.HttpFilter
+	if denyFilter := buildDenyHTTPFilter(service, option, isXDSMarshalingToAnyEnabled); denyFilter != nil {
+		filters = append(filters, denyFilter)
+	}
+	return append(filters, buildHTTPFilter(service, option, isXDSMarshalingToAnyEnabled))
+}
+
 func buildTCPFilter(service *serviceMetadata, option rbacOption, isXDSMarshalingToAnyEnabled bool) *listener.Filter {
 	option.forTCPFilter = true
 	// The result of convertRbacRulesToFilterConfig() is wrapped in a config for http filter, here we
@@ -280,13 +305,31 @@ func buildTCPFilter(service *serviceMetadata, option rbacOption, isXDSMarshaling
 		rbacLog.Debugf("used RBAC v1 for TCP filter")
 		config = convertRbacRulesToFilterConfig(service, option)
 	}
+	return newTCPFilter(config, rbacTCPFilterStatPrefix, isXDSMarshalingToAnyEnabled)
+}
+
+// buildDenyTCPFilter builds the RBAC network filter enforcing the DENY policies of the service. It
+// returns nil if there is no such policy, which is always the case with RBAC v1.
+func buildDenyTCPFilter(service *serviceMetadata, option rbacOption, isXDSMarshalingToAnyEnabled bool) *listener.Filter {
+	if !option.authzPolicies.IsRbacV2 {
+		return nil
+	}
+	option.forTCPFilter = true
+	config := convertDenyRulesToFilterConfigV2(service, option)
+	if config == nil {
+		return nil
+	}
+	return newTCPFilter(config, rbacTCPDenyFilterStatPrefix, isXDSMarshalingToAnyEnabled)
+}
+
+func newTCPFilter(config *http_config.RBAC, statPrefix string, isXDSMarshalingToAnyEnabled bool) *listener.Filter {
 	tcpConfig := listener.Filter{
 		Name: rbacTCPFilterName,
 	}
 	rbacConfig := &network_config.RBAC{
 		Rules:       config.Rules,
 		ShadowRules: config.ShadowRules,
-		StatPrefix:  rbacTCPFilterStatPrefix,
+		StatPrefix:  statPrefix,
 	}
 
 	if isXDSMarshalingToAnyEnabled {
@@ -311,6 +354,24 @@ func buildHTTPFilter(service *serviceMetadata, option rbacOption, isXDSMarshalin
 		rbacLog.Debugf("used RBAC v1 for HTTP filter")
 		config = convertRbacRulesToFilterConfig(service, option)
 	}
+	return newHTTPFilter(config, isXDSMarshalingToAnyEnabled)
+}
+
+// buildDenyHTTPFilter builds the RBAC http filter enforcing the DENY policies of the service. It
+// returns nil if there is no such policy, which is always the case with RBAC v1.
+func buildDenyHTTPFilter(service *serviceMetadata, option rbacOption, isXDSMarshalingToAnyEnabled bool) *http_conn.HttpFilter {
+	if !option.authzPolicies.IsRbacV2 {
+		return nil
+	}
+	option.forTCPFilter = false
+	config := convertDenyRulesToFilterConfigV2(service, option)
+	if config == nil {
+		return nil
+	}
+	return newHTTPFilter(config, isXDSMarshalingToAnyEnabled)
+}
+
+func newHTTPFilter(config *http_config.RBAC, isXDSMarshalingToAnyEnabled bool) *http_conn.HttpFilter {
 	rbacLog.Debugf("generated http filter config: %v", *config)
 	out := &http_conn.HttpFilter{
 		Name: rbacHTTPFilterName,
@@ -379,7 +440,7 @@ func setPolicy(config *policyproto.RBAC, roleConfig model.Config, bindings []*rb
 	if len(bindings) != 0 {
 		role := roleConfig.Spec.(*rbacproto.ServiceRole)
 		m := NewModel(role, bindings)
-		policy := m.Generate(service, option.forTCPFilter)
+		policy := m.Generate(service, option.forTCPFilter, false)
 		if policy != nil {
 			rbacLog.Debugf("generated policy for role: %s", roleConfig.Name)
 			config.Policies[roleConfig.Name] = policy
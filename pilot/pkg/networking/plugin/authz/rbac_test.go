@@ -34,7 +34,9 @@ import (
 
 	rbacproto "istio.io/api/rbac/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/plugin/authz/rbacfilter"
 	authn_v1alpha1 "istio.io/istio/pilot/pkg/security/authn/v1alpha1"
+	"istio.io/istio/pkg/spiffe"
 )
 
 func newAuthzPoliciesWithRolesAndBindings(configs ...[]model.Config) *model.AuthorizationPolicies {
@@ -334,6 +336,108 @@ func TestBuildHTTPFilter(t *testing.T) {
 	}
 }
 
+func TestBuildFiltersWithDenyPolicy(t *testing.T) {
+	allowCfg := model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type: model.AuthorizationPolicy.Type, Name: "allow", Namespace: "default"},
+		Spec: &rbacproto.AuthorizationPolicy{
+			Allow: []*rbacproto.ServiceRoleBinding{{
+				Subjects: []*rbacproto.Subject{{Names: []string{"allUsers"}}},
+				Actions:  []*rbacproto.AccessRule{{Ports: []int32{9000}}},
+			}},
+		},
+	}
+	denyCfg := model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type: model.AuthorizationPolicy.Type, Name: "deny", Namespace: "default",
+			Annotations: map[string]string{model.AuthorizationActionAnnotation: model.AuthorizationActionDeny}},
+		Spec: &rbacproto.AuthorizationPolicy{
+			Allow: []*rbacproto.ServiceRoleBinding{{
+				Subjects: []*rbacproto.Subject{{NotNamespaces: []string{"default"}}},
+				Actions:  []*rbacproto.AccessRule{{Ports: []int32{9000}}},
+			}},
+		},
+	}
+	service := &serviceMetadata{
+		name: "product.default", attributes: map[string]string{attrDestName: "product", attrDestNamespace: "default"}}
+
+	testCases := []struct {
+		name    string
+		configs []model.Config
+		// Expected policies of each filter, in order.
+		policies []string
+	}{
+		{
+			name:     "allow policy only",
+			configs:  []model.Config{allowCfg},
+			policies: []string{"authz-policy-allow-allow[0]"},
+		},
+		{
+			name:     "deny and allow policies",
+			configs:  []model.Config{allowCfg, denyCfg},
+			policies: []string{"authz-policy-deny-deny[0]", "authz-policy-allow-allow[0]"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			option := rbacOption{authzPolicies: newAuthzPoliciesWithRolesAndBindings(tc.configs)}
+			option.authzPolicies.IsRbacV2 = true
+
+			checkRules := func(i int, rbac *policy.RBAC) {
+				t.Helper()
+				wantAction := policy.RBAC_ALLOW
+				if i < len(tc.policies)-1 {
+					wantAction = policy.RBAC_DENY
+				}
+				if rbac.Action != wantAction {
+					t.Errorf("filter %d: expecting action %v but got %v", i, wantAction, rbac.Action)
+				}
+				if _, ok := rbac.Policies[tc.policies[i]]; !ok || len(rbac.Policies) != 1 {
+					t.Errorf("filter %d: expecting policy %s but got %v", i, tc.policies[i], rbac.Policies)
+				}
+			}
+
+			httpFilters := buildHTTPFilters(service, option, true)
+			if len(httpFilters) != len(tc.policies) {
+				t.Fatalf("expecting %d http filters but got %d", len(tc.policies), len(httpFilters))
+			}
+			for i, filter := range httpFilters {
+				if filter.Name != rbacHTTPFilterName {
+					t.Errorf("filter %d: expecting filter name %s, but got %s", i, rbacHTTPFilterName, filter.Name)
+				}
+				rbacConfig := &http_config.RBAC{}
+				if err := rbacConfig.Unmarshal(filter.GetTypedConfig().GetValue()); err != nil {
+					t.Fatalf("filter %d: bad rbac config: %v", i, err)
+				}
+				checkRules(i, rbacConfig.Rules)
+			}
+
+			tcpFilters := buildTCPFilters(service, option, true)
+			if len(tcpFilters) != len(tc.policies) {
+				t.Fatalf("expecting %d tcp filters but got %d", len(tc.policies), len(tcpFilters))
+			}
+			for i, filter := range tcpFilters {
+				if filter.Name != rbacTCPFilterName {
+					t.Errorf("filter %d: expecting filter name %s, but got %s", i, rbacTCPFilterName, filter.Name)
+				}
+				rbacConfig := &network_config.RBAC{}
+				if err := rbacConfig.Unmarshal(filter.GetTypedConfig().GetValue()); err != nil {
+					t.Fatalf("filter %d: bad rbac config: %v", i, err)
+				}
+				wantStatPrefix := rbacTCPFilterStatPrefix
+				if i < len(tc.policies)-1 {
+					wantStatPrefix = rbacTCPDenyFilterStatPrefix
+				}
+				if rbacConfig.StatPrefix != wantStatPrefix {
+					t.Errorf("filter %d: expecting stat prefix %s but got %s", i, wantStatPrefix, rbacConfig.StatPrefix)
+				}
+				checkRules(i, rbacConfig.Rules)
+			}
+		})
+	}
+}
+
 func TestConvertRbacRulesToFilterConfig(t *testing.T) {
 	roles := []model.Config{
 		{
@@ -1950,3 +2054,34 @@ func TestPrincipalForProperty(t *testing.T) {
 		}
 	}
 }
+
+func TestPrincipalForSourcePrincipalWithTrustDomainAliases(t *testing.T) {
+	oldTrustDomain := spiffe.GetTrustDomain()
+	defer spiffe.SetTrustDomain(oldTrustDomain)
+	defer spiffe.SetTrustDomainAliases(nil)
+	spiffe.SetTrustDomain("new.td")
+	spiffe.SetTrustDomainAliases([]string{"old.td"})
+
+	cases := []struct {
+		v    string
+		tcp  bool
+		want []string
+	}{
+		{v: "old.td/ns/foo/sa/bar", want: []string{"old.td/ns/foo/sa/bar", "new.td/ns/foo/sa/bar"}},
+		{v: "new.td/ns/foo/sa/bar", tcp: true, want: []string{"new.td/ns/foo/sa/bar", "old.td/ns/foo/sa/bar"}},
+		{v: "other.td/ns/foo/sa/bar", want: []string{"other.td/ns/foo/sa/bar"}},
+	}
+	for _, tc := range cases {
+		pg := rbacfilter.PrincipalGenerator{}
+		for _, v := range tc.want {
+			pg.Append(principalForSourcePrincipal(v, tc.tcp))
+		}
+		want := pg.OrPrincipals()
+		if len(tc.want) == 1 {
+			want = principalForSourcePrincipal(tc.want[0], tc.tcp)
+		}
+		if got := principalForKeyValue(attrSrcPrincipal, tc.v, tc.tcp); !reflect.DeepEqual(got, want) {
+			t.Errorf("(%s, tcp %v):\nwant: %v\n got: %v", tc.v, tc.tcp, want, got)
+		}
+	}
+}
@@ -25,6 +25,7 @@
 // * Deprecate ServiceRoleBinding. Only support two CRDs: ServiceRole and AuthorizationPolicy.
 // * Allow multiple bindings and roles in one CRD, i.e. Authorization.
 // * Support workload selector.
+// * Support DENY policies, enforced by a separate RBAC filter placed before the ALLOW one.
 package authz
 
 import (
@@ -70,29 +71,65 @@ func roleForBinding(binding *rbacproto.ServiceRoleBinding, namespace string, ap
 // convertRbacRulesToFilterConfigV2 is the successor of convertRbacRulesToFilterConfig, which supports
 // converting AuthorizationPolicy.
 func convertRbacRulesToFilterConfigV2(service *serviceMetadata, option rbacOption) *http_config.RBAC {
-	rbac := &policyproto.RBAC{
-		Action:   policyproto.RBAC_ALLOW,
+	return convertToFilterConfigV2(service, option, policyproto.RBAC_ALLOW)
+}
+
+// convertDenyRulesToFilterConfigV2 converts the AuthorizationPolicies with the DENY action to the
+// config of a RBAC filter that must be evaluated before the one of convertRbacRulesToFilterConfigV2.
+// It returns nil if no DENY policy applies to the service.
+func convertDenyRulesToFilterConfigV2(service *serviceMetadata, option rbacOption) *http_config.RBAC {
+	config := convertToFilterConfigV2(service, option, policyproto.RBAC_DENY)
+	if len(config.GetRules().GetPolicies()) == 0 && len(config.GetShadowRules().GetPolicies()) == 0 {
+		return nil
+	}
+	return config
+}
+
+func convertToFilterConfigV2(service *serviceMetadata, option rbacOption, action policyproto.RBAC_Action) *http_config.RBAC {
+	enforcedConfig := &policyproto.RBAC{
+		Action:   action,
+		Policies: map[string]*policyproto.Policy{},
+	}
+	permissiveConfig := &policyproto.RBAC{
+		Action:   action,
 		Policies: map[string]*policyproto.Policy{},
 	}
+	setPoliciesV2(enforcedConfig, permissiveConfig, service, option)
 
+	// If RBAC Config is set to permissive mode globally, RBAC is transparent to users;
+	// when mapping to rbac filter config, there is only shadow rules (no normal rules).
 	if option.globalPermissiveMode {
-		// Permissive Mode will be ignored.
-		rbacLog.Warnf("Permissive mode is not supported for service %s.", service.name)
-		// TODO(pitlv2109): Handle permissive mode in the future.
-		return &http_config.RBAC{Rules: rbac}
+		return &http_config.RBAC{ShadowRules: permissiveConfig}
 	}
 
+	ret := &http_config.RBAC{Rules: enforcedConfig}
+	// Set ShadowRules only when there is a binding in permissive (dry-run) mode. Otherwise, non-empty
+	// shadow_rules causes permissive attributes to be sent to mixer.
+	if len(permissiveConfig.Policies) > 0 {
+		ret.ShadowRules = permissiveConfig
+	}
+	return ret
+}
+
+// setPoliciesV2 generates the policies of the AuthorizationPolicies selecting the service, whose
+// action is the one of enforcedConfig. The policies of bindings in permissive mode are added to
+// permissiveConfig, the others to enforcedConfig.
+func setPoliciesV2(enforcedConfig, permissiveConfig *policyproto.RBAC, service *serviceMetadata, option rbacOption) {
 	namespace := service.attributes[attrDestNamespace]
 	if option.authzPolicies == nil || option.authzPolicies.NamespaceToAuthorizationConfigV2 == nil {
-		return &http_config.RBAC{Rules: rbac}
+		return
 	}
 	var authorizationConfigV2FromNamespace, present = option.authzPolicies.NamespaceToAuthorizationConfigV2[namespace]
 	if !present {
-		return &http_config.RBAC{Rules: rbac}
+		return
 	}
+	deny := enforcedConfig.Action == policyproto.RBAC_DENY
 	// Get all AuthorizationPolicy Istio config from this namespace.
 	allAuthzPolicies := authorizationConfigV2FromNamespace.AuthzPolicies
 	for _, authzPolicy := range allAuthzPolicies {
+		if authzPolicy.Deny != deny {
+			continue
+		}
 		workloadLabels := model.LabelsCollection{service.labels}
 		policySelector := authzPolicy.Policy.WorkloadSelector.GetLabels()
 		if !(workloadLabels.IsSupersetOf(policySelector)) {
@@ -105,13 +142,22 @@ func convertRbacRulesToFilterConfigV2(service *serviceMetadata, option rbacOptio
 			roleName, role := roleForBinding(binding, namespace, option.authzPolicies)
 			// TODO: optimize for multiple bindings referring to the same role.
 			m := NewModel(role, []*rbacproto.ServiceRoleBinding{binding})
-			policy := m.Generate(service, option.forTCPFilter)
-			if policy != nil {
-				rbacLog.Debugf("generated config for role: %s", roleName)
-				policyName := fmt.Sprintf("authz-policy-%s-allow[%d]", authzPolicy.Name, i)
-				rbac.Policies[policyName] = policy
+			policy := m.Generate(service, option.forTCPFilter, deny)
+			if policy == nil {
+				continue
+			}
+			rbacLog.Debugf("generated config for role: %s", roleName)
+			policyName := fmt.Sprintf("authz-policy-%s-allow[%d]", authzPolicy.Name, i)
+			if deny {
+				policyName = fmt.Sprintf("authz-policy-%s-deny[%d]", authzPolicy.Name, i)
+			}
+			if binding.Mode == rbacproto.EnforcementMode_PERMISSIVE || option.globalPermissiveMode {
+				// If RBAC Config is set to permissive mode globally, all policies will be in
+				// permissive mode regardless its own mode.
+				permissiveConfig.Policies[policyName] = policy
+			} else {
+				enforcedConfig.Policies[policyName] = policy
 			}
 		}
 	}
-	return &http_config.RBAC{Rules: rbac}
 }
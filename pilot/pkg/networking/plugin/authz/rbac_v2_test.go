@@ -6,10 +6,12 @@ import (
 	"testing"
 
 	"istio.io/istio/pilot/pkg/networking/plugin/authz/matcher"
+	"istio.io/istio/pilot/pkg/networking/plugin/authz/rbacfilter"
 
 	authn_v1alpha1 "istio.io/istio/pilot/pkg/security/authn/v1alpha1"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	http_config "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/rbac/v2"
 	policy "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v2alpha"
 	metadata "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
 
@@ -357,3 +359,270 @@ func TestConvertRbacRulesToFilterConfigV2(t *testing.T) {
 		}
 	}
 }
+
+func TestConvertRbacRulesToFilterConfigV2DryRun(t *testing.T) {
+	roles := []model.Config{
+		{
+			ConfigMeta: model.ConfigMeta{Name: "service-role-1"},
+			Spec:       generateServiceRole([]string{"service"}, []string{"GET"}),
+		},
+	}
+	newPolicy := func(name string, annotations map[string]string, modes ...rbacproto.EnforcementMode) model.Config {
+		authzPolicy := &rbacproto.AuthorizationPolicy{}
+		for i, mode := range modes {
+			authzPolicy.Allow = append(authzPolicy.Allow, &rbacproto.ServiceRoleBinding{
+				Subjects: []*rbacproto.Subject{{User: fmt.Sprintf("user%d", i+1)}},
+				RoleRef:  &rbacproto.RoleRef{Kind: "ServiceRole", Name: "service-role-1"},
+				Mode:     mode,
+			})
+		}
+		return model.Config{
+			ConfigMeta: model.ConfigMeta{Name: name, Annotations: annotations},
+			Spec:       authzPolicy,
+		}
+	}
+	dryRun := map[string]string{model.AuthorizationDryRunAnnotation: "true"}
+	enforced := rbacproto.EnforcementMode_ENFORCED
+	permissive := rbacproto.EnforcementMode_PERMISSIVE
+
+	emptyRbac := generateExpectRBACForSinglePolicy("", nil)
+	user1Policy := &policy.Policy{
+		Permissions: []*policy.Permission{generatePermission(":method", "GET")},
+		Principals:  []*policy.Principal{generatePrincipal("user1")},
+	}
+	user2Policy := &policy.Policy{
+		Permissions: []*policy.Permission{generatePermission(":method", "GET")},
+		Principals:  []*policy.Principal{generatePrincipal("user2")},
+	}
+
+	testCases := []struct {
+		name                 string
+		policy               model.Config
+		globalPermissiveMode bool
+		expectRules          *policy.RBAC
+		expectShadowRules    *policy.RBAC
+	}{
+		{
+			name:        "enforced",
+			policy:      newPolicy("policy", nil, enforced),
+			expectRules: generateExpectRBACForSinglePolicy("authz-policy-policy-allow[0]", user1Policy),
+		},
+		{
+			name:              "dry-run",
+			policy:            newPolicy("policy", dryRun, enforced),
+			expectRules:       emptyRbac,
+			expectShadowRules: generateExpectRBACForSinglePolicy("authz-policy-policy-allow[0]", user1Policy),
+		},
+		{
+			name:              "permissive binding",
+			policy:            newPolicy("policy", nil, enforced, permissive),
+			expectRules:       generateExpectRBACForSinglePolicy("authz-policy-policy-allow[0]", user1Policy),
+			expectShadowRules: generateExpectRBACForSinglePolicy("authz-policy-policy-allow[1]", user2Policy),
+		},
+		{
+			name:                 "global permissive",
+			policy:               newPolicy("policy", nil, enforced, permissive),
+			globalPermissiveMode: true,
+			expectShadowRules: generateExpectRBACWithAuthzPolicyKeysAndRbacPolicies(
+				[]string{"authz-policy-policy-allow[0]", "authz-policy-policy-allow[1]"},
+				[]*policy.Policy{user1Policy, user2Policy}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			option := rbacOption{
+				authzPolicies:        newAuthzPoliciesWithRolesAndBindings(roles, []model.Config{tc.policy}),
+				globalPermissiveMode: tc.globalPermissiveMode,
+			}
+			option.authzPolicies.IsRbacV2 = true
+			rbac := convertRbacRulesToFilterConfigV2(&serviceMetadata{name: "service"}, option)
+			if !reflect.DeepEqual(tc.expectRules, rbac.Rules) {
+				t.Errorf("rules want:\n%v\nbut got:\n%v", tc.expectRules, rbac.Rules)
+			}
+			if !reflect.DeepEqual(tc.expectShadowRules, rbac.ShadowRules) {
+				t.Errorf("shadow rules want:\n%v\nbut got:\n%v", tc.expectShadowRules, rbac.ShadowRules)
+			}
+		})
+	}
+
+	// The shadow rules of a policy in dry-run mode must be the rules of the same policy when enforced,
+	// so that the shadow decisions are the ones that would be enforced.
+	enforcedOption := rbacOption{authzPolicies: newAuthzPoliciesWithRolesAndBindings(roles, []model.Config{newPolicy("policy", nil, enforced)})}
+	dryRunOption := rbacOption{authzPolicies: newAuthzPoliciesWithRolesAndBindings(roles, []model.Config{newPolicy("policy", dryRun, enforced)})}
+	enforcedRbac := convertRbacRulesToFilterConfigV2(&serviceMetadata{name: "service"}, enforcedOption)
+	dryRunRbac := convertRbacRulesToFilterConfigV2(&serviceMetadata{name: "service"}, dryRunOption)
+	if !reflect.DeepEqual(enforcedRbac.Rules, dryRunRbac.ShadowRules) {
+		t.Errorf("dry-run shadow rules %v differ from enforced rules %v", dryRunRbac.ShadowRules, enforcedRbac.Rules)
+	}
+}
+
+func TestConvertDenyRulesToFilterConfigV2(t *testing.T) {
+	const opsPrincipal = "cluster.local/ns/default/sa/ops"
+	roles := []model.Config{
+		{
+			ConfigMeta: model.ConfigMeta{Name: "admin", Namespace: "default"},
+			Spec: &rbacproto.ServiceRole{
+				Rules: []*rbacproto.AccessRule{{Paths: []string{"/admin*"}}},
+			},
+		},
+		{
+			ConfigMeta: model.ConfigMeta{Name: "port", Namespace: "default"},
+			Spec: &rbacproto.ServiceRole{
+				Rules: []*rbacproto.AccessRule{{Ports: []int32{9000}, NotPaths: []string{"/healthz"}}},
+			},
+		},
+	}
+	newPolicy := func(name string, annotations map[string]string, selector map[string]string, binding *rbacproto.ServiceRoleBinding) model.Config {
+		return model.Config{
+			ConfigMeta: model.ConfigMeta{Name: name, Namespace: "default", Annotations: annotations},
+			Spec: &rbacproto.AuthorizationPolicy{
+				WorkloadSelector: &rbacproto.WorkloadSelector{Labels: selector},
+				Allow:            []*rbacproto.ServiceRoleBinding{binding},
+			},
+		}
+	}
+	deny := map[string]string{model.AuthorizationActionAnnotation: model.AuthorizationActionDeny}
+	denyDryRun := map[string]string{
+		model.AuthorizationActionAnnotation: model.AuthorizationActionDeny,
+		model.AuthorizationDryRunAnnotation: "true",
+	}
+	exceptOps := &rbacproto.ServiceRoleBinding{
+		Subjects: []*rbacproto.Subject{{NotNames: []string{opsPrincipal}}},
+		RoleRef:  &rbacproto.RoleRef{Kind: "ServiceRole", Name: "admin"},
+	}
+	outsideNetworkOrUser := &rbacproto.ServiceRoleBinding{
+		Subjects: []*rbacproto.Subject{
+			{NotIps: []string{"10.0.0.0/8"}},
+			{Properties: map[string]string{attrRequestPrincipal: "https://accounts.example.com/user"}},
+		},
+		RoleRef: &rbacproto.RoleRef{Kind: "ServiceRole", Name: "port"},
+	}
+	allUsersGet := &rbacproto.ServiceRoleBinding{
+		Subjects: []*rbacproto.Subject{{Names: []string{allUsers}}},
+		Actions:  []*rbacproto.AccessRule{{Methods: []string{"GET"}}},
+	}
+
+	and := func(permissions ...*policy.Permission) *policy.Permission {
+		pg := rbacfilter.PermissionGenerator{}
+		for _, p := range permissions {
+			pg.Append(p)
+		}
+		return pg.AndPermissions()
+	}
+	andPrincipal := func(principals ...*policy.Principal) *policy.Principal {
+		pg := rbacfilter.PrincipalGenerator{}
+		for _, p := range principals {
+			pg.Append(p)
+		}
+		return pg.AndPrincipals()
+	}
+	adminPaths := permissionForKeyValues(pathHeader, []string{"/admin*"})
+	notOps := rbacfilter.PrincipalNot(principalForKeyValues(attrSrcPrincipal, []string{opsPrincipal}, false))
+	exceptOpsPolicy := &policy.Policy{
+		Permissions: []*policy.Permission{and(adminPaths)},
+		Principals:  []*policy.Principal{andPrincipal(notOps)},
+	}
+	outsideNetworkOrUserPolicy := func(forTCPFilter bool) *policy.Policy {
+		port := permissionForKeyValues(attrDestPort, []string{"9000"})
+		notIPs := andPrincipal(rbacfilter.PrincipalNot(principalForKeyValues(attrSrcIP, []string{"10.0.0.0/8"}, forTCPFilter)))
+		if forTCPFilter {
+			// The path and the request principal are ignored by the TCP filter.
+			return &policy.Policy{
+				Permissions: []*policy.Permission{and(port)},
+				Principals:  []*policy.Principal{notIPs, rbacfilter.PrincipalAny(true)},
+			}
+		}
+		notHealthz := rbacfilter.PermissionNot(permissionForKeyValues(pathHeader, []string{"/healthz"}))
+		user := principalForKeyValue(attrRequestPrincipal, "https://accounts.example.com/user", false)
+		return &policy.Policy{
+			Permissions: []*policy.Permission{and(notHealthz, port)},
+			Principals:  []*policy.Principal{notIPs, andPrincipal(user)},
+		}
+	}
+	allUsersGetPolicy := &policy.Policy{
+		Permissions: []*policy.Permission{and(permissionForKeyValues(methodHeader, []string{"GET"}))},
+		Principals:  []*policy.Principal{andPrincipal(principalForKeyValues(attrSrcPrincipal, []string{allUsers}, false))},
+	}
+	newRBAC := func(action policy.RBAC_Action, policies map[string]*policy.Policy) *policy.RBAC {
+		return &policy.RBAC{Action: action, Policies: policies}
+	}
+
+	service := &serviceMetadata{
+		name:       "productpage.default",
+		labels:     map[string]string{"app": "productpage"},
+		attributes: map[string]string{attrDestName: "productpage", attrDestNamespace: "default"},
+	}
+
+	testCases := []struct {
+		name         string
+		policies     []model.Config
+		forTCPFilter bool
+		expectDeny   *http_config.RBAC
+		expectAllow  *policy.RBAC
+	}{
+		{
+			name:        "no deny policy",
+			policies:    []model.Config{newPolicy("allow", nil, nil, allUsersGet)},
+			expectAllow: generateExpectRBACForSinglePolicy("authz-policy-allow-allow[0]", allUsersGetPolicy),
+		},
+		{
+			name: "deny admin except ops",
+			policies: []model.Config{
+				newPolicy("deny-admin", deny, nil, exceptOps),
+				newPolicy("allow", nil, nil, allUsersGet),
+			},
+			expectDeny: &http_config.RBAC{
+				Rules: newRBAC(policy.RBAC_DENY, map[string]*policy.Policy{"authz-policy-deny-admin-deny[0]": exceptOpsPolicy}),
+			},
+			expectAllow: generateExpectRBACForSinglePolicy("authz-policy-allow-allow[0]", allUsersGetPolicy),
+		},
+		{
+			name:     "deny with not_ips and request principal",
+			policies: []model.Config{newPolicy("deny-port", deny, map[string]string{"app": "productpage"}, outsideNetworkOrUser)},
+			expectDeny: &http_config.RBAC{
+				Rules: newRBAC(policy.RBAC_DENY, map[string]*policy.Policy{"authz-policy-deny-port-deny[0]": outsideNetworkOrUserPolicy(false)}),
+			},
+			expectAllow: generateExpectRBACForSinglePolicy("", nil),
+		},
+		{
+			name:         "deny for TCP filter",
+			policies:     []model.Config{newPolicy("deny-port", deny, nil, outsideNetworkOrUser)},
+			forTCPFilter: true,
+			expectDeny: &http_config.RBAC{
+				Rules: newRBAC(policy.RBAC_DENY, map[string]*policy.Policy{"authz-policy-deny-port-deny[0]": outsideNetworkOrUserPolicy(true)}),
+			},
+			expectAllow: generateExpectRBACForSinglePolicy("", nil),
+		},
+		{
+			name:        "deny with selector not matched",
+			policies:    []model.Config{newPolicy("deny-admin", deny, map[string]string{"app": "reviews"}, exceptOps)},
+			expectAllow: generateExpectRBACForSinglePolicy("", nil),
+		},
+		{
+			name:     "deny in dry-run mode",
+			policies: []model.Config{newPolicy("deny-admin", denyDryRun, nil, exceptOps)},
+			expectDeny: &http_config.RBAC{
+				Rules:       newRBAC(policy.RBAC_DENY, map[string]*policy.Policy{}),
+				ShadowRules: newRBAC(policy.RBAC_DENY, map[string]*policy.Policy{"authz-policy-deny-admin-deny[0]": exceptOpsPolicy}),
+			},
+			expectAllow: generateExpectRBACForSinglePolicy("", nil),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			option := rbacOption{
+				authzPolicies: newAuthzPoliciesWithRolesAndBindings(roles, tc.policies),
+				forTCPFilter:  tc.forTCPFilter,
+			}
+			option.authzPolicies.IsRbacV2 = true
+			if got := convertDenyRulesToFilterConfigV2(service, option); !reflect.DeepEqual(tc.expectDeny, got) {
+				t.Errorf("deny config want:\n%v\nbut got:\n%v", tc.expectDeny, got)
+			}
+			if got := convertRbacRulesToFilterConfigV2(service, option); !reflect.DeepEqual(tc.expectAllow, got.Rules) {
+				t.Errorf("allow rules want:\n%v\nbut got:\n%v", tc.expectAllow, got.Rules)
+			}
+		})
+	}
+}
@@ -41,6 +41,8 @@ const (
 	Authz = "authz"
 	// Health is the name of the health plugin passed through the command line
 	Health = "health"
+	// LocalRateLimit is the name of the local rate limit plugin passed through the command line
+	LocalRateLimit = "localratelimit"
 	// Mixer is the name of the mixer plugin passed through the command line
 	Mixer = "mixer"
 )
@@ -0,0 +1,318 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit implements a plugin generating the configuration of the Envoy local rate limit
+// filter from LocalRateLimit resources, so that proxies can enforce coarse rate limits without Mixer.
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/pilot/pkg/model"
+	config "istio.io/istio/pilot/pkg/model/ratelimit"
+	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/features/pilot"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// LocalRateLimitFilterName is the name of the Envoy local rate limit HTTP filter.
+	LocalRateLimitFilterName = "envoy.filters.http.local_ratelimit"
+
+	// statPrefix is the prefix of the stats emitted by the local rate limit filter.
+	statPrefix = "local_rate_limit"
+)
+
+// Plugin implements local rate limiting.
+type Plugin struct{}
+
+// NewPlugin returns an instance of the local rate limit plugin.
+func NewPlugin() plugin.Plugin {
+	return Plugin{}
+}
+
+// localRateLimitForProxy returns the LocalRateLimit applying to the proxy, if any. When several
+// resources select the proxy, the oldest one wins. None applies unless the filter is enabled, since
+// the bundled proxy does not have it.
+func localRateLimitForProxy(in *plugin.InputParams) *model.Config {
+	if !pilot.EnableLocalRateLimit() {
+		return nil
+	}
+	if in.Env == nil || in.Env.IstioConfigStore == nil || in.Node == nil {
+		return nil
+	}
+	configs, err := in.Env.IstioConfigStore.List(model.LocalRateLimit.Type, in.Node.ConfigNamespace)
+	if err != nil {
+		log.Warnf("unable to fetch local rate limits: %v", err)
+		return nil
+	}
+	sort.SliceStable(configs, func(i, j int) bool {
+		if configs[i].CreationTimestamp.Equal(configs[j].CreationTimestamp) {
+			return configs[i].Name < configs[j].Name
+		}
+		return configs[i].CreationTimestamp.Before(configs[j].CreationTimestamp)
+	})
+	for i := range configs {
+		rule := configs[i].Spec.(*config.LocalRateLimit)
+		if in.Node.WorkloadLabels.IsSupersetOf(model.Labels(rule.WorkloadLabels)) {
+			return &configs[i]
+		}
+	}
+	return nil
+}
+
+// OnOutboundListener is called whenever a new outbound listener is added to the LDS output for a given service
+// Can be used to add additional filters on the outbound path
+func (Plugin) OnOutboundListener(in *plugin.InputParams, mutable *plugin.MutableObjects) error {
+	if in.Node.Type != model.Router {
+		// Sidecars only rate limit their inbound traffic.
+		return nil
+	}
+	return buildFilter(in, mutable)
+}
+
+// OnInboundListener is called whenever a new listener is added to the LDS output for a given service
+// Can be used to add additional filters (e.g., mixer filter) or add more stuff to the HTTP connection manager
+// on the inbound path
+func (Plugin) OnInboundListener(in *plugin.InputParams, mutable *plugin.MutableObjects) error {
+	if in.Node.Type != model.SidecarProxy {
+		return nil
+	}
+	return buildFilter(in, mutable)
+}
+
+func buildFilter(in *plugin.InputParams, mutable *plugin.MutableObjects) error {
+	cfg := localRateLimitForProxy(in)
+	if cfg == nil {
+		return nil
+	}
+	if mutable.Listener == nil || (len(mutable.Listener.FilterChains) != len(mutable.FilterChains)) {
+		return fmt.Errorf("expected same number of filter chains in listener (%d) and mutable (%d)", len(mutable.Listener.FilterChains), len(mutable.FilterChains))
+	}
+	rule := cfg.Spec.(*config.LocalRateLimit)
+	filter := buildLocalRateLimitFilter(rule.TokenBucket, rule.Descriptors, util.IsXDSMarshalingToAnyEnabled(in.Node))
+	for i := range mutable.Listener.FilterChains {
+		if in.ListenerProtocol == plugin.ListenerProtocolHTTP || mutable.FilterChains[i].ListenerProtocol == plugin.ListenerProtocolHTTP {
+			mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP, filter)
+		}
+	}
+	return nil
+}
+
+// OnInboundCluster implements the Plugin interface method.
+func (Plugin) OnInboundCluster(in *plugin.InputParams, cluster *xdsapi.Cluster) {
+}
+
+// OnOutboundRouteConfiguration implements the Plugin interface method.
+func (Plugin) OnOutboundRouteConfiguration(in *plugin.InputParams, routeConfiguration *xdsapi.RouteConfiguration) {
+	if in.Node.Type != model.Router {
+		return
+	}
+	cfg := localRateLimitForProxy(in)
+	if cfg == nil {
+		return
+	}
+	rule := cfg.Spec.(*config.LocalRateLimit)
+	modifyRoutes(in, routeConfiguration, rule, func(r *route.Route) *config.RouteRateLimit {
+		for _, override := range rule.Routes {
+			if override.VirtualService != "" && routeFromVirtualService(r, cfg.Namespace, override.VirtualService) {
+				return override
+			}
+		}
+		return nil
+	})
+}
+
+// OnInboundRouteConfiguration implements the Plugin interface method.
+func (Plugin) OnInboundRouteConfiguration(in *plugin.InputParams, routeConfiguration *xdsapi.RouteConfiguration) {
+	if in.Node.Type != model.SidecarProxy || in.ServiceInstance == nil {
+		return
+	}
+	cfg := localRateLimitForProxy(in)
+	if cfg == nil {
+		return
+	}
+	rule := cfg.Spec.(*config.LocalRateLimit)
+	port := uint32(in.ServiceInstance.Endpoint.ServicePort.Port)
+	modifyRoutes(in, routeConfiguration, rule, func(*route.Route) *config.RouteRateLimit {
+		for _, override := range rule.Routes {
+			if override.Port == port {
+				return override
+			}
+		}
+		return nil
+	})
+}
+
+// modifyRoutes sets the rate limit actions producing the descriptors of the routes, and the per
+// route configuration of the routes whose limits are overridden.
+func modifyRoutes(in *plugin.InputParams, routeConfiguration *xdsapi.RouteConfiguration, rule *config.LocalRateLimit,
+	overrideFor func(*route.Route) *config.RouteRateLimit) {
+	isXDSMarshalingToAnyEnabled := util.IsXDSMarshalingToAnyEnabled(in.Node)
+	for i := range routeConfiguration.VirtualHosts {
+		host := &routeConfiguration.VirtualHosts[i]
+		for j := range host.Routes {
+			r := &host.Routes[j]
+			descriptors := rule.Descriptors
+			if override := overrideFor(r); override != nil {
+				descriptors = override.Descriptors
+				perRouteConfig := buildLocalRateLimitConfig(override.TokenBucket, override.Descriptors)
+				if isXDSMarshalingToAnyEnabled {
+					if r.TypedPerFilterConfig == nil {
+						r.TypedPerFilterConfig = make(map[string]*types.Any)
+					}
+					r.TypedPerFilterConfig[LocalRateLimitFilterName] = util.MessageToAny(perRouteConfig)
+				} else {
+					if r.PerFilterConfig == nil {
+						r.PerFilterConfig = make(map[string]*types.Struct)
+					}
+					r.PerFilterConfig[LocalRateLimitFilterName] = perRouteConfig
+				}
+			}
+			if action := r.GetRoute(); action != nil {
+				action.RateLimits = append(action.RateLimits, buildRateLimitActions(descriptors)...)
+			}
+		}
+	}
+}
+
+// routeFromVirtualService returns true if the route was generated from the given virtual service.
+func routeFromVirtualService(r *route.Route, namespace, name string) bool {
+	configInfo := r.GetMetadata().GetFilterMetadata()[util.IstioMetadataKey].GetFields()["config"].GetStringValue()
+	return strings.HasSuffix(configInfo, fmt.Sprintf("/namespaces/%s/%s/%s", namespace, model.VirtualService.Type, name))
+}
+
+// OnOutboundCluster implements the Plugin interface method.
+func (Plugin) OnOutboundCluster(in *plugin.InputParams, cluster *xdsapi.Cluster) {
+}
+
+// OnInboundFilterChains is called whenever a plugin needs to setup the filter chains, including relevant filter chain configuration.
+func (Plugin) OnInboundFilterChains(in *plugin.InputParams) []plugin.FilterChain {
+	return nil
+}
+
+// buildLocalRateLimitFilter returns the local rate limit HTTP filter.
+func buildLocalRateLimitFilter(bucket *config.TokenBucket, descriptors []*config.Descriptor, isXDSMarshalingToAnyEnabled bool) *http_conn.HttpFilter {
+	filterConfig := buildLocalRateLimitConfig(bucket, descriptors)
+	out := &http_conn.HttpFilter{
+		Name: LocalRateLimitFilterName,
+	}
+	if isXDSMarshalingToAnyEnabled {
+		out.ConfigType = &http_conn.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(filterConfig)}
+	} else {
+		out.ConfigType = &http_conn.HttpFilter_Config{Config: filterConfig}
+	}
+	return out
+}
+
+// buildLocalRateLimitConfig returns the config of the local rate limit filter. The filter API is not
+// part of the go-control-plane version in use, so the config is built as a struct, which Envoy converts.
+func buildLocalRateLimitConfig(bucket *config.TokenBucket, descriptors []*config.Descriptor) *types.Struct {
+	enabled := structValue(map[string]*types.Value{
+		"default_value": structValue(map[string]*types.Value{
+			"numerator":   numberValue(100),
+			"denominator": stringValue("HUNDRED"),
+		}),
+	})
+	out := &types.Struct{
+		Fields: map[string]*types.Value{
+			"stat_prefix":     stringValue(statPrefix),
+			"token_bucket":    tokenBucketValue(bucket),
+			"filter_enabled":  enabled,
+			"filter_enforced": enabled,
+		},
+	}
+	if len(descriptors) > 0 {
+		values := make([]*types.Value, 0, len(descriptors))
+		for _, descriptor := range descriptors {
+			values = append(values, structValue(map[string]*types.Value{
+				"entries": listValue(structValue(map[string]*types.Value{
+					"key":   stringValue(descriptorKey(descriptor.Header)),
+					"value": stringValue(descriptor.Value),
+				})),
+				"token_bucket": tokenBucketValue(descriptor.TokenBucket),
+			}))
+		}
+		out.Fields["descriptors"] = listValue(values...)
+	}
+	return out
+}
+
+// buildRateLimitActions returns the route rate limit actions producing the descriptors of the
+// requests, one action per header.
+func buildRateLimitActions(descriptors []*config.Descriptor) []*route.RateLimit {
+	seen := make(map[string]bool)
+	var out []*route.RateLimit
+	for _, descriptor := range descriptors {
+		key := descriptorKey(descriptor.Header)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, &route.RateLimit{
+			Actions: []*route.RateLimit_Action{{
+				ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+					RequestHeaders: &route.RateLimit_Action_RequestHeaders{
+						HeaderName:    descriptor.Header,
+						DescriptorKey: key,
+					},
+				},
+			}},
+		})
+	}
+	return out
+}
+
+// descriptorKey returns the descriptor entry key for the header. Header names are case insensitive.
+func descriptorKey(header string) string {
+	return strings.ToLower(header)
+}
+
+func tokenBucketValue(bucket *config.TokenBucket) *types.Value {
+	tokensPerFill := bucket.TokensPerFill
+	if tokensPerFill == 0 {
+		tokensPerFill = 1
+	}
+	interval, _ := types.DurationFromProto(bucket.FillInterval)
+	return structValue(map[string]*types.Value{
+		"max_tokens":      numberValue(float64(bucket.MaxTokens)),
+		"tokens_per_fill": numberValue(float64(tokensPerFill)),
+		"fill_interval":   stringValue(strconv.FormatFloat(interval.Seconds(), 'f', -1, 64) + "s"),
+	})
+}
+
+func stringValue(s string) *types.Value {
+	return &types.Value{Kind: &types.Value_StringValue{StringValue: s}}
+}
+
+func numberValue(n float64) *types.Value {
+	return &types.Value{Kind: &types.Value_NumberValue{NumberValue: n}}
+}
+
+func structValue(fields map[string]*types.Value) *types.Value {
+	return &types.Value{Kind: &types.Value_StructValue{StructValue: &types.Struct{Fields: fields}}}
+}
+
+func listValue(values ...*types.Value) *types.Value {
+	return &types.Value{Kind: &types.Value_ListValue{ListValue: &types.ListValue{Values: values}}}
+}
@@ -0,0 +1,360 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	config "istio.io/istio/pilot/pkg/model/ratelimit"
+	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+var (
+	defaultBucket = &config.TokenBucket{
+		MaxTokens:     200,
+		TokensPerFill: 100,
+		FillInterval:  types.DurationProto(time.Second),
+	}
+	freeTierBucket = &config.TokenBucket{
+		MaxTokens:    10,
+		FillInterval: types.DurationProto(500 * time.Millisecond),
+	}
+	routeBucket = &config.TokenBucket{
+		MaxTokens:     20,
+		TokensPerFill: 20,
+		FillInterval:  types.DurationProto(time.Minute),
+	}
+
+	reviewsRateLimit = &config.LocalRateLimit{
+		WorkloadLabels: map[string]string{"app": "reviews"},
+		TokenBucket:    defaultBucket,
+		Descriptors: []*config.Descriptor{{
+			Header:      "X-User-Tier",
+			Value:       "free",
+			TokenBucket: freeTierBucket,
+		}},
+		Routes: []*config.RouteRateLimit{
+			{Port: 9080, TokenBucket: routeBucket},
+			{VirtualService: "reviews-canary", TokenBucket: routeBucket},
+		},
+	}
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("PILOT_ENABLE_LOCAL_RATE_LIMIT", "true")
+	os.Exit(m.Run())
+}
+
+func newInputParams(t *testing.T, nodeType model.NodeType, labels map[string]string, rules ...*config.LocalRateLimit) *plugin.InputParams {
+	t.Helper()
+	store := model.MakeIstioStore(memory.Make(model.IstioConfigTypes))
+	for i, rule := range rules {
+		if _, err := store.Create(model.Config{
+			ConfigMeta: model.ConfigMeta{
+				Type:              model.LocalRateLimit.Type,
+				Name:              fmt.Sprintf("rule%d", i),
+				Namespace:         "default",
+				CreationTimestamp: time.Unix(int64(i), 0),
+			},
+			Spec: rule,
+		}); err != nil {
+			t.Fatalf("failed to create local rate limit: %v", err)
+		}
+	}
+	return &plugin.InputParams{
+		ListenerProtocol: plugin.ListenerProtocolHTTP,
+		Env:              &model.Environment{IstioConfigStore: store},
+		Node: &model.Proxy{
+			Type:            nodeType,
+			ConfigNamespace: "default",
+			WorkloadLabels:  model.LabelsCollection{labels},
+			Metadata:        map[string]string{"ISTIO_PROXY_VERSION": "1.1"},
+		},
+		ServiceInstance: &model.ServiceInstance{
+			Endpoint: model.NetworkEndpoint{
+				ServicePort: &model.Port{Name: "http", Port: 9080, Protocol: model.ProtocolHTTP},
+			},
+		},
+	}
+}
+
+func newMutableObjects() *plugin.MutableObjects {
+	return &plugin.MutableObjects{
+		Listener:     &xdsapi.Listener{FilterChains: []listener.FilterChain{{}}},
+		FilterChains: []plugin.FilterChain{{}},
+	}
+}
+
+func filterConfig(t *testing.T, mutable *plugin.MutableObjects) *types.Struct {
+	t.Helper()
+	if len(mutable.FilterChains[0].HTTP) != 1 {
+		t.Fatalf("expected 1 filter, got %v", mutable.FilterChains[0].HTTP)
+	}
+	filter := mutable.FilterChains[0].HTTP[0]
+	if filter.Name != LocalRateLimitFilterName {
+		t.Fatalf("expected filter %s, got %s", LocalRateLimitFilterName, filter.Name)
+	}
+	out := &types.Struct{}
+	if err := types.UnmarshalAny(filter.GetTypedConfig(), out); err != nil {
+		t.Fatalf("failed to read filter config: %v", err)
+	}
+	return out
+}
+
+func TestOnInboundListener(t *testing.T) {
+	cases := []struct {
+		name       string
+		nodeType   model.NodeType
+		labels     map[string]string
+		rules      []*config.LocalRateLimit
+		wantFilter bool
+	}{
+		{
+			name:       "matching workload",
+			nodeType:   model.SidecarProxy,
+			labels:     map[string]string{"app": "reviews", "version": "v1"},
+			rules:      []*config.LocalRateLimit{reviewsRateLimit},
+			wantFilter: true,
+		},
+		{
+			name:     "other workload",
+			nodeType: model.SidecarProxy,
+			labels:   map[string]string{"app": "ratings"},
+			rules:    []*config.LocalRateLimit{reviewsRateLimit},
+		},
+		{
+			name:       "namespace wide",
+			nodeType:   model.SidecarProxy,
+			labels:     map[string]string{"app": "ratings"},
+			rules:      []*config.LocalRateLimit{{TokenBucket: defaultBucket}},
+			wantFilter: true,
+		},
+		{
+			name:     "no rate limit",
+			nodeType: model.SidecarProxy,
+			labels:   map[string]string{"app": "reviews"},
+		},
+		{
+			name:     "gateway",
+			nodeType: model.Router,
+			labels:   map[string]string{"app": "reviews"},
+			rules:    []*config.LocalRateLimit{reviewsRateLimit},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			in := newInputParams(t, c.nodeType, c.labels, c.rules...)
+			mutable := newMutableObjects()
+			if err := NewPlugin().OnInboundListener(in, mutable); err != nil {
+				t.Fatalf("OnInboundListener(): %v", err)
+			}
+			if !c.wantFilter {
+				if len(mutable.FilterChains[0].HTTP) != 0 {
+					t.Errorf("expected no filter, got %v", mutable.FilterChains[0].HTTP)
+				}
+				return
+			}
+			filterConfig(t, mutable)
+		})
+	}
+}
+
+func TestOnInboundListenerDisabled(t *testing.T) {
+	os.Setenv("PILOT_ENABLE_LOCAL_RATE_LIMIT", "false")
+	defer os.Setenv("PILOT_ENABLE_LOCAL_RATE_LIMIT", "true")
+
+	in := newInputParams(t, model.SidecarProxy, map[string]string{"app": "reviews"}, reviewsRateLimit)
+	mutable := newMutableObjects()
+	if err := NewPlugin().OnInboundListener(in, mutable); err != nil {
+		t.Fatalf("OnInboundListener(): %v", err)
+	}
+	if len(mutable.FilterChains[0].HTTP) != 0 {
+		t.Errorf("expected no filter when the local rate limit is disabled, got %v", mutable.FilterChains[0].HTTP)
+	}
+}
+
+func TestOnOutboundListener(t *testing.T) {
+	in := newInputParams(t, model.Router, map[string]string{"app": "reviews"}, reviewsRateLimit)
+	mutable := newMutableObjects()
+	if err := NewPlugin().OnOutboundListener(in, mutable); err != nil {
+		t.Fatalf("OnOutboundListener(): %v", err)
+	}
+	got := filterConfig(t, mutable)
+	want := buildLocalRateLimitConfig(defaultBucket, reviewsRateLimit.Descriptors)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got filter config %v, want %v", got, want)
+	}
+
+	// Sidecars do not rate limit outbound traffic.
+	in = newInputParams(t, model.SidecarProxy, map[string]string{"app": "reviews"}, reviewsRateLimit)
+	mutable = newMutableObjects()
+	if err := NewPlugin().OnOutboundListener(in, mutable); err != nil {
+		t.Fatalf("OnOutboundListener(): %v", err)
+	}
+	if len(mutable.FilterChains[0].HTTP) != 0 {
+		t.Errorf("expected no filter, got %v", mutable.FilterChains[0].HTTP)
+	}
+}
+
+func TestBuildLocalRateLimitConfig(t *testing.T) {
+	got := buildLocalRateLimitConfig(defaultBucket, reviewsRateLimit.Descriptors)
+	fields := got.Fields
+	if prefix := fields["stat_prefix"].GetStringValue(); prefix != statPrefix {
+		t.Errorf("got stat prefix %q, want %q", prefix, statPrefix)
+	}
+	bucket := fields["token_bucket"].GetStructValue().Fields
+	if bucket["max_tokens"].GetNumberValue() != 200 || bucket["tokens_per_fill"].GetNumberValue() != 100 ||
+		bucket["fill_interval"].GetStringValue() != "1s" {
+		t.Errorf("unexpected token bucket %v", bucket)
+	}
+	for _, name := range []string{"filter_enabled", "filter_enforced"} {
+		percent := fields[name].GetStructValue().Fields["default_value"].GetStructValue().Fields
+		if percent["numerator"].GetNumberValue() != 100 || percent["denominator"].GetStringValue() != "HUNDRED" {
+			t.Errorf("expected %s to be 100%%, got %v", name, percent)
+		}
+	}
+	descriptors := fields["descriptors"].GetListValue().Values
+	if len(descriptors) != 1 {
+		t.Fatalf("expected 1 descriptor, got %v", descriptors)
+	}
+	descriptor := descriptors[0].GetStructValue().Fields
+	entry := descriptor["entries"].GetListValue().Values[0].GetStructValue().Fields
+	if entry["key"].GetStringValue() != "x-user-tier" || entry["value"].GetStringValue() != "free" {
+		t.Errorf("unexpected descriptor entry %v", entry)
+	}
+	bucket = descriptor["token_bucket"].GetStructValue().Fields
+	if bucket["max_tokens"].GetNumberValue() != 10 || bucket["tokens_per_fill"].GetNumberValue() != 1 ||
+		bucket["fill_interval"].GetStringValue() != "0.5s" {
+		t.Errorf("unexpected descriptor token bucket %v", bucket)
+	}
+}
+
+func newRouteConfiguration(routes ...route.Route) *xdsapi.RouteConfiguration {
+	return &xdsapi.RouteConfiguration{
+		VirtualHosts: []route.VirtualHost{{Routes: routes}},
+	}
+}
+
+func newRoute(virtualService string) route.Route {
+	r := route.Route{
+		Action: &route.Route_Route{Route: &route.RouteAction{}},
+	}
+	if virtualService != "" {
+		r.Metadata = util.BuildConfigInfoMetadata(model.ConfigMeta{
+			Group:     "networking.istio.io",
+			Version:   "v1alpha3",
+			Type:      model.VirtualService.Type,
+			Namespace: "default",
+			Name:      virtualService,
+		})
+	}
+	return r
+}
+
+func perRouteConfig(t *testing.T, r route.Route) *types.Struct {
+	t.Helper()
+	out := &types.Struct{}
+	if err := types.UnmarshalAny(r.TypedPerFilterConfig[LocalRateLimitFilterName], out); err != nil {
+		t.Fatalf("failed to read per route config: %v", err)
+	}
+	return out
+}
+
+var freeTierActions = []*route.RateLimit{{
+	Actions: []*route.RateLimit_Action{{
+		ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+			RequestHeaders: &route.RateLimit_Action_RequestHeaders{
+				HeaderName:    "X-User-Tier",
+				DescriptorKey: "x-user-tier",
+			},
+		},
+	}},
+}}
+
+func TestOnInboundRouteConfiguration(t *testing.T) {
+	in := newInputParams(t, model.SidecarProxy, map[string]string{"app": "reviews"}, reviewsRateLimit)
+	routeConfiguration := newRouteConfiguration(newRoute(""))
+	NewPlugin().OnInboundRouteConfiguration(in, routeConfiguration)
+
+	r := routeConfiguration.VirtualHosts[0].Routes[0]
+	want := buildLocalRateLimitConfig(routeBucket, nil)
+	if got := perRouteConfig(t, r); !reflect.DeepEqual(got, want) {
+		t.Errorf("got per route config %v, want %v", got, want)
+	}
+	// The route override has no descriptors.
+	if len(r.GetRoute().RateLimits) != 0 {
+		t.Errorf("expected no rate limit actions, got %v", r.GetRoute().RateLimits)
+	}
+
+	// Other ports use the default limits.
+	in.ServiceInstance.Endpoint.ServicePort.Port = 8080
+	routeConfiguration = newRouteConfiguration(newRoute(""))
+	NewPlugin().OnInboundRouteConfiguration(in, routeConfiguration)
+	r = routeConfiguration.VirtualHosts[0].Routes[0]
+	if len(r.TypedPerFilterConfig) != 0 {
+		t.Errorf("expected no per route config, got %v", r.TypedPerFilterConfig)
+	}
+	if !reflect.DeepEqual(r.GetRoute().RateLimits, freeTierActions) {
+		t.Errorf("got rate limit actions %v, want %v", r.GetRoute().RateLimits, freeTierActions)
+	}
+}
+
+func TestOnOutboundRouteConfiguration(t *testing.T) {
+	in := newInputParams(t, model.Router, map[string]string{"app": "reviews"}, reviewsRateLimit)
+	routeConfiguration := newRouteConfiguration(newRoute("reviews-canary"), newRoute("reviews"))
+	NewPlugin().OnOutboundRouteConfiguration(in, routeConfiguration)
+
+	canary := routeConfiguration.VirtualHosts[0].Routes[0]
+	want := buildLocalRateLimitConfig(routeBucket, nil)
+	if got := perRouteConfig(t, canary); !reflect.DeepEqual(got, want) {
+		t.Errorf("got per route config %v, want %v", got, want)
+	}
+	other := routeConfiguration.VirtualHosts[0].Routes[1]
+	if len(other.TypedPerFilterConfig) != 0 {
+		t.Errorf("expected no per route config, got %v", other.TypedPerFilterConfig)
+	}
+	if !reflect.DeepEqual(other.GetRoute().RateLimits, freeTierActions) {
+		t.Errorf("got rate limit actions %v, want %v", other.GetRoute().RateLimits, freeTierActions)
+	}
+
+	// Sidecar outbound routes are left untouched.
+	in = newInputParams(t, model.SidecarProxy, map[string]string{"app": "reviews"}, reviewsRateLimit)
+	routeConfiguration = newRouteConfiguration(newRoute("reviews-canary"))
+	NewPlugin().OnOutboundRouteConfiguration(in, routeConfiguration)
+	if r := routeConfiguration.VirtualHosts[0].Routes[0]; len(r.TypedPerFilterConfig) != 0 || len(r.GetRoute().RateLimits) != 0 {
+		t.Errorf("expected sidecar outbound route to be untouched, got %v", r)
+	}
+}
+
+func TestLocalRateLimitForProxyOldestWins(t *testing.T) {
+	newer := &config.LocalRateLimit{TokenBucket: routeBucket}
+	in := newInputParams(t, model.SidecarProxy, map[string]string{"app": "reviews"}, reviewsRateLimit, newer)
+	got := localRateLimitForProxy(in)
+	if got == nil || got.Spec != reviewsRateLimit {
+		t.Errorf("expected the oldest local rate limit to be selected, got %v", got)
+	}
+}
@@ -77,6 +77,10 @@ var (
 		MaxRetries:      10,
 		InitialInterval: 200 * time.Millisecond,
 	}
+
+	// drainCheckInterval is the interval between the checks of the active connections of a
+	// draining proxy.
+	drainCheckInterval = time.Second
 )
 
 const (
@@ -127,6 +131,13 @@ type Proxy interface {
 	Panic(interface{})
 }
 
+// ConnectionCounter is optionally implemented by a Proxy able to report its number of active
+// connections. The agent then terminates the proxies as soon as all the connections are drained,
+// rather than always waiting for the termination drain duration.
+type ConnectionCounter interface {
+	ActiveConnections() (int, error)
+}
+
 // DrainConfig is used to signal to the Proxy that it should start draining connections
 type DrainConfig struct{}
 
@@ -266,11 +277,44 @@ func (a *agent) terminate() {
 	a.desiredConfig = DrainConfig{}
 	a.reconcile()
 	log.Infof("Graceful termination period is %v, starting...", a.terminationDrainDuration)
-	time.Sleep(a.terminationDrainDuration)
+	a.waitDrained()
 	log.Infof("Graceful termination period complete, terminating remaining proxies.")
 	a.abortAll()
 }
 
+// waitDrained waits for the termination drain duration, or until the proxy has no active
+// connection left if it reports them.
+func (a *agent) waitDrained() {
+	counter, ok := a.proxy.(ConnectionCounter)
+	if !ok {
+		time.Sleep(a.terminationDrainDuration)
+		return
+	}
+
+	deadline := time.NewTimer(a.terminationDrainDuration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+			// The proxy may not answer while it restarts with the drain configuration.
+			active, err := counter.ActiveConnections()
+			if err != nil {
+				log.Debugf("Failed to get the active connections of the proxy: %v", err)
+				continue
+			}
+			if active == 0 {
+				log.Infof("All connections are drained")
+				return
+			}
+			log.Debugf("Waiting for %d active connections to drain", active)
+		}
+	}
+}
+
 func (a *agent) reconcile() {
 	// cancel any scheduled restart
 	a.retry.restart = nil
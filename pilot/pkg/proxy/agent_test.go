@@ -427,3 +427,54 @@ func TestLockup(t *testing.T) {
 		t.Error("liveness check failed")
 	}
 }
+
+// CountingProxy is a TestProxy reporting its active connections
+type CountingProxy struct {
+	TestProxy
+	activeConnections func() (int, error)
+}
+
+func (cp CountingProxy) ActiveConnections() (int, error) {
+	return cp.activeConnections()
+}
+
+// TestWaitDrained tests that the termination drain ends once all the connections are closed
+func TestWaitDrained(t *testing.T) {
+	drainCheckInterval = time.Millisecond
+	defer func() { drainCheckInterval = time.Second }()
+
+	checks := 0
+	a := NewAgent(CountingProxy{activeConnections: func() (int, error) {
+		checks++
+		switch checks {
+		case 1:
+			return 0, errors.New("proxy restarting")
+		case 2:
+			return 2, nil
+		}
+		return 0, nil
+	}}, testRetry, time.Minute).(*agent)
+	start := time.Now()
+	a.waitDrained()
+	if checks != 3 {
+		t.Errorf("drain ended after %d checks, want 3", checks)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("drain took %v, the connections were closed", elapsed)
+	}
+
+	// The connections are never closed, the drain ends after the termination drain duration.
+	a = NewAgent(CountingProxy{activeConnections: func() (int, error) {
+		return 1, nil
+	}}, testRetry, 50*time.Millisecond).(*agent)
+	done := make(chan struct{})
+	go func() {
+		a.waitDrained()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Error("drain did not end after the termination drain duration")
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"istio.io/istio/pkg/envoy/compare"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// ConfigHistoryPath serves the epochs of the config history, or the configs of an epoch
+	// with the epoch query parameter.
+	ConfigHistoryPath = "/debug/config_history"
+
+	// ConfigDiffPath serves the diff of the configs of an epoch with the ones of the previous
+	// epoch, with the epoch query parameter, or between two epochs, with the from and to query
+	// parameters. The latest epoch is diffed by default.
+	ConfigDiffPath = "/debug/config_diff"
+)
+
+var (
+	// configDumpSettleDelay is the delay before the config dump of a new epoch is recorded,
+	// leaving Envoy the time to fetch its dynamic configuration.
+	configDumpSettleDelay = 30 * time.Second
+)
+
+// EpochConfig is the configuration of an epoch of Envoy.
+type EpochConfig struct {
+	Epoch int       `json:"epoch"`
+	Time  time.Time `json:"time"`
+	// Bootstrap is the rendered bootstrap config of the epoch.
+	Bootstrap string `json:"bootstrap,omitempty"`
+	// ConfigDumpBefore is the config dump of the previous epoch when the epoch started, empty
+	// if no epoch was running.
+	ConfigDumpBefore json.RawMessage `json:"configDumpBefore,omitempty"`
+	// ConfigDump is the config dump of the epoch once settled.
+	ConfigDump json.RawMessage `json:"configDump,omitempty"`
+}
+
+// epochSummary is an entry of the config history listing.
+type epochSummary struct {
+	Epoch         int       `json:"epoch"`
+	Time          time.Time `json:"time"`
+	HasConfigDump bool      `json:"hasConfigDump"`
+}
+
+// ConfigHistory keeps the configurations of the last epochs of Envoy, to debug the changes
+// of configuration across hot restarts.
+type ConfigHistory struct {
+	size   int
+	mutex  sync.RWMutex
+	epochs []*EpochConfig
+}
+
+// NewConfigHistory creates a history of the configurations of the last size epochs.
+func NewConfigHistory(size int) *ConfigHistory {
+	return &ConfigHistory{size: size}
+}
+
+// Record adds the bootstrap config of a starting epoch to the history, with the config dump
+// of the running Envoy. The config dump of the new epoch is added once settled. A retried
+// epoch replaces the previous attempt.
+func (h *ConfigHistory) Record(epoch int, bootstrap []byte, configDump func() ([]byte, error)) {
+	config := &EpochConfig{
+		Epoch:     epoch,
+		Time:      time.Now(),
+		Bootstrap: string(bootstrap),
+	}
+	if epoch > 0 {
+		if dump, err := configDump(); err != nil {
+			log.Warnf("Failed to get the config dump before epoch %d: %v", epoch, err)
+		} else {
+			config.ConfigDumpBefore = dump
+		}
+	}
+
+	h.mutex.Lock()
+	epochs := make([]*EpochConfig, 0, h.size)
+	for _, c := range h.epochs {
+		if c.Epoch != epoch {
+			epochs = append(epochs, c)
+		}
+	}
+	epochs = append(epochs, config)
+	if len(epochs) > h.size {
+		epochs = epochs[len(epochs)-h.size:]
+	}
+	h.epochs = epochs
+	h.mutex.Unlock()
+
+	delay := configDumpSettleDelay
+	go func() {
+		time.Sleep(delay)
+		dump, err := configDump()
+		if err != nil {
+			log.Warnf("Failed to get the config dump of epoch %d: %v", epoch, err)
+			return
+		}
+		h.mutex.Lock()
+		config.ConfigDump = dump
+		h.mutex.Unlock()
+	}()
+}
+
+// get returns the configuration of an epoch, or the latest one if epoch is negative.
+func (h *ConfigHistory) get(epoch int) (EpochConfig, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if len(h.epochs) == 0 {
+		return EpochConfig{}, false
+	}
+	if epoch < 0 {
+		return *h.epochs[len(h.epochs)-1], true
+	}
+	for _, c := range h.epochs {
+		if c.Epoch == epoch {
+			return *c, true
+		}
+	}
+	return EpochConfig{}, false
+}
+
+// ServeHTTP serves ConfigHistoryPath and ConfigDiffPath.
+func (h *ConfigHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case ConfigHistoryPath:
+		h.handleHistory(w, r)
+	case ConfigDiffPath:
+		h.handleDiff(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *ConfigHistory) handleHistory(w http.ResponseWriter, r *http.Request) {
+	var out interface{}
+	if r.URL.Query().Get("epoch") != "" {
+		epoch, err := epochParam(r, "epoch")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		config, ok := h.get(epoch)
+		if !ok {
+			http.Error(w, fmt.Sprintf("epoch %d is not in the config history", epoch), http.StatusNotFound)
+			return
+		}
+		out = config
+	} else {
+		h.mutex.RLock()
+		summaries := make([]epochSummary, 0, len(h.epochs))
+		for _, c := range h.epochs {
+			summaries = append(summaries, epochSummary{Epoch: c.Epoch, Time: c.Time, HasConfigDump: c.ConfigDump != nil})
+		}
+		h.mutex.RUnlock()
+		out = summaries
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+func (h *ConfigHistory) handleDiff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var from, to EpochConfig
+	var fromDump, toDump json.RawMessage
+	if query.Get("from") != "" || query.Get("to") != "" {
+		fromEpoch, err := epochParam(r, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		toEpoch, err := epochParam(r, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ok bool
+		if from, ok = h.get(fromEpoch); !ok {
+			http.Error(w, fmt.Sprintf("epoch %d is not in the config history", fromEpoch), http.StatusNotFound)
+			return
+		}
+		if to, ok = h.get(toEpoch); !ok {
+			http.Error(w, fmt.Sprintf("epoch %d is not in the config history", toEpoch), http.StatusNotFound)
+			return
+		}
+		fromDump, toDump = from.ConfigDump, to.ConfigDump
+	} else {
+		epoch := -1
+		if query.Get("epoch") != "" {
+			var err error
+			if epoch, err = epochParam(r, "epoch"); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var ok bool
+		if to, ok = h.get(epoch); !ok {
+			http.Error(w, "the epoch is not in the config history", http.StatusNotFound)
+			return
+		}
+		// The previous epoch may have left the history, its config dump is kept with the epoch.
+		from = EpochConfig{Epoch: to.Epoch - 1}
+		if to.Epoch > 0 {
+			if c, ok := h.get(to.Epoch - 1); ok {
+				from = c
+			}
+		}
+		fromDump, toDump = to.ConfigDumpBefore, to.ConfigDump
+	}
+
+	out := &bytes.Buffer{}
+	fromLabel, toLabel := fmt.Sprintf("Epoch %d", from.Epoch), fmt.Sprintf("Epoch %d", to.Epoch)
+	if from.Bootstrap == "" {
+		fmt.Fprintf(out, "No rendered bootstrap for epoch %d\n", from.Epoch)
+	} else {
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			FromFile: fromLabel + " Rendered Bootstrap",
+			A:        difflib.SplitLines(from.Bootstrap),
+			ToFile:   toLabel + " Rendered Bootstrap",
+			B:        difflib.SplitLines(to.Bootstrap),
+			Context:  7,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if text != "" {
+			fmt.Fprintln(out, text)
+		} else {
+			fmt.Fprintln(out, "Rendered Bootstrap Match")
+		}
+	}
+	if fromDump == nil || toDump == nil {
+		fmt.Fprintf(out, "No config dump to diff between epochs %d and %d\n", from.Epoch, to.Epoch)
+	} else {
+		c, err := compare.NewEnvoyComparator(out, fromLabel, fromDump, toLabel, toDump)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := c.BootstrapDiff(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := c.Diff(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write(out.Bytes())
+}
+
+func epochParam(r *http.Request, name string) (int, error) {
+	epoch, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || epoch < 0 {
+		return 0, fmt.Errorf("invalid %s epoch %q", name, r.URL.Query().Get(name))
+	}
+	return epoch, nil
+}
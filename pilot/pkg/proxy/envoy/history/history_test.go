@@ -0,0 +1,148 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	configDumpTemplate = `{"configs":[` +
+		`{"@type":"type.googleapis.com/envoy.admin.v2alpha.BootstrapConfigDump","bootstrap":{"node":{"cluster":"%s"}}},` +
+		`{"@type":"type.googleapis.com/envoy.admin.v2alpha.ClustersConfigDump"},` +
+		`{"@type":"type.googleapis.com/envoy.admin.v2alpha.ListenersConfigDump"},` +
+		`{"@type":"type.googleapis.com/envoy.admin.v2alpha.RoutesConfigDump"}]}`
+)
+
+var (
+	configDump0 = fmt.Sprintf(configDumpTemplate, "foo")
+	configDump1 = fmt.Sprintf(configDumpTemplate, "bar")
+)
+
+func waitConfigDump(t *testing.T, h *ConfigHistory, epoch int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if c, ok := h.get(epoch); ok && c.ConfigDump != nil {
+			return
+		}
+	}
+	t.Fatalf("the config dump of epoch %d was not recorded", epoch)
+}
+
+func TestConfigHistory(t *testing.T) {
+	delay := configDumpSettleDelay
+	configDumpSettleDelay = 0
+	defer func() { configDumpSettleDelay = delay }()
+
+	h := NewConfigHistory(2)
+	// The config dump of Envoy changes once the new epoch runs.
+	dumps := []string{configDump0, configDump0, configDump1}
+	configDump := func() ([]byte, error) {
+		dump := dumps[0]
+		dumps = dumps[1:]
+		return []byte(dump), nil
+	}
+
+	h.Record(0, []byte("{\n\"cluster\": \"foo\"\n}\n"), configDump)
+	waitConfigDump(t, h, 0)
+	h.Record(1, []byte("{\n\"cluster\": \"bar\"\n}\n"), configDump)
+	waitConfigDump(t, h, 1)
+
+	c, _ := h.get(1)
+	if string(c.ConfigDumpBefore) != configDump0 || string(c.ConfigDump) != configDump1 {
+		t.Errorf("unexpected config dumps of epoch 1: %s, %s", c.ConfigDumpBefore, c.ConfigDump)
+	}
+
+	cases := []struct {
+		name   string
+		path   string
+		status int
+		want   []string
+	}{
+		{
+			name:   "history",
+			path:   ConfigHistoryPath,
+			status: http.StatusOK,
+			want:   []string{`"epoch": 0`, `"epoch": 1`, `"hasConfigDump": true`},
+		},
+		{
+			name:   "epoch",
+			path:   ConfigHistoryPath + "?epoch=1",
+			status: http.StatusOK,
+			want:   []string{`"bootstrap": "{\n\"cluster\": \"bar\"\n}\n"`, `"configDumpBefore"`},
+		},
+		{
+			name:   "unknown epoch",
+			path:   ConfigHistoryPath + "?epoch=7",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid epoch",
+			path:   ConfigDiffPath + "?epoch=foo",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "latest diff",
+			path:   ConfigDiffPath,
+			status: http.StatusOK,
+			want: []string{
+				"--- Epoch 0 Rendered Bootstrap", "-\"cluster\": \"foo\"", "+\"cluster\": \"bar\"",
+				"--- Epoch 0 Bootstrap", "+++ Epoch 1 Bootstrap", "Clusters Match",
+			},
+		},
+		{
+			name:   "from to diff",
+			path:   ConfigDiffPath + "?from=1&to=0",
+			status: http.StatusOK,
+			want:   []string{"--- Epoch 1 Rendered Bootstrap", "+++ Epoch 0 Rendered Bootstrap", "+\"cluster\": \"foo\""},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("response %s does not contain %q", w.Body.String(), want)
+				}
+			}
+		})
+	}
+
+	// The oldest epochs leave the history.
+	h.Record(2, []byte("{}"), func() ([]byte, error) { return nil, errors.New("not ready") })
+	if _, ok := h.get(0); ok {
+		t.Error("epoch 0 is still in the history")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", ConfigHistoryPath, nil))
+	var summaries []epochSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].Epoch != 1 || summaries[1].Epoch != 2 {
+		t.Errorf("unexpected history %v", summaries)
+	}
+}
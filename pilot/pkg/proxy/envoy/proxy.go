@@ -15,12 +15,16 @@
 package envoy
 
 import (
+	"bufio"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gogo/protobuf/types"
@@ -38,6 +42,9 @@ const (
 
 	// drainFile is the location of the bootstrap config used for draining on istio-proxy termination
 	drainFile = "/var/lib/istio/envoy/envoy_bootstrap_drain.json"
+
+	// adminRequestTimeout is the timeout of the requests to the Envoy admin API.
+	adminRequestTimeout = 2 * time.Second
 )
 
 type envoy struct {
@@ -48,10 +55,20 @@ type envoy struct {
 	opts      map[string]interface{}
 	errChan   chan error
 	nodeIPs   []string
+	recorder  ConfigRecorder
+}
+
+// ConfigRecorder records the configurations of the epochs of Envoy, for debugging.
+type ConfigRecorder interface {
+	// Record is called with the rendered bootstrap config of an epoch before it starts. The
+	// config dump of the running Envoy is returned by configDump.
+	Record(epoch int, bootstrap []byte, configDump func() ([]byte, error))
 }
 
-// NewProxy creates an instance of the proxy control commands
-func NewProxy(config meshconfig.ProxyConfig, node string, logLevel string, componentLogLevel string, pilotSAN []string, nodeIPs []string) proxy.Proxy {
+// NewProxy creates an instance of the proxy control commands. The configurations of the epochs
+// are recorded by the recorder if not nil.
+func NewProxy(config meshconfig.ProxyConfig, node string, logLevel string, componentLogLevel string, pilotSAN []string,
+	nodeIPs []string, recorder ConfigRecorder) proxy.Proxy {
 	// inject tracing flag for higher levels
 	var args []string
 	if logLevel != "" {
@@ -67,6 +84,7 @@ func NewProxy(config meshconfig.ProxyConfig, node string, logLevel string, compo
 		extraArgs: args,
 		pilotSAN:  pilotSAN,
 		nodeIPs:   nodeIPs,
+		recorder:  recorder,
 	}
 }
 
@@ -106,7 +124,23 @@ func (e *envoy) args(fname string, epoch int, bootstrapConfig string) []string {
 
 var istioBootstrapOverrideVar = env.RegisterStringVar("ISTIO_BOOTSTRAP_OVERRIDE", "", "")
 
+// withConfig returns a copy of the proxy using another proxy configuration.
+func (e *envoy) withConfig(config *meshconfig.ProxyConfig) *envoy {
+	copied := *e
+	copied.config = *config
+	return &copied
+}
+
+// generateBootstrap renders the bootstrap of the proxy, as written by Run.
+func (e *envoy) generateBootstrap() ([]byte, error) {
+	return bootstrap.GenerateBootstrap(&e.config, e.node, e.pilotSAN, e.opts, os.Environ(), e.nodeIPs)
+}
+
 func (e *envoy) Run(config interface{}, epoch int, abort <-chan error) error {
+	// A reloaded proxy configuration is only used by this epoch.
+	if c, ok := config.(BootstrapConfig); ok && c.ProxyConfig != nil {
+		e = e.withConfig(c.ProxyConfig)
+	}
 
 	var fname string
 	// Note: the cert checking still works, the generated file is updated if certs are changed.
@@ -125,6 +159,13 @@ func (e *envoy) Run(config interface{}, epoch int, abort <-chan error) error {
 			return err
 		}
 		fname = out
+		if e.recorder != nil {
+			if bootstrap, err := ioutil.ReadFile(fname); err != nil {
+				log.Warnf("Failed to read the bootstrap config of epoch %d: %v", epoch, err)
+			} else {
+				e.recorder.Record(epoch, bootstrap, e.configDump)
+			}
+		}
 	}
 
 	// spin up a new Envoy process
@@ -184,6 +225,67 @@ func (e *envoy) Panic(epoch interface{}) {
 	os.Exit(-1)
 }
 
+// ActiveConnections returns the number of active downstream connections of the listeners of
+// Envoy, from the stats of the admin API.
+func (e *envoy) ActiveConnections() (int, error) {
+	client := &http.Client{Timeout: adminRequestTimeout}
+	resp, err := client.Get(e.adminURL("/stats?usedonly&filter=downstream_cx_active"))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	active := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 || !isListenerConnectionsStat(parts[0]) {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return 0, fmt.Errorf("failed parsing Envoy stat %s: %v", parts[0], err)
+		}
+		active += value
+	}
+	return active, scanner.Err()
+}
+
+// configDump returns the config dump of Envoy, from the admin API.
+func (e *envoy) configDump() ([]byte, error) {
+	client := &http.Client{Timeout: adminRequestTimeout}
+	resp, err := client.Get(e.adminURL("/config_dump"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// adminURL returns the URL of a path of the Envoy admin API.
+func (e *envoy) adminURL(path string) string {
+	host := "127.0.0.1"
+	if isIPv6Proxy(e.nodeIPs) {
+		host = "::1"
+	}
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(host, fmt.Sprint(e.config.ProxyAdminPort)), path)
+}
+
+// isListenerConnectionsStat returns whether a stat is the active connections of a listener,
+// leaving out the admin listener and the per worker stats, which are already counted by the
+// listener stats.
+func isListenerConnectionsStat(name string) bool {
+	return strings.HasPrefix(name, "listener.") && strings.HasSuffix(name, ".downstream_cx_active") &&
+		!strings.HasPrefix(name, "listener.admin.") && !strings.Contains(name, ".worker_") &&
+		!strings.Contains(name, ".main_thread.")
+}
+
 // convertDuration converts to golang duration and logs errors
 func convertDuration(d *types.Duration) time.Duration {
 	if d == nil {
@@ -16,7 +16,11 @@ package envoy
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"istio.io/istio/pilot/pkg/model"
@@ -40,6 +44,7 @@ func TestEnvoyArgs(t *testing.T) {
 		"misc:error",
 		nil,
 		[]string{"10.75.2.9", "192.168.11.18"},
+		nil,
 	)
 	if !reflect.DeepEqual(testProxy, test) {
 		t.Errorf("unexpected struct got\n%v\nwant\n%v", testProxy, test)
@@ -67,3 +72,69 @@ func TestEnvoyArgs(t *testing.T) {
 }
 
 // TestEnvoyRun is no longer used - we are now using v2 bootstrap API.
+
+func TestActiveConnections(t *testing.T) {
+	cases := []struct {
+		name    string
+		stats   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "listeners",
+			stats: `listener.0.0.0.0_15001.downstream_cx_active: 3
+listener.0.0.0.0_15001.worker_0.downstream_cx_active: 2
+listener.0.0.0.0_15001.worker_1.downstream_cx_active: 1
+listener.10.1.0.5_80.downstream_cx_active: 2
+listener.admin.downstream_cx_active: 1
+listener.admin.main_thread.downstream_cx_active: 1
+http.admin.downstream_cx_active: 1
+`,
+			want: 5,
+		},
+		{
+			name:  "drained",
+			stats: "listener.admin.downstream_cx_active: 1\n",
+			want:  0,
+		},
+		{
+			name:    "invalid stat",
+			stats:   "listener.0.0.0.0_15001.downstream_cx_active: many\n",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/stats" || r.URL.Query().Get("filter") != "downstream_cx_active" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(c.stats))
+			}))
+			defer server.Close()
+			port, err := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := model.DefaultProxyConfig()
+			config.ProxyAdminPort = int32(port)
+			e := &envoy{config: config, nodeIPs: []string{"10.1.0.5"}}
+			got, err := e.ActiveConnections()
+			if c.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got %d active connections, want %d", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/proxy"
+	"istio.io/istio/pkg/filewatcher"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// configMinDelay is the minimum amount of time between two reloads of the config files.
+	// The files are usually mounted ConfigMaps, which are updated atomically.
+	configMinDelay = time.Second
+)
+
+var (
+	newFileWatcher = filewatcher.NewWatcher
+)
+
+// BootstrapConfig is sent by the watcher to the agent when the proxy configuration reloaded from
+// the config files changes the bootstrap or the command line of the proxy. The proxy is then
+// hot restarted with the new configuration.
+type BootstrapConfig struct {
+	// ProxyConfig is the proxy configuration of the next epoch.
+	ProxyConfig *meshconfig.ProxyConfig
+	// Hash is the hash of the rendered bootstrap, the command line and the certificates.
+	Hash string
+}
+
+// ConfigReloader loads the proxy configuration from the mesh config and proxy config files.
+type ConfigReloader struct {
+	// Base is the proxy configuration from the command line. It is overridden by the default proxy
+	// configuration of the mesh config file, itself overridden by the proxy config file.
+	Base meshconfig.ProxyConfig
+	// MeshConfigFile is the optional mesh config file.
+	MeshConfigFile string
+	// ProxyConfigFile is the optional proxy config file.
+	ProxyConfigFile string
+	// Proxy is the proxy created by NewProxy and started with the configuration. Its bootstrap
+	// is rendered the same way, with the same options, to find out whether it changes.
+	Proxy proxy.Proxy
+}
+
+// Load returns the current proxy configuration. A missing config file is ignored.
+func (r *ConfigReloader) Load() (*meshconfig.ProxyConfig, error) {
+	config := proto.Clone(&r.Base).(*meshconfig.ProxyConfig)
+
+	if r.MeshConfigFile != "" {
+		mesh := meshconfig.MeshConfig{}
+		if err := applyYAMLFile(r.MeshConfigFile, &mesh); err != nil {
+			return nil, err
+		}
+		if mesh.DefaultConfig != nil {
+			proto.Merge(config, mesh.DefaultConfig)
+		}
+	}
+	if r.ProxyConfigFile != "" {
+		proxyConfig := meshconfig.ProxyConfig{}
+		if err := applyYAMLFile(r.ProxyConfigFile, &proxyConfig); err != nil {
+			return nil, err
+		}
+		proto.Merge(config, &proxyConfig)
+	}
+
+	// Like for the command line, Envoy needs the IP address of statsd.
+	if config.StatsdUdpAddress != "" && config.StatsdUdpAddress != r.Base.StatsdUdpAddress {
+		addr, err := proxy.ResolveAddr(config.StatsdUdpAddress)
+		if err != nil {
+			return nil, fmt.Errorf("resolve StatsdUdpAddress failed: %v", err)
+		}
+		config.StatsdUdpAddress = addr
+	}
+
+	if err := model.ValidateProxyConfig(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// files returns the config files watched for changes.
+func (r *ConfigReloader) files() []string {
+	var files []string
+	for _, f := range []string{r.MeshConfigFile, r.ProxyConfigFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// writeHash adds what the proxy is started with for the configuration to the hash: the rendered
+// bootstrap, or the custom config file, the files it refers to and the command line.
+func (r *ConfigReloader) writeHash(h hash.Hash, config *meshconfig.ProxyConfig) error {
+	current, ok := r.Proxy.(*envoy)
+	if !ok {
+		return fmt.Errorf("the bootstrap of proxy %T cannot be rendered", r.Proxy)
+	}
+	e := current.withConfig(config)
+
+	var out []byte
+	var err error
+	if config.CustomConfigFile != "" {
+		out, err = ioutil.ReadFile(config.CustomConfigFile)
+	} else {
+		out, err = e.generateBootstrap()
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := h.Write(out); err != nil {
+		return err
+	}
+	// The access token is written to a file by the agent, and only its path is rendered.
+	if lightstep := config.GetTracing().GetLightstep(); lightstep != nil {
+		if _, err := io.WriteString(h, lightstep.AccessToken); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(h, "%s %s", config.BinaryPath, strings.Join(e.args("", 0, ""), " "))
+	return err
+}
+
+func applyYAMLFile(filename string, pb proto.Message) error {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := model.ApplyYAML(string(data), pb); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+	return nil
+}
+
+// watchConfig watches the config files and calls updateFunc when they change. This method is
+// blocking so it should be run as a goroutine. updateFunc will not be called more than one time
+// per minDelay.
+func watchConfig(ctx context.Context, files []string, fw filewatcher.FileWatcher, minDelay time.Duration, updateFunc func()) {
+	defer func() {
+		if err := fw.Close(); err != nil {
+			log.Warnf("closing config watcher encounters an error %v", err)
+		}
+	}()
+
+	changed := make(chan struct{}, 1)
+	for _, f := range files {
+		if err := fw.Add(f); err != nil {
+			log.Warnf("watching %s encounters an error %v", f, err)
+			continue
+		}
+		log.Infof("watching %s for changes", f)
+		f, events, errs := f, fw.Events(f), fw.Errors(f)
+		go func() {
+			for {
+				select {
+				case ev, ok := <-events:
+					if !ok {
+						return
+					}
+					log.Infof("watchConfig: %s", ev.String())
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				case err, ok := <-errs:
+					if !ok {
+						return
+					}
+					log.Warnf("error event while watching %s: %v", f, err)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	var timeChan <-chan time.Time
+	for {
+		select {
+		case <-changed:
+			if timeChan == nil {
+				timeChan = time.After(minDelay)
+			}
+		case <-timeChan:
+			timeChan = nil
+			log.Info("watchConfig: notifying")
+			updateFunc()
+		case <-ctx.Done():
+			log.Info("watchConfig has successfully terminated")
+			return
+		}
+	}
+}
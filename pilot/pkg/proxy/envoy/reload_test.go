@@ -0,0 +1,209 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/filewatcher"
+)
+
+func testReloader(t *testing.T, dir string) *ConfigReloader {
+	t.Helper()
+	tmpl := filepath.Join(dir, "envoy_bootstrap_tmpl.json")
+	if err := ioutil.WriteFile(tmpl, []byte(`{"zipkin": {{ .zipkin }}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := model.DefaultProxyConfig()
+	base.ConfigPath = dir
+	base.BinaryPath = filepath.Join(dir, "envoy")
+	base.ProxyBootstrapTemplatePath = tmpl
+	return &ConfigReloader{
+		Base:            base,
+		MeshConfigFile:  filepath.Join(dir, "mesh"),
+		ProxyConfigFile: filepath.Join(dir, "proxy"),
+		Proxy:           NewProxy(base, "sidecar~1.2.3.4~foo~bar", "", "", nil, []string{"1.2.3.4"}, nil),
+	}
+}
+
+func TestConfigReloaderLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	r := testReloader(t, dir)
+
+	// The files are missing.
+	config, err := r.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ServiceCluster != r.Base.ServiceCluster || config.Concurrency != r.Base.Concurrency {
+		t.Errorf("got %v, want the base config %v", config, r.Base)
+	}
+
+	if err := ioutil.WriteFile(r.MeshConfigFile, []byte(`
+defaultConfig:
+  serviceCluster: mesh-cluster
+  concurrency: 4
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(r.ProxyConfigFile, []byte("concurrency: 8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if config, err = r.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if config.ServiceCluster != "mesh-cluster" {
+		t.Errorf("got service cluster %q, want the one of the mesh config", config.ServiceCluster)
+	}
+	if config.Concurrency != 8 {
+		t.Errorf("got concurrency %d, want the one of the proxy config", config.Concurrency)
+	}
+	if config.ConfigPath != dir {
+		t.Errorf("got config path %q, want the base one", config.ConfigPath)
+	}
+
+	if err := ioutil.WriteFile(r.ProxyConfigFile, []byte("discoveryAddress: istio-pilot\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Load(); err == nil {
+		t.Error("expected an error for an invalid proxy config")
+	}
+}
+
+func TestReloadingWatcherSendConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	r := testReloader(t, dir)
+	cert := filepath.Join(dir, "cert-chain.pem")
+	updates := make(chan interface{}, 10)
+	w := NewReloadingWatcher([]string{cert}, r, updates).(*watcher)
+
+	steps := []struct {
+		name       string
+		proxy      string
+		cert       string
+		wantUpdate bool
+		// wantZipkin is the zipkin address of the updated proxy config, if checked.
+		wantZipkin string
+	}{
+		{
+			name:       "initial config",
+			proxy:      "tracing: {zipkin: {address: zipkin-a:9411}}\n",
+			wantUpdate: true,
+		},
+		{
+			name:       "tracing change",
+			proxy:      "tracing: {zipkin: {address: zipkin-b:9411}}\n",
+			wantUpdate: true,
+		},
+		{
+			name:  "change not rendered",
+			proxy: "tracing: {zipkin: {address: zipkin-b:9411}}\nproxyAdminPort: 15001\n",
+		},
+		{
+			name:       "command line change",
+			proxy:      "tracing: {zipkin: {address: zipkin-b:9411}}\nconcurrency: 4\n",
+			wantUpdate: true,
+		},
+		{
+			name:  "invalid config",
+			proxy: "tracing: {zipkin: {address: zipkin-c}}\n",
+		},
+		{
+			name:       "certificate rotation with an invalid config",
+			proxy:      "tracing: {zipkin: {address: zipkin-c}}\n",
+			cert:       "rotated",
+			wantUpdate: true,
+			wantZipkin: "zipkin-b:9411",
+		},
+	}
+	for _, step := range steps {
+		if err := ioutil.WriteFile(r.ProxyConfigFile, []byte(step.proxy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(cert, []byte(step.cert), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w.SendConfig()
+		select {
+		case update := <-updates:
+			if !step.wantUpdate {
+				t.Errorf("%s: unexpected update %v", step.name, update)
+				continue
+			}
+			config, ok := update.(BootstrapConfig)
+			if !ok || config.ProxyConfig == nil || config.Hash == "" {
+				t.Errorf("%s: got update %v, want a bootstrap config", step.name, update)
+				continue
+			}
+			if config.ProxyConfig.GetTracing().GetZipkin() == nil {
+				t.Errorf("%s: the proxy config is not reloaded: %v", step.name, config.ProxyConfig)
+			}
+			if got := config.ProxyConfig.GetTracing().GetZipkin().GetAddress(); step.wantZipkin != "" && got != step.wantZipkin {
+				t.Errorf("%s: got zipkin address %q, want %q", step.name, got, step.wantZipkin)
+			}
+		default:
+			if step.wantUpdate {
+				t.Errorf("%s: expected an update", step.name)
+			}
+		}
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	added := make(chan string, 2)
+	newWatcher, fakeWatcher := filewatcher.NewFakeWatcher(func(path string, _ bool) { added <- path })
+	files := []string{"/etc/istio/mesh/mesh", "/etc/istio/proxy/proxy"}
+	called := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go watchConfig(ctx, files, newWatcher(), 50*time.Millisecond, func() { called <- struct{}{} })
+
+	// Wait for the files to be watched.
+	for range files {
+		<-added
+	}
+
+	// Related changes are batched.
+	for _, f := range files {
+		fakeWatcher.InjectEvent(f, fsnotify.Event{Name: f, Op: fsnotify.Write})
+	}
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("the config change is not notified")
+	}
+	select {
+	case <-called:
+		t.Error("the changes are notified more than once")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
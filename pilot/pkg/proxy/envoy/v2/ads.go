@@ -241,6 +241,8 @@ type XdsConnection struct {
 	LDSWatch bool
 	// CDSWatch is set if the remote server is watching Clusters
 	CDSWatch bool
+	// NDSWatch is set if the remote server is watching the name table, e.g. the DNS proxy of pilot-agent
+	NDSWatch bool
 
 	// added will be true if at least one discovery request was received, and the connection
 	// is added to the map of active.
@@ -574,6 +576,23 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream ads.AggregatedDiscove
 					return err
 				}
 
+			case NameTableType:
+				if con.NDSWatch {
+					// Already received a name table watch request, this is an ACK
+					if discReq.ErrorDetail != nil {
+						adsLog.Warnf("ADS:NDS: ACK ERROR %v %s %v", peerAddr, con.ConID, discReq.String())
+						totalXDSRejects.Add(1)
+					}
+					adsLog.Debugf("ADS:NDS: ACK %v", discReq.String())
+					continue
+				}
+				adsLog.Infof("ADS:NDS: REQ %s %v", con.ConID, peerAddr)
+				con.NDSWatch = true
+				err := s.pushNds(con, s.globalPushContext(), versionInfo())
+				if err != nil {
+					return err
+				}
+
 			default:
 				adsLog.Warnf("ADS: Unknown watched resources %s", discReq.String())
 			}
@@ -683,6 +702,12 @@ func (s *DiscoveryServer) pushConnection(con *XdsConnection, pushEv *XdsEvent) e
 				return err
 			}
 		}
+		// The hosts without VIP resolve to the addresses of their endpoints.
+		if con.NDSWatch {
+			if err := s.pushNds(con, pushEv.push, pushEv.version); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 
@@ -762,6 +787,12 @@ func (s *DiscoveryServer) pushConnection(con *XdsConnection, pushEv *XdsEvent) e
 			return err
 		}
 	}
+	if con.NDSWatch {
+		err := s.pushNds(con, pushEv.push, pushEv.version)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
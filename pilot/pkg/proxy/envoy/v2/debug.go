@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 
 	"github.com/gogo/protobuf/jsonpb"
 
@@ -26,6 +27,7 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
 	networking_core "istio.io/istio/pilot/pkg/networking/core/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/util"
 	authn_alpha1 "istio.io/istio/pilot/pkg/security/authn/v1alpha1"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
@@ -61,6 +63,7 @@ func (s *DiscoveryServer) InitDebug(mux *http.ServeMux, sctl *aggregate.Controll
 	mux.HandleFunc("/debug/configz", s.configz)
 
 	mux.HandleFunc("/debug/authenticationz", s.authenticationz)
+	mux.HandleFunc("/debug/mtlsz", s.mtlsz)
 	mux.HandleFunc("/debug/config_dump", s.ConfigDump)
 	mux.HandleFunc("/debug/push_status", s.PushStatusHandler)
 }
@@ -285,6 +288,15 @@ func configName(config *model.Config) string {
 	return "-"
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func authProtocolToString(protocol authProtocol) string {
 	switch protocol {
 	case authnHTTP:
@@ -302,6 +314,34 @@ func authProtocolToString(protocol authProtocol) string {
 	}
 }
 
+// tlsConflictStatus returns whether traffic sent with the client protocol is accepted by a server
+// expecting the server protocol.
+func tlsConflictStatus(clientProtocol, serverProtocol authProtocol) string {
+	if (clientProtocol & serverProtocol) == 0 {
+		if clientProtocol == authnCustomMTls && serverProtocol != authnHTTP {
+			return "MAY CONFLICT"
+		}
+		return "CONFLICT"
+	}
+	return "OK"
+}
+
+// WorkloadMTLSDebug holds debug information for the effective mutual TLS mode between a client
+// workload and a port of a server workload.
+type WorkloadMTLSDebug struct {
+	Client                   string `json:"client"`
+	ClientVersion            string `json:"client_version"`
+	Server                   string `json:"server"`
+	Host                     string `json:"host"`
+	Port                     int    `json:"port"`
+	AuthenticationPolicyName string `json:"authentication_policy_name"`
+	DestinationRuleName      string `json:"destination_rule_name"`
+	ServerProtocol           string `json:"server_protocol"`
+	ClientProtocol           string `json:"client_protocol"`
+	TLSConflictStatus        string `json:"TLS_conflict_status"`
+	Reason                   string `json:"reason,omitempty"`
+}
+
 // Authentication debugging
 // This handler lists what authentication policy is used for a service and destination rules to
 // that service that a proxy instance received.
@@ -359,16 +399,7 @@ func (s *DiscoveryServer) authenticationz(w http.ResponseWriter, req *http.Reque
 				clientProtocol = authnHTTP
 			}
 			info.ClientProtocol = authProtocolToString(clientProtocol)
-
-			if (clientProtocol & serverProtocol) == 0 {
-				if clientProtocol == authnCustomMTls && serverProtocol != authnHTTP {
-					info.TLSConflictStatus = "MAY CONFLICT"
-				} else {
-					info.TLSConflictStatus = "CONFLICT"
-				}
-			} else {
-				info.TLSConflictStatus = "OK"
-			}
+			info.TLSConflictStatus = tlsConflictStatus(clientProtocol, serverProtocol)
 			if b, err := json.MarshalIndent(info, "  ", "  "); err == nil {
 				_, _ = w.Write(b)
 			}
@@ -378,6 +409,145 @@ func (s *DiscoveryServer) authenticationz(w http.ResponseWriter, req *http.Reque
 	fmt.Fprint(w, "\n{}]")
 }
 
+// mTLS debugging
+// This handler reports the effective mutual TLS mode between every sidecar connected to this Pilot
+// instance and every port of the server workloads in the service registry, optionally restricted to a
+// namespace. Unlike authenticationz, the server side is computed for each workload port, so port level
+// authentication policies are taken into account, and the destination rules are the ones visible to
+// each client. Since the servers do not depend on the connected sidecars, istioctl merges the reports
+// of all replicas into the report of all the sidecars.
+func (s *DiscoveryServer) mtlsz(w http.ResponseWriter, req *http.Request) {
+	_ = req.ParseForm()
+	w.Header().Add("Content-Type", "application/json")
+
+	namespace := req.Form.Get("namespace")
+	adsClientsMutex.RLock()
+	clients := make([]*model.Proxy, 0, len(adsSidecarIDConnectionsMap))
+	for _, connections := range adsSidecarIDConnectionsMap {
+		mostRecent := ""
+		for key := range connections {
+			if mostRecent == "" || key > mostRecent {
+				mostRecent = key
+			}
+		}
+		proxy := connections[mostRecent].modelNode
+		if proxy == nil || proxy.Type != model.SidecarProxy {
+			continue
+		}
+		if namespace != "" && proxy.ConfigNamespace != namespace {
+			continue
+		}
+		clients = append(clients, proxy)
+	}
+	adsClientsMutex.RUnlock()
+
+	servers, err := s.serverInstances(namespace)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "unable to list the service instances: %v", err)
+		return
+	}
+	out, err := json.MarshalIndent(s.workloadMTLSDebug(clients, servers), "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "unable to marshal mTLS report: %v", err)
+		return
+	}
+	_, _ = w.Write(out)
+}
+
+// serverInstances returns the instances of every port of the services in the registry, optionally
+// restricted to a namespace.
+func (s *DiscoveryServer) serverInstances(namespace string) ([]*model.ServiceInstance, error) {
+	services, err := s.Env.ServiceDiscovery.Services()
+	if err != nil {
+		return nil, err
+	}
+	var instances []*model.ServiceInstance
+	for _, service := range services {
+		if service.MeshExternal || (namespace != "" && service.Attributes.Namespace != namespace) {
+			continue
+		}
+		for _, port := range service.Ports {
+			portInstances, err := s.Env.ServiceDiscovery.InstancesByPort(service.Hostname, port.Port, nil)
+			if err != nil {
+				return nil, err
+			}
+			instances = append(instances, portInstances...)
+		}
+	}
+	return instances, nil
+}
+
+// workloadMTLSDebug computes the effective mutual TLS mode for traffic from each of the client proxies
+// to each of the server instances, which are identified by their address.
+func (s *DiscoveryServer) workloadMTLSDebug(clients []*model.Proxy, servers []*model.ServiceInstance) []WorkloadMTLSDebug {
+	push := s.globalPushContext()
+	report := make([]WorkloadMTLSDebug, 0)
+	for _, instance := range servers {
+		port := instance.Endpoint.ServicePort
+		if port == nil {
+			continue
+		}
+		authnConfig := s.Env.IstioConfigStore.AuthenticationPolicyForWorkload(instance.Service, instance.Labels, port)
+		serverProtocol := getServerAuthProtocol(nil)
+		if authnConfig != nil {
+			serverProtocol = getServerAuthProtocol(authn_alpha1.GetMutualTLS(authnConfig.Spec.(*authn.Policy)))
+		}
+
+		for _, client := range clients {
+			if containsString(client.IPAddresses, instance.Endpoint.Address) {
+				continue
+			}
+			clientVersion, _ := client.GetProxyVersion()
+			info := WorkloadMTLSDebug{
+				Client:                   client.ID,
+				ClientVersion:            clientVersion,
+				Server:                   instance.Endpoint.Address,
+				Host:                     string(instance.Service.Hostname),
+				Port:                     port.Port,
+				AuthenticationPolicyName: configName(authnConfig),
+				ServerProtocol:           authProtocolToString(serverProtocol),
+			}
+
+			clientProtocol := authnHTTP
+			destConfig := push.DestinationRule(client, instance.Service)
+			info.DestinationRuleName = configName(destConfig)
+			if destConfig != nil {
+				clientProtocol = clientAuthProtocol(destConfig.Spec.(*networking.DestinationRule), port)
+			}
+			info.ClientProtocol = authProtocolToString(clientProtocol)
+			info.TLSConflictStatus = tlsConflictStatus(clientProtocol, serverProtocol)
+
+			switch {
+			case clientProtocol == authnHTTP && serverProtocol == authnMTls:
+				info.Reason = "client sends plaintext to a STRICT server"
+			case clientProtocol == authnMTls && serverProtocol == authnPermissive &&
+				clientVersion != "" && !util.IsProxyVersionGE11(client):
+				// PERMISSIVE servers only accept mutual TLS on the filter chain matching the
+				// "istio" ALPN, which sidecars older than 1.1 do not advertise.
+				info.TLSConflictStatus = "CONFLICT"
+				info.Reason = fmt.Sprintf("client sidecar %s does not support PERMISSIVE servers", clientVersion)
+			}
+			report = append(report, info)
+		}
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Client != report[j].Client {
+			return report[i].Client < report[j].Client
+		}
+		if report[i].Host != report[j].Host {
+			return report[i].Host < report[j].Host
+		}
+		if report[i].Port != report[j].Port {
+			return report[i].Port < report[j].Port
+		}
+		return report[i].Server < report[j].Server
+	})
+	return report
+}
+
 // adsz implements a status and debug interface for ADS.
 // It is mapped to /debug/adsz
 func (s *DiscoveryServer) adsz(w http.ResponseWriter, req *http.Request) {
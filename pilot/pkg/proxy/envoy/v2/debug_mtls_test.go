@@ -0,0 +1,207 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"reflect"
+	"testing"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	memregistry "istio.io/istio/pilot/pkg/serviceregistry/memory"
+)
+
+func TestWorkloadMTLSDebug(t *testing.T) {
+	service := &model.Service{
+		Hostname: "server.ns.svc.cluster.local",
+		Address:  "10.0.0.1",
+		Ports: model.PortList{
+			{Name: "http", Port: 80, Protocol: model.ProtocolHTTP},
+			{Name: "tcp", Port: 90, Protocol: model.ProtocolTCP},
+		},
+		Attributes: model.ServiceAttributes{Name: "server", Namespace: "ns"},
+	}
+	// The server workload is the instance of the registry, and is connected to Pilot as well, so it is
+	// a client of its own service that must not be reported.
+	server := &model.Proxy{
+		ID:              "server.ns",
+		Type:            model.SidecarProxy,
+		IPAddresses:     []string{memregistry.MakeIP(service, 0)},
+		ConfigNamespace: "ns",
+		Metadata:        map[string]string{model.NodeMetadataIstioProxyVersion: "1.1.0"},
+	}
+
+	// The namespace is PERMISSIVE, except for port 90 of the server workload which is STRICT.
+	policies := []model.Config{
+		{
+			ConfigMeta: model.ConfigMeta{Type: model.AuthenticationPolicy.Type, Name: "default", Namespace: "ns"},
+			Spec: &authn.Policy{
+				Peers: []*authn.PeerAuthenticationMethod{{
+					Params: &authn.PeerAuthenticationMethod_Mtls{Mtls: &authn.MutualTls{Mode: authn.MutualTls_PERMISSIVE}},
+				}},
+			},
+		},
+		{
+			ConfigMeta: model.ConfigMeta{
+				Type:      model.AuthenticationPolicy.Type,
+				Name:      "server-tcp",
+				Namespace: "ns",
+				Domain:    "cluster.local",
+			},
+			Spec: &authn.Policy{
+				Targets: []*authn.TargetSelector{{
+					Name:  "server",
+					Ports: []*authn.PortSelector{{Port: &authn.PortSelector_Number{Number: 90}}},
+				}},
+				Peers: []*authn.PeerAuthenticationMethod{{
+					Params: &authn.PeerAuthenticationMethod_Mtls{Mtls: &authn.MutualTls{}},
+				}},
+			},
+		},
+	}
+	istioMutual := model.Config{
+		ConfigMeta: model.ConfigMeta{Type: model.DestinationRule.Type, Name: "server", Namespace: "ns"},
+		Spec: &networking.DestinationRule{
+			Host: "server.ns.svc.cluster.local",
+			TrafficPolicy: &networking.TrafficPolicy{
+				Tls: &networking.TLSSettings{Mode: networking.TLSSettings_ISTIO_MUTUAL},
+			},
+		},
+	}
+
+	cases := []struct {
+		name          string
+		clientVersion string
+		configs       []model.Config
+		want          []WorkloadMTLSDebug
+	}{
+		{
+			name:          "plaintext client",
+			clientVersion: "1.1.0",
+			configs:       policies,
+			want: []WorkloadMTLSDebug{
+				{
+					Port:                     80,
+					AuthenticationPolicyName: "default/ns",
+					DestinationRuleName:      "-",
+					ServerProtocol:           "HTTP/mTLS",
+					ClientProtocol:           "HTTP",
+					TLSConflictStatus:        "OK",
+				},
+				{
+					Port:                     90,
+					AuthenticationPolicyName: "server-tcp/ns",
+					DestinationRuleName:      "-",
+					ServerProtocol:           "mTLS",
+					ClientProtocol:           "HTTP",
+					TLSConflictStatus:        "CONFLICT",
+					Reason:                   "client sends plaintext to a STRICT server",
+				},
+			},
+		},
+		{
+			name:          "mTLS client",
+			clientVersion: "1.1.0",
+			configs:       append(policies, istioMutual),
+			want: []WorkloadMTLSDebug{
+				{
+					Port:                     80,
+					AuthenticationPolicyName: "default/ns",
+					DestinationRuleName:      "server/ns",
+					ServerProtocol:           "HTTP/mTLS",
+					ClientProtocol:           "mTLS",
+					TLSConflictStatus:        "OK",
+				},
+				{
+					Port:                     90,
+					AuthenticationPolicyName: "server-tcp/ns",
+					DestinationRuleName:      "server/ns",
+					ServerProtocol:           "mTLS",
+					ClientProtocol:           "mTLS",
+					TLSConflictStatus:        "OK",
+				},
+			},
+		},
+		{
+			name:          "mTLS client without ALPN support",
+			clientVersion: "1.0.6",
+			configs:       append(policies, istioMutual),
+			want: []WorkloadMTLSDebug{
+				{
+					Port:                     80,
+					AuthenticationPolicyName: "default/ns",
+					DestinationRuleName:      "server/ns",
+					ServerProtocol:           "HTTP/mTLS",
+					ClientProtocol:           "mTLS",
+					TLSConflictStatus:        "CONFLICT",
+					Reason:                   "client sidecar 1.0.6 does not support PERMISSIVE servers",
+				},
+				{
+					Port:                     90,
+					AuthenticationPolicyName: "server-tcp/ns",
+					DestinationRuleName:      "server/ns",
+					ServerProtocol:           "mTLS",
+					ClientProtocol:           "mTLS",
+					TLSConflictStatus:        "OK",
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := model.MakeIstioStore(memory.Make(model.IstioConfigTypes))
+			for _, cfg := range c.configs {
+				if _, err := store.Create(cfg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mesh := model.DefaultMeshConfig()
+			env := &model.Environment{
+				ServiceDiscovery: memregistry.NewDiscovery(map[model.Hostname]*model.Service{service.Hostname: service}, 1),
+				IstioConfigStore: store,
+				Mesh:             &mesh,
+				PushContext:      model.NewPushContext(),
+			}
+			if err := env.PushContext.InitContext(env); err != nil {
+				t.Fatal(err)
+			}
+			s := &DiscoveryServer{Env: env}
+
+			client := &model.Proxy{
+				ID:              "client.ns",
+				Type:            model.SidecarProxy,
+				ConfigNamespace: "ns",
+				Metadata:        map[string]string{model.NodeMetadataIstioProxyVersion: c.clientVersion},
+			}
+			for i := range c.want {
+				c.want[i].Client = client.ID
+				c.want[i].ClientVersion = c.clientVersion
+				c.want[i].Server = server.IPAddresses[0]
+				c.want[i].Host = string(service.Hostname)
+			}
+
+			servers, err := s.serverInstances("ns")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.workloadMTLSDebug([]*model.Proxy{server, client}, servers); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("workloadMTLSDebug() got:\n%+v\nwant:\n%+v", got, c.want)
+			}
+		})
+	}
+}
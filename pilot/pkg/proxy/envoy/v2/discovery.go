@@ -124,6 +124,10 @@ type DiscoveryServer struct {
 	// pushes between the 2 packages.
 	edsUpdates map[string]struct{}
 
+	// ndsCache has the addresses of the hosts without VIP of the last push, shared by the
+	// connections watching the name table.
+	ndsCache ndsCache
+
 	updateChannel chan *updateReq
 
 	// mutex used for config update scheduling (former cache update mutex)
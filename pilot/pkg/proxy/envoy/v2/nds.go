@@ -0,0 +1,202 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/dns"
+)
+
+// NameTableType is used for name discovery (NDS), by the DNS proxy of pilot-agent.
+const NameTableType = dns.NameTableType
+
+// ndsLookupTimeout is the timeout of the resolution by Pilot of an external host without VIP nor
+// endpoint.
+const ndsLookupTimeout = time.Second
+
+// lookupHost resolves a host with the resolvers of Pilot. It is replaced by the tests.
+var lookupHost = net.DefaultResolver.LookupHost
+
+func (s *DiscoveryServer) pushNds(con *XdsConnection, push *model.PushContext, version string) error {
+	nt := buildNameTable(push.Services(con.modelNode), con.modelNode, func(svc *model.Service) hostAddresses {
+		return s.ndsCache.addresses(push, version, svc, s.Env.ServiceDiscovery)
+	})
+	response, err := ndsDiscoveryResponse(nt, version)
+	if err != nil {
+		return err
+	}
+	err = con.send(response)
+	if err != nil {
+		adsLog.Warnf("NDS: Send failure %s: %v", con.ConID, err)
+		pushes.With(prometheus.Labels{"type": "nds_senderr"}).Add(1)
+		return err
+	}
+	pushes.With(prometheus.Labels{"type": "nds"}).Add(1)
+
+	adsLog.Infof("NDS: PUSH for node:%s addr:%q hosts:%d", con.modelNode.ID, con.PeerAddr, len(nt.Table))
+	return nil
+}
+
+// hostAddresses are the addresses of a host without VIP.
+type hostAddresses struct {
+	ips []string
+	// resolved is true if the addresses were resolved by Pilot, for an external host without
+	// endpoint.
+	resolved bool
+}
+
+// ndsCache has the addresses of the hosts without VIP of a push, computed once for all the
+// connections. The incremental pushes of endpoints share the push context of the last full push,
+// so a push is identified by its push context and version.
+type ndsCache struct {
+	mu      sync.Mutex
+	push    *model.PushContext
+	version string
+	hosts   map[model.Hostname]*ndsCacheEntry
+}
+
+// ndsCacheEntry has the addresses of a host, set before done is closed.
+type ndsCacheEntry struct {
+	done      chan struct{}
+	addresses hostAddresses
+}
+
+// addresses returns the addresses of the service without VIP for the push.
+func (c *ndsCache) addresses(push *model.PushContext, version string, svc *model.Service,
+	discovery model.ServiceDiscovery) hostAddresses {
+	c.mu.Lock()
+	if c.push != push || c.version != version {
+		c.push, c.version, c.hosts = push, version, map[model.Hostname]*ndsCacheEntry{}
+	}
+	e, ok := c.hosts[svc.Hostname]
+	if !ok {
+		e = &ndsCacheEntry{done: make(chan struct{})}
+		c.hosts[svc.Hostname] = e
+	}
+	c.mu.Unlock()
+	if ok {
+		<-e.done
+		return e.addresses
+	}
+
+	// The addresses are computed without the lock, as the host may be resolved by Pilot, so that
+	// only the connections waiting for this host are blocked.
+	e.addresses = hostAddresses{ips: endpointAddresses(svc, discovery)}
+	if len(e.addresses.ips) == 0 && svc.MeshExternal && svc.Resolution == model.Passthrough {
+		e.addresses = hostAddresses{ips: resolveHost(svc.Hostname), resolved: true}
+	}
+	close(e.done)
+	return e.addresses
+}
+
+// endpointAddresses returns the sorted IP addresses of the endpoints of the service.
+func endpointAddresses(svc *model.Service, discovery model.ServiceDiscovery) []string {
+	var ips []string
+	seen := map[string]bool{}
+	for _, port := range svc.Ports {
+		instances, err := discovery.InstancesByPort(svc.Hostname, port.Port, nil)
+		if err != nil {
+			adsLog.Debugf("NDS: failed to get the endpoints of %s: %v", svc.Hostname, err)
+			continue
+		}
+		for _, instance := range instances {
+			address := instance.Endpoint.Address
+			if net.ParseIP(address) != nil && !seen[address] {
+				seen[address] = true
+				ips = append(ips, address)
+			}
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// resolveHost returns the sorted IP addresses of the host resolved by Pilot, or nil if it fails.
+func resolveHost(host model.Hostname) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), ndsLookupTimeout)
+	defer cancel()
+	ips, err := lookupHost(ctx, string(host))
+	if err != nil {
+		adsLog.Debugf("NDS: failed to resolve %s: %v", host, err)
+		return nil
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// buildNameTable returns the addresses of the hosts of the services visible to the proxy. A host
+// resolves to its VIPs, or to the addresses of its endpoints if it has no VIP, like a headless
+// service or a ServiceEntry with the NONE resolution. An external host with the NONE resolution
+// and no endpoint resolves to the addresses resolved by Pilot, answered by the DNS proxy only
+// when the upstream resolvers fail. The hosts with DNS resolution, the CIDR addresses and the
+// wildcard hosts are left to the upstream resolvers.
+func buildNameTable(services []*model.Service, proxy *model.Proxy,
+	addresses func(*model.Service) hostAddresses) *dns.NameTable {
+	nt := &dns.NameTable{Table: map[string]*dns.NameInfo{}}
+	// A ServiceEntry with several addresses has a service per address.
+	vips := map[model.Hostname][]string{}
+	for _, svc := range services {
+		if strings.HasPrefix(string(svc.Hostname), "*") {
+			continue
+		}
+		if address := svc.GetServiceAddressForProxy(proxy); address != "" && address != model.UnspecifiedIP {
+			if net.ParseIP(address) != nil {
+				vips[svc.Hostname] = append(vips[svc.Hostname], address)
+			}
+			continue
+		}
+		if svc.Resolution == model.DNSLB {
+			continue
+		}
+		if a := addresses(svc); len(a.ips) > 0 {
+			nt.Table[string(svc.Hostname)] = &dns.NameInfo{Ips: a.ips, Fallback: a.resolved}
+		}
+	}
+	for host, ips := range vips {
+		sort.Strings(ips)
+		unique := ips[:1]
+		for _, ip := range ips[1:] {
+			if ip != unique[len(unique)-1] {
+				unique = append(unique, ip)
+			}
+		}
+		nt.Table[string(host)] = &dns.NameInfo{Ips: unique}
+	}
+	return nt
+}
+
+func ndsDiscoveryResponse(nt *dns.NameTable, version string) (*xdsapi.DiscoveryResponse, error) {
+	resource, err := types.MarshalAny(nt)
+	if err != nil {
+		return nil, err
+	}
+	return &xdsapi.DiscoveryResponse{
+		TypeUrl:     NameTableType,
+		VersionInfo: version,
+		Nonce:       nonce(),
+		Resources:   []types.Any{*resource},
+	}, nil
+}
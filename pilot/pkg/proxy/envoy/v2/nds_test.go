@@ -0,0 +1,186 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/dns"
+)
+
+func TestBuildNameTable(t *testing.T) {
+	ports := model.PortList{{Name: "http", Port: 80, Protocol: model.ProtocolHTTP}}
+	services := []*model.Service{
+		{Hostname: "vip.default.svc.cluster.local", Address: "10.0.0.1", Ports: ports},
+		{
+			Hostname:    "multicluster.default.svc.cluster.local",
+			Address:     "10.0.0.2",
+			ClusterVIPs: map[string]string{"cluster-2": "10.1.0.2"},
+			Ports:       ports,
+		},
+		{Hostname: "headless.default.svc.cluster.local", Address: model.UnspecifiedIP, Ports: ports, Resolution: model.Passthrough},
+		{Hostname: "external.com", Ports: ports, Resolution: model.ClientSideLB},
+		{Hostname: "dns.com", Ports: ports, Resolution: model.DNSLB},
+		{Hostname: "*.wildcard.com", Ports: ports, Resolution: model.Passthrough},
+		{Hostname: "no-endpoints.com", Ports: ports, Resolution: model.ClientSideLB},
+		// A ServiceEntry with several addresses has a service per address.
+		{Hostname: "addresses.com", Address: "10.4.0.2", Ports: ports, Resolution: model.Passthrough},
+		{Hostname: "addresses.com", Address: "10.4.0.1", Ports: ports, Resolution: model.Passthrough},
+		{Hostname: "cidr.com", Address: "10.5.0.0/16", Ports: ports, Resolution: model.Passthrough},
+		{Hostname: "none.com", Ports: ports, Resolution: model.Passthrough, MeshExternal: true},
+		{Hostname: "unresolved.com", Ports: ports, Resolution: model.Passthrough, MeshExternal: true},
+		{Hostname: "internal.com", Ports: ports, Resolution: model.Passthrough},
+	}
+	discovery := NewMemServiceDiscovery(map[model.Hostname]*model.Service{}, 0)
+	for _, svc := range services {
+		discovery.AddService(svc.Hostname, svc)
+	}
+	discovery.AddEndpoint("headless.default.svc.cluster.local", "http", 80, "10.2.0.2", 8080)
+	discovery.AddEndpoint("headless.default.svc.cluster.local", "http", 80, "10.2.0.1", 8080)
+	discovery.AddEndpoint("external.com", "http", 80, "2001:db8::1", 80)
+	discovery.AddEndpoint("external.com", "http", 80, "2001:db8::1", 80)
+	discovery.AddEndpoint("dns.com", "http", 80, "dns.com", 80)
+	discovery.AddEndpoint("*.wildcard.com", "http", 80, "10.3.0.1", 80)
+
+	// The external hosts without VIP nor endpoint are resolved by Pilot.
+	var resolved []string
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		resolved = append(resolved, host)
+		if host == "none.com" {
+			return []string{"10.6.0.2", "10.6.0.1"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	defer func() {
+		lookupHost = net.DefaultResolver.LookupHost
+	}()
+
+	var cache ndsCache
+	push := &model.PushContext{}
+	nt := buildNameTable(services, &model.Proxy{ClusterID: "cluster-2"}, func(svc *model.Service) hostAddresses {
+		return cache.addresses(push, "v1", svc, discovery)
+	})
+	want := map[string]*dns.NameInfo{
+		"vip.default.svc.cluster.local":          {Ips: []string{"10.0.0.1"}},
+		"multicluster.default.svc.cluster.local": {Ips: []string{"10.1.0.2"}},
+		"headless.default.svc.cluster.local":     {Ips: []string{"10.2.0.1", "10.2.0.2"}},
+		"external.com":                           {Ips: []string{"2001:db8::1"}},
+		"addresses.com":                          {Ips: []string{"10.4.0.1", "10.4.0.2"}},
+		"none.com":                               {Ips: []string{"10.6.0.1", "10.6.0.2"}, Fallback: true},
+	}
+	if !reflect.DeepEqual(nt.Table, want) {
+		t.Errorf("got name table %v, want %v", nt.Table, want)
+	}
+	if wantResolved := []string{"none.com", "unresolved.com"}; !reflect.DeepEqual(resolved, wantResolved) {
+		t.Errorf("resolved %v, want %v", resolved, wantResolved)
+	}
+}
+
+func TestNdsCache(t *testing.T) {
+	ports := model.PortList{{Name: "http", Port: 80, Protocol: model.ProtocolHTTP}}
+	svc := &model.Service{Hostname: "headless.default.svc.cluster.local", Ports: ports, Resolution: model.Passthrough}
+	discovery := NewMemServiceDiscovery(map[model.Hostname]*model.Service{}, 0)
+	discovery.AddService(svc.Hostname, svc)
+	discovery.AddEndpoint(svc.Hostname, "http", 80, "10.0.0.1", 8080)
+
+	var cache ndsCache
+	push := &model.PushContext{}
+	if got := cache.addresses(push, "v1", svc, discovery); !reflect.DeepEqual(got.ips, []string{"10.0.0.1"}) {
+		t.Fatalf("got %v, want the endpoint", got)
+	}
+
+	// The addresses are computed once per push, by push context and version.
+	discovery.AddEndpoint(svc.Hostname, "http", 80, "10.0.0.2", 8080)
+	if got := cache.addresses(push, "v1", svc, discovery); !reflect.DeepEqual(got.ips, []string{"10.0.0.1"}) {
+		t.Errorf("got %v in the same push, want the cached addresses", got)
+	}
+	want := []string{"10.0.0.1", "10.0.0.2"}
+	if got := cache.addresses(push, "v2", svc, discovery); !reflect.DeepEqual(got.ips, want) {
+		t.Errorf("got %v in an incremental push, want %v", got, want)
+	}
+	if got := cache.addresses(&model.PushContext{}, "v2", svc, discovery); !reflect.DeepEqual(got.ips, want) {
+		t.Errorf("got %v in a full push, want %v", got, want)
+	}
+}
+
+func TestNdsCacheResolvesWithoutLock(t *testing.T) {
+	ports := model.PortList{{Name: "http", Port: 80, Protocol: model.ProtocolHTTP}}
+	external := &model.Service{Hostname: "none.com", Ports: ports, Resolution: model.Passthrough, MeshExternal: true}
+	headless := &model.Service{Hostname: "headless.default.svc.cluster.local", Ports: ports, Resolution: model.Passthrough}
+	discovery := NewMemServiceDiscovery(map[model.Hostname]*model.Service{}, 0)
+	discovery.AddService(external.Hostname, external)
+	discovery.AddService(headless.Hostname, headless)
+	discovery.AddEndpoint(headless.Hostname, "http", 80, "10.0.0.1", 8080)
+
+	lookupStarted := make(chan struct{})
+	lookupDone := make(chan struct{})
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		close(lookupStarted)
+		<-lookupDone
+		return []string{"10.6.0.1"}, nil
+	}
+	defer func() {
+		lookupHost = net.DefaultResolver.LookupHost
+	}()
+
+	var cache ndsCache
+	push := &model.PushContext{}
+	resolved := make(chan hostAddresses, 2)
+	go func() {
+		resolved <- cache.addresses(push, "v1", external, discovery)
+	}()
+	<-lookupStarted
+	go func() {
+		resolved <- cache.addresses(push, "v1", external, discovery)
+	}()
+
+	// The other hosts are not blocked by the resolution.
+	if got := cache.addresses(push, "v1", headless, discovery); !reflect.DeepEqual(got.ips, []string{"10.0.0.1"}) {
+		t.Errorf("got %v, want the endpoint", got)
+	}
+	close(lookupDone)
+	for i := 0; i < 2; i++ {
+		if got := <-resolved; !reflect.DeepEqual(got, hostAddresses{ips: []string{"10.6.0.1"}, resolved: true}) {
+			t.Errorf("got %v, want the resolved addresses", got)
+		}
+	}
+}
+
+func TestNdsDiscoveryResponse(t *testing.T) {
+	nt := &dns.NameTable{Table: map[string]*dns.NameInfo{
+		"foo.default.svc.cluster.local": {Ips: []string{"10.0.0.1"}},
+	}}
+	resp, err := ndsDiscoveryResponse(nt, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TypeUrl != NameTableType || resp.VersionInfo != "v1" || resp.Nonce == "" || len(resp.Resources) != 1 {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	got := &dns.NameTable{}
+	if err := types.UnmarshalAny(&resp.Resources[0], got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, nt) {
+		t.Errorf("got name table %v, want %v", got, nt)
+	}
+}
@@ -17,13 +17,17 @@ package envoy
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/hex"
 	"hash"
 	"io/ioutil"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/howeyc/fsnotify"
 
+	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pkg/log"
 )
 
@@ -39,8 +43,17 @@ type Watcher interface {
 }
 
 type watcher struct {
-	certs   []string
-	updates chan<- interface{}
+	certs    []string
+	reloader *ConfigReloader
+	updates  chan<- interface{}
+
+	mu       sync.Mutex
+	lastHash string
+	// config is the last proxy configuration loaded successfully, and configHash the hash of what
+	// the proxy is started with for it. They are kept when the config files become invalid, so that
+	// the proxy is still restarted when the certificates change.
+	config     *meshconfig.ProxyConfig
+	configHash []byte
 }
 
 // NewWatcher creates a new watcher instance from a proxy agent and a set of monitored certificate file paths
@@ -53,6 +66,20 @@ func NewWatcher(
 	}
 }
 
+// NewReloadingWatcher creates a watcher which also reloads the proxy configuration when the config
+// files of the reloader change. The proxy is only restarted when its rendered bootstrap, its
+// command line or the certificates change.
+func NewReloadingWatcher(
+	certs []string,
+	reloader *ConfigReloader,
+	updates chan<- interface{}) Watcher {
+	return &watcher{
+		certs:    certs,
+		reloader: reloader,
+		updates:  updates,
+	}
+}
+
 func (w *watcher) Run(ctx context.Context) {
 	// kick start the proxy with partial state (in case there are no notifications coming)
 	w.SendConfig()
@@ -60,6 +87,11 @@ func (w *watcher) Run(ctx context.Context) {
 	// monitor certificates
 	go watchCerts(ctx, w.certs, watchFileEvents, defaultMinDelay, w.SendConfig)
 
+	// monitor the config files
+	if w.reloader != nil {
+		go watchConfig(ctx, w.reloader.files(), newFileWatcher(), configMinDelay, w.SendConfig)
+	}
+
 	<-ctx.Done()
 	log.Info("Watcher has successfully terminated")
 }
@@ -67,7 +99,46 @@ func (w *watcher) Run(ctx context.Context) {
 func (w *watcher) SendConfig() {
 	h := sha256.New()
 	generateCertHash(h, w.certs)
-	w.updates <- h.Sum(nil)
+	if w.reloader == nil {
+		w.updates <- h.Sum(nil)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.reloadConfig(); err != nil {
+		if w.config != nil {
+			log.Warnf("Failed to reload the proxy configuration, keeping the current one: %v", err)
+		} else {
+			// The proxy must start even if the config files are invalid.
+			log.Warnf("Failed to load the proxy configuration, using the command line one: %v", err)
+			w.config = proto.Clone(&w.reloader.Base).(*meshconfig.ProxyConfig)
+		}
+	}
+	_, _ = h.Write(w.configHash)
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	if hash == w.lastHash {
+		log.Info("The rendered bootstrap of the proxy is unchanged")
+		return
+	}
+	w.lastHash = hash
+	w.updates <- BootstrapConfig{ProxyConfig: w.config, Hash: hash}
+}
+
+// reloadConfig loads the proxy configuration from the config files and hashes what the proxy is
+// started with for it. The last configuration is kept on failure.
+func (w *watcher) reloadConfig() error {
+	config, err := w.reloader.Load()
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if err := w.reloader.writeHash(h, config); err != nil {
+		return err
+	}
+	w.config, w.configHash = config, h.Sum(nil)
+	return nil
 }
 
 type watchFileEventsFn func(ctx context.Context, wch <-chan *fsnotify.FileEvent,
@@ -282,7 +282,11 @@ func (a v1alpha1PolicyApplier) InboundFilterChain(sdsUdsPath string, sdsUseTrust
 
 		tls.CommonTlsContext.ValidationContextType = &auth.CommonTlsContext_CombinedValidationContext{
 			CombinedValidationContext: &auth.CommonTlsContext_CombinedCertificateValidationContext{
-				DefaultValidationContext:         &auth.CertificateValidationContext{VerifySubjectAltName: []string{} /*subjectAltNames*/},
+				DefaultValidationContext: &auth.CertificateValidationContext{
+					VerifySubjectAltName: []string{}, /*subjectAltNames*/
+					// The clients may be peers of another trust domain, whose CAs have no CRL.
+					Crl: model.ConstructCertificateRevocationList(nil),
+				},
 				ValidationContextSdsSecretConfig: model.ConstructSdsSecretConfig(model.SDSRootResourceName, sdsUdsPath, sdsUseTrustworthyJwt, sdsUseNormalJwt, meta),
 			},
 		}
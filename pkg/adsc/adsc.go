@@ -36,6 +36,8 @@ import (
 	"github.com/gogo/protobuf/types"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/pkg/dns"
 )
 
 // Config for the ADS connection.
@@ -97,6 +99,9 @@ type ADSC struct {
 	// All received endpoints, keyed by cluster name
 	EDS map[string]*xdsapi.ClusterLoadAssignment
 
+	// NameTable is the last received name table, if WatchNameTable was called.
+	NameTable *dns.NameTable
+
 	// DumpCfg will print all received config
 	DumpCfg bool
 
@@ -124,6 +129,8 @@ const (
 	listenerType = typePrefix + "Listener"
 	// RouteType is sent after listeners.
 	routeType = typePrefix + "RouteConfiguration"
+	// nameTableType is used for name discovery, by the DNS proxy.
+	nameTableType = dns.NameTableType
 )
 
 var (
@@ -269,6 +276,7 @@ func (a *ADSC) handleRecv() {
 		clusters := []*xdsapi.Cluster{}
 		routes := []*xdsapi.RouteConfiguration{}
 		eds := []*xdsapi.ClusterLoadAssignment{}
+		var nt *dns.NameTable
 		for _, rsc := range msg.Resources { // Any
 			a.VersionInfo[rsc.TypeUrl] = msg.VersionInfo
 			valBytes := rsc.Value
@@ -288,6 +296,9 @@ func (a *ADSC) handleRecv() {
 				ll := &xdsapi.RouteConfiguration{}
 				_ = proto.Unmarshal(valBytes, ll)
 				routes = append(routes, ll)
+			} else if rsc.TypeUrl == nameTableType {
+				nt = &dns.NameTable{}
+				_ = proto.Unmarshal(valBytes, nt)
 			}
 		}
 
@@ -308,6 +319,9 @@ func (a *ADSC) handleRecv() {
 		if len(routes) > 0 {
 			a.handleRDS(routes)
 		}
+		if nt != nil {
+			a.handleNDS(nt)
+		}
 	}
 
 }
@@ -600,6 +614,23 @@ func (a *ADSC) handleRDS(configurations []*xdsapi.RouteConfiguration) {
 
 }
 
+func (a *ADSC) handleNDS(nt *dns.NameTable) {
+	log.Println("NDS: ", len(nt.Table), "hosts")
+	if a.DumpCfg {
+		b, _ := json.MarshalIndent(nt, " ", " ")
+		log.Println(string(b))
+	}
+
+	a.mutex.Lock()
+	a.NameTable = nt
+	a.mutex.Unlock()
+
+	select {
+	case a.Updates <- "nds":
+	default:
+	}
+}
+
 // WaitClear will clear the waiting events, so next call to Wait will get
 // the next push type.
 func (a *ADSC) WaitClear() {
@@ -645,6 +676,23 @@ func (a *ADSC) Watch() {
 	})
 }
 
+// WatchNameTable will start watching the name table, with the addresses of the hosts of the
+// services visible to the node.
+func (a *ADSC) WatchNameTable() {
+	_ = a.stream.Send(&xdsapi.DiscoveryRequest{
+		ResponseNonce: time.Now().String(),
+		Node:          a.node(),
+		TypeUrl:       nameTableType,
+	})
+}
+
+// GetNameTable returns the last received name table, or nil if none was received.
+func (a *ADSC) GetNameTable() *dns.NameTable {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.NameTable
+}
+
 func (a *ADSC) sendRsc(typeurl string, rsc []string) {
 	_ = a.stream.Send(&xdsapi.DiscoveryRequest{
 		ResponseNonce: "",
@@ -15,6 +15,7 @@
 package bootstrap
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -197,20 +198,53 @@ func getNodeMetaData(envs []string) map[string]string {
 	return meta
 }
 
+// NodeMetadata returns the metadata of the node sent by Envoy to Pilot, from the environment and
+// the IP addresses of the node.
+func NodeMetadata(localEnv []string, nodeIPs []string) map[string]string {
+	meta := getNodeMetaData(localEnv)
+	// Support multiple network interfaces
+	meta["ISTIO_META_INSTANCE_IPS"] = strings.Join(nodeIPs, ",")
+	return meta
+}
+
 var overrideVar = env.RegisterStringVar("ISTIO_BOOTSTRAP", "", "")
 
 // WriteBootstrap generates an envoy config based on config and epoch, and returns the filename.
 // TODO: in v2 some of the LDS ports (port, http_port) should be configured in the bootstrap.
 func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilotSAN []string,
 	opts map[string]interface{}, localEnv []string, nodeIPs []string) (string, error) {
-	if opts == nil {
-		opts = map[string]interface{}{}
+	out, err := GenerateBootstrap(config, node, pilotSAN, opts, localEnv, nodeIPs)
+	if err != nil {
+		return "", err
 	}
 	if err := os.MkdirAll(config.ConfigPath, 0700); err != nil {
 		return "", err
 	}
+	// The rendered config refers to the access token file of Lightstep.
+	if lightstep := config.GetTracing().GetLightstep(); lightstep != nil {
+		if err := ioutil.WriteFile(lightstepAccessTokenFile(config.ConfigPath), []byte(lightstep.AccessToken), 0600); err != nil {
+			return "", err
+		}
+	}
 	// attempt to write file
 	fname := configFile(config.ConfigPath, epoch)
+	if err := ioutil.WriteFile(fname, out, 0644); err != nil {
+		return "", err
+	}
+	return fname, nil
+}
+
+// GenerateBootstrap renders the envoy config based on config, without writing any file nor
+// modifying opts. The rendering only depends on its arguments, the environment and the version of
+// the Envoy binary, so it can be compared with a previous rendering to find out whether the proxy
+// must be restarted. The files the config refers to, like the Lightstep access token, are written
+// by WriteBootstrap.
+func GenerateBootstrap(config *meshconfig.ProxyConfig, node string, pilotSAN []string,
+	baseOpts map[string]interface{}, localEnv []string, nodeIPs []string) ([]byte, error) {
+	opts := make(map[string]interface{}, len(baseOpts))
+	for k, v := range baseOpts {
+		opts[k] = v
+	}
 
 	cfg := config.CustomConfigFile
 	if cfg == "" {
@@ -227,16 +261,24 @@ func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilo
 
 	cfgTmpl, err := ioutil.ReadFile(cfg)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	t, err := template.New("bootstrap").Parse(string(cfgTmpl))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	opts["config"] = config
 
+	if _, ok := opts["envoy_version"]; !ok {
+		version, err := GetEnvoyVersion(config.BinaryPath)
+		if err != nil {
+			log.Warnf("Unknown Envoy version, the bootstrap is rendered for the latest one: %v", err)
+		}
+		opts["envoy_version"] = version
+	}
+
 	if pilotSAN == nil {
 		pilotSAN = defaultPilotSan()
 	}
@@ -257,7 +299,7 @@ func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilo
 	}
 
 	// Support passing extra info from node environment as metadata
-	meta := getNodeMetaData(localEnv)
+	meta := NodeMetadata(localEnv, nodeIPs)
 
 	if inclusionPatterns, ok := meta[EnvoyStatsMatcherInclusionPatterns]; ok {
 		opts["inclusionPatterns"] = strings.Split(inclusionPatterns, ",")
@@ -265,12 +307,9 @@ func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilo
 		opts["inclusionPatterns"] = defaultEnvoyStatsMatcherInclusionPatterns
 	}
 
-	// Support multiple network interfaces
-	meta["ISTIO_META_INSTANCE_IPS"] = strings.Join(nodeIPs, ",")
-
 	ba, err := json.Marshal(meta)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	opts["meta_json_str"] = string(ba)
 
@@ -280,7 +319,7 @@ func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilo
 
 	h, p, err := GetHostPort("Discovery", config.DiscoveryAddress)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	StoreHostPort(h, p, "pilot_grpc_address", opts)
 
@@ -303,32 +342,23 @@ func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilo
 		case *meshconfig.Tracing_Zipkin_:
 			h, p, err = GetHostPort("Zipkin", tracer.Zipkin.Address)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 			StoreHostPort(h, p, "zipkin", opts)
 		case *meshconfig.Tracing_Lightstep_:
 			h, p, err = GetHostPort("Lightstep", tracer.Lightstep.Address)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 			StoreHostPort(h, p, "lightstep", opts)
 
-			lightstepAccessTokenPath := lightstepAccessTokenFile(config.ConfigPath)
-			lsConfigOut, err := os.Create(lightstepAccessTokenPath)
-			if err != nil {
-				return "", err
-			}
-			_, err = lsConfigOut.WriteString(tracer.Lightstep.AccessToken)
-			if err != nil {
-				return "", err
-			}
-			opts["lightstepToken"] = lightstepAccessTokenPath
+			opts["lightstepToken"] = lightstepAccessTokenFile(config.ConfigPath)
 			opts["lightstepSecure"] = tracer.Lightstep.Secure
 			opts["lightstepCacertPath"] = tracer.Lightstep.CacertPath
 		case *meshconfig.Tracing_Datadog_:
 			h, p, err = GetHostPort("Datadog", tracer.Datadog.Address)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 			StoreHostPort(h, p, "datadog", opts)
 		}
@@ -337,7 +367,7 @@ func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilo
 	if config.StatsdUdpAddress != "" {
 		h, p, err = GetHostPort("statsd UDP", config.StatsdUdpAddress)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		StoreHostPort(h, p, "statsd", opts)
 	}
@@ -345,20 +375,16 @@ func WriteBootstrap(config *meshconfig.ProxyConfig, node string, epoch int, pilo
 	if config.EnvoyMetricsServiceAddress != "" {
 		h, p, err = GetHostPort("envoy metrics service", config.EnvoyMetricsServiceAddress)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		StoreHostPort(h, p, "envoy_metrics_service", opts)
 	}
 
-	fout, err := os.Create(fname)
-	if err != nil {
-		return "", err
+	var out bytes.Buffer
+	if err := t.Execute(&out, opts); err != nil {
+		return nil, err
 	}
-	defer fout.Close()
-
-	// Execute needs some sort of io.Writer
-	err = t.Execute(fout, opts)
-	return fname, err
+	return out.Bytes(), nil
 }
 
 // isIPv6Proxy check the addresses slice and returns true for a valid IPv6 address
@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EnvoyVersion is the version of the Envoy binary, available to the bootstrap templates as
+// `.envoy_version`. Templates can branch on it, for example:
+//
+//	{{ if .envoy_version.AtLeast "1.12" }} ... {{ end }}
+//
+// An unknown version, when the binary cannot be run or its output is not recognized, is
+// considered to be the latest one.
+type EnvoyVersion struct {
+	// Major, Minor and Patch are the numbers of the version, all 0 if it is unknown.
+	Major, Minor, Patch int
+}
+
+var (
+	// envoyVersionRegexp matches the version of the output of `envoy --version`, for example:
+	// envoy  version: 2d1e5db3b3bbe5a3b0b7e3b5ac2f3c7f4ad8d1c2/1.12.0/Clean/RELEASE/BoringSSL
+	envoyVersionRegexp = regexp.MustCompile(`version: [0-9a-f]+/(\d+)\.(\d+)(?:\.(\d+))?`)
+
+	envoyVersionsMutex sync.Mutex
+	// envoyVersions caches the version of each binary, or the error getting it, as running it for
+	// each rendering of the bootstrap is needlessly slow.
+	envoyVersions = map[string]envoyVersionResult{}
+)
+
+type envoyVersionResult struct {
+	version EnvoyVersion
+	err     error
+}
+
+// Known returns true if the version was detected.
+func (v EnvoyVersion) Known() bool {
+	return v != EnvoyVersion{}
+}
+
+// AtLeast returns true if the version is the same or more recent than the given one, like "1.12"
+// or "1.12.1". An unknown version is at least any version.
+func (v EnvoyVersion) AtLeast(version string) (bool, error) {
+	other, err := ParseEnvoyVersion(version)
+	if err != nil {
+		return false, err
+	}
+	if !v.Known() {
+		return true, nil
+	}
+	if v.Major != other.Major {
+		return v.Major > other.Major, nil
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor, nil
+	}
+	return v.Patch >= other.Patch, nil
+}
+
+func (v EnvoyVersion) String() string {
+	if !v.Known() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// ParseEnvoyVersion parses a version like "1.12" or "1.12.1".
+func ParseEnvoyVersion(version string) (EnvoyVersion, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return EnvoyVersion{}, fmt.Errorf("invalid Envoy version %q", version)
+	}
+	numbers := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return EnvoyVersion{}, fmt.Errorf("invalid Envoy version %q", version)
+		}
+		numbers[i] = n
+	}
+	return EnvoyVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// parseEnvoyVersionOutput extracts the version from the output of `envoy --version`.
+func parseEnvoyVersionOutput(out string) (EnvoyVersion, error) {
+	m := envoyVersionRegexp.FindStringSubmatch(out)
+	if m == nil {
+		return EnvoyVersion{}, fmt.Errorf("no version in %q", strings.TrimSpace(out))
+	}
+	version := m[1] + "." + m[2]
+	if m[3] != "" {
+		version += "." + m[3]
+	}
+	return ParseEnvoyVersion(version)
+}
+
+// GetEnvoyVersion returns the version reported by the Envoy binary, or an unknown version if it
+// cannot be determined. The binary is only run once, the failures are cached as well.
+func GetEnvoyVersion(binaryPath string) (EnvoyVersion, error) {
+	envoyVersionsMutex.Lock()
+	defer envoyVersionsMutex.Unlock()
+	if r, ok := envoyVersions[binaryPath]; ok {
+		return r.version, r.err
+	}
+
+	var r envoyVersionResult
+	out, err := exec.Command(binaryPath, "--version").CombinedOutput()
+	if err != nil {
+		r.err = fmt.Errorf("failed to run %s --version: %v", binaryPath, err)
+	} else {
+		r.version, r.err = parseEnvoyVersionOutput(string(out))
+	}
+	envoyVersions[binaryPath] = r
+	return r.version, r.err
+}
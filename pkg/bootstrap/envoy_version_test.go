@@ -0,0 +1,195 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+)
+
+func TestParseEnvoyVersionOutput(t *testing.T) {
+	cases := []struct {
+		out  string
+		want EnvoyVersion
+		err  bool
+	}{
+		{
+			out:  "\nenvoy  version: 2d1e5db3b3bbe5a3b0b7e3b5ac2f3c7f4ad8d1c2/1.12.0/Clean/RELEASE/BoringSSL\n\n",
+			want: EnvoyVersion{Major: 1, Minor: 12},
+		},
+		{
+			out:  "envoy  version: 2d1e5db3/1.11.2-dev/Modified/DEBUG/BoringSSL",
+			want: EnvoyVersion{Major: 1, Minor: 11, Patch: 2},
+		},
+		{
+			out: "unknown option --version",
+			err: true,
+		},
+	}
+	for _, c := range cases {
+		got, err := parseEnvoyVersionOutput(c.out)
+		if c.err != (err != nil) {
+			t.Errorf("parseEnvoyVersionOutput(%q) got error %v, want error %v", c.out, err, c.err)
+		}
+		if got != c.want {
+			t.Errorf("parseEnvoyVersionOutput(%q) got %v, want %v", c.out, got, c.want)
+		}
+	}
+}
+
+func TestEnvoyVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		version EnvoyVersion
+		other   string
+		want    bool
+	}{
+		{EnvoyVersion{Major: 1, Minor: 12}, "1.12", true},
+		{EnvoyVersion{Major: 1, Minor: 12}, "1.12.1", false},
+		{EnvoyVersion{Major: 1, Minor: 12, Patch: 3}, "1.11.5", true},
+		{EnvoyVersion{Major: 1, Minor: 9}, "1.10", false},
+		{EnvoyVersion{Major: 2}, "1.13", true},
+		{EnvoyVersion{}, "1.13", true},
+	}
+	for _, c := range cases {
+		got, err := c.version.AtLeast(c.other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%v.AtLeast(%q) got %v, want %v", c.version, c.other, got, c.want)
+		}
+	}
+
+	if _, err := (EnvoyVersion{}).AtLeast("1.x"); err == nil {
+		t.Error("expected an error for an invalid version")
+	}
+}
+
+func TestGenerateBootstrapEnvoyVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bootstrap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	tmpl := filepath.Join(dir, "envoy_bootstrap_tmpl.json")
+	if err := ioutil.WriteFile(tmpl,
+		[]byte(`{{ if .envoy_version.AtLeast "1.12" }}new{{ else }}old{{ end }} {{ .envoy_version }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &meshconfig.ProxyConfig{
+		ConfigPath:                 dir,
+		ConnectTimeout:             types.DurationProto(time.Second),
+		BinaryPath:                 filepath.Join(dir, "envoy"),
+		DiscoveryAddress:           "istio-pilot:15010",
+		ProxyBootstrapTemplatePath: tmpl,
+	}
+
+	cases := []struct {
+		version interface{}
+		want    string
+	}{
+		{EnvoyVersion{Major: 1, Minor: 11, Patch: 1}, "old 1.11.1"},
+		{EnvoyVersion{Major: 1, Minor: 12}, "new 1.12.0"},
+		// The binary does not exist, so its version is unknown.
+		{nil, "new unknown"},
+	}
+	for _, c := range cases {
+		opts := map[string]interface{}{}
+		if c.version != nil {
+			opts["envoy_version"] = c.version
+		}
+		out, err := GenerateBootstrap(cfg, "sidecar~1.2.3.4~foo~bar", nil, opts, nil, []string{"10.3.3.3"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != c.want {
+			t.Errorf("got %q, want %q", out, c.want)
+		}
+	}
+}
+
+func TestGetEnvoyVersionCachesFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envoy_version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	binary := filepath.Join(dir, "envoy")
+	if _, err := GetEnvoyVersion(binary); err == nil {
+		t.Fatal("expected an error for a missing binary")
+	}
+
+	// The binary is not run again once it failed.
+	script := "#!/bin/sh\necho 'envoy  version: 2d1e5db3/1.12.0/Clean/RELEASE/BoringSSL'\n"
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := GetEnvoyVersion(binary); err == nil || v.Known() {
+		t.Errorf("got version %v and error %v, want the cached failure", v, err)
+	}
+}
+
+func TestGenerateBootstrapNoSideEffects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bootstrap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	tmpl := filepath.Join(dir, "envoy_bootstrap_tmpl.json")
+	if err := ioutil.WriteFile(tmpl, []byte(`{{ .lightstepToken }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &meshconfig.ProxyConfig{
+		ConfigPath:                 filepath.Join(dir, "config"),
+		ConnectTimeout:             types.DurationProto(time.Second),
+		BinaryPath:                 filepath.Join(dir, "envoy"),
+		DiscoveryAddress:           "istio-pilot:15010",
+		ProxyBootstrapTemplatePath: tmpl,
+		Tracing: &meshconfig.Tracing{Tracer: &meshconfig.Tracing_Lightstep_{Lightstep: &meshconfig.Tracing_Lightstep{
+			Address:     "lightstep:8080",
+			AccessToken: "secret",
+		}}},
+	}
+	opts := map[string]interface{}{"envoy_version": EnvoyVersion{Major: 1, Minor: 12}}
+
+	out, err := GenerateBootstrap(cfg, "sidecar~1.2.3.4~foo~bar", nil, opts, nil, []string{"10.3.3.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := lightstepAccessTokenFile(cfg.ConfigPath)
+	if string(out) != token {
+		t.Errorf("got %q, want the token file %s", out, token)
+	}
+	if _, err := os.Stat(cfg.ConfigPath); !os.IsNotExist(err) {
+		t.Errorf("the config path is created: %v", err)
+	}
+	if len(opts) != 1 {
+		t.Errorf("the options are modified: %v", opts)
+	}
+
+	if _, err := WriteBootstrap(cfg, "sidecar~1.2.3.4~foo~bar", 0, nil, opts, nil, []string{"10.3.3.3"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(token); err != nil || string(data) != "secret" {
+		t.Errorf("got token %q and error %v", data, err)
+	}
+}
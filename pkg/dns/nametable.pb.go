@@ -0,0 +1,828 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: pkg/dns/nametable.proto
+
+package dns // import "istio.io/istio/pkg/dns"
+
+/*
+	Name discovery (NDS) resources, pushed by Pilot over ADS to the DNS proxy of pilot-agent.
+*/
+
+import proto "github.com/gogo/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import strings "strings"
+import reflect "reflect"
+import github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
+
+import io "io"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
+
+// NameTable has the addresses of the hosts of the services visible to a proxy.
+type NameTable struct {
+	// Map of the fully qualified host names to their addresses.
+	Table map[string]*NameInfo `protobuf:"bytes,1,rep,name=table,proto3" json:"table,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *NameTable) Reset()      { *m = NameTable{} }
+func (*NameTable) ProtoMessage() {}
+func (*NameTable) Descriptor() ([]byte, []int) {
+	return fileDescriptor_nametable_93c71f44644c064f, []int{0}
+}
+func (m *NameTable) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *NameTable) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_NameTable.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *NameTable) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NameTable.Merge(dst, src)
+}
+func (m *NameTable) XXX_Size() int {
+	return m.Size()
+}
+func (m *NameTable) XXX_DiscardUnknown() {
+	xxx_messageInfo_NameTable.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NameTable proto.InternalMessageInfo
+
+func (m *NameTable) GetTable() map[string]*NameInfo {
+	if m != nil {
+		return m.Table
+	}
+	return nil
+}
+
+// NameInfo has the addresses of a host.
+type NameInfo struct {
+	// The IPv4 and IPv6 addresses of the host: its VIP, or the addresses of its endpoints for a
+	// host without a VIP.
+	Ips []string `protobuf:"bytes,1,rep,name=ips,proto3" json:"ips,omitempty"`
+	// Whether the addresses were resolved by Pilot, for an external host without VIP nor endpoint.
+	// They are answered only if the upstream resolvers fail to resolve the host.
+	Fallback bool `protobuf:"varint,2,opt,name=fallback,proto3" json:"fallback,omitempty"`
+}
+
+func (m *NameInfo) Reset()      { *m = NameInfo{} }
+func (*NameInfo) ProtoMessage() {}
+func (*NameInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_nametable_93c71f44644c064f, []int{1}
+}
+func (m *NameInfo) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *NameInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_NameInfo.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *NameInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NameInfo.Merge(dst, src)
+}
+func (m *NameInfo) XXX_Size() int {
+	return m.Size()
+}
+func (m *NameInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_NameInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NameInfo proto.InternalMessageInfo
+
+func (m *NameInfo) GetIps() []string {
+	if m != nil {
+		return m.Ips
+	}
+	return nil
+}
+
+func (m *NameInfo) GetFallback() bool {
+	if m != nil {
+		return m.Fallback
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterType((*NameTable)(nil), "istio.networking.nds.v1.NameTable")
+	proto.RegisterMapType((map[string]*NameInfo)(nil), "istio.networking.nds.v1.NameTable.TableEntry")
+	proto.RegisterType((*NameInfo)(nil), "istio.networking.nds.v1.NameInfo")
+}
+func (this *NameTable) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*NameTable)
+	if !ok {
+		that2, ok := that.(NameTable)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Table) != len(that1.Table) {
+		return false
+	}
+	for i := range this.Table {
+		if !this.Table[i].Equal(that1.Table[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *NameInfo) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*NameInfo)
+	if !ok {
+		that2, ok := that.(NameInfo)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Ips) != len(that1.Ips) {
+		return false
+	}
+	for i := range this.Ips {
+		if this.Ips[i] != that1.Ips[i] {
+			return false
+		}
+	}
+	if this.Fallback != that1.Fallback {
+		return false
+	}
+	return true
+}
+func (this *NameTable) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&dns.NameTable{")
+	keysForTable := make([]string, 0, len(this.Table))
+	for k, _ := range this.Table {
+		keysForTable = append(keysForTable, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForTable)
+	mapStringForTable := "map[string]*NameInfo{"
+	for _, k := range keysForTable {
+		mapStringForTable += fmt.Sprintf("%#v: %#v,", k, this.Table[k])
+	}
+	mapStringForTable += "}"
+	if this.Table != nil {
+		s = append(s, "Table: "+mapStringForTable+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *NameInfo) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&dns.NameInfo{")
+	s = append(s, "Ips: "+fmt.Sprintf("%#v", this.Ips)+",\n")
+	s = append(s, "Fallback: "+fmt.Sprintf("%#v", this.Fallback)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringNametable(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+func (m *NameTable) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *NameTable) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Table) > 0 {
+		for k, _ := range m.Table {
+			dAtA[i] = 0xa
+			i++
+			v := m.Table[k]
+			msgSize := 0
+			if v != nil {
+				msgSize = v.Size()
+				msgSize += 1 + sovNametable(uint64(msgSize))
+			}
+			mapSize := 1 + len(k) + sovNametable(uint64(len(k))) + msgSize
+			i = encodeVarintNametable(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintNametable(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			if v != nil {
+				dAtA[i] = 0x12
+				i++
+				i = encodeVarintNametable(dAtA, i, uint64(v.Size()))
+				n1, err := v.MarshalTo(dAtA[i:])
+				if err != nil {
+					return 0, err
+				}
+				i += n1
+			}
+		}
+	}
+	return i, nil
+}
+
+func (m *NameInfo) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *NameInfo) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Ips) > 0 {
+		for _, s := range m.Ips {
+			dAtA[i] = 0xa
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.Fallback {
+		dAtA[i] = 0x10
+		i++
+		if m.Fallback {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	return i, nil
+}
+
+func encodeVarintNametable(dAtA []byte, offset int, v uint64) int {
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return offset + 1
+}
+func (m *NameTable) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Table) > 0 {
+		for k, v := range m.Table {
+			_ = k
+			_ = v
+			l = 0
+			if v != nil {
+				l = v.Size()
+				l += 1 + sovNametable(uint64(l))
+			}
+			mapEntrySize := 1 + len(k) + sovNametable(uint64(len(k))) + l
+			n += mapEntrySize + 1 + sovNametable(uint64(mapEntrySize))
+		}
+	}
+	return n
+}
+
+func (m *NameInfo) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Ips) > 0 {
+		for _, s := range m.Ips {
+			l = len(s)
+			n += 1 + l + sovNametable(uint64(l))
+		}
+	}
+	if m.Fallback {
+		n += 2
+	}
+	return n
+}
+
+func sovNametable(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+func sozNametable(x uint64) (n int) {
+	return sovNametable(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *NameTable) String() string {
+	if this == nil {
+		return "nil"
+	}
+	keysForTable := make([]string, 0, len(this.Table))
+	for k, _ := range this.Table {
+		keysForTable = append(keysForTable, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForTable)
+	mapStringForTable := "map[string]*NameInfo{"
+	for _, k := range keysForTable {
+		mapStringForTable += fmt.Sprintf("%v: %v,", k, this.Table[k])
+	}
+	mapStringForTable += "}"
+	s := strings.Join([]string{`&NameTable{`,
+		`Table:` + mapStringForTable + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *NameInfo) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&NameInfo{`,
+		`Ips:` + fmt.Sprintf("%v", this.Ips) + `,`,
+		`Fallback:` + fmt.Sprintf("%v", this.Fallback) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringNametable(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *NameTable) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowNametable
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: NameTable: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: NameTable: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Table", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowNametable
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthNametable
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Table == nil {
+				m.Table = make(map[string]*NameInfo)
+			}
+			var mapkey string
+			var mapvalue *NameInfo
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowNametable
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowNametable
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthNametable
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var mapmsglen int
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowNametable
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						mapmsglen |= (int(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					if mapmsglen < 0 {
+						return ErrInvalidLengthNametable
+					}
+					postmsgIndex := iNdEx + mapmsglen
+					if mapmsglen < 0 {
+						return ErrInvalidLengthNametable
+					}
+					if postmsgIndex > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = &NameInfo{}
+					if err := mapvalue.Unmarshal(dAtA[iNdEx:postmsgIndex]); err != nil {
+						return err
+					}
+					iNdEx = postmsgIndex
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipNametable(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthNametable
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Table[mapkey] = mapvalue
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipNametable(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthNametable
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *NameInfo) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowNametable
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: NameInfo: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: NameInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ips", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowNametable
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthNametable
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Ips = append(m.Ips, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Fallback", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowNametable
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Fallback = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipNametable(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthNametable
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipNametable(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowNametable
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowNametable
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+			return iNdEx, nil
+		case 1:
+			iNdEx += 8
+			return iNdEx, nil
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowNametable
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			iNdEx += length
+			if length < 0 {
+				return 0, ErrInvalidLengthNametable
+			}
+			return iNdEx, nil
+		case 3:
+			for {
+				var innerWire uint64
+				var start int = iNdEx
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return 0, ErrIntOverflowNametable
+					}
+					if iNdEx >= l {
+						return 0, io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					innerWire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				innerWireType := int(innerWire & 0x7)
+				if innerWireType == 4 {
+					break
+				}
+				next, err := skipNametable(dAtA[start:])
+				if err != nil {
+					return 0, err
+				}
+				iNdEx = start + next
+			}
+			return iNdEx, nil
+		case 4:
+			return iNdEx, nil
+		case 5:
+			iNdEx += 4
+			return iNdEx, nil
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+	}
+	panic("unreachable")
+}
+
+var (
+	ErrInvalidLengthNametable = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowNametable   = fmt.Errorf("proto: integer overflow")
+)
+
+func init() { proto.RegisterFile("pkg/dns/nametable.proto", fileDescriptor_nametable_93c71f44644c064f) }
+
+var fileDescriptor_nametable_93c71f44644c064f = []byte{
+	// 276 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x2f, 0xc8, 0x4e, 0xd7,
+	0x4f, 0xc9, 0x2b, 0xd6, 0xcf, 0x4b, 0xcc, 0x4d, 0x2d, 0x49, 0x4c, 0xca, 0x49, 0xd5, 0x2b, 0x28,
+	0xca, 0x2f, 0xc9, 0x17, 0x12, 0xcf, 0x2c, 0x2e, 0xc9, 0xcc, 0xd7, 0xcb, 0x4b, 0x2d, 0x29, 0xcf,
+	0x2f, 0xca, 0xce, 0xcc, 0x4b, 0xd7, 0xcb, 0x4b, 0x29, 0xd6, 0x2b, 0x33, 0x54, 0x5a, 0xcb, 0xc8,
+	0xc5, 0xe9, 0x97, 0x98, 0x9b, 0x1a, 0x02, 0x52, 0x2c, 0xe4, 0xcc, 0xc5, 0x0a, 0xd6, 0x25, 0xc1,
+	0xa8, 0xc0, 0xac, 0xc1, 0x6d, 0xa4, 0xab, 0x87, 0x43, 0x9b, 0x1e, 0x5c, 0x8b, 0x1e, 0x98, 0x74,
+	0xcd, 0x2b, 0x29, 0xaa, 0x0c, 0x82, 0xe8, 0x95, 0x8a, 0xe6, 0xe2, 0x42, 0x08, 0x0a, 0x09, 0x70,
+	0x31, 0x67, 0xa7, 0x56, 0x4a, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x81, 0x98, 0x42, 0xe6, 0x5c,
+	0xac, 0x65, 0x89, 0x39, 0xa5, 0xa9, 0x12, 0x4c, 0x0a, 0x8c, 0x1a, 0xdc, 0x46, 0x8a, 0x78, 0x2d,
+	0xf1, 0xcc, 0x4b, 0xcb, 0x0f, 0x82, 0xa8, 0xb7, 0x62, 0xb2, 0x60, 0x54, 0xb2, 0xe0, 0xe2, 0x80,
+	0x09, 0x83, 0x8c, 0xce, 0x2c, 0x28, 0x06, 0xbb, 0x95, 0x33, 0x08, 0xc4, 0x14, 0x92, 0xe2, 0xe2,
+	0x48, 0x4b, 0xcc, 0xc9, 0x49, 0x4a, 0x4c, 0xce, 0x06, 0x9b, 0xce, 0x11, 0x04, 0xe7, 0x3b, 0xf9,
+	0x5c, 0x78, 0x28, 0xc7, 0x70, 0xe3, 0xa1, 0x1c, 0xc3, 0x87, 0x87, 0x72, 0x8c, 0x0d, 0x8f, 0xe4,
+	0x18, 0x57, 0x3c, 0x92, 0x63, 0x3c, 0xf1, 0x48, 0x8e, 0xf1, 0xc2, 0x23, 0x39, 0xc6, 0x07, 0x8f,
+	0xe4, 0x18, 0x5f, 0x3c, 0x92, 0x63, 0xf8, 0xf0, 0x48, 0x8e, 0x71, 0xc2, 0x63, 0x39, 0x86, 0x0b,
+	0x8f, 0xe5, 0x18, 0x6e, 0x3c, 0x96, 0x63, 0x88, 0x12, 0x83, 0x38, 0x2e, 0x33, 0x5f, 0x1f, 0xcc,
+	0xd0, 0x87, 0x06, 0x70, 0x12, 0x1b, 0x38, 0x5c, 0x8d, 0x01, 0x03, 0x00, 0x4e, 0x93, 0x0d, 0x9b,
+	0x72, 0x01, 0x00, 0x00,
+}
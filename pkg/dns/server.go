@@ -0,0 +1,495 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dns implements the DNS proxy of pilot-agent. It answers the queries for the hosts of
+// the services of the mesh from the name table pushed by Pilot, and forwards the other queries
+// to the upstream resolvers. The name table is the NDS resource of nametable.proto.
+package dns
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"istio.io/istio/pkg/log"
+)
+
+// NameTableType is the type URL of the name table resource sent over ADS.
+const NameTableType = "type.googleapis.com/istio.networking.nds.v1.NameTable"
+
+const (
+	// ttl is the TTL of the answers from the name table, in seconds. It is short since the table
+	// changes with the service registry.
+	ttl = 30
+
+	// maxUDPSize is the maximum size of a response over UDP to a query without EDNS(0).
+	maxUDPSize = 512
+
+	// ednsUDPSize is the UDP payload size advertised in the responses to the EDNS(0) queries.
+	ednsUDPSize = 4096
+
+	// maxMessageSize is the maximum size of a DNS message.
+	maxMessageSize = 65535
+
+	// defaultUpstreamTimeout is the timeout of a query forwarded to an upstream resolver.
+	defaultUpstreamTimeout = 2 * time.Second
+
+	// tcpIdleTimeout is the time after which an idle TCP connection is closed.
+	tcpIdleTimeout = 10 * time.Second
+)
+
+var (
+	dnsLog = log.RegisterScope("dns", "DNS proxy debugging", 0)
+
+	errNoUpstream = errors.New("no upstream resolver answered")
+)
+
+// Server is a DNS proxy over UDP and TCP, listening on the same port. The clients retry over
+// TCP when a response over UDP is truncated.
+type Server struct {
+	conn      net.PacketConn
+	listener  net.Listener
+	upstreams []string
+	timeout   time.Duration
+
+	mu sync.RWMutex
+	// hosts are the hosts of the name table, keyed by lower case fully qualified name with the
+	// trailing dot.
+	hosts map[string]host
+}
+
+// host is a host of the name table.
+type host struct {
+	ips []net.IP
+	// fallback is true if the host is answered only when the upstream resolvers fail.
+	fallback bool
+}
+
+// NewServer creates a DNS proxy listening on the given UDP and TCP address, and forwarding the
+// queries for the hosts which are not in the name table to the upstream resolvers ("host:port").
+func NewServer(addr string, upstreams []string) (*Server, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// Listen on the port picked for UDP if addr has none.
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &Server{
+		conn:      conn,
+		listener:  listener,
+		upstreams: upstreams,
+		timeout:   defaultUpstreamTimeout,
+		hosts:     map[string]host{},
+	}, nil
+}
+
+// Address returns the address the server listens on.
+func (s *Server) Address() string {
+	return s.conn.LocalAddr().String()
+}
+
+// UpdateNameTable replaces the hosts answered by the server.
+func (s *Server) UpdateNameTable(nt *NameTable) {
+	hosts := make(map[string]host, len(nt.Table))
+	for name, info := range nt.Table {
+		if info == nil {
+			continue
+		}
+		h := host{fallback: info.Fallback}
+		for _, addr := range info.Ips {
+			if ip := net.ParseIP(addr); ip != nil {
+				h.ips = append(h.ips, ip)
+			}
+		}
+		hosts[strings.ToLower(strings.TrimSuffix(name, "."))+"."] = h
+	}
+	s.mu.Lock()
+	s.hosts = hosts
+	s.mu.Unlock()
+	dnsLog.Infof("Updated the name table: %d hosts", len(hosts))
+}
+
+// Run serves the queries until the context is canceled.
+func (s *Server) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		_ = s.conn.Close()
+		_ = s.listener.Close()
+	}()
+	log.Infof("DNS proxy listening on %s, upstream resolvers: %v", s.Address(), s.upstreams)
+	go s.serveTCP(ctx)
+	for {
+		buf := make([]byte, maxMessageSize)
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				log.Info("DNS proxy has successfully terminated")
+				return
+			default:
+			}
+			dnsLog.Warnf("Failed to read a DNS query: %v", err)
+			continue
+		}
+		go s.serveUDP(buf[:n], addr)
+	}
+}
+
+func (s *Server) serveUDP(query []byte, addr net.Addr) {
+	resp := s.answer("udp", query)
+	if resp == nil {
+		return
+	}
+	if _, err := s.conn.WriteTo(resp, addr); err != nil {
+		dnsLog.Debugf("Failed to send a DNS response to %v: %v", addr, err)
+	}
+}
+
+func (s *Server) serveTCP(ctx context.Context) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			dnsLog.Warnf("Failed to accept a DNS connection: %v", err)
+			continue
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn answers the queries of a TCP connection until it is closed or idle.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+	for {
+		if err := conn.SetDeadline(time.Now().Add(tcpIdleTimeout)); err != nil {
+			return
+		}
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			if err != io.EOF {
+				dnsLog.Debugf("Failed to read a DNS query from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		resp := s.answer("tcp", query)
+		if resp == nil {
+			return
+		}
+		if err := writeTCPMessage(conn, resp); err != nil {
+			dnsLog.Debugf("Failed to send a DNS response to %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// answer returns the response to a query received over the network, or nil if the query is
+// invalid.
+func (s *Server) answer(network string, query []byte) []byte {
+	maxSize := maxMessageSize
+	if network == "udp" {
+		maxSize = udpSize(query)
+	}
+	local, fallback, ok := s.lookup(query, maxSize)
+	if ok && !fallback {
+		return local
+	}
+	resp, err := s.forward(network, query)
+	if ok && (err != nil || !resolved(resp)) {
+		return local
+	}
+	if err != nil {
+		dnsLog.Debugf("Failed to forward a DNS query: %v", err)
+		return serverFailure(query)
+	}
+	return resp
+}
+
+// resolved returns true if the response of an upstream resolver is not an error, like NXDOMAIN.
+func resolved(resp []byte) bool {
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	return err == nil && h.RCode == dnsmessage.RCodeSuccess
+}
+
+// lookup returns the response to the query if its name is in the name table, and whether the
+// host is answered only when the upstream resolvers fail. The answers which do not fit in maxSize
+// bytes are left out, and the response is marked as truncated.
+func (s *Server) lookup(query []byte, maxSize int) ([]byte, bool, bool) {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil, false, false
+	}
+	q, err := p.Question()
+	if err != nil || q.Class != dnsmessage.ClassINET {
+		return nil, false, false
+	}
+	s.mu.RLock()
+	entry, ok := s.hosts[strings.ToLower(q.Name.String())]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false, false
+	}
+	_, edns := ednsSize(&p)
+
+	// The hosts of the table have no other records than A and AAAA, so the answer of the other
+	// queries is empty.
+	var answers []net.IP
+	for _, ip := range entry.ips {
+		if (q.Type == dnsmessage.TypeA && ip.To4() != nil) || (q.Type == dnsmessage.TypeAAAA && ip.To4() == nil) {
+			answers = append(answers, ip)
+		}
+	}
+	resp, err := buildResponse(h, q, answers, edns, false)
+	for n := len(answers); err == nil && len(resp) > maxSize && n > 0; {
+		n--
+		resp, err = buildResponse(h, q, answers[:n], edns, true)
+	}
+	if err != nil {
+		return nil, false, false
+	}
+	return resp, entry.fallback, true
+}
+
+// buildResponse returns the response to the query h and q with the answers.
+func buildResponse(h dnsmessage.Header, q dnsmessage.Question, answers []net.IP, edns, truncated bool) ([]byte, error) {
+	b := dnsmessage.NewBuilder(make([]byte, 0, maxUDPSize), dnsmessage.Header{
+		ID:                 h.ID,
+		Response:           true,
+		Authoritative:      true,
+		Truncated:          truncated,
+		RecursionDesired:   h.RecursionDesired,
+		RecursionAvailable: true,
+	})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	for _, ip := range answers {
+		header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: ttl}
+		var err error
+		if ip4 := ip.To4(); ip4 != nil {
+			r := dnsmessage.AResource{}
+			copy(r.A[:], ip4)
+			err = b.AResource(header, r)
+		} else {
+			r := dnsmessage.AAAAResource{}
+			copy(r.AAAA[:], ip.To16())
+			err = b.AAAAResource(header, r)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if edns {
+		if err := b.StartAdditionals(); err != nil {
+			return nil, err
+		}
+		var header dnsmessage.ResourceHeader
+		if err := header.SetEDNS0(ednsUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+			return nil, err
+		}
+		if err := b.OPTResource(header, dnsmessage.OPTResource{}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// udpSize returns the maximum size of the response over UDP to the query: 512 bytes, or the
+// payload size of its EDNS(0) record.
+func udpSize(query []byte) int {
+	var p dnsmessage.Parser
+	if _, err := p.Start(query); err != nil {
+		return maxUDPSize
+	}
+	size, ok := ednsSize(&p)
+	switch {
+	case !ok || size < maxUDPSize:
+		return maxUDPSize
+	case size > maxMessageSize:
+		return maxMessageSize
+	default:
+		return size
+	}
+}
+
+// ednsSize returns the UDP payload size of the EDNS(0) record of the message, and whether it has
+// one. The parser must not be past the additional section.
+func ednsSize(p *dnsmessage.Parser) (int, bool) {
+	if err := p.SkipAllQuestions(); err != nil {
+		return 0, false
+	}
+	if err := p.SkipAllAnswers(); err != nil {
+		return 0, false
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return 0, false
+	}
+	for {
+		h, err := p.AdditionalHeader()
+		if err != nil {
+			return 0, false
+		}
+		if h.Type == dnsmessage.TypeOPT {
+			// The class of the OPT record is the payload size.
+			return int(h.Class), true
+		}
+		if err := p.SkipAdditional(); err != nil {
+			return 0, false
+		}
+	}
+}
+
+// forward sends the query to the upstream resolvers in order over the network, and returns the
+// first response.
+func (s *Server) forward(network string, query []byte) ([]byte, error) {
+	err := errNoUpstream
+	for _, upstream := range s.upstreams {
+		var resp []byte
+		if resp, err = exchange(network, upstream, query, s.timeout); err == nil {
+			return resp, nil
+		}
+	}
+	return nil, err
+}
+
+func exchange(network, upstream string, query []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout(network, upstream, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() // nolint: errcheck
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if network == "tcp" {
+		if err := writeTCPMessage(conn, query); err != nil {
+			return nil, err
+		}
+		return readTCPMessage(conn)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// readTCPMessage reads a DNS message prefixed by its length, as sent over TCP.
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeTCPMessage writes a DNS message prefixed by its length, as sent over TCP.
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// serverFailure returns a SERVFAIL response to the query, or nil if the query is invalid.
+func serverFailure(query []byte) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 h.ID,
+			Response:           true,
+			RecursionDesired:   h.RecursionDesired,
+			RecursionAvailable: true,
+			RCode:              dnsmessage.RCodeServerFailure,
+		},
+		Questions: []dnsmessage.Question{q},
+	}
+	resp, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// UpstreamResolvers returns the addresses of the name servers of a resolv.conf file, except the
+// excluded ones, typically the DNS proxy itself.
+func UpstreamResolvers(resolvConf string, exclude ...string) ([]string, error) {
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var upstreams []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" || net.ParseIP(fields[1]) == nil {
+			continue
+		}
+		addr := net.JoinHostPort(fields[1], "53")
+		excluded := false
+		for _, e := range exclude {
+			if addr == e {
+				excluded = true
+			}
+		}
+		if !excluded {
+			upstreams = append(upstreams, addr)
+		}
+	}
+	return upstreams, scanner.Err()
+}
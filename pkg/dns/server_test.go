@@ -0,0 +1,255 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func startServer(ctx context.Context, t *testing.T, upstreams []string, nt *NameTable) *Server {
+	t.Helper()
+	s, err := NewServer("127.0.0.1:0", upstreams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.timeout = 500 * time.Millisecond
+	s.UpdateNameTable(nt)
+	go s.Run(ctx)
+	return s
+}
+
+// resolver returns a client sending all its queries to the server.
+func resolver(s *Server) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", s.Address())
+		},
+	}
+}
+
+func lookup(r *net.Resolver, host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP.String())
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	upstream := startServer(ctx, t, nil, &NameTable{Table: map[string]*NameInfo{
+		"upstream.example.com": {Ips: []string{"192.168.0.1"}},
+	}})
+	s := startServer(ctx, t, []string{upstream.Address()}, &NameTable{Table: map[string]*NameInfo{
+		"foo.default.svc.cluster.local":   {Ips: []string{"10.0.0.1"}},
+		"dual.default.svc.cluster.local.": {Ips: []string{"10.0.0.2", "2001:db8::2"}},
+		"External.com":                    {Ips: []string{"2001:db8::3"}},
+		// The upstream resolvers are asked first for the fallback hosts.
+		"upstream.example.com": {Ips: []string{"10.0.0.5"}, Fallback: true},
+		"fallback.example.com": {Ips: []string{"10.0.0.6"}, Fallback: true},
+	}})
+	r := resolver(s)
+
+	cases := []struct {
+		name    string
+		host    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "name table", host: "foo.default.svc.cluster.local.", want: []string{"10.0.0.1"}},
+		{name: "A and AAAA records", host: "dual.default.svc.cluster.local.", want: []string{"10.0.0.2", "2001:db8::2"}},
+		{name: "case insensitive", host: "external.COM.", want: []string{"2001:db8::3"}},
+		{name: "forwarded", host: "upstream.example.com.", want: []string{"192.168.0.1"}},
+		{name: "fallback", host: "fallback.example.com.", want: []string{"10.0.0.6"}},
+		{name: "upstream failure", host: "unknown.example.com.", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := lookup(r, c.host)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	// The name table is replaced.
+	s.UpdateNameTable(&NameTable{Table: map[string]*NameInfo{
+		"bar.default.svc.cluster.local": {Ips: []string{"10.0.0.4"}},
+	}})
+	if got, err := lookup(r, "bar.default.svc.cluster.local."); err != nil || !reflect.DeepEqual(got, []string{"10.0.0.4"}) {
+		t.Errorf("got %v %v after the update, want the new table", got, err)
+	}
+	if got, err := lookup(r, "foo.default.svc.cluster.local."); err == nil {
+		t.Errorf("got %v after the update, want the host to be removed", got)
+	}
+}
+
+// exchangeQuery sends an A query for the host to the address over the network, with an EDNS(0)
+// record if ednsSize is not 0.
+func exchangeQuery(t *testing.T, network, addr, host string, ednsSize int) dnsmessage.Message {
+	t.Helper()
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 42, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(host),
+		Type:  dnsmessage.TypeA,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if ednsSize != 0 {
+		if err := b.StartAdditionals(); err != nil {
+			t.Fatal(err)
+		}
+		var h dnsmessage.ResourceHeader
+		if err := h.SetEDNS0(ednsSize, dnsmessage.RCodeSuccess, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.OPTResource(h, dnsmessage.OPTResource{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := exchange(network, addr, query, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestServerTruncation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 40 A records do not fit in 512 bytes.
+	var ips []string
+	for i := 1; i <= 40; i++ {
+		ips = append(ips, fmt.Sprintf("10.0.0.%d", i))
+	}
+	upstream := startServer(ctx, t, nil, &NameTable{Table: map[string]*NameInfo{
+		"upstream.example.com": {Ips: ips},
+	}})
+	s := startServer(ctx, t, []string{upstream.Address()}, &NameTable{Table: map[string]*NameInfo{
+		"large.default.svc.cluster.local": {Ips: ips},
+	}})
+
+	cases := []struct {
+		name          string
+		network       string
+		host          string
+		ednsSize      int
+		wantTruncated bool
+	}{
+		{name: "udp", network: "udp", host: "large.default.svc.cluster.local.", wantTruncated: true},
+		{name: "udp with edns", network: "udp", host: "large.default.svc.cluster.local.", ednsSize: 4096},
+		{name: "tcp", network: "tcp", host: "large.default.svc.cluster.local."},
+		{name: "forwarded udp", network: "udp", host: "upstream.example.com.", wantTruncated: true},
+		{name: "forwarded tcp", network: "tcp", host: "upstream.example.com."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := exchangeQuery(t, c.network, s.Address(), c.host, c.ednsSize)
+			if msg.ID != 42 || msg.RCode != dnsmessage.RCodeSuccess {
+				t.Fatalf("unexpected response %v", msg.Header)
+			}
+			if msg.Truncated != c.wantTruncated {
+				t.Errorf("got truncated %v, want %v", msg.Truncated, c.wantTruncated)
+			}
+			if c.wantTruncated {
+				if len(msg.Answers) == 0 || len(msg.Answers) >= len(ips) {
+					t.Errorf("got %d answers in the truncated response, want less than %d", len(msg.Answers), len(ips))
+				}
+			} else if len(msg.Answers) != len(ips) {
+				t.Errorf("got %d answers, want %d", len(msg.Answers), len(ips))
+			}
+			if c.ednsSize != 0 && len(msg.Additionals) != 1 {
+				t.Errorf("got additionals %v, want the EDNS(0) record", msg.Additionals)
+			}
+		})
+	}
+}
+
+func TestUpstreamResolvers(t *testing.T) {
+	f, err := ioutil.TempFile("", "resolv.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name()) // nolint: errcheck
+	if _, err := f.WriteString(`# comment
+search default.svc.cluster.local svc.cluster.local cluster.local
+nameserver 10.96.0.10
+nameserver 127.0.0.1
+nameserver fd00::10
+nameserver invalid
+options ndots:5
+`); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := UpstreamResolvers(f.Name(), "127.0.0.1:53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.96.0.10:53", "[fd00::10]:53"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := UpstreamResolvers("/does/not/exist"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
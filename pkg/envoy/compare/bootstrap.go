@@ -0,0 +1,64 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// BootstrapDiff prints a diff between the bootstrap configs of the two dumps to the passed writer
+func (c *Comparator) BootstrapDiff() error {
+	jsonm := &jsonpb.Marshaler{Indent: "   "}
+	toBytes, fromBytes := &bytes.Buffer{}, &bytes.Buffer{}
+	toBootstrapDump, err := c.envoy.GetBootstrapConfigDump()
+	if err != nil {
+		toBytes.WriteString(err.Error())
+	} else {
+		bootstrap := toBootstrapDump.GetBootstrap()
+		if err := jsonm.Marshal(toBytes, &bootstrap); err != nil {
+			return err
+		}
+	}
+	fromBootstrapDump, err := c.pilot.GetBootstrapConfigDump()
+	if err != nil {
+		fromBytes.WriteString(err.Error())
+	} else {
+		bootstrap := fromBootstrapDump.GetBootstrap()
+		if err := jsonm.Marshal(fromBytes, &bootstrap); err != nil {
+			return err
+		}
+	}
+	diff := difflib.UnifiedDiff{
+		FromFile: c.fromLabel + " Bootstrap",
+		A:        difflib.SplitLines(fromBytes.String()),
+		ToFile:   c.toLabel + " Bootstrap",
+		B:        difflib.SplitLines(toBytes.String()),
+		Context:  c.context,
+	}
+	text, err := difflib.GetUnifiedDiffString(diff)
+	if err != nil {
+		return err
+	}
+	if text != "" {
+		fmt.Fprintln(c.w, text)
+	} else {
+		fmt.Fprintln(c.w, "Bootstrap Match")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestComparator_BootstrapDiff(t *testing.T) {
+	changed := bytes.Replace(loadEnvoyDump(), []byte(`"cluster": "details"`), []byte(`"cluster": "reviews"`), 1)
+	if bytes.Equal(changed, loadEnvoyDump()) {
+		t.Fatal("failed to change the bootstrap of the test config dump")
+	}
+	tests := []struct {
+		name      string
+		from, to  []byte
+		wantMatch bool
+	}{
+		{
+			name:      "prints match",
+			from:      loadEnvoyDump(),
+			to:        loadDiffEnvoyDump(),
+			wantMatch: true,
+		},
+		{
+			name: "prints a diff",
+			from: loadEnvoyDump(),
+			to:   changed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &bytes.Buffer{}
+			c, err := NewEnvoyComparator(got, "epoch 0", tt.from, "epoch 1", tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.BootstrapDiff(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMatch {
+				if got.String() != "Bootstrap Match\n" {
+					t.Errorf("wanted match but got a diff: %s", got.String())
+				}
+				return
+			}
+			for _, want := range []string{"--- epoch 0 Bootstrap", "+++ epoch 1 Bootstrap", `-      "cluster": "details"`, `+      "cluster": "reviews"`} {
+				if !strings.Contains(got.String(), want) {
+					t.Errorf("diff %s does not contain %q", got.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewEnvoyComparator(t *testing.T) {
+	got := &bytes.Buffer{}
+	c, err := NewEnvoyComparator(got, "pod-a", loadEnvoyDump(), "pod-b", loadDiffEnvoyDump())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClusterDiff(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.String(), "--- pod-a Clusters\n+++ pod-b Clusters\n") {
+		t.Errorf("unexpected diff labels: %s", got.String())
+	}
+
+	if _, err := NewEnvoyComparator(got, "pod-a", []byte("nope"), "pod-b", loadEnvoyDump()); err == nil || !strings.Contains(err.Error(), "pod-a") {
+		t.Errorf("got error %v, want an error for the pod-a config dump", err)
+	}
+}
@@ -39,9 +39,9 @@ func (c *Comparator) ClusterDiff() error {
 		return err
 	}
 	diff := difflib.UnifiedDiff{
-		FromFile: "Pilot Clusters",
+		FromFile: c.fromLabel + " Clusters",
 		A:        difflib.SplitLines(pilotBytes.String()),
-		ToFile:   "Envoy Clusters",
+		ToFile:   c.toLabel + " Clusters",
 		B:        difflib.SplitLines(envoyBytes.String()),
 		Context:  c.context,
 	}
@@ -22,12 +22,14 @@ import (
 	"istio.io/istio/istioctl/pkg/util/configdump"
 )
 
-// Comparator diffs between a config dump from Pilot and one from Envoy
+// Comparator diffs between a config dump from Pilot and one from Envoy, or between two config
+// dumps from Envoy. The pilot dump is the one diffed from, the envoy dump the one diffed to.
 type Comparator struct {
-	envoy, pilot *configdump.Wrapper
-	w            io.Writer
-	context      int
-	location     string
+	envoy, pilot       *configdump.Wrapper
+	fromLabel, toLabel string
+	w                  io.Writer
+	context            int
+	location           string
 }
 
 // NewComparator is a comparator constructor
@@ -51,12 +53,37 @@ func NewComparator(w io.Writer, pilotResponses map[string][]byte, envoyResponse
 		return nil, err
 	}
 	c.envoy = envoyDump
+	c.fromLabel = "Pilot"
+	c.toLabel = "Envoy"
 	c.w = w
 	c.context = 7
 	c.location = "Local" // the time.Location for formatting time.Time instances
 	return c, nil
 }
 
+// NewEnvoyComparator is a constructor of a comparator between two config dumps from Envoy, for
+// example of two proxies or of a proxy at two points in time. The labels name the dumps in the
+// diffs.
+func NewEnvoyComparator(w io.Writer, fromLabel string, from []byte, toLabel string, to []byte) (*Comparator, error) {
+	fromDump := &configdump.Wrapper{}
+	if err := json.Unmarshal(from, fromDump); err != nil {
+		return nil, fmt.Errorf("unable to parse the %s config dump: %v", fromLabel, err)
+	}
+	toDump := &configdump.Wrapper{}
+	if err := json.Unmarshal(to, toDump); err != nil {
+		return nil, fmt.Errorf("unable to parse the %s config dump: %v", toLabel, err)
+	}
+	return &Comparator{
+		pilot:     fromDump,
+		envoy:     toDump,
+		fromLabel: fromLabel,
+		toLabel:   toLabel,
+		w:         w,
+		context:   7,
+		location:  "Local",
+	}, nil
+}
+
 // Diff prints a diff between Pilot and Envoy to the passed writer
 func (c *Comparator) Diff() error {
 	if err := c.ClusterDiff(); err != nil {
@@ -39,9 +39,9 @@ func (c *Comparator) ListenerDiff() error {
 		return err
 	}
 	diff := difflib.UnifiedDiff{
-		FromFile: "Pilot Listeners",
+		FromFile: c.fromLabel + " Listeners",
 		A:        difflib.SplitLines(pilotBytes.String()),
-		ToFile:   "Envoy Listeners",
+		ToFile:   c.toLabel + " Listeners",
 		B:        difflib.SplitLines(envoyBytes.String()),
 		Context:  c.context,
 	}
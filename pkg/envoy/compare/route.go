@@ -40,9 +40,9 @@ func (c *Comparator) RouteDiff() error {
 		return err
 	}
 	diff := difflib.UnifiedDiff{
-		FromFile: "Pilot Routes",
+		FromFile: c.fromLabel + " Routes",
 		A:        difflib.SplitLines(pilotBytes.String()),
-		ToFile:   "Envoy Routes",
+		ToFile:   c.toLabel + " Routes",
 		B:        difflib.SplitLines(envoyBytes.String()),
 		Context:  c.context,
 	}
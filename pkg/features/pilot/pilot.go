@@ -127,6 +127,49 @@ var (
 	)
 	EnableFallthroughRoute = enableFallthroughRouteVar.Get
 
+	enableJwtClaimRoutingVar = env.RegisterBoolVar(
+		"PILOT_ENABLE_JWT_CLAIM_ROUTING",
+		false,
+		"EnableJwtClaimRouting provides an option to route on the claims of the request JWT in gateways. "+
+			"When enabled, gateways copy the claims validated by the authentication policy into request headers "+
+			"before routing, and VirtualService matches on \"@request.auth.claims.<claim>\" are honored.",
+	)
+	EnableJwtClaimRouting = enableJwtClaimRoutingVar.Get
+
+	// EnableLocalRateLimit provides an option to generate the Envoy local rate limit filter from the
+	// LocalRateLimit resources. The filter is not part of the bundled proxy, which NACKs listeners
+	// referring to it, so it must only be enabled when all proxies are built with it.
+	enableLocalRateLimitVar = env.RegisterBoolVar(
+		"PILOT_ENABLE_LOCAL_RATE_LIMIT",
+		false,
+		"EnableLocalRateLimit provides an option to apply the LocalRateLimit resources with the Envoy "+
+			"\"envoy.filters.http.local_ratelimit\" filter. Only enable it when the proxies are built with this filter.",
+	)
+	EnableLocalRateLimit = enableLocalRateLimitVar.Get
+
+	// CertificateRevocationListFile is the PEM-encoded CRL published by Citadel, e.g. the crl.pem of the
+	// istio-revocations configmap mounted in Pilot. When set, sidecars reject the peer certificates it revokes.
+	CertificateRevocationListFile = env.RegisterStringVar(
+		"PILOT_CERT_REVOCATION_LIST_FILE",
+		"",
+		"The file of the PEM-encoded CRL of the mesh CA, which is pushed to the sidecars to reject the revoked "+
+			"certificates of Istio mutual TLS. Only a CRL signed by the root of the mesh is supported, and an "+
+			"expired CRL is not pushed.",
+	).Get()
+
+	// TrustBundlesFile is the JSON object of the PEM-encoded root certificates by trust domain exchanged by
+	// Citadel with the peer meshes, e.g. the trust-bundles.json of the istio-trust-bundles configmap mounted
+	// in Pilot. When set, the certificates of the peers of Istio mutual TLS whose expected SANs are all in a
+	// peer trust domain are validated with the roots of this trust domain only. The CRL of the mesh CA is
+	// no longer pushed once a peer bundle is loaded, since the sidecars would reject the peer certificates.
+	TrustBundlesFile = env.RegisterStringVar(
+		"PILOT_TRUST_BUNDLES_FILE",
+		"",
+		"The file of the JSON object of the PEM-encoded root certificates by trust domain, which are pushed to "+
+			"the sidecars to validate the certificates of Istio mutual TLS by the trust domain of their SANs. "+
+			"The certificate revocation list of the mesh CA is not pushed once the bundle of a peer is loaded.",
+	).Get()
+
 	// DisableXDSMarshalingToAny provides an option to disable the "xDS marshaling to Any" feature ("on" by default).
 	disableXDSMarshalingToAnyVar = env.RegisterStringVar("PILOT_DISABLE_XDS_MARSHALING_TO_ANY", "", "")
 	DisableXDSMarshalingToAny    = func() bool {
@@ -32,8 +32,9 @@ const (
 )
 
 var (
-	trustDomain      = defaultTrustDomain
-	trustDomainMutex sync.RWMutex
+	trustDomain        = defaultTrustDomain
+	trustDomainAliases []string
+	trustDomainMutex   sync.RWMutex
 )
 
 func SetTrustDomain(value string) {
@@ -50,6 +51,98 @@ func GetTrustDomain() string {
 	return trustDomain
 }
 
+// SetTrustDomainAliases sets the aliases of the trust domain, e.g. the previous trust domain after
+// the trust domain is renamed, or the trust domains of the merged meshes. The identities in the
+// aliases are trusted as the same identities in the trust domain.
+func SetTrustDomainAliases(values []string) {
+	aliases := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			aliases = append(aliases, strings.Replace(value, "@", ".", -1))
+		}
+	}
+	trustDomainMutex.Lock()
+	trustDomainAliases = aliases
+	trustDomainMutex.Unlock()
+}
+
+func GetTrustDomainAliases() []string {
+	trustDomainMutex.RLock()
+	defer trustDomainMutex.RUnlock()
+	return trustDomainAliases
+}
+
+// ExpandWithTrustDomainAliases returns the identities, with each identity in the trust domain or one
+// of its aliases followed by the same identity in the others. The identities are either SPIFFE IDs,
+// or SPIFFE IDs without the scheme as the principals in the authorization policies. The other
+// identities are returned as is.
+func ExpandWithTrustDomainAliases(identities []string) []string {
+	trustDomainMutex.RLock()
+	domains := append([]string{trustDomain}, trustDomainAliases...)
+	trustDomainMutex.RUnlock()
+	if len(domains) == 1 {
+		return identities
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	add := func(identity string) {
+		if !seen[identity] {
+			seen[identity] = true
+			out = append(out, identity)
+		}
+	}
+	for _, identity := range identities {
+		add(identity)
+		prefix, domain, path := splitIdentity(identity)
+		if !contains(domains, domain) {
+			continue
+		}
+		for _, d := range domains {
+			add(prefix + d + path)
+		}
+	}
+	return out
+}
+
+// ReplaceTrustDomainAlias returns the identity moved to the trust domain if it is in one of the
+// aliases, or the identity as is otherwise.
+func ReplaceTrustDomainAlias(identity string) string {
+	trustDomainMutex.RLock()
+	defer trustDomainMutex.RUnlock()
+	prefix, domain, path := splitIdentity(identity)
+	if !contains(trustDomainAliases, domain) {
+		return identity
+	}
+	return prefix + trustDomain + path
+}
+
+// splitIdentity splits the identity into the optional scheme prefix, the trust domain and the path.
+func splitIdentity(identity string) (prefix, domain, path string) {
+	rest := identity
+	if strings.HasPrefix(rest, URIPrefix) {
+		prefix = URIPrefix
+		rest = strings.TrimPrefix(rest, URIPrefix)
+	}
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return prefix, "", rest
+	}
+	return prefix, rest[:i], rest[i:]
+}
+
+func contains(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func DetermineTrustDomain(commandLineTrustDomain string, isKubernetes bool) string {
 	if len(commandLineTrustDomain) != 0 {
 		return commandLineTrustDomain
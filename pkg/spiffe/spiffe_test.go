@@ -1,6 +1,7 @@
 package spiffe
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -127,3 +128,54 @@ func TestGenCustomSpiffe(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandWithTrustDomainAliases(t *testing.T) {
+	oldTrustDomain := GetTrustDomain()
+	defer SetTrustDomain(oldTrustDomain)
+	defer SetTrustDomainAliases(nil)
+
+	SetTrustDomain("new.td")
+	in := []string{"spiffe://old.td/ns/foo/sa/bar", "new.td/ns/foo/sa/*", "other.td/ns/foo/sa/bar", "*"}
+	if got := ExpandWithTrustDomainAliases(in); !reflect.DeepEqual(got, in) {
+		t.Errorf("ExpandWithTrustDomainAliases() without aliases = %v, want %v", got, in)
+	}
+
+	SetTrustDomainAliases([]string{"old.td", " old@mesh "})
+	if got, want := GetTrustDomainAliases(), []string{"old.td", "old.mesh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTrustDomainAliases() = %v, want %v", got, want)
+	}
+	want := []string{
+		"spiffe://old.td/ns/foo/sa/bar",
+		"spiffe://new.td/ns/foo/sa/bar",
+		"spiffe://old.mesh/ns/foo/sa/bar",
+		"new.td/ns/foo/sa/*",
+		"old.td/ns/foo/sa/*",
+		"old.mesh/ns/foo/sa/*",
+		"other.td/ns/foo/sa/bar",
+		"*",
+	}
+	if got := ExpandWithTrustDomainAliases(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandWithTrustDomainAliases() = %v, want %v", got, want)
+	}
+}
+
+func TestReplaceTrustDomainAlias(t *testing.T) {
+	oldTrustDomain := GetTrustDomain()
+	defer SetTrustDomain(oldTrustDomain)
+	defer SetTrustDomainAliases(nil)
+
+	SetTrustDomain("new.td")
+	SetTrustDomainAliases([]string{"old.td"})
+	cases := map[string]string{
+		"spiffe://old.td/ns/foo/sa/bar":   "spiffe://new.td/ns/foo/sa/bar",
+		"spiffe://new.td/ns/foo/sa/bar":   "spiffe://new.td/ns/foo/sa/bar",
+		"spiffe://other.td/ns/foo/sa/bar": "spiffe://other.td/ns/foo/sa/bar",
+		"old.td":                          "old.td",
+		"istio-citadel":                   "istio-citadel",
+	}
+	for in, want := range cases {
+		if got := ReplaceTrustDomainAlias(in); got != want {
+			t.Errorf("ReplaceTrustDomainAlias(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"istio.io/istio/pkg/test/util/retry"
@@ -89,6 +91,31 @@ func GetConfigDump(adminPort int) (*envoyAdmin.ConfigDump, error) {
 	return msg, nil
 }
 
+// GetStats polls Envoy admin port for the counters and gauges, by stat name.
+func GetStats(adminPort int) (map[string]int, error) {
+	buffer, err := doEnvoyGet("stats", adminPort)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStats(buffer.String()), nil
+}
+
+// ParseStats parses the counters and gauges of the text output of the /stats admin endpoint.
+// Histograms are skipped.
+func ParseStats(stats string) map[string]int {
+	out := make(map[string]int)
+	for _, line := range strings.Split(stats, "\n") {
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if value, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+			out[parts[0]] = value
+		}
+	}
+	return out
+}
+
 func doEnvoyGet(path string, adminPort int) (*bytes.Buffer, error) {
 	requestURL := fmt.Sprintf("http://127.0.0.1:%d/%s", adminPort, path)
 	buffer, err := doHTTPGet(requestURL)
@@ -97,4 +97,8 @@ type Sidecar interface {
 	// has been accepted.
 	WaitForConfig(accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option) error
 	WaitForConfigOrFail(t test.Failer, accept func(*envoyAdmin.ConfigDump) (bool, error), options ...retry.Option)
+
+	// Stats returns the counters and gauges of the Envoy instance, by stat name.
+	Stats() (map[string]int, error)
+	StatsOrFail(t test.Failer) map[string]int
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/gogo/protobuf/types"
 
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/envoy"
 	"istio.io/istio/pkg/test/framework/components/echo"
 	"istio.io/istio/pkg/test/framework/components/echo/common"
 	"istio.io/istio/pkg/test/kube"
@@ -126,13 +127,27 @@ func (s *sidecar) WaitForConfigOrFail(t test.Failer, accept func(*envoyAdmin.Con
 	}
 }
 
+func (s *sidecar) Stats() (map[string]int, error) {
+	response, err := s.adminGet("stats")
+	if err != nil {
+		return nil, err
+	}
+	return envoy.ParseStats(response), nil
+}
+
+func (s *sidecar) StatsOrFail(t test.Failer) map[string]int {
+	t.Helper()
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
 func (s *sidecar) adminRequest(path string, out proto.Message) error {
-	// Exec onto the pod and make a curl request to the admin port, writing
-	command := fmt.Sprintf("curl http://127.0.0.1:%d/%s", proxyAdminPort, path)
-	response, err := s.accessor.Exec(s.podNamespace, s.podName, proxyContainerName, command)
+	response, err := s.adminGet(path)
 	if err != nil {
-		return fmt.Errorf("failed exec on pod %s/%s: %v. Command: %s. Output:\n%s",
-			s.podNamespace, s.podName, err, command, response)
+		return err
 	}
 
 	if err := jsonpb.Unmarshal(strings.NewReader(response), out); err != nil {
@@ -140,3 +155,14 @@ func (s *sidecar) adminRequest(path string, out proto.Message) error {
 	}
 	return nil
 }
+
+func (s *sidecar) adminGet(path string) (string, error) {
+	// Exec onto the pod and make a curl request to the admin port, writing
+	command := fmt.Sprintf("curl http://127.0.0.1:%d/%s", proxyAdminPort, path)
+	response, err := s.accessor.Exec(s.podNamespace, s.podName, proxyContainerName, command)
+	if err != nil {
+		return "", fmt.Errorf("failed exec on pod %s/%s: %v. Command: %s. Output:\n%s",
+			s.podNamespace, s.podName, err, command, response)
+	}
+	return response, nil
+}
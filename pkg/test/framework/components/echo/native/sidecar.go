@@ -259,6 +259,19 @@ func (s *sidecar) WaitForConfigOrFail(t test.Failer, accept func(*envoyAdmin.Con
 	}
 }
 
+func (s *sidecar) Stats() (map[string]int, error) {
+	return envoy.GetStats(s.adminPort)
+}
+
+func (s *sidecar) StatsOrFail(t test.Failer) map[string]int {
+	t.Helper()
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
 func (s *sidecar) Stop() {
 	_ = s.envoy.Stop()
 }
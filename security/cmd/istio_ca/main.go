@@ -16,7 +16,11 @@ package main
 
 import (
 	"context"
+	"crypto"
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 	"time"
@@ -33,15 +37,24 @@ import (
 	"istio.io/istio/pkg/probe"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/version"
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/caclient"
 	"istio.io/istio/security/pkg/cmd"
+	"istio.io/istio/security/pkg/jointoken"
+	"istio.io/istio/security/pkg/k8s/configmap"
 	"istio.io/istio/security/pkg/k8s/controller"
 	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/ca/remotesigner"
+	"istio.io/istio/security/pkg/pki/ca/vault"
+	"istio.io/istio/security/pkg/pki/util"
 	probecontroller "istio.io/istio/security/pkg/probe"
 	"istio.io/istio/security/pkg/registry"
 	"istio.io/istio/security/pkg/registry/kube"
+	"istio.io/istio/security/pkg/revocation"
 	caserver "istio.io/istio/security/pkg/server/ca"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
 	"istio.io/istio/security/pkg/server/monitoring"
+	"istio.io/istio/security/pkg/trustbundle"
 )
 
 type cliOptions struct { // nolint: maligned
@@ -56,14 +69,60 @@ type cliOptions struct { // nolint: maligned
 	signingKeyFile  string
 	rootCertFile    string
 
+	// The backend signing the certificates, one of local, vault or remote-signer.
+	signingBackend string
+	// The options of the Vault backend.
+	vaultOptions vault.Options
+	// The file of the root certificates verifying the Vault server.
+	vaultTLSRootCertFile string
+	// The address of the remote signer.
+	remoteSignerAddress string
+	// The file of the root certificates verifying the remote signer.
+	remoteSignerTLSRootCertFile string
+	// The files of the client certificate and key Citadel authenticates to the remote signer with.
+	remoteSignerTLSCertFile string
+	remoteSignerTLSKeyFile  string
+
 	selfSignedCA        bool
 	selfSignedCACertTTL time.Duration
+	// The algorithm of the self-signed CA private key.
+	selfSignedCAKeyAlgorithm string
+	// The ratio of the self-signed CA root TTL left at which its rotation starts.
+	rootRotationGracePeriodRatio float64
+	// The minimum ratio of workloads updated for a root rotation phase to complete.
+	rootRotationMinAdoptionRatio float64
+	// The minimum duration of a root rotation phase, the max workload cert TTL if 0.
+	rootRotationMinPhaseDuration time.Duration
+	// The interval between two checks of the root rotation.
+	rootRotationCheckInterval time.Duration
 
 	// if set, namespaces require explicit labeling to have Citadel generate secrets.
 	explicitOptInRequired bool
 
 	workloadCertTTL    time.Duration
 	maxWorkloadCertTTL time.Duration
+	// The max TTLs of the workload certificates of some identities, as <identity prefix>=<max TTL> separated by comma.
+	maxWorkloadCertTTLPolicies string
+	// The interval between two reloads of the revocations.
+	revocationCheckInterval time.Duration
+	// The TTL of the published CRL.
+	crlTTL time.Duration
+	// The sink of the audit records of the issued certificates, one of none, file or kubernetes-event.
+	auditSink string
+	// The file the audit records are appended to.
+	auditLogFile string
+	// If set, the certificates whose audit record can't be written are not handed out.
+	auditFailClosed bool
+	// Whether to authenticate the callers presenting a one-time join token.
+	joinTokenAttestation bool
+	// The file listing the peer meshes whose trust bundles are fetched.
+	trustBundlePeersFile string
+	// The port the trust bundle of the mesh is published at, 0 to not publish it.
+	trustBundlePort int
+	// The interval between two fetches of the trust bundles of the peer meshes.
+	trustBundleRefreshInterval time.Duration
+	// Whether to append the root certificates of the peer meshes to those of the workloads.
+	trustBundleWorkloadPeerRoots bool
 	// The length of certificate rotation grace period, configured as the ratio of the certificate TTL.
 	// If workloadCertGracePeriodRatio is 0.2, and cert TTL is 24 hours, then the rotation will happen
 	// after 24*(1-0.2) hours since the cert is issued.
@@ -80,6 +139,8 @@ type cliOptions struct { // nolint: maligned
 	signCACerts bool
 	// Whether to generate PKCS#8 private keys.
 	pkcs8Keys bool
+	// The algorithm of the generated workload private keys.
+	workloadKeyAlgorithm string
 
 	cAClientConfig caclient.Config
 
@@ -92,6 +153,10 @@ type cliOptions struct { // nolint: maligned
 	// This will be used for k8s liveness probe. If empty, it does nothing.
 	LivenessProbeOptions *probe.Options
 	probeCheckInterval   time.Duration
+	// The path to the file which indicates the readiness of the server by its existence. A warning is
+	// logged when the CA certificates expire within caCertExpiryWarningThreshold.
+	ReadinessProbeOptions        *probe.Options
+	caCertExpiryWarningThreshold time.Duration
 
 	loggingOptions *log.Options
 	ctrlzOptions   *ctrlz.Options
@@ -105,16 +170,31 @@ type cliOptions struct { // nolint: maligned
 
 	// domain to use in SPIFFE identity URLs
 	trustDomain string
+	// The other trust domains whose identities are trusted as the same identities in the trust domain.
+	trustDomainAliases []string
 
 	// Enable dual-use certs - SPIFFE in SAN and in CommonName
 	dualUse bool
 }
 
+const (
+	// The backends signing the certificates.
+	localSigningBackend  = "local"
+	vaultSigningBackend  = "vault"
+	remoteSigningBackend = "remote-signer"
+
+	// The sinks of the audit records.
+	noAuditSink    = "none"
+	fileAuditSink  = "file"
+	eventAuditSink = "kubernetes-event"
+)
+
 var (
 	opts = cliOptions{
-		loggingOptions:       log.DefaultOptions(),
-		ctrlzOptions:         ctrlz.DefaultOptions(),
-		LivenessProbeOptions: &probe.Options{},
+		loggingOptions:        log.DefaultOptions(),
+		ctrlzOptions:          ctrlz.DefaultOptions(),
+		LivenessProbeOptions:  &probe.Options{},
+		ReadinessProbeOptions: &probe.Options{},
 	}
 
 	rootCmd = &cobra.Command{
@@ -178,14 +258,57 @@ func init() {
 	// Both self-signed or non-self-signed Citadel may take a root certificate file with a list of root certificates.
 	flags.StringVar(&opts.rootCertFile, "root-cert", "", "Path to the root certificate file.")
 
+	// Configuration of the backend signing the certificates of a non self-signed Citadel.
+	flags.StringVar(&opts.signingBackend, "signing-backend", localSigningBackend,
+		"The backend signing the certificates, one of "+localSigningBackend+" (with the '--signing-key' key), "+
+			vaultSigningBackend+" (the Vault PKI secrets engine issues the certificates) or "+remoteSigningBackend+
+			" (a KMS or an HSM signs with a key that never leaves it). Requires '--self-signed-ca=false' unless "+
+			localSigningBackend+".")
+	flags.StringVar(&opts.vaultOptions.Address, "vault-address", "", "The address of the Vault server.")
+	flags.StringVar(&opts.vaultTLSRootCertFile, "vault-tls-root-cert", "",
+		"Path to the root certificates verifying the Vault server. Uses the system roots if unspecified.")
+	flags.StringVar(&opts.vaultOptions.LoginPath, "vault-login-path", "auth/kubernetes/login",
+		"The path of the Vault Kubernetes auth login.")
+	flags.StringVar(&opts.vaultOptions.LoginRole, "vault-login-role", "", "The Vault role Citadel logs in as.")
+	flags.StringVar(&opts.vaultOptions.JWTPath, "vault-jwt-path", "/var/run/secrets/kubernetes.io/serviceaccount/token",
+		"Path to the service account token Citadel logs in to Vault with.")
+	flags.StringVar(&opts.vaultOptions.SignPath, "vault-sign-path", "",
+		"The path of the Vault PKI sign endpoint, e.g. pki/sign/istio. The issuing CA of the PKI secrets engine "+
+			"must be the '--signing-cert' certificate, the certificates it issues are rejected otherwise.")
+	flags.StringVar(&opts.remoteSignerAddress, "remote-signer-address", "", "The address of the remote signer.")
+	flags.StringVar(&opts.remoteSignerTLSRootCertFile, "remote-signer-tls-root-cert", "",
+		"Path to the root certificates verifying the remote signer.")
+	flags.StringVar(&opts.remoteSignerTLSCertFile, "remote-signer-tls-cert", "",
+		"Path to the client certificate Citadel authenticates to the remote signer with.")
+	flags.StringVar(&opts.remoteSignerTLSKeyFile, "remote-signer-tls-key", "",
+		"Path to the private key of the '--remote-signer-tls-cert' client certificate.")
+
 	// Configuration if Citadel acts as a self signed CA.
 	flags.BoolVar(&opts.selfSignedCA, "self-signed-ca", false,
 		"Indicates whether to use auto-generated self-signed CA certificate. "+
 			"When set to true, the '--signing-cert' and '--signing-key' options are ignored.")
 	flags.DurationVar(&opts.selfSignedCACertTTL, "self-signed-ca-cert-ttl", cmd.DefaultSelfSignedCACertTTL,
 		"The TTL of self-signed CA root certificate.")
+	flags.StringVar(&opts.selfSignedCAKeyAlgorithm, "self-signed-ca-key-algorithm", string(util.RSAKey),
+		"The algorithm of the self-signed CA private key, one of RSA, ECDSA-P256 or ECDSA-P384.")
+	flags.Float64Var(&opts.rootRotationGracePeriodRatio, "root-rotation-grace-period-ratio", 0,
+		"The ratio of the self-signed CA root TTL left at which the root is rotated. When 0, the root is only "+
+			"rotated on request, by annotating the "+ca.CASecret+" secret with "+ca.RootRotationPhaseAnnotationKey+"="+
+			string(ca.RootRotationRequested)+".")
+	flags.Float64Var(&opts.rootRotationMinAdoptionRatio, "root-rotation-min-adoption-ratio", 1,
+		"The minimum ratio of workload secrets updated with the root certificates and the signing certificate "+
+			"for a root rotation phase to complete. The workloads getting their certificates through SDS are not "+
+			"tracked, see '--root-rotation-min-phase-duration'.")
+	flags.DurationVar(&opts.rootRotationMinPhaseDuration, "root-rotation-min-phase-duration", 0,
+		"The minimum duration of a root rotation phase, whatever the ratio of updated workload secrets, so that "+
+			"the workloads using SDS renew their certificates. When 0, '--max-workload-cert-ttl' is used.")
+	flags.DurationVar(&opts.rootRotationCheckInterval, "root-rotation-check-interval", time.Minute,
+		"The interval between two checks of the root rotation.")
 	flags.StringVar(&opts.trustDomain, "trust-domain", "",
 		"The domain serves to identify the system with SPIFFE.")
+	flags.StringSliceVar(&opts.trustDomainAliases, "trust-domain-aliases", nil,
+		"The other trust domains, e.g. the previous trust domain, whose identities are trusted as the same "+
+			"identities in the trust domain. The certificates are renewed in the trust domain.")
 	// Upstream CA configuration if Citadel interacts with upstream CA.
 	flags.StringVar(&opts.cAClientConfig.CAAddress, "upstream-ca-address", "", "The IP:port address of the upstream "+
 		"CA. When set, the CA will rely on the upstream Citadel to provision its own certificate.")
@@ -199,6 +322,39 @@ func init() {
 		"The TTL of issued workload certificates.")
 	flags.DurationVar(&opts.maxWorkloadCertTTL, "max-workload-cert-ttl", cmd.DefaultMaxWorkloadCertTTL,
 		"The max TTL of issued workload certificates.")
+	flags.StringVar(&opts.maxWorkloadCertTTLPolicies, "max-workload-cert-ttl-policies", "",
+		"The max TTLs of the workload certificates of the identities starting with a prefix, lower than "+
+			"'--max-workload-cert-ttl', as <identity prefix>=<max TTL> separated by comma, e.g. "+
+			"'spiffe://cluster.local/ns/payments/=1h'.")
+	flags.DurationVar(&opts.revocationCheckInterval, "revocation-check-interval", time.Minute,
+		"The interval between two reloads of the revocations in the "+revocation.ConfigMapName+" configmap.")
+	flags.DurationVar(&opts.crlTTL, "crl-ttl", 24*time.Hour,
+		"The TTL of the CRL published in the "+revocation.ConfigMapName+" configmap. The CRL only lists the revoked "+
+			"serial numbers: the certificates already issued to a revoked identity stay valid for the sidecars "+
+			"until they expire.")
+	flags.StringVar(&opts.auditSink, "audit-sink", noAuditSink, "The sink of the audit records of the issued "+
+		"certificates, one of "+noAuditSink+", "+fileAuditSink+" (appended to '--audit-log-file') or "+eventAuditSink+
+		" (events on the service accounts the certificates are issued to).")
+	flags.StringVar(&opts.auditLogFile, "audit-log-file", "/var/log/citadel/audit.log",
+		"The file the audit records are appended to, one JSON record per line.")
+	flags.BoolVar(&opts.auditFailClosed, "audit-fail-closed", false, "If set, the certificates whose audit "+
+		"record can't be written are not handed out. Otherwise they are, and the failures are counted by the "+
+		"citadel_audit_error_count metric.")
+	flags.BoolVar(&opts.joinTokenAttestation, "join-token-attestation", false,
+		"Whether to issue the initial certificates of the node agents, e.g. on VMs, presenting a one-time "+
+			"join token of the "+jointoken.SecretName+" secret, created by 'istioctl experimental join-token'.")
+	flags.StringVar(&opts.trustBundlePeersFile, "trust-bundle-peers", "",
+		"The YAML file listing the trust domain, bundle endpoint and bootstrap root certificate file of the peer "+
+			"meshes whose trust bundles are fetched into the "+trustbundle.ConfigMapName+" configmap.")
+	flags.IntVar(&opts.trustBundlePort, "trust-bundle-port", 0, "The port the trust bundle of the mesh is "+
+		"published at over HTTPS, at "+trustbundle.BundlePath+". If 0, the bundle is not published.")
+	flags.DurationVar(&opts.trustBundleRefreshInterval, "trust-bundle-refresh-interval", 5*time.Minute,
+		"The interval between two fetches of the trust bundles of the peer meshes.")
+	flags.BoolVar(&opts.trustBundleWorkloadPeerRoots, "trust-bundle-workload-peer-roots", false,
+		"Whether to append the root certificates of the peer meshes to the root certificates of the workloads, "+
+			"so that they accept the inbound connections of the peers. The peer CAs are then trusted for all the "+
+			"identities, including those of this mesh: only enable it if the peer meshes are fully trusted. Pilot "+
+			"does not push the certificate revocation list of the mesh CA while the peer bundles are loaded.")
 	flags.Float32Var(&opts.workloadCertGracePeriodRatio, "workload-cert-grace-period-ratio",
 		cmd.DefaultWorkloadCertGracePeriodRatio, "The workload certificate rotation grace period, as a ratio of the "+
 			"workload certificate TTL.")
@@ -215,6 +371,8 @@ func init() {
 
 	flags.BoolVar(&opts.signCACerts, "sign-ca-certs", false, "Whether Citadel signs certificates for other CAs.")
 	flags.BoolVar(&opts.pkcs8Keys, "pkcs8-keys", false, "Whether to generate PKCS#8 private keys.")
+	flags.StringVar(&opts.workloadKeyAlgorithm, "workload-key-algorithm", string(util.RSAKey),
+		"The algorithm of the generated workload private keys, one of RSA, ECDSA-P256 or ECDSA-P384.")
 
 	// Monitoring configuration
 	flags.IntVar(&opts.monitoringPort, "monitoring-port", 15014, "The port number for monitoring Citadel. "+
@@ -228,6 +386,13 @@ func init() {
 		"Interval of updating file for the liveness probe.")
 	flags.DurationVar(&opts.probeCheckInterval, "probe-check-interval", cmd.DefaultProbeCheckInterval,
 		"Interval of checking the liveness of the CA.")
+	flags.StringVar(&opts.ReadinessProbeOptions.Path, "readiness-probe-path", "",
+		"Path to the file for the readiness probe.")
+	flags.DurationVar(&opts.ReadinessProbeOptions.UpdateInterval, "readiness-probe-interval", 0,
+		"Interval of updating file for the readiness probe.")
+	flags.DurationVar(&opts.caCertExpiryWarningThreshold, "ca-cert-expiry-warning-threshold", 30*24*time.Hour,
+		"A warning is logged when the CA signing or root certificate expires within this duration. The "+
+			"expiry is also reported by the citadel_health_check_ca_cert_expiry_seconds metric.")
 
 	flags.BoolVar(&opts.appendDNSNames, "append-dns-names", true,
 		"Append DNS names to the certificates for webhook services.")
@@ -277,6 +442,8 @@ func runCA() {
 
 	_, _ = ctrlz.Run(opts.ctrlzOptions, nil)
 
+	spiffe.SetTrustDomainAliases(opts.trustDomainAliases)
+
 	if value, exists := listenedNamespaceKeyVar.Lookup(); exists {
 		// When -namespace is not set, try to read the namespace from environment variable.
 		if opts.listenedNamespaces == "" {
@@ -300,16 +467,27 @@ func runCA() {
 	if err != nil {
 		fatalf("Could not create k8s clientset: %v", err)
 	}
-	ca := createCA(cs.CoreV1())
-
 	stopCh := make(chan struct{})
+	ca, revocations := createCA(cs.CoreV1(), stopCh)
+
+	go revocations.Run(opts.revocationCheckInterval, stopCh)
+	auditor := createAuditor(cs.CoreV1())
+	trustBundles := runTrustBundles(ca, cs.CoreV1(), stopCh)
+	// The peer roots are kept out of the roots of the workloads unless explicitly enabled, since the
+	// workloads would otherwise accept the certificates issued by a peer CA for the identities of the mesh.
+	var workloadTrustBundles *trustbundle.Controller
+	if opts.trustBundleWorkloadPeerRoots {
+		workloadTrustBundles = trustBundles
+	}
+	var sc *controller.SecretController
 	if !opts.serverOnly {
 		log.Infof("Creating Kubernetes controller to write issued keys and certs into secret ...")
 		// For workloads in K8s, we apply the configured workload cert TTL.
-		sc, err := controller.NewSecretController(ca, opts.explicitOptInRequired,
+		sc, err = controller.NewSecretController(ca, opts.explicitOptInRequired,
 			opts.workloadCertTTL,
 			opts.workloadCertGracePeriodRatio, opts.workloadCertMinGracePeriod, opts.dualUse,
-			cs.CoreV1(), opts.signCACerts, opts.pkcs8Keys, listenedNamespaces, webhooks)
+			cs.CoreV1(), opts.signCACerts, opts.pkcs8Keys, util.KeyAlgorithm(opts.workloadKeyAlgorithm),
+			listenedNamespaces, webhooks, auditor, workloadTrustBundles)
 		if err != nil {
 			fatalf("Failed to create secret controller: %v", err)
 		}
@@ -321,6 +499,10 @@ func runCA() {
 		}
 	}
 
+	if opts.selfSignedCA {
+		runRootRotator(ca, cs.CoreV1(), sc, stopCh)
+	}
+
 	if opts.grpcPort > 0 {
 		// start registry if gRPC server is to be started
 		reg := registry.GetIdentityRegistry()
@@ -344,7 +526,12 @@ func runCA() {
 
 		// The CA API uses cert with the max workload cert TTL.
 		hostnames := append(strings.Split(opts.grpcHosts, ","), fqdn())
-		caServer, startErr := caserver.New(ca, opts.maxWorkloadCertTTL, opts.signCACerts, hostnames, opts.grpcPort, spiffe.GetTrustDomain())
+		var joinTokens authenticate.JoinTokenConsumer
+		if opts.joinTokenAttestation {
+			joinTokens = jointoken.NewController(opts.istioCaStorageNamespace, cs.CoreV1())
+		}
+		caServer, startErr := caserver.New(ca, opts.maxWorkloadCertTTL, opts.signCACerts, hostnames, opts.grpcPort,
+			spiffe.GetTrustDomain(), revocations, auditor, joinTokens, workloadTrustBundles)
 		if startErr != nil {
 			fatalf("Failed to create istio ca server: %v", startErr)
 		}
@@ -404,7 +591,35 @@ func runCA() {
 	}
 }
 
-func createCA(client corev1.CoreV1Interface) *ca.IstioCA {
+// runRootRotator starts the rotation of the self-signed CA root, gated on the workload secrets of sc if any.
+func runRootRotator(istioCA *ca.IstioCA, client corev1.CoreV1Interface, sc *controller.SecretController,
+	stopCh chan struct{}) {
+	var tracker ca.WorkloadTracker
+	if sc != nil {
+		tracker = sc
+	}
+	minPhaseDuration := opts.rootRotationMinPhaseDuration
+	if minPhaseDuration == 0 {
+		minPhaseDuration = opts.maxWorkloadCertTTL
+	}
+	rotator := ca.NewRootRotator(istioCA, client, tracker, ca.RootRotatorOptions{
+		Namespace:        opts.istioCaStorageNamespace,
+		CheckInterval:    opts.rootRotationCheckInterval,
+		GracePeriodRatio: opts.rootRotationGracePeriodRatio,
+		MinAdoptionRatio: opts.rootRotationMinAdoptionRatio,
+		MinPhaseDuration: minPhaseDuration,
+		ReadOnly:         opts.readSigningCertOnly,
+		CACertTTL:        opts.selfSignedCACertTTL,
+		Org:              spiffe.GetTrustDomain(),
+		DualUse:          opts.dualUse,
+		KeyAlgorithm:     util.KeyAlgorithm(opts.selfSignedCAKeyAlgorithm),
+		RootCertFile:     opts.rootCertFile,
+	})
+	go rotator.Run(stopCh)
+	log.Info("Root rotator has started.")
+}
+
+func createCA(client corev1.CoreV1Interface, stopCh <-chan struct{}) (*ca.IstioCA, *revocation.Cache) {
 	var caOpts *ca.IstioCAOptions
 	var err error
 
@@ -421,11 +636,19 @@ func createCA(client corev1.CoreV1Interface) *ca.IstioCA {
 			checkInterval = -1
 		}
 		caOpts, err = ca.NewSelfSignedIstioCAOptions(ctx, opts.selfSignedCACertTTL, opts.workloadCertTTL,
-			opts.maxWorkloadCertTTL, spiffe.GetTrustDomain(), opts.dualUse,
+			opts.maxWorkloadCertTTL, spiffe.GetTrustDomain(), opts.dualUse, util.KeyAlgorithm(opts.selfSignedCAKeyAlgorithm),
 			opts.istioCaStorageNamespace, checkInterval, client, opts.rootCertFile)
 		if err != nil {
 			fatalf("Failed to create a self-signed Citadel (error: %v)", err)
 		}
+	} else if opts.signingBackend != localSigningBackend {
+		log.Infof("Use certificate from argument as the CA certificate, signing with the %s backend", opts.signingBackend)
+		signer, backend := createSigningBackend()
+		caOpts, err = ca.NewExternalKeyIstioCAOptions(opts.certChainFile, opts.signingCertFile, opts.rootCertFile,
+			signer, backend, opts.workloadCertTTL, opts.maxWorkloadCertTTL, opts.istioCaStorageNamespace, client)
+		if err != nil {
+			fatalf("Failed to create an Citadel (error: %v)", err)
+		}
 	} else {
 		log.Info("Use certificate from argument as the CA certificate")
 		caOpts, err = ca.NewPluggedCertIstioCAOptions(opts.certChainFile, opts.signingCertFile, opts.signingKeyFile,
@@ -437,6 +660,10 @@ func createCA(client corev1.CoreV1Interface) *ca.IstioCA {
 
 	caOpts.LivenessProbeOptions = opts.LivenessProbeOptions
 	caOpts.ProbeCheckInterval = opts.probeCheckInterval
+	caOpts.MaxCertTTLPolicies, _ = ca.ParseMaxCertTTLPolicies(opts.maxWorkloadCertTTLPolicies)
+	revocations := revocation.NewCache(revocation.NewController(opts.istioCaStorageNamespace, client),
+		caOpts.KeyCertBundle, opts.crlTTL)
+	caOpts.Revocations = revocations
 
 	istioCA, err := ca.NewIstioCA(caOpts)
 	if err != nil {
@@ -446,19 +673,162 @@ func createCA(client corev1.CoreV1Interface) *ca.IstioCA {
 	if opts.LivenessProbeOptions.IsValid() {
 		livenessProbeChecker, err := probecontroller.NewLivenessCheckController(
 			opts.probeCheckInterval, fmt.Sprintf("%v:%v", fqdn(), opts.grpcPort), istioCA,
-			opts.LivenessProbeOptions, probecontroller.GrpcProtocolProvider)
+			opts.LivenessProbeOptions, probecontroller.GrpcProtocolProvider, publishedRootCerts(client, stopCh))
 		if err != nil {
 			log.Errorf("failed to create an liveness probe check controller (error: %v)", err)
 		} else {
 			livenessProbeChecker.Run()
 		}
 	}
+	// The expiry of the CA certificates is reported even if the readiness probe is not enabled.
+	probecontroller.NewReadinessCheckController(opts.probeCheckInterval, opts.caCertExpiryWarningThreshold,
+		istioCA, opts.ReadinessProbeOptions).Run()
+
+	return istioCA, revocations
+}
+
+// publishedRootCerts returns the root certificates published in the istio-security configmap, which the
+// liveness probe check verifies the issued certificates against. The configmap is cached by an informer
+// until stopCh is closed, so that the probe does not query the API server.
+func publishedRootCerts(client corev1.CoreV1Interface, stopCh <-chan struct{}) probecontroller.RootCertProvider {
+	cmc := configmap.NewCache(opts.istioCaStorageNamespace, client)
+	cmc.Run(stopCh)
+	return func() ([]byte, error) {
+		rootCert, err := cmc.GetCATLSRootCert()
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(rootCert)
+	}
+}
+
+// createAuditor returns the auditor of the issued certificates selected by '--audit-sink', nil if none.
+func createAuditor(client corev1.CoreV1Interface) *audit.Auditor {
+	switch opts.auditSink {
+	case fileAuditSink:
+		sink, err := audit.NewFileSink(opts.auditLogFile)
+		if err != nil {
+			fatalf("Failed to create the audit sink (error: %v)", err)
+		}
+		log.Infof("Recording the issued certificates in %s", opts.auditLogFile)
+		return audit.NewAuditor(sink, opts.auditFailClosed)
+	case eventAuditSink:
+		log.Info("Recording the issued certificates as Kubernetes events")
+		return audit.NewAuditor(audit.NewEventSink(client, opts.istioCaStorageNamespace), opts.auditFailClosed)
+	}
+	return nil
+}
+
+// runTrustBundles starts exchanging the trust bundles with the peer meshes configured by
+// '--trust-bundle-peers' and '--trust-bundle-port', returns nil if neither is set.
+func runTrustBundles(istioCA *ca.IstioCA, client corev1.CoreV1Interface, stopCh <-chan struct{}) *trustbundle.Controller {
+	if opts.trustBundlePeersFile == "" && opts.trustBundlePort <= 0 {
+		return nil
+	}
+	var peers []trustbundle.Peer
+	if opts.trustBundlePeersFile != "" {
+		var err error
+		if peers, err = trustbundle.LoadPeers(opts.trustBundlePeersFile); err != nil {
+			fatalf("Failed to load the trust bundle peers (error: %v)", err)
+		}
+	}
+	tb := trustbundle.NewController(spiffe.GetTrustDomain(), istioCA.GetCAKeyCertBundle().GetRootCertPem, peers,
+		opts.istioCaStorageNamespace, client)
+	go tb.Run(opts.trustBundleRefreshInterval, stopCh)
 
-	return istioCA
+	if opts.trustBundlePort > 0 {
+		hostnames := append(strings.Split(opts.grpcHosts, ","), fqdn())
+		server := trustbundle.NewServer(opts.trustBundlePort, hostnames, istioCA, opts.maxWorkloadCertTTL, tb)
+		go func() {
+			if err := server.Run(stopCh); err != nil {
+				log.Errorf("The trust bundle server stopped (error: %v)", err)
+			}
+		}()
+		log.Infof("Publishing the trust bundle of the mesh on port %d", opts.trustBundlePort)
+	}
+	return tb
+}
+
+// createSigningBackend returns the remote signer or the signing backend selected by '--signing-backend'.
+func createSigningBackend() (crypto.Signer, ca.SigningBackend) {
+	switch opts.signingBackend {
+	case vaultSigningBackend:
+		vaultOpts := opts.vaultOptions
+		if opts.vaultTLSRootCertFile != "" {
+			var err error
+			if vaultOpts.TLSRootCert, err = ioutil.ReadFile(opts.vaultTLSRootCertFile); err != nil {
+				fatalf("Failed to read the Vault TLS root certificates (error: %v)", err)
+			}
+		}
+		backend, err := vault.NewBackend(vaultOpts)
+		if err != nil {
+			fatalf("Failed to create the Vault signing backend (error: %v)", err)
+		}
+		return nil, backend
+	case remoteSigningBackend:
+		rootCert, err := ioutil.ReadFile(opts.remoteSignerTLSRootCertFile)
+		if err != nil {
+			fatalf("Failed to read the remote signer TLS root certificates (error: %v)", err)
+		}
+		clientCert, err := tls.LoadX509KeyPair(opts.remoteSignerTLSCertFile, opts.remoteSignerTLSKeyFile)
+		if err != nil {
+			fatalf("Failed to load the remote signer TLS client certificate (error: %v)", err)
+		}
+		signer, err := remotesigner.NewSigner(opts.remoteSignerAddress, rootCert, clientCert)
+		if err != nil {
+			fatalf("Failed to create the remote signer (error: %v)", err)
+		}
+		return signer, nil
+	}
+	fatalf("Unsupported signing backend %q", opts.signingBackend)
+	return nil, nil
 }
 
 func verifyCommandLineOptions() {
+	if _, err := ca.ParseMaxCertTTLPolicies(opts.maxWorkloadCertTTLPolicies); err != nil {
+		fatalf("Invalid '--max-workload-cert-ttl-policies' option: %v", err)
+	}
+	if _, err := util.ParseKeyAlgorithm(opts.workloadKeyAlgorithm); err != nil {
+		fatalf("Invalid '--workload-key-algorithm' option: %v", err)
+	}
+
+	switch opts.auditSink {
+	case noAuditSink, fileAuditSink, eventAuditSink:
+	default:
+		fatalf("Invalid '--audit-sink' option %q, must be one of %s, %s or %s", opts.auditSink,
+			noAuditSink, fileAuditSink, eventAuditSink)
+	}
+
+	switch opts.signingBackend {
+	case localSigningBackend:
+	case vaultSigningBackend:
+		if opts.vaultOptions.Address == "" || opts.vaultOptions.LoginRole == "" || opts.vaultOptions.SignPath == "" {
+			fatalf("The '--vault-address', '--vault-login-role' and '--vault-sign-path' options are required " +
+				"with '--signing-backend=" + vaultSigningBackend + "'")
+		}
+	case remoteSigningBackend:
+		if opts.remoteSignerAddress == "" || opts.remoteSignerTLSRootCertFile == "" ||
+			opts.remoteSignerTLSCertFile == "" || opts.remoteSignerTLSKeyFile == "" {
+			fatalf("The '--remote-signer-address', '--remote-signer-tls-root-cert', '--remote-signer-tls-cert' and " +
+				"'--remote-signer-tls-key' options are required with '--signing-backend=" + remoteSigningBackend + "'")
+		}
+	default:
+		fatalf("Invalid '--signing-backend' option %q, must be one of %s, %s or %s", opts.signingBackend,
+			localSigningBackend, vaultSigningBackend, remoteSigningBackend)
+	}
+	if opts.signingBackend != localSigningBackend {
+		if opts.selfSignedCA {
+			fatalf("'--signing-backend=%s' is incompatible with '--self-signed-ca'", opts.signingBackend)
+		}
+		if len(opts.cAClientConfig.CAAddress) != 0 {
+			fatalf("'--signing-backend=%s' is incompatible with an upstream CA", opts.signingBackend)
+		}
+	}
+
 	if opts.selfSignedCA {
+		if _, err := util.ParseKeyAlgorithm(opts.selfSignedCAKeyAlgorithm); err != nil {
+			fatalf("Invalid '--self-signed-ca-key-algorithm' option: %v", err)
+		}
 		return
 	}
 
@@ -468,7 +838,7 @@ func verifyCommandLineOptions() {
 				"or use '-self-signed-ca'")
 	}
 
-	if opts.signingKeyFile == "" {
+	if opts.signingKeyFile == "" && opts.signingBackend == localSigningBackend {
 		fatalf(
 			"No signing key has been specified. Either specify a key file via '-signing-key' option " +
 				"or use '-self-signed-ca'")
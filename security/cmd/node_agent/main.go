@@ -60,7 +60,7 @@ func init() {
 		"ca-address", "istio-citadel:8060", "Istio CA address")
 
 	flags.StringVar(&cAClientConfig.Env, "env", "unspecified",
-		"Node Environment : unspecified | onprem | gcp | aws")
+		"Node Environment : unspecified | onprem | jointoken | gcp | aws")
 	flags.StringVar(&cAClientConfig.Platform, "platform", "vm", "The platform istio runs on: vm | k8s")
 
 	flags.StringVar(&cAClientConfig.CertChainFile, "cert-chain",
@@ -69,6 +69,8 @@ func init() {
 		"key", "/etc/certs/key.pem", "Node Agent private key file")
 	flags.StringVar(&cAClientConfig.RootCertFile, "root-cert",
 		"/etc/certs/root-cert.pem", "Root Certificate file")
+	flags.StringVar(&cAClientConfig.JoinTokenFile, "join-token",
+		"/etc/certs/join-token", "One-time join token file, presented for the initial certificate in the jointoken env")
 
 	flags.BoolVar(&naConfig.DualUse, "experimental-dual-use",
 		false, "Enable dual-use mode. Generates certificates with a CommonName identical to the SAN.")
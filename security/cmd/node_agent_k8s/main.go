@@ -25,12 +25,15 @@ import (
 
 	"istio.io/istio/pkg/cmd"
 	"istio.io/istio/pkg/collateral"
+	"istio.io/istio/pkg/ctrlz"
+	"istio.io/istio/pkg/ctrlz/fw"
 	"istio.io/istio/pkg/env"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/version"
 	"istio.io/istio/security/pkg/nodeagent/cache"
 	"istio.io/istio/security/pkg/nodeagent/sds"
 	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+	"istio.io/istio/security/pkg/pki/util"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
@@ -108,6 +111,21 @@ const (
 	// example value format like "20m"
 	SecretRotationInterval     = "SECRET_JOB_RUN_INTERVAL"
 	secretRotationIntervalFlag = "secretRotationInterval"
+
+	// The environmental variable name for the algorithm of the private keys generated for CSRs.
+	// example value format like "ECDSA-P256"
+	keyAlgorithm     = "KEY_ALGORITHM"
+	keyAlgorithmFlag = "keyAlgorithm"
+
+	// The environmental variable name for the number of consecutive CSR failures caused by the CA
+	// after which the CSRs are suspended, 0 to never suspend them.
+	csrFailureThreshold     = "CSR_FAILURE_THRESHOLD"
+	csrFailureThresholdFlag = "csrFailureThreshold"
+
+	// The environmental variable name for the time the CSRs are suspended for after repeated failures.
+	// example value format like "30s"
+	csrCircuitOpenDuration     = "CSR_CIRCUIT_OPEN_DURATION"
+	csrCircuitOpenDurationFlag = "csrCircuitOpenDuration"
 )
 
 var (
@@ -115,7 +133,9 @@ var (
 	gatewaySdsCacheOptions  cache.Options
 	serverOptions           sds.Options
 	gatewaySecretChan       chan struct{}
+	keyAlgorithmName        string
 	loggingOptions          = log.DefaultOptions()
+	ctrlzOptions            = ctrlz.DefaultOptions()
 
 	// rootCmd defines the command for node agent.
 	rootCmd = &cobra.Command{
@@ -128,6 +148,13 @@ var (
 
 			applyEnvVars(c)
 
+			algorithm, err := util.ParseKeyAlgorithm(keyAlgorithmName)
+			if err != nil {
+				log.Errorf("Invalid key algorithm: %v", err)
+				os.Exit(1)
+			}
+			workloadSdsCacheOptions.KeyAlgorithm = algorithm
+
 			gatewaySdsCacheOptions = workloadSdsCacheOptions
 
 			if serverOptions.EnableIngressGatewaySDS && serverOptions.EnableWorkloadSDS &&
@@ -161,6 +188,14 @@ var (
 				return fmt.Errorf("failed to create sds service")
 			}
 
+			cacheTopic := cache.NewTopic(map[string]*cache.SecretCache{
+				"workload":        workloadSecretCache,
+				"ingress gateway": gatewaySecretCache,
+			})
+			if _, err := ctrlz.Run(ctrlzOptions, []fw.Topic{cacheTopic}); err != nil {
+				log.Warnf("failed to start ControlZ: %v", err)
+			}
+
 			cmd.WaitSignal(stop)
 
 			return nil
@@ -219,6 +254,9 @@ var (
 	secretTTLEnv                  = env.RegisterDurationVar(secretTTL, 24*time.Hour, "").Get()
 	secretRefreshGraceDurationEnv = env.RegisterDurationVar(SecretRefreshGraceDuration, 1*time.Hour, "").Get()
 	secretRotationIntervalEnv     = env.RegisterDurationVar(SecretRotationInterval, 10*time.Minute, "").Get()
+	keyAlgorithmEnv               = env.RegisterStringVar(keyAlgorithm, string(util.RSAKey), "").Get()
+	csrFailureThresholdEnv        = env.RegisterIntVar(csrFailureThreshold, 5, "").Get()
+	csrCircuitOpenDurationEnv     = env.RegisterDurationVar(csrCircuitOpenDuration, 30*time.Second, "").Get()
 )
 
 func applyEnvVars(cmd *cobra.Command) {
@@ -285,6 +323,18 @@ func applyEnvVars(cmd *cobra.Command) {
 	if !cmd.Flag(skipValidateCertFlag).Changed {
 		workloadSdsCacheOptions.SkipValidateCert = skipValidateCertFlagEnv
 	}
+
+	if !cmd.Flag(keyAlgorithmFlag).Changed {
+		keyAlgorithmName = keyAlgorithmEnv
+	}
+
+	if !cmd.Flag(csrFailureThresholdFlag).Changed {
+		workloadSdsCacheOptions.CSRFailureThreshold = csrFailureThresholdEnv
+	}
+
+	if !cmd.Flag(csrCircuitOpenDurationFlag).Changed {
+		workloadSdsCacheOptions.CSRCircuitOpenDuration = csrCircuitOpenDurationEnv
+	}
 }
 
 var defaultInitialBackoff = 10
@@ -342,6 +392,15 @@ func main() {
 		false,
 		"If true, node agent skip validating format of certificate returned from CA.")
 
+	rootCmd.PersistentFlags().StringVar(&keyAlgorithmName, keyAlgorithmFlag, string(util.RSAKey),
+		"The algorithm of the private keys generated for CSRs, one of RSA, ECDSA-P256 or ECDSA-P384.")
+
+	rootCmd.PersistentFlags().IntVar(&workloadSdsCacheOptions.CSRFailureThreshold, csrFailureThresholdFlag, 5,
+		"The number of consecutive CSR failures caused by the CA after which the CSRs are suspended, "+
+			"0 to never suspend them. The proxies are served their cached secrets while still valid.")
+	rootCmd.PersistentFlags().DurationVar(&workloadSdsCacheOptions.CSRCircuitOpenDuration, csrCircuitOpenDurationFlag,
+		30*time.Second, "The time the CSRs are suspended for after repeated failures")
+
 	rootCmd.PersistentFlags().StringVar(&serverOptions.VaultAddress, vaultAddressFlag, "",
 		"Vault address")
 	rootCmd.PersistentFlags().StringVar(&serverOptions.VaultRole, vaultRoleFlag, "",
@@ -355,6 +414,7 @@ func main() {
 
 	// Attach the Istio logging options to the command.
 	loggingOptions.AttachCobraFlags(rootCmd)
+	ctrlzOptions.AttachCobraFlags(rootCmd)
 
 	rootCmd.AddCommand(version.CobraCommand())
 	rootCmd.AddCommand(collateral.CobraCommand(rootCmd, &doc.GenManHeader{
@@ -363,8 +423,6 @@ func main() {
 		Manual:  "Istio Node K8s Agent",
 	}))
 
-	// TODO: need integration with ctrlz?
-
 	if err := rootCmd.Execute(); err != nil {
 		log.Errora(err)
 		os.Exit(1)
@@ -0,0 +1,211 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func issueCert(t *testing.T, host string) []byte {
+	cert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         host,
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestNewRecord(t *testing.T) {
+	record, err := NewRecord(issueCert(t, "spiffe://cluster.local/ns/foo/sa/bar"), "requester", "10.1.2.3")
+	if err != nil {
+		t.Fatalf("Failed to create the record: %v", err)
+	}
+	if !reflect.DeepEqual(record.SubjectIDs, []string{"spiffe://cluster.local/ns/foo/sa/bar"}) ||
+		record.Requester != "requester" || record.SourceIP != "10.1.2.3" || record.TTLSeconds != 3600 ||
+		record.Serial == "" || record.IssuedAt.IsZero() {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if !record.IssuedTo("foo", "bar") {
+		t.Errorf("Expected the record to be issued to foo/bar")
+	}
+	if record.IssuedTo("foo", "baz") || record.IssuedTo("bar", "bar") {
+		t.Errorf("Expected the record to be issued to foo/bar only")
+	}
+
+	if _, err := NewRecord([]byte("invalid"), "requester", ""); err == nil {
+		t.Errorf("Expected an error for an invalid certificate")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	path := filepath.Join(dir, "audit.log")
+
+	var want []*Record
+	for _, host := range []string{"spiffe://cluster.local/ns/foo/sa/bar", "spiffe://cluster.local/ns/foo/sa/baz"} {
+		// Reopen the file to check the records are appended.
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("Failed to create the sink: %v", err)
+		}
+		record, err := NewRecord(issueCert(t, host), SecretControllerRequester, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = NewAuditor(sink, false).Audit(issueCert(t, host), SecretControllerRequester, "")
+		if err := sink.Write(record); err != nil {
+			t.Fatalf("Failed to write the record: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, record)
+	}
+
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read the audit log: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Got %d records, want 4", len(records))
+	}
+	for i, record := range []*Record{records[1], records[3]} {
+		if record.Serial != want[i].Serial || !record.IssuedAt.Equal(want[i].IssuedAt) {
+			t.Errorf("Got record %+v, want %+v", record, want[i])
+		}
+	}
+}
+
+func TestEventSink(t *testing.T) {
+	client := fake.NewSimpleClientset().CoreV1()
+	auditor := NewAuditor(NewEventSink(client, "istio-system"), false)
+	_ = auditor.Audit(issueCert(t, "spiffe://cluster.local/ns/foo/sa/bar"), "requester", "10.1.2.3")
+	_ = auditor.Audit(issueCert(t, "spiffe://cluster.local/ns/foo/sa/baz"), "requester", "10.1.2.3")
+	_ = auditor.Audit(issueCert(t, "istio-citadel.istio-system.svc"), "requester", "10.1.2.3")
+
+	records, err := ListEvents(client, "foo", "bar")
+	if err != nil {
+		t.Fatalf("Failed to list the events: %v", err)
+	}
+	if len(records) != 1 || !records[0].IssuedTo("foo", "bar") || records[0].Requester != "requester" {
+		t.Errorf("Got records %+v, want the record of foo/bar", records)
+	}
+	events, err := client.Events("istio-system").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 || events.Items[0].InvolvedObject.Kind != "Namespace" {
+		t.Errorf("Expected the event of a non service account identity on the Citadel namespace, got %v", events.Items)
+	}
+}
+
+// citadelClusterRole is the ClusterRole of Citadel in the Helm chart.
+const citadelClusterRole = "../../../install/kubernetes/helm/istio/charts/security/templates/clusterrole.yaml"
+
+// citadelRules returns the rules of the ClusterRole of Citadel.
+func citadelRules(t *testing.T) []rbacv1.PolicyRule {
+	t.Helper()
+	data, err := ioutil.ReadFile(citadelClusterRole)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the rules are parsed, the metadata holds template directives.
+	i := strings.Index(string(data), "\nrules:")
+	if i < 0 {
+		t.Fatalf("No rules in %s", citadelClusterRole)
+	}
+	var role rbacv1.ClusterRole
+	if err := yaml.Unmarshal(data[i:], &role); err != nil {
+		t.Fatalf("Failed to parse %s: %v", citadelClusterRole, err)
+	}
+	return role.Rules
+}
+
+// newRestrictedClient returns a fake client forbidding the requests not allowed by the rules.
+func newRestrictedClient(rules []rbacv1.PolicyRule) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		resource := action.GetResource()
+		for _, rule := range rules {
+			if contains(rule.APIGroups, resource.Group) && contains(rule.Resources, resource.Resource) &&
+				contains(rule.Verbs, action.GetVerb()) {
+				return false, nil, nil
+			}
+		}
+		return true, nil, errors.NewForbidden(schema.GroupResource{Group: resource.Group, Resource: resource.Resource},
+			"", nil)
+	})
+	return client
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == rbacv1.ResourceAll {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEventSinkWithCitadelClusterRole(t *testing.T) {
+	rules := citadelRules(t)
+	client := newRestrictedClient(rules).CoreV1()
+	auditor := NewAuditor(NewEventSink(client, "istio-system"), true)
+	for _, host := range []string{"spiffe://cluster.local/ns/foo/sa/bar", "istio-citadel.istio-system.svc"} {
+		if err := auditor.Audit(issueCert(t, host), "requester", "10.1.2.3"); err != nil {
+			t.Errorf("Audit of %s: unexpected error %v", host, err)
+		}
+	}
+	if records, err := ListEvents(client, "foo", "bar"); err != nil || len(records) != 1 {
+		t.Errorf("ListEvents: expected the record of foo/bar, got %v, %v", records, err)
+	}
+
+	// Without the events rule, a fail-closed auditor would deny all the certificates.
+	var withoutEvents []rbacv1.PolicyRule
+	for _, rule := range rules {
+		if !contains(rule.Resources, "events") {
+			withoutEvents = append(withoutEvents, rule)
+		}
+	}
+	auditor = NewAuditor(NewEventSink(newRestrictedClient(withoutEvents).CoreV1(), "istio-system"), true)
+	if err := auditor.Audit(issueCert(t, "spiffe://cluster.local/ns/foo/sa/bar"), "requester", "10.1.2.3"); err == nil {
+		t.Error("Audit without the events rule: expected an error")
+	}
+}
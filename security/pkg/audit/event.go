@@ -0,0 +1,109 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// CertificateIssuedReason is the reason of the audit events.
+	CertificateIssuedReason = "CertificateIssued"
+
+	// RecordAnnotationKey is the annotation of the audit events holding the JSON record.
+	RecordAnnotationKey = "security.istio.io/certificate-audit-record"
+
+	eventSource = "citadel"
+)
+
+// EventSink records the audit records as Kubernetes events on the service accounts the certificates are
+// issued to, or on the Citadel namespace for the certificates of other identities. The API server only
+// keeps the events for a limited time, one hour by default, so a long-term trail needs the FileSink.
+type EventSink struct {
+	client    corev1.CoreV1Interface
+	namespace string
+}
+
+// NewEventSink returns a sink creating the events, namespace being the namespace of Citadel.
+func NewEventSink(client corev1.CoreV1Interface, namespace string) *EventSink {
+	return &EventSink{client: client, namespace: namespace}
+}
+
+// Write creates the event of the record.
+func (s *EventSink) Write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	object := v1.ObjectReference{Kind: "Namespace", Name: s.namespace, Namespace: s.namespace}
+	if ns, sa, ok := record.ServiceAccount(); ok {
+		object = v1.ObjectReference{Kind: "ServiceAccount", APIVersion: "v1", Name: sa, Namespace: ns}
+	}
+	now := metav1.NewTime(record.IssuedAt)
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s.%s", object.Name, record.Serial),
+			Namespace:   object.Namespace,
+			Annotations: map[string]string{RecordAnnotationKey: string(data)},
+		},
+		InvolvedObject: object,
+		Reason:         CertificateIssuedReason,
+		Message: fmt.Sprintf("Issued the certificate %s for %v to %s, valid for %v", record.Serial,
+			record.SubjectIDs, record.Requester, time.Duration(record.TTLSeconds)*time.Second),
+		Source:         v1.EventSource{Component: eventSource},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           v1.EventTypeNormal,
+	}
+	_, err = s.client.Events(object.Namespace).Create(event)
+	return err
+}
+
+// ListEvents returns the records of the audit events on the service account.
+func ListEvents(client corev1.CoreV1Interface, namespace, serviceAccount string) ([]*Record, error) {
+	events, err := client.Events(namespace).List(metav1.ListOptions{
+		FieldSelector: fields.Set{
+			"involvedObject.kind": "ServiceAccount",
+			"involvedObject.name": serviceAccount,
+			"reason":              CertificateIssuedReason,
+		}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var records []*Record
+	for _, event := range events.Items {
+		data, found := event.Annotations[RecordAnnotationKey]
+		if !found {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal([]byte(data), record); err != nil {
+			return nil, fmt.Errorf("invalid audit record of the event %s: %v", event.Name, err)
+		}
+		if record.IssuedTo(namespace, serviceAccount) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
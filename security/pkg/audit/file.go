@@ -0,0 +1,80 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSink appends the audit records to a file, one JSON record per line.
+type FileSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileSink returns a sink appending to the file at path, which is created if missing.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit log %s: %v", path, err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Write appends the record to the file.
+func (s *FileSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// ReadFile returns the records of the audit log at path.
+func ReadFile(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() // nolint: errcheck
+
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("invalid audit record at line %d of %s: %v", line, path, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	auditRecordCounts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "citadel",
+		Subsystem: "audit",
+		Name:      "record_count",
+		Help:      "The number of audit records written for the issued certificates.",
+	})
+
+	auditErrorCounts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "citadel",
+		Subsystem: "audit",
+		Name:      "error_count",
+		Help:      "The number of issued certificates whose audit record failed to be written.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(auditRecordCounts)
+	prometheus.MustRegister(auditErrorCounts)
+}
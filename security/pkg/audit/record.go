@@ -0,0 +1,127 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records the certificates issued by Citadel in an append-only audit trail.
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// SecretControllerRequester is the requester of the certificates issued to the workload secrets.
+const SecretControllerRequester = "secret-controller"
+
+// Record is the audit record of an issued certificate.
+type Record struct {
+	// The serial number of the certificate, in hex.
+	Serial string `json:"serial"`
+	// The SANs of the certificate.
+	SubjectIDs []string `json:"subject_ids"`
+	// The authenticated identities of the requester, or SecretControllerRequester.
+	Requester string `json:"requester"`
+	// The IP of the requester, empty for the secret controller.
+	SourceIP string `json:"source_ip,omitempty"`
+	// The TTL of the certificate in seconds.
+	TTLSeconds int64 `json:"ttl_seconds"`
+	// The time of issuance.
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// NewRecord returns the record of the issued PEM certificate certPEM.
+func NewRecord(certPEM []byte, requester, sourceIP string) (*Record, error) {
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := util.ExtractIDs(cert.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the SANs of the certificate: %v", err)
+	}
+	return &Record{
+		Serial:     cert.SerialNumber.Text(16),
+		SubjectIDs: ids,
+		Requester:  requester,
+		SourceIP:   sourceIP,
+		TTLSeconds: int64(cert.NotAfter.Sub(cert.NotBefore) / time.Second),
+		IssuedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// ServiceAccount returns the namespace and the service account of the first SPIFFE ID of the record.
+func (r *Record) ServiceAccount() (namespace string, serviceAccount string, ok bool) {
+	for _, id := range r.SubjectIDs {
+		if !strings.HasPrefix(id, "spiffe://") {
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(id, "spiffe://"), "/")
+		if len(parts) == 5 && parts[1] == "ns" && parts[3] == "sa" {
+			return parts[2], parts[4], true
+		}
+	}
+	return "", "", false
+}
+
+// IssuedTo returns whether the certificate of the record is issued to the service account.
+func (r *Record) IssuedTo(namespace, serviceAccount string) bool {
+	ns, sa, ok := r.ServiceAccount()
+	return ok && ns == namespace && sa == serviceAccount
+}
+
+// Sink writes the audit records.
+type Sink interface {
+	Write(record *Record) error
+}
+
+// Auditor records the issued certificates in a sink.
+type Auditor struct {
+	sink Sink
+	// failClosed fails the issuance of the certificates whose record can't be written.
+	failClosed bool
+}
+
+// NewAuditor returns an auditor writing to sink. If failClosed is set, the certificates whose record can't
+// be written are not handed out.
+func NewAuditor(sink Sink, failClosed bool) *Auditor {
+	return &Auditor{sink: sink, failClosed: failClosed}
+}
+
+// Audit records the issuance of certPEM to the requester. A failure to record is logged and counted by
+// the citadel_audit_error_count metric, and only returned if the auditor fails closed, in which case the
+// certificate must not be handed out. A nil auditor records nothing.
+func (a *Auditor) Audit(certPEM []byte, requester, sourceIP string) error {
+	if a == nil {
+		return nil
+	}
+	record, err := NewRecord(certPEM, requester, sourceIP)
+	if err != nil {
+		err = fmt.Errorf("failed to create the audit record of the issued certificate: %v", err)
+	} else if err = a.sink.Write(record); err != nil {
+		err = fmt.Errorf("failed to write the audit record of the certificate %s: %v", record.Serial, err)
+	}
+	if err != nil {
+		log.Errorf("%v", err)
+		auditErrorCounts.Inc()
+		if a.failClosed {
+			return err
+		}
+		return nil
+	}
+	auditRecordCounts.Inc()
+	return nil
+}
@@ -62,4 +62,8 @@ type Config struct {
 
 	// RootCertFile defines the root cert of the CA client.
 	RootCertFile string
+
+	// JoinTokenFile defines the one-time join token of the CA client, which is presented for the
+	// initial certificate in the "jointoken" environment.
+	JoinTokenFile string
 }
@@ -64,3 +64,37 @@ func (c *GrpcConnection) SendCSR(req *pb.CsrRequest) (*pb.CsrResponse, error) {
 func (c *GrpcConnection) Close() error {
 	return c.connection.Close()
 }
+
+// DialingGrpcConnection implements CAProtocol talking to CA via a new gRPC connection for each
+// request, so that the changes of the dial options, e.g. a renewed client certificate, take effect
+// on the next request.
+type DialingGrpcConnection struct {
+	caAddr      string
+	dialOptions func() ([]grpc.DialOption, error)
+}
+
+// NewDialingGrpcConnection creates a DialingGrpcConnection, which dials with the options returned by
+// dialOptions for each request.
+func NewDialingGrpcConnection(caAddr string, dialOptions func() ([]grpc.DialOption, error)) (*DialingGrpcConnection, error) {
+	if caAddr == "" {
+		return nil, fmt.Errorf("istio CA address is empty")
+	}
+	return &DialingGrpcConnection{
+		caAddr:      caAddr,
+		dialOptions: dialOptions,
+	}, nil
+}
+
+// SendCSR sends a resquest to CA server over a new connection and returns the response.
+func (c *DialingGrpcConnection) SendCSR(req *pb.CsrRequest) (*pb.CsrResponse, error) {
+	options, err := c.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := NewGrpcConnection(c.caAddr, options)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() // nolint: errcheck
+	return conn.SendCSR(req)
+}
@@ -0,0 +1,191 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jointoken
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// SecretName is the name of the secret holding the hashes of the outstanding join tokens.
+	SecretName = "istio-join-tokens"
+
+	// maxUpdateAttempts is the number of attempts to update the secret on conflicts.
+	maxUpdateAttempts = 5
+)
+
+var errInvalidToken = fmt.Errorf("the join token is invalid, expired or already used")
+
+// entry is an outstanding join token in the secret.
+type entry struct {
+	Identity string    `json:"identity"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// Controller issues and consumes the join tokens in the secret.
+type Controller struct {
+	core      corev1.CoreV1Interface
+	namespace string
+	now       func() time.Time
+}
+
+// NewController creates a new Controller.
+func NewController(namespace string, core corev1.CoreV1Interface) *Controller {
+	return &Controller{
+		namespace: namespace,
+		core:      core,
+		now:       time.Now,
+	}
+}
+
+// Create issues a join token bound to the identity, which is valid for ttl.
+func (c *Controller) Create(identity string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", fmt.Errorf("the TTL of the join token must be positive")
+	}
+	token, err := New(identity)
+	if err != nil {
+		return "", err
+	}
+	value, err := json.Marshal(entry{Identity: identity, Expiry: c.now().Add(ttl)})
+	if err != nil {
+		return "", err
+	}
+	err = c.update(func(secret *v1.Secret) error {
+		secret.Data[hash(token)] = value
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Validate returns the identity the join token is bound to, without consuming the token.
+func (c *Controller) Validate(token string) (string, error) {
+	secret, err := c.core.Secrets(c.namespace).Get(SecretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", errInvalidToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get join tokens: %v", err)
+	}
+	e, err := lookup(secret, token)
+	if err != nil {
+		return "", err
+	}
+	if c.now().After(e.Expiry) {
+		return "", errInvalidToken
+	}
+	return e.Identity, nil
+}
+
+// Consume validates the join token and removes it from the secret, so that each token is accepted
+// at most once. It returns the identity the token is bound to.
+func (c *Controller) Consume(token string) (string, error) {
+	var identity string
+	err := c.update(func(secret *v1.Secret) error {
+		e, err := lookup(secret, token)
+		if err != nil {
+			return err
+		}
+		delete(secret.Data, hash(token))
+		identity = e.Identity
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return identity, nil
+}
+
+// lookup returns the entry of the join token in the secret.
+func lookup(secret *v1.Secret, token string) (*entry, error) {
+	value, ok := secret.Data[hash(token)]
+	if !ok {
+		return nil, errInvalidToken
+	}
+	var e entry
+	if err := json.Unmarshal(value, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse the join token entry: %v", err)
+	}
+	return &e, nil
+}
+
+// update applies modify to the secret, after removing the expired tokens from it. It retries
+// on conflicts, so that concurrent consumers of the same token cannot both succeed.
+func (c *Controller) update(modify func(*v1.Secret) error) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err = c.tryUpdate(modify)
+		if !errors.IsConflict(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to update join tokens: %v", err)
+}
+
+func (c *Controller) tryUpdate(modify func(*v1.Secret) error) error {
+	secret, err := c.core.Secrets(c.namespace).Get(SecretName, metav1.GetOptions{})
+	exists := true
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get join tokens: %v", err)
+		}
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      SecretName,
+				Namespace: c.namespace,
+			},
+		}
+		exists = false
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	c.removeExpired(secret)
+	if err = modify(secret); err != nil {
+		return err
+	}
+	if exists {
+		_, err = c.core.Secrets(c.namespace).Update(secret)
+	} else {
+		_, err = c.core.Secrets(c.namespace).Create(secret)
+	}
+	if errors.IsConflict(err) || errors.IsAlreadyExists(err) {
+		return errors.NewConflict(v1.Resource("secrets"), SecretName, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update join tokens: %v", err)
+	}
+	return nil
+}
+
+func (c *Controller) removeExpired(secret *v1.Secret) {
+	now := c.now()
+	for key, value := range secret.Data {
+		var e entry
+		if err := json.Unmarshal(value, &e); err == nil && now.After(e.Expiry) {
+			delete(secret.Data, key)
+		}
+	}
+}
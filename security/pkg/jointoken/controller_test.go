@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jointoken
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestController(t *testing.T) {
+	const identity = "spiffe://cluster.local/ns/vm/sa/database"
+	c := NewController("istio-system", fake.NewSimpleClientset().CoreV1())
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	if _, err := c.Create("database", time.Hour); err == nil {
+		t.Error("Created a join token for a non SPIFFE identity")
+	}
+	if _, err := c.Create(identity, 0); err == nil {
+		t.Error("Created a join token with zero TTL")
+	}
+
+	token, err := c.Create(identity, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create the join token: %v", err)
+	}
+	if id, err := Identity(token); err != nil || id != identity {
+		t.Errorf("Identity(%q) = %q, %v, expected %q", token, id, err, identity)
+	}
+	expired, err := c.Create(identity, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create the join token: %v", err)
+	}
+
+	if _, err := c.Validate(token + "0"); err == nil {
+		t.Error("Validated an invalid join token")
+	}
+	for i := 0; i < 2; i++ {
+		if id, err := c.Validate(token); err != nil || id != identity {
+			t.Errorf("Validate() = %q, %v, expected %q", id, err, identity)
+		}
+	}
+	if _, err := c.Consume(token + "0"); err == nil {
+		t.Error("Consumed an invalid join token")
+	}
+	if id, err := c.Consume(token); err != nil || id != identity {
+		t.Errorf("Consume() = %q, %v, expected %q", id, err, identity)
+	}
+	if _, err := c.Consume(token); err == nil {
+		t.Error("Consumed the join token twice")
+	}
+	if _, err := c.Validate(token); err == nil {
+		t.Error("Validated a consumed join token")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := c.Validate(expired); err == nil {
+		t.Error("Validated an expired join token")
+	}
+	if _, err := c.Consume(expired); err == nil {
+		t.Error("Consumed an expired join token")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	for _, token := range []string{"", "abc", "abc.def", "c3BpZmZlOi8vZm9v.", "a.b.c"} {
+		if _, err := Identity(token); err == nil {
+			t.Errorf("Identity(%q) succeeded, expected an error", token)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jointoken implements the one-time join tokens issued by Citadel, which attest the
+// workloads outside Kubernetes, e.g. on-premise VMs, for their initial certificates.
+package jointoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pkg/spiffe"
+)
+
+const (
+	// MetadataKey is the gRPC metadata key carrying the join token in the requests to Citadel.
+	MetadataKey = "istio-join-token"
+
+	// secretSize is the number of random bytes in a join token.
+	secretSize = 32
+	separator  = "."
+)
+
+// New generates a join token bound to the identity. The token is the base64url-encoded identity and
+// a random secret separated by a dot, so that the node agent learns its identity from the token.
+func New(identity string) (string, error) {
+	if !strings.HasPrefix(identity, spiffe.URIPrefix) {
+		return "", fmt.Errorf("identity %q is not a SPIFFE ID", identity)
+	}
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate the join token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(identity)) + separator + hex.EncodeToString(secret), nil
+}
+
+// Identity returns the identity the join token is bound to. It does not validate the token.
+func Identity(token string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(token), separator)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", fmt.Errorf("malformed join token")
+	}
+	identity, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || !strings.HasPrefix(string(identity), spiffe.URIPrefix) {
+		return "", fmt.Errorf("malformed join token")
+	}
+	return string(identity), nil
+}
+
+// hash returns the key of the token in the secret. Only the hashes of the tokens are stored.
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configmap
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Cache serves the CA TLS root certificate from a local copy of the configmap, kept up to date by an
+// informer, so that frequent readers do not query the API server.
+type Cache struct {
+	namespace  string
+	store      cache.Store
+	controller cache.Controller
+}
+
+// NewCache creates a new Cache.
+func NewCache(namespace string, core corev1.CoreV1Interface) *Cache {
+	selector := fields.OneTermEqualSelector("metadata.name", istioSecurityConfigMapName).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return core.ConfigMaps(namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return core.ConfigMaps(namespace).Watch(options)
+		},
+	}
+	store, controller := cache.NewInformer(lw, &v1.ConfigMap{}, time.Minute, cache.ResourceEventHandlerFuncs{})
+	return &Cache{
+		namespace:  namespace,
+		store:      store,
+		controller: controller,
+	}
+}
+
+// Run starts the informer until stopCh is closed.
+func (c *Cache) Run(stopCh <-chan struct{}) {
+	go c.controller.Run(stopCh)
+}
+
+// GetCATLSRootCert gets the CA TLS root certificate from the cached configmap.
+func (c *Cache) GetCATLSRootCert() (string, error) {
+	obj, exists, err := c.store.GetByKey(c.namespace + "/" + istioSecurityConfigMapName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get CA TLS root cert: %v", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("configmap %s is not found", istioSecurityConfigMapName)
+	}
+	rootCert := obj.(*v1.ConfigMap).Data[caTLSRootCertName]
+	if rootCert == "" {
+		return "", fmt.Errorf("failed to get CA TLS root cert from configmap %s:%s",
+			istioSecurityConfigMapName, caTLSRootCertName)
+	}
+	return rootCert, nil
+}
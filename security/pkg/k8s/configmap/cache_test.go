@@ -0,0 +1,52 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configmap
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCacheGetCATLSRootCert(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	c := NewCache("test-ns", client.CoreV1())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c.Run(stopCh)
+
+	if _, err := c.GetCATLSRootCert(); err == nil {
+		t.Error("GetCATLSRootCert() succeeded without the configmap")
+	}
+
+	if err := NewController("test-ns", client.CoreV1()).InsertCATLSRootCert("ABCD"); err != nil {
+		t.Fatalf("Failed to insert the CA TLS root cert: %v", err)
+	}
+	var rootCert string
+	var err error
+	for i := 0; i < 50; i++ {
+		if rootCert, err = c.GetCATLSRootCert(); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil || rootCert != "ABCD" {
+		t.Errorf("GetCATLSRootCert() = %q, %v, expected %q", rootCert, err, "ABCD")
+	}
+	if actions := client.Actions(); len(actions) > 4 {
+		t.Errorf("GetCATLSRootCert() queried the API server: %v", actions)
+	}
+}
@@ -16,6 +16,7 @@ package controller
 
 import (
 	"bytes"
+	"crypto/x509"
 	"fmt"
 	"strings"
 	"time"
@@ -31,9 +32,11 @@ import (
 
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/listwatch"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/trustbundle"
 )
 
 /* #nosec: disable gas linter */
@@ -96,6 +99,9 @@ type SecretController struct {
 	// If true, generate a PKCS#8 private key.
 	pkcs8Key bool
 
+	// The algorithm of the generated private keys, RSA if empty.
+	keyAlgorithm util.KeyAlgorithm
+
 	// whether ServiceAccount objects must explicitly opt-in for secrets.
 	// Object explicit opt-in is based on "istio-inject" NS label value.
 	// The default value should be read from a configmap and applied consistently
@@ -117,13 +123,20 @@ type SecretController struct {
 	scrtStore      cache.Store
 
 	monitoring monitoringMetrics
+
+	// Records the issued certificates, nil if not audited.
+	auditor *audit.Auditor
+
+	// The root certificates of the federated trust domains appended to those of the workloads, nil if
+	// they are not distributed to the workloads.
+	trustBundles *trustbundle.Controller
 }
 
 // NewSecretController returns a pointer to a newly constructed SecretController instance.
 func NewSecretController(ca ca.CertificateAuthority, requireOptIn bool, certTTL time.Duration,
 	gracePeriodRatio float32, minGracePeriod time.Duration, dualUse bool,
-	core corev1.CoreV1Interface, forCA bool, pkcs8Key bool, namespaces []string,
-	dnsNames map[string]*DNSNameEntry) (*SecretController, error) {
+	core corev1.CoreV1Interface, forCA bool, pkcs8Key bool, keyAlgorithm util.KeyAlgorithm, namespaces []string,
+	dnsNames map[string]*DNSNameEntry, auditor *audit.Auditor, trustBundles *trustbundle.Controller) (*SecretController, error) {
 
 	if gracePeriodRatio < 0 || gracePeriodRatio > 1 {
 		return nil, fmt.Errorf("grace period ratio %f should be within [0, 1]", gracePeriodRatio)
@@ -142,10 +155,13 @@ func NewSecretController(ca ca.CertificateAuthority, requireOptIn bool, certTTL
 		core:             core,
 		forCA:            forCA,
 		pkcs8Key:         pkcs8Key,
+		keyAlgorithm:     keyAlgorithm,
 		explicitOptIn:    requireOptIn,
 		namespaces:       make(map[string]struct{}),
 		dnsNames:         dnsNames,
 		monitoring:       newMonitoringMetrics(),
+		auditor:          auditor,
+		trustBundles:     trustBundles,
 	}
 
 	for _, ns := range namespaces {
@@ -276,11 +292,10 @@ func (sc *SecretController) upsertSecret(saName, saNamespace string) {
 
 		return
 	}
-	rootCert := sc.ca.GetCAKeyCertBundle().GetRootCertPem()
 	secret.Data = map[string][]byte{
 		CertChainID:  chain,
 		PrivateKeyID: key,
-		RootCertID:   rootCert,
+		RootCertID:   sc.rootCerts(),
 	}
 
 	// We retry several times when create secret to mitigate transient network failures.
@@ -355,10 +370,11 @@ func (sc *SecretController) generateKeyAndCert(saName string, saNamespace string
 	}
 
 	options := util.CertOptions{
-		Host:       id,
-		RSAKeySize: keySize,
-		IsDualUse:  sc.dualUse,
-		PKCS8Key:   sc.pkcs8Key,
+		Host:         id,
+		RSAKeySize:   keySize,
+		KeyAlgorithm: sc.keyAlgorithm,
+		IsDualUse:    sc.dualUse,
+		PKCS8Key:     sc.pkcs8Key,
 	}
 
 	csrPEM, keyPEM, err := util.GenCSR(options)
@@ -375,6 +391,9 @@ func (sc *SecretController) generateKeyAndCert(saName string, saNamespace string
 		sc.monitoring.GetCertSignError(signErr.(*ca.Error).ErrorType()).Inc()
 		return nil, nil, fmt.Errorf("CSR signing error (%v)", signErr.(*ca.Error))
 	}
+	if err := sc.auditor.Audit(certPEM, audit.SecretControllerRequester, ""); err != nil {
+		return nil, nil, err
+	}
 	certPEM = append(certPEM, certChainPEM...)
 
 	return certPEM, keyPEM, nil
@@ -411,15 +430,20 @@ func (sc *SecretController) scrtUpdated(oldObj, newObj interface{}) {
 			certLifeTime, sc.gracePeriodRatio, gracePeriod, sc.minGracePeriod)
 		gracePeriod = sc.minGracePeriod
 	}
-	rootCertificate := sc.ca.GetCAKeyCertBundle().GetRootCertPem()
+	signingCert, _, _, _ := sc.ca.GetCAKeyCertBundle().GetAll()
+	rootCertificate := sc.rootCerts()
 
 	// Refresh the secret if 1) the certificate contained in the secret is about
 	// to expire, or 2) the root certificate in the secret is different than the
 	// one held by the ca (this may happen when the CA is restarted and
-	// a new self-signed CA cert is generated).
-	if certLifeTimeLeft < gracePeriod || !bytes.Equal(rootCertificate, scrt.Data[RootCertID]) {
+	// a new self-signed CA cert is generated, during a root rotation, or when
+	// the root certificates of a federated trust domain change), or
+	// 3) the certificate is not signed by the signing certificate of the ca
+	// (this happens when a root rotation moves to the new root).
+	if certLifeTimeLeft < gracePeriod || !bytes.Equal(rootCertificate, scrt.Data[RootCertID]) ||
+		!signedBy(cert, signingCert) {
 		log.Infof("Refreshing secret %s/%s, either the leaf certificate is about to expire "+
-			"or the root certificate is outdated", namespace, name)
+			"or the root or signing certificate is outdated", namespace, name)
 
 		if err = sc.refreshSecret(scrt); err != nil {
 			log.Errorf("Failed to update secret %s/%s (error: %s)", namespace, name, err)
@@ -427,6 +451,33 @@ func (sc *SecretController) scrtUpdated(oldObj, newObj interface{}) {
 	}
 }
 
+// UpToDateWorkloads returns the number of Istio secrets holding the root certificates of the ca and a
+// certificate signed by its signing certificate, and the total number of Istio secrets.
+func (sc *SecretController) UpToDateWorkloads() (upToDate, total int) {
+	signingCert, _, _, _ := sc.ca.GetCAKeyCertBundle().GetAll()
+	rootCertificate := sc.rootCerts()
+	for _, obj := range sc.scrtStore.List() {
+		scrt, ok := obj.(*v1.Secret)
+		if !ok {
+			continue
+		}
+		total++
+		if !bytes.Equal(rootCertificate, scrt.Data[RootCertID]) {
+			continue
+		}
+		if cert, err := util.ParsePemEncodedCertificate(scrt.Data[CertChainID]); err == nil && signedBy(cert, signingCert) {
+			upToDate++
+		}
+	}
+	return upToDate, total
+}
+
+// signedBy returns whether cert is signed by signingCert. The signature can't be checked against
+// a ca that is not ready.
+func signedBy(cert, signingCert *x509.Certificate) bool {
+	return signingCert == nil || cert.CheckSignatureFrom(signingCert) == nil
+}
+
 // refreshSecret is an inner func to refresh cert secrets when necessary
 func (sc *SecretController) refreshSecret(scrt *v1.Secret) error {
 	namespace := scrt.GetNamespace()
@@ -439,8 +490,13 @@ func (sc *SecretController) refreshSecret(scrt *v1.Secret) error {
 
 	scrt.Data[CertChainID] = chain
 	scrt.Data[PrivateKeyID] = key
-	scrt.Data[RootCertID] = sc.ca.GetCAKeyCertBundle().GetRootCertPem()
+	scrt.Data[RootCertID] = sc.rootCerts()
 
 	_, err = sc.core.Secrets(namespace).Update(scrt)
 	return err
 }
+
+// rootCerts returns the root certificates of the ca, followed by those of the federated trust domains.
+func (sc *SecretController) rootCerts() []byte {
+	return trustbundle.MergeRootCerts(sc.ca.GetCAKeyCertBundle().GetRootCertPem(), sc.trustBundles.PeerRootCerts())
+}
@@ -144,8 +144,8 @@ func TestSecretController(t *testing.T) {
 			},
 		}
 		controller, err := NewSecretController(createFakeCA(), requireExplicitOptIn, defaultTTL,
-			tc.gracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false,
-			[]string{metav1.NamespaceAll}, webhooks)
+			tc.gracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false, util.RSAKey,
+			[]string{metav1.NamespaceAll}, webhooks, nil, nil)
 		if tc.shouldFail {
 			if err == nil {
 				t.Errorf("should have failed to create secret controller")
@@ -182,8 +182,8 @@ func TestSecretContent(t *testing.T) {
 	saNamespace := "test-namespace"
 	client := fake.NewSimpleClientset()
 	controller, err := NewSecretController(createFakeCA(), requireExplicitOptIn, defaultTTL,
-		defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false,
-		[]string{metav1.NamespaceAll}, map[string]*DNSNameEntry{})
+		defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false, util.RSAKey,
+		[]string{metav1.NamespaceAll}, map[string]*DNSNameEntry{}, nil, nil)
 	if err != nil {
 		t.Errorf("Failed to create secret controller: %v", err)
 	}
@@ -202,11 +202,37 @@ func TestSecretContent(t *testing.T) {
 		t.Errorf("Cert chain verification error: expected %v but got %v", certChain, secret.Data[CertChainID])
 	}
 }
+
+func TestSecretContentWithECDSAKey(t *testing.T) {
+	saName := "test-serviceaccount"
+	saNamespace := "test-namespace"
+	client := fake.NewSimpleClientset()
+	controller, err := NewSecretController(createFakeCA(), requireExplicitOptIn, defaultTTL,
+		defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false, util.ECDSAP256Key,
+		[]string{metav1.NamespaceAll}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create secret controller: %v", err)
+	}
+	controller.saAdded(createServiceAccount(saName, saNamespace))
+
+	secret, err := client.CoreV1().Secrets(saNamespace).Get(GetSecretName(saName), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to retrieve secret: %v", err)
+	}
+	key, err := util.ParsePemEncodedKey(secret.Data[PrivateKeyID])
+	if err != nil {
+		t.Fatalf("Failed to parse the private key: %v", err)
+	}
+	if algorithm, _, err := util.GetKeyAlgorithm(key); err != nil || algorithm != util.ECDSAP256Key {
+		t.Errorf("Unexpected key algorithm %s (%v), expecting %s", algorithm, err, util.ECDSAP256Key)
+	}
+}
+
 func TestDeletedIstioSecret(t *testing.T) {
 	client := fake.NewSimpleClientset()
 	controller, err := NewSecretController(createFakeCA(), requireExplicitOptIn, defaultTTL,
-		defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false,
-		[]string{metav1.NamespaceAll}, nil)
+		defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false, util.RSAKey,
+		[]string{metav1.NamespaceAll}, nil, nil, nil)
 	if err != nil {
 		t.Errorf("failed to create secret controller: %v", err)
 	}
@@ -325,8 +351,8 @@ func TestUpdateSecret(t *testing.T) {
 		client := fake.NewSimpleClientset()
 
 		controller, err := NewSecretController(createFakeCA(), requireExplicitOptIn, time.Hour,
-			tc.gracePeriodRatio, tc.minGracePeriod, false, client.CoreV1(), false, false,
-			[]string{metav1.NamespaceAll}, nil)
+			tc.gracePeriodRatio, tc.minGracePeriod, false, client.CoreV1(), false, false, util.RSAKey,
+			[]string{metav1.NamespaceAll}, nil, nil, nil)
 		if err != nil {
 			t.Errorf("failed to create secret controller: %v", err)
 		}
@@ -415,8 +441,8 @@ func TestSecretOptIn(t *testing.T) {
 	for k, tc := range testCases {
 		client := fake.NewSimpleClientset()
 		controller, err := NewSecretController(createFakeCA(), tc.requireOptIn, defaultTTL,
-			defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false,
-			[]string{metav1.NamespaceAll}, nil)
+			defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false, util.RSAKey,
+			[]string{metav1.NamespaceAll}, nil, nil, nil)
 		if err != nil {
 			t.Errorf("failed to create secret controller: %v", err)
 		}
@@ -481,3 +507,74 @@ func createNS(name string, labels map[string]string) *v1.Namespace {
 		},
 	}
 }
+
+func TestUpToDateWorkloads(t *testing.T) {
+	newBundle := func() util.KeyCertBundle {
+		certPem, keyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+			Org: "test.ca.org", TTL: time.Hour, IsCA: true, IsSelfSigned: true, KeyAlgorithm: util.ECDSAP256Key,
+		})
+		if err != nil {
+			t.Fatalf("Failed to generate a root: %v", err)
+		}
+		bundle, err := util.NewVerifiedKeyCertBundleFromPem(certPem, keyPem, nil, certPem)
+		if err != nil {
+			t.Fatalf("Failed to create a key cert bundle: %v", err)
+		}
+		return bundle
+	}
+	bundle := newBundle()
+	istioCA, err := ca.NewIstioCA(&ca.IstioCAOptions{CertTTL: time.Hour, MaxCertTTL: time.Hour, KeyCertBundle: bundle})
+	if err != nil {
+		t.Fatalf("Failed to create a CA: %v", err)
+	}
+	client := fake.NewSimpleClientset()
+	controller, err := NewSecretController(istioCA, requireExplicitOptIn, time.Hour,
+		defaultGracePeriodRatio, defaultMinGracePeriod, false, client.CoreV1(), false, false, util.ECDSAP256Key,
+		[]string{metav1.NamespaceAll}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create secret controller: %v", err)
+	}
+	sync := func() *v1.Secret {
+		scrt, err := client.CoreV1().Secrets("test-ns").Get(GetSecretName("test-sa"), metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to retrieve secret: %v", err)
+		}
+		if err := controller.scrtStore.Update(scrt); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+		return scrt
+	}
+	check := func(stage string, wantUpToDate int) {
+		if upToDate, total := controller.UpToDateWorkloads(); upToDate != wantUpToDate || total != 1 {
+			t.Errorf("%s: got %d/%d up-to-date workloads, want %d/1", stage, upToDate, total, wantUpToDate)
+		}
+	}
+
+	controller.saAdded(createServiceAccount("test-sa", "test-ns"))
+	scrt := sync()
+	check("created secret", 1)
+
+	// The CA publishes a new root, and then signs with it.
+	oldCertPem, oldKeyPem, _, _ := bundle.GetAllPem()
+	newCertPem, newKeyPem, _, _ := newBundle().GetAllPem()
+	rootsPem := append(append([]byte{}, oldCertPem...), newCertPem...)
+	for _, stage := range []struct {
+		name            string
+		certPem, keyPem []byte
+	}{
+		{"published new root", oldCertPem, oldKeyPem},
+		{"signing with new root", newCertPem, newKeyPem},
+	} {
+		if err := bundle.VerifyAndSetAll(stage.certPem, stage.keyPem, nil, rootsPem); err != nil {
+			t.Fatalf("%s: failed to update the key cert bundle: %v", stage.name, err)
+		}
+		check(stage.name, 0)
+
+		controller.scrtUpdated(scrt, scrt)
+		scrt = sync()
+		check(stage.name+" refreshed", 1)
+		if !bytes.Equal(scrt.Data[RootCertID], rootsPem) {
+			t.Errorf("%s: the refreshed secret does not hold both roots", stage.name)
+		}
+	}
+}
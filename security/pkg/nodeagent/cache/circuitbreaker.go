@@ -0,0 +1,91 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// The states of the circuit breaker toward the CA.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuitBreaker suspends the CSRs to the CA after consecutive failures, so that the retries of
+// every proxy do not pile up on a CA which is down. Once the open duration has passed, a single
+// CSR is let through to probe the CA, closing the breaker if it succeeds.
+type circuitBreaker struct {
+	mutex sync.Mutex
+	// The number of consecutive failures opening the breaker, 0 disables the breaker.
+	threshold    int
+	openDuration time.Duration
+
+	failures int
+	// The time until which the breaker rejects the CSRs.
+	openUntil time.Time
+}
+
+func newCircuitBreaker(threshold int, openDuration time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, openDuration: openDuration}
+}
+
+// allow returns whether a CSR can be sent to the CA.
+func (b *circuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		return false
+	}
+	// Half-open: let this CSR probe the CA, and reject the others until it completes.
+	b.openUntil = now.Add(b.openDuration)
+	return true
+}
+
+// success records a CSR the CA handled.
+func (b *circuitBreaker) success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures = 0
+}
+
+// failure records a CSR failing because of the CA.
+func (b *circuitBreaker) failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.openDuration)
+	}
+}
+
+// state returns the state of the breaker.
+func (b *circuitBreaker) state() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return circuitClosed
+	}
+	if time.Now().Before(b.openUntil) {
+		return circuitOpen
+	}
+	return circuitHalfOpen
+}
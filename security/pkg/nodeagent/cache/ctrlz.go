@@ -0,0 +1,138 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"html/template"
+	"net/http"
+
+	"istio.io/istio/pkg/ctrlz/fw"
+)
+
+// stateTemplate renders the states of the caches in ControlZ.
+const stateTemplate = `{{ define "content" }}
+
+<p>
+    The secrets cached by the node agent for the proxies, and the state of the CSRs toward the CA.
+</p>
+
+{{ range $name, $state := . }}
+<h2>{{ $name }}</h2>
+
+<p>
+    Root cert known: {{ $state.HasRootCert }}<br>
+    CA circuit breaker: {{ $state.CACircuitBreaker }}<br>
+    Cached secrets served during CA failures: {{ $state.StaleSecretsServed }}
+</p>
+
+<table>
+    <thead>
+    <tr>
+        <th>Workload Identity</th>
+        <th>Resource</th>
+        <th>Expires</th>
+        <th>Last Requested</th>
+        <th>Needs Refresh</th>
+    </tr>
+    </thead>
+    <tbody>
+        {{ range $state.Workloads }}
+            <tr>
+                <td>{{.Identity}}</td>
+                <td>{{.ResourceName}}</td>
+                <td>{{.ExpireTime}}</td>
+                <td>{{.LastUsed}}</td>
+                <td>{{.NeedsRefresh}}</td>
+            </tr>
+        {{ end }}
+    </tbody>
+</table>
+
+<table>
+    <thead>
+    <tr>
+        <th>Connection</th>
+        <th>Resource</th>
+        <th>Version</th>
+        <th>Expires</th>
+    </tr>
+    </thead>
+    <tbody>
+        {{ range $state.Connections }}
+            <tr>
+                <td>{{.ConnectionID}}</td>
+                <td>{{.ResourceName}}</td>
+                <td>{{.Version}}</td>
+                <td>{{.ExpireTime}}</td>
+            </tr>
+        {{ end }}
+    </tbody>
+</table>
+{{ end }}
+
+{{ template "last-refresh" .}}
+
+{{ end }}
+`
+
+// cacheTopic is the ControlZ topic exposing the state of the secret caches.
+type cacheTopic struct {
+	caches map[string]*SecretCache
+}
+
+var _ fw.Topic = &cacheTopic{}
+
+// NewTopic returns the ControlZ topic of the caches, by name. The nil caches are skipped.
+func NewTopic(caches map[string]*SecretCache) fw.Topic {
+	t := &cacheTopic{caches: make(map[string]*SecretCache)}
+	for name, c := range caches {
+		if c != nil {
+			t.caches[name] = c
+		}
+	}
+	return t
+}
+
+// Title is implementation of Topic.Title.
+func (t *cacheTopic) Title() string {
+	return "Secret Cache"
+}
+
+// Prefix is implementation of Topic.Prefix.
+func (t *cacheTopic) Prefix() string {
+	return "secretcache"
+}
+
+// Activate is implementation of Topic.Activate.
+func (t *cacheTopic) Activate(context fw.TopicContext) {
+	l := template.Must(context.Layout().Clone())
+	tmpl := template.Must(l.Parse(stateTemplate))
+
+	_ = context.HTMLRouter().StrictSlash(true).NewRoute().Path("/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fw.RenderHTML(w, tmpl, t.states())
+	})
+
+	_ = context.JSONRouter().StrictSlash(true).NewRoute().Methods("GET").Path("/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fw.RenderJSON(w, http.StatusOK, t.states())
+	})
+}
+
+func (t *cacheTopic) states() map[string]*State {
+	states := make(map[string]*State, len(t.caches))
+	for name, c := range t.caches {
+		states[name] = c.State()
+	}
+	return states
+}
@@ -61,8 +61,14 @@ const (
 
 	// initialBackOffIntervalInMilliSec is the initial backoff time interval when hitting non-retryable error in CSR request.
 	initialBackOffIntervalInMilliSec = 50
+
+	// maxBackOffIntervalInMilliSec caps the exponential backoff time interval between two CSR retries.
+	maxBackOffIntervalInMilliSec = 400
 )
 
+// errCircuitOpen is returned for the CSRs which are not sent because the CA failed repeatedly.
+var errCircuitOpen = status.Error(codes.Unavailable, "CSRs to the CA are suspended after repeated failures")
+
 type k8sJwtPayload struct {
 	Sub string `json:"sub"`
 }
@@ -97,6 +103,16 @@ type Options struct {
 
 	// set this flag to true if skip validate format for certificate chain returned from CA.
 	SkipValidateCert bool
+
+	// The algorithm of the private keys generated for CSRs, RSA if empty.
+	KeyAlgorithm util.KeyAlgorithm
+
+	// The number of consecutive CSR failures caused by the CA after which the CSRs are suspended for
+	// CSRCircuitOpenDuration, 0 to never suspend them.
+	CSRFailureThreshold int
+
+	// The time the CSRs are suspended for after CSRFailureThreshold consecutive failures.
+	CSRCircuitOpenDuration time.Duration
 }
 
 // SecretManager defines secrets management interface which is used by SDS.
@@ -155,6 +171,31 @@ type SecretCache struct {
 
 	rootCertMutex *sync.Mutex
 	rootCert      []byte
+
+	// workloadSecrets map is the cache for the secrets shared by the proxies of a workload.
+	// map key is workloadKey, map value is *workloadSecret.
+	workloadSecrets sync.Map
+
+	// The circuit breaker of the CSRs toward the CA.
+	breaker *circuitBreaker
+
+	// How many times a cached secret still valid was served because the CA failed.
+	staleSecretServedCount uint64
+}
+
+// workloadKey is the key of the secret of a workload, shared by the proxies presenting the same token.
+type workloadKey struct {
+	Token        string
+	ResourceName string
+}
+
+// workloadSecret is the cached secret of a workload.
+type workloadSecret struct {
+	// Serializes the CSRs of the workload, so that its proxies share one key and certificate.
+	mutex  sync.Mutex
+	secret *model.SecretItem
+	// The last time a proxy requested the secret.
+	lastUsed time.Time
 }
 
 // NewSecretCache creates a new secret cache.
@@ -165,6 +206,7 @@ func NewSecretCache(fetcher *secretfetcher.SecretFetcher, notifyCb func(string,
 		notifyCallback: notifyCb,
 		rootCertMutex:  &sync.Mutex{},
 		configOptions:  options,
+		breaker:        newCircuitBreaker(options.CSRFailureThreshold, options.CSRCircuitOpenDuration),
 	}
 
 	fetcher.AddCache = ret.UpdateK8sSecret
@@ -179,8 +221,9 @@ func NewSecretCache(fetcher *secretfetcher.SecretFetcher, notifyCb func(string,
 }
 
 // GenerateSecret generates new secret and cache the secret, this function is called by SDS.StreamSecrets
-// and SDS.FetchSecret. Since credential passing from client may change, regenerate secret every time
-// instead of reading from cache.
+// and SDS.FetchSecret. Since credential passing from client may change, the secret is only shared by
+// the proxies presenting the same credential: it is read from the workload cache if still fresh, and
+// regenerated otherwise.
 func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourceName, token string) (*model.SecretItem, error) {
 	var ns *model.SecretItem
 	key := ConnKey{
@@ -192,7 +235,7 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 		// If working as Citadel agent, send request for normal key/cert pair.
 		// If working as ingress gateway agent, fetch key/cert or root cert from SecretFetcher. Resource name for
 		// root cert ends with "-cacert".
-		ns, err := sc.generateSecret(ctx, token, resourceName, time.Now())
+		ns, err := sc.getWorkloadSecret(ctx, token, resourceName, true)
 		if err != nil {
 			log.Errorf("Failed to generate secret for proxy %q: %v", connectionID, err)
 			return nil, err
@@ -204,20 +247,13 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 
 	// If request is for root certificate,
 	// retry since rootCert may be empty until there is CSR response returned from CA.
-	if sc.rootCert == nil {
-		wait := retryWaitDuration
-		retryNum := 0
-		for ; retryNum < maxRetryNum; retryNum++ {
-			time.Sleep(retryWaitDuration)
-			if sc.rootCert != nil {
-				break
-			}
-
-			wait *= 2
-		}
+	rootCert := sc.getRootCert()
+	for retryNum := 0; rootCert == nil && retryNum < maxRetryNum; retryNum++ {
+		time.Sleep(retryWaitDuration)
+		rootCert = sc.getRootCert()
 	}
 
-	if sc.rootCert == nil {
+	if rootCert == nil {
 		log.Errorf("Failed to get root cert for proxy %q", connectionID)
 		return nil, errors.New("failed to get root cert")
 
@@ -226,7 +262,7 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 	t := time.Now()
 	ns = &model.SecretItem{
 		ResourceName: resourceName,
-		RootCert:     sc.rootCert,
+		RootCert:     rootCert,
 		Token:        token,
 		CreatedTime:  t,
 		Version:      t.String(),
@@ -439,7 +475,7 @@ func (sc *SecretCache) rotate(updateRootFlag bool) {
 			t := time.Now()
 			ns := &model.SecretItem{
 				ResourceName: resourceName,
-				RootCert:     sc.rootCert,
+				RootCert:     sc.getRootCert(),
 				Token:        e.Token,
 				CreatedTime:  t,
 				Version:      t.String(),
@@ -498,11 +534,16 @@ func (sc *SecretCache) rotate(updateRootFlag bool) {
 				// If token is still valid, re-generated the secret and push change to proxy.
 				// Most likey this code path may not necessary, since TTL of cert is much longer than token.
 				// When cert has expired, we could make it simple by assuming token has already expired.
-				ns, err := sc.generateSecret(context.Background(), e.Token, resourceName, now)
+				// The proxies of the workload share the secret, which is only regenerated for the first one.
+				ns, err := sc.getWorkloadSecret(context.Background(), e.Token, resourceName, false)
 				if err != nil {
 					log.Errorf("Failed to generate secret for proxy %q: %v", connectionID, err)
 					return
 				}
+				// The CA failed and the proxy keeps its secret until it expires.
+				if ns.Version == e.Version {
+					return
+				}
 
 				secretMap.Store(key, ns)
 
@@ -528,6 +569,86 @@ func (sc *SecretCache) rotate(updateRootFlag bool) {
 		sc.secrets.Store(key, *e)
 		return true
 	})
+
+	if !updateRootFlag {
+		sc.refreshWorkloadSecrets()
+	}
+}
+
+// refreshWorkloadSecrets evicts the secrets of the workloads no proxy requested within the eviction
+// duration, and pre-fetches the secrets of the others before they expire, so that a proxy of the
+// workload reconnecting, e.g. after a restart, gets its secret without waiting for the CA.
+func (sc *SecretCache) refreshWorkloadSecrets() {
+	now := time.Now()
+	wg := sync.WaitGroup{}
+	sc.workloadSecrets.Range(func(k interface{}, v interface{}) bool {
+		key := k.(workloadKey)
+		ws := v.(*workloadSecret)
+		ws.mutex.Lock()
+		lastUsed, secret := ws.lastUsed, ws.secret
+		ws.mutex.Unlock()
+
+		if now.After(lastUsed.Add(sc.configOptions.EvictionDuration)) {
+			sc.workloadSecrets.Delete(key)
+			return true
+		}
+		// The token of the workload can only be used again if it is still valid.
+		if secret == nil || !sc.shouldRefresh(secret) || sc.isTokenExpired() {
+			return true
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sc.getWorkloadSecret(context.Background(), key.Token, key.ResourceName, false); err != nil {
+				log.Warnf("Failed to pre-fetch the secret %q: %v", key.ResourceName, err)
+			}
+		}()
+		return true
+	})
+	wg.Wait()
+}
+
+// getWorkloadSecret returns the secret of the workload presenting token, read from the workload cache
+// unless it needs a refresh. If the refresh fails, the cached secret is served as long as it is valid,
+// so that the proxies ride out a CA outage. used marks the secret as requested by a proxy.
+func (sc *SecretCache) getWorkloadSecret(ctx context.Context, token, resourceName string, used bool) (
+	*model.SecretItem, error) {
+	// The secrets of the ingress gateway agent are read from the Kubernetes secrets.
+	if !sc.fetcher.UseCaClient {
+		return sc.generateSecret(ctx, token, resourceName, time.Now())
+	}
+
+	v, _ := sc.workloadSecrets.LoadOrStore(workloadKey{Token: token, ResourceName: resourceName}, &workloadSecret{})
+	ws := v.(*workloadSecret)
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	now := time.Now()
+	if used {
+		ws.lastUsed = now
+	}
+	if ws.secret != nil && !sc.shouldRefresh(ws.secret) {
+		return ws.secret, nil
+	}
+	ns, err := sc.generateSecret(ctx, token, resourceName, now)
+	if err != nil {
+		if ws.secret != nil && now.Before(ws.secret.ExpireTime) {
+			atomic.AddUint64(&sc.staleSecretServedCount, 1)
+			log.Warnf("Failed to refresh secret %q, serve the cached secret valid until %v: %v",
+				resourceName, ws.secret.ExpireTime, err)
+			return ws.secret, nil
+		}
+		return nil, err
+	}
+	ws.secret = ns
+	return ns, nil
+}
+
+func (sc *SecretCache) getRootCert() []byte {
+	sc.rootCertMutex.Lock()
+	defer sc.rootCertMutex.Unlock()
+	return sc.rootCert
 }
 
 func (sc *SecretCache) generateSecret(ctx context.Context, token, resourceName string, t time.Time) (*model.SecretItem, error) {
@@ -579,8 +700,9 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token, resourceName s
 		csrHostName = resourceName
 	}
 	options := util.CertOptions{
-		Host:       csrHostName,
-		RSAKeySize: keySize,
+		Host:         csrHostName,
+		RSAKeySize:   keySize,
+		KeyAlgorithm: sc.configOptions.KeyAlgorithm,
 	}
 
 	// Generate the cert/key, send CSR to CA.
@@ -598,17 +720,25 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token, resourceName s
 	var retry int64
 	var certChainPEM []string
 	for {
+		if !sc.breaker.allow() {
+			log.Warnf("CSR for %q is not sent since the CA failed repeatedly", resourceName)
+			return nil, errCircuitOpen
+		}
 		certChainPEM, err = sc.fetcher.CaClient.CSRSign(
 			ctx, csrPEM, exchangedToken, int64(sc.configOptions.SecretTTL.Seconds()))
 		if err == nil {
+			sc.breaker.success()
 			break
 		}
 
 		// If non-retryable error, fail the request by returning err
 		if !isRetryableErr(status.Code(err)) {
+			// The CA is available, it rejected the request.
+			sc.breaker.success()
 			log.Errorf("CSR for %q hit non-retryable error %v", resourceName, err)
 			return nil, err
 		}
+		sc.breaker.failure()
 
 		// If reach envoy timeout, fail the request by returning err
 		if startTime.Add(time.Millisecond * envoyDefaultTimeoutInMilliSec).Before(time.Now()) {
@@ -617,9 +747,9 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token, resourceName s
 		}
 
 		retry++
-		backOffInMilliSec = rand.Int63n(retry * initialBackOffIntervalInMilliSec)
-		time.Sleep(time.Duration(backOffInMilliSec) * time.Millisecond)
+		backOffInMilliSec = csrBackOffInMilliSec(retry)
 		log.Warnf("CSR failed for %q: %v, retry in %d millisec", resourceName, err, backOffInMilliSec)
+		time.Sleep(time.Duration(backOffInMilliSec) * time.Millisecond)
 	}
 
 	log.Debugf("CSR response certificate chain %+v \n", certChainPEM)
@@ -649,8 +779,9 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token, resourceName s
 
 	length := len(certChainPEM)
 	// Leaf cert is element '0'. Root cert is element 'n'.
-	rootCertChanged := !bytes.Equal(sc.rootCert, []byte(certChainPEM[length-1]))
-	if sc.rootCert == nil || rootCertChanged {
+	currentRootCert := sc.getRootCert()
+	rootCertChanged := !bytes.Equal(currentRootCert, []byte(certChainPEM[length-1]))
+	if currentRootCert == nil || rootCertChanged {
 		sc.rootCertMutex.Lock()
 		sc.rootCert = []byte(certChainPEM[length-1])
 		sc.rootCertMutex.Unlock()
@@ -727,6 +858,18 @@ func constructCSRHostName(trustDomain, token string) (string, error) {
 	return fmt.Sprintf(identityTemplate, domain, ns, sa), nil
 }
 
+// csrBackOffInMilliSec returns the jittered exponential backoff before the retry of a CSR: a random
+// duration up to the initial interval doubled for every previous retry, capped by the max interval.
+func csrBackOffInMilliSec(retry int64) int64 {
+	interval := int64(maxBackOffIntervalInMilliSec)
+	if retry <= 8 {
+		if i := int64(initialBackOffIntervalInMilliSec) << uint(retry-1); i < interval {
+			interval = i
+		}
+	}
+	return rand.Int63n(interval) + 1
+}
+
 func isRetryableErr(c codes.Code) bool {
 	switch c {
 	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unavailable:
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -29,6 +30,7 @@ import (
 
 	"istio.io/istio/security/pkg/nodeagent/model"
 	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+	"istio.io/istio/security/pkg/pki/util"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -167,6 +169,35 @@ func TestWorkloadAgentGenerateSecret(t *testing.T) {
 	}
 }
 
+func TestWorkloadAgentGenerateSecretWithECDSAKey(t *testing.T) {
+	opt := Options{
+		SecretTTL:        time.Minute,
+		RotationInterval: 300 * time.Microsecond,
+		EvictionDuration: 2 * time.Second,
+		InitialBackoff:   10,
+		SkipValidateCert: true,
+		KeyAlgorithm:     util.ECDSAP384Key,
+	}
+	fetcher := &secretfetcher.SecretFetcher{
+		UseCaClient: true,
+		CaClient:    newMockCAClient(),
+	}
+	sc := NewSecretCache(fetcher, notifyCb, opt)
+	defer sc.Close()
+
+	gotSecret, err := sc.GenerateSecret(context.Background(), "proxy1-id", testResourceName, "jwtToken1")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	key, err := util.ParsePemEncodedKey(gotSecret.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse the private key: %v", err)
+	}
+	if algorithm, _, err := util.GetKeyAlgorithm(key); err != nil || algorithm != util.ECDSAP384Key {
+		t.Errorf("Unexpected key algorithm %s (%v), expecting %s", algorithm, err, util.ECDSAP384Key)
+	}
+}
+
 func TestWorkloadAgentRefreshSecret(t *testing.T) {
 	fakeCACli := newMockCAClient()
 	opt := Options{
@@ -599,3 +630,146 @@ func convertToBytes(ss []string) []byte {
 	}
 	return res
 }
+
+// fakeCAClient counts the CSRs and fails them with err if set.
+type fakeCAClient struct {
+	mutex sync.Mutex
+	count int
+	err   error
+}
+
+func (c *fakeCAClient) CSRSign(ctx context.Context, csrPEM []byte, subjectID string,
+	certValidTTLInSec int64) ([]string /*PEM-encoded certificate chain*/, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return []string{fmt.Sprintf("cert-%d", c.count), "rootcert"}, nil
+}
+
+func (c *fakeCAClient) setErr(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.err = err
+}
+
+func (c *fakeCAClient) getCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.count
+}
+
+func TestWorkloadAgentShareSecret(t *testing.T) {
+	fakeCACli := &fakeCAClient{}
+	opt := Options{
+		SecretTTL:        time.Hour,
+		RotationInterval: time.Hour,
+		EvictionDuration: time.Hour,
+		InitialBackoff:   10,
+		SkipValidateCert: true,
+	}
+	sc := NewSecretCache(&secretfetcher.SecretFetcher{UseCaClient: true, CaClient: fakeCACli}, notifyCb, opt)
+	defer sc.Close()
+
+	ctx := context.Background()
+	secret1, err := sc.GenerateSecret(ctx, "proxy1-id", testResourceName, "jwtToken1")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	secret2, err := sc.GenerateSecret(ctx, "proxy2-id", testResourceName, "jwtToken1")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	if !bytes.Equal(secret1.CertificateChain, secret2.CertificateChain) || secret1.Version != secret2.Version {
+		t.Errorf("Expected the proxies of the workload to share the secret, got %q and %q",
+			secret1.CertificateChain, secret2.CertificateChain)
+	}
+	checkBool(t, "SecretExist", sc.SecretExist("proxy2-id", testResourceName, "jwtToken1", secret1.Version), true)
+
+	// Another token is another workload.
+	secret3, err := sc.GenerateSecret(ctx, "proxy3-id", testResourceName, "jwtToken2")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	if bytes.Equal(secret1.CertificateChain, secret3.CertificateChain) {
+		t.Errorf("Expected a new secret for another token")
+	}
+	if got := fakeCACli.getCount(); got != 2 {
+		t.Errorf("Got %d CSRs, want 2", got)
+	}
+	if state := sc.State(); len(state.Connections) != 3 || len(state.Workloads) != 2 || !state.HasRootCert {
+		t.Errorf("Unexpected cache state %+v", state)
+	}
+}
+
+func TestWorkloadAgentServeCachedSecretOnCAFailure(t *testing.T) {
+	fakeCACli := &fakeCAClient{}
+	opt := Options{
+		SecretTTL: time.Hour,
+		// The secrets need a refresh as soon as they are issued.
+		SecretRefreshGraceDuration: 2 * time.Hour,
+		RotationInterval:           time.Hour,
+		EvictionDuration:           time.Hour,
+		InitialBackoff:             10,
+		SkipValidateCert:           true,
+		CSRFailureThreshold:        2,
+		CSRCircuitOpenDuration:     time.Hour,
+	}
+	sc := NewSecretCache(&secretfetcher.SecretFetcher{UseCaClient: true, CaClient: fakeCACli}, notifyCb, opt)
+	defer sc.Close()
+
+	ctx := context.Background()
+	secret1, err := sc.GenerateSecret(ctx, "proxy1-id", testResourceName, "jwtToken1")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+
+	fakeCACli.setErr(status.Error(codes.Unavailable, "CA is down"))
+	secret2, err := sc.GenerateSecret(ctx, "proxy2-id", testResourceName, "jwtToken1")
+	if err != nil {
+		t.Fatalf("Expected the cached secret while the CA is down, got error: %v", err)
+	}
+	if !bytes.Equal(secret1.CertificateChain, secret2.CertificateChain) {
+		t.Errorf("Got secret %q, want the cached secret %q", secret2.CertificateChain, secret1.CertificateChain)
+	}
+	if got := atomic.LoadUint64(&sc.staleSecretServedCount); got != 1 {
+		t.Errorf("Got %d stale secrets served, want 1", got)
+	}
+
+	// The circuit breaker opened after 2 failures: no CSR is sent to the CA anymore.
+	if got := sc.State().CACircuitBreaker; got != circuitOpen {
+		t.Errorf("Got circuit breaker %s, want %s", got, circuitOpen)
+	}
+	count := fakeCACli.getCount()
+	if _, err := sc.GenerateSecret(ctx, "proxy3-id", testResourceName, "jwtToken2"); err != errCircuitOpen {
+		t.Errorf("Got error %v, want %v", err, errCircuitOpen)
+	}
+	if got := fakeCACli.getCount(); got != count {
+		t.Errorf("Got %d CSRs sent while the circuit breaker is open", got-count)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, 50*time.Millisecond)
+	b.failure()
+	checkBool(t, "allow after 1 failure", b.allow(), true)
+	b.failure()
+	checkBool(t, "allow after 2 failures", b.allow(), false)
+
+	time.Sleep(60 * time.Millisecond)
+	if got := b.state(); got != circuitHalfOpen {
+		t.Errorf("Got state %s, want %s", got, circuitHalfOpen)
+	}
+	checkBool(t, "allow the probe", b.allow(), true)
+	checkBool(t, "allow during the probe", b.allow(), false)
+	b.success()
+	checkBool(t, "allow after success", b.allow(), true)
+
+	for retry := int64(1); retry < 100; retry++ {
+		if backOff := csrBackOffInMilliSec(retry); backOff < 1 || backOff > maxBackOffIntervalInMilliSec {
+			t.Errorf("Got backoff %d for retry %d", backOff, retry)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"istio.io/istio/security/pkg/nodeagent/model"
+)
+
+// State is the state of the secret cache, exposed for debugging.
+type State struct {
+	// The secrets pushed to the proxy connections.
+	Connections []ConnectionState `json:"connections"`
+	// The secrets shared by the proxies of the workloads.
+	Workloads []WorkloadState `json:"workloads"`
+	// Whether the root cert is known.
+	HasRootCert bool `json:"has_root_cert"`
+	// The state of the circuit breaker of the CSRs toward the CA: closed, open or half-open.
+	CACircuitBreaker string `json:"ca_circuit_breaker"`
+	// How many times a cached secret still valid was served because the CA failed.
+	StaleSecretsServed uint64 `json:"stale_secrets_served"`
+}
+
+// ConnectionState is the state of the secret of a proxy connection.
+type ConnectionState struct {
+	ConnectionID string    `json:"connection_id"`
+	ResourceName string    `json:"resource_name"`
+	Version      string    `json:"version"`
+	CreatedTime  time.Time `json:"created_time"`
+	ExpireTime   time.Time `json:"expire_time"`
+}
+
+// WorkloadState is the state of the secret of a workload. The token of the workload is not exposed.
+type WorkloadState struct {
+	Identity     string    `json:"identity"`
+	ResourceName string    `json:"resource_name"`
+	ExpireTime   time.Time `json:"expire_time"`
+	LastUsed     time.Time `json:"last_used"`
+	NeedsRefresh bool      `json:"needs_refresh"`
+}
+
+// State returns the state of the cache.
+func (sc *SecretCache) State() *State {
+	state := &State{
+		Connections:        []ConnectionState{},
+		Workloads:          []WorkloadState{},
+		HasRootCert:        sc.getRootCert() != nil,
+		CACircuitBreaker:   sc.breaker.state(),
+		StaleSecretsServed: atomic.LoadUint64(&sc.staleSecretServedCount),
+	}
+	sc.secrets.Range(func(k interface{}, v interface{}) bool {
+		key := k.(ConnKey)
+		e := v.(model.SecretItem)
+		state.Connections = append(state.Connections, ConnectionState{
+			ConnectionID: key.ConnectionID,
+			ResourceName: key.ResourceName,
+			Version:      e.Version,
+			CreatedTime:  e.CreatedTime,
+			ExpireTime:   e.ExpireTime,
+		})
+		return true
+	})
+	sc.workloadSecrets.Range(func(k interface{}, v interface{}) bool {
+		key := k.(workloadKey)
+		ws := v.(*workloadSecret)
+		identity, err := constructCSRHostName(sc.configOptions.TrustDomain, key.Token)
+		if err != nil {
+			identity = "unknown"
+		}
+		ws.mutex.Lock()
+		defer ws.mutex.Unlock()
+		if ws.secret == nil {
+			return true
+		}
+		state.Workloads = append(state.Workloads, WorkloadState{
+			Identity:     identity,
+			ResourceName: key.ResourceName,
+			ExpireTime:   ws.secret.ExpireTime,
+			LastUsed:     ws.lastUsed,
+			NeedsRefresh: sc.shouldRefresh(ws.secret),
+		})
+		return true
+	})
+
+	sort.Slice(state.Connections, func(i, j int) bool {
+		if state.Connections[i].ConnectionID != state.Connections[j].ConnectionID {
+			return state.Connections[i].ConnectionID < state.Connections[j].ConnectionID
+		}
+		return state.Connections[i].ResourceName < state.Connections[j].ResourceName
+	})
+	sort.Slice(state.Workloads, func(i, j int) bool {
+		if state.Workloads[i].Identity != state.Workloads[j].Identity {
+			return state.Workloads[i].Identity < state.Workloads[j].Identity
+		}
+		return state.Workloads[i].ResourceName < state.Workloads[j].ResourceName
+	})
+	return state
+}
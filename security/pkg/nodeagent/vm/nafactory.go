@@ -39,16 +39,16 @@ func NewNodeAgent(cfg *Config) (NodeAgent, error) {
 	}
 
 	pc, err := platform.NewClient(cfg.CAClientConfig.Env, cfg.CAClientConfig.RootCertFile, cfg.CAClientConfig.KeyFile,
-		cfg.CAClientConfig.CertChainFile)
+		cfg.CAClientConfig.CertChainFile, cfg.CAClientConfig.JoinTokenFile)
 	if err != nil {
 		return nil, err
 	}
 	na.pc = pc
-	dialOpts, err := pc.GetDialOptions()
-	if err != nil {
+	if _, err = pc.GetDialOptions(); err != nil {
 		return nil, err
 	}
-	grpcConn, err := protocol.NewGrpcConnection(cfg.CAClientConfig.CAAddress, dialOpts)
+	// The CSRs are sent over new connections, which present the latest certificate of the node agent.
+	grpcConn, err := protocol.NewDialingGrpcConnection(cfg.CAClientConfig.CAAddress, pc.GetDialOptions)
 	if err != nil {
 		return nil, err
 	}
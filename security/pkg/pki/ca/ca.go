@@ -15,7 +15,9 @@
 package ca
 
 import (
+	"bytes"
 	"context"
+	"crypto"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
@@ -67,6 +69,8 @@ const (
 	selfSignedCA caTypes = iota
 	// pluggedCertCA means the Istio CA uses a operator-specified key/cert.
 	pluggedCertCA
+	// externalKeyCA means the Istio CA uses an operator-specified cert, whose key is held by a signing backend.
+	externalKeyCA
 )
 
 // CertificateAuthority contains methods to be supported by a CA.
@@ -78,6 +82,46 @@ type CertificateAuthority interface {
 	GetCAKeyCertBundle() util.KeyCertBundle
 }
 
+// SigningBackend issues the certificates of a CA whose signing key is held outside of Citadel, e.g. by Vault.
+// The CA still authorizes the requests and enforces the TTLs before delegating to the backend.
+type SigningBackend interface {
+	// Sign returns the PEM-encoded certificate signed for the CSR, with the subject IDs as SANs.
+	Sign(csr *x509.CertificateRequest, subjectIDs []string, lifetime time.Duration, forCA bool) ([]byte, error)
+}
+
+// RevocationChecker checks whether identities are revoked.
+type RevocationChecker interface {
+	// IsIdentityRevoked returns whether any of the identities is revoked.
+	IsIdentityRevoked(ids []string) bool
+}
+
+// MaxCertTTLPolicy is the max TTL of the certificates of the identities starting with a prefix.
+type MaxCertTTLPolicy struct {
+	IdentityPrefix string
+	MaxTTL         time.Duration
+}
+
+// ParseMaxCertTTLPolicies parses comma separated policies of the form <identity prefix>=<max TTL>,
+// e.g. "spiffe://cluster.local/ns/payments/=1h".
+func ParseMaxCertTTLPolicies(value string) ([]MaxCertTTLPolicy, error) {
+	var policies []MaxCertTTLPolicy
+	for _, p := range strings.Split(value, ",") {
+		if p == "" {
+			continue
+		}
+		i := strings.LastIndex(p, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid max cert TTL policy %q, must be <identity prefix>=<max TTL>", p)
+		}
+		ttl, err := time.ParseDuration(p[i+1:])
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid max TTL of max cert TTL policy %q", p)
+		}
+		policies = append(policies, MaxCertTTLPolicy{IdentityPrefix: p[:i], MaxTTL: ttl})
+	}
+	return policies, nil
+}
+
 // IstioCAOptions holds the configurations for creating an Istio CA.
 // TODO(myidpt): remove IstioCAOptions.
 type IstioCAOptions struct {
@@ -85,8 +129,15 @@ type IstioCAOptions struct {
 
 	CertTTL    time.Duration
 	MaxCertTTL time.Duration
+	// The max TTLs of the certificates of some identities, to which longer TTLs are shortened.
+	MaxCertTTLPolicies []MaxCertTTLPolicy
+
+	// The revoked identities, for which the CA does not sign. No identity is revoked if nil.
+	Revocations RevocationChecker
 
 	KeyCertBundle util.KeyCertBundle
+	// The backend issuing the certificates. The certificates are signed with the key of KeyCertBundle if nil.
+	Backend SigningBackend
 
 	LivenessProbeOptions *probe.Options
 	ProbeCheckInterval   time.Duration
@@ -94,10 +145,13 @@ type IstioCAOptions struct {
 
 // IstioCA generates keys and certificates for Istio identities.
 type IstioCA struct {
-	certTTL    time.Duration
-	maxCertTTL time.Duration
+	certTTL            time.Duration
+	maxCertTTL         time.Duration
+	maxCertTTLPolicies []MaxCertTTLPolicy
+	revocations        RevocationChecker
 
 	keyCertBundle util.KeyCertBundle
+	backend       SigningBackend
 
 	livenessProbe *probe.Probe
 }
@@ -128,7 +182,7 @@ func appendRootCerts(pemCert []byte, rootCertFile string) ([]byte, error) {
 
 // NewSelfSignedIstioCAOptions returns a new IstioCAOptions instance using self-signed certificate.
 func NewSelfSignedIstioCAOptions(ctx context.Context, caCertTTL, certTTL, maxCertTTL time.Duration, org string, dualUse bool,
-	namespace string, readCertRetryInterval time.Duration, client corev1.CoreV1Interface, rootCertFile string) (caOpts *IstioCAOptions, err error) {
+	keyAlgorithm util.KeyAlgorithm, namespace string, readCertRetryInterval time.Duration, client corev1.CoreV1Interface, rootCertFile string) (caOpts *IstioCAOptions, err error) {
 	// For the first time the CA is up, if readSigningCertOnly is unset,
 	// it generates a self-signed key/cert pair and write it to CASecret.
 	// For subsequent restart, CA will reads key/cert from CASecret.
@@ -158,14 +212,7 @@ func NewSelfSignedIstioCAOptions(ctx context.Context, caCertTTL, certTTL, maxCer
 	if scrtErr != nil {
 		log.Infof("Failed to get secret (error: %s), will create one", scrtErr)
 
-		options := util.CertOptions{
-			TTL:          caCertTTL,
-			Org:          org,
-			IsCA:         true,
-			IsSelfSigned: true,
-			RSAKeySize:   caKeySize,
-			IsDualUse:    dualUse,
-		}
+		options := selfSignedRootCertOptions(caCertTTL, org, dualUse, keyAlgorithm)
 		pemCert, pemKey, ckErr := util.GenCertKeyFromOptions(options)
 		if ckErr != nil {
 			return nil, fmt.Errorf("unable to generate CA cert and key for self-signed CA (%v)", ckErr)
@@ -187,9 +234,9 @@ func NewSelfSignedIstioCAOptions(ctx context.Context, caCertTTL, certTTL, maxCer
 		}
 	} else {
 		log.Infof("Load signing key and cert from existing secret %s:%s", caSecret.Namespace, caSecret.Name)
-		rootCerts, err := appendRootCerts(caSecret.Data[caCertID], rootCertFile)
+		rootCerts, err := rootCertsFromSecret(caSecret, rootCertFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to append root certificates (%v)", err)
+			return nil, err
 		}
 		if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromPem(caSecret.Data[caCertID],
 			caSecret.Data[caPrivateKeyID], nil, rootCerts); err != nil {
@@ -203,6 +250,20 @@ func NewSelfSignedIstioCAOptions(ctx context.Context, caCertTTL, certTTL, maxCer
 	return caOpts, nil
 }
 
+// selfSignedRootCertOptions returns the options to generate the key/cert of a self-signed CA.
+func selfSignedRootCertOptions(caCertTTL time.Duration, org string, dualUse bool,
+	keyAlgorithm util.KeyAlgorithm) util.CertOptions {
+	return util.CertOptions{
+		TTL:          caCertTTL,
+		Org:          org,
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   caKeySize,
+		KeyAlgorithm: keyAlgorithm,
+		IsDualUse:    dualUse,
+	}
+}
+
 // NewPluggedCertIstioCAOptions returns a new IstioCAOptions instance using given certificate.
 func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile, rootCertFile string,
 	certTTL, maxCertTTL time.Duration, namespace string, client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
@@ -215,43 +276,82 @@ func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile
 		signingCertFile, signingKeyFile, certChainFile, rootCertFile); err != nil {
 		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
 	}
+	if err = publishPluggedCert(caOpts.KeyCertBundle, namespace, client); err != nil {
+		return nil, err
+	}
+	return caOpts, nil
+}
 
-	// Validate that the passed in signing cert can be used as CA.
-	// The check can't be done inside `KeyCertBundle`, since bundle could also be used to
-	// validate workload certificates (i.e., where the leaf certificate is not a CA).
-	b, err := ioutil.ReadFile(signingCertFile)
+// NewExternalKeyIstioCAOptions returns a new IstioCAOptions instance using given certificate, whose key is
+// held outside of Citadel. Either signer signs the certificates built by the CA, e.g. in a KMS or an HSM,
+// or backend issues the certificates, e.g. Vault.
+func NewExternalKeyIstioCAOptions(certChainFile, signingCertFile, rootCertFile string, signer crypto.Signer,
+	backend SigningBackend, certTTL, maxCertTTL time.Duration, namespace string,
+	client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
+	if (signer == nil) == (backend == nil) {
+		return nil, fmt.Errorf("exactly one of a signer or a signing backend must be set")
+	}
+	caOpts = &IstioCAOptions{
+		CAType:     externalKeyCA,
+		CertTTL:    certTTL,
+		MaxCertTTL: maxCertTTL,
+		Backend:    backend,
+	}
+	certBytes, err := ioutil.ReadFile(signingCertFile)
 	if err != nil {
 		return nil, err
 	}
-	block, _ := pem.Decode(b)
-	if block == nil {
-		return nil, fmt.Errorf("invalid PEM encoded certificate")
+	var certChainBytes []byte
+	if len(certChainFile) != 0 {
+		if certChainBytes, err = ioutil.ReadFile(certChainFile); err != nil {
+			return nil, err
+		}
 	}
-	cert, err := x509.ParseCertificate(block.Bytes)
+	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse X.509 certificate")
+		return nil, err
+	}
+	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleWithSigner(
+		certBytes, signer, certChainBytes, rootCertBytes); err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+	if err = publishPluggedCert(caOpts.KeyCertBundle, namespace, client); err != nil {
+		return nil, err
 	}
+	return caOpts, nil
+}
+
+// publishPluggedCert validates that the signing cert of the bundle can be used as CA, and writes the
+// certificate the node agents trust to the configmap.
+func publishPluggedCert(bundle util.KeyCertBundle, namespace string, client corev1.CoreV1Interface) error {
+	// Validate that the passed in signing cert can be used as CA.
+	// The check can't be done inside `KeyCertBundle`, since bundle could also be used to
+	// validate workload certificates (i.e., where the leaf certificate is not a CA).
+	cert, _, _, _ := bundle.GetAll()
 	if !cert.IsCA {
-		return nil, fmt.Errorf("certificate is not authorized to sign other certificates")
+		return fmt.Errorf("certificate is not authorized to sign other certificates")
 	}
 
-	crt := caOpts.KeyCertBundle.GetCertChainPem()
+	crt := bundle.GetCertChainPem()
 	if len(crt) == 0 {
-		crt = caOpts.KeyCertBundle.GetRootCertPem()
+		crt = bundle.GetRootCertPem()
 	}
-	if err = updateCertInConfigmap(namespace, client, crt); err != nil {
+	if err := updateCertInConfigmap(namespace, client, crt); err != nil {
 		log.Errorf("Failed to write Citadel cert to configmap (%v). Node agents will not be able to connect.", err)
 	}
-	return caOpts, nil
+	return nil
 }
 
 // NewIstioCA returns a new IstioCA instance.
 func NewIstioCA(opts *IstioCAOptions) (*IstioCA, error) {
 	ca := &IstioCA{
-		certTTL:       opts.CertTTL,
-		maxCertTTL:    opts.MaxCertTTL,
-		keyCertBundle: opts.KeyCertBundle,
-		livenessProbe: probe.NewProbe(),
+		certTTL:            opts.CertTTL,
+		maxCertTTL:         opts.MaxCertTTL,
+		maxCertTTLPolicies: opts.MaxCertTTLPolicies,
+		revocations:        opts.Revocations,
+		keyCertBundle:      opts.KeyCertBundle,
+		backend:            opts.Backend,
+		livenessProbe:      probe.NewProbe(),
 	}
 
 	return ca, nil
@@ -271,6 +371,10 @@ func (ca *IstioCA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 		return nil, NewError(CSRError, err)
 	}
 
+	if ca.revocations != nil && ca.revocations.IsIdentityRevoked(subjectIDs) {
+		return nil, NewError(RevokedError, fmt.Errorf("identities %v are revoked", subjectIDs))
+	}
+
 	lifetime := requestedLifetime
 	// If the requested requestedLifetime is non-positive, apply the default TTL.
 	if requestedLifetime.Seconds() <= 0 {
@@ -281,6 +385,22 @@ func (ca *IstioCA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 		return nil, NewError(TTLError, fmt.Errorf(
 			"requested TTL %s is greater than the max allowed TTL %s", requestedLifetime, ca.maxCertTTL))
 	}
+	// The TTL is shortened to the max TTL of the policies of the identities, so that the secret controller
+	// and the node agents, which request the same TTL for all workloads, still get short-lived certificates.
+	if maxTTL, found := ca.policyMaxCertTTL(subjectIDs); found && lifetime > maxTTL {
+		lifetime = maxTTL
+	}
+
+	if ca.backend != nil {
+		cert, err := ca.backend.Sign(csr, subjectIDs, lifetime, forCA)
+		if err != nil {
+			return nil, NewError(CertGenError, err)
+		}
+		if err = verifyBackendCert(cert, csr, signingCert); err != nil {
+			return nil, NewError(CertGenError, err)
+		}
+		return cert, nil
+	}
 
 	certBytes, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, subjectIDs, lifetime, forCA)
 	if err != nil {
@@ -296,6 +416,38 @@ func (ca *IstioCA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 	return cert, nil
 }
 
+// verifyBackendCert verifies that the certificate issued by a signing backend is for the CSR, and signed
+// by the signing certificate of the CA, which is returned as the chain of the certificate and checked by
+// the secret controller. A backend issuing with another CA, e.g. after a Vault reconfiguration, is rejected.
+func verifyBackendCert(certPEM []byte, csr *x509.CertificateRequest, signingCert *x509.Certificate) error {
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		return fmt.Errorf("invalid certificate from the signing backend: %v", err)
+	}
+	if err = cert.CheckSignatureFrom(signingCert); err != nil {
+		return fmt.Errorf("the certificate from the signing backend is not signed by the signing certificate "+
+			"%q: %v", signingCert.Subject, err)
+	}
+	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, csr.RawSubjectPublicKeyInfo) {
+		return fmt.Errorf("the certificate from the signing backend is not for the public key of the CSR")
+	}
+	return nil
+}
+
+// policyMaxCertTTL returns the lowest max TTL of the policies of the identities, if any.
+func (ca *IstioCA) policyMaxCertTTL(subjectIDs []string) (time.Duration, bool) {
+	var maxTTL time.Duration
+	found := false
+	for _, p := range ca.maxCertTTLPolicies {
+		for _, id := range subjectIDs {
+			if strings.HasPrefix(id, p.IdentityPrefix) && (!found || p.MaxTTL < maxTTL) {
+				maxTTL, found = p.MaxTTL, true
+			}
+		}
+	}
+	return maxTTL, found
+}
+
 // GetCAKeyCertBundle returns the KeyCertBundle for the CA.
 func (ca *IstioCA) GetCAKeyCertBundle() util.KeyCertBundle {
 	return ca.keyCertBundle
@@ -17,8 +17,10 @@ package ca
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"io/ioutil"
 	"reflect"
 	"testing"
@@ -95,7 +97,7 @@ func TestCreateSelfSignedIstioCAWithoutSecret(t *testing.T) {
 	rootCertFile := ""
 
 	caopts, err := NewSelfSignedIstioCAOptions(context.Background(), caCertTTL, defaultCertTTL, maxCertTTL,
-		org, false, caNamespace, -1, client.CoreV1(), rootCertFile)
+		org, false, util.RSAKey, caNamespace, -1, client.CoreV1(), rootCertFile)
 	if err != nil {
 		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
 	}
@@ -161,6 +163,47 @@ func TestCreateSelfSignedIstioCAWithoutSecret(t *testing.T) {
 	}
 }
 
+func TestCreateSelfSignedIstioCAWithECDSAKey(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(), time.Hour, 30*time.Minute, time.Hour,
+		"test.ca.org", false, util.ECDSAP384Key, "default", -1, client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating self-signed CA: %v", err)
+	}
+
+	_, signingKey, _, rootCertBytes := ca.GetCAKeyCertBundle().GetAll()
+	if algorithm, _, err := util.GetKeyAlgorithm(*signingKey); err != nil || algorithm != util.ECDSAP384Key {
+		t.Errorf("Unexpected CA key algorithm %s (%v), expecting %s", algorithm, err, util.ECDSAP384Key)
+	}
+
+	// The ECDSA root signs both ECDSA and RSA workload keys.
+	for _, opts := range []util.CertOptions{
+		{Host: "spiffe://test.com/ns/foo/sa/bar", KeyAlgorithm: util.ECDSAP256Key},
+		{Host: "spiffe://test.com/ns/foo/sa/bar", RSAKeySize: 1024},
+	} {
+		csrPEM, keyPEM, err := util.GenCSR(opts)
+		if err != nil {
+			t.Fatalf("Failed to generate a CSR: %v", err)
+		}
+		certPEM, err := ca.Sign(csrPEM, []string{opts.Host}, 10*time.Minute, false)
+		if err != nil {
+			t.Fatalf("Failed to sign a CSR: %v", err)
+		}
+		fields := &util.VerifyFields{
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			Host:        opts.Host,
+		}
+		if err := util.VerifyCertificate(keyPEM, certPEM, rootCertBytes, fields); err != nil {
+			t.Errorf("Failed to verify the certificate of a %q key: %v", opts.KeyAlgorithm, err)
+		}
+	}
+}
+
 func TestCreateSelfSignedIstioCAWithSecret(t *testing.T) {
 	rootCertPem := cert1Pem
 	// Use the same signing cert and root cert for self-signed CA.
@@ -182,7 +225,7 @@ func TestCreateSelfSignedIstioCAWithSecret(t *testing.T) {
 	const rootCertFile = ""
 
 	caopts, err := NewSelfSignedIstioCAOptions(context.Background(), caCertTTL, certTTL, maxCertTTL,
-		org, false, caNamespace, -1, client.CoreV1(), rootCertFile)
+		org, false, util.RSAKey, caNamespace, -1, client.CoreV1(), rootCertFile)
 	if err != nil {
 		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
 	}
@@ -250,7 +293,7 @@ func TestCreateSelfSignedIstioCAReadSigningCertOnly(t *testing.T) {
 	ctx0, cancel0 := context.WithTimeout(context.Background(), time.Millisecond*50)
 	defer cancel0()
 	_, err := NewSelfSignedIstioCAOptions(ctx0, caCertTTL, certTTL, maxCertTTL,
-		org, false, caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile)
+		org, false, util.RSAKey, caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile)
 	if err == nil {
 		t.Errorf("Expected error, but succeeded.")
 	} else if err.Error() != expectedErr {
@@ -268,7 +311,7 @@ func TestCreateSelfSignedIstioCAReadSigningCertOnly(t *testing.T) {
 	ctx1, cancel1 := context.WithCancel(context.Background())
 	defer cancel1()
 	caopts, err := NewSelfSignedIstioCAOptions(ctx1, caCertTTL, certTTL, maxCertTTL,
-		org, false, caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile)
+		org, false, util.RSAKey, caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -438,7 +481,7 @@ func TestSignCSRForCA(t *testing.T) {
 	}
 
 	fields := &util.VerifyFields{
-		KeyUsage: x509.KeyUsageCertSign,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		IsCA:     true,
 		Host:     subjectID,
 	}
@@ -497,6 +540,144 @@ func TestSignCSRTTLError(t *testing.T) {
 	}
 }
 
+type fakeRevocations struct {
+	revoked string
+}
+
+func (r *fakeRevocations) IsIdentityRevoked(ids []string) bool {
+	for _, id := range ids {
+		if id == r.revoked {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSignCSRForRevokedIdentity(t *testing.T) {
+	subjectID := "spiffe://example.com/ns/foo/sa/bar"
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := createCA(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.revocations = &fakeRevocations{revoked: subjectID}
+
+	cert, signErr := ca.Sign(csrPEM, []string{subjectID}, time.Hour, false)
+	if cert != nil {
+		t.Errorf("Expected null cert be obtained a non-null cert.")
+	}
+	if signErr == nil || signErr.(*Error).ErrorType() != "REVOKED_ERROR" {
+		t.Errorf("Expected a REVOKED_ERROR but got %v", signErr)
+	}
+	if _, signErr = ca.Sign(csrPEM, []string{"spiffe://example.com/ns/foo/sa/baz"}, time.Hour, false); signErr != nil {
+		t.Errorf("Unexpected error signing for an identity not revoked: %v", signErr)
+	}
+}
+
+func TestSignCSRWithMaxCertTTLPolicies(t *testing.T) {
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := createCA(2 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.maxCertTTLPolicies, err = ParseMaxCertTTLPolicies(
+		"spiffe://example.com/ns/payments/=30m,spiffe://example.com/ns/payments/sa/ledger=10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		subjectID string
+		ttl       time.Duration
+		expected  time.Duration
+	}{
+		"No policy": {
+			subjectID: "spiffe://example.com/ns/foo/sa/bar",
+			ttl:       time.Hour,
+			expected:  time.Hour,
+		},
+		"Shortened by policy": {
+			subjectID: "spiffe://example.com/ns/payments/sa/bar",
+			ttl:       time.Hour,
+			expected:  30 * time.Minute,
+		},
+		"Default TTL shortened by policy": {
+			subjectID: "spiffe://example.com/ns/payments/sa/bar",
+			expected:  30 * time.Minute,
+		},
+		"Within policy": {
+			subjectID: "spiffe://example.com/ns/payments/sa/bar",
+			ttl:       20 * time.Minute,
+			expected:  20 * time.Minute,
+		},
+		"Most restrictive policy": {
+			subjectID: "spiffe://example.com/ns/payments/sa/ledger",
+			ttl:       time.Hour,
+			expected:  10 * time.Minute,
+		},
+	}
+	for id, tc := range testCases {
+		certPEM, signErr := ca.Sign(csrPEM, []string{tc.subjectID}, tc.ttl, false)
+		if signErr != nil {
+			t.Errorf("%s: unexpected error: %v", id, signErr)
+			continue
+		}
+		cert, err := util.ParsePemEncodedCertificate(certPEM)
+		if err != nil {
+			t.Errorf("%s: failed to parse the cert: %v", id, err)
+			continue
+		}
+		if ttl := cert.NotAfter.Sub(cert.NotBefore); ttl != tc.expected {
+			t.Errorf("%s: got TTL %v, want %v", id, ttl, tc.expected)
+		}
+	}
+}
+
+func TestParseMaxCertTTLPolicies(t *testing.T) {
+	testCases := map[string]struct {
+		value    string
+		expected []MaxCertTTLPolicy
+		errMsg   string
+	}{
+		"Empty": {},
+		"Policies": {
+			value: "spiffe://cluster.local/ns/a/=1h,spiffe://cluster.local/ns/b/sa/c=10m",
+			expected: []MaxCertTTLPolicy{
+				{IdentityPrefix: "spiffe://cluster.local/ns/a/", MaxTTL: time.Hour},
+				{IdentityPrefix: "spiffe://cluster.local/ns/b/sa/c", MaxTTL: 10 * time.Minute},
+			},
+		},
+		"No TTL": {
+			value:  "spiffe://cluster.local/ns/a/",
+			errMsg: `invalid max cert TTL policy "spiffe://cluster.local/ns/a/", must be <identity prefix>=<max TTL>`,
+		},
+		"Invalid TTL": {
+			value:  "spiffe://cluster.local/ns/a/=-1h",
+			errMsg: `invalid max TTL of max cert TTL policy "spiffe://cluster.local/ns/a/=-1h"`,
+		},
+	}
+	for id, tc := range testCases {
+		policies, err := ParseMaxCertTTLPolicies(tc.value)
+		if tc.errMsg != "" {
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("%s: got error %v, want %s", id, err, tc.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		} else if !reflect.DeepEqual(policies, tc.expected) {
+			t.Errorf("%s: got %v, want %v", id, policies, tc.expected)
+		}
+	}
+}
+
 func TestAppendRootCerts(t *testing.T) {
 	root1 := "root-cert-1"
 	expRootCerts := `root-cert-1
@@ -587,3 +768,81 @@ func comparePem(expectedBytes []byte, file string) bool {
 	}
 	return true
 }
+
+// fakeBackend signs the CSRs with a CA, or for another public key if set.
+type fakeBackend struct {
+	cert     *x509.Certificate
+	key      crypto.PrivateKey
+	otherKey crypto.PublicKey
+}
+
+func (b *fakeBackend) Sign(csr *x509.CertificateRequest, subjectIDs []string, lifetime time.Duration,
+	forCA bool) ([]byte, error) {
+	pub := csr.PublicKey
+	if b.otherKey != nil {
+		pub = b.otherKey
+	}
+	der, err := util.GenCertFromCSR(csr, b.cert, pub, b.key, subjectIDs, lifetime, forCA)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func TestSignCSRWithBackend(t *testing.T) {
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := createCA(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingCert, signingKey, _, _ := ca.GetCAKeyCertBundle().GetAll()
+
+	otherCertPem, otherKeyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+		IsCA:         true,
+		IsSelfSigned: true,
+		TTL:          time.Hour,
+		Org:          "Other CA",
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCert, err := util.ParsePemEncodedCertificate(otherCertPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := util.ParsePemEncodedKey(otherKeyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		backend *fakeBackend
+		wantErr bool
+	}{
+		{name: "signed by the signing cert", backend: &fakeBackend{cert: signingCert, key: *signingKey}},
+		{name: "signed by another CA", backend: &fakeBackend{cert: otherCert, key: otherKey}, wantErr: true},
+		{
+			name:    "another public key",
+			backend: &fakeBackend{cert: signingCert, key: *signingKey, otherKey: otherCert.PublicKey},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		ca.backend = c.backend
+		cert, signErr := ca.Sign(csrPEM, []string{"spiffe://example.com/ns/foo/sa/bar"}, time.Hour, false)
+		if c.wantErr {
+			if cert != nil || signErr == nil || signErr.(*Error).ErrorType() != "CERT_GEN_ERROR" {
+				t.Errorf("%s: expected a CERT_GEN_ERROR but got %v", c.name, signErr)
+			}
+			continue
+		}
+		if signErr != nil {
+			t.Errorf("%s: unexpected error %v", c.name, signErr)
+		}
+	}
+}
@@ -28,6 +28,8 @@ const (
 	TTLError
 	// CertGenError means an error happened during the certificate generation.
 	CertGenError
+	// RevokedError means the identity is revoked.
+	RevokedError
 )
 
 // Error encapsulates the short and long errors.
@@ -52,6 +54,8 @@ func (e Error) ErrorType() string {
 		return "TTL_ERROR"
 	case CertGenError:
 		return "CERT_GEN_ERROR"
+	case RevokedError:
+		return "REVOKED_ERROR"
 	}
 	return "UNKNOWN"
 }
@@ -67,6 +71,8 @@ func (e Error) HTTPErrorCode() codes.Code {
 		return codes.InvalidArgument
 	case TTLError:
 		return codes.InvalidArgument
+	case RevokedError:
+		return codes.PermissionDenied
 	}
 	return codes.Internal
 }
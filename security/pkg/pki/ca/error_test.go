@@ -52,6 +52,12 @@ func TestError(t *testing.T) {
 			message: "CERT_GEN_ERROR",
 			code:    codes.Internal,
 		},
+		"REVOKED_ERROR": {
+			eType:   RevokedError,
+			err:     fmt.Errorf("test error6"),
+			message: "REVOKED_ERROR",
+			code:    codes.PermissionDenied,
+		},
 		"UNKNOWN": {
 			eType:   -1,
 			err:     fmt.Errorf("test error5"),
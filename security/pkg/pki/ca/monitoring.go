@@ -0,0 +1,82 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	phaseLabelName = "phase"
+)
+
+var (
+	rootRotationPhases = []RootRotationPhase{
+		RootRotationIdle, RootRotationRequested, RootRotationPublishBundle, RootRotationSignWithNew,
+	}
+
+	rootRotationPhaseGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "citadel",
+		Subsystem: "root_rotation",
+		Name:      "phase",
+		Help:      "Whether the root rotation is in the phase, 1 for the current phase and 0 for the others.",
+	}, []string{phaseLabelName})
+
+	rootRotationTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "citadel",
+		Subsystem: "root_rotation",
+		Name:      "transition_count",
+		Help:      "The number of times the root rotation moved to the phase.",
+	}, []string{phaseLabelName})
+
+	rootRotationUpToDateWorkloads = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "citadel",
+		Subsystem: "root_rotation",
+		Name:      "up_to_date_workloads",
+		Help:      "The number of workloads holding the current root certificates and signing certificate.",
+	})
+
+	rootRotationWorkloads = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "citadel",
+		Subsystem: "root_rotation",
+		Name:      "workloads",
+		Help:      "The number of workloads tracked by the root rotation.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(rootRotationPhaseGauge)
+	prometheus.MustRegister(rootRotationTransitions)
+	prometheus.MustRegister(rootRotationUpToDateWorkloads)
+	prometheus.MustRegister(rootRotationWorkloads)
+}
+
+func phaseLabel(phase RootRotationPhase) prometheus.Labels {
+	if phase == RootRotationIdle {
+		return prometheus.Labels{phaseLabelName: "Idle"}
+	}
+	return prometheus.Labels{phaseLabelName: string(phase)}
+}
+
+// setRootRotationPhase sets the phase gauge to 1 for the current phase and 0 for the others.
+func setRootRotationPhase(current RootRotationPhase) {
+	for _, phase := range rootRotationPhases {
+		value := 0.0
+		if phase == current {
+			value = 1
+		}
+		rootRotationPhaseGauge.With(phaseLabel(phase)).Set(value)
+	}
+}
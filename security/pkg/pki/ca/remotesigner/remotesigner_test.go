@@ -0,0 +1,298 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotesigner
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	testCases := map[string]util.KeyAlgorithm{
+		"RSA":   util.RSAKey,
+		"ECDSA": util.ECDSAP256Key,
+	}
+	for name, keyAlgorithm := range testCases {
+		t.Run(name, func(t *testing.T) {
+			caCert, caKey, err := util.GenCertKeyFromOptions(util.CertOptions{
+				TTL:          time.Hour,
+				Org:          "remote.ca.org",
+				IsCA:         true,
+				IsSelfSigned: true,
+				RSAKeySize:   2048,
+				KeyAlgorithm: keyAlgorithm,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir, err := ioutil.TempDir("", "remotesigner")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint: errcheck
+			caCertPath := filepath.Join(dir, "ca-cert.pem")
+			caKeyPath := filepath.Join(dir, "ca-key.pem")
+			for path, data := range map[string][]byte{caCertPath: caCert, caKeyPath: caKey} {
+				if err := ioutil.WriteFile(path, data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tlsRoot := newTestTLSRoot(t)
+			addr, stop := startTestServer(t, caKeyPath, tlsRoot)
+			defer stop()
+
+			signer, err := NewSigner(addr, tlsRoot.certPem, tlsRoot.issue(t, citadelIdentity, false))
+			if err != nil {
+				t.Fatalf("Failed to create the remote signer: %v", err)
+			}
+			defer signer.Close() // nolint: errcheck
+
+			caopts, err := ca.NewExternalKeyIstioCAOptions("", caCertPath, caCertPath, signer, nil, time.Hour,
+				2*time.Hour, "istio-system", fake.NewSimpleClientset().CoreV1())
+			if err != nil {
+				t.Fatalf("Failed to create the CA options: %v", err)
+			}
+			istioCA, err := ca.NewIstioCA(caopts)
+			if err != nil {
+				t.Fatalf("Failed to create the CA: %v", err)
+			}
+
+			csr, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", RSAKeySize: 2048})
+			if err != nil {
+				t.Fatal(err)
+			}
+			certPem, err := istioCA.Sign(csr, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, 30*time.Minute, false)
+			if err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+			cert, err := util.ParsePemEncodedCertificate(certPem)
+			if err != nil {
+				t.Fatalf("Failed to parse the cert: %v", err)
+			}
+			signingCert, _, _, _ := istioCA.GetCAKeyCertBundle().GetAll()
+			if err := cert.CheckSignatureFrom(signingCert); err != nil {
+				t.Errorf("The cert is not signed by the remote key: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewExternalKeyIstioCAOptionsKeyMismatch(t *testing.T) {
+	caCert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "remote.ca.org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "other.ca.org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "remotesigner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	caCertPath := filepath.Join(dir, "ca-cert.pem")
+	otherKeyPath := filepath.Join(dir, "other-key.pem")
+	for path, data := range map[string][]byte{caCertPath: caCert, otherKeyPath: otherKey} {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, err := NewServerFromFile(otherKeyPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.NewExternalKeyIstioCAOptions("", caCertPath, caCertPath, server.signer, nil, time.Hour,
+		2*time.Hour, "istio-system", fake.NewSimpleClientset().CoreV1()); err == nil {
+		t.Errorf("Expected an error for a signing cert not matching the key of the signer")
+	}
+}
+
+const citadelIdentity = "spiffe://cluster.local/ns/istio-system/sa/istio-citadel-service-account"
+
+func TestRemoteSignerAuthentication(t *testing.T) {
+	_, caKey, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "remote.ca.org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "remotesigner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	caKeyPath := filepath.Join(dir, "ca-key.pem")
+	if err := ioutil.WriteFile(caKeyPath, caKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tlsRoot := newTestTLSRoot(t)
+	addr, stop := startTestServer(t, caKeyPath, tlsRoot)
+	defer stop()
+	digest := sha256.Sum256([]byte("digest"))
+
+	// The signer refuses to connect without mutual TLS.
+	if _, err := NewSigner(addr, nil, tlsRoot.issue(t, citadelIdentity, false)); err == nil {
+		t.Error("NewSigner without root certificates: expected an error")
+	}
+	if _, err := NewSigner(addr, tlsRoot.certPem, tls.Certificate{}); err == nil {
+		t.Error("NewSigner without client certificate: expected an error")
+	}
+
+	// The server rejects the clients whose certificates are not verified by its client roots.
+	if _, err := NewSigner(addr, tlsRoot.certPem, newTestTLSRoot(t).issue(t, citadelIdentity, false)); err == nil {
+		t.Error("NewSigner with an untrusted client certificate: expected an error")
+	}
+
+	cases := []struct {
+		name      string
+		identity  string
+		wantError string
+	}{
+		{
+			name:     "citadel",
+			identity: citadelIdentity,
+		},
+		{
+			name:      "other identity",
+			identity:  "spiffe://cluster.local/ns/default/sa/sleep",
+			wantError: "PermissionDenied",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			signer, err := NewSigner(addr, tlsRoot.certPem, tlsRoot.issue(t, c.identity, false))
+			if err != nil {
+				t.Fatalf("Failed to create the remote signer: %v", err)
+			}
+			defer signer.Close() // nolint: errcheck
+			_, err = signer.Sign(nil, digest[:], crypto.SHA256)
+			if c.wantError == "" && err != nil {
+				t.Errorf("Sign: unexpected error %v", err)
+			}
+			if c.wantError != "" && (err == nil || !strings.Contains(err.Error(), c.wantError)) {
+				t.Errorf("Sign: expected an error containing %q, got %v", c.wantError, err)
+			}
+		})
+	}
+}
+
+// testTLSRoot is a CA issuing the TLS certificates of the remote signer and its clients.
+type testTLSRoot struct {
+	certPem []byte
+	cert    *x509.Certificate
+	key     crypto.PrivateKey
+}
+
+func newTestTLSRoot(t *testing.T) *testTLSRoot {
+	t.Helper()
+	certPem, keyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "remote.signer.org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := util.ParsePemEncodedKey(keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testTLSRoot{certPem: certPem, cert: cert, key: key}
+}
+
+// issue returns a TLS certificate of host issued by the root.
+func (r *testTLSRoot) issue(t *testing.T, host string, isServer bool) tls.Certificate {
+	t.Helper()
+	certPem, keyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         host,
+		TTL:          time.Hour,
+		SignerCert:   r.cert,
+		SignerPriv:   r.key,
+		IsClient:     !isServer,
+		IsServer:     isServer,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// startTestServer serves the remote signer of the key of keyPath over mutual TLS with certificates of tlsRoot,
+// for Citadel only, and returns its address and the function stopping it.
+func startTestServer(t *testing.T, keyPath string, tlsRoot *testTLSRoot) (string, func()) {
+	t.Helper()
+	server, err := NewServerFromFile(keyPath, []string{citadelIdentity})
+	if err != nil {
+		t.Fatalf("Failed to create the remote signer server: %v", err)
+	}
+	creds, err := NewServerCredentials(tlsRoot.issue(t, "localhost,127.0.0.1", true), tlsRoot.certPem)
+	if err != nil {
+		t.Fatalf("Failed to create the server credentials: %v", err)
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(grpc.Creds(creds))
+	RegisterRemoteSignerServer(grpcServer, server)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	return lis.Addr().String(), grpcServer.Stop
+}
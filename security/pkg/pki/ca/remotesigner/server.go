@@ -0,0 +1,134 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotesigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// Server serves the RemoteSigner service with a local key. It stands in for a KMS or an HSM, e.g. in tests.
+type Server struct {
+	signer crypto.Signer
+	// The identities of the clients allowed to sign, i.e. of Citadel.
+	identities map[string]bool
+}
+
+// NewServer returns a server signing with signer for the clients authenticated as one of identities.
+func NewServer(signer crypto.Signer, identities []string) *Server {
+	s := &Server{
+		signer:     signer,
+		identities: make(map[string]bool, len(identities)),
+	}
+	for _, id := range identities {
+		s.identities[id] = true
+	}
+	return s
+}
+
+// NewServerFromFile returns a server signing with the PEM-encoded private key of keyFile for the clients
+// authenticated as one of identities.
+func NewServerFromFile(keyFile string, identities []string) (*Server, error) {
+	keyPem, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := util.ParsePemEncodedKey(keyPem)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the key of %s can't sign", keyFile)
+	}
+	return NewServer(signer, identities), nil
+}
+
+// NewServerCredentials returns the transport credentials of the server, serving tlsCert over mutual TLS and
+// requiring the client certificates to be verified by clientRootCert.
+func NewServerCredentials(tlsCert tls.Certificate, clientRootCert []byte) (credentials.TransportCredentials, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(clientRootCert) {
+		return nil, fmt.Errorf("failed to append the client root certificates to the certificate pool")
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{tlsCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}), nil
+}
+
+// GetPublicKey returns the public key of the local key.
+func (s *Server) GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKeyResponse, error) {
+	der, err := x509.MarshalPKIXPublicKey(s.signer.Public())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal the public key: %v", err)
+	}
+	return &PublicKeyResponse{PublicKey: der}, nil
+}
+
+// Sign signs the digest with the local key, if the client is authenticated as one of the allowed identities.
+func (s *Server) Sign(ctx context.Context, request *SignRequest) (*SignResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	hash := crypto.Hash(request.Hash)
+	if !hash.Available() || len(request.Digest) != hash.Size() {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid digest of hash %d", request.Hash)
+	}
+	signature, err := s.signer.Sign(rand.Reader, request.Digest, hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to sign: %v", err)
+	}
+	return &SignResponse{Signature: signature}, nil
+}
+
+// authorize returns an error unless the verified client certificate has one of the allowed identities.
+func (s *Server) authorize(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "no client certificate is presented")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "the client is not authenticated over TLS")
+	}
+	chains := tlsInfo.State.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return status.Error(codes.Unauthenticated, "no verified client certificate is presented")
+	}
+	ids, err := util.ExtractIDs(chains[0][0].Extensions)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "failed to extract the client identities: %v", err)
+	}
+	for _, id := range ids {
+		if s.identities[id] {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "the client identities %v are not allowed to sign", ids)
+}
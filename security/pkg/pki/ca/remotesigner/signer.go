@@ -0,0 +1,107 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotesigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/pkg/log"
+)
+
+// signTimeout is the timeout of the calls to the remote signer.
+const signTimeout = 10 * time.Second
+
+// Signer is the crypto.Signer of a key held by a remote signer.
+type Signer struct {
+	conn   *grpc.ClientConn
+	client RemoteSignerClient
+	public crypto.PublicKey
+}
+
+// NewSigner connects to the remote signer at address over mutual TLS, verifying the remote signer with
+// tlsRootCert and authenticating with tlsCert, and fetches its public key.
+func NewSigner(address string, tlsRootCert []byte, tlsCert tls.Certificate) (*Signer, error) {
+	if len(tlsRootCert) == 0 {
+		return nil, fmt.Errorf("the root certificates verifying the remote signer are required")
+	}
+	if len(tlsCert.Certificate) == 0 {
+		return nil, fmt.Errorf("the client certificate authenticating to the remote signer is required")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(tlsRootCert) {
+		return nil, fmt.Errorf("failed to append the remote signer TLS root certificates to the certificate pool")
+	}
+	creds := credentials.NewTLS(&tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{tlsCert},
+	})
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial the remote signer at %s: %v", address, err)
+	}
+	s := &Signer{
+		conn:   conn,
+		client: NewRemoteSignerClient(conn),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	resp, err := s.client.GetPublicKey(ctx, &PublicKeyRequest{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to get the public key of the remote signer at %s: %v", address, err)
+	}
+	if s.public, err = x509.ParsePKIXPublicKey(resp.PublicKey); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to parse the public key of the remote signer at %s: %v", address, err)
+	}
+	log.Infof("connected to the remote signer at %s", address)
+	return s, nil
+}
+
+// Public returns the public key of the remote key.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs the digest with the remote key. Only PKCS #1 v1.5 RSA signatures and ECDSA signatures,
+// which are the ones of the certificates, are supported.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, fmt.Errorf("RSA-PSS signatures are not supported by the remote signer")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	resp, err := s.client.Sign(ctx, &SignRequest{Digest: digest, Hash: uint32(opts.HashFunc())})
+	if err != nil {
+		return nil, fmt.Errorf("the remote signer failed to sign: %v", err)
+	}
+	return resp.Signature, nil
+}
+
+// Close closes the connection to the remote signer.
+func (s *Signer) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,302 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// RootRotationPhaseAnnotationKey is the annotation of CASecret holding the phase of the root rotation.
+	// Operators start a rotation by setting it to RootRotationRequested.
+	RootRotationPhaseAnnotationKey = "istio.io/root-rotation-phase"
+	// RootRotationPhaseStartAnnotationKey is the annotation of CASecret holding the start time of the phase
+	// of the root rotation, in RFC 3339 format.
+	RootRotationPhaseStartAnnotationKey = "istio.io/root-rotation-phase-start"
+
+	// newCACertID and newCAPrivateKeyID are the key/cert of the new root, while it is only published.
+	newCACertID       = "new-ca-cert.pem"
+	newCAPrivateKeyID = "new-ca-key.pem"
+	// oldCACertID is the cert of the old root, while it is still published.
+	oldCACertID = "old-ca-cert.pem"
+)
+
+// RootRotationPhase is a phase of the rotation of the root of a self-signed CA.
+type RootRotationPhase string
+
+const (
+	// RootRotationIdle means no rotation is in progress.
+	RootRotationIdle RootRotationPhase = ""
+	// RootRotationRequested means a new root is to be generated.
+	RootRotationRequested RootRotationPhase = "Requested"
+	// RootRotationPublishBundle means both roots are published, while the old root still signs.
+	RootRotationPublishBundle RootRotationPhase = "PublishBundle"
+	// RootRotationSignWithNew means both roots are published, while the new root signs.
+	RootRotationSignWithNew RootRotationPhase = "SignWithNew"
+)
+
+// WorkloadTracker reports how many workloads have picked up the key cert bundle of the CA. Only the
+// workloads whose certificates are stored in secrets by Citadel can be tracked: the ones getting their
+// certificates through SDS are not, hence RootRotatorOptions.MinPhaseDuration.
+type WorkloadTracker interface {
+	// UpToDateWorkloads returns the number of workloads holding the current root certificates and a
+	// certificate signed by the current signing certificate, and the total number of workloads.
+	UpToDateWorkloads() (upToDate, total int)
+}
+
+// RootRotatorOptions holds the configurations of a RootRotator.
+type RootRotatorOptions struct {
+	// The namespace of CASecret.
+	Namespace string
+	// The interval between two checks of the rotation.
+	CheckInterval time.Duration
+	// A rotation starts when the lifetime left of the root is less than this ratio of its TTL.
+	// Zero disables the automatic rotation, which is then only started through RootRotationPhaseAnnotationKey.
+	GracePeriodRatio float64
+	// The minimum ratio of up-to-date workloads for the rotation to move to the next phase.
+	MinAdoptionRatio float64
+	// The minimum duration of a phase of the rotation, whatever the ratio of up-to-date workloads. It should
+	// be at least the max TTL of the workload certificates, so that the workloads which can't be tracked,
+	// such as the ones using SDS, had their certificates renewed before the next phase.
+	MinPhaseDuration time.Duration
+	// If set, the rotation is only followed from CASecret, without moving it forward.
+	ReadOnly bool
+
+	// The options of the new root.
+	CACertTTL    time.Duration
+	Org          string
+	DualUse      bool
+	KeyAlgorithm util.KeyAlgorithm
+	RootCertFile string
+}
+
+// RootRotator rotates the root of a self-signed CA in phases, so that workloads trust both roots before
+// the new root signs their certificates, and the old root is only dropped when they no longer need it.
+// The phase is persisted in CASecret.
+type RootRotator struct {
+	ca      *IstioCA
+	client  corev1.CoreV1Interface
+	tracker WorkloadTracker
+	opts    RootRotatorOptions
+}
+
+// NewRootRotator returns a RootRotator of the self-signed CA. The tracker may be nil if workloads
+// can't be tracked, in which case every phase is considered adopted.
+func NewRootRotator(ca *IstioCA, client corev1.CoreV1Interface, tracker WorkloadTracker,
+	opts RootRotatorOptions) *RootRotator {
+	return &RootRotator{
+		ca:      ca,
+		client:  client,
+		tracker: tracker,
+		opts:    opts,
+	}
+}
+
+// Run checks the rotation every CheckInterval until stopCh is closed.
+func (r *RootRotator) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(r.opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.check(); err != nil {
+				log.Errorf("Root rotation check failed: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// check moves the rotation to its next phase when the current one has been adopted, and loads
+// the key cert bundle of the current phase into the CA.
+func (r *RootRotator) check() error {
+	secret, err := r.client.Secrets(r.opts.Namespace).Get(CASecret, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s:%s (%v)", r.opts.Namespace, CASecret, err)
+	}
+	phase := RootRotationPhase(secret.Annotations[RootRotationPhaseAnnotationKey])
+	if !r.opts.ReadOnly {
+		start := secret.Annotations[RootRotationPhaseStartAnnotationKey]
+		next, err := r.nextPhase(secret, phase)
+		if err != nil {
+			return err
+		}
+		if next != phase || secret.Annotations[RootRotationPhaseStartAnnotationKey] != start {
+			if secret, err = r.client.Secrets(r.opts.Namespace).Update(secret); err != nil {
+				return fmt.Errorf("failed to persist root rotation phase %q (%v)", next, err)
+			}
+		}
+		if next != phase {
+			log.Infof("Root rotation moves from phase %q to %q", phase, next)
+			rootRotationTransitions.With(phaseLabel(next)).Inc()
+			phase = next
+		}
+	}
+	setRootRotationPhase(phase)
+	return r.load(secret)
+}
+
+// nextPhase updates secret for the next phase of the rotation, if the current phase is complete.
+func (r *RootRotator) nextPhase(secret *v1.Secret, phase RootRotationPhase) (RootRotationPhase, error) {
+	switch phase {
+	case RootRotationIdle:
+		if !r.rootExpiring(secret.Data[caCertID]) {
+			return phase, nil
+		}
+		log.Infof("The root certificate is about to expire, starting a root rotation")
+		fallthrough
+	case RootRotationRequested:
+		options := selfSignedRootCertOptions(r.opts.CACertTTL, r.opts.Org, r.opts.DualUse, r.opts.KeyAlgorithm)
+		pemCert, pemKey, err := util.GenCertKeyFromOptions(options)
+		if err != nil {
+			return phase, fmt.Errorf("unable to generate the new root cert and key (%v)", err)
+		}
+		secret.Data[newCACertID] = pemCert
+		secret.Data[newCAPrivateKeyID] = pemKey
+		return setPhase(secret, RootRotationPublishBundle), nil
+	case RootRotationPublishBundle:
+		if !r.phaseElapsed(secret) || !r.adopted() {
+			return phase, nil
+		}
+		secret.Data[oldCACertID] = secret.Data[caCertID]
+		secret.Data[caCertID] = secret.Data[newCACertID]
+		secret.Data[caPrivateKeyID] = secret.Data[newCAPrivateKeyID]
+		delete(secret.Data, newCACertID)
+		delete(secret.Data, newCAPrivateKeyID)
+		return setPhase(secret, RootRotationSignWithNew), nil
+	case RootRotationSignWithNew:
+		if !r.phaseElapsed(secret) || !r.adopted() {
+			return phase, nil
+		}
+		delete(secret.Data, oldCACertID)
+		return setPhase(secret, RootRotationIdle), nil
+	default:
+		return phase, fmt.Errorf("unknown root rotation phase %q", phase)
+	}
+}
+
+func setPhase(secret *v1.Secret, phase RootRotationPhase) RootRotationPhase {
+	if phase == RootRotationIdle {
+		delete(secret.Annotations, RootRotationPhaseAnnotationKey)
+		delete(secret.Annotations, RootRotationPhaseStartAnnotationKey)
+		return phase
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[RootRotationPhaseAnnotationKey] = string(phase)
+	secret.Annotations[RootRotationPhaseStartAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+	return phase
+}
+
+// phaseElapsed returns whether the phase of secret has lasted at least MinPhaseDuration. A phase
+// without a valid start time, e.g. set by an operator, starts now.
+func (r *RootRotator) phaseElapsed(secret *v1.Secret) bool {
+	if r.opts.MinPhaseDuration <= 0 {
+		return true
+	}
+	start, err := time.Parse(time.RFC3339, secret.Annotations[RootRotationPhaseStartAnnotationKey])
+	if err != nil {
+		secret.Annotations[RootRotationPhaseStartAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+		return false
+	}
+	if elapsed := time.Since(start); elapsed < r.opts.MinPhaseDuration {
+		log.Infof("Root rotation waits for the minimum phase duration (%v/%v)", elapsed.Round(time.Second),
+			r.opts.MinPhaseDuration)
+		return false
+	}
+	return true
+}
+
+// rootExpiring returns whether the lifetime left of the root is less than the grace period.
+func (r *RootRotator) rootExpiring(certPem []byte) bool {
+	if r.opts.GracePeriodRatio <= 0 {
+		return false
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		log.Errorf("Failed to parse the root certificate (%v)", err)
+		return false
+	}
+	gracePeriod := time.Duration(r.opts.GracePeriodRatio * float64(cert.NotAfter.Sub(cert.NotBefore)))
+	return time.Until(cert.NotAfter) < gracePeriod
+}
+
+// adopted returns whether enough workloads are up to date with the key cert bundle of the CA.
+func (r *RootRotator) adopted() bool {
+	if r.tracker == nil {
+		return true
+	}
+	upToDate, total := r.tracker.UpToDateWorkloads()
+	rootRotationUpToDateWorkloads.Set(float64(upToDate))
+	rootRotationWorkloads.Set(float64(total))
+	if total > 0 && float64(upToDate) < r.opts.MinAdoptionRatio*float64(total) {
+		log.Infof("Root rotation waits for workloads to be updated (%d/%d)", upToDate, total)
+		return false
+	}
+	return true
+}
+
+// load sets the key cert bundle of the CA and the configmap to the ones of secret.
+func (r *RootRotator) load(secret *v1.Secret) error {
+	rootCerts, err := rootCertsFromSecret(secret, r.opts.RootCertFile)
+	if err != nil {
+		return err
+	}
+	bundle := r.ca.GetCAKeyCertBundle()
+	certPem, _, _, currentRootCerts := bundle.GetAllPem()
+	if bytes.Equal(certPem, secret.Data[caCertID]) && bytes.Equal(currentRootCerts, rootCerts) {
+		return nil
+	}
+	if err = bundle.VerifyAndSetAll(secret.Data[caCertID], secret.Data[caPrivateKeyID], nil, rootCerts); err != nil {
+		return fmt.Errorf("failed to load the key cert bundle of the root rotation (%v)", err)
+	}
+	if err = updateCertInConfigmap(r.opts.Namespace, r.client, rootCerts); err != nil {
+		return fmt.Errorf("failed to write the root certificates to configmap (%v)", err)
+	}
+	return nil
+}
+
+// rootCertsFromSecret returns the root certificates to publish for CASecret, which include both roots
+// during a rotation.
+func rootCertsFromSecret(secret *v1.Secret, rootCertFile string) ([]byte, error) {
+	certs := secret.Data[caCertID]
+	switch RootRotationPhase(secret.Annotations[RootRotationPhaseAnnotationKey]) {
+	case RootRotationPublishBundle:
+		certs = concatCerts(certs, secret.Data[newCACertID])
+	case RootRotationSignWithNew:
+		certs = concatCerts(secret.Data[oldCACertID], certs)
+	}
+	rootCerts, err := appendRootCerts(certs, rootCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append root certificates (%v)", err)
+	}
+	return rootCerts, nil
+}
+
+func concatCerts(first, second []byte) []byte {
+	certs := append([]byte{}, bytes.TrimSuffix(first, []byte("\n"))...)
+	return append(append(certs, '\n'), second...)
+}
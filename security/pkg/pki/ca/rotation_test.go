@@ -0,0 +1,241 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/k8s/configmap"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type fakeTracker struct {
+	upToDate, total int
+}
+
+func (t *fakeTracker) UpToDateWorkloads() (int, int) {
+	return t.upToDate, t.total
+}
+
+func TestRootRotation(t *testing.T) {
+	const namespace = "istio-system"
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(), time.Hour, time.Hour, time.Hour,
+		"test.ca.org", false, util.ECDSAP256Key, namespace, -1, client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA: %v", err)
+	}
+	tracker := &fakeTracker{total: 10}
+	rotator := NewRootRotator(ca, client.CoreV1(), tracker, RootRotatorOptions{
+		Namespace:        namespace,
+		MinAdoptionRatio: 0.9,
+		CACertTTL:        time.Hour,
+		Org:              "test.ca.org",
+		KeyAlgorithm:     util.ECDSAP256Key,
+	})
+
+	getSecret := func() map[string][]byte {
+		secret, err := client.CoreV1().Secrets(namespace).Get(CASecret, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		return secret.Data
+	}
+	check := func(stage string, wantPhase RootRotationPhase, wantSigningCert []byte, wantRootCerts ...[]byte) {
+		t.Helper()
+		if err := rotator.check(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", stage, err)
+		}
+		secret, err := client.CoreV1().Secrets(namespace).Get(CASecret, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get secret: %v", stage, err)
+		}
+		if phase := RootRotationPhase(secret.Annotations[RootRotationPhaseAnnotationKey]); phase != wantPhase {
+			t.Errorf("%s: got phase %q, want %q", stage, phase, wantPhase)
+		}
+		certPem, _, _, rootCerts := ca.GetCAKeyCertBundle().GetAllPem()
+		if !bytes.Equal(certPem, wantSigningCert) {
+			t.Errorf("%s: unexpected signing cert", stage)
+		}
+		if want := bytes.Join(wantRootCerts, nil); !bytes.Equal(rootCerts, want) {
+			t.Errorf("%s: got root certs\n%s\nwant\n%s", stage, rootCerts, want)
+		}
+		fromConfigMap, err := configmap.NewController(namespace, client.CoreV1()).GetCATLSRootCert()
+		if err != nil {
+			t.Fatalf("%s: failed to get the root certs from configmap: %v", stage, err)
+		}
+		if fromConfigMap != base64.StdEncoding.EncodeToString(rootCerts) {
+			t.Errorf("%s: the configmap does not hold the root certs", stage)
+		}
+	}
+
+	oldRoot := getSecret()[caCertID]
+	check("no rotation", RootRotationIdle, oldRoot, oldRoot)
+
+	secret, _ := client.CoreV1().Secrets(namespace).Get(CASecret, metav1.GetOptions{})
+	secret.Annotations = map[string]string{RootRotationPhaseAnnotationKey: string(RootRotationRequested)}
+	if _, err := client.CoreV1().Secrets(namespace).Update(secret); err != nil {
+		t.Fatalf("Failed to request a rotation: %v", err)
+	}
+	if err := rotator.check(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	newRoot := getSecret()[newCACertID]
+	check("requested", RootRotationPublishBundle, oldRoot, oldRoot, newRoot)
+
+	tracker.upToDate = 8
+	check("bundle not adopted", RootRotationPublishBundle, oldRoot, oldRoot, newRoot)
+
+	tracker.upToDate = 9
+	check("bundle adopted", RootRotationSignWithNew, newRoot, oldRoot, newRoot)
+
+	// The rotation resumes from the persisted phase after a restart.
+	restarted, err := NewSelfSignedIstioCAOptions(context.Background(), time.Hour, time.Hour, time.Hour,
+		"test.ca.org", false, util.ECDSAP256Key, namespace, -1, client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to restart the self-signed CA: %v", err)
+	}
+	if certPem, _, _, rootCerts := restarted.KeyCertBundle.GetAllPem(); !bytes.Equal(certPem, newRoot) ||
+		!bytes.Equal(rootCerts, bytes.Join([][]byte{oldRoot, newRoot}, nil)) {
+		t.Errorf("The restarted CA does not resume the rotation")
+	}
+
+	tracker.upToDate = 5
+	check("new signing cert not adopted", RootRotationSignWithNew, newRoot, oldRoot, newRoot)
+
+	tracker.upToDate = 10
+	check("new signing cert adopted", RootRotationIdle, newRoot, newRoot)
+	if data := getSecret(); data[oldCACertID] != nil || data[newCACertID] != nil || data[newCAPrivateKeyID] != nil {
+		t.Errorf("The rotation left certs in the secret")
+	}
+}
+
+func TestRootRotationOfExpiringRoot(t *testing.T) {
+	const namespace = "istio-system"
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(), time.Hour, time.Hour, time.Hour,
+		"test.ca.org", false, util.ECDSAP256Key, namespace, -1, client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA: %v", err)
+	}
+
+	for _, c := range []struct {
+		ratio     float64
+		wantPhase RootRotationPhase
+	}{
+		{ratio: 0, wantPhase: RootRotationIdle},
+		{ratio: 0.5, wantPhase: RootRotationIdle},
+		{ratio: 1, wantPhase: RootRotationPublishBundle},
+	} {
+		rotator := NewRootRotator(ca, client.CoreV1(), nil, RootRotatorOptions{
+			Namespace:        namespace,
+			GracePeriodRatio: c.ratio,
+			CACertTTL:        time.Hour,
+			KeyAlgorithm:     util.ECDSAP256Key,
+		})
+		if err := rotator.check(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		secret, err := client.CoreV1().Secrets(namespace).Get(CASecret, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		if phase := RootRotationPhase(secret.Annotations[RootRotationPhaseAnnotationKey]); phase != c.wantPhase {
+			t.Errorf("grace period ratio %v: got phase %q, want %q", c.ratio, phase, c.wantPhase)
+		}
+	}
+}
+
+func TestRootRotationMinPhaseDuration(t *testing.T) {
+	const namespace = "istio-system"
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(), time.Hour, time.Hour, time.Hour,
+		"test.ca.org", false, util.ECDSAP256Key, namespace, -1, client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA: %v", err)
+	}
+	// Every tracked workload is up to date, but the ones using SDS may not be.
+	rotator := NewRootRotator(ca, client.CoreV1(), &fakeTracker{upToDate: 10, total: 10}, RootRotatorOptions{
+		Namespace:        namespace,
+		MinAdoptionRatio: 1,
+		MinPhaseDuration: time.Hour,
+		CACertTTL:        time.Hour,
+		KeyAlgorithm:     util.ECDSAP256Key,
+	})
+
+	setAnnotations := func(annotations map[string]string) {
+		t.Helper()
+		secret, err := client.CoreV1().Secrets(namespace).Get(CASecret, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		secret.Annotations = annotations
+		if _, err := client.CoreV1().Secrets(namespace).Update(secret); err != nil {
+			t.Fatalf("Failed to update secret: %v", err)
+		}
+	}
+	check := func(stage string, wantPhase RootRotationPhase) map[string]string {
+		t.Helper()
+		if err := rotator.check(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", stage, err)
+		}
+		secret, err := client.CoreV1().Secrets(namespace).Get(CASecret, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get secret: %v", stage, err)
+		}
+		if phase := RootRotationPhase(secret.Annotations[RootRotationPhaseAnnotationKey]); phase != wantPhase {
+			t.Errorf("%s: got phase %q, want %q", stage, phase, wantPhase)
+		}
+		return secret.Annotations
+	}
+
+	setAnnotations(map[string]string{RootRotationPhaseAnnotationKey: string(RootRotationRequested)})
+	annotations := check("requested", RootRotationPublishBundle)
+	if _, err := time.Parse(time.RFC3339, annotations[RootRotationPhaseStartAnnotationKey]); err != nil {
+		t.Errorf("Invalid phase start %q: %v", annotations[RootRotationPhaseStartAnnotationKey], err)
+	}
+	check("phase too recent", RootRotationPublishBundle)
+
+	annotations[RootRotationPhaseStartAnnotationKey] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	setAnnotations(annotations)
+	check("phase elapsed", RootRotationSignWithNew)
+
+	// A phase without start time, e.g. set by an operator, starts when first checked.
+	setAnnotations(map[string]string{RootRotationPhaseAnnotationKey: string(RootRotationSignWithNew)})
+	annotations = check("phase without start", RootRotationSignWithNew)
+	if annotations[RootRotationPhaseStartAnnotationKey] == "" {
+		t.Errorf("The phase start was not persisted")
+	}
+	check("phase started", RootRotationSignWithNew)
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vault is the signing backend of Citadel delegating to the PKI secrets engine of Vault.
+package vault
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+
+	"istio.io/istio/pkg/log"
+)
+
+// Options are the options of the Vault backend.
+type Options struct {
+	// The address of Vault, e.g. https://vault:8200.
+	Address string
+	// The PEM-encoded root certificates of the TLS certificate of Vault. The system roots are used if empty.
+	TLSRootCert []byte
+	// The path of the Kubernetes auth method login, e.g. auth/kubernetes/login.
+	LoginPath string
+	// The role of the Kubernetes auth method login.
+	LoginRole string
+	// The file of the service account JWT Citadel logs in with.
+	JWTPath string
+	// The path signing the CSRs, e.g. pki/sign/istio-workload. The role must allow the URI SANs of the workloads.
+	SignPath string
+}
+
+// Backend signs the certificates with the PKI secrets engine of Vault.
+type Backend struct {
+	opts   Options
+	client *api.Client
+
+	// mutex protects the token.
+	mutex       sync.Mutex
+	tokenExpiry time.Time
+	loggedIn    bool
+}
+
+// NewBackend creates a Vault backend.
+func NewBackend(opts Options) (*Backend, error) {
+	config := api.DefaultConfig()
+	config.Address = opts.Address
+	if len(opts.TLSRootCert) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("could not get SystemCertPool: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(opts.TLSRootCert) {
+			return nil, fmt.Errorf("failed to append the Vault TLS root certificates to the certificate pool")
+		}
+		config.HttpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	}
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a Vault client: %v", err)
+	}
+	log.Infof("created Vault signing backend for Vault address: %s", opts.Address)
+	return &Backend{opts: opts, client: client}, nil
+}
+
+// Sign signs the CSR with Vault. Vault sets the subject IDs as the URI SANs, or the DNS SANs of the IDs
+// without a scheme, e.g. the webhook services.
+func (b *Backend) Sign(csr *x509.CertificateRequest, subjectIDs []string, lifetime time.Duration,
+	forCA bool) ([]byte, error) {
+	if forCA {
+		return nil, fmt.Errorf("the Vault backend does not sign CA certificates")
+	}
+	if err := b.login(); err != nil {
+		return nil, err
+	}
+
+	var uriSANs, dnsSANs []string
+	for _, id := range subjectIDs {
+		if strings.Contains(id, "://") {
+			uriSANs = append(uriSANs, id)
+		} else {
+			dnsSANs = append(dnsSANs, id)
+		}
+	}
+	m := map[string]interface{}{
+		"format":               "pem",
+		"csr":                  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+		"ttl":                  strconv.FormatInt(int64(lifetime.Seconds()), 10) + "s",
+		"uri_sans":             strings.Join(uriSANs, ","),
+		"alt_names":            strings.Join(dnsSANs, ","),
+		"exclude_cn_from_sans": true,
+	}
+	res, err := b.client.Logical().Write(b.opts.SignPath, m)
+	if err != nil {
+		b.logout()
+		return nil, fmt.Errorf("failed to post to %v: %v", b.opts.SignPath, err)
+	}
+	if res == nil || res.Data == nil {
+		return nil, fmt.Errorf("sign response of %v has no data", b.opts.SignPath)
+	}
+	cert, ok := res.Data["certificate"].(string)
+	if !ok {
+		return nil, fmt.Errorf("no certificate in the sign response of %v", b.opts.SignPath)
+	}
+	return []byte(strings.TrimSuffix(cert, "\n") + "\n"), nil
+}
+
+// login logs into the Kubernetes auth method of Vault, unless the token of the last login is still valid.
+func (b *Backend) login() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.loggedIn && (b.tokenExpiry.IsZero() || time.Now().Before(b.tokenExpiry)) {
+		return nil
+	}
+	jwt, err := ioutil.ReadFile(b.opts.JWTPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the JWT to login Vault: %v", err)
+	}
+	resp, err := b.client.Logical().Write(b.opts.LoginPath, map[string]interface{}{
+		"jwt":  strings.TrimSpace(string(jwt)),
+		"role": b.opts.LoginRole,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to login Vault at %s: %v", b.opts.Address, err)
+	}
+	if resp == nil || resp.Auth == nil {
+		return fmt.Errorf("login response of Vault has no auth")
+	}
+	b.client.SetToken(resp.Auth.ClientToken)
+	b.loggedIn = true
+	b.tokenExpiry = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		// Login again before the token expires.
+		b.tokenExpiry = time.Now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second * 9 / 10)
+	}
+	return nil
+}
+
+// logout forces the next signing to login again, e.g. after the token was revoked.
+func (b *Backend) logout() {
+	b.mutex.Lock()
+	b.loggedIn = false
+	b.mutex.Unlock()
+}
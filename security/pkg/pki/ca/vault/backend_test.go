@@ -0,0 +1,186 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	loginPath = "auth/kubernetes/login"
+	signPath  = "pki/sign/istio"
+	token     = "vault-token"
+)
+
+// mockVault is a Vault server signing the CSRs with a local CA.
+type mockVault struct {
+	caCert   []byte
+	caKey    []byte
+	mutex    sync.Mutex
+	logins   int
+	requests []map[string]interface{}
+}
+
+func (v *mockVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	switch r.URL.Path {
+	case "/v1/" + loginPath:
+		if body["jwt"] != "citadel-jwt" || body["role"] != "citadel" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		v.logins++
+		_, _ = w.Write([]byte(`{"auth":{"client_token":"` + token + `","lease_duration":3600}}`))
+	case "/v1/" + signPath:
+		if r.Header.Get("X-Vault-Token") != token {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		v.requests = append(v.requests, body)
+		csr, err := util.ParsePemEncodedCSR([]byte(body["csr"].(string)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ttl, err := time.ParseDuration(body["ttl"].(string))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		caCert, _ := util.ParsePemEncodedCertificate(v.caCert)
+		caKey, _ := util.ParsePemEncodedKey(v.caKey)
+		der, err := util.GenCertFromCSR(csr, caCert, csr.PublicKey, caKey,
+			strings.Split(body["uri_sans"].(string), ","), ttl, false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		resp, _ := json.Marshal(map[string]interface{}{"data": map[string]interface{}{
+			"certificate": strings.TrimSpace(string(cert)),
+			"issuing_ca":  strings.TrimSpace(string(v.caCert)),
+		}})
+		_, _ = w.Write(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestVaultBackend(t *testing.T) {
+	caCert, caKey, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "vault.ca.org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := &mockVault{caCert: caCert, caKey: caKey}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	jwtPath := filepath.Join(dir, "token")
+	caCertPath := filepath.Join(dir, "ca-cert.pem")
+	for path, data := range map[string][]byte{jwtPath: []byte("citadel-jwt\n"), caCertPath: caCert} {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend, err := NewBackend(Options{
+		Address:   server.URL,
+		LoginPath: loginPath,
+		LoginRole: "citadel",
+		JWTPath:   jwtPath,
+		SignPath:  signPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create the Vault backend: %v", err)
+	}
+	caopts, err := ca.NewExternalKeyIstioCAOptions("", caCertPath, caCertPath, nil, backend, time.Hour, 2*time.Hour,
+		"istio-system", fake.NewSimpleClientset().CoreV1())
+	if err != nil {
+		t.Fatalf("Failed to create the CA options: %v", err)
+	}
+	istioCA, err := ca.NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create the CA: %v", err)
+	}
+
+	csr, _, err := util.GenCSR(util.CertOptions{Org: "istio.io", KeyAlgorithm: util.ECDSAP256Key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjectID := "spiffe://cluster.local/ns/foo/sa/bar"
+	for i := 0; i < 2; i++ {
+		certPem, err := istioCA.Sign(csr, []string{subjectID}, 30*time.Minute, false)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		cert, err := util.ParsePemEncodedCertificate(certPem)
+		if err != nil {
+			t.Fatalf("Failed to parse the cert: %v", err)
+		}
+		signingCert, _, _, _ := istioCA.GetCAKeyCertBundle().GetAll()
+		if err := cert.CheckSignatureFrom(signingCert); err != nil {
+			t.Errorf("The cert is not signed by the Vault CA: %v", err)
+		}
+		if ids, _ := util.ExtractIDs(cert.Extensions); !reflect.DeepEqual(ids, []string{subjectID}) {
+			t.Errorf("Got SANs %v, want %v", ids, []string{subjectID})
+		}
+	}
+	if vault.logins != 1 {
+		t.Errorf("Got %d logins, want the token of the first login to be reused", vault.logins)
+	}
+	if got := vault.requests[0]["ttl"]; got != "1800s" {
+		t.Errorf("Got TTL %v, want 1800s", got)
+	}
+
+	if _, err := istioCA.Sign(csr, []string{subjectID}, 3*time.Hour, false); err == nil {
+		t.Errorf("Expected the CA to enforce the max TTL before calling Vault")
+	}
+	if _, err := istioCA.Sign(csr, []string{subjectID}, time.Hour, true); err == nil {
+		t.Errorf("Expected an error signing a CA certificate with Vault")
+	}
+}
@@ -16,6 +16,9 @@ package util
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -29,6 +32,62 @@ const (
 	blockTypePKCS8PrivateKey = "PRIVATE KEY"     // PKCS#8 plain private key
 )
 
+// KeyAlgorithm is the algorithm of a private key.
+type KeyAlgorithm string
+
+const (
+	// RSAKey is an RSA key, whose size is set separately.
+	RSAKey KeyAlgorithm = "RSA"
+	// ECDSAP256Key is an ECDSA key on the NIST P-256 curve.
+	ECDSAP256Key KeyAlgorithm = "ECDSA-P256"
+	// ECDSAP384Key is an ECDSA key on the NIST P-384 curve.
+	ECDSAP384Key KeyAlgorithm = "ECDSA-P384"
+)
+
+// ParseKeyAlgorithm parses the name of a key algorithm, RSA if empty.
+func ParseKeyAlgorithm(name string) (KeyAlgorithm, error) {
+	switch a := KeyAlgorithm(name); a {
+	case "", RSAKey:
+		return RSAKey, nil
+	case ECDSAP256Key, ECDSAP384Key:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unsupported key algorithm %q, must be one of %s, %s or %s", name, RSAKey, ECDSAP256Key, ECDSAP384Key)
+	}
+}
+
+// GetKeyAlgorithm returns the algorithm of a private key, with its size if it is an RSA key.
+func GetKeyAlgorithm(privKey crypto.PrivateKey) (KeyAlgorithm, int, error) {
+	switch k := privKey.(type) {
+	case *rsa.PrivateKey:
+		return RSAKey, k.N.BitLen(), nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return ECDSAP256Key, 0, nil
+		case elliptic.P384():
+			return ECDSAP384Key, 0, nil
+		}
+		return "", 0, fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+	default:
+		return "", 0, fmt.Errorf("unsupported key type %T", privKey)
+	}
+}
+
+// generateKey generates a private key with the algorithm of the options.
+func generateKey(options CertOptions) (crypto.Signer, error) {
+	switch options.KeyAlgorithm {
+	case "", RSAKey:
+		return rsa.GenerateKey(rand.Reader, options.RSAKeySize)
+	case ECDSAP256Key:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ECDSAP384Key:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", options.KeyAlgorithm)
+	}
+}
+
 // ParsePemEncodedCertificate constructs a `x509.Certificate` object using the
 // given a PEM-encoded certificate.
 func ParsePemEncodedCertificate(certBytes []byte) (*x509.Certificate, error) {
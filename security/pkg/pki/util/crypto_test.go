@@ -312,3 +312,85 @@ func TestGetRSAKeySize(t *testing.T) {
 		}
 	}
 }
+
+func TestParseKeyAlgorithm(t *testing.T) {
+	testCases := map[string]struct {
+		name      string
+		algorithm KeyAlgorithm
+		errMsg    string
+	}{
+		"Default to RSA": {
+			algorithm: RSAKey,
+		},
+		"RSA": {
+			name:      "RSA",
+			algorithm: RSAKey,
+		},
+		"ECDSA P-256": {
+			name:      "ECDSA-P256",
+			algorithm: ECDSAP256Key,
+		},
+		"ECDSA P-384": {
+			name:      "ECDSA-P384",
+			algorithm: ECDSAP384Key,
+		},
+		"Unsupported algorithm": {
+			name:   "ECDSA-P521",
+			errMsg: `unsupported key algorithm "ECDSA-P521", must be one of RSA, ECDSA-P256 or ECDSA-P384`,
+		},
+	}
+
+	for id, c := range testCases {
+		algorithm, err := ParseKeyAlgorithm(c.name)
+		if c.errMsg != "" {
+			if err == nil || err.Error() != c.errMsg {
+				t.Errorf(`%s: Unexpected error: expected "%s" but got "%v"`, id, c.errMsg, err)
+			}
+		} else if err != nil {
+			t.Errorf(`%s: Unexpected error: "%s"`, id, err)
+		} else if algorithm != c.algorithm {
+			t.Errorf(`%s: Unmatched key algorithm: expected %s but got %s`, id, c.algorithm, algorithm)
+		}
+	}
+}
+
+func TestGetKeyAlgorithm(t *testing.T) {
+	testCases := map[string]struct {
+		pem       string
+		algorithm KeyAlgorithm
+		size      int
+		errMsg    string
+	}{
+		"RSA key": {
+			pem:       keyRSA,
+			algorithm: RSAKey,
+			size:      2048,
+		},
+		"PKCS8 RSA key": {
+			pem:       keyPKCS8RSA,
+			algorithm: RSAKey,
+			size:      2048,
+		},
+		"ECDSA key on an unsupported curve": {
+			pem:    keyECDSA,
+			errMsg: "unsupported ECDSA curve P-224",
+		},
+	}
+
+	for id, c := range testCases {
+		key, err := ParsePemEncodedKey([]byte(c.pem))
+		if err != nil {
+			t.Errorf("%s: failed to parse the Pem key.", id)
+		}
+		algorithm, size, err := GetKeyAlgorithm(key)
+		if c.errMsg != "" {
+			if err == nil || err.Error() != c.errMsg {
+				t.Errorf(`%s: Unexpected error: expected "%s" but got "%v"`, id, c.errMsg, err)
+			}
+		} else if err != nil {
+			t.Errorf(`%s: Unexpected error: "%s"`, id, err)
+		} else if algorithm != c.algorithm || size != c.size {
+			t.Errorf(`%s: Unmatched key algorithm: expected %s/%d but got %s/%d`, id, c.algorithm, c.size, algorithm, size)
+		}
+	}
+}
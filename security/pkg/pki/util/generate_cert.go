@@ -20,6 +20,7 @@ package util
 
 import (
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -59,6 +60,9 @@ type CertOptions struct {
 	// The size of RSA private key to be generated.
 	RSAKeySize int
 
+	// The algorithm of the private key to be generated, an RSA key of RSAKeySize if empty.
+	KeyAlgorithm KeyAlgorithm
+
 	// Whether this certificate is used as signing cert for CA.
 	IsCA bool
 
@@ -80,14 +84,14 @@ type CertOptions struct {
 
 // GenCertKeyFromOptions generates a X.509 certificate and a private key with the given options.
 func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte, err error) {
-	// Generate a RSA private&public key pair.
+	// Generate a private&public key pair.
 	// The public key will be bound to the certificate generated below. The
 	// private key will be used to sign this certificate in the self-signed
 	// case, otherwise the certificate is signed by the signer private key
 	// as specified in the CertOptions.
-	priv, err := rsa.GenerateKey(rand.Reader, options.RSAKeySize)
+	priv, err := generateKey(options)
 	if err != nil {
-		return nil, nil, fmt.Errorf("cert generation fails at RSA key generation (%v)", err)
+		return nil, nil, fmt.Errorf("cert generation fails at key generation (%v)", err)
 	}
 	template, err := genCertTemplateFromOptions(options)
 	if err != nil {
@@ -97,7 +101,7 @@ func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte,
 	if !options.IsSelfSigned {
 		signerCert, signerKey = options.SignerCert, options.SignerPriv
 	}
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, signerCert, &priv.PublicKey, signerKey)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, signerCert, priv.Public(), signerKey)
 	if err != nil {
 		return nil, nil, fmt.Errorf("cert generation fails at X509 cert creation (%v)", err)
 	}
@@ -154,8 +158,8 @@ func genCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, t
 	var keyUsage x509.KeyUsage
 	extKeyUsages := []x509.ExtKeyUsage{}
 	if isCA {
-		// If the cert is a CA cert, the private key is allowed to sign other certificates.
-		keyUsage = x509.KeyUsageCertSign
+		// If the cert is a CA cert, the private key is allowed to sign other certificates and CRLs.
+		keyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	} else {
 		// Otherwise the private key is allowed for digital signature and key encipherment.
 		keyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
@@ -189,6 +193,8 @@ func genCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, t
 		return nil, err
 	}
 
+	// The signature algorithm is left to follow the signing key, whose type may differ from the
+	// one of the CSR key.
 	return &x509.Certificate{
 		SerialNumber:          serialNum,
 		Subject:               subject,
@@ -198,16 +204,15 @@ func genCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, t
 		ExtKeyUsage:           extKeyUsages,
 		IsCA:                  isCA,
 		BasicConstraintsValid: true,
-		ExtraExtensions:       exts,
-		SignatureAlgorithm:    csr.SignatureAlgorithm}, nil
+		ExtraExtensions:       exts}, nil
 }
 
 // genCertTemplateFromoptions generates a certificate template with the given options.
 func genCertTemplateFromOptions(options CertOptions) (*x509.Certificate, error) {
 	var keyUsage x509.KeyUsage
 	if options.IsCA {
-		// If the cert is a CA cert, the private key is allowed to sign other certificates.
-		keyUsage = x509.KeyUsageCertSign
+		// If the cert is a CA cert, the private key is allowed to sign other certificates and CRLs.
+		keyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	} else {
 		// Otherwise the private key is allowed for digital signature and key encipherment.
 		keyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
@@ -274,7 +279,7 @@ func genSerialNum() (*big.Int, error) {
 	return serialNum, nil
 }
 
-func encodePem(isCSR bool, csrOrCert []byte, priv *rsa.PrivateKey, pkcs8 bool) (
+func encodePem(isCSR bool, csrOrCert []byte, priv crypto.Signer, pkcs8 bool) (
 	csrOrCertPem []byte, privPem []byte, err error) {
 	encodeMsg := "CERTIFICATE"
 	if isCSR {
@@ -289,8 +294,18 @@ func encodePem(isCSR bool, csrOrCert []byte, priv *rsa.PrivateKey, pkcs8 bool) (
 		}
 		privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypePKCS8PrivateKey, Bytes: encodedKey})
 	} else {
-		encodedKey = x509.MarshalPKCS1PrivateKey(priv)
-		privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypeRSAPrivateKey, Bytes: encodedKey})
+		switch k := priv.(type) {
+		case *rsa.PrivateKey:
+			encodedKey = x509.MarshalPKCS1PrivateKey(k)
+			privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypeRSAPrivateKey, Bytes: encodedKey})
+		case *ecdsa.PrivateKey:
+			if encodedKey, err = x509.MarshalECPrivateKey(k); err != nil {
+				return nil, nil, err
+			}
+			privPem = pem.EncodeToMemory(&pem.Block{Type: blockTypeECPrivateKey, Bytes: encodedKey})
+		default:
+			return nil, nil, fmt.Errorf("unsupported private key type %T", priv)
+		}
 	}
 	err = nil
 	return
@@ -19,6 +19,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"strings"
 	"testing"
 	"time"
@@ -56,7 +57,7 @@ func TestGenCertKeyFromOptions(t *testing.T) {
 		NotBefore:   caCertNotBefore,
 		TTL:         caCertTTL,
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		KeyUsage:    x509.KeyUsageCertSign,
+		KeyUsage:    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		IsCA:        true,
 		Org:         "MyOrg",
 		Host:        caCertOptions.Host,
@@ -419,6 +420,80 @@ func TestGenCertFromCSR(t *testing.T) {
 	}
 }
 
+func TestGenCertKeyWithECDSAKey(t *testing.T) {
+	// An ECDSA root signs an ECDSA leaf and an RSA root signs an ECDSA leaf.
+	cases := []struct {
+		name      string
+		root      CertOptions
+		algorithm KeyAlgorithm
+	}{
+		{
+			name:      "ECDSA P-384 root",
+			root:      CertOptions{KeyAlgorithm: ECDSAP384Key},
+			algorithm: ECDSAP256Key,
+		},
+		{
+			name:      "RSA root",
+			root:      CertOptions{RSAKeySize: 1024},
+			algorithm: ECDSAP256Key,
+		},
+	}
+
+	for _, c := range cases {
+		rootOptions := c.root
+		rootOptions.Host = "test_ca.com"
+		rootOptions.Org = "MyOrg"
+		rootOptions.NotBefore = now.Add(-time.Hour)
+		rootOptions.TTL = 24 * time.Hour
+		rootOptions.IsCA = true
+		rootOptions.IsSelfSigned = true
+		rootCertPem, rootKeyPem, err := GenCertKeyFromOptions(rootOptions)
+		if err != nil {
+			t.Fatalf("%s: failed to generate the root cert: %v", c.name, err)
+		}
+		fields := &VerifyFields{
+			NotBefore: rootOptions.NotBefore,
+			TTL:       rootOptions.TTL,
+			KeyUsage:  x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			IsCA:      true,
+			Org:       "MyOrg",
+			Host:      rootOptions.Host,
+		}
+		if err := VerifyCertificate(rootKeyPem, rootCertPem, rootCertPem, fields); err != nil {
+			t.Errorf("%s: failed to verify the root cert: %v", c.name, err)
+		}
+		bundle, err := NewVerifiedKeyCertBundleFromPem(rootCertPem, rootKeyPem, nil, rootCertPem)
+		if err != nil {
+			t.Fatalf("%s: failed to load the root: %v", c.name, err)
+		}
+		rootCert, rootKey, _, _ := bundle.GetAll()
+
+		csrPem, keyPem, err := GenCSR(CertOptions{Host: "spiffe://test.com/ns/foo/sa/bar", KeyAlgorithm: c.algorithm})
+		if err != nil {
+			t.Fatalf("%s: failed to generate the CSR: %v", c.name, err)
+		}
+		csr, err := ParsePemEncodedCSR(csrPem)
+		if err != nil {
+			t.Fatalf("%s: failed to parse the CSR: %v", c.name, err)
+		}
+		certBytes, err := GenCertFromCSR(csr, rootCert, csr.PublicKey, *rootKey, []string{"spiffe://test.com/ns/foo/sa/bar"},
+			time.Hour, false)
+		if err != nil {
+			t.Fatalf("%s: failed to sign the CSR: %v", c.name, err)
+		}
+		certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+		fields = &VerifyFields{
+			TTL:         time.Hour,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			Host:        "spiffe://test.com/ns/foo/sa/bar",
+		}
+		if err := VerifyCertificate(keyPem, certPem, rootCertPem, fields); err != nil {
+			t.Errorf("%s: failed to verify the signed cert: %v", c.name, err)
+		}
+	}
+}
+
 func TestLoadSignerCredsFromFiles(t *testing.T) {
 	testCases := map[string]struct {
 		certFile    string
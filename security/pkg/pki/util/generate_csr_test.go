@@ -102,3 +102,33 @@ func TestGenCSRTemplateForDualUse(t *testing.T) {
 		}
 	}
 }
+
+func TestGenCSRWithECDSAKey(t *testing.T) {
+	for _, algorithm := range []KeyAlgorithm{ECDSAP256Key, ECDSAP384Key} {
+		csrPem, keyPem, err := GenCSR(CertOptions{
+			Host:         "test_ca.com",
+			Org:          "MyOrg",
+			KeyAlgorithm: algorithm,
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to gen CSR: %v", algorithm, err)
+		}
+		csr, err := ParsePemEncodedCSR(csrPem)
+		if err != nil {
+			t.Fatalf("%s: failed to parse csr: %v", algorithm, err)
+		}
+		if err = csr.CheckSignature(); err != nil {
+			t.Errorf("%s: csr signature is invalid: %v", algorithm, err)
+		}
+		if csr.PublicKeyAlgorithm != x509.ECDSA {
+			t.Errorf("%s: got public key algorithm %v, want ECDSA", algorithm, csr.PublicKeyAlgorithm)
+		}
+		key, err := ParsePemEncodedKey(keyPem)
+		if err != nil {
+			t.Fatalf("%s: failed to parse the key: %v", algorithm, err)
+		}
+		if got, _, err := GetKeyAlgorithm(key); err != nil || got != algorithm {
+			t.Errorf("%s: got key algorithm %s (%v)", algorithm, got, err)
+		}
+	}
+}
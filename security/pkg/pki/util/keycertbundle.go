@@ -24,6 +24,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"reflect"
 	"sync"
 )
 
@@ -101,6 +102,31 @@ func NewVerifiedKeyCertBundleFromFile(certFile, privKeyFile, certChainFile, root
 	return NewVerifiedKeyCertBundleFromPem(certBytes, privKeyBytes, certChainBytes, rootCertBytes)
 }
 
+// NewVerifiedKeyCertBundleWithSigner returns a new KeyCertBundle of a key held outside of the bundle, e.g. by
+// a remote signer, or of no key if signer is nil, or error if the provided certs failed the verification.
+func NewVerifiedKeyCertBundleWithSigner(certBytes []byte, signer crypto.Signer, certChainBytes, rootCertBytes []byte) (
+	*KeyCertBundleImpl, error) {
+	cert, err := verifyCertChain(certBytes, certChainBytes, rootCertBytes)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &KeyCertBundleImpl{
+		certBytes:      copyBytes(certBytes),
+		cert:           cert,
+		privKeyBytes:   []byte{},
+		certChainBytes: copyBytes(certChainBytes),
+		rootCertBytes:  copyBytes(rootCertBytes),
+	}
+	if signer != nil {
+		if !reflect.DeepEqual(signer.Public(), cert.PublicKey) {
+			return nil, fmt.Errorf("the cert does not match the key of the signer")
+		}
+		var privKey crypto.PrivateKey = signer
+		bundle.privKey = &privKey
+	}
+	return bundle, nil
+}
+
 // NewKeyCertBundleWithRootCertFromFile returns a new KeyCertBundle with the root cert without verification.
 func NewKeyCertBundleWithRootCertFromFile(rootCertFile string) (*KeyCertBundleImpl, error) {
 	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
@@ -186,23 +212,43 @@ func (b *KeyCertBundleImpl) CertOptions() (*CertOptions, error) {
 	if len(ids) != 1 {
 		return nil, fmt.Errorf("expect single id from the cert, found %v", ids)
 	}
-	size, err := GetRSAKeySize(*b.privKey)
+	algorithm, size, err := GetKeyAlgorithm(*b.privKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get RSA key size: %v", err)
+		return nil, fmt.Errorf("failed to get the key algorithm: %v", err)
 	}
 	return &CertOptions{
-		Host:       ids[0],
-		Org:        b.cert.Issuer.Organization[0],
-		IsCA:       b.cert.IsCA,
-		TTL:        b.cert.NotAfter.Sub(b.cert.NotBefore),
-		RSAKeySize: size,
-		IsDualUse:  ids[0] == b.cert.Subject.CommonName,
+		Host:         ids[0],
+		Org:          b.cert.Issuer.Organization[0],
+		IsCA:         b.cert.IsCA,
+		TTL:          b.cert.NotAfter.Sub(b.cert.NotBefore),
+		RSAKeySize:   size,
+		KeyAlgorithm: algorithm,
+		IsDualUse:    ids[0] == b.cert.Subject.CommonName,
 	}, nil
 }
 
 // Verify that the cert chain, root cert and key/cert match.
 func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error {
-	// Verify the cert can be verified from the root cert through the cert chain.
+	if _, err := verifyCertChain(certBytes, certChainBytes, rootCertBytes); err != nil {
+		return err
+	}
+
+	// Verify that the key can be correctly parsed.
+	if _, err := ParsePemEncodedKey(privKeyBytes); err != nil {
+		return fmt.Errorf("failed to parse private key PEM: %v", err)
+	}
+
+	// Verify the cert and key match.
+	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
+		return fmt.Errorf("the cert does not match the key")
+	}
+
+	return nil
+}
+
+// verifyCertChain verifies the cert can be verified from the root cert through the cert chain, and
+// returns the parsed cert.
+func verifyCertChain(certBytes, certChainBytes, rootCertBytes []byte) (*x509.Certificate, error) {
 	rcp := x509.NewCertPool()
 	rcp.AppendCertsFromPEM(rootCertBytes)
 
@@ -215,27 +261,16 @@ func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error
 	}
 	cert, err := ParsePemEncodedCertificate(certBytes)
 	if err != nil {
-		return fmt.Errorf("failed to parse cert PEM: %v", err)
+		return nil, fmt.Errorf("failed to parse cert PEM: %v", err)
 	}
 	chains, err := cert.Verify(opts)
 
 	if len(chains) == 0 || err != nil {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"cannot verify the cert with the provided root chain and cert "+
 				"pool with error: %v", err)
 	}
-
-	// Verify that the key can be correctly parsed.
-	if _, err = ParsePemEncodedKey(privKeyBytes); err != nil {
-		return fmt.Errorf("failed to parse private key PEM: %v", err)
-	}
-
-	// Verify the cert and key match.
-	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
-		return fmt.Errorf("the cert does not match the key")
-	}
-
-	return nil
+	return cert, nil
 }
 
 func copyBytes(src []byte) []byte {
@@ -15,6 +15,8 @@
 package util
 
 import (
+	"crypto"
+	"io/ioutil"
 	"strings"
 	"testing"
 	"time"
@@ -138,11 +140,12 @@ func TestCertOptionsAndRetrieveID(t *testing.T) {
 			certChainFile: "",
 			rootCertFile:  rootCertFile1,
 			certOptions: &CertOptions{
-				Host:       "watt",
-				TTL:        100 * 365 * 24 * time.Hour,
-				Org:        "Juju org",
-				IsCA:       false,
-				RSAKeySize: 2048,
+				Host:         "watt",
+				TTL:          100 * 365 * 24 * time.Hour,
+				Org:          "Juju org",
+				IsCA:         false,
+				RSAKeySize:   2048,
+				KeyAlgorithm: RSAKey,
 			},
 			expectedErr: "",
 		},
@@ -183,6 +186,9 @@ func compareCertOptions(actual, expected *CertOptions, t *testing.T) {
 	if actual.RSAKeySize != expected.RSAKeySize {
 		t.Errorf("RSAKeySize does not match")
 	}
+	if actual.KeyAlgorithm != expected.KeyAlgorithm {
+		t.Errorf("KeyAlgorithm does not match")
+	}
 }
 
 // The test of NewVerifiedKeyCertBundleFromPem, VerifyAndSetAll can be covered by this test.
@@ -303,3 +309,74 @@ func TestNewVerifiedKeyCertBundleFromFile(t *testing.T) {
 		}
 	}
 }
+
+func TestNewVerifiedKeyCertBundleWithSigner(t *testing.T) {
+	testCases := map[string]struct {
+		caCertFile    string
+		caKeyFile     string
+		certChainFile string
+		expectedErr   string
+	}{
+		"Success - 2 level CA": {
+			caCertFile:    intCertFile,
+			caKeyFile:     intKeyFile,
+			certChainFile: intCertChainFile,
+			expectedErr:   "",
+		},
+		"Success - no signer": {
+			caCertFile:    intCertFile,
+			caKeyFile:     "",
+			certChainFile: intCertChainFile,
+			expectedErr:   "",
+		},
+		"Failure - cert and signer do not match": {
+			caCertFile:    int2CertFile,
+			caKeyFile:     anotherKeyFile,
+			certChainFile: int2CertChainFile,
+			expectedErr:   "the cert does not match the key of the signer",
+		},
+	}
+	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, tc := range testCases {
+		certBytes, err := ioutil.ReadFile(tc.caCertFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certChainBytes, err := ioutil.ReadFile(tc.certChainFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var signer crypto.Signer
+		if tc.caKeyFile != "" {
+			keyBytes, err := ioutil.ReadFile(tc.caKeyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := ParsePemEncodedKey(keyBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer = key.(crypto.Signer)
+		}
+
+		bundle, err := NewVerifiedKeyCertBundleWithSigner(certBytes, signer, certChainBytes, rootCertBytes)
+		if err != nil {
+			if tc.expectedErr == "" {
+				t.Errorf("%s: Unexpected error: %v", id, err)
+			} else if strings.Compare(err.Error(), tc.expectedErr) != 0 {
+				t.Errorf("%s: Unexpected error: %v VS (expected) %s", id, err, tc.expectedErr)
+			}
+			continue
+		}
+		if tc.expectedErr != "" {
+			t.Errorf("%s: Expected error %s but succeeded", id, tc.expectedErr)
+			continue
+		}
+		if _, key, _, _ := bundle.GetAll(); (key == nil) != (signer == nil) {
+			t.Errorf("%s: Got private key %v, want the signer %v", id, key, signer)
+		}
+	}
+}
@@ -38,10 +38,13 @@ type Client interface {
 }
 
 // NewClient is the function to create implementations of the platform metadata client.
-func NewClient(platform, rootCertFile, keyFile, certChainFile string) (Client, error) {
+// The join token file is only used by the "jointoken" platform.
+func NewClient(platform, rootCertFile, keyFile, certChainFile, joinTokenFile string) (Client, error) {
 	switch platform {
 	case "onprem":
 		return NewOnPremClientImpl(rootCertFile, keyFile, certChainFile)
+	case "jointoken":
+		return NewJoinTokenClientImpl(rootCertFile, keyFile, certChainFile, joinTokenFile)
 	case "gcp":
 		// Temporarily disable ID token authentication on CSR API.
 		// [TODO](myidpt): enable when the Citadel authz can work correctly.
@@ -27,6 +27,7 @@ func TestNewClient(t *testing.T) {
 		rootCertFile  string
 		keyFile       string
 		certChainFile string
+		joinTokenFile string
 		caAddr        string
 		expectedErr   string
 	}{
@@ -38,6 +39,15 @@ func TestNewClient(t *testing.T) {
 			caAddr:        "localhost",
 			expectedErr:   "",
 		},
+		"jointoken test": {
+			platform:      "jointoken",
+			rootCertFile:  "testdata/cert-root-good.pem",
+			keyFile:       "testdata/key-from-root-good.pem",
+			certChainFile: "testdata/cert-from-root-good.pem",
+			joinTokenFile: "testdata/nonexistent-join-token",
+			caAddr:        "localhost",
+			expectedErr:   "",
+		},
 		"gcp test": {
 			platform:      "gcp",
 			rootCertFile:  "testdata/cert-root-good.pem",
@@ -70,7 +80,7 @@ func TestNewClient(t *testing.T) {
 
 	for id, tc := range testCases {
 		client, err := NewClient(
-			tc.platform, tc.rootCertFile, tc.keyFile, tc.certChainFile)
+			tc.platform, tc.rootCertFile, tc.keyFile, tc.certChainFile, tc.joinTokenFile)
 		if len(tc.expectedErr) > 0 {
 			if err == nil {
 				t.Errorf("%s: Succeeded. Error expected: %v", id, err)
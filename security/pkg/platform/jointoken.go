@@ -0,0 +1,157 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/security/pkg/jointoken"
+)
+
+// JoinTokenClientImpl is the implementation of the client attested by a one-time join token issued
+// by Citadel, e.g. on on-premise VMs without a provisioned certificate. It presents the join token
+// for the initial certificate, and the certificate for the renewals once it exists.
+type JoinTokenClientImpl struct {
+	// Root CA cert file to validate the gRPC service in CA.
+	rootCertFile string
+	// The private key file
+	keyFile string
+	// The cert chain file
+	certChainFile string
+	// The file of the join token
+	tokenFile string
+}
+
+// NewJoinTokenClientImpl creates a new JoinTokenClientImpl.
+func NewJoinTokenClientImpl(rootCert, key, certChain, token string) (*JoinTokenClientImpl, error) {
+	if _, err := os.Stat(rootCert); err != nil {
+		return nil, fmt.Errorf("failed to create join token client root cert file %v error %v", rootCert, err)
+	}
+	ci := &JoinTokenClientImpl{rootCert, key, certChain, token}
+	if !ci.hasCertificate() {
+		if _, err := os.Stat(token); err != nil {
+			return nil, fmt.Errorf("failed to create join token client: neither the join token nor the "+
+				"certificate exists: %v", err)
+		}
+	}
+	return ci, nil
+}
+
+// GetDialOptions returns the GRPC dial options to connect to the CA. The node agent is authenticated
+// by its certificate if it exists, or by the join token otherwise.
+func (ci *JoinTokenClientImpl) GetDialOptions() ([]grpc.DialOption, error) {
+	if ci.hasCertificate() {
+		transportCreds, err := getTLSCredentials(ci.rootCertFile, ci.keyFile, ci.certChainFile)
+		if err != nil {
+			return nil, err
+		}
+		return []grpc.DialOption{grpc.WithTransportCredentials(transportCreds)}, nil
+	}
+
+	token, err := ci.getToken()
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	bs, err := ioutil.ReadFile(ci.rootCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA cert: %s", err)
+	}
+	if !certPool.AppendCertsFromPEM(bs) {
+		return nil, fmt.Errorf("failed to append certificates")
+	}
+	transportCreds := credentials.NewTLS(&tls.Config{
+		RootCAs:    certPool,
+		ServerName: CitadelDNSSan,
+	})
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(transportCreds),
+		grpc.WithPerRPCCredentials(joinTokenCredentials(token)),
+	}, nil
+}
+
+// IsProperPlatform returns true, as join tokens do not depend on the platform.
+func (ci *JoinTokenClientImpl) IsProperPlatform() bool {
+	return true
+}
+
+// GetServiceIdentity gets the service identity from the cert SAN field if the cert exists, or
+// from the join token otherwise.
+func (ci *JoinTokenClientImpl) GetServiceIdentity() (string, error) {
+	if ci.hasCertificate() {
+		return getServiceIdentityFromCert(ci.certChainFile)
+	}
+	token, err := ci.getToken()
+	if err != nil {
+		return "", err
+	}
+	return jointoken.Identity(token)
+}
+
+// GetAgentCredential returns the certificate if it exists. The join token is not returned, since it
+// is passed in the metadata of the request.
+func (ci *JoinTokenClientImpl) GetAgentCredential() ([]byte, error) {
+	if !ci.hasCertificate() {
+		return []byte{}, nil
+	}
+	certBytes, err := ioutil.ReadFile(ci.certChainFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cert file: %s", ci.certChainFile)
+	}
+	return certBytes, nil
+}
+
+// GetCredentialType returns "jointoken".
+func (ci *JoinTokenClientImpl) GetCredentialType() string {
+	return "jointoken"
+}
+
+func (ci *JoinTokenClientImpl) hasCertificate() bool {
+	if _, err := os.Stat(ci.keyFile); err != nil {
+		return false
+	}
+	if _, err := os.Stat(ci.certChainFile); err != nil {
+		return false
+	}
+	return true
+}
+
+func (ci *JoinTokenClientImpl) getToken() (string, error) {
+	token, err := ioutil.ReadFile(ci.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read join token file: %v", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// joinTokenCredentials passes the join token in the metadata of the requests.
+type joinTokenCredentials string
+
+func (t joinTokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{jointoken.MetadataKey: string(t)}, nil
+}
+
+func (t joinTokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
@@ -79,7 +79,12 @@ func (ci *OnPremClientImpl) IsProperPlatform() bool {
 
 // GetServiceIdentity gets the service account from the cert SAN field.
 func (ci *OnPremClientImpl) GetServiceIdentity() (string, error) {
-	certBytes, err := ioutil.ReadFile(ci.certChainFile)
+	return getServiceIdentityFromCert(ci.certChainFile)
+}
+
+// getServiceIdentityFromCert gets the SPIFFE service identity from the SAN field of the cert.
+func getServiceIdentityFromCert(certChainFile string) (string, error) {
+	certBytes, err := ioutil.ReadFile(certChainFile)
 	if err != nil {
 		return "", err
 	}
@@ -47,6 +47,9 @@ func GrpcProtocolProvider(caAddress string, dialOpts []grpc.DialOption) (protoco
 	return protocol.NewGrpcConnection(caAddress, dialOpts)
 }
 
+// RootCertProvider returns the PEM-encoded root certificates published to the workloads.
+type RootCertProvider func() ([]byte, error)
+
 // LivenessCheckController updates the availability of the liveness probe of the CA instance
 type LivenessCheckController struct {
 	interval           time.Duration
@@ -56,13 +59,16 @@ type LivenessCheckController struct {
 	ca                 *ca.IstioCA
 	livenessProbe      *probe.Probe
 	provider           CAProtocolProvider
+	// publishedRootCerts returns the roots the issued certificates are verified against. If nil, the
+	// root of the CA is used.
+	publishedRootCerts RootCertProvider
 	checkCount         int
 }
 
 // NewLivenessCheckController creates the liveness check controller instance
 func NewLivenessCheckController(probeCheckInterval time.Duration, caAddr string,
 	ca *ca.IstioCA, livenessProbeOptions *probe.Options,
-	provider CAProtocolProvider) (*LivenessCheckController, error) {
+	provider CAProtocolProvider, publishedRootCerts RootCertProvider) (*LivenessCheckController, error) {
 	livenessProbe := probe.NewProbe()
 	livenessProbeController := probe.NewFileController(livenessProbeOptions)
 	livenessProbe.RegisterProbe(livenessProbeController, "liveness")
@@ -79,6 +85,8 @@ func NewLivenessCheckController(probeCheckInterval time.Duration, caAddr string,
 		caAddress:     caAddr,
 		provider:      provider,
 		checkCount:    0,
+
+		publishedRootCerts: publishedRootCerts,
 	}, nil
 }
 
@@ -96,7 +104,7 @@ func (c *LivenessCheckController) checkGrpcServer() error {
 
 	certPEM, signErr := c.ca.Sign(csrPEM, []string{LivenessProbeClientIdentity}, c.interval, false)
 	if signErr != nil {
-		return signErr.(ca.Error)
+		return signErr
 	}
 
 	// Store certificate chain and private key to generate CSR
@@ -134,6 +142,17 @@ func (c *LivenessCheckController) checkGrpcServer() error {
 		return err
 	}
 
+	// The issued certificate is verified against the roots the workloads actually trust.
+	verifyRootCertBytes := rootCertBytes
+	// The root of the CA is used when they cannot be read, since this is not a failure of the CA.
+	if c.publishedRootCerts != nil {
+		if published, err := c.publishedRootCerts(); err != nil {
+			log.Warnf("Failed to get the published root certificates, verifying against the CA root: %v", err)
+		} else {
+			verifyRootCertBytes = published
+		}
+	}
+
 	err = ioutil.WriteFile(testKey.Name(), privPEM, 0644)
 	if err != nil {
 		return err
@@ -186,7 +205,7 @@ func (c *LivenessCheckController) checkGrpcServer() error {
 	if !resp.IsApproved {
 		return fmt.Errorf("CSR sign failure: request is not approaved")
 	}
-	if vErr := util.Verify(resp.SignedCert, privKeyBytes, resp.CertChain, rootCertBytes); vErr != nil {
+	if vErr := util.Verify(resp.SignedCert, privKeyBytes, resp.CertChain, verifyRootCertBytes); vErr != nil {
 		err := fmt.Errorf("CSR sign failure: %v", vErr)
 		log.Errora(err)
 		return err
@@ -207,7 +226,21 @@ func (c *LivenessCheckController) Run() {
 	go func() {
 		t := time.NewTicker(c.interval)
 		for range t.C {
-			c.livenessProbe.SetAvailable(c.checkGrpcServer())
+			c.livenessProbe.SetAvailable(c.check())
 		}
 	}()
 }
+
+// check runs the CSR round trip and records its result in the metrics.
+func (c *LivenessCheckController) check() error {
+	start := time.Now()
+	err := c.checkGrpcServer()
+	healthCheckLatency.Set(time.Since(start).Seconds())
+	if err != nil {
+		healthCheckFailureCounts.Inc()
+		log.Warnf("CSR signing service health check failed: %v", err)
+		return err
+	}
+	healthCheckSuccessCounts.Inc()
+	return nil
+}
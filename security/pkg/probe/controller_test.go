@@ -88,6 +88,7 @@ func TestGcpGetServiceIdentity(t *testing.T) {
 				UpdateInterval: time.Minute,
 			},
 			fakeProvider,
+			nil,
 		)
 		if err != nil {
 			t.Errorf("%v: Expecting an error but an Istio CA is wrongly instantiated", id)
@@ -104,3 +105,40 @@ func TestGcpGetServiceIdentity(t *testing.T) {
 		}
 	}
 }
+
+func TestLivenessCheckPublishedRootCertsUnavailable(t *testing.T) {
+	bundle, err := util.NewVerifiedKeyCertBundleFromFile(
+		"../pki/testdata/multilevelpki/int-cert.pem", "../pki/testdata/multilevelpki/int-key.pem",
+		"", "../pki/testdata/multilevelpki/root-cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	istioCA, err := ca.NewIstioCA(&ca.IstioCAOptions{
+		CertTTL:       time.Minute * time.Duration(2),
+		MaxCertTTL:    time.Minute * time.Duration(4),
+		KeyCertBundle: bundle,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create a CA instances: %v", err)
+	}
+	sent := false
+	fakeProvider := func(_ string, _ []grpc.DialOption) (protocol.CAProtocol, error) {
+		sent = true
+		return mock.NewFakeProtocol(nil, "unexpected"), nil
+	}
+	controller, err := NewLivenessCheckController(time.Minute, "", istioCA,
+		&probe.Options{Path: "/tmp/test.key", UpdateInterval: time.Minute}, fakeProvider,
+		func() ([]byte, error) { return nil, fmt.Errorf("configmap not found") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The liveness check falls back to the CA root, rather than failing on the configmap.
+	expected := "unexpected"
+	if err := controller.check(); err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error. expected: %v, got: %v", expected, err)
+	}
+	if !sent {
+		t.Error("The CSR was not sent without the published root certificates")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	healthCheckSuccessCounts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "citadel",
+		Subsystem: "health_check",
+		Name:      "success_count",
+		Help:      "The number of CSR round trips through the Citadel server that have succeeded.",
+	})
+
+	healthCheckFailureCounts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "citadel",
+		Subsystem: "health_check",
+		Name:      "failure_count",
+		Help:      "The number of CSR round trips through the Citadel server that have failed.",
+	})
+
+	healthCheckLatency = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "citadel",
+		Subsystem: "health_check",
+		Name:      "latency_seconds",
+		Help:      "The duration of the last CSR round trip through the Citadel server.",
+	})
+
+	caCertExpirySeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "citadel",
+		Subsystem: "health_check",
+		Name:      "ca_cert_expiry_seconds",
+		Help:      "The time until the earliest expiry of the CA signing and root certificates.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(healthCheckSuccessCounts)
+	prometheus.MustRegister(healthCheckFailureCounts)
+	prometheus.MustRegister(healthCheckLatency)
+	prometheus.MustRegister(caCertExpirySeconds)
+}
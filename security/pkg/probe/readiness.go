@@ -0,0 +1,100 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"fmt"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/probe"
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// ReadinessCheckController updates the readiness probe of the CA instance, and reports the expiry of the
+// CA certificates. The probe stays available when they are about to expire, since the CA still serves
+// until then: the expiry is reported by the citadel_health_check_ca_cert_expiry_seconds metric and logs.
+type ReadinessCheckController struct {
+	interval        time.Duration
+	expiryThreshold time.Duration
+	ca              *ca.IstioCA
+	readinessProbe  *probe.Probe
+	now             func() time.Time
+}
+
+// NewReadinessCheckController creates the readiness check controller instance. A warning is logged when
+// the CA signing or root certificate expires within expiryThreshold. The readiness probe file is only
+// updated if readinessProbeOptions is valid.
+func NewReadinessCheckController(probeCheckInterval time.Duration, expiryThreshold time.Duration,
+	ca *ca.IstioCA, readinessProbeOptions *probe.Options) *ReadinessCheckController {
+	readinessProbe := probe.NewProbe()
+	if readinessProbeOptions.IsValid() {
+		readinessProbeController := probe.NewFileController(readinessProbeOptions)
+		readinessProbe.RegisterProbe(readinessProbeController, "readiness")
+		readinessProbeController.Start()
+	}
+
+	return &ReadinessCheckController{
+		interval:        probeCheckInterval,
+		expiryThreshold: expiryThreshold,
+		ca:              ca,
+		readinessProbe:  readinessProbe,
+		now:             time.Now,
+	}
+}
+
+// checkCACertExpiry updates the metric of the CA certificate expiry, and returns an error if the CA signing
+// or root certificate expires within the threshold.
+func (c *ReadinessCheckController) checkCACertExpiry() error {
+	cert, _, _, rootCertBytes := c.ca.GetCAKeyCertBundle().GetAll()
+	if cert == nil {
+		return fmt.Errorf("no CA signing certificate")
+	}
+	rootCert, err := util.ParsePemEncodedCertificate(rootCertBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse the CA root certificate: %v", err)
+	}
+	name, expiry := "signing", cert.NotAfter
+	if rootCert.NotAfter.Before(expiry) {
+		name, expiry = "root", rootCert.NotAfter
+	}
+
+	remaining := expiry.Sub(c.now())
+	caCertExpirySeconds.Set(remaining.Seconds())
+	if remaining < c.expiryThreshold {
+		return fmt.Errorf("the CA %s certificate expires in %v, at %v", name, remaining.Round(time.Second), expiry)
+	}
+	return nil
+}
+
+// Run starts the check routine
+func (c *ReadinessCheckController) Run() {
+	c.readinessProbe.SetAvailable(nil)
+	c.check()
+	go func() {
+		t := time.NewTicker(c.interval)
+		for range t.C {
+			c.check()
+		}
+	}()
+}
+
+// check logs the expiry of the CA certificates as a warning, without failing the readiness probe.
+func (c *ReadinessCheckController) check() {
+	if err := c.checkCACertExpiry(); err != nil {
+		log.Warna(err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/probe"
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestReadinessCheckCACertExpiry(t *testing.T) {
+	bundle, err := util.NewVerifiedKeyCertBundleFromFile(
+		"../pki/testdata/multilevelpki/int-cert.pem", "../pki/testdata/multilevelpki/int-key.pem",
+		"", "../pki/testdata/multilevelpki/root-cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	istioCA, err := ca.NewIstioCA(&ca.IstioCAOptions{
+		CertTTL:       time.Minute * time.Duration(2),
+		MaxCertTTL:    time.Minute * time.Duration(4),
+		KeyCertBundle: bundle,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create a CA instances: %v", err)
+	}
+	signingCert, _, _, _ := bundle.GetAll()
+
+	const threshold = 30 * 24 * time.Hour
+	controller := NewReadinessCheckController(time.Minute, threshold, istioCA,
+		&probe.Options{Path: "/tmp/test.ready", UpdateInterval: time.Minute})
+
+	testCases := map[string]struct {
+		now      time.Time
+		expected string
+	}{
+		"not about to expire": {
+			now: signingCert.NotAfter.Add(-2 * threshold),
+		},
+		"about to expire": {
+			now:      signingCert.NotAfter.Add(-threshold / 2),
+			expected: "the CA signing certificate expires in 360h0m0s",
+		},
+		"expired": {
+			now:      signingCert.NotAfter.Add(time.Hour),
+			expected: "the CA signing certificate expires in -1h0m0s",
+		},
+	}
+	for id, c := range testCases {
+		controller.now = func() time.Time { return c.now }
+		err := controller.checkCACertExpiry()
+		if len(c.expected) == 0 {
+			if err != nil {
+				t.Errorf("%v: checkCACertExpiry should return nil: %v", id, err)
+			}
+		} else if err == nil || !strings.HasPrefix(err.Error(), c.expected) {
+			t.Errorf("%v: Unexpected error. expected: %v, got: %v", id, c.expected, err)
+		}
+	}
+
+	// The CA stays ready when its certificates are about to expire.
+	controller.now = func() time.Time { return signingCert.NotAfter.Add(-threshold / 2) }
+	controller.Run()
+	if err := controller.readinessProbe.IsAvailable(); err != nil {
+		t.Errorf("The readiness probe is unavailable: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// Cache holds the revocations of the configmap, and publishes their CRL signed by the CA.
+type Cache struct {
+	controller *Controller
+	bundle     util.KeyCertBundle
+	crlTTL     time.Duration
+
+	mutex    sync.RWMutex
+	denylist *Denylist
+}
+
+// NewCache returns a Cache of the revocations managed by controller, whose CRL is signed by the bundle and
+// valid for crlTTL.
+func NewCache(controller *Controller, bundle util.KeyCertBundle, crlTTL time.Duration) *Cache {
+	return &Cache{
+		controller: controller,
+		bundle:     bundle,
+		crlTTL:     crlTTL,
+		denylist:   &Denylist{},
+	}
+}
+
+// Run refreshes the revocations every interval until stopCh is closed.
+func (c *Cache) Run(interval time.Duration, stopCh <-chan struct{}) {
+	c.refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.refresh()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// IsIdentityRevoked returns whether any of the identities is revoked.
+func (c *Cache) IsIdentityRevoked(ids []string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.denylist.IsIdentityRevoked(ids)
+}
+
+// IsCertRevoked returns whether the certificate or any of its identities is revoked.
+func (c *Cache) IsCertRevoked(cert *x509.Certificate) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.denylist.IsCertRevoked(cert)
+}
+
+// refresh reloads the revocations, and publishes a new CRL if the published one is outdated.
+func (c *Cache) refresh() {
+	denylist, crl, err := c.controller.Get()
+	if err != nil {
+		log.Errorf("Failed to refresh the revocations: %v", err)
+		return
+	}
+	c.mutex.Lock()
+	c.denylist = denylist
+	c.mutex.Unlock()
+
+	signingCert, signingKey, certChain, _ := c.bundle.GetAll()
+	// There is no key when a signing backend issues the certificates, which then publishes the CRL itself.
+	if signingCert == nil || signingKey == nil {
+		return
+	}
+	// The sidecars require the CRL of every CA of a chain once one has a CRL, and the one of the root
+	// can't be signed by an intermediate CA. Any published CRL is withdrawn, rather than rejecting the
+	// whole mesh.
+	if len(certChain) > 0 || signingCert.CheckSignatureFrom(signingCert) != nil {
+		log.Errorf("The CRL is not published since the signing certificate is not a root, the revoked " +
+			"certificates are only rejected by Citadel")
+		if len(crl) > 0 {
+			if err = c.controller.SetCRL(nil); err != nil {
+				log.Errorf("Failed to withdraw the CRL: %v", err)
+			}
+		}
+		return
+	}
+	if !c.crlOutdated(crl, denylist, signingCert) {
+		return
+	}
+	newCRL, err := CreateCRL(denylist, signingCert, *signingKey, c.crlTTL)
+	if err != nil {
+		log.Errorf("Failed to create the CRL: %v", err)
+		return
+	}
+	if err = c.controller.SetCRL(newCRL); err != nil {
+		log.Errorf("Failed to publish the CRL: %v", err)
+		return
+	}
+	log.Infof("Published the CRL of %d revocations", len(denylist.Entries))
+}
+
+// crlOutdated returns whether the CRL is not the one of the revoked serial numbers, signed by the
+// signing cert, and in the first half of its validity.
+func (c *Cache) crlOutdated(crlPem []byte, denylist *Denylist, signingCert *x509.Certificate) bool {
+	block, _ := pem.Decode(crlPem)
+	if block == nil {
+		return true
+	}
+	crl, err := x509.ParseCRL(block.Bytes)
+	if err != nil || signingCert.CheckCRLSignature(crl) != nil {
+		return true
+	}
+	thisUpdate, nextUpdate := crl.TBSCertList.ThisUpdate, crl.TBSCertList.NextUpdate
+	if time.Now().After(thisUpdate.Add(nextUpdate.Sub(thisUpdate) / 2)) {
+		return true
+	}
+	published := map[string]bool{}
+	for _, e := range crl.TBSCertList.RevokedCertificates {
+		published[e.SerialNumber.Text(16)] = true
+	}
+	revoked := map[string]bool{}
+	for _, e := range denylist.Entries {
+		if e.Serial != "" {
+			if n, err := parseSerial(e.Serial); err == nil {
+				revoked[n.Text(16)] = true
+			}
+		}
+	}
+	return !reflect.DeepEqual(published, revoked)
+}
@@ -0,0 +1,184 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestController(t *testing.T) {
+	controller := NewController("istio-system", fake.NewSimpleClientset().CoreV1())
+
+	denylist, crl, err := controller.Get()
+	if err != nil || len(denylist.Entries) != 0 || crl != nil {
+		t.Fatalf("Expected no revocation without the configmap, got %v, %q (error %v)", denylist, crl, err)
+	}
+
+	serial, _ := NewSerialEntry("2a", "compromised")
+	identity, _ := NewIdentityEntry("spiffe://cluster.local/ns/foo/sa/bar", "")
+	for _, e := range []Entry{serial, identity} {
+		if err := controller.Revoke(e); err != nil {
+			t.Fatalf("Failed to revoke %v: %v", e, err)
+		}
+	}
+	if err := controller.SetCRL([]byte("crl")); err != nil {
+		t.Fatalf("Failed to set the CRL: %v", err)
+	}
+
+	denylist, crl, err = controller.Get()
+	if err != nil {
+		t.Fatalf("Failed to get the revocations: %v", err)
+	}
+	if len(denylist.Entries) != 2 || denylist.Entries[0].Serial != "2a" ||
+		denylist.Entries[1].Identity != "spiffe://cluster.local/ns/foo/sa/bar" {
+		t.Errorf("Unexpected revocations %v", denylist.Entries)
+	}
+	if string(crl) != "crl" {
+		t.Errorf("Unexpected CRL %q", crl)
+	}
+}
+
+func TestCache(t *testing.T) {
+	certPem, keyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "test.ca.org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate the CA: %v", err)
+	}
+	bundle, err := util.NewVerifiedKeyCertBundleFromPem(certPem, keyPem, nil, certPem)
+	if err != nil {
+		t.Fatalf("Failed to create the key cert bundle: %v", err)
+	}
+	controller := NewController("istio-system", fake.NewSimpleClientset().CoreV1())
+	cache := NewCache(controller, bundle, time.Hour)
+
+	parseCRL := func() (*pkix.CertificateList, []byte) {
+		t.Helper()
+		_, crlPem, err := controller.Get()
+		if err != nil {
+			t.Fatalf("Failed to get the CRL: %v", err)
+		}
+		block, _ := pem.Decode(crlPem)
+		if block == nil {
+			t.Fatalf("No CRL is published")
+		}
+		crl, err := x509.ParseCRL(block.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse the CRL: %v", err)
+		}
+		if signingCert, _, _, _ := bundle.GetAll(); signingCert.CheckCRLSignature(crl) != nil {
+			t.Errorf("The CRL is not signed by the CA")
+		}
+		return crl, crlPem
+	}
+
+	cache.refresh()
+	crl, published := parseCRL()
+	if revoked := crl.TBSCertList.RevokedCertificates; len(revoked) != 0 {
+		t.Errorf("Expected an empty CRL, got %v", revoked)
+	}
+
+	cache.refresh()
+	if _, crlPem := parseCRL(); !bytes.Equal(crlPem, published) {
+		t.Errorf("The CRL is republished without any new revocation")
+	}
+
+	serial, _ := NewSerialEntry("2a", "")
+	identity, _ := NewIdentityEntry("spiffe://cluster.local/ns/foo/sa/bar", "")
+	for _, e := range []Entry{serial, identity} {
+		if err := controller.Revoke(e); err != nil {
+			t.Fatalf("Failed to revoke %v: %v", e, err)
+		}
+	}
+	cache.refresh()
+	crl, _ = parseCRL()
+	if revoked := crl.TBSCertList.RevokedCertificates; len(revoked) != 1 || revoked[0].SerialNumber.Int64() != 0x2a {
+		t.Errorf("Unexpected revoked certificates %v", revoked)
+	}
+	if !cache.IsIdentityRevoked([]string{"spiffe://cluster.local/ns/foo/sa/bar"}) {
+		t.Errorf("Expected the identity to be revoked")
+	}
+}
+
+func TestCacheWithIntermediateCA(t *testing.T) {
+	rootCertPem, rootKeyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "test.ca.org",
+		IsCA:         true,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate the root CA: %v", err)
+	}
+	rootCert, err := util.ParsePemEncodedCertificate(rootCertPem)
+	if err != nil {
+		t.Fatalf("Failed to parse the root CA: %v", err)
+	}
+	rootKey, err := util.ParsePemEncodedKey(rootKeyPem)
+	if err != nil {
+		t.Fatalf("Failed to parse the root key: %v", err)
+	}
+	certPem, keyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          "test.ca.org",
+		IsCA:         true,
+		SignerCert:   rootCert,
+		SignerPriv:   rootKey,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate the intermediate CA: %v", err)
+	}
+	bundle, err := util.NewVerifiedKeyCertBundleFromPem(certPem, keyPem, certPem, rootCertPem)
+	if err != nil {
+		t.Fatalf("Failed to create the key cert bundle: %v", err)
+	}
+	controller := NewController("istio-system", fake.NewSimpleClientset().CoreV1())
+	if err := controller.SetCRL([]byte("stale")); err != nil {
+		t.Fatalf("Failed to set the CRL: %v", err)
+	}
+	serial, _ := NewSerialEntry("2a", "")
+	if err := controller.Revoke(serial); err != nil {
+		t.Fatalf("Failed to revoke %v: %v", serial, err)
+	}
+
+	cache := NewCache(controller, bundle, time.Hour)
+	cache.refresh()
+	_, crl, err := controller.Get()
+	if err != nil {
+		t.Fatalf("Failed to get the CRL: %v", err)
+	}
+	if len(crl) != 0 {
+		t.Errorf("Expected the CRL of the intermediate CA to be withdrawn, got %q", crl)
+	}
+	if !cache.IsCertRevoked(&x509.Certificate{SerialNumber: big.NewInt(0x2a)}) {
+		t.Errorf("Expected the certificate to be revoked by Citadel")
+	}
+}
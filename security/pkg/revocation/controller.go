@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// ConfigMapName is the name of the configmap holding the revocations and the CRL.
+	ConfigMapName = "istio-revocations"
+	// DenylistKey is the key of the JSON list of revocation entries in the configmap.
+	DenylistKey = "denylist"
+	// CRLKey is the key of the PEM-encoded CRL in the configmap.
+	CRLKey = "crl.pem"
+)
+
+// Controller manages the revocations and the CRL in ConfigMap.
+type Controller struct {
+	core      corev1.CoreV1Interface
+	namespace string
+}
+
+// NewController creates a new Controller.
+func NewController(namespace string, core corev1.CoreV1Interface) *Controller {
+	return &Controller{
+		namespace: namespace,
+		core:      core,
+	}
+}
+
+// Get gets the revocations and the CRL from the configmap. Both are empty if the configmap does not exist.
+func (c *Controller) Get() (*Denylist, []byte, error) {
+	configmap, err := c.core.ConfigMaps(c.namespace).Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return &Denylist{}, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get revocations: %v", err)
+	}
+	denylist, err := parseDenylist(configmap)
+	if err != nil {
+		return nil, nil, err
+	}
+	return denylist, []byte(configmap.Data[CRLKey]), nil
+}
+
+// Revoke adds the entry to the revocations in the configmap.
+func (c *Controller) Revoke(entry Entry) error {
+	return c.update(func(configmap *v1.ConfigMap) error {
+		denylist, err := parseDenylist(configmap)
+		if err != nil {
+			return err
+		}
+		denylist.Entries = append(denylist.Entries, entry)
+		value, err := json.MarshalIndent(denylist.Entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		configmap.Data[DenylistKey] = string(value)
+		return nil
+	})
+}
+
+// SetCRL updates the CRL in the configmap.
+func (c *Controller) SetCRL(crl []byte) error {
+	return c.update(func(configmap *v1.ConfigMap) error {
+		configmap.Data[CRLKey] = string(crl)
+		return nil
+	})
+}
+
+func (c *Controller) update(modify func(*v1.ConfigMap) error) error {
+	configmap, err := c.core.ConfigMaps(c.namespace).Get(ConfigMapName, metav1.GetOptions{})
+	exists := true
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get revocations: %v", err)
+		}
+		configmap = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapName,
+				Namespace: c.namespace,
+			},
+		}
+		exists = false
+	}
+	if configmap.Data == nil {
+		configmap.Data = map[string]string{}
+	}
+	if err = modify(configmap); err != nil {
+		return err
+	}
+	if exists {
+		_, err = c.core.ConfigMaps(c.namespace).Update(configmap)
+	} else {
+		_, err = c.core.ConfigMaps(c.namespace).Create(configmap)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update revocations: %v", err)
+	}
+	return nil
+}
+
+func parseDenylist(configmap *v1.ConfigMap) (*Denylist, error) {
+	denylist := &Denylist{}
+	if value := configmap.Data[DenylistKey]; value != "" {
+		if err := json.Unmarshal([]byte(value), &denylist.Entries); err != nil {
+			return nil, fmt.Errorf("failed to parse revocations %s:%s: %v", ConfigMapName, DenylistKey, err)
+		}
+	}
+	return denylist, nil
+}
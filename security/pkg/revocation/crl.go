@@ -0,0 +1,59 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"time"
+)
+
+const blockTypeCRL = "X509 CRL"
+
+// CreateCRL returns the PEM-encoded CRL of the revoked serial numbers of the denylist, signed by the CA
+// and valid for ttl. Revoked identities are not part of the CRL, since a CRL only lists serial numbers and
+// the CA keeps no record of the serial numbers it issued per identity: the certificates already issued to a
+// revoked identity are only rejected by Citadel, and stay valid for the sidecars until they expire, unless
+// their serial numbers are revoked as well.
+func CreateCRL(denylist *Denylist, signingCert *x509.Certificate, signingKey crypto.PrivateKey,
+	ttl time.Duration) ([]byte, error) {
+	if _, ok := signingKey.(crypto.Signer); !ok {
+		return nil, fmt.Errorf("the signing key %T can't sign a CRL", signingKey)
+	}
+	var revoked []pkix.RevokedCertificate
+	for _, e := range denylist.Entries {
+		if e.Serial == "" {
+			continue
+		}
+		serial, err := parseSerial(e.Serial)
+		if err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: e.RevokedAt,
+		})
+	}
+	now := time.Now()
+	crl, err := signingCert.CreateCRL(rand.Reader, signingKey, revoked, now, now.Add(ttl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the CRL: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockTypeCRL, Bytes: crl}), nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revocation holds the certificates and identities revoked by the CA, and publishes them
+// as a CRL signed by the CA.
+package revocation
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// Entry is a revoked certificate, identified by its serial number, or a revoked identity.
+type Entry struct {
+	// The serial number of the revoked certificate, in hexadecimal.
+	Serial string `json:"serial,omitempty"`
+	// The revoked identity, e.g. a SPIFFE ID. The CA no longer signs certificates for it, but the certificates
+	// already issued to it are not part of the CRL, and stay valid for the sidecars until they expire.
+	Identity string `json:"identity,omitempty"`
+	// The reason of the revocation, for operators.
+	Reason string `json:"reason,omitempty"`
+	// The time of the revocation.
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+// NewSerialEntry returns the entry revoking the certificate of the hexadecimal serial number, which may
+// be separated by colons as printed by openssl.
+func NewSerialEntry(serial, reason string) (Entry, error) {
+	n, err := parseSerial(serial)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Serial: n.Text(16), Reason: reason, RevokedAt: time.Now().UTC()}, nil
+}
+
+// NewIdentityEntry returns the entry revoking the identity.
+func NewIdentityEntry(identity, reason string) (Entry, error) {
+	if identity == "" {
+		return Entry{}, fmt.Errorf("empty identity")
+	}
+	return Entry{Identity: identity, Reason: reason, RevokedAt: time.Now().UTC()}, nil
+}
+
+func parseSerial(serial string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(strings.Replace(strings.TrimPrefix(serial, "0x"), ":", "", -1), 16)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid serial number %q, must be hexadecimal", serial)
+	}
+	return n, nil
+}
+
+// Denylist is the list of revocations.
+type Denylist struct {
+	Entries []Entry
+}
+
+// IsIdentityRevoked returns whether any of the identities, or the same identity in an alias of the
+// trust domain, is revoked.
+func (d *Denylist) IsIdentityRevoked(ids []string) bool {
+	ids = spiffe.ExpandWithTrustDomainAliases(ids)
+	for _, e := range d.Entries {
+		if e.Identity == "" {
+			continue
+		}
+		for _, id := range ids {
+			if id == e.Identity {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsCertRevoked returns whether the certificate or any of its identities is revoked.
+func (d *Denylist) IsCertRevoked(cert *x509.Certificate) bool {
+	serial := cert.SerialNumber.Text(16)
+	for _, e := range d.Entries {
+		if e.Serial == serial {
+			return true
+		}
+	}
+	ids, err := util.ExtractIDs(cert.Extensions)
+	return err == nil && d.IsIdentityRevoked(ids)
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestNewSerialEntry(t *testing.T) {
+	testCases := map[string]struct {
+		serial   string
+		expected string
+		errMsg   string
+	}{
+		"Hexadecimal": {
+			serial:   "1A2B",
+			expected: "1a2b",
+		},
+		"Colons": {
+			serial:   "00:1a:2b",
+			expected: "1a2b",
+		},
+		"Prefix": {
+			serial:   "0x1a2b",
+			expected: "1a2b",
+		},
+		"Invalid": {
+			serial: "xyz",
+			errMsg: `invalid serial number "xyz", must be hexadecimal`,
+		},
+	}
+	for id, tc := range testCases {
+		entry, err := NewSerialEntry(tc.serial, "compromised")
+		if tc.errMsg != "" {
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("%s: got error %v, want %s", id, err, tc.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", id, err)
+			continue
+		}
+		if entry.Serial != tc.expected || entry.Reason != "compromised" || entry.RevokedAt.IsZero() {
+			t.Errorf("%s: unexpected entry %+v", id, entry)
+		}
+	}
+}
+
+func TestNewIdentityEntry(t *testing.T) {
+	if _, err := NewIdentityEntry("", ""); err == nil {
+		t.Errorf("Expected an error for an empty identity")
+	}
+	entry, err := NewIdentityEntry("spiffe://cluster.local/ns/foo/sa/bar", "")
+	if err != nil || entry.Identity != "spiffe://cluster.local/ns/foo/sa/bar" {
+		t.Errorf("Unexpected entry %+v (error %v)", entry, err)
+	}
+}
+
+func TestDenylist(t *testing.T) {
+	denylist := &Denylist{Entries: []Entry{
+		{Serial: "2a"},
+		{Identity: "spiffe://cluster.local/ns/foo/sa/revoked"},
+	}}
+	certOf := func(serial int64, id string) *x509.Certificate {
+		san, err := util.BuildSANExtension([]util.Identity{{Type: util.TypeURI, Value: []byte(id)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &x509.Certificate{SerialNumber: big.NewInt(serial), Extensions: []pkix.Extension{*san}}
+	}
+
+	testCases := map[string]struct {
+		cert    *x509.Certificate
+		revoked bool
+	}{
+		"Not revoked": {
+			cert: certOf(1, "spiffe://cluster.local/ns/foo/sa/bar"),
+		},
+		"Revoked serial": {
+			cert:    certOf(0x2a, "spiffe://cluster.local/ns/foo/sa/bar"),
+			revoked: true,
+		},
+		"Revoked identity": {
+			cert:    certOf(1, "spiffe://cluster.local/ns/foo/sa/revoked"),
+			revoked: true,
+		},
+	}
+	for id, tc := range testCases {
+		if revoked := denylist.IsCertRevoked(tc.cert); revoked != tc.revoked {
+			t.Errorf("%s: got revoked %v, want %v", id, revoked, tc.revoked)
+		}
+	}
+
+	if !denylist.IsIdentityRevoked([]string{"a", "spiffe://cluster.local/ns/foo/sa/revoked"}) {
+		t.Errorf("Expected the identity to be revoked")
+	}
+	if denylist.IsIdentityRevoked([]string{"spiffe://cluster.local/ns/foo/sa/bar"}) {
+		t.Errorf("Expected the identity not to be revoked")
+	}
+}
+
+func TestDenylistWithTrustDomainAliases(t *testing.T) {
+	oldTrustDomain := spiffe.GetTrustDomain()
+	defer spiffe.SetTrustDomain(oldTrustDomain)
+	defer spiffe.SetTrustDomainAliases(nil)
+	spiffe.SetTrustDomain("new.td")
+	spiffe.SetTrustDomainAliases([]string{"old.td"})
+
+	denylist := &Denylist{Entries: []Entry{{Identity: "spiffe://old.td/ns/foo/sa/revoked"}}}
+	if !denylist.IsIdentityRevoked([]string{"spiffe://new.td/ns/foo/sa/revoked"}) {
+		t.Errorf("Expected the identity in the trust domain to be revoked by its alias")
+	}
+	if denylist.IsIdentityRevoked([]string{"spiffe://other.td/ns/foo/sa/revoked"}) {
+		t.Errorf("Expected the identity in another trust domain not to be revoked")
+	}
+}
@@ -15,6 +15,7 @@
 package authenticate
 
 import (
+	"crypto/x509"
 	"fmt"
 	"strings"
 
@@ -39,6 +40,7 @@ type AuthSource int
 const (
 	AuthSourceClientCertificate AuthSource = iota
 	AuthSourceIDToken
+	AuthSourceJoinToken
 )
 
 // Caller carries the identity and authentication source of a caller.
@@ -47,8 +49,17 @@ type Caller struct {
 	Identities []string
 }
 
+// CertRevocationChecker checks whether certificates are revoked.
+type CertRevocationChecker interface {
+	// IsCertRevoked returns whether the certificate or any of its identities is revoked.
+	IsCertRevoked(cert *x509.Certificate) bool
+}
+
 // ClientCertAuthenticator extracts identities from client certificate.
-type ClientCertAuthenticator struct{}
+type ClientCertAuthenticator struct {
+	// Revocations rejects the revoked client certificates. No certificate is revoked if nil.
+	Revocations CertRevocationChecker
+}
 
 // Authenticate extracts identities from presented client certificates. This
 // method assumes that certificate chain has been properly validated before
@@ -69,6 +80,9 @@ func (cca *ClientCertAuthenticator) Authenticate(ctx context.Context) (*Caller,
 	if len(chains) == 0 || len(chains[0]) == 0 {
 		return nil, fmt.Errorf("no verified chain is found")
 	}
+	if cca.Revocations != nil && cca.Revocations.IsCertRevoked(chains[0][0]) {
+		return nil, fmt.Errorf("the client certificate %s is revoked", chains[0][0].SerialNumber.Text(16))
+	}
 
 	ids, err := util.ExtractIDs(chains[0][0].Extensions)
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"errors"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -39,6 +40,14 @@ func (ai mockAuthInfo) AuthType() string {
 	return ai.authType
 }
 
+type mockRevocations struct {
+	serial int64
+}
+
+func (r *mockRevocations) IsCertRevoked(cert *x509.Certificate) bool {
+	return cert.SerialNumber != nil && cert.SerialNumber.Int64() == r.serial
+}
+
 func TestAuthenticate_clientCertAuthenticator(t *testing.T) {
 	callerID := "test.identity"
 	ids := []util.Identity{
@@ -91,9 +100,20 @@ func TestAuthenticate_clientCertAuthenticator(t *testing.T) {
 			},
 			caller: &Caller{Identities: []string{callerID}},
 		},
+		"With revoked client certificate": {
+			certChain: [][]*x509.Certificate{
+				{
+					{
+						SerialNumber: big.NewInt(0x2a),
+						Extensions:   []pkix.Extension{*sanExt},
+					},
+				},
+			},
+			authenticateErrMsg: "the client certificate 2a is revoked",
+		},
 	}
 
-	auth := &ClientCertAuthenticator{}
+	auth := &ClientCertAuthenticator{Revocations: &mockRevocations{serial: 0x2a}}
 
 	for id, tc := range testCases {
 		ctx := context.Background()
@@ -0,0 +1,88 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	"istio.io/istio/security/pkg/jointoken"
+)
+
+// JoinTokenConsumer validates and consumes one-time join tokens.
+type JoinTokenConsumer interface {
+	// Validate returns the identity the join token is bound to, without invalidating the token.
+	Validate(token string) (string, error)
+	// Consume returns the identity the join token is bound to, and invalidates the token.
+	Consume(token string) (string, error)
+}
+
+// JoinTokenAuthenticator authenticates the callers presenting a one-time join token, e.g. the node
+// agents on VMs requesting their initial certificates. The later renewals are authenticated by
+// the issued certificates.
+type JoinTokenAuthenticator struct {
+	tokens JoinTokenConsumer
+}
+
+// NewJoinTokenAuthenticator creates a new JoinTokenAuthenticator.
+func NewJoinTokenAuthenticator(tokens JoinTokenConsumer) *JoinTokenAuthenticator {
+	return &JoinTokenAuthenticator{tokens: tokens}
+}
+
+// Authenticate authenticates the call using the join token in the metadata of the context.
+// The returned Caller.Identities is the SPIFFE ID the token is bound to. The token is not consumed,
+// so that a failed signing can be retried with it; the server calls Consume once the certificate
+// is issued.
+func (a *JoinTokenAuthenticator) Authenticate(ctx context.Context) (*Caller, error) {
+	token, err := joinToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := a.tokens.Validate(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate the join token: %v", err)
+	}
+	return &Caller{
+		AuthSource: AuthSourceJoinToken,
+		Identities: []string{id},
+	}, nil
+}
+
+// Consume invalidates the join token in the metadata of the context. It fails if the token has
+// been consumed by a concurrent call in the meantime.
+func (a *JoinTokenAuthenticator) Consume(ctx context.Context) error {
+	token, err := joinToken(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := a.tokens.Consume(token); err != nil {
+		return fmt.Errorf("failed to consume the join token: %v", err)
+	}
+	return nil
+}
+
+func joinToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("no metadata is attached")
+	}
+	tokens := md.Get(jointoken.MetadataKey)
+	if len(tokens) != 1 {
+		return "", fmt.Errorf("expected exactly one join token, found %d", len(tokens))
+	}
+	return tokens[0], nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	"istio.io/istio/security/pkg/jointoken"
+)
+
+type mockJoinTokenConsumer map[string]string
+
+func (c mockJoinTokenConsumer) Validate(token string) (string, error) {
+	id, ok := c[token]
+	if !ok {
+		return "", fmt.Errorf("invalid token")
+	}
+	return id, nil
+}
+
+func (c mockJoinTokenConsumer) Consume(token string) (string, error) {
+	id, ok := c[token]
+	if !ok {
+		return "", fmt.Errorf("invalid token")
+	}
+	delete(c, token)
+	return id, nil
+}
+
+func TestJoinTokenAuthenticator(t *testing.T) {
+	const identity = "spiffe://cluster.local/ns/vm/sa/database"
+	authenticator := NewJoinTokenAuthenticator(mockJoinTokenConsumer{"token": identity})
+
+	testCases := []struct {
+		name           string
+		metadata       metadata.MD
+		expectedCaller *Caller
+		expectedErrMsg string
+	}{
+		{
+			name:           "No metadata",
+			expectedErrMsg: "no metadata is attached",
+		},
+		{
+			name:           "No join token",
+			metadata:       metadata.Pairs("authorization", "Bearer jwt"),
+			expectedErrMsg: "expected exactly one join token, found 0",
+		},
+		{
+			name:           "Invalid join token",
+			metadata:       metadata.Pairs(jointoken.MetadataKey, "other"),
+			expectedErrMsg: "failed to validate the join token: invalid token",
+		},
+		{
+			name:     "Valid join token",
+			metadata: metadata.Pairs(jointoken.MetadataKey, "token"),
+			expectedCaller: &Caller{
+				AuthSource: AuthSourceJoinToken,
+				Identities: []string{identity},
+			},
+		},
+		{
+			name:     "Validated join token",
+			metadata: metadata.Pairs(jointoken.MetadataKey, "token"),
+			expectedCaller: &Caller{
+				AuthSource: AuthSourceJoinToken,
+				Identities: []string{identity},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		ctx := context.Background()
+		if tc.metadata != nil {
+			ctx = metadata.NewIncomingContext(ctx, tc.metadata)
+		}
+		caller, err := authenticator.Authenticate(ctx)
+		if len(tc.expectedErrMsg) > 0 {
+			if err == nil || err.Error() != tc.expectedErrMsg {
+				t.Errorf("%s: got error %v, expected %q", tc.name, err, tc.expectedErrMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !reflect.DeepEqual(caller, tc.expectedCaller) {
+			t.Errorf("%s: got caller %+v, expected %+v", tc.name, caller, tc.expectedCaller)
+		}
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(jointoken.MetadataKey, "token"))
+	if err := authenticator.Consume(ctx); err != nil {
+		t.Errorf("Consume() failed: %v", err)
+	}
+	if _, err := authenticator.Authenticate(ctx); err == nil {
+		t.Error("Authenticated with a consumed join token")
+	}
+	if err := authenticator.Consume(ctx); err == nil {
+		t.Error("Consumed the join token twice")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/caclient"
+	"istio.io/istio/security/pkg/caclient/protocol"
+	"istio.io/istio/security/pkg/jointoken"
+	"istio.io/istio/security/pkg/pki/ca"
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+	mockutil "istio.io/istio/security/pkg/pki/util/mock"
+	"istio.io/istio/security/pkg/platform"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	pb "istio.io/istio/security/proto"
+)
+
+// TestJoinTokenAttestation joins a node agent with a join token to an in-process CA, renews its
+// certificate with mTLS and verifies that the join token cannot be reused.
+func TestJoinTokenAttestation(t *testing.T) {
+	const (
+		namespace = "istio-system"
+		identity  = "spiffe://cluster.local/ns/vm/sa/database"
+	)
+	client := fake.NewSimpleClientset()
+	caopts, err := ca.NewSelfSignedIstioCAOptions(context.Background(), time.Hour, time.Hour, time.Hour,
+		"test.ca.org", false, util.ECDSAP256Key, namespace, -1, client.CoreV1(), "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	istioCA, err := ca.NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA: %v", err)
+	}
+	tokens := jointoken.NewController(namespace, client.CoreV1())
+	token, err := tokens.Create(identity, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create the join token: %v", err)
+	}
+
+	port := freePort(t)
+	server, err := New(istioCA, time.Hour, false, []string{platform.CitadelDNSSan}, port, "cluster.local",
+		nil, nil, tokens, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the CA server: %v", err)
+	}
+	if err := server.Run(); err != nil {
+		t.Fatalf("Failed to run the CA server: %v", err)
+	}
+	caAddr := fmt.Sprintf("localhost:%d", port)
+
+	dir, err := ioutil.TempDir("", "join_token_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rootCertFile := filepath.Join(dir, "root-cert.pem")
+	tokenFile := filepath.Join(dir, "join-token")
+	if err := ioutil.WriteFile(rootCertFile, istioCA.GetCAKeyCertBundle().GetRootCertPem(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(tokenFile, []byte(token+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	certChainFile := filepath.Join(dir, "cert-chain.pem")
+
+	// Join with the token, then renew with the issued certificate.
+	for _, step := range []string{"join", "renew"} {
+		pc, err := platform.NewJoinTokenClientImpl(rootCertFile, keyFile, certChainFile, tokenFile)
+		if err != nil {
+			t.Fatalf("%s: failed to create the join token client: %v", step, err)
+		}
+		resp, privKey, err := sendCSR(pc, caAddr)
+		if err != nil {
+			t.Fatalf("%s: CSR failed: %v", step, err)
+		}
+		if err := caclient.SaveKeyCert(keyFile, certChainFile, privKey,
+			append(resp.SignedCert, resp.CertChain...)); err != nil {
+			t.Fatal(err)
+		}
+		cert, err := util.ParsePemEncodedCertificate(resp.SignedCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids, err := util.ExtractIDs(cert.Extensions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 1 || ids[0] != identity {
+			t.Errorf("%s: certificate issued to %v, expected %s", step, ids, identity)
+		}
+	}
+
+	// Another node agent cannot join with the used token.
+	otherDir, err := ioutil.TempDir("", "join_token_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(otherDir)
+	pc, err := platform.NewJoinTokenClientImpl(rootCertFile, filepath.Join(otherDir, "key.pem"),
+		filepath.Join(otherDir, "cert-chain.pem"), tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sendCSR(pc, caAddr); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Reused join token: got error %v, expected %v", err, codes.Unauthenticated)
+	}
+}
+
+// TestJoinTokenConsumedAfterSigning verifies that a join token survives a failed signing, so that
+// the caller can retry, and is consumed once a certificate is issued.
+func TestJoinTokenConsumedAfterSigning(t *testing.T) {
+	const identity = "spiffe://cluster.local/ns/vm/sa/database"
+	tokens := jointoken.NewController("istio-system", fake.NewSimpleClientset().CoreV1())
+	token, err := tokens.Create(identity, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create the join token: %v", err)
+	}
+	fakeCA := &mockca.FakeCA{
+		SignErr: ca.NewError(ca.CANotReady, fmt.Errorf("cannot sign")),
+		KeyCertBundle: &mockutil.FakeKeyCertBundle{
+			RootCertBytes: []byte("root_cert"),
+		},
+	}
+	joinTokens := authenticate.NewJoinTokenAuthenticator(tokens)
+	server := &Server{
+		ca:             fakeCA,
+		authenticators: []authenticator{joinTokens},
+		joinTokens:     joinTokens,
+		monitoring:     newMonitoringMetrics(),
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(jointoken.MetadataKey, token))
+	request := &pb.IstioCertificateRequest{Csr: "dumb CSR"}
+
+	if _, err := server.CreateCertificate(ctx, request); status.Code(err) != codes.Internal {
+		t.Fatalf("CreateCertificate() with a failing CA: got error %v, expected %v", err, codes.Internal)
+	}
+	if _, err := tokens.Validate(token); err != nil {
+		t.Errorf("The join token is invalid after a failed signing: %v", err)
+	}
+
+	fakeCA.SignErr = nil
+	fakeCA.SignedCert = []byte("cert")
+	if _, err := server.CreateCertificate(ctx, request); err != nil {
+		t.Fatalf("CreateCertificate() failed: %v", err)
+	}
+	if _, err := tokens.Validate(token); err == nil {
+		t.Error("The join token is still valid after a certificate is issued")
+	}
+	if _, err := server.CreateCertificate(ctx, request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Reused join token: got error %v, expected %v", err, codes.Unauthenticated)
+	}
+}
+
+func sendCSR(pc platform.Client, caAddr string) (*pb.CsrResponse, []byte, error) {
+	identity, err := pc.GetServiceIdentity()
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, privKey, err := util.GenCSR(util.CertOptions{Host: identity, RSAKeySize: 2048})
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := protocol.NewDialingGrpcConnection(caAddr, pc.GetDialOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := conn.SendCSR(&pb.CsrRequest{CsrPem: csr, RequestedTtlMinutes: 30})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, privKey, nil
+}
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
@@ -15,10 +15,13 @@
 package ca
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
 	"time"
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
@@ -26,13 +29,17 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/util"
 	"istio.io/istio/security/pkg/registry"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
+	"istio.io/istio/security/pkg/trustbundle"
 	pb "istio.io/istio/security/proto"
 )
 
@@ -60,6 +67,13 @@ type Server struct {
 	forCA          bool
 	port           int
 	monitoring     monitoringMetrics
+	auditor        *audit.Auditor
+	trustBundles   *trustbundle.Controller
+	joinTokens     *authenticate.JoinTokenAuthenticator
+
+	clientCAsMutex    sync.Mutex
+	clientCAPool      *x509.CertPool
+	clientCARootCerts []byte
 }
 
 // CreateCertificate handles an incoming certificate signing request (CSR). It does
@@ -87,11 +101,19 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		s.monitoring.GetCertSignError(signErr.(*ca.Error).ErrorType()).Inc()
 		return nil, status.Errorf(signErr.(*ca.Error).HTTPErrorCode(), "CSR signing error (%v)", signErr.(*ca.Error))
 	}
+	if err := s.auditor.Audit(cert, strings.Join(caller.Identities, ","), sourceIP(ctx)); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "certificate not audited (%v)", err)
+	}
+	if err := s.consumeJoinToken(ctx, caller); err != nil {
+		return nil, err
+	}
 	respCertChain := []string{string(cert)}
 	if len(certChainBytes) != 0 {
 		respCertChain = append(respCertChain, string(certChainBytes))
 	}
-	respCertChain = append(respCertChain, string(rootCertBytes))
+	// The root certificates of the peer meshes are appended if they are distributed to the workloads, so that
+	// the workloads accept their certificates.
+	respCertChain = append(respCertChain, string(trustbundle.MergeRootCerts(rootCertBytes, s.trustBundles.PeerRootCerts())))
 	response := &pb.IstioCertificateResponse{
 		CertChain: respCertChain,
 	}
@@ -138,6 +160,12 @@ func (s *Server) HandleCSR(ctx context.Context, request *pb.CsrRequest) (*pb.Csr
 		s.monitoring.GetCertSignError(signErr.(*ca.Error).ErrorType()).Inc()
 		return nil, status.Errorf(codes.Internal, "CSR signing error (%v)", signErr.(*ca.Error))
 	}
+	if err := s.auditor.Audit(cert, strings.Join(caller.Identities, ","), sourceIP(ctx)); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "certificate not audited (%v)", err)
+	}
+	if err := s.consumeJoinToken(ctx, caller); err != nil {
+		return nil, err
+	}
 
 	response := &pb.CsrResponse{
 		IsApproved: true,
@@ -150,6 +178,20 @@ func (s *Server) HandleCSR(ctx context.Context, request *pb.CsrRequest) (*pb.Csr
 	return response, nil
 }
 
+// consumeJoinToken invalidates the join token which authenticated the caller, once its certificate
+// is signed. The token stays valid when the signing fails, so that the caller can retry with it.
+func (s *Server) consumeJoinToken(ctx context.Context, caller *authenticate.Caller) error {
+	if caller.AuthSource != authenticate.AuthSourceJoinToken || s.joinTokens == nil {
+		return nil
+	}
+	if err := s.joinTokens.Consume(ctx); err != nil {
+		log.Warnf("join token consumption failure (%v)", err)
+		s.monitoring.AuthnError.Inc()
+		return status.Errorf(codes.Unauthenticated, "join token already used (%v)", err)
+	}
+	return nil
+}
+
 // Run starts a GRPC server on the specified port.
 func (s *Server) Run() error {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
@@ -181,16 +223,27 @@ func (s *Server) Run() error {
 }
 
 // New creates a new instance of `IstioCAServiceServer`.
-func New(ca ca.CertificateAuthority, ttl time.Duration, forCA bool, hostlist []string, port int, trustDomain string) (*Server, error) {
+func New(ca ca.CertificateAuthority, ttl time.Duration, forCA bool, hostlist []string, port int, trustDomain string,
+	revocations authenticate.CertRevocationChecker, auditor *audit.Auditor,
+	joinTokens authenticate.JoinTokenConsumer, trustBundles *trustbundle.Controller) (*Server, error) {
 	if len(hostlist) == 0 {
 		return nil, fmt.Errorf("failed to create grpc server hostlist empty")
 	}
 	// Notice that the order of authenticators matters, since at runtime
 	// authenticators are activated sequentially and the first successful attempt
 	// is used as the authentication result.
-	authenticators := []authenticator{&authenticate.ClientCertAuthenticator{}}
+	authenticators := []authenticator{&authenticate.ClientCertAuthenticator{Revocations: revocations}}
 	log.Info("added client certificate authenticator")
 
+	// The join token authenticator follows the client certificate authenticator, so that the
+	// tokens are not consumed by the callers which already hold a certificate.
+	var joinTokenAuthenticator *authenticate.JoinTokenAuthenticator
+	if joinTokens != nil {
+		joinTokenAuthenticator = authenticate.NewJoinTokenAuthenticator(joinTokens)
+		authenticators = append(authenticators, joinTokenAuthenticator)
+		log.Info("added join token authenticator")
+	}
+
 	authenticator, err := authenticate.NewKubeJWTAuthenticator(k8sAPIServerURL, caCertPath, jwtPath, trustDomain)
 	if err == nil {
 		authenticators = append(authenticators, authenticator)
@@ -220,16 +273,18 @@ func New(ca ca.CertificateAuthority, ttl time.Duration, forCA bool, hostlist []s
 		forCA:          forCA,
 		port:           port,
 		monitoring:     newMonitoringMetrics(),
+		auditor:        auditor,
+		trustBundles:   trustBundles,
+		joinTokens:     joinTokenAuthenticator,
 	}, nil
 }
 
 func (s *Server) createTLSServerOption() grpc.ServerOption {
-	cp := x509.NewCertPool()
-	rootCertBytes := s.ca.GetCAKeyCertBundle().GetRootCertPem()
-	cp.AppendCertsFromPEM(rootCertBytes)
+	return grpc.Creds(credentials.NewTLS(s.createTLSConfig()))
+}
 
+func (s *Server) createTLSConfig() *tls.Config {
 	config := &tls.Config{
-		ClientCAs:  cp,
 		ClientAuth: tls.VerifyClientCertIfGiven,
 		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
 			if s.certificate == nil || shouldRefresh(s.certificate) {
@@ -243,7 +298,29 @@ func (s *Server) createTLSServerOption() grpc.ServerOption {
 			return s.certificate, nil
 		},
 	}
-	return grpc.Creds(credentials.NewTLS(config))
+	// The client certificates are verified with the root certificates of the CA at the time of the
+	// connection, which include both roots during a root rotation, so that the workloads holding a
+	// certificate signed by either root can renew it.
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := config.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = s.clientCAs()
+		return c, nil
+	}
+	return config
+}
+
+// clientCAs returns the pool of the root certificates of the CA, built again when they change.
+func (s *Server) clientCAs() *x509.CertPool {
+	rootCertBytes := s.ca.GetCAKeyCertBundle().GetRootCertPem()
+	s.clientCAsMutex.Lock()
+	defer s.clientCAsMutex.Unlock()
+	if s.clientCAPool == nil || !bytes.Equal(s.clientCARootCerts, rootCertBytes) {
+		cp := x509.NewCertPool()
+		cp.AppendCertsFromPEM(rootCertBytes)
+		s.clientCAPool, s.clientCARootCerts = cp, rootCertBytes
+	}
+	return s.clientCAPool
 }
 
 func (s *Server) applyServerCertificate() (*tls.Certificate, error) {
@@ -278,13 +355,31 @@ func (s *Server) authenticate(ctx context.Context) *authenticate.Caller {
 		}
 		if u != nil && err == nil {
 			log.Debugf("Authentication successful through auth source %v", u.AuthSource)
-			return u
+			// The callers in an alias of the trust domain, e.g. presenting a certificate issued before
+			// the trust domain is renamed, are issued certificates in the trust domain.
+			ids := make([]string, 0, len(u.Identities))
+			for _, id := range u.Identities {
+				ids = append(ids, spiffe.ReplaceTrustDomainAlias(id))
+			}
+			return &authenticate.Caller{AuthSource: u.AuthSource, Identities: ids}
 		}
 	}
 	log.Warnf("Authentication failed: %s", errMsg)
 	return nil
 }
 
+// sourceIP returns the IP of the peer of the request, or an empty string if unknown.
+func sourceIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
 // shouldRefresh indicates whether the given certificate should be refreshed.
 func shouldRefresh(cert *tls.Certificate) bool {
 	// Check whether there is a valid leaf certificate.
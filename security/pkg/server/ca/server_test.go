@@ -19,16 +19,22 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/audit"
 	"istio.io/istio/security/pkg/pki/ca"
 	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
 	mockutil "istio.io/istio/security/pkg/pki/util/mock"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	pb "istio.io/istio/security/proto"
@@ -303,6 +309,120 @@ func TestHandleCSR(t *testing.T) {
 	}
 }
 
+type memorySink struct {
+	records []*audit.Record
+	err     error
+}
+
+func (s *memorySink) Write(record *audit.Record) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestAuditIssuedCertificates(t *testing.T) {
+	subjectID := "spiffe://cluster.local/ns/foo/sa/bar"
+	cert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         subjectID,
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &memorySink{}
+	server := &Server{
+		ca: &mockca.FakeCA{
+			SignedCert:    cert,
+			KeyCertBundle: &mockutil.FakeKeyCertBundle{CertChainBytes: []byte("cert chain")},
+		},
+		authorizer:     &mockAuthorizer{},
+		authenticators: []authenticator{&mockAuthenticator{identities: []string{subjectID}}},
+		monitoring:     newMonitoringMetrics(),
+		auditor:        audit.NewAuditor(sink, false),
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 34567},
+	})
+
+	if _, err := server.CreateCertificate(ctx, &pb.IstioCertificateRequest{Csr: csr}); err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	if _, err := server.HandleCSR(ctx, &pb.CsrRequest{CsrPem: []byte(csr)}); err != nil {
+		t.Fatalf("HandleCSR failed: %v", err)
+	}
+	if len(sink.records) != 2 {
+		t.Fatalf("Got %d audit records, want 2", len(sink.records))
+	}
+	for _, record := range sink.records {
+		if !reflect.DeepEqual(record.SubjectIDs, []string{subjectID}) || record.Requester != subjectID ||
+			record.SourceIP != "10.1.2.3" || record.TTLSeconds != 3600 || record.Serial == "" {
+			t.Errorf("Unexpected audit record %+v", record)
+		}
+	}
+}
+
+func TestAuditFailure(t *testing.T) {
+	subjectID := "spiffe://cluster.local/ns/foo/sa/bar"
+	cert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         subjectID,
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, failClosed := range []bool{false, true} {
+		server := &Server{
+			ca: &mockca.FakeCA{
+				SignedCert:    cert,
+				KeyCertBundle: &mockutil.FakeKeyCertBundle{CertChainBytes: []byte("cert chain")},
+			},
+			authorizer:     &mockAuthorizer{},
+			authenticators: []authenticator{&mockAuthenticator{identities: []string{subjectID}}},
+			monitoring:     newMonitoringMetrics(),
+			auditor:        audit.NewAuditor(&memorySink{err: fmt.Errorf("disk full")}, failClosed),
+		}
+		_, createErr := server.CreateCertificate(context.Background(), &pb.IstioCertificateRequest{Csr: csr})
+		_, handleErr := server.HandleCSR(context.Background(), &pb.CsrRequest{CsrPem: []byte(csr)})
+		for name, err := range map[string]error{"CreateCertificate": createErr, "HandleCSR": handleErr} {
+			if !failClosed && err != nil {
+				t.Errorf("%s failing open: unexpected error %v", name, err)
+			}
+			if failClosed && status.Code(err) != codes.Unavailable {
+				t.Errorf("%s failing closed: got error %v, want %v", name, err, codes.Unavailable)
+			}
+		}
+	}
+}
+
+func TestAuthenticateReplacesTrustDomainAlias(t *testing.T) {
+	oldTrustDomain := spiffe.GetTrustDomain()
+	defer spiffe.SetTrustDomain(oldTrustDomain)
+	defer spiffe.SetTrustDomainAliases(nil)
+	spiffe.SetTrustDomain("new.td")
+	spiffe.SetTrustDomainAliases([]string{"old.td"})
+
+	server := &Server{
+		authenticators: []authenticator{&mockAuthenticator{
+			authSource: authenticate.AuthSourceClientCertificate,
+			identities: []string{"spiffe://old.td/ns/foo/sa/bar", "spiffe://other.td/ns/foo/sa/bar"},
+		}},
+	}
+	caller := server.authenticate(context.Background())
+	expected := &authenticate.Caller{
+		AuthSource: authenticate.AuthSourceClientCertificate,
+		Identities: []string{"spiffe://new.td/ns/foo/sa/bar", "spiffe://other.td/ns/foo/sa/bar"},
+	}
+	if !reflect.DeepEqual(caller, expected) {
+		t.Errorf("authenticate() = %+v, expected %+v", caller, expected)
+	}
+}
+
 func TestShouldRefresh(t *testing.T) {
 	now := time.Now()
 	testCases := map[string]struct {
@@ -399,7 +519,7 @@ func TestRun(t *testing.T) {
 			// K8s JWT authenticator is added in k8s env.
 			tc.expectedAuthenticatorsLen++
 		}
-		server, err := New(tc.ca, time.Hour, false, tc.hostname, tc.port, "testdomain.com")
+		server, err := New(tc.ca, time.Hour, false, tc.hostname, tc.port, "testdomain.com", nil, nil, nil, nil)
 		if err == nil {
 			err = server.Run()
 		}
@@ -443,3 +563,113 @@ func TestRun(t *testing.T) {
 		}
 	}
 }
+
+func TestClientCAsFollowRootRotation(t *testing.T) {
+	genRoot := func() ([]byte, []byte) {
+		cert, key, err := util.GenCertKeyFromOptions(util.CertOptions{
+			TTL:          time.Hour,
+			Org:          "istio",
+			IsCA:         true,
+			IsSelfSigned: true,
+			RSAKeySize:   2048,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	genCert := func(rootCert, rootKey []byte, host string, server bool) tls.Certificate {
+		signerCert, err := util.ParsePemEncodedCertificate(rootCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signerKey, err := util.ParsePemEncodedKey(rootKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certPem, keyPem, err := util.GenCertKeyFromOptions(util.CertOptions{
+			Host:       host,
+			TTL:        time.Hour,
+			SignerCert: signerCert,
+			SignerPriv: signerKey,
+			IsClient:   !server,
+			IsServer:   server,
+			RSAKeySize: 2048,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := tls.X509KeyPair(certPem, keyPem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	oldRoot, oldKey := genRoot()
+	newRoot, newKey := genRoot()
+	bundle, err := util.NewVerifiedKeyCertBundleFromPem(oldRoot, oldKey, nil, oldRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert := genCert(oldRoot, oldKey, "localhost", true)
+	server := &Server{ca: &mockca.FakeCA{KeyCertBundle: bundle}, certificate: &serverCert}
+	config := server.createTLSConfig()
+
+	// handshake connects to the server with the client certificate, over TLS 1.2 so that the
+	// client certificate is verified before the client handshake completes.
+	handshake := func(clientCert tls.Certificate) error {
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close() // nolint: errcheck
+		defer clientConn.Close() // nolint: errcheck
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = tls.Client(clientConn, &tls.Config{
+				Certificates:       []tls.Certificate{clientCert},
+				InsecureSkipVerify: true,
+				MaxVersion:         tls.VersionTLS12,
+			}).Handshake()
+			_ = clientConn.Close()
+		}()
+		err := tls.Server(serverConn, config).Handshake()
+		_ = serverConn.Close()
+		<-done
+		return err
+	}
+
+	id := "spiffe://cluster.local/ns/default/sa/default"
+	oldCert := genCert(oldRoot, oldKey, id, false)
+	newCert := genCert(newRoot, newKey, id, false)
+	steps := []struct {
+		name      string
+		cert      []byte
+		key       []byte
+		rootCerts []byte
+		wantOld   bool
+		wantNew   bool
+	}{
+		{name: "before the rotation", cert: oldRoot, key: oldKey, rootCerts: oldRoot, wantOld: true},
+		{name: "bundle published", cert: oldRoot, key: oldKey, rootCerts: concatPem(oldRoot, newRoot), wantOld: true, wantNew: true},
+		{name: "signing with the new root", cert: newRoot, key: newKey, rootCerts: concatPem(oldRoot, newRoot), wantOld: true, wantNew: true},
+		{name: "old root dropped", cert: newRoot, key: newKey, rootCerts: newRoot, wantNew: true},
+	}
+	for _, step := range steps {
+		if err := bundle.VerifyAndSetAll(step.cert, step.key, nil, step.rootCerts); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if err := handshake(oldCert); (err == nil) != step.wantOld {
+			t.Errorf("%s: got error %v with a certificate signed by the old root", step.name, err)
+		}
+		if err := handshake(newCert); (err == nil) != step.wantNew {
+			t.Errorf("%s: got error %v with a certificate signed by the new root", step.name, err)
+		}
+	}
+}
+
+func concatPem(first, second []byte) []byte {
+	return append(append(append([]byte{}, bytes.TrimSuffix(first, []byte("\n"))...), '\n'), second...)
+}
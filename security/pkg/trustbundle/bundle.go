@@ -0,0 +1,120 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trustbundle exchanges the root certificates of Citadel with the peer meshes, so that the
+// workloads trust the certificates of the federated trust domains without concatenating the roots by hand.
+package trustbundle
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/ghodss/yaml"
+)
+
+// Bundle is the root certificates of a trust domain published to the peer meshes.
+type Bundle struct {
+	TrustDomain string `json:"trust_domain"`
+	// RootCerts are the PEM-encoded root certificates.
+	RootCerts string `json:"root_certs"`
+}
+
+// Peer is a federated mesh whose bundle is fetched.
+type Peer struct {
+	// TrustDomain is the trust domain of the peer.
+	TrustDomain string `json:"trustDomain"`
+	// Endpoint is the HTTPS URL the peer publishes its bundle at.
+	Endpoint string `json:"endpoint"`
+	// BootstrapCertFile is the file of the pinned root certificates of the peer, which authenticate the
+	// endpoint until its bundle is fetched.
+	BootstrapCertFile string `json:"bootstrapCertFile"`
+
+	bootstrapCerts []byte
+}
+
+// LoadPeers loads the list of the peers, in YAML or JSON, and their bootstrap certificates.
+func LoadPeers(file string) ([]Peer, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the trust bundle peers: %v", err)
+	}
+	var peers []Peer
+	if err := yaml.Unmarshal(content, &peers); err != nil {
+		return nil, fmt.Errorf("failed to parse the trust bundle peers %s: %v", file, err)
+	}
+	seen := map[string]bool{}
+	for i := range peers {
+		peer := &peers[i]
+		if peer.TrustDomain == "" || seen[peer.TrustDomain] {
+			return nil, fmt.Errorf("the trust domain of peer #%d is empty or duplicated", i)
+		}
+		seen[peer.TrustDomain] = true
+		if u, err := url.Parse(peer.Endpoint); err != nil || u.Scheme != "https" {
+			return nil, fmt.Errorf("the endpoint of peer %s must be an HTTPS URL", peer.TrustDomain)
+		}
+		if peer.bootstrapCerts, err = ioutil.ReadFile(peer.BootstrapCertFile); err != nil {
+			return nil, fmt.Errorf("failed to read the bootstrap certificates of peer %s: %v", peer.TrustDomain, err)
+		}
+		if _, err := ParseRootCerts(peer.bootstrapCerts); err != nil {
+			return nil, fmt.Errorf("invalid bootstrap certificates of peer %s: %v", peer.TrustDomain, err)
+		}
+	}
+	return peers, nil
+}
+
+// ParseRootCerts parses the PEM-encoded root certificates. It fails unless there is at least one
+// certificate and all of them are CA certificates.
+func ParseRootCerts(rootCerts []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := rootCerts
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if !cert.IsCA {
+			return nil, fmt.Errorf("certificate %s is not a CA certificate", cert.Subject)
+		}
+		certs = append(certs, cert)
+	}
+	if len(bytes.TrimSpace(rest)) != 0 || len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM-encoded certificate found")
+	}
+	return certs, nil
+}
+
+// MergeRootCerts returns the root certificates of the mesh followed by those of the peers.
+func MergeRootCerts(rootCerts, peerRootCerts []byte) []byte {
+	if len(peerRootCerts) == 0 {
+		return rootCerts
+	}
+	merged := make([]byte, 0, len(rootCerts)+len(peerRootCerts)+1)
+	merged = append(merged, rootCerts...)
+	if len(merged) > 0 && merged[len(merged)-1] != '\n' {
+		merged = append(merged, '\n')
+	}
+	return append(merged, peerRootCerts...)
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustbundle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func genRootCert(t *testing.T, isCA bool) []byte {
+	t.Helper()
+	certPem, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "test.ca.org",
+		TTL:          time.Hour,
+		Org:          "test.ca.org",
+		IsCA:         isCA,
+		IsSelfSigned: true,
+		KeyAlgorithm: util.ECDSAP256Key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate the certificate: %v", err)
+	}
+	return certPem
+}
+
+func TestLoadPeers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustbundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rootFile := filepath.Join(dir, "root-cert.pem")
+	leafFile := filepath.Join(dir, "leaf-cert.pem")
+	if err := ioutil.WriteFile(rootFile, genRootCert(t, true), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(leafFile, genRootCert(t, false), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		peers       string
+		expectedErr bool
+	}{
+		"valid": {
+			peers: `
+- trustDomain: east.example.com
+  endpoint: https://citadel.east.example.com:15443/v1/trust-bundle
+  bootstrapCertFile: ` + rootFile,
+		},
+		"no trust domain": {
+			peers: `
+- endpoint: https://citadel.east.example.com:15443/v1/trust-bundle
+  bootstrapCertFile: ` + rootFile,
+			expectedErr: true,
+		},
+		"duplicated trust domain": {
+			peers: `
+- trustDomain: east.example.com
+  endpoint: https://citadel.east.example.com:15443/v1/trust-bundle
+  bootstrapCertFile: ` + rootFile + `
+- trustDomain: east.example.com
+  endpoint: https://citadel.east.example.com:15443/v1/trust-bundle
+  bootstrapCertFile: ` + rootFile,
+			expectedErr: true,
+		},
+		"HTTP endpoint": {
+			peers: `
+- trustDomain: east.example.com
+  endpoint: http://citadel.east.example.com:15443/v1/trust-bundle
+  bootstrapCertFile: ` + rootFile,
+			expectedErr: true,
+		},
+		"missing bootstrap certificates": {
+			peers: `
+- trustDomain: east.example.com
+  endpoint: https://citadel.east.example.com:15443/v1/trust-bundle
+  bootstrapCertFile: ` + filepath.Join(dir, "missing.pem"),
+			expectedErr: true,
+		},
+		"non CA bootstrap certificate": {
+			peers: `
+- trustDomain: east.example.com
+  endpoint: https://citadel.east.example.com:15443/v1/trust-bundle
+  bootstrapCertFile: ` + leafFile,
+			expectedErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		file := filepath.Join(dir, "peers.yaml")
+		if err := ioutil.WriteFile(file, []byte(tc.peers), 0644); err != nil {
+			t.Fatal(err)
+		}
+		peers, err := LoadPeers(file)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%s: LoadPeers() succeeded, expected an error", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: LoadPeers() failed: %v", name, err)
+			continue
+		}
+		if len(peers) != 1 || peers[0].TrustDomain != "east.example.com" || len(peers[0].bootstrapCerts) == 0 {
+			t.Errorf("%s: unexpected peers %+v", name, peers)
+		}
+	}
+}
+
+func TestMergeRootCerts(t *testing.T) {
+	root := genRootCert(t, true)
+	peerRoot := genRootCert(t, true)
+
+	if merged := MergeRootCerts(root, nil); !bytes.Equal(merged, root) {
+		t.Errorf("MergeRootCerts() without peers = %q, expected %q", merged, root)
+	}
+	merged := MergeRootCerts(bytes.TrimSpace(root), peerRoot)
+	certs, err := ParseRootCerts(merged)
+	if err != nil {
+		t.Fatalf("Failed to parse the merged root certificates: %v", err)
+	}
+	if len(certs) != 2 {
+		t.Errorf("Merged %d root certificates, expected 2", len(certs))
+	}
+}
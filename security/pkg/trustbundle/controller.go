@@ -0,0 +1,261 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustbundle
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// ConfigMapName is the name of the configmap holding the bundles of the mesh and its peers.
+	ConfigMapName = "istio-trust-bundles"
+	// BundlesKey is the key of the JSON object of the PEM-encoded root certificates by trust domain
+	// in the configmap.
+	BundlesKey = "trust-bundles.json"
+	// BundlePath is the path the bundle of the mesh is published at.
+	BundlePath = "/v1/trust-bundle"
+
+	fetchTimeout = 10 * time.Second
+)
+
+// Controller publishes the bundle of the mesh, periodically fetches the bundles of the peers and
+// stores them all in the configmap.
+type Controller struct {
+	trustDomain string
+	rootCerts   func() []byte
+	peers       []Peer
+	core        corev1.CoreV1Interface
+	namespace   string
+
+	mutex sync.RWMutex
+	// bundles are the last fetched root certificates of the peers by trust domain.
+	bundles map[string][]byte
+}
+
+// NewController creates a new Controller. rootCerts returns the root certificates of the mesh.
+func NewController(trustDomain string, rootCerts func() []byte, peers []Peer, namespace string,
+	core corev1.CoreV1Interface) *Controller {
+	return &Controller{
+		trustDomain: trustDomain,
+		rootCerts:   rootCerts,
+		peers:       peers,
+		core:        core,
+		namespace:   namespace,
+		bundles:     map[string][]byte{},
+	}
+}
+
+// Run loads the bundles stored by the previous instance, then refreshes them at the interval until
+// stopCh is closed.
+func (c *Controller) Run(interval time.Duration, stopCh <-chan struct{}) {
+	if stored, err := Get(c.namespace, c.core); err != nil {
+		log.Warnf("Failed to load the stored trust bundles: %v", err)
+	} else {
+		c.mutex.Lock()
+		for _, peer := range c.peers {
+			if rootCerts, ok := stored[peer.TrustDomain]; ok {
+				c.bundles[peer.TrustDomain] = rootCerts
+			}
+		}
+		c.mutex.Unlock()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.refresh()
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// PeerRootCerts returns the root certificates of all the peers, ordered by trust domain. It returns
+// nil if the controller is nil.
+func (c *Controller) PeerRootCerts() []byte {
+	if c == nil {
+		return nil
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var trustDomains []string
+	for trustDomain := range c.bundles {
+		trustDomains = append(trustDomains, trustDomain)
+	}
+	sort.Strings(trustDomains)
+	var merged []byte
+	for _, trustDomain := range trustDomains {
+		merged = MergeRootCerts(merged, c.bundles[trustDomain])
+	}
+	return merged
+}
+
+// ServeHTTP serves the bundle of the mesh.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(Bundle{TrustDomain: c.trustDomain, RootCerts: string(c.rootCerts())})
+}
+
+// refresh fetches the bundles of the peers and stores them in the configmap. The last bundle of a
+// peer is kept when it cannot be fetched.
+func (c *Controller) refresh() {
+	for _, peer := range c.peers {
+		rootCerts, err := c.fetch(peer)
+		if err != nil {
+			log.Warnf("Failed to fetch the trust bundle of %s: %v", peer.TrustDomain, err)
+			continue
+		}
+		c.mutex.Lock()
+		if !bytes.Equal(c.bundles[peer.TrustDomain], rootCerts) {
+			log.Infof("Updated the trust bundle of %s", peer.TrustDomain)
+			c.bundles[peer.TrustDomain] = rootCerts
+		}
+		c.mutex.Unlock()
+	}
+
+	bundles := map[string][]byte{c.trustDomain: c.rootCerts()}
+	c.mutex.RLock()
+	for trustDomain, rootCerts := range c.bundles {
+		bundles[trustDomain] = rootCerts
+	}
+	c.mutex.RUnlock()
+	if err := c.store(bundles); err != nil {
+		log.Errorf("Failed to store the trust bundles: %v", err)
+	}
+}
+
+// fetch fetches the bundle of the peer. The endpoint is authenticated by the bootstrap certificates
+// or the last bundle of the peer, so that the peer can rotate its root after the bootstrap.
+func (c *Controller) fetch(peer Peer) ([]byte, error) {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(peer.bootstrapCerts)
+	c.mutex.RLock()
+	pool.AppendCertsFromPEM(c.bundles[peer.TrustDomain])
+	c.mutex.RUnlock()
+
+	client := &http.Client{
+		Timeout:   fetchTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+	resp, err := client.Get(peer.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(body, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse the trust bundle: %v", err)
+	}
+	if bundle.TrustDomain != peer.TrustDomain {
+		return nil, fmt.Errorf("the trust bundle is for %q", bundle.TrustDomain)
+	}
+	if _, err := ParseRootCerts([]byte(bundle.RootCerts)); err != nil {
+		return nil, fmt.Errorf("invalid root certificates in the trust bundle: %v", err)
+	}
+	return []byte(bundle.RootCerts), nil
+}
+
+func (c *Controller) store(bundles map[string][]byte) error {
+	values := map[string]string{}
+	for trustDomain, rootCerts := range bundles {
+		values[trustDomain] = string(rootCerts)
+	}
+	value, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	configmap, err := c.core.ConfigMaps(c.namespace).Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		_, err = c.core.ConfigMaps(c.namespace).Create(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapName,
+				Namespace: c.namespace,
+			},
+			Data: map[string]string{BundlesKey: string(value)},
+		})
+		return err
+	}
+	if configmap.Data[BundlesKey] == string(value) {
+		return nil
+	}
+	if configmap.Data == nil {
+		configmap.Data = map[string]string{}
+	}
+	configmap.Data[BundlesKey] = string(value)
+	_, err = c.core.ConfigMaps(c.namespace).Update(configmap)
+	return err
+}
+
+// Get gets the PEM-encoded root certificates by trust domain from the configmap. It is empty if the
+// configmap does not exist.
+func Get(namespace string, core corev1.CoreV1Interface) (map[string][]byte, error) {
+	configmap, err := core.ConfigMaps(namespace).Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return map[string][]byte{}, nil
+		}
+		return nil, fmt.Errorf("failed to get trust bundles: %v", err)
+	}
+	return ParseBundles([]byte(configmap.Data[BundlesKey]))
+}
+
+// ParseBundles parses the JSON object of the PEM-encoded root certificates by trust domain.
+func ParseBundles(value []byte) (map[string][]byte, error) {
+	bundles := map[string][]byte{}
+	if len(bytes.TrimSpace(value)) == 0 {
+		return bundles, nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal(value, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse trust bundles: %v", err)
+	}
+	for trustDomain, rootCerts := range values {
+		bundles[trustDomain] = []byte(rootCerts)
+	}
+	return bundles, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustbundle
+
+import (
+	"bytes"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestControllerRefresh(t *testing.T) {
+	rootCerts := genRootCert(t, true)
+	peerRootCerts := genRootCert(t, true)
+
+	peer := NewController("east.example.com", func() []byte { return peerRootCerts }, nil, "istio-system",
+		fake.NewSimpleClientset().CoreV1())
+	server := httptest.NewTLSServer(peer)
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	core := fake.NewSimpleClientset().CoreV1()
+	c := NewController("west.example.com", func() []byte { return rootCerts }, []Peer{
+		{TrustDomain: "east.example.com", Endpoint: server.URL + BundlePath, bootstrapCerts: serverCert},
+		// The bundle is not trusted without the pinned bootstrap certificates.
+		{TrustDomain: "north.example.com", Endpoint: server.URL + BundlePath, bootstrapCerts: rootCerts},
+	}, "istio-system", core)
+	c.refresh()
+
+	if got := c.PeerRootCerts(); !bytes.Equal(got, peerRootCerts) {
+		t.Errorf("PeerRootCerts() = %q, expected %q", got, peerRootCerts)
+	}
+	bundles, err := Get("istio-system", core)
+	if err != nil {
+		t.Fatalf("Failed to get the stored trust bundles: %v", err)
+	}
+	if len(bundles) != 2 || !bytes.Equal(bundles["west.example.com"], rootCerts) ||
+		!bytes.Equal(bundles["east.example.com"], peerRootCerts) {
+		t.Errorf("Unexpected stored trust bundles %q", bundles)
+	}
+
+	// The last bundle is kept when the peer is unavailable.
+	server.Close()
+	c.refresh()
+	if got := c.PeerRootCerts(); !bytes.Equal(got, peerRootCerts) {
+		t.Errorf("PeerRootCerts() after the peer is down = %q, expected %q", got, peerRootCerts)
+	}
+}
+
+func TestControllerRejectsOtherTrustDomain(t *testing.T) {
+	peer := NewController("east.example.com", func() []byte { return genRootCert(t, true) }, nil, "istio-system",
+		fake.NewSimpleClientset().CoreV1())
+	server := httptest.NewTLSServer(peer)
+	defer server.Close()
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	c := NewController("west.example.com", func() []byte { return nil }, nil, "istio-system",
+		fake.NewSimpleClientset().CoreV1())
+	if _, err := c.fetch(Peer{TrustDomain: "south.example.com", Endpoint: server.URL + BundlePath,
+		bootstrapCerts: serverCert}); err == nil {
+		t.Error("Fetched the trust bundle of another trust domain")
+	}
+}
+
+func TestPeerRootCertsNilController(t *testing.T) {
+	var c *Controller
+	if got := c.PeerRootCerts(); got != nil {
+		t.Errorf("PeerRootCerts() = %q, expected nil", got)
+	}
+}
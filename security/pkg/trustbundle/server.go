@@ -0,0 +1,106 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustbundle
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// Server publishes the bundle of the mesh over HTTPS, with a server certificate issued by the CA
+// for the hostnames the peers connect to.
+type Server struct {
+	port      int
+	hostnames []string
+	ca        ca.CertificateAuthority
+	certTTL   time.Duration
+	handler   http.Handler
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+}
+
+// NewServer creates a new Server publishing the bundle of the controller.
+func NewServer(port int, hostnames []string, certificateAuthority ca.CertificateAuthority, certTTL time.Duration,
+	bundles *Controller) *Server {
+	mux := http.NewServeMux()
+	mux.Handle(BundlePath, bundles)
+	return &Server{
+		port:      port,
+		hostnames: hostnames,
+		ca:        certificateAuthority,
+		certTTL:   certTTL,
+		handler:   mux,
+	}
+}
+
+// Run starts serving on the port until stopCh is closed.
+func (s *Server) Run(stopCh <-chan struct{}) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		return fmt.Errorf("cannot listen on port %d (error: %v)", s.port, err)
+	}
+	server := &http.Server{
+		Handler:   s.handler,
+		TLSConfig: &tls.Config{GetCertificate: s.getCertificate},
+	}
+	go func() {
+		log.Infof("Publishing the trust bundle on port %d", s.port)
+		if err := server.ServeTLS(listener, "", ""); err != http.ErrServerClosed {
+			log.Errorf("Trust bundle server returns an error: %v", err)
+		}
+	}()
+	go func() {
+		<-stopCh
+		_ = server.Close()
+	}()
+	return nil
+}
+
+// getCertificate returns the server certificate, issuing a new one if there is none yet or it
+// expires within a fifth of its TTL.
+func (s *Server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.certificate != nil && time.Until(s.certificate.Leaf.NotAfter) > s.certTTL/5 {
+		return s.certificate, nil
+	}
+	csrPEM, keyPEM, err := util.GenCSR(util.CertOptions{RSAKeySize: 2048})
+	if err != nil {
+		return nil, err
+	}
+	certPEM, err := s.ca.Sign(csrPEM, s.hostnames, s.certTTL, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue the trust bundle server certificate: %v", err)
+	}
+	_, _, certChainPEM, _ := s.ca.GetCAKeyCertBundle().GetAll()
+	cert, err := tls.X509KeyPair(append(certPEM, certChainPEM...), keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = util.ParsePemEncodedCertificate(certPEM); err != nil {
+		return nil, err
+	}
+	s.certificate = &cert
+	return s.certificate, nil
+}
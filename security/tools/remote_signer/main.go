@@ -0,0 +1,84 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Provide a remote signer serving a local key file, which stands in for a KMS or an HSM when
+// running Citadel with --signing-backend=remote-signer.
+
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	"istio.io/istio/security/pkg/pki/ca/remotesigner"
+)
+
+var (
+	signingKey        = flag.String("signing-key", "", "Private key file (PEM encoded) to sign with.")
+	port              = flag.Int("port", 8070, "Port to serve the remote signer on.")
+	tlsCert           = flag.String("tls-cert", "", "Serving certificate file (PEM encoded).")
+	tlsKey            = flag.String("tls-key", "", "Serving private key file (PEM encoded).")
+	tlsClientRootCert = flag.String("tls-client-root-cert", "",
+		"Root certificates file (PEM encoded) verifying the client certificates of Citadel.")
+	identities = flag.String("allowed-identities", "spiffe://cluster.local/ns/istio-system/sa/istio-citadel-service-account",
+		"The identities of the client certificates allowed to sign, separated by comma.")
+)
+
+func main() {
+	flag.Parse()
+	if len(*signingKey) == 0 {
+		log.Fatalf("--signing-key is required.")
+	}
+	if len(*tlsCert) == 0 || len(*tlsKey) == 0 || len(*tlsClientRootCert) == 0 {
+		log.Fatalf("--tls-cert, --tls-key and --tls-client-root-cert are required.")
+	}
+	if len(*identities) == 0 {
+		log.Fatalf("--allowed-identities is required.")
+	}
+
+	server, err := remotesigner.NewServerFromFile(*signingKey, strings.Split(*identities, ","))
+	if err != nil {
+		log.Fatalf("Failed to load the signing key: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+	if err != nil {
+		log.Fatalf("Failed to load the serving certificate: %v", err)
+	}
+	clientRootCert, err := ioutil.ReadFile(*tlsClientRootCert)
+	if err != nil {
+		log.Fatalf("Failed to read the client root certificates: %v", err)
+	}
+	creds, err := remotesigner.NewServerCredentials(cert, clientRootCert)
+	if err != nil {
+		log.Fatalf("Failed to create the server credentials: %v", err)
+	}
+	grpcServer := grpc.NewServer(grpc.Creds(creds))
+	remotesigner.RegisterRemoteSignerServer(grpcServer, server)
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		log.Fatalf("Failed to listen on port %d: %v", *port, err)
+	}
+	log.Printf("Serving the remote signer on port %d", *port)
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+}
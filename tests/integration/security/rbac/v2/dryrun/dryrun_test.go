@@ -0,0 +1,191 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v2alpha"
+	"github.com/gogo/protobuf/jsonpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/environment"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/util/file"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/test/util/tmpl"
+	"istio.io/istio/tests/integration/security/rbac/util"
+	"istio.io/istio/tests/integration/security/util/connection"
+)
+
+const (
+	rbacV2DryRunRulesTmpl = "testdata/istio-rbac-v2-dryrun-rules.yaml.tmpl"
+
+	shadowAllowedStat = "shadow_allowed"
+	shadowDeniedStat  = "shadow_denied"
+)
+
+// TestRBACV2DryRun applies the same AuthorizationPolicy in enforced and in dry-run mode. In dry-run
+// mode the policy is only pushed to the sidecar as shadow rules, so no enforced policy allows the
+// requests and all of them are denied, while the shadow decisions of the policy are recorded in the
+// shadow_allowed and shadow_denied stats of the RBAC filter.
+func TestRBACV2DryRun(t *testing.T) {
+	framework.NewTest(t).
+		RequiresEnvironment(environment.Kube).
+		Run(func(ctx framework.TestContext) {
+			ns := namespace.NewOrFail(t, ctx, "rbacv2-dryrun-test", true)
+			ports := []echo.Port{
+				{
+					Name:        "http",
+					Protocol:    model.ProtocolHTTP,
+					ServicePort: 80,
+				},
+			}
+			a := echoboot.NewOrFail(t, ctx, echo.Config{
+				Service:        "a",
+				Namespace:      ns,
+				Sidecar:        true,
+				ServiceAccount: true,
+				Ports:          ports,
+				Galley:         g,
+				Pilot:          p,
+			})
+			b := echoboot.NewOrFail(t, ctx, echo.Config{
+				Service:        "b",
+				Namespace:      ns,
+				Sidecar:        true,
+				ServiceAccount: true,
+				Ports:          ports,
+				Galley:         g,
+				Pilot:          p,
+			})
+
+			type testCase struct {
+				util.TestCase
+				// expectShadowStat is the RBAC filter stat incremented by the request, if any.
+				expectShadowStat string
+			}
+			newTestCase := func(path string, expectAllowed bool, expectShadowStat string) testCase {
+				return testCase{
+					TestCase: util.TestCase{
+						Request: connection.Checker{
+							From: b,
+							Options: echo.CallOptions{
+								Target:   a,
+								PortName: "http",
+								Scheme:   scheme.HTTP,
+								Path:     path,
+							},
+						},
+						ExpectAllowed: expectAllowed,
+					},
+					expectShadowStat: expectShadowStat,
+				}
+			}
+
+			for _, dryRun := range []bool{false, true} {
+				t.Run(fmt.Sprintf("dryRun=%v", dryRun), func(t *testing.T) {
+					cases := []testCase{
+						newTestCase("/allowed", true, ""),
+						newTestCase("/denied", false, ""),
+					}
+					if dryRun {
+						// Nothing is allowed by an enforced policy, the dry-run decisions are only
+						// recorded in the stats.
+						cases = []testCase{
+							newTestCase("/allowed", false, shadowAllowedStat),
+							newTestCase("/denied", false, shadowDeniedStat),
+						}
+					}
+
+					templateValues := map[string]string{
+						"Namespace": ns.Name(),
+						"DryRun":    fmt.Sprint(dryRun),
+					}
+					policies := tmpl.EvaluateAllOrFail(t, templateValues,
+						file.AsString(t, rbacClusterConfigTmpl),
+						file.AsString(t, rbacV2DryRunRulesTmpl))
+
+					g.ApplyConfigOrFail(t, ns, policies...)
+					defer g.DeleteConfigOrFail(t, ns, policies...)
+
+					// Wait for the policy to be pushed to the sidecar of a, as shadow rules in dry-run mode.
+					a.WorkloadsOrFail(t)[0].Sidecar().WaitForConfigOrFail(t, func(cfg *envoyAdmin.ConfigDump) (bool, error) {
+						return hasRbacPolicy(cfg, dryRun)
+					}, retry.Timeout(60*time.Second))
+
+					for _, tc := range cases {
+						testName := fmt.Sprintf("%s->%s:%s%s[%v]",
+							tc.Request.From.Config().Service,
+							tc.Request.Options.Target.Config().Service,
+							tc.Request.Options.PortName,
+							tc.Request.Options.Path,
+							tc.ExpectAllowed)
+						t.Run(testName, func(t *testing.T) {
+							sidecar := a.WorkloadsOrFail(t)[0].Sidecar()
+							before := rbacStats(sidecar.StatsOrFail(t))
+							retry.UntilSuccessOrFail(t, tc.CheckRBACRequest, retry.Delay(time.Second), retry.Timeout(10*time.Second))
+							if tc.expectShadowStat == "" {
+								return
+							}
+							retry.UntilSuccessOrFail(t, func() error {
+								after := rbacStats(sidecar.StatsOrFail(t))
+								if after[tc.expectShadowStat] <= before[tc.expectShadowStat] {
+									return fmt.Errorf("%s not incremented: before %d, after %d",
+										tc.expectShadowStat, before[tc.expectShadowStat], after[tc.expectShadowStat])
+								}
+								return nil
+							}, retry.Delay(time.Second), retry.Timeout(10*time.Second))
+						})
+					}
+				})
+			}
+		})
+}
+
+// hasRbacPolicy returns true if the config dump includes the RBAC policy of the AuthorizationPolicy,
+// and has shadow rules only if the policy is in dry-run mode.
+func hasRbacPolicy(cfg *envoyAdmin.ConfigDump, dryRun bool) (bool, error) {
+	dump, err := (&jsonpb.Marshaler{OrigName: true}).MarshalToString(cfg)
+	if err != nil {
+		return false, err
+	}
+	if !strings.Contains(dump, "authz-policy-authz-policy-access-a-allowed-allow[0]") {
+		return false, fmt.Errorf("RBAC policy not found in the config dump")
+	}
+	if hasShadowRules := strings.Contains(dump, "shadow_rules"); hasShadowRules != dryRun {
+		return false, fmt.Errorf("got shadow rules %v, expected %v", hasShadowRules, dryRun)
+	}
+	return true, nil
+}
+
+// rbacStats sums the stats of the RBAC filters of the HTTP connection managers, by stat name without
+// the "http.<stat_prefix>.rbac." prefix.
+func rbacStats(stats map[string]int) map[string]int {
+	out := make(map[string]int)
+	for name, value := range stats {
+		if i := strings.Index(name, ".rbac."); i >= 0 && strings.HasPrefix(name, "http.") {
+			out[name[i+len(".rbac."):]] += value
+		}
+	}
+	return out
+}
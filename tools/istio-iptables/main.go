@@ -0,0 +1,276 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/pkg/cmd"
+	"istio.io/istio/pkg/env"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/tools/istio-iptables/pkg/capture"
+)
+
+var (
+	clusterConfigVar = env.RegisterStringVar("ISTIO_CLUSTER_CONFIG", "/var/lib/istio/envoy/cluster.env",
+		"File with the cluster wide settings, e.g. the service CIDR, pushed to all the VMs of the cluster")
+	sidecarConfigVar = env.RegisterStringVar("ISTIO_SIDECAR_CONFIG", "/var/lib/istio/envoy/sidecar.env",
+		"File with the per machine settings, e.g. the inbound ports")
+	envoyPortVar              = env.RegisterIntVar("ENVOY_PORT", 15001, "")
+	envoyUserVar              = env.RegisterStringVar("ENVOY_USER", "istio-proxy", "")
+	inboundCapturePortVar     = env.RegisterIntVar("INBOUND_CAPTURE_PORT", 0, "")
+	interceptionModeVar       = env.RegisterStringVar("ISTIO_INBOUND_INTERCEPTION_MODE", "", "")
+	tproxyMarkVar             = env.RegisterIntVar("ISTIO_INBOUND_TPROXY_MARK", 1337, "")
+	tproxyRouteTableVar       = env.RegisterIntVar("ISTIO_INBOUND_TPROXY_ROUTE_TABLE", 133, "")
+	inboundPortsVar           = env.RegisterStringVar("ISTIO_INBOUND_PORTS", "", "")
+	localExcludePortsVar      = env.RegisterStringVar("ISTIO_LOCAL_EXCLUDE_PORTS", "", "")
+	serviceCIDRVar            = env.RegisterStringVar("ISTIO_SERVICE_CIDR", "", "")
+	serviceExcludeCIDRVar     = env.RegisterStringVar("ISTIO_SERVICE_EXCLUDE_CIDR", "", "")
+	disableLocalLoopbackVar   = env.RegisterStringVar("DISABLE_REDIRECTION_ON_LOCAL_LOOPBACK", "", "")
+	dnsCapturePortVar         = env.RegisterIntVar("ISTIO_DNS_CAPTURE_PORT", 0, "")
+	defaultProxyUID           = "1337"
+	defaultAdditionalProxyUID = "0"
+
+	flags = struct {
+		proxyPort                    int
+		proxyUID                     string
+		proxyGID                     string
+		inboundInterceptionMode      string
+		inboundPortsInclude          string
+		inboundPortsExclude          string
+		outboundIPRangesInclude      string
+		outboundIPRangesExclude      string
+		kubevirtInterfaces           string
+		enableInboundIPv6            bool
+		dryRun                       bool
+		skipEnvFiles                 bool
+		disableLocalLoopbackRedirect bool
+		dnsCapturePort               int
+	}{}
+
+	rootCmd = &cobra.Command{
+		Use:   "istio-iptables [clean]",
+		Short: "Set up the iptables rules redirecting the traffic of the pod or VM to Envoy.",
+		Long: `Set up the iptables rules redirecting the traffic of the pod or VM to Envoy.
+
+The flags default to the environment variables used by istio-iptables.sh, which are also read from
+$ISTIO_CLUSTER_CONFIG and $ISTIO_SIDECAR_CONFIG. With the "clean" argument, only the rules of a
+previous run are removed.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			clean := false
+			if len(args) == 1 {
+				if args[0] != "clean" {
+					return fmt.Errorf("unknown argument %q", args[0])
+				}
+				clean = true
+			}
+			if !flags.skipEnvFiles {
+				for _, file := range []string{clusterConfigVar.Get(), sidecarConfigVar.Get()} {
+					if err := loadEnvFile(file); err != nil {
+						return err
+					}
+				}
+			}
+
+			config, err := buildConfig(c)
+			if err != nil {
+				return err
+			}
+			if clean {
+				if flags.dryRun {
+					for _, command := range capture.CleanupCommands(config.EnableInboundIPv6) {
+						fmt.Fprintln(c.OutOrStdout(), strings.Join(command, " "))
+					}
+					return nil
+				}
+				capture.Cleanup(config.EnableInboundIPv6)
+				fmt.Fprintln(c.OutOrStdout(), "Only cleaning, no new rules added")
+				return nil
+			}
+
+			if err := config.Validate(); err != nil {
+				return err
+			}
+			rules := capture.Build(config)
+			fmt.Fprint(c.OutOrStdout(), rules.String())
+			if flags.dryRun {
+				return nil
+			}
+			return capture.Apply(rules)
+		},
+	}
+)
+
+func init() {
+	f := rootCmd.PersistentFlags()
+	f.IntVarP(&flags.proxyPort, "envoy-port", "p", envoyPortVar.Get(),
+		"Envoy port to which redirect all TCP traffic (default $ENVOY_PORT)")
+	f.StringVarP(&flags.proxyUID, "proxy-uid", "u", "",
+		"Comma separated list of UIDs of the users for which the redirection is not applied. Typically, "+
+			"this is the UID of the proxy container (default to uid of $ENVOY_USER and root)")
+	f.StringVarP(&flags.proxyGID, "proxy-gid", "g", "",
+		"Comma separated list of GIDs for which the redirection is not applied (same default value as --proxy-uid)")
+	f.StringVarP(&flags.inboundInterceptionMode, "inbound-interception-mode", "m", "",
+		`The mode used to redirect inbound connections to Envoy, either "REDIRECT" or "TPROXY" `+
+			"(default $ISTIO_INBOUND_INTERCEPTION_MODE)")
+	f.StringVarP(&flags.inboundPortsInclude, "inbound-ports", "b", "",
+		`Comma separated list of inbound ports for which traffic is to be redirected to Envoy. The wildcard "*" `+
+			"redirects all ports. An empty list disables all inbound redirection (default $ISTIO_INBOUND_PORTS)")
+	f.StringVarP(&flags.inboundPortsExclude, "local-exclude-ports", "d", "",
+		"Comma separated list of inbound ports to be excluded from redirection to Envoy. Only applies when all "+
+			"inbound traffic is redirected (default $ISTIO_LOCAL_EXCLUDE_PORTS)")
+	f.StringVarP(&flags.outboundIPRangesInclude, "service-cidr", "i", "",
+		`Comma separated list of IP ranges in CIDR form to redirect to Envoy. The wildcard "*" redirects all `+
+			"outbound traffic. An empty list disables all outbound redirection (default $ISTIO_SERVICE_CIDR)")
+	f.StringVarP(&flags.outboundIPRangesExclude, "service-exclude-cidr", "x", "",
+		"Comma separated list of IP ranges in CIDR form to be excluded from redirection. Only applies when all "+
+			"outbound traffic is redirected (default $ISTIO_SERVICE_EXCLUDE_CIDR)")
+	f.StringVarP(&flags.kubevirtInterfaces, "kube-virt-interfaces", "k", "",
+		"Comma separated list of virtual interfaces whose inbound traffic (from VM) will be treated as outbound")
+	f.BoolVar(&flags.enableInboundIPv6, "enable-inbound-ipv6", false,
+		"Capture IPv6 traffic, which is otherwise rejected (default to whether the host IP is an IPv6 address)")
+	f.BoolVar(&flags.disableLocalLoopbackRedirect, "disable-redirection-on-local-loopback", false,
+		"Do not redirect the traffic sent by the application to itself (default $DISABLE_REDIRECTION_ON_LOCAL_LOOPBACK)")
+	f.IntVar(&flags.dnsCapturePort, "dns-capture-port", 0,
+		"Port of the DNS proxy of pilot-agent to which the outbound DNS queries over UDP and TCP are redirected. "+
+			"The queries are not redirected if 0 (default $ISTIO_DNS_CAPTURE_PORT)")
+	f.BoolVar(&flags.skipEnvFiles, "skip-env-files", false,
+		"Do not read the settings from $ISTIO_CLUSTER_CONFIG and $ISTIO_SIDECAR_CONFIG")
+	f.BoolVarP(&flags.dryRun, "dry-run", "n", false, "Print the rules without applying them")
+
+	cmd.AddFlags(rootCmd)
+}
+
+// buildConfig returns the config of the traffic capture, using the environment variables for the
+// flags which are not set.
+func buildConfig(c *cobra.Command) (*capture.Config, error) {
+	changed := c.Flags().Changed
+	stringFlag := func(name, value string, v env.StringVar) string {
+		if changed(name) {
+			return value
+		}
+		return v.Get()
+	}
+
+	config := &capture.Config{
+		ProxyPort:                         flags.proxyPort,
+		InboundCapturePort:                inboundCapturePortVar.Get(),
+		InboundInterceptionMode:           stringFlag("inbound-interception-mode", flags.inboundInterceptionMode, interceptionModeVar),
+		InboundTProxyMark:                 tproxyMarkVar.Get(),
+		InboundTProxyRouteTable:           tproxyRouteTableVar.Get(),
+		InboundPortsInclude:               capture.SplitList(stringFlag("inbound-ports", flags.inboundPortsInclude, inboundPortsVar)),
+		InboundPortsExclude:               capture.SplitList(stringFlag("local-exclude-ports", flags.inboundPortsExclude, localExcludePortsVar)),
+		OutboundIPRangesInclude:           capture.SplitList(stringFlag("service-cidr", flags.outboundIPRangesInclude, serviceCIDRVar)),
+		OutboundIPRangesExclude:           capture.SplitList(stringFlag("service-exclude-cidr", flags.outboundIPRangesExclude, serviceExcludeCIDRVar)),
+		KubevirtInterfaces:                capture.SplitList(flags.kubevirtInterfaces),
+		DisableRedirectionOnLocalLoopback: flags.disableLocalLoopbackRedirect,
+		EnableInboundIPv6:                 flags.enableInboundIPv6,
+		DNSCapturePort:                    flags.dnsCapturePort,
+	}
+	if !changed("envoy-port") {
+		config.ProxyPort = envoyPortVar.Get()
+	}
+	if !changed("disable-redirection-on-local-loopback") {
+		config.DisableRedirectionOnLocalLoopback = disableLocalLoopbackVar.Get() != ""
+	}
+	if !changed("dns-capture-port") {
+		config.DNSCapturePort = dnsCapturePortVar.Get()
+	}
+	if !changed("enable-inbound-ipv6") {
+		config.EnableInboundIPv6 = isHostIPv6()
+	}
+
+	if flags.proxyUID != "" {
+		config.ProxyUIDs = capture.SplitList(flags.proxyUID)
+	} else {
+		// Default to the UID of ENVOY_USER, and root for the CA agent.
+		uid := defaultProxyUID
+		if u, err := user.Lookup(envoyUserVar.Get()); err == nil {
+			uid = u.Uid
+		}
+		config.ProxyUIDs = []string{uid, defaultAdditionalProxyUID}
+	}
+	// For TPROXY as its uid and gid are same.
+	config.ProxyGIDs = config.ProxyUIDs
+	if flags.proxyGID != "" {
+		config.ProxyGIDs = capture.SplitList(flags.proxyGID)
+	}
+	return config, nil
+}
+
+// isHostIPv6 returns whether the IP address of the host is an IPv6 address.
+func isHostIPv6() bool {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false
+	}
+	ips, err := net.LookupIP(hostname)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	return ips[0].To4() == nil
+}
+
+// loadEnvFile sets the environment variables defined in a file sourced by istio-iptables.sh, if
+// it exists. Only simple KEY=VALUE assignments are supported.
+func loadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			log.Warnf("Ignoring line %q of %s", line, path)
+			continue
+		}
+		value := parts[1]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, "'")
+		}
+		if err := os.Setenv(strings.TrimSpace(parts[0]), value); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(-1)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"istio.io/istio/pkg/log"
+)
+
+// Runner runs a command with the given standard input, and returns its combined output.
+type Runner func(cmd []string, stdin string) (string, error)
+
+// Cleanup removes the rules installed by a previous run.
+func Cleanup(withIPv6 bool) {
+	for _, cmd := range CleanupCommands(withIPv6) {
+		// The chains and rules may not exist.
+		_, _ = run(cmd, "")
+	}
+}
+
+// Apply installs the ruleset, after removing the rules installed by a previous run.
+func Apply(r *Ruleset) error {
+	return ApplyWith(r, run)
+}
+
+// ApplyWith installs the ruleset with the given runner, e.g. to run the commands in another
+// network namespace.
+func ApplyWith(r *Ruleset, run Runner) error {
+	for _, cmd := range r.Cleanup {
+		// The chains and rules may not exist.
+		_, _ = run(cmd, "")
+	}
+	if out, err := run([]string{"iptables-restore", "--noflush"}, r.IPv4); err != nil {
+		return fmt.Errorf("iptables-restore failed: %v: %s", err, out)
+	}
+	if out, err := run([]string{"ip6tables-restore", "--noflush"}, r.IPv6); err != nil {
+		if r.ipv6Enabled {
+			return fmt.Errorf("ip6tables-restore failed: %v: %s", err, out)
+		}
+		log.Warnf("Failed to reject inbound IPv6 traffic: %v: %s", err, out)
+	}
+	for _, cmd := range r.Routes {
+		if out, err := run(cmd, ""); err != nil {
+			// The routes of a previous run are not removed.
+			if strings.Contains(out, "File exists") {
+				continue
+			}
+			return fmt.Errorf("%s failed: %v: %s", strings.Join(cmd, " "), err, out)
+		}
+	}
+	return nil
+}
+
+// NamespaceRunner returns a runner executing the commands in the network namespace at the given
+// path, e.g. /proc/<pid>/ns/net.
+func NamespaceRunner(netns string) Runner {
+	return func(cmd []string, stdin string) (string, error) {
+		return run(append([]string{"nsenter", "--net=" + netns, "--"}, cmd...), stdin)
+	}
+}
+
+func run(cmd []string, stdin string) (string, error) {
+	log.Infof("Running %s", strings.Join(cmd, " "))
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Stdin = strings.NewReader(stdin)
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	err := c.Run()
+	return out.String(), err
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capture builds the iptables rules redirecting the traffic of a pod or VM to Envoy.
+// It is the Go implementation of istio-iptables.sh.
+package capture
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+const (
+	// InterceptionModeRedirect redirects inbound traffic to Envoy with the REDIRECT target.
+	InterceptionModeRedirect = "REDIRECT"
+	// InterceptionModeTProxy redirects inbound traffic to Envoy with the TPROXY target, which
+	// preserves the source address of the connections.
+	InterceptionModeTProxy = "TPROXY"
+
+	// Wildcard is the value of the inbound ports and outbound IP ranges to capture all traffic.
+	Wildcard = "*"
+)
+
+// Config holds the inputs of the traffic capture, with the semantics of the istio-iptables.sh flags.
+type Config struct {
+	// ProxyPort is the port to which all outbound TCP traffic is redirected.
+	ProxyPort int
+	// InboundCapturePort is the port to which inbound TCP traffic is redirected, defaults to ProxyPort.
+	InboundCapturePort int
+	// ProxyUIDs and ProxyGIDs are the users and groups whose traffic is not redirected.
+	ProxyUIDs []string
+	ProxyGIDs []string
+	// InboundInterceptionMode is either REDIRECT or TPROXY, REDIRECT if empty.
+	InboundInterceptionMode string
+	// InboundTProxyMark and InboundTProxyRouteTable are used in TPROXY mode to route the marked
+	// inbound packets to the loopback interface.
+	InboundTProxyMark       int
+	InboundTProxyRouteTable int
+	// InboundPortsInclude are the inbound ports redirected to Envoy, or "*" for all of them.
+	// Inbound traffic is not captured if empty.
+	InboundPortsInclude []string
+	// InboundPortsExclude are the inbound ports excluded from the redirection when all ports are captured.
+	InboundPortsExclude []string
+	// OutboundIPRangesInclude are the CIDRs redirected to Envoy, or "*" for all of them.
+	// Outbound traffic is not captured if empty.
+	OutboundIPRangesInclude []string
+	// OutboundIPRangesExclude are the CIDRs excluded from the redirection when all outbound
+	// traffic is captured.
+	OutboundIPRangesExclude []string
+	// KubevirtInterfaces are the virtual interfaces whose inbound traffic is treated as outbound.
+	KubevirtInterfaces []string
+	// EnableInboundIPv6 configures ip6tables to capture IPv6 traffic, which is otherwise rejected.
+	EnableInboundIPv6 bool
+	// DisableRedirectionOnLocalLoopback disables the redirection of the traffic sent by the
+	// application to itself through a non loopback address.
+	DisableRedirectionOnLocalLoopback bool
+	// DNSCapturePort is the port of the DNS proxy of pilot-agent, to which the outbound DNS
+	// queries over UDP and TCP are redirected. They are not redirected if 0.
+	DNSCapturePort int
+}
+
+// SplitList splits a comma separated list, ignoring the empty items.
+func SplitList(s string) []string {
+	out := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Validate returns an error if the config can't be turned into rules.
+func (c *Config) Validate() error {
+	var errs error
+	if err := validatePort(c.ProxyPort); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid proxy port: %v", err))
+	}
+	if c.InboundCapturePort != 0 {
+		if err := validatePort(c.InboundCapturePort); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid inbound capture port: %v", err))
+		}
+	}
+	if c.DNSCapturePort != 0 {
+		if err := validatePort(c.DNSCapturePort); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid DNS capture port: %v", err))
+		}
+	}
+	switch c.InboundInterceptionMode {
+	case "", InterceptionModeRedirect, InterceptionModeTProxy:
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("invalid inbound interception mode %q, must be %s or %s",
+			c.InboundInterceptionMode, InterceptionModeRedirect, InterceptionModeTProxy))
+	}
+	for _, id := range append(append([]string{}, c.ProxyUIDs...), c.ProxyGIDs...) {
+		if _, err := strconv.ParseUint(id, 10, 32); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid user or group ID %q", id))
+		}
+	}
+	if !isWildcard(c.InboundPortsInclude) {
+		for _, port := range c.InboundPortsInclude {
+			errs = appendPortError(errs, "inbound port", port)
+		}
+	}
+	for _, port := range c.InboundPortsExclude {
+		errs = appendPortError(errs, "excluded inbound port", port)
+	}
+	if !isWildcard(c.OutboundIPRangesInclude) {
+		for _, cidr := range c.OutboundIPRangesInclude {
+			if _, _, err := parseIPRange(cidr); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+	for _, cidr := range c.OutboundIPRangesExclude {
+		if _, _, err := parseIPRange(cidr); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+func validatePort(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("%d is out of range", port)
+	}
+	return nil
+}
+
+func appendPortError(errs error, name, port string) error {
+	p, err := strconv.Atoi(port)
+	if err == nil {
+		err = validatePort(p)
+	}
+	if err != nil {
+		return multierror.Append(errs, fmt.Errorf("invalid %s %q", name, port))
+	}
+	return errs
+}
+
+func isWildcard(list []string) bool {
+	return len(list) == 1 && list[0] == Wildcard
+}
+
+// parseIPRange parses an IP address or a CIDR, and returns it in CIDR form along with whether it
+// is an IPv6 range.
+func parseIPRange(s string) (string, bool, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		if ip.To4() != nil {
+			return s + "/32", false, nil
+		}
+		return s + "/128", true, nil
+	}
+	ip, _, err := net.ParseCIDR(s)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid IP range %q", s)
+	}
+	return s, ip.To4() == nil, nil
+}
+
+// splitIPRanges returns the IPv4 and IPv6 ranges of the list, which must be valid.
+// The wildcard is kept in both lists.
+func splitIPRanges(ranges []string) ([]string, []string) {
+	if isWildcard(ranges) {
+		return ranges, ranges
+	}
+	var ipv4, ipv6 []string
+	for _, r := range ranges {
+		cidr, isIPv6, err := parseIPRange(r)
+		if err != nil {
+			continue
+		}
+		if isIPv6 {
+			ipv6 = append(ipv6, cidr)
+		} else {
+			ipv4 = append(ipv4, cidr)
+		}
+	}
+	return ipv4, ipv6
+}
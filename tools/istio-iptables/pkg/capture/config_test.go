@@ -0,0 +1,86 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	cases := map[string][]string{
+		"":            {},
+		"*":           {"*"},
+		"80, 8080,,":  {"80", "8080"},
+		" 10.0.0.0/8": {"10.0.0.0/8"},
+	}
+	for in, want := range cases {
+		if got := SplitList(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitList(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		config func(c *Config)
+		errs   []string
+	}{
+		{name: "valid", config: func(c *Config) {
+			c.InboundPortsInclude = []string{"*"}
+			c.OutboundIPRangesInclude = []string{"10.0.0.0/8", "10.0.0.1", "fd00::/8"}
+		}},
+		{name: "invalid proxy port", config: func(c *Config) { c.ProxyPort = 0 }, errs: []string{"invalid proxy port"}},
+		{name: "invalid capture port", config: func(c *Config) { c.InboundCapturePort = 70000 },
+			errs: []string{"invalid inbound capture port"}},
+		{name: "invalid mode", config: func(c *Config) { c.InboundInterceptionMode = "DNAT" },
+			errs: []string{`invalid inbound interception mode "DNAT"`}},
+		{name: "invalid uid", config: func(c *Config) { c.ProxyUIDs = []string{"istio-proxy"} },
+			errs: []string{`invalid user or group ID "istio-proxy"`}},
+		{name: "invalid ports", config: func(c *Config) {
+			c.InboundPortsInclude = []string{"80", "http"}
+			c.InboundPortsExclude = []string{"0"}
+		}, errs: []string{`invalid inbound port "http"`, `invalid excluded inbound port "0"`}},
+		{name: "wildcard mixed with ports", config: func(c *Config) { c.InboundPortsInclude = []string{"*", "80"} },
+			errs: []string{`invalid inbound port "*"`}},
+		{name: "invalid ranges", config: func(c *Config) {
+			c.OutboundIPRangesInclude = []string{"10.0.0.0/33"}
+			c.OutboundIPRangesExclude = []string{"*"}
+		}, errs: []string{`invalid IP range "10.0.0.0/33"`, `invalid IP range "*"`}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := defaultConfig()
+			tc.config(config)
+			err := config.Validate()
+			if len(tc.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %v", tc.errs)
+			}
+			for _, want := range tc.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Chains created by the traffic capture.
+const (
+	ChainRedirect   = "ISTIO_REDIRECT"
+	ChainInRedirect = "ISTIO_IN_REDIRECT"
+	ChainInbound    = "ISTIO_INBOUND"
+	ChainOutput     = "ISTIO_OUTPUT"
+	ChainDivert     = "ISTIO_DIVERT"
+	ChainTProxy     = "ISTIO_TPROXY"
+	ChainDNS        = "ISTIO_DNS"
+)
+
+const (
+	tableNat    = "nat"
+	tableMangle = "mangle"
+	tableFilter = "filter"
+)
+
+// Ruleset holds everything needed to set up the traffic capture.
+type Ruleset struct {
+	// Cleanup are the iptables and ip6tables commands removing the rules of a previous run. They
+	// fail if there is nothing to remove, so their errors must be ignored.
+	Cleanup [][]string
+	// IPv4 and IPv6 are the inputs of iptables-restore and ip6tables-restore, to be used with
+	// --noflush so that the rules of the built-in chains are preserved.
+	IPv4 string
+	IPv6 string
+	// Routes are the ip commands routing the packets marked in TPROXY mode to the loopback interface.
+	Routes [][]string
+
+	// ipv6Enabled is false if the IPv6 rules only reject the inbound traffic, in which case they
+	// are applied on a best effort basis as IPv6 may not be supported by the host.
+	ipv6Enabled bool
+}
+
+// table accumulates the chains and the rules of an iptables table, in the iptables-restore format.
+type table struct {
+	name   string
+	chains []string
+	rules  []string
+}
+
+// tables accumulates the tables of an IP family in the order they are first used.
+type tables struct {
+	tables []*table
+}
+
+func (t *tables) get(name string) *table {
+	for _, tbl := range t.tables {
+		if tbl.name == name {
+			return tbl
+		}
+	}
+	tbl := &table{name: name}
+	t.tables = append(t.tables, tbl)
+	return tbl
+}
+
+// newChain declares a new chain, which is created empty by iptables-restore.
+func (t *tables) newChain(table, chain string) {
+	tbl := t.get(table)
+	tbl.chains = append(tbl.chains, chain)
+}
+
+// rule adds an iptables command, without the table, e.g. "-A", "OUTPUT", "-j", "RETURN".
+func (t *tables) rule(table string, args ...string) {
+	tbl := t.get(table)
+	tbl.rules = append(tbl.rules, strings.Join(args, " "))
+}
+
+func (t *tables) String() string {
+	var b bytes.Buffer
+	for _, tbl := range t.tables {
+		fmt.Fprintf(&b, "*%s\n", tbl.name)
+		for _, chain := range tbl.chains {
+			fmt.Fprintf(&b, ":%s - [0:0]\n", chain)
+		}
+		for _, rule := range tbl.rules {
+			fmt.Fprintln(&b, rule)
+		}
+		fmt.Fprintln(&b, "COMMIT")
+	}
+	return b.String()
+}
+
+// family holds the differences between the IPv4 and IPv6 rules.
+type family struct {
+	command  string
+	loopback string
+}
+
+var (
+	ipv4 = family{command: "iptables", loopback: "127.0.0.1/32"}
+	ipv6 = family{command: "ip6tables", loopback: "::1/128"}
+)
+
+// Build returns the rules capturing the traffic as described by the config, which must be valid.
+func Build(c *Config) *Ruleset {
+	ipv4Include, ipv6Include := splitIPRanges(c.OutboundIPRangesInclude)
+	ipv4Exclude, ipv6Exclude := splitIPRanges(c.OutboundIPRangesExclude)
+
+	r := &Ruleset{
+		Cleanup:     CleanupCommands(c.EnableInboundIPv6),
+		ipv6Enabled: c.EnableInboundIPv6,
+	}
+	v4 := &tables{}
+	r.Routes = buildInbound(c, v4, c.InboundInterceptionMode == InterceptionModeTProxy)
+	buildDNS(c, v4)
+	buildOutbound(c, v4, ipv4, ipv4Include, ipv4Exclude)
+	r.IPv4 = v4.String()
+
+	v6 := &tables{}
+	if c.EnableInboundIPv6 {
+		// TPROXY is not supported for IPv6, inbound traffic is always redirected.
+		buildInbound(c, v6, false)
+		buildDNS(c, v6)
+		buildOutbound(c, v6, ipv6, ipv6Include, ipv6Exclude)
+	} else {
+		// Drop all inbound traffic except established connections.
+		v6.rule(tableFilter, "-F", "INPUT")
+		v6.rule(tableFilter, "-A", "INPUT", "-m", "state", "--state", "ESTABLISHED", "-j", "ACCEPT")
+		v6.rule(tableFilter, "-A", "INPUT", "-i", "lo", "-d", "::1", "-j", "ACCEPT")
+		v6.rule(tableFilter, "-A", "INPUT", "-j", "REJECT")
+	}
+	r.IPv6 = v6.String()
+	return r
+}
+
+// buildInbound adds the rules redirecting the inbound traffic to Envoy, and returns the ip
+// commands needed in TPROXY mode.
+func buildInbound(c *Config, t *tables, tproxy bool) [][]string {
+	proxyPort := strconv.Itoa(c.ProxyPort)
+	inboundCapturePort := proxyPort
+	if c.InboundCapturePort != 0 {
+		inboundCapturePort = strconv.Itoa(c.InboundCapturePort)
+	}
+
+	// Create a new chain for redirecting outbound traffic to the common Envoy port.
+	// In both chains, '-j RETURN' bypasses Envoy and '-j ISTIO_REDIRECT' redirects to Envoy.
+	t.newChain(tableNat, ChainRedirect)
+	t.rule(tableNat, "-A", ChainRedirect, "-p", "tcp", "-j", "REDIRECT", "--to-port", proxyPort)
+	// Use this chain also for redirecting inbound traffic to the common Envoy port when not using TPROXY.
+	t.newChain(tableNat, ChainInRedirect)
+	t.rule(tableNat, "-A", ChainInRedirect, "-p", "tcp", "-j", "REDIRECT", "--to-port", inboundCapturePort)
+
+	// If not set, no inbound port is intercepted.
+	if len(c.InboundPortsInclude) == 0 {
+		return nil
+	}
+
+	var routes [][]string
+	inboundTable := tableNat
+	if tproxy {
+		mark := strconv.Itoa(c.InboundTProxyMark)
+		routeTable := strconv.Itoa(c.InboundTProxyRouteTable)
+		// Any packet entering ISTIO_DIVERT gets marked, so that it is routed to the loopback
+		// interface in order to get redirected to Envoy.
+		t.newChain(tableMangle, ChainDivert)
+		t.rule(tableMangle, "-A", ChainDivert, "-j", "MARK", "--set-mark", mark)
+		t.rule(tableMangle, "-A", ChainDivert, "-j", "ACCEPT")
+		// Route all the marked packets to the loopback interface.
+		routes = [][]string{
+			{"ip", "-f", "inet", "rule", "add", "fwmark", mark, "lookup", routeTable},
+			{"ip", "-f", "inet", "route", "add", "local", "default", "dev", "lo", "table", routeTable},
+		}
+		// New connections are redirected to Envoy by ISTIO_TPROXY.
+		t.newChain(tableMangle, ChainTProxy)
+		t.rule(tableMangle, "-A", ChainTProxy, "!", "-d", ipv4.loopback, "-p", "tcp", "-j", "TPROXY",
+			"--tproxy-mark", mark+"/0xffffffff", "--on-port", proxyPort)
+		inboundTable = tableMangle
+	}
+	t.newChain(inboundTable, ChainInbound)
+	t.rule(inboundTable, "-A", "PREROUTING", "-p", "tcp", "-j", ChainInbound)
+
+	redirect := func(match ...string) {
+		if tproxy {
+			// Packets of established sockets are routed to the loopback interface, new
+			// connections are redirected with TPROXY.
+			t.rule(tableMangle, append(append([]string{"-A", ChainInbound, "-p", "tcp"}, match...),
+				"-m", "socket", "-j", ChainDivert)...)
+			t.rule(tableMangle, append(append([]string{"-A", ChainInbound, "-p", "tcp"}, match...),
+				"-j", ChainTProxy)...)
+		} else {
+			t.rule(tableNat, append(append([]string{"-A", ChainInbound, "-p", "tcp"}, match...),
+				"-j", ChainInRedirect)...)
+		}
+	}
+	if isWildcard(c.InboundPortsInclude) {
+		// Makes sure SSH is not redirected.
+		t.rule(inboundTable, "-A", ChainInbound, "-p", "tcp", "--dport", "22", "-j", "RETURN")
+		for _, port := range c.InboundPortsExclude {
+			t.rule(inboundTable, "-A", ChainInbound, "-p", "tcp", "--dport", port, "-j", "RETURN")
+		}
+		redirect()
+	} else {
+		for _, port := range c.InboundPortsInclude {
+			redirect("--dport", port)
+		}
+	}
+	return routes
+}
+
+// buildOutbound adds the rules redirecting the outbound traffic to Envoy.
+func buildOutbound(c *Config, t *tables, f family, include, exclude []string) {
+	t.newChain(tableNat, ChainOutput)
+	t.rule(tableNat, "-A", "OUTPUT", "-p", "tcp", "-j", ChainOutput)
+
+	if !c.DisableRedirectionOnLocalLoopback {
+		// Redirect app calls to back itself via Envoy when using the service VIP or endpoint
+		// address, e.g. appN => Envoy (client) => Envoy (server) => appN.
+		t.rule(tableNat, "-A", ChainOutput, "-o", "lo", "!", "-d", f.loopback, "-j", ChainRedirect)
+	}
+	// Avoid infinite loops. Don't redirect Envoy traffic directly back to Envoy for non-loopback traffic.
+	for _, uid := range c.ProxyUIDs {
+		t.rule(tableNat, "-A", ChainOutput, "-m", "owner", "--uid-owner", uid, "-j", "RETURN")
+	}
+	for _, gid := range c.ProxyGIDs {
+		t.rule(tableNat, "-A", ChainOutput, "-m", "owner", "--gid-owner", gid, "-j", "RETURN")
+	}
+	// Skip redirection for Envoy-aware applications and container-to-container traffic both of
+	// which explicitly use localhost.
+	t.rule(tableNat, "-A", ChainOutput, "-d", f.loopback, "-j", "RETURN")
+
+	// Exclusions must be applied before inclusions.
+	for _, cidr := range exclude {
+		t.rule(tableNat, "-A", ChainOutput, "-d", cidr, "-j", "RETURN")
+	}
+	for _, iface := range c.KubevirtInterfaces {
+		t.rule(tableNat, "-I", "PREROUTING", "1", "-i", iface, "-j", "RETURN")
+	}
+
+	if len(include) == 0 {
+		return
+	}
+	if isWildcard(include) {
+		// Redirect all remaining outbound traffic to Envoy.
+		t.rule(tableNat, "-A", ChainOutput, "-j", ChainRedirect)
+		for _, iface := range c.KubevirtInterfaces {
+			t.rule(tableNat, "-I", "PREROUTING", "1", "-i", iface, "-j", ChainRedirect)
+		}
+		return
+	}
+	for _, cidr := range include {
+		for _, iface := range c.KubevirtInterfaces {
+			t.rule(tableNat, "-I", "PREROUTING", "1", "-i", iface, "-d", cidr, "-j", ChainRedirect)
+		}
+		t.rule(tableNat, "-A", ChainOutput, "-d", cidr, "-j", ChainRedirect)
+	}
+	// All other traffic is not redirected.
+	t.rule(tableNat, "-A", ChainOutput, "-j", "RETURN")
+}
+
+// buildDNS adds the rules redirecting the DNS queries to the DNS proxy of pilot-agent. The queries
+// over TCP, sent again when a response over UDP is truncated, are redirected before the outbound
+// traffic is captured by Envoy.
+func buildDNS(c *Config, t *tables) {
+	if c.DNSCapturePort == 0 {
+		return
+	}
+	t.newChain(tableNat, ChainDNS)
+	t.rule(tableNat, "-A", "OUTPUT", "-p", "udp", "--dport", "53", "-j", ChainDNS)
+	t.rule(tableNat, "-A", "OUTPUT", "-p", "tcp", "--dport", "53", "-j", ChainDNS)
+	// The queries of the DNS proxy itself are forwarded to the upstream resolvers.
+	for _, uid := range c.ProxyUIDs {
+		t.rule(tableNat, "-A", ChainDNS, "-m", "owner", "--uid-owner", uid, "-j", "RETURN")
+	}
+	for _, gid := range c.ProxyGIDs {
+		t.rule(tableNat, "-A", ChainDNS, "-m", "owner", "--gid-owner", gid, "-j", "RETURN")
+	}
+	t.rule(tableNat, "-A", ChainDNS, "-p", "udp", "-j", "REDIRECT", "--to-port", strconv.Itoa(c.DNSCapturePort))
+	t.rule(tableNat, "-A", ChainDNS, "-p", "tcp", "-j", "REDIRECT", "--to-port", strconv.Itoa(c.DNSCapturePort))
+}
+
+// CleanupCommands returns the commands removing the rules installed by a previous run.
+func CleanupCommands(withIPv6 bool) [][]string {
+	families := []family{ipv4}
+	if withIPv6 {
+		families = append(families, ipv6)
+	}
+	var out [][]string
+	for _, f := range families {
+		out = append(out,
+			[]string{f.command, "-t", tableNat, "-D", "PREROUTING", "-p", "tcp", "-j", ChainInbound},
+			[]string{f.command, "-t", tableMangle, "-D", "PREROUTING", "-p", "tcp", "-j", ChainInbound},
+			[]string{f.command, "-t", tableNat, "-D", "OUTPUT", "-p", "tcp", "-j", ChainOutput},
+			[]string{f.command, "-t", tableNat, "-D", "OUTPUT", "-p", "udp", "--dport", "53", "-j", ChainDNS},
+			[]string{f.command, "-t", tableNat, "-D", "OUTPUT", "-p", "tcp", "--dport", "53", "-j", ChainDNS})
+		// The redirect chains must be last, the others refer to them.
+		for _, chain := range []struct{ table, name string }{
+			{tableNat, ChainOutput},
+			{tableNat, ChainDNS},
+			{tableNat, ChainInbound},
+			{tableMangle, ChainInbound},
+			{tableMangle, ChainDivert},
+			{tableMangle, ChainTProxy},
+			{tableNat, ChainRedirect},
+			{tableNat, ChainInRedirect},
+		} {
+			out = append(out,
+				[]string{f.command, "-t", chain.table, "-F", chain.name},
+				[]string{f.command, "-t", chain.table, "-X", chain.name})
+		}
+	}
+	return out
+}
+
+// String returns the commands and the iptables-restore inputs of the ruleset, as they are run
+// when applying it.
+func (r *Ruleset) String() string {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "# Cleanup, errors are ignored")
+	for _, cmd := range r.Cleanup {
+		fmt.Fprintln(&b, strings.Join(cmd, " "))
+	}
+	fmt.Fprintln(&b, "# iptables-restore --noflush")
+	fmt.Fprint(&b, r.IPv4)
+	fmt.Fprintln(&b, "# ip6tables-restore --noflush")
+	fmt.Fprint(&b, r.IPv6)
+	if len(r.Routes) > 0 {
+		fmt.Fprintln(&b, "# Routes")
+		for _, cmd := range r.Routes {
+			fmt.Fprintln(&b, strings.Join(cmd, " "))
+		}
+	}
+	return b.String()
+}
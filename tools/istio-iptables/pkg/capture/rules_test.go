@@ -0,0 +1,205 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pilot/test/util"
+)
+
+func defaultConfig() *Config {
+	return &Config{
+		ProxyPort:               15001,
+		ProxyUIDs:               []string{"1337", "0"},
+		ProxyGIDs:               []string{"1337", "0"},
+		InboundTProxyMark:       1337,
+		InboundTProxyRouteTable: 133,
+	}
+}
+
+func TestBuild(t *testing.T) {
+	cases := []struct {
+		name   string
+		config func(c *Config)
+	}{
+		{"empty", func(c *Config) {}},
+		{"inbound-wildcard", func(c *Config) {
+			c.InboundPortsInclude = []string{"*"}
+			c.InboundPortsExclude = []string{"15020", "15090"}
+		}},
+		{"inbound-ports", func(c *Config) {
+			c.InboundPortsInclude = []string{"80", "8080"}
+		}},
+		{"inbound-capture-port", func(c *Config) {
+			c.InboundCapturePort = 15006
+			c.InboundPortsInclude = []string{"*"}
+		}},
+		{"tproxy-wildcard", func(c *Config) {
+			c.InboundInterceptionMode = InterceptionModeTProxy
+			c.InboundPortsInclude = []string{"*"}
+			c.InboundPortsExclude = []string{"15020"}
+			c.OutboundIPRangesInclude = []string{"*"}
+		}},
+		{"tproxy-ports", func(c *Config) {
+			c.InboundInterceptionMode = InterceptionModeTProxy
+			c.InboundPortsInclude = []string{"80", "8080"}
+		}},
+		{"outbound-wildcard", func(c *Config) {
+			c.OutboundIPRangesInclude = []string{"*"}
+			c.OutboundIPRangesExclude = []string{"10.0.0.1", "169.254.0.0/16", "fd00::/8"}
+		}},
+		{"outbound-ranges", func(c *Config) {
+			c.OutboundIPRangesInclude = []string{"10.96.0.0/12", "192.168.0.1", "fd00::/8"}
+		}},
+		{"kubevirt", func(c *Config) {
+			c.KubevirtInterfaces = []string{"net1", "net2"}
+			c.OutboundIPRangesInclude = []string{"10.96.0.0/12"}
+		}},
+		{"kubevirt-wildcard", func(c *Config) {
+			c.KubevirtInterfaces = []string{"net1"}
+			c.OutboundIPRangesInclude = []string{"*"}
+			c.EnableInboundIPv6 = true
+		}},
+		{"dns", func(c *Config) {
+			c.OutboundIPRangesInclude = []string{"*"}
+			c.DNSCapturePort = 15053
+			c.EnableInboundIPv6 = true
+		}},
+		{"ipv6", func(c *Config) {
+			c.EnableInboundIPv6 = true
+			c.InboundPortsInclude = []string{"*"}
+			c.OutboundIPRangesInclude = []string{"*"}
+			c.OutboundIPRangesExclude = []string{"10.0.0.1", "fd00::1"}
+		}},
+		{"disable-local-loopback", func(c *Config) {
+			c.DisableRedirectionOnLocalLoopback = true
+			c.InboundPortsInclude = []string{"*"}
+			c.OutboundIPRangesInclude = []string{"*"}
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := defaultConfig()
+			tc.config(config)
+			if err := config.Validate(); err != nil {
+				t.Fatalf("invalid test config: %v", err)
+			}
+			util.CompareContent([]byte(Build(config).String()), "testdata/"+tc.name+".golden", t)
+		})
+	}
+}
+
+// TestBuildCombinations checks that every combination of the flags produces well formed
+// iptables-restore inputs.
+func TestBuildCombinations(t *testing.T) {
+	modes := []string{"", InterceptionModeRedirect, InterceptionModeTProxy}
+	inbound := [][]string{nil, {"*"}, {"80", "443"}}
+	outbound := [][]string{nil, {"*"}, {"10.0.0.0/8", "fd00::/8"}}
+	bools := []bool{false, true}
+
+	for _, mode := range modes {
+		for _, in := range inbound {
+			for _, out := range outbound {
+				for _, ipv6 := range bools {
+					for _, noLoopback := range bools {
+						for _, kubevirt := range bools {
+							config := defaultConfig()
+							config.InboundInterceptionMode = mode
+							config.InboundPortsInclude = in
+							config.InboundPortsExclude = []string{"15020"}
+							config.OutboundIPRangesInclude = out
+							config.OutboundIPRangesExclude = []string{"169.254.169.254"}
+							config.EnableInboundIPv6 = ipv6
+							config.DisableRedirectionOnLocalLoopback = noLoopback
+							if kubevirt {
+								config.KubevirtInterfaces = []string{"net1"}
+							}
+							name := fmt.Sprintf("mode=%q,in=%v,out=%v,ipv6=%v,noLoopback=%v,kubevirt=%v",
+								mode, in, out, ipv6, noLoopback, kubevirt)
+
+							rules := Build(config)
+							if err := checkRestoreInput(rules.IPv4); err != nil {
+								t.Errorf("%s: invalid IPv4 rules: %v\n%s", name, err, rules.IPv4)
+							}
+							if err := checkRestoreInput(rules.IPv6); err != nil {
+								t.Errorf("%s: invalid IPv6 rules: %v\n%s", name, err, rules.IPv6)
+							}
+							if tproxy := mode == InterceptionModeTProxy && len(in) > 0; tproxy != (len(rules.Routes) > 0) {
+								t.Errorf("%s: got routes %v", name, rules.Routes)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+var builtinChains = map[string]bool{
+	"PREROUTING": true, "INPUT": true, "FORWARD": true, "OUTPUT": true, "POSTROUTING": true,
+}
+
+// checkRestoreInput verifies that the tables are committed, and that the chains are declared
+// before being used.
+func checkRestoreInput(input string) error {
+	if input == "" {
+		return nil
+	}
+	var declared map[string]bool
+	inTable := false
+	for _, line := range strings.Split(strings.TrimSuffix(input, "\n"), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "*"):
+			if inTable {
+				return fmt.Errorf("table %s started before COMMIT", line)
+			}
+			inTable = true
+			declared = map[string]bool{}
+		case line == "COMMIT":
+			if !inTable {
+				return fmt.Errorf("COMMIT outside of a table")
+			}
+			inTable = false
+		case strings.HasPrefix(line, ":"):
+			declared[strings.TrimPrefix(fields[0], ":")] = true
+		case strings.HasPrefix(line, "-A") || strings.HasPrefix(line, "-I") || strings.HasPrefix(line, "-F"):
+			if !inTable {
+				return fmt.Errorf("rule %q outside of a table", line)
+			}
+			chains := []string{fields[1]}
+			for i, field := range fields {
+				if field == "-j" && i+1 < len(fields) && strings.HasPrefix(fields[i+1], "ISTIO_") {
+					chains = append(chains, fields[i+1])
+				}
+			}
+			for _, chain := range chains {
+				if !builtinChains[chain] && !declared[chain] {
+					return fmt.Errorf("chain %s of rule %q is not declared", chain, line)
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected line %q", line)
+		}
+	}
+	if inTable {
+		return fmt.Errorf("missing COMMIT")
+	}
+	return nil
+}